@@ -7,14 +7,47 @@ import (
 	"github.com/blang/semver/v4"
 	"github.com/lippkg/lip/internal/cmd/cmdlip"
 	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/interrupt"
+	"github.com/lippkg/lip/internal/render"
+	"github.com/lippkg/lip/internal/selfupdate"
 
 	log "github.com/sirupsen/logrus"
 )
 
 var defaultConfig context.Config = context.Config{
-	GitHubMirrorURL:  "https://github.com",
-	GoModuleProxyURL: "https://goproxy.io",
-	ProxyURL:         "",
+	AdvisoryDBURL:          "",
+	ConnectTimeoutMs:       30000,
+	CredentialStore:        "file",
+	DNSServers:             "",
+	DownloadRateLimit:      "",
+	DownloadTimeoutMs:      1800000,
+	ExtraGitHubMirrorURLs:  "",
+	ExtraGoModuleProxyURLs: "",
+	GitHubAPIURL:           "https://api.github.com",
+	GitHubMirrorURL:        "https://github.com",
+	GitHubToken:            "",
+	GoModuleProxyURL:       "https://goproxy.io",
+	HappyEyeballsDelayMs:   0,
+	HostOverrides:          "",
+	InstallTimeoutMs:       7200000,
+	LANCacheDiscoveryMs:    0,
+	Language:               "",
+	OCIRegistryURL:         "",
+	PerDownloadRateLimit:   "",
+	ProxyURL:               "",
+	Quarantine:             "keep",
+	RegistryURL:            "",
+	RetryBackoffMs:         1000,
+	RetryBudgetMs:          60000,
+	RetryMaxAttempts:       3,
+	SnapshotCount:          3,
+	Theme:                  "default",
+	ToothSource:            "goproxy",
+	UpdateCheckHours:       0,
+	UpdatePolicy:           "all",
+	WorkspaceType:          "auto",
 }
 
 var lipVersion semver.Version = semver.MustParse("0.21.3")
@@ -26,20 +59,43 @@ func main() {
 		log.SetFormatter(&nested.Formatter{})
 	}
 
-	ctx := context.New(defaultConfig, lipVersion)
+	// Style output only on terminals that support it. The theme is applied once the config file
+	// is loaded.
+	render.Init()
 
-	if err := ctx.CreateDirStructure(); err != nil {
-		log.Errorf("\n\tcannot create directory structure\n\t%v", err.Error())
-		return
-	}
+	// Use the language of the environment until the config file is loaded.
+	i18n.SetLanguage(i18n.DetectLanguage(""))
+
+	// Ctrl-C and SIGTERM cancel the operation in progress, which cleans up before lip exits.
+	ctx := context.New(defaultConfig, lipVersion, context.WithGoContext(interrupt.Notify()))
+
+	// The directory structure is created by cmdlip.Run, which may switch to an overlay
+	// directory first.
 
 	if err := ctx.LoadOrCreateConfigFile(); err != nil {
-		log.Errorf("\n\tcannot load or create config file\n\t%v", err.Error())
-		return
+		log.Errorf(i18n.T("\n\tcannot load or create config file\n\t%v"), err.Error())
+		os.Exit(errcode.GeneralExitCode)
+	}
+
+	i18n.SetLanguage(i18n.DetectLanguage(ctx.Config().Language))
+
+	if theme, err := ctx.Theme(); err != nil {
+		log.Warnf(i18n.T("\n\tcannot apply theme\n\t%v"), err.Error())
+	} else {
+		render.SetTheme(theme)
+	}
+
+	// Remove the executable left behind by a previous self update.
+	if err := selfupdate.CleanUp(); err != nil {
+		log.Warnf(i18n.T("\n\tcannot clean up after self update\n\t%v"), err.Error())
 	}
 
 	if err := cmdlip.Run(ctx, os.Args[1:]); err != nil {
-		log.Errorf("\n\t%v", err.Error())
-		return
+		if code, ok := errcode.GetCode(err); ok {
+			log.WithField("code", code).Errorf("\n\t%v", render.Style(render.ErrorRole, err.Error()))
+		} else {
+			log.Errorf("\n\t%v", render.Style(render.ErrorRole, err.Error()))
+		}
+		os.Exit(errcode.ExitCode(err))
 	}
 }
@@ -0,0 +1,208 @@
+package advisory
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+
+	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/network"
+	"github.com/lippkg/lip/internal/registry"
+)
+
+// Database is a security advisory database, listing known vulnerabilities of teeth.
+type Database struct {
+	FormatVersion int        `json:"format_version"`
+	Advisories    []Advisory `json:"advisories"`
+}
+
+// Advisory is a known vulnerability of a tooth.
+type Advisory struct {
+	// ID is the identifier of the advisory, e.g. LIP-2024-0001 or a CVE ID.
+	ID    string `json:"id"`
+	Tooth string `json:"tooth"`
+	// Affected is the version range of the affected versions, in the syntax of dependencies,
+	// e.g. <1.2.3 or >=1.0.0 <1.2.3.
+	Affected string   `json:"affected"`
+	Severity Severity `json:"severity"`
+	Summary  string   `json:"summary"`
+	// Fixed is the versions fixing the vulnerability, e.g. the first fixed version of each
+	// supported release line.
+	Fixed []string `json:"fixed,omitempty"`
+	URL   string   `json:"url,omitempty"`
+}
+
+// Severity is how severe a vulnerability is.
+type Severity string
+
+const (
+	LowSeverity      Severity = "low"
+	MediumSeverity   Severity = "medium"
+	HighSeverity     Severity = "high"
+	CriticalSeverity Severity = "critical"
+)
+
+// Match is an advisory affecting an installed version of a tooth.
+type Match struct {
+	Advisory Advisory       `json:"advisory"`
+	Version  semver.Version `json:"version"`
+	// FixedVersion is the lowest version fixing the vulnerability that is newer than the
+	// installed version. Nil if there is none.
+	FixedVersion *semver.Version `json:"fixed_version,omitempty"`
+}
+
+const expectedFormatVersion = 1
+
+// severityRanks orders severities from the least severe.
+var severityRanks = map[Severity]int{
+	LowSeverity:      1,
+	MediumSeverity:   2,
+	HighSeverity:     3,
+	CriticalSeverity: 4,
+}
+
+// ParseSeverity parses a severity.
+func ParseSeverity(s string) (Severity, error) {
+	if _, ok := severityRanks[Severity(s)]; !ok {
+		return "", errcode.Errorf(errcode.InvalidArgument, "invalid severity %v, expected low, medium, high or critical",
+			s)
+	}
+
+	return Severity(s), nil
+}
+
+// AtLeast returns whether the severity is at least as severe as another. Unknown severities
+// are less severe than any known one.
+func (s Severity) AtLeast(other Severity) bool {
+	return severityRanks[s] >= severityRanks[other]
+}
+
+// MakeDatabase parses the given jsonBytes and returns a Database.
+func MakeDatabase(jsonBytes []byte) (Database, error) {
+	var database Database
+	if err := json.Unmarshal(jsonBytes, &database); err != nil {
+		return Database{}, fmt.Errorf("failed to unmarshal advisory database\n\t%w", err)
+	}
+
+	if database.FormatVersion != expectedFormatVersion {
+		return Database{}, fmt.Errorf("unsupported format version: %v", database.FormatVersion)
+	}
+
+	for _, advisory := range database.Advisories {
+		if _, err := semver.ParseRange(advisory.Affected); err != nil {
+			return Database{}, fmt.Errorf("invalid affected versions %v of advisory %v\n\t%w", advisory.Affected,
+				advisory.ID, err)
+		}
+	}
+
+	return database, nil
+}
+
+// Fetch fetches the advisory database at AdvisoryDBURL, or the one of the registry if it is
+// not set. In offline mode, the cached database is used.
+func Fetch(ctx *context.Context) (Database, error) {
+	databaseURL, err := getDatabaseURL(ctx)
+	if err != nil {
+		return Database{}, err
+	}
+
+	proxyURL, err := ctx.ProxyURL()
+	if err != nil {
+		return Database{}, fmt.Errorf("failed to get proxy URL\n\t%w", err)
+	}
+
+	cacheDir, err := ctx.CacheDir()
+	if err != nil {
+		return Database{}, fmt.Errorf("failed to get cache directory\n\t%w", err)
+	}
+
+	var content []byte
+	if ctx.Offline() {
+		content, err = ctx.Network().GetCachedContent(databaseURL, cacheDir)
+	} else {
+		content, err = ctx.Network().GetContentWithRevalidation(ctx.GoContext(), databaseURL, proxyURL, nil,
+			ctx.RetryPolicy(), cacheDir)
+	}
+	if err != nil {
+		return Database{}, fmt.Errorf("failed to fetch advisory database %v\n\t%w", databaseURL, err)
+	}
+
+	return MakeDatabase(content)
+}
+
+// Match returns the advisories affecting a version of a tooth, ordered from the most severe.
+func (db Database) Match(toothRepoPath string, version semver.Version) []Match {
+	matches := make([]Match, 0)
+
+	for _, advisory := range db.Advisories {
+		if advisory.Tooth != toothRepoPath {
+			continue
+		}
+
+		// Ranges are validated by MakeDatabase.
+		affected, _ := semver.ParseRange(advisory.Affected)
+		if !affected(version) {
+			continue
+		}
+
+		matches = append(matches, Match{
+			Advisory:     advisory,
+			Version:      version,
+			FixedVersion: getFixedVersion(advisory, version),
+		})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return severityRanks[matches[i].Advisory.Severity] > severityRanks[matches[j].Advisory.Severity]
+	})
+
+	return matches
+}
+
+// ---------------------------------------------------------------------
+
+// getDatabaseURL returns the URL of the advisory database.
+func getDatabaseURL(ctx *context.Context) (*url.URL, error) {
+	advisoryDBURL, err := ctx.AdvisoryDBURL()
+	if err != nil {
+		return nil, err
+	}
+
+	if advisoryDBURL.String() != "" {
+		return advisoryDBURL, nil
+	}
+
+	if !registry.IsConfigured(ctx) {
+		return nil, errcode.Errorf(errcode.InvalidArgument,
+			"no advisory database is configured. Set AdvisoryDBURL or RegistryURL with lip config")
+	}
+
+	registryURL, err := ctx.RegistryURL()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get registry URL\n\t%w", err)
+	}
+
+	return network.GenerateRegistryAdvisoryDBURL(registryURL)
+}
+
+// getFixedVersion returns the lowest fixed version of an advisory newer than a version, or nil
+// if there is none. Fixed versions that cannot be parsed are skipped.
+func getFixedVersion(advisory Advisory, version semver.Version) *semver.Version {
+	var fixedVersion *semver.Version
+
+	for _, versionString := range advisory.Fixed {
+		candidate, err := semver.Parse(versionString)
+		if err != nil || !candidate.GT(version) {
+			continue
+		}
+
+		if fixedVersion == nil || candidate.LT(*fixedVersion) {
+			fixedVersion = &candidate
+		}
+	}
+
+	return fixedVersion
+}
@@ -0,0 +1,229 @@
+package bundle
+
+import (
+	gozip "archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/path"
+	"github.com/lippkg/lip/internal/vfs"
+	"github.com/lippkg/lip/internal/zip"
+)
+
+// ManifestFileName is the name of the bundle manifest in a bundle.
+const ManifestFileName = "bundle.json"
+
+// VendorDirName is the name of the directory in a bundle holding the vendored archives, laid
+// out like the vendor directory of a workspace.
+const VendorDirName = "vendor"
+
+const expectedFormatVersion = 1
+
+// Manifest describes the installation exported to a bundle.
+type Manifest struct {
+	FormatVersion int     `json:"format_version"`
+	LipVersion    string  `json:"lip_version"`
+	Teeth         []Tooth `json:"teeth"`
+}
+
+// Tooth is an installed tooth in a bundle.
+type Tooth struct {
+	ToothRepoPath string `json:"tooth"`
+	Version       string `json:"version"`
+	IsExplicit    bool   `json:"is_explicit"`
+
+	// Override is the version the tooth is forced to by an override, or empty if it is not
+	// overridden.
+	Override string `json:"override,omitempty"`
+}
+
+// NewManifest creates a bundle manifest.
+func NewManifest(lipVersion string, teeth []Tooth) Manifest {
+	return Manifest{
+		FormatVersion: expectedFormatVersion,
+		LipVersion:    lipVersion,
+		Teeth:         teeth,
+	}
+}
+
+// MakeStagingDir creates an empty directory in the cache directory to prepare or extract a
+// bundle in. The caller should remove it when done.
+func MakeStagingDir(ctx *context.Context) (path.Path, error) {
+	cacheDir, err := ctx.CacheDir()
+	if err != nil {
+		return path.Path{}, fmt.Errorf("failed to get cache directory\n\t%w", err)
+	}
+
+	stagingDirStr, err := vfs.MkdirTemp(ctx.FS(), cacheDir.LocalString(), "bundle-*")
+	if err != nil {
+		return path.Path{}, fmt.Errorf("failed to create staging directory\n\t%w", err)
+	}
+
+	stagingDir, err := path.Parse(stagingDirStr)
+	if err != nil {
+		return path.Path{}, fmt.Errorf("failed to parse staging directory %v\n\t%w", stagingDirStr, err)
+	}
+
+	return stagingDir, nil
+}
+
+// Pack writes a bundle with the manifest and the files of a vendor directory on a file system.
+func Pack(fs vfs.FS, bundlePath path.Path, manifest Manifest, vendorDir path.Path) error {
+	bundleFile, err := fs.OpenFile(bundlePath.LocalString(), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create bundle %v\n\t%w", bundlePath.LocalString(), err)
+	}
+	defer bundleFile.Close()
+
+	zipWriter := gozip.NewWriter(bundleFile)
+
+	jsonBytes, err := json.MarshalIndent(manifest, "", "    ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal bundle manifest\n\t%w", err)
+	}
+
+	writer, err := zipWriter.Create(ManifestFileName)
+	if err != nil {
+		return fmt.Errorf("failed to create %v in bundle\n\t%w", ManifestFileName, err)
+	}
+
+	if _, err := writer.Write(jsonBytes); err != nil {
+		return fmt.Errorf("failed to write %v to bundle\n\t%w", ManifestFileName, err)
+	}
+
+	err = vfs.Walk(fs, vendorDir.LocalString(), func(filePathStr string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		relPathStr, err := filepath.Rel(vendorDir.LocalString(), filePathStr)
+		if err != nil {
+			return err
+		}
+
+		// Archives are already compressed.
+		writer, err := zipWriter.CreateHeader(&gozip.FileHeader{
+			Name:     VendorDirName + "/" + filepath.ToSlash(relPathStr),
+			Method:   gozip.Store,
+			Modified: info.ModTime(),
+		})
+		if err != nil {
+			return err
+		}
+
+		file, err := fs.Open(filePathStr)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		_, err = io.Copy(writer, file)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to pack vendor directory %v\n\t%w", vendorDir.LocalString(), err)
+	}
+
+	if err := zipWriter.Close(); err != nil {
+		return fmt.Errorf("failed to write bundle %v\n\t%w", bundlePath.LocalString(), err)
+	}
+
+	return nil
+}
+
+// Unpack extracts a bundle into a directory on a file system and returns its manifest. The
+// vendored archives are extracted to the VendorDirName subdirectory.
+func Unpack(fs vfs.FS, bundlePath path.Path, dir path.Path) (Manifest, error) {
+	r, err := zip.OpenReader(fs, bundlePath.LocalString())
+	if err != nil {
+		return Manifest{}, errcode.Errorf(errcode.InvalidArgument, "failed to open bundle %v: %v",
+			bundlePath.LocalString(), err)
+	}
+	defer r.Close()
+
+	for _, file := range r.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+
+		// Only extract known files, so that a bundle cannot write outside the directory.
+		if file.Name != ManifestFileName && !strings.HasPrefix(file.Name, VendorDirName+"/") {
+			return Manifest{}, errcode.Errorf(errcode.InvalidArgument, "unexpected file %v in bundle %v",
+				file.Name, bundlePath.LocalString())
+		}
+
+		filePath, err := path.Parse(file.Name)
+		if err != nil {
+			return Manifest{}, errcode.Errorf(errcode.InvalidArgument, "unexpected file %v in bundle %v",
+				file.Name, bundlePath.LocalString())
+		}
+
+		if err := extractFile(fs, file, dir.Join(filePath)); err != nil {
+			return Manifest{}, err
+		}
+	}
+
+	manifestPath := dir.Join(path.MustParse(ManifestFileName))
+
+	jsonBytes, err := fs.ReadFile(manifestPath.LocalString())
+	if os.IsNotExist(err) {
+		return Manifest{}, errcode.Errorf(errcode.InvalidArgument, "%v is not a bundle created by lip export",
+			bundlePath.LocalString())
+	} else if err != nil {
+		return Manifest{}, fmt.Errorf("failed to read bundle manifest\n\t%w", err)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(jsonBytes, &manifest); err != nil {
+		return Manifest{}, fmt.Errorf("failed to unmarshal bundle manifest\n\t%w", err)
+	}
+
+	if manifest.FormatVersion != expectedFormatVersion {
+		return Manifest{}, errcode.Errorf(errcode.InvalidArgument, "unsupported format version of bundle: %v",
+			manifest.FormatVersion)
+	}
+
+	return manifest, nil
+}
+
+// ---------------------------------------------------------------------
+
+// extractFile extracts a file in a zip archive to a path on a file system.
+func extractFile(fs vfs.FS, file *gozip.File, filePath path.Path) error {
+	parentDir, err := filePath.Dir()
+	if err != nil {
+		return fmt.Errorf("failed to get parent directory of %v\n\t%w", filePath.LocalString(), err)
+	}
+
+	if err := fs.MkdirAll(parentDir.LocalString(), 0755); err != nil {
+		return fmt.Errorf("failed to create directory %v\n\t%w", parentDir.LocalString(), err)
+	}
+
+	reader, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open %v in bundle\n\t%w", file.Name, err)
+	}
+	defer reader.Close()
+
+	writer, err := fs.Create(filePath.LocalString())
+	if err != nil {
+		return fmt.Errorf("failed to create %v\n\t%w", filePath.LocalString(), err)
+	}
+	defer writer.Close()
+
+	if _, err := io.Copy(writer, reader); err != nil {
+		return fmt.Errorf("failed to extract %v\n\t%w", file.Name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,295 @@
+package cacheproxy
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/path"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+// Handler serves the Go module zip files in the cache in the layout of a Go module proxy, so
+// that the cache can be used as GoModuleProxyURL by other lip instances. Zip files downloaded
+// from GoModuleProxyURL or any of ExtraGoModuleProxyURLs are served. For each module, the
+// version list, the .info, .mod and .zip files of the cached versions, and the latest cached
+// version are served. The cache is read on every request, so files added to it are served
+// without restarting.
+type Handler struct {
+	ctx *context.Context
+
+	cacheDir          path.Path
+	goModuleProxyURLs []*url.URL
+}
+
+// versionInfo is the content of a .info file of a Go module proxy.
+type versionInfo struct {
+	Version string `json:"Version"`
+}
+
+// NewHandler makes a handler serving the cache of ctx.
+func NewHandler(ctx *context.Context) (*Handler, error) {
+	cacheDir, err := ctx.CacheDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cache directory\n\t%w", err)
+	}
+
+	goModuleProxyURL, err := ctx.GoModuleProxyURL()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Go module proxy URL\n\t%w", err)
+	}
+
+	extraGoModuleProxyURLs, err := ctx.ExtraGoModuleProxyURLs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get extra Go module proxy URLs\n\t%w", err)
+	}
+
+	return &Handler{
+		ctx:               ctx,
+		cacheDir:          cacheDir,
+		goModuleProxyURLs: append([]*url.URL{goModuleProxyURL}, extraGoModuleProxyURLs...),
+	}, nil
+}
+
+// ServeHTTP serves a request of the Go module proxy protocol. Only cached files are served, and
+// the cache is never modified.
+func (handler *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	debugLogger := handler.ctx.Logger().WithFields(log.Fields{
+		"package": "cacheproxy",
+		"method":  "Handler.ServeHTTP",
+	})
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Requests are <escaped module path>/@v/<file> or <escaped module path>/@latest.
+	requestPath := strings.TrimPrefix(r.URL.Path, "/")
+
+	if strings.HasSuffix(requestPath, "/@latest") {
+		handler.serveLatest(w, r, strings.TrimSuffix(requestPath, "/@latest"))
+		return
+	}
+
+	escapedModulePath, fileName, ok := strings.Cut(requestPath, "/@v/")
+	if !ok || strings.Contains(fileName, "/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	modulePath, err := module.UnescapePath(escapedModulePath)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if fileName == "list" {
+		versions, err := handler.getCachedVersions(escapedModulePath)
+		if err != nil {
+			debugLogger.Debugf("Failed to list versions of %v: %v", modulePath, err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, version := range versions {
+			fmt.Fprintln(w, version)
+		}
+		return
+	}
+
+	dotIndex := strings.LastIndex(fileName, ".")
+	if dotIndex < 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	version, err := module.UnescapeVersion(fileName[:dotIndex])
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	zipPath, ok := handler.findZipFile(escapedModulePath, fileName[:dotIndex])
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch fileName[dotIndex:] {
+	case ".zip":
+		debugLogger.Debugf("Serving %v to %v", zipPath.LocalString(), r.RemoteAddr)
+		w.Header().Set("Content-Type", "application/zip")
+		http.ServeFile(w, r, zipPath.LocalString())
+
+	case ".info":
+		writeInfo(w, version)
+
+	case ".mod":
+		goModContent, err := readGoMod(zipPath, modulePath, version)
+		if err != nil {
+			debugLogger.Debugf("Failed to read go.mod of %v@%v: %v", modulePath, version, err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write(goModContent)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// ---------------------------------------------------------------------
+
+// serveLatest serves the .info file of the latest cached version of a module.
+func (handler *Handler) serveLatest(w http.ResponseWriter, r *http.Request, escapedModulePath string) {
+	debugLogger := handler.ctx.Logger().WithFields(log.Fields{
+		"package": "cacheproxy",
+		"method":  "Handler.serveLatest",
+	})
+
+	versions, err := handler.getCachedVersions(escapedModulePath)
+	if err != nil {
+		debugLogger.Debugf("Failed to list versions of %v: %v", escapedModulePath, err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if len(versions) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	writeInfo(w, versions[len(versions)-1])
+}
+
+// getCachedVersions returns the versions of a module whose zip files are cached, in ascending
+// order.
+func (handler *Handler) getCachedVersions(escapedModulePath string) ([]string, error) {
+	dirEntries, err := os.ReadDir(handler.cacheDir.LocalString())
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read cache directory\n\t%w", err)
+	}
+
+	prefixes := make([]string, 0, len(handler.goModuleProxyURLs))
+	for _, goModuleProxyURL := range handler.goModuleProxyURLs {
+		prefixURL, err := goModuleProxyURL.Parse(escapedModulePath + "/@v/")
+		if err != nil {
+			continue
+		}
+
+		prefixes = append(prefixes, prefixURL.String())
+	}
+
+	versionSet := make(map[string]bool)
+	for _, dirEntry := range dirEntries {
+		if dirEntry.IsDir() {
+			continue
+		}
+
+		cachedURL, err := url.QueryUnescape(dirEntry.Name())
+		if err != nil {
+			continue
+		}
+
+		for _, prefix := range prefixes {
+			if !strings.HasPrefix(cachedURL, prefix) || !strings.HasSuffix(cachedURL, ".zip") {
+				continue
+			}
+
+			escapedVersion := strings.TrimSuffix(strings.TrimPrefix(cachedURL, prefix), ".zip")
+			if strings.Contains(escapedVersion, "/") {
+				continue
+			}
+
+			version, err := module.UnescapeVersion(escapedVersion)
+			if err != nil || !semver.IsValid(version) {
+				continue
+			}
+
+			versionSet[version] = true
+		}
+	}
+
+	versions := make([]string, 0, len(versionSet))
+	for version := range versionSet {
+		versions = append(versions, version)
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		if c := semver.Compare(versions[i], versions[j]); c != 0 {
+			return c < 0
+		}
+		return versions[i] < versions[j]
+	})
+
+	return versions, nil
+}
+
+// findZipFile returns the path of the cached zip file of a module version downloaded from any
+// of the Go module proxies. The second return value is false if it is not cached.
+func (handler *Handler) findZipFile(escapedModulePath string, escapedVersion string) (path.Path, bool) {
+	for _, goModuleProxyURL := range handler.goModuleProxyURLs {
+		zipURL, err := goModuleProxyURL.Parse(escapedModulePath + "/@v/" + escapedVersion + ".zip")
+		if err != nil {
+			continue
+		}
+
+		// Cache files are named by the escaped URLs, so the name cannot leave the cache
+		// directory.
+		zipPath := handler.cacheDir.Join(path.MustParse(url.QueryEscape(zipURL.String())))
+
+		if fileInfo, err := os.Stat(zipPath.LocalString()); err == nil && fileInfo.Mode().IsRegular() {
+			return zipPath, true
+		}
+	}
+
+	return path.Path{}, false
+}
+
+// readGoMod returns the go.mod file in the zip file of a module version. Modules without one,
+// such as most teeth, get one declaring only the module path, as Go module proxies serve.
+func readGoMod(zipPath path.Path, modulePath string, version string) ([]byte, error) {
+	zipReader, err := zip.OpenReader(zipPath.LocalString())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open zip file %v\n\t%w", zipPath.LocalString(), err)
+	}
+	defer zipReader.Close()
+
+	goModName := modulePath + "@" + version + "/go.mod"
+	for _, file := range zipReader.File {
+		if file.Name != goModName {
+			continue
+		}
+
+		fileReader, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %v\n\t%w", goModName, err)
+		}
+		defer fileReader.Close()
+
+		return io.ReadAll(fileReader)
+	}
+
+	return []byte(fmt.Sprintf("module %v\n", modulePath)), nil
+}
+
+// writeInfo writes the .info file of a module version.
+func writeInfo(w http.ResponseWriter, version string) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(versionInfo{Version: version})
+}
@@ -0,0 +1,174 @@
+package changelog
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/network"
+	"github.com/lippkg/lip/internal/path"
+	"github.com/lippkg/lip/internal/tooth"
+)
+
+// Entry is the section of a version in a changelog.
+type Entry struct {
+	Version semver.Version `json:"version"`
+	// Heading is the heading of the section as written, e.g. ## [1.2.0] - 2024-03-23.
+	Heading string `json:"heading"`
+	// Body is the section without its heading, trimmed.
+	Body string `json:"body"`
+}
+
+// headingPattern matches a Markdown heading, capturing its level and its text.
+var headingPattern = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+
+// headingVersionPattern matches the version in the heading of a section, e.g. 1.2.0 in
+// [1.2.0] - 2024-03-23 or v1.2.0.
+var headingVersionPattern = regexp.MustCompile(`\bv?(\d+\.\d+\.\d+(?:-[0-9A-Za-z.-]+)?(?:\+[0-9A-Za-z.-]+)?)`)
+
+// linkDefinitionPattern matches a Markdown link reference definition, e.g. the links to the
+// diffs of the versions at the end of a changelog in the format of Keep a Changelog.
+var linkDefinitionPattern = regexp.MustCompile(`^\[[^\]]+\]:\s*\S+`)
+
+// Get reads the changelog of the tooth in a tooth archive, from the URL declared in its
+// tooth.json or from the file in the archive, by default CHANGELOG.md. The second return value
+// is false if the tooth has no changelog.
+func Get(ctx *context.Context, archive tooth.Archive) ([]Entry, bool, error) {
+	changelog := archive.Metadata().Changelog()
+
+	var content []byte
+	if tooth.IsChangelogURL(changelog) {
+		changelogURL, err := url.Parse(changelog)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to parse changelog URL %v\n\t%w", changelog, err)
+		}
+
+		proxyURL, err := ctx.ProxyURL()
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to get proxy URL\n\t%w", err)
+		}
+
+		content, err = ctx.Network().GetContent(ctx.GoContext(), changelogURL, proxyURL, nil, ctx.RetryPolicy())
+		if network.IsNotFound(err) {
+			return nil, false, nil
+		} else if err != nil {
+			return nil, false, fmt.Errorf("failed to fetch changelog %v\n\t%w", changelog, err)
+		}
+
+	} else {
+		changelogPath, err := path.Parse(changelog)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to parse changelog path %v\n\t%w", changelog, err)
+		}
+
+		var ok bool
+		content, ok, err = archive.ReadFile(changelogPath)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to read changelog %v\n\t%w", changelog, err)
+		}
+
+		if !ok {
+			return nil, false, nil
+		}
+	}
+
+	return Parse(string(content)), true, nil
+}
+
+// Parse parses a Markdown changelog into the sections of its versions, in their order, e.g. in
+// the format of Keep a Changelog. A section starts with a heading containing a version, and
+// ends before the next heading of the same or a higher level. Sections without versions, e.g.
+// Unreleased, and link reference definitions are left out.
+func Parse(content string) []Entry {
+	entries := make([]Entry, 0)
+
+	// The level of the headings of the sections, found from the first one.
+	sectionLevel := 0
+
+	var entry *Entry
+	bodyLines := make([]string, 0)
+
+	finishEntry := func() {
+		if entry != nil {
+			entry.Body = strings.TrimSpace(strings.Join(bodyLines, "\n"))
+			entries = append(entries, *entry)
+		}
+
+		entry = nil
+		bodyLines = bodyLines[:0]
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		if match := headingPattern.FindStringSubmatch(line); match != nil {
+			level := len(match[1])
+
+			if sectionLevel == 0 || level <= sectionLevel {
+				versionMatch := headingVersionPattern.FindStringSubmatch(match[2])
+
+				if versionMatch != nil {
+					if version, err := semver.Parse(versionMatch[1]); err == nil {
+						finishEntry()
+
+						if sectionLevel == 0 {
+							sectionLevel = level
+						}
+
+						entry = &Entry{Version: version, Heading: line}
+						continue
+					}
+				}
+
+				if sectionLevel != 0 {
+					finishEntry()
+					continue
+				}
+			}
+		}
+
+		if entry != nil && !linkDefinitionPattern.MatchString(line) {
+			bodyLines = append(bodyLines, line)
+		}
+	}
+
+	finishEntry()
+
+	return entries
+}
+
+// Between returns the entries of the versions after from and up to to, e.g. the changes an
+// update from the installed version to the target version brings, in their order.
+func Between(entries []Entry, from semver.Version, to semver.Version) []Entry {
+	selectedEntries := make([]Entry, 0)
+	for _, entry := range entries {
+		if entry.Version.GT(from) && entry.Version.LTE(to) {
+			selectedEntries = append(selectedEntries, entry)
+		}
+	}
+
+	return selectedEntries
+}
+
+// Format formats entries as Markdown, each with its heading.
+func Format(entries []Entry) string {
+	builder := &strings.Builder{}
+	for i, entry := range entries {
+		if i != 0 {
+			builder.WriteString("\n")
+		}
+
+		builder.WriteString(entry.Heading + "\n")
+		if entry.Body != "" {
+			builder.WriteString("\n" + entry.Body + "\n")
+		}
+	}
+
+	return builder.String()
+}
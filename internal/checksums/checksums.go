@@ -0,0 +1,212 @@
+package checksums
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/vfs"
+	log "github.com/sirupsen/logrus"
+)
+
+// FileName is the name of the checksums file listing the SHA-256 checksums of the tooth
+// archives in its directory, in the format of sha256sum.
+const FileName = "SHA256SUMS"
+
+// SignatureFileName is the name of the ASCII-armored detached GnuPG signature of the checksums
+// file.
+const SignatureFileName = FileName + ".asc"
+
+// Add records the checksum of a file in the checksums file in its directory, creating the
+// checksums file if it does not exist. An existing entry of the file is replaced.
+func Add(fs vfs.FS, filePath string, checksum string) error {
+	checksumsFilePath := filepath.Join(filepath.Dir(filePath), FileName)
+
+	checksums := make(map[string]string)
+
+	content, err := fs.ReadFile(checksumsFilePath)
+	if err == nil {
+		checksums, err = Parse(content)
+		if err != nil {
+			return fmt.Errorf("failed to parse %v\n\t%w", checksumsFilePath, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %v\n\t%w", checksumsFilePath, err)
+	}
+
+	checksums[filepath.Base(filePath)] = checksum
+
+	if err := fs.WriteFile(checksumsFilePath, format(checksums), 0644); err != nil {
+		return fmt.Errorf("failed to write %v\n\t%w", checksumsFilePath, err)
+	}
+
+	return nil
+}
+
+// Parse parses a checksums file in the format of sha256sum, i.e. lines of a hex-encoded
+// checksum and a file name separated by two spaces, or by a space and an asterisk for binary
+// mode. It returns the checksums keyed by the file names.
+func Parse(content []byte) (map[string]string, error) {
+	checksums := make(map[string]string)
+
+	for i, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		checksum, fileName, ok := strings.Cut(line, " ")
+		if !ok {
+			return nil, fmt.Errorf("invalid line %v: %v", i+1, line)
+		}
+
+		checksum = strings.ToLower(checksum)
+		if decoded, err := hex.DecodeString(checksum); err != nil || len(decoded) != sha256.Size {
+			return nil, fmt.Errorf("invalid SHA-256 checksum on line %v: %v", i+1, checksum)
+		}
+
+		fileName = strings.TrimPrefix(strings.TrimPrefix(fileName, " "), "*")
+		if fileName == "" {
+			return nil, fmt.Errorf("missing file name on line %v", i+1)
+		}
+
+		checksums[fileName] = checksum
+	}
+
+	return checksums, nil
+}
+
+// Verify verifies a file against the checksums file in its directory on a file system. The
+// first return value is false if there is no checksums file or it has no entry of the file. If
+// a signature of the checksums file is present, it is verified first with GnuPG. Warnings are
+// written to logger.
+func Verify(logger log.FieldLogger, fs vfs.FS, filePath string, checksum string) (bool, error) {
+	checksumsFilePath := filepath.Join(filepath.Dir(filePath), FileName)
+
+	content, err := fs.ReadFile(checksumsFilePath)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to read %v\n\t%w", checksumsFilePath, err)
+	}
+
+	checksums, err := Parse(content)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse %v\n\t%w", checksumsFilePath, err)
+	}
+
+	expectedChecksum, ok := checksums[filepath.Base(filePath)]
+	if !ok {
+		return false, nil
+	}
+
+	if err := verifySignature(logger, fs, checksumsFilePath, content); err != nil {
+		return false, err
+	}
+
+	if checksum != expectedChecksum {
+		return false, errcode.Errorf(errcode.ChecksumMismatch, "checksum mismatch for %v: expected %v in %v, got %v",
+			filePath, expectedChecksum, checksumsFilePath, checksum)
+	}
+
+	return true, nil
+}
+
+// Sign signs the checksums file in a directory with GnuPG, as an ASCII-armored detached
+// signature. An empty keyID uses the default key of GnuPG.
+func Sign(dir string, keyID string) error {
+	checksumsFilePath := filepath.Join(dir, FileName)
+	signatureFilePath := filepath.Join(dir, SignatureFileName)
+
+	args := []string{"--batch", "--yes", "--armor", "--detach-sign", "--output", signatureFilePath}
+	if keyID != "" {
+		args = append(args, "--local-user", keyID)
+	}
+	args = append(args, checksumsFilePath)
+
+	output, err := exec.Command("gpg", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to sign %v with gpg: %v\n\t%w", checksumsFilePath,
+			strings.TrimSpace(string(output)), err)
+	}
+
+	return nil
+}
+
+// ---------------------------------------------------------------------
+
+// format formats checksums in the format of sha256sum, sorted by file names.
+func format(checksums map[string]string) []byte {
+	fileNames := make([]string, 0, len(checksums))
+	for fileName := range checksums {
+		fileNames = append(fileNames, fileName)
+	}
+	sort.Strings(fileNames)
+
+	var buf bytes.Buffer
+	for _, fileName := range fileNames {
+		fmt.Fprintf(&buf, "%v  %v\n", checksums[fileName], fileName)
+	}
+
+	return buf.Bytes()
+}
+
+// verifySignature verifies the signature of a checksums file with GnuPG if the signature is
+// present. If GnuPG is not installed, the signature is not verified and a warning is shown.
+// GnuPG reads files of the operating system only, so the signature is copied to a temporary
+// file and the content of the checksums file is passed on its standard input.
+func verifySignature(logger log.FieldLogger, fs vfs.FS, checksumsFilePath string, content []byte) error {
+	debugLogger := logger.WithFields(log.Fields{
+		"package": "checksums",
+		"method":  "verifySignature",
+	})
+
+	signatureFilePath := filepath.Join(filepath.Dir(checksumsFilePath), SignatureFileName)
+	signature, err := fs.ReadFile(signatureFilePath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to read signature %v\n\t%w", signatureFilePath, err)
+	}
+
+	if _, err := exec.LookPath("gpg"); err != nil {
+		logger.Warnf(i18n.T("GnuPG is not installed, so the signature %v is not verified."), signatureFilePath)
+		return nil
+	}
+
+	signatureFile, err := os.CreateTemp("", "*"+SignatureFileName)
+	if err != nil {
+		return fmt.Errorf("failed to create a temporary signature file\n\t%w", err)
+	}
+	defer os.Remove(signatureFile.Name())
+
+	_, err = signatureFile.Write(signature)
+	if closeErr := signatureFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write temporary signature file\n\t%w", err)
+	}
+
+	// "-" makes GnuPG read the signed data from its standard input.
+	cmd := exec.Command("gpg", "--batch", "--verify", signatureFile.Name(), "-")
+	cmd.Stdin = bytes.NewReader(content)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return errcode.Errorf(errcode.VerificationFailed, "failed to verify signature %v: %v\n\t%w",
+			signatureFilePath, strings.TrimSpace(string(output)), err)
+	}
+
+	debugLogger.Debugf("Verified signature %v", signatureFilePath)
+
+	return nil
+}
@@ -4,26 +4,81 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	nested "github.com/antonfisher/nested-logrus-formatter"
+	"github.com/lippkg/lip/internal/cmd/cmdlipaudit"
+	"github.com/lippkg/lip/internal/cmd/cmdlipautoremove"
+	"github.com/lippkg/lip/internal/cmd/cmdlipbuild"
 	"github.com/lippkg/lip/internal/cmd/cmdlipcache"
+	"github.com/lippkg/lip/internal/cmd/cmdlipcompletion"
 	"github.com/lippkg/lip/internal/cmd/cmdlipconfig"
+	"github.com/lippkg/lip/internal/cmd/cmdlipdb"
+	"github.com/lippkg/lip/internal/cmd/cmdlipdiff"
+	"github.com/lippkg/lip/internal/cmd/cmdlipenv"
+	"github.com/lippkg/lip/internal/cmd/cmdlipexport"
+	"github.com/lippkg/lip/internal/cmd/cmdliphistory"
+	"github.com/lippkg/lip/internal/cmd/cmdlipimport"
+	"github.com/lippkg/lip/internal/cmd/cmdlipindex"
 	"github.com/lippkg/lip/internal/cmd/cmdlipinstall"
+	"github.com/lippkg/lip/internal/cmd/cmdliplint"
 	"github.com/lippkg/lip/internal/cmd/cmdliplist"
+	"github.com/lippkg/lip/internal/cmd/cmdliplogin"
+	"github.com/lippkg/lip/internal/cmd/cmdliplogout"
+	"github.com/lippkg/lip/internal/cmd/cmdlipmigrate"
+	"github.com/lippkg/lip/internal/cmd/cmdlipnew"
+	"github.com/lippkg/lip/internal/cmd/cmdlipowner"
+	"github.com/lippkg/lip/internal/cmd/cmdlipprune"
+	"github.com/lippkg/lip/internal/cmd/cmdlippublish"
+	"github.com/lippkg/lip/internal/cmd/cmdliprollback"
+	"github.com/lippkg/lip/internal/cmd/cmdlipsearch"
+	"github.com/lippkg/lip/internal/cmd/cmdlipself"
 	"github.com/lippkg/lip/internal/cmd/cmdlipshow"
+	"github.com/lippkg/lip/internal/cmd/cmdlipsnapshot"
+	"github.com/lippkg/lip/internal/cmd/cmdlipstats"
+	"github.com/lippkg/lip/internal/cmd/cmdlipswitch"
+	"github.com/lippkg/lip/internal/cmd/cmdlipsync"
 	"github.com/lippkg/lip/internal/cmd/cmdliptooth"
+	"github.com/lippkg/lip/internal/cmd/cmdliptui"
+	"github.com/lippkg/lip/internal/cmd/cmdlipundo"
 	"github.com/lippkg/lip/internal/cmd/cmdlipuninstall"
+	"github.com/lippkg/lip/internal/cmd/cmdlipupdate"
+	"github.com/lippkg/lip/internal/cmd/cmdlipvendor"
+	"github.com/lippkg/lip/internal/cmd/cmdlipverify"
+	"github.com/lippkg/lip/internal/cmd/cmdlipwhy"
+	"github.com/lippkg/lip/internal/cmd/cmdlipworkspace"
 	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/credential"
+	"github.com/lippkg/lip/internal/envfile"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/layout"
+	"github.com/lippkg/lip/internal/network"
+	"github.com/lippkg/lip/internal/overlay"
+	"github.com/lippkg/lip/internal/profiling"
+	"github.com/lippkg/lip/internal/prompt"
+	"github.com/lippkg/lip/internal/render"
+	"github.com/lippkg/lip/internal/tooth"
+	"github.com/lippkg/lip/internal/updatecheck"
 
+	"github.com/olekukonko/tablewriter"
 	log "github.com/sirupsen/logrus"
 )
 
 type FlagDict struct {
-	helpFlag    bool
-	versionFlag bool
-	verboseFlag bool
-	quietFlag   bool
-	noColorFlag bool
+	helpFlag        bool
+	versionFlag     bool
+	verboseFlag     bool
+	quietFlag       bool
+	noColorFlag     bool
+	offlineFlag     bool
+	limitRateFlag   string
+	overlayFlag     string
+	profileFlag     string
+	promptFlag      string
+	answersFileFlag string
 }
 
 const helpMessage = `
@@ -31,13 +86,46 @@ Usage:
   lip [options] [<command> [subcommand options]] ...
 
 Commands:
+  audit                       Check installed teeth against security advisories.
+  autoremove                  Uninstall teeth that are no longer required.
+  build                       Build the tooth in the current directory before packing it.
   cache                       Inspect and manage lip's cache.
+  completion                  Generate shell completion scripts.
   config					  Manage configuration.
+  db                          Inspect the installed-state database.
+  diff                        Compare an installed tooth with another version of it.
+  env                         Show the environment required by installed teeth.
+  export                      Export installed teeth to a bundle.
+  history                     List the operations on the teeth of the workspace.
+  import                      Install the teeth in a bundle made by lip export.
+  index                       Mirror and serve the registry.
   install                     Install a tooth.
+  lint                        Check the tooth in the current directory for common problems.
   list                        List installed teeth.
+  login                       Save a credential for a host.
+  logout                      Remove the credential of a host.
+  migrate                     Upgrade files left by older versions of lip to the current layout.
+  new                         Create a new tooth from a template.
+  owner                       Show which installed tooth placed a file.
+  prune                       Remove stale metadata, cache entries and temporary files.
+  publish                     Publish a tooth archive.
+  rollback                    Roll back a tooth to a previously installed version.
+  search                      Search the registry.
+  self                        Manage lip itself.
   show                        Show information about installed teeth.
+  snapshot                    Save, compare and restore the installed state.
+  stats                       Show local usage statistics of the cache, downloads and installs.
+  switch                      Switch a tooth between versions installed side by side.
+  sync                        Sync installed teeth with the workspace manifest.
   tooth                       Maintain a tooth.
+  tui                         Browse and manage teeth interactively.
+  undo                        Revert the most recent operation in the history.
   uninstall                   Uninstall a tooth.
+  update                      Update installed teeth within an update policy.
+  vendor                      Copy the archives of installed teeth into the workspace.
+  verify                      Verify installed files of teeth.
+  why                         Explain why a tooth is installed.
+  workspace                   Show the type and layout of the workspace.
 
 Options:
   -h, --help                  Show help.
@@ -45,6 +133,16 @@ Options:
   -v, --verbose               Show verbose output.
   -q, --quiet                 Show only errors.
   --no-color                  Disable color output.
+  --offline                   Use only cached data and never access the network.
+  --limit-rate <rate>         Cap the total download bandwidth, e.g. 512K or 2M.
+  --overlay <dir>             Leave the workspace untouched and place files and keep state in
+                              the overlay directory, with a mapping file for mod loaders.
+  --profile <dir>             Write CPU and heap profiles in pprof format to the directory, and
+                              print the time spent resolving, downloading, extracting and placing.
+  --prompt <mode>             How to answer confirmations: tty (default) to ask on the terminal,
+                              or yes or no to answer them all without asking.
+  --answers-file <file>       Answer confirmations with the lines of the file in order, or of
+                              the standard input if the file is -.
 `
 
 func Run(ctx *context.Context, args []string) error {
@@ -66,6 +164,12 @@ func Run(ctx *context.Context, args []string) error {
 	flagSet.BoolVar(&flagDict.quietFlag, "quiet", false, "")
 	flagSet.BoolVar(&flagDict.quietFlag, "q", false, "")
 	flagSet.BoolVar(&flagDict.noColorFlag, "no-color", false, "")
+	flagSet.BoolVar(&flagDict.offlineFlag, "offline", false, "")
+	flagSet.StringVar(&flagDict.limitRateFlag, "limit-rate", "", "")
+	flagSet.StringVar(&flagDict.overlayFlag, "overlay", "", "")
+	flagSet.StringVar(&flagDict.profileFlag, "profile", "", "")
+	flagSet.StringVar(&flagDict.promptFlag, "prompt", "", "")
+	flagSet.StringVar(&flagDict.answersFileFlag, "answers-file", "", "")
 
 	if err := flagSet.Parse(args); err != nil {
 		return fmt.Errorf("cannot parse flags\n\t%w", err)
@@ -73,6 +177,7 @@ func Run(ctx *context.Context, args []string) error {
 
 	if flagDict.noColorFlag {
 		log.SetFormatter(&nested.Formatter{NoColors: true})
+		render.Disable()
 	}
 
 	// Set logging level.
@@ -98,58 +203,471 @@ func Run(ctx *context.Context, args []string) error {
 
 	// Verbose and quiet flags are mutually exclusive.
 	if flagDict.verboseFlag && flagDict.quietFlag {
-		return fmt.Errorf("verbose and quiet flags are mutually exclusive")
+		return errcode.Errorf(errcode.InvalidArgument, "verbose and quiet flags are mutually exclusive")
+	}
+
+	ctx.SetOffline(flagDict.offlineFlag)
+
+	if err := configurePrompter(ctx, flagDict); err != nil {
+		return err
+	}
+
+	// Profiles and the timing breakdown are written even if the command fails, since slow
+	// failures are worth diagnosing as well.
+	if flagDict.profileFlag != "" {
+		stopProfiles, err := profiling.StartProfiles(flagDict.profileFlag)
+		if err != nil {
+			return fmt.Errorf("cannot start profiling\n\t%w", err)
+		}
+
+		ctx.SetTimer(profiling.NewTimer())
+
+		defer func() {
+			if err := stopProfiles(); err != nil {
+				log.Warnf(i18n.T("Cannot write the profiles\n\t%v"), err)
+			} else {
+				log.Infof(i18n.T("Wrote CPU and heap profiles to %v"), flagDict.profileFlag)
+			}
+
+			printTimingBreakdown(ctx.Timer())
+		}()
+	}
+
+	// lip config does not access the network, and must keep working to fix invalid network
+	// configuration.
+	if flagSet.Arg(0) != "config" {
+		if err := configureNetwork(ctx, flagDict); err != nil {
+			return err
+		}
+	}
+
+	// In overlay mode, lip works in the overlay directory, so that a read-only workspace is
+	// never written to.
+	if flagDict.overlayFlag != "" {
+		workspaceDir, err := enterOverlay(flagDict.overlayFlag)
+		if err != nil {
+			return err
+		}
+
+		// The mapping is written even if the command fails, since it may have changed files
+		// before failing.
+		defer func() {
+			if err := overlay.WriteMapping(ctx, workspaceDir); err != nil {
+				log.Warnf(i18n.T("Cannot write the overlay mapping file\n\t%v"), err)
+			}
+		}()
+	}
+
+	if err := ctx.CreateDirStructure(); err != nil {
+		return fmt.Errorf("cannot create directory structure\n\t%w", err)
+	}
+
+	// Commands of teeth load the env file themselves, and fail if it is invalid.
+	if envFileVariables, err := envfile.Load(ctx); err != nil {
+		log.Warnf(i18n.T("Cannot load the env file, expanding no env variables in templates\n\t%v"), err)
+	} else {
+		tooth.SetEnvVariables(envFileVariables)
+	}
+
+	// Teeth placing files under logical placement roots cannot be installed without a layout.
+	if workspaceLayout, err := layout.Get(ctx); err != nil {
+		log.Warnf(i18n.T("Cannot get the layout of the workspace, expanding no placement roots in templates\n\t%v"), err)
+	} else {
+		tooth.SetLayoutRoots(workspaceLayout.Roots)
+	}
+
+	// Check for updates after the command if enabled, except after commands run by scripts or
+	// managing lip itself.
+	switch flagSet.Arg(0) {
+	case "", "completion", "config", "self":
+	default:
+		defer updatecheck.CheckAndNotify(ctx)
 	}
 
 	// If there is a subcommand, run it and exit.
 	if flagSet.NArg() >= 1 {
 		switch flagSet.Arg(0) {
+		case "audit":
+			if err := cmdlipaudit.Run(ctx, flagSet.Args()[1:]); err != nil {
+				return err
+			}
+			return nil
+
+		case "autoremove":
+			if err := cmdlipautoremove.Run(ctx, flagSet.Args()[1:]); err != nil {
+				return err
+			}
+			return nil
+
+		case "build":
+			if err := cmdlipbuild.Run(ctx, flagSet.Args()[1:]); err != nil {
+				return err
+			}
+			return nil
+
 		case "cache":
 			if err := cmdlipcache.Run(ctx, flagSet.Args()[1:]); err != nil {
 				return err
 			}
 			return nil
 
+		case "completion":
+			if err := cmdlipcompletion.Run(ctx, flagSet.Args()[1:]); err != nil {
+				return err
+			}
+			return nil
+
 		case "config":
 			if err := cmdlipconfig.Run(ctx, flagSet.Args()[1:]); err != nil {
 				return err
 			}
 			return nil
 
+		case "db":
+			if err := cmdlipdb.Run(ctx, flagSet.Args()[1:]); err != nil {
+				return err
+			}
+			return nil
+
+		case "diff":
+			if err := cmdlipdiff.Run(ctx, flagSet.Args()[1:]); err != nil {
+				return err
+			}
+			return nil
+
+		case "env":
+			if err := cmdlipenv.Run(ctx, flagSet.Args()[1:]); err != nil {
+				return err
+			}
+			return nil
+
+		case "export":
+			if err := cmdlipexport.Run(ctx, flagSet.Args()[1:]); err != nil {
+				return err
+			}
+			return nil
+
+		case "history":
+			if err := cmdliphistory.Run(ctx, flagSet.Args()[1:]); err != nil {
+				return err
+			}
+			return nil
+
+		case "import":
+			if err := cmdlipimport.Run(ctx, flagSet.Args()[1:]); err != nil {
+				return err
+			}
+			return nil
+
+		case "index":
+			if err := cmdlipindex.Run(ctx, flagSet.Args()[1:]); err != nil {
+				return err
+			}
+			return nil
+
 		case "install":
 			if err := cmdlipinstall.Run(ctx, flagSet.Args()[1:]); err != nil {
 				return err
 			}
 			return nil
 
+		case "lint":
+			if err := cmdliplint.Run(ctx, flagSet.Args()[1:]); err != nil {
+				return err
+			}
+			return nil
+
 		case "list":
 			if err := cmdliplist.Run(ctx, flagSet.Args()[1:]); err != nil {
 				return err
 			}
 			return nil
 
+		case "login":
+			if err := cmdliplogin.Run(ctx, flagSet.Args()[1:]); err != nil {
+				return err
+			}
+			return nil
+
+		case "logout":
+			if err := cmdliplogout.Run(ctx, flagSet.Args()[1:]); err != nil {
+				return err
+			}
+			return nil
+
+		case "migrate":
+			if err := cmdlipmigrate.Run(ctx, flagSet.Args()[1:]); err != nil {
+				return err
+			}
+			return nil
+
+		case "new":
+			if err := cmdlipnew.Run(ctx, flagSet.Args()[1:]); err != nil {
+				return err
+			}
+			return nil
+
+		case "owner":
+			if err := cmdlipowner.Run(ctx, flagSet.Args()[1:]); err != nil {
+				return err
+			}
+			return nil
+
+		case "prune":
+			if err := cmdlipprune.Run(ctx, flagSet.Args()[1:]); err != nil {
+				return err
+			}
+			return nil
+
+		case "publish":
+			if err := cmdlippublish.Run(ctx, flagSet.Args()[1:]); err != nil {
+				return err
+			}
+			return nil
+
+		case "rollback":
+			if err := cmdliprollback.Run(ctx, flagSet.Args()[1:]); err != nil {
+				return err
+			}
+			return nil
+
+		case "search":
+			if err := cmdlipsearch.Run(ctx, flagSet.Args()[1:]); err != nil {
+				return err
+			}
+			return nil
+
+		case "self":
+			if err := cmdlipself.Run(ctx, flagSet.Args()[1:]); err != nil {
+				return err
+			}
+			return nil
+
 		case "show":
 			if err := cmdlipshow.Run(ctx, flagSet.Args()[1:]); err != nil {
 				return err
 			}
 			return nil
 
+		case "snapshot":
+			if err := cmdlipsnapshot.Run(ctx, flagSet.Args()[1:]); err != nil {
+				return err
+			}
+			return nil
+
+		case "stats":
+			if err := cmdlipstats.Run(ctx, flagSet.Args()[1:]); err != nil {
+				return err
+			}
+			return nil
+
+		case "switch":
+			if err := cmdlipswitch.Run(ctx, flagSet.Args()[1:]); err != nil {
+				return err
+			}
+			return nil
+
+		case "sync":
+			if err := cmdlipsync.Run(ctx, flagSet.Args()[1:]); err != nil {
+				return err
+			}
+			return nil
+
 		case "tooth":
 			if err := cmdliptooth.Run(ctx, flagSet.Args()[1:]); err != nil {
 				return err
 			}
 			return nil
 
+		case "tui":
+			if err := cmdliptui.Run(ctx, flagSet.Args()[1:]); err != nil {
+				return err
+			}
+			return nil
+
+		case "undo":
+			if err := cmdlipundo.Run(ctx, flagSet.Args()[1:]); err != nil {
+				return err
+			}
+			return nil
+
 		case "uninstall":
 			if err := cmdlipuninstall.Run(ctx, flagSet.Args()[1:]); err != nil {
 				return err
 			}
 			return nil
 
+		case "update":
+			if err := cmdlipupdate.Run(ctx, flagSet.Args()[1:]); err != nil {
+				return err
+			}
+			return nil
+
+		case "vendor":
+			if err := cmdlipvendor.Run(ctx, flagSet.Args()[1:]); err != nil {
+				return err
+			}
+			return nil
+
+		case "verify":
+			if err := cmdlipverify.Run(ctx, flagSet.Args()[1:]); err != nil {
+				return err
+			}
+			return nil
+
+		case "why":
+			if err := cmdlipwhy.Run(ctx, flagSet.Args()[1:]); err != nil {
+				return err
+			}
+			return nil
+
+		case "workspace":
+			if err := cmdlipworkspace.Run(ctx, flagSet.Args()[1:]); err != nil {
+				return err
+			}
+			return nil
+
 		default:
-			return fmt.Errorf("unknown command: lip %v", flagSet.Arg(0))
+			return errcode.Errorf(errcode.InvalidArgument, "unknown command: lip %v", flagSet.Arg(0))
+		}
+	}
+
+	return errcode.Errorf(errcode.InvalidArgument, "no command specified. See 'lip --help' for more information")
+}
+
+// ---------------------------------------------------------------------
+
+// enterOverlay creates the overlay directory if needed and makes it the working directory. It
+// returns the absolute path of the workspace it overlays, i.e. the previous working directory.
+func enterOverlay(overlayDir string) (string, error) {
+	workspaceDir, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("cannot get workspace directory\n\t%w", err)
+	}
+
+	absOverlayDir, err := filepath.Abs(overlayDir)
+	if err != nil {
+		return "", errcode.Errorf(errcode.InvalidArgument, "invalid --overlay\n\t%w", err)
+	}
+
+	if absOverlayDir == workspaceDir {
+		return "", errcode.Errorf(errcode.InvalidArgument, "the overlay directory cannot be the workspace")
+	}
+
+	if err := os.MkdirAll(absOverlayDir, 0755); err != nil {
+		return "", fmt.Errorf("cannot create overlay directory %v\n\t%w", absOverlayDir, err)
+	}
+
+	if err := os.Chdir(absOverlayDir); err != nil {
+		return "", fmt.Errorf("cannot enter overlay directory %v\n\t%w", absOverlayDir, err)
+	}
+
+	return workspaceDir, nil
+}
+
+// configurePrompter sets the prompter of confirmations by the flags.
+func configurePrompter(ctx *context.Context, flagDict FlagDict) error {
+	if flagDict.promptFlag != "" && flagDict.answersFileFlag != "" {
+		return errcode.Errorf(errcode.InvalidArgument, "--prompt and --answers-file are mutually exclusive")
+	}
+
+	if flagDict.answersFileFlag != "" {
+		prompter, err := prompt.FromFile(flagDict.answersFileFlag)
+		if err != nil {
+			return errcode.Errorf(errcode.InvalidArgument, "invalid --answers-file\n\t%w", err)
+		}
+
+		ctx.SetPrompter(prompter)
+	}
+
+	if flagDict.promptFlag != "" {
+		prompter, err := prompt.New(flagDict.promptFlag)
+		if err != nil {
+			return err
+		}
+
+		ctx.SetPrompter(prompter)
+	}
+
+	return nil
+}
+
+// configureNetwork makes the client network requests are sent with from the network
+// configuration and flags, with the HTTP client and the file system of the context.
+func configureNetwork(ctx *context.Context, flagDict FlagDict) error {
+	rateLimit, err := ctx.RateLimit()
+	if err != nil {
+		return fmt.Errorf("cannot get download rate limit\n\t%w", err)
+	}
+
+	// The flag takes precedence over the configuration.
+	if flagDict.limitRateFlag != "" {
+		total, err := network.ParseRate(flagDict.limitRateFlag)
+		if err != nil {
+			return errcode.Errorf(errcode.InvalidArgument, "invalid --limit-rate\n\t%w", err)
+		}
+
+		rateLimit.Total = total
+	}
+
+	dialerConfig, err := ctx.DialerConfig()
+	if err != nil {
+		return fmt.Errorf("cannot get dialer configuration\n\t%w", err)
+	}
+
+	ctx.SetNetwork(network.NewClient(network.ClientConfig{
+		HTTPClient:      ctx.HTTPClient(),
+		FS:              ctx.FS(),
+		Logger:          ctx.Logger(),
+		DialerConfig:    dialerConfig,
+		RateLimit:       rateLimit,
+		DownloadTimeout: ctx.DownloadTimeout(),
+		CredentialLookup: func(host string) (network.Credential, bool) {
+			c, ok, err := credential.Get(ctx, host)
+			if err != nil {
+				log.Warnf(i18n.T("Cannot get the credential of %v, sending requests without it\n\t%v"), host, err)
+				return network.Credential{}, false
+			}
+
+			return network.Credential(c), ok
+		},
+	}))
+
+	return nil
+}
+
+// printTimingBreakdown prints the time spent in each phase of installing teeth, and the rest
+// of the time of the command.
+func printTimingBreakdown(timer *profiling.Timer) {
+	total := timer.Total()
+
+	tableString := &strings.Builder{}
+	table := tablewriter.NewWriter(tableString)
+	table.SetHeader([]string{"Phase", "Time", "Share"})
+	table.SetAutoFormatHeaders(false)
+
+	appendRow := func(name string, duration time.Duration) {
+		share := 0.0
+		if total != 0 {
+			share = float64(duration) * 100 / float64(total)
 		}
+
+		table.Append([]string{name, duration.Round(time.Millisecond).String(), fmt.Sprintf("%.1f%%", share)})
+	}
+
+	other := total
+	for _, phase := range profiling.Phases {
+		duration := timer.Duration(phase)
+		other -= duration
+
+		appendRow(string(phase), duration)
 	}
 
-	return fmt.Errorf("no command specified. See 'lip --help' for more information")
+	appendRow("other", other)
+	table.SetFooter([]string{"total", total.Round(time.Millisecond).String(), "100.0%"})
+
+	table.Render()
+
+	log.Info(i18n.T("Timing breakdown:"))
+	fmt.Print(tableString.String())
 }
@@ -0,0 +1,151 @@
+package cmdlipaudit
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/lippkg/lip/internal/advisory"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/tooth"
+	"github.com/olekukonko/tablewriter"
+	log "github.com/sirupsen/logrus"
+)
+
+type FlagDict struct {
+	helpFlag   bool
+	failOnFlag string
+	jsonFlag   bool
+}
+
+const helpMessage = `
+Usage:
+  lip audit [options]
+
+Description:
+  Check installed teeth against the security advisory database at AdvisoryDBURL, or the one
+  of the registry if it is not set, and report the advisories affecting them with the versions
+  fixing them.
+
+Options:
+  -h, --help                  Show help.
+  --fail-on <severity>        Fail with E_ADVISORY_MATCHED if an advisory at least this severe
+                              matches: low, medium, high or critical (default).
+  --json                      Output in JSON format.
+`
+
+func Run(ctx *context.Context, args []string) error {
+
+	flagSet := flag.NewFlagSet("audit", flag.ContinueOnError)
+
+	// Rewrite the default usage message.
+	flagSet.Usage = func() {
+		// Do nothing.
+	}
+
+	var flagDict FlagDict
+	flagSet.BoolVar(&flagDict.helpFlag, "help", false, "")
+	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
+	flagSet.StringVar(&flagDict.failOnFlag, "fail-on", string(advisory.CriticalSeverity), "")
+	flagSet.BoolVar(&flagDict.jsonFlag, "json", false, "")
+	err := flagSet.Parse(args)
+	if err != nil {
+		return fmt.Errorf("failed to parse flags\n\t%w", err)
+	}
+
+	// Help flag has the highest priority.
+	if flagDict.helpFlag {
+		fmt.Print(helpMessage)
+		return nil
+	}
+
+	// Check if there are unexpected arguments.
+	if flagSet.NArg() != 0 {
+		return errcode.Errorf(errcode.InvalidArgument, "unexpected arguments: %v", flagSet.Args())
+	}
+
+	failOn, err := advisory.ParseSeverity(flagDict.failOnFlag)
+	if err != nil {
+		return err
+	}
+
+	database, err := advisory.Fetch(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch advisory database\n\t%w", err)
+	}
+
+	metadataList, err := tooth.GetAllMetadata(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list all installed teeth\n\t%w", err)
+	}
+
+	matches := make([]advisory.Match, 0)
+	for _, metadata := range metadataList {
+		matches = append(matches, database.Match(metadata.ToothRepoPath(), metadata.Version())...)
+	}
+
+	if flagDict.jsonFlag {
+		jsonBytes, err := json.Marshal(matches)
+		if err != nil {
+			return fmt.Errorf("failed to marshal JSON\n\t%w", err)
+		}
+
+		fmt.Print(string(jsonBytes))
+
+	} else if len(matches) == 0 {
+		log.Infof(i18n.T("No known vulnerabilities in %v installed teeth."), len(metadataList))
+
+	} else {
+		printMatches(matches)
+	}
+
+	failingCount := 0
+	for _, match := range matches {
+		if match.Advisory.Severity.AtLeast(failOn) {
+			failingCount++
+		}
+	}
+
+	if failingCount != 0 {
+		return errcode.Errorf(errcode.AdvisoryMatched, "%v advisories of severity %v or higher affect installed teeth",
+			failingCount, failOn)
+	}
+
+	return nil
+}
+
+// ---------------------------------------------------------------------
+
+// printMatches prints the advisories affecting installed teeth as a table.
+func printMatches(matches []advisory.Match) {
+	tableString := &strings.Builder{}
+	table := tablewriter.NewWriter(tableString)
+	table.SetHeader([]string{
+		"Tooth", "Version", "Advisory", "Severity", "Fixed In", "Summary",
+	})
+
+	for _, match := range matches {
+		fixedIn := ""
+		if match.FixedVersion != nil {
+			fixedIn = match.FixedVersion.String()
+		}
+
+		table.Append([]string{
+			match.Advisory.Tooth,
+			match.Version.String(),
+			match.Advisory.ID,
+			string(match.Advisory.Severity),
+			fixedIn,
+			match.Advisory.Summary,
+		})
+	}
+
+	table.Render()
+
+	fmt.Print(tableString.String())
+
+	log.Info(i18n.T("Upgrade the affected teeth to the fixed versions with lip install --upgrade."))
+}
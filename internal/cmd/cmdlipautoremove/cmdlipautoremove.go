@@ -0,0 +1,309 @@
+package cmdlipautoremove
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/history"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/install"
+	"github.com/lippkg/lip/internal/record"
+	"github.com/lippkg/lip/internal/render"
+	"github.com/lippkg/lip/internal/service"
+	"github.com/lippkg/lip/internal/snapshot"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/lippkg/lip/internal/tooth"
+)
+
+type FlagDict struct {
+	helpFlag bool
+	yesFlag  bool
+}
+
+const helpMessage = `
+Usage:
+  lip autoremove [options]
+
+Description:
+  Uninstall teeth that were installed as dependencies and are no longer required by any explicitly installed tooth.
+
+Options:
+  -h, --help                  Show help.
+  -y, --yes                   Skip confirmation.
+`
+
+func Run(ctx *context.Context, args []string) error {
+
+	flagSet := flag.NewFlagSet("autoremove", flag.ContinueOnError)
+
+	// Rewrite the default usage message.
+	flagSet.Usage = func() {
+		// Do nothing.
+	}
+
+	var flagDict FlagDict
+	flagSet.BoolVar(&flagDict.helpFlag, "help", false, "")
+	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
+	flagSet.BoolVar(&flagDict.yesFlag, "yes", false, "")
+	flagSet.BoolVar(&flagDict.yesFlag, "y", false, "")
+	err := flagSet.Parse(args)
+	if err != nil {
+		return fmt.Errorf("failed to parse flags\n\t%w", err)
+	}
+
+	// Help flag has the highest priority.
+	if flagDict.helpFlag {
+		fmt.Print(helpMessage)
+		return nil
+	}
+
+	// Check if there are unexpected arguments.
+	if flagSet.NArg() != 0 {
+		return errcode.Errorf(errcode.InvalidArgument, "unexpected arguments: %v", flagSet.Args())
+	}
+
+	// 1. Find orphaned teeth.
+
+	orphanList, err := getOrphans(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to find orphaned teeth\n\t%w", err)
+	}
+
+	if len(orphanList) == 0 {
+		log.Info(i18n.T("No orphaned teeth to remove."))
+		return nil
+	}
+
+	// 2. Prompt for confirmation.
+
+	if !flagDict.yesFlag {
+		err := askForConfirmation(ctx, orphanList)
+		if err != nil {
+			return err
+		}
+	}
+
+	// 3. Uninstall orphaned teeth.
+
+	changes := make([]history.Change, 0, len(orphanList))
+	for _, metadata := range orphanList {
+		change, err := history.GetUninstallChange(ctx, metadata.ToothRepoPath())
+		if err != nil {
+			return err
+		}
+
+		if serviceName, err := service.Unregister(ctx, metadata.ToothRepoPath()); err != nil {
+			return fmt.Errorf("failed to unregister OS service of %v\n\t%w", metadata.ToothRepoPath(), err)
+		} else if serviceName != "" {
+			log.Infof(i18n.T("Removed OS service %v"), serviceName)
+		}
+
+		if err := install.Uninstall(ctx, metadata.ToothRepoPath()); err != nil {
+			return fmt.Errorf("failed to uninstall tooth %v\n\t%w", metadata.ToothRepoPath(), err)
+		}
+
+		changes = append(changes, change)
+
+		if err := snapshot.RemoveAll(ctx, metadata.ToothRepoPath()); err != nil {
+			return fmt.Errorf("failed to remove snapshots of tooth %v\n\t%w", metadata.ToothRepoPath(), err)
+		}
+
+		if err := install.RemoveVersions(ctx, metadata.ToothRepoPath()); err != nil {
+			return fmt.Errorf("failed to remove versions of tooth %v\n\t%w", metadata.ToothRepoPath(), err)
+		}
+	}
+
+	if err := history.Append(ctx, history.AutoremoveOperation, changes, 0); err != nil {
+		return fmt.Errorf("failed to record history\n\t%w", err)
+	}
+
+	log.Info(i18n.T("Done."))
+
+	return nil
+}
+
+// ---------------------------------------------------------------------
+
+// askForConfirmation asks for confirmation before removing the teeth.
+func askForConfirmation(ctx *context.Context, metadataList []tooth.Metadata) error {
+
+	// Print the list of teeth to be removed.
+	log.Info(render.Label(render.RemovedRole, i18n.T("The following teeth will be uninstalled:")))
+	for _, metadata := range metadataList {
+		log.Infof("  %v: %v",
+			render.Style(render.RemovedRole, fmt.Sprintf("%v@%v", metadata.ToothRepoPath(), metadata.Version())),
+			metadata.Info().Name)
+	}
+
+	// Ask for confirmation.
+	if ok, err := ctx.Prompter().Confirm(i18n.T("Do you want to continue? [y/N]"), false); err != nil {
+		return err
+	} else if !ok {
+		return errcode.Errorf(errcode.Aborted, "aborted")
+	}
+
+	return nil
+}
+
+// getOrphans returns installed teeth that are not reachable from any explicitly installed
+// tooth. Dependents come before their dependencies in the returned list.
+func getOrphans(ctx *context.Context) ([]tooth.Metadata, error) {
+	metadataList, err := tooth.GetAllMetadata(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list all installed teeth\n\t%w", err)
+	}
+
+	metadataMap := make(map[string]tooth.Metadata)
+	for _, metadata := range metadataList {
+		metadataMap[metadata.ToothRepoPath()] = metadata
+	}
+
+	// Mark all teeth reachable from explicitly installed teeth as required.
+	required := make(map[string]bool)
+	for _, metadata := range metadataList {
+		currentRecord, err := record.Get(ctx, metadata.ToothRepoPath())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get record of tooth %v\n\t%w", metadata.ToothRepoPath(), err)
+		}
+
+		if !currentRecord.IsExplicit {
+			continue
+		}
+
+		if err := markRequired(metadata, metadataMap, required); err != nil {
+			return nil, err
+		}
+	}
+
+	// Sort orphans so that dependents are removed before their dependencies.
+	visited := make(map[string]bool)
+	sorted := make([]tooth.Metadata, 0)
+	for _, metadata := range metadataList {
+		if required[metadata.ToothRepoPath()] {
+			continue
+		}
+
+		if err := visitOrphan(metadata, metadataMap, required, visited, &sorted); err != nil {
+			return nil, err
+		}
+	}
+
+	// Reverse the post-order so that dependents come first.
+	for i, j := 0, len(sorted)-1; i < j; i, j = i+1, j-1 {
+		sorted[i], sorted[j] = sorted[j], sorted[i]
+	}
+
+	return sorted, nil
+}
+
+// markRequired marks a tooth and all its installed dependencies as required.
+func markRequired(metadata tooth.Metadata, metadataMap map[string]tooth.Metadata,
+	required map[string]bool) error {
+
+	if required[metadata.ToothRepoPath()] {
+		return nil
+	}
+
+	required[metadata.ToothRepoPath()] = true
+
+	dependencies, err := metadata.Dependencies()
+	if err != nil {
+		return fmt.Errorf("failed to get dependencies of tooth %v\n\t%w", metadata.ToothRepoPath(), err)
+	}
+
+	for depToothRepoPath := range dependencies {
+		dep, ok := metadataMap[depToothRepoPath]
+		if !ok {
+			continue
+		}
+
+		if err := markRequired(dep, metadataMap, required); err != nil {
+			return err
+		}
+	}
+
+	providers, err := getProviders(metadata, metadataMap)
+	if err != nil {
+		return err
+	}
+
+	for _, provider := range providers {
+		if err := markRequired(provider, metadataMap, required); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getProviders returns the installed teeth providing the capabilities a tooth depends on, and
+// the teeth it depends on that are not installed themselves.
+func getProviders(metadata tooth.Metadata, metadataMap map[string]tooth.Metadata) ([]tooth.Metadata, error) {
+	dependencies, err := metadata.Dependencies()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dependencies of tooth %v\n\t%w", metadata.ToothRepoPath(), err)
+	}
+
+	capabilityDependencies, err := metadata.CapabilityDependencies()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get capability dependencies of tooth %v\n\t%w", metadata.ToothRepoPath(), err)
+	}
+
+	providers := make([]tooth.Metadata, 0)
+	for depToothRepoPath, versionRange := range dependencies {
+		if _, ok := metadataMap[depToothRepoPath]; ok {
+			continue
+		}
+
+		for _, provider := range metadataMap {
+			if provider.ProvidesTooth(depToothRepoPath, versionRange) {
+				providers = append(providers, provider)
+			}
+		}
+	}
+
+	for capability, versionRange := range capabilityDependencies {
+		for _, provider := range metadataMap {
+			if provider.ToothRepoPath() != metadata.ToothRepoPath() &&
+				provider.ProvidesCapability(capability, versionRange) {
+				providers = append(providers, provider)
+			}
+		}
+	}
+
+	return providers, nil
+}
+
+// visitOrphan appends an orphan to sorted after all its orphaned dependencies.
+func visitOrphan(metadata tooth.Metadata, metadataMap map[string]tooth.Metadata,
+	required map[string]bool, visited map[string]bool, sorted *[]tooth.Metadata) error {
+
+	if visited[metadata.ToothRepoPath()] {
+		return nil
+	}
+
+	visited[metadata.ToothRepoPath()] = true
+
+	dependencies, err := metadata.Dependencies()
+	if err != nil {
+		return fmt.Errorf("failed to get dependencies of tooth %v\n\t%w", metadata.ToothRepoPath(), err)
+	}
+
+	for depToothRepoPath := range dependencies {
+		dep, ok := metadataMap[depToothRepoPath]
+		if !ok || required[depToothRepoPath] {
+			continue
+		}
+
+		if err := visitOrphan(dep, metadataMap, required, visited, sorted); err != nil {
+			return err
+		}
+	}
+
+	*sorted = append(*sorted, metadata)
+
+	return nil
+}
@@ -0,0 +1,139 @@
+package cmdlipbuild
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/tooth"
+	log "github.com/sirupsen/logrus"
+)
+
+type FlagDict struct {
+	helpFlag bool
+}
+
+const helpMessage = `
+Usage:
+  lip build [options]
+
+Description:
+  Build the tooth in the current directory before packing it, by running the build commands of
+  its metadata file for the current platform. If the metadata file whitelists build output,
+  it is checked to exist afterwards, and lip tooth pack packs only the metadata file and the
+  build output.
+
+Options:
+  -h, --help                  Show help.
+`
+
+func Run(ctx *context.Context, args []string) error {
+
+	flagSet := flag.NewFlagSet("build", flag.ContinueOnError)
+
+	// Rewrite the default usage message.
+	flagSet.Usage = func() {
+		// Do nothing.
+	}
+
+	var flagDict FlagDict
+	flagSet.BoolVar(&flagDict.helpFlag, "help", false, "")
+	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
+	err := flagSet.Parse(args)
+	if err != nil {
+		return fmt.Errorf("failed to parse flags\n\t%w", err)
+	}
+
+	// Help flag has the highest priority.
+	if flagDict.helpFlag {
+		fmt.Print(helpMessage)
+		return nil
+	}
+
+	// Check if there are unexpected arguments.
+	if flagSet.NArg() != 0 {
+		return errcode.Errorf(errcode.InvalidArgument, "unexpected arguments: %v", flagSet.Args())
+	}
+
+	// 1. Read the build of the current platform.
+
+	metadataFilePath, ok, err := tooth.FindMetadataFile(".")
+	if err != nil {
+		return fmt.Errorf("failed to find the metadata file\n\t%w", err)
+	}
+
+	if !ok {
+		return errcode.Errorf(errcode.MetadataInvalid, "no tooth.json, tooth.yaml or tooth.toml in the current directory")
+	}
+
+	metadata, err := tooth.ReadMetadataFile(metadataFilePath)
+	if err != nil {
+		return err
+	}
+
+	metadata, err = metadata.ToPlatformSpecific(runtime.GOOS, runtime.GOARCH)
+	if err != nil {
+		return fmt.Errorf("failed to get platform-specific metadata\n\t%w", err)
+	}
+
+	build, err := metadata.Build()
+	if err != nil {
+		return fmt.Errorf("failed to get build of metadata\n\t%w", err)
+	}
+
+	if len(build.Commands) == 0 {
+		log.Info(i18n.T("No build commands for this platform."))
+	}
+
+	// 2. Run the build commands.
+
+	for _, command := range build.Commands {
+		log.Infof(i18n.T("Running %v"), command)
+
+		if err := runCommand(command); err != nil {
+			return err
+		}
+	}
+
+	// 3. Check the build output.
+
+	for _, outputPath := range build.Output {
+		if _, err := os.Stat(outputPath.LocalString()); os.IsNotExist(err) {
+			return i18n.Errorf("build output %v does not exist after building", outputPath.LocalString())
+		} else if err != nil {
+			return fmt.Errorf("failed to stat build output %v\n\t%w", outputPath.LocalString(), err)
+		}
+	}
+
+	log.Infof(i18n.T("Built %v"), metadata.ToothRepoPath())
+
+	return nil
+}
+
+// ---------------------------------------------------------------------
+
+// runCommand runs a build command in the shell of the platform.
+func runCommand(command string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.Command("cmd", "/C", command)
+	default:
+		cmd = exec.Command("sh", "-c", command)
+	}
+
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to run build command %v\n\t%w", command, err)
+	}
+
+	return nil
+}
@@ -5,7 +5,10 @@ import (
 	"fmt"
 
 	"github.com/lippkg/lip/internal/cmd/cmdlipcachepurge"
+	"github.com/lippkg/lip/internal/cmd/cmdlipcacheserve"
+	"github.com/lippkg/lip/internal/cmd/cmdlipcacheshare"
 	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
 )
 
 type FlagDict struct {
@@ -19,6 +22,8 @@ Usage:
 
 Commands:
   purge                       Clear the cache.
+  serve                       Serve the cache over HTTP as a Go module proxy.
+  share                       Share the cache with lip instances on the local network.
 
 Options:
   -h, --help                  Show help.
@@ -55,11 +60,22 @@ func Run(ctx *context.Context, args []string) error {
 			}
 			return nil
 
+		case "serve":
+			if err := cmdlipcacheserve.Run(ctx, flagSet.Args()[1:]); err != nil {
+				return err
+			}
+			return nil
+
+		case "share":
+			if err := cmdlipcacheshare.Run(ctx, flagSet.Args()[1:]); err != nil {
+				return err
+			}
+			return nil
+
 		default:
-			return fmt.Errorf("unknown command: lip cache %v", flagSet.Arg(0))
+			return errcode.Errorf(errcode.InvalidArgument, "unknown command: lip cache %v", flagSet.Arg(0))
 		}
 	}
 
-	return fmt.Errorf(
-		"no command specified. See 'lip cache --help' for more information")
+	return errcode.Errorf(errcode.InvalidArgument, "no command specified. See 'lip cache --help' for more information")
 }
@@ -5,7 +5,11 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/lippkg/lip/internal/contentstore"
 	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	log "github.com/sirupsen/logrus"
 )
 
 type FlagDict struct {
@@ -17,7 +21,8 @@ Usage:
   lip cache purge [options]
 
 Description:
-  Remove all items from the cache.
+  Remove all items from the cache, and the files in the content store that are no longer
+  used by any workspace.
 
 Options:
   -h, --help                  Show help.
@@ -47,7 +52,7 @@ func Run(ctx *context.Context, args []string) error {
 
 	// Check if there are unexpected arguments.
 	if flagSet.NArg() != 0 {
-		return fmt.Errorf("unexpected arguments: %v", flagSet.Args())
+		return errcode.Errorf(errcode.InvalidArgument, "unexpected arguments: %v", flagSet.Args())
 	}
 
 	// Purge the cache.
@@ -56,6 +61,13 @@ func Run(ctx *context.Context, args []string) error {
 		return fmt.Errorf("failed to purge the cache\n\t%w", err)
 	}
 
+	removedCount, err := contentstore.Prune(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to prune the content store\n\t%w", err)
+	}
+
+	log.Infof(i18n.T("Removed %v unused files from the content store."), removedCount)
+
 	return nil
 }
 
@@ -0,0 +1,97 @@
+package cmdlipcacheserve
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/lippkg/lip/internal/cacheproxy"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	log "github.com/sirupsen/logrus"
+)
+
+type FlagDict struct {
+	helpFlag bool
+	addrFlag string
+}
+
+const helpMessage = `
+Usage:
+  lip cache serve [options]
+
+Description:
+  Serve the tooth archives in the cache over HTTP as a Go module proxy until interrupted, so that
+  other machines, e.g. of an offline cluster, can install the cached versions of teeth from this
+  one. To install teeth from it, set GoModuleProxyURL to the URL it is served at.
+
+Options:
+  -h, --help                  Show help.
+  --addr <address>            The address to listen on. [default: 127.0.0.1:8080]
+`
+
+func Run(ctx *context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("serve", flag.ContinueOnError)
+
+	// Rewrite the default usage message.
+	flagSet.Usage = func() {
+		// Do nothing.
+	}
+
+	var flagDict FlagDict
+	flagSet.BoolVar(&flagDict.helpFlag, "help", false, "")
+	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
+	flagSet.StringVar(&flagDict.addrFlag, "addr", "127.0.0.1:8080", "")
+
+	if err := flagSet.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags\n\t%w", err)
+	}
+
+	// Help flag has the highest priority.
+	if flagDict.helpFlag {
+		fmt.Print(helpMessage)
+		return nil
+	}
+
+	// Check if there are unexpected arguments.
+	if flagSet.NArg() != 0 {
+		return errcode.Errorf(errcode.InvalidArgument, "unexpected arguments: %v", flagSet.Args())
+	}
+
+	handler, err := cacheproxy.NewHandler(ctx)
+	if err != nil {
+		return err
+	}
+
+	cacheDir, err := ctx.CacheDir()
+	if err != nil {
+		return fmt.Errorf("failed to get cache directory\n\t%w", err)
+	}
+
+	listener, err := net.Listen("tcp", flagDict.addrFlag)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %v\n\t%w", flagDict.addrFlag, err)
+	}
+	defer listener.Close()
+
+	baseURL := fmt.Sprintf("http://%v/", listener.Addr())
+
+	log.Infof(i18n.T("Serving %v at %v"), cacheDir.LocalString(), baseURL)
+	log.Infof(i18n.T("Install from it with GoModuleProxyURL set to %v."), baseURL)
+
+	server := &http.Server{Handler: handler}
+
+	// Serving stops when interrupted, e.g. by Ctrl-C.
+	go func() {
+		<-ctx.GoContext().Done()
+		server.Close()
+	}()
+
+	if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+		return fmt.Errorf("failed to serve %v\n\t%w", cacheDir.LocalString(), err)
+	}
+
+	return nil
+}
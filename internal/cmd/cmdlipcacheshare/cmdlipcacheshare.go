@@ -0,0 +1,82 @@
+package cmdlipcacheshare
+
+import (
+	"flag"
+	"fmt"
+	"net"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/lancache"
+	log "github.com/sirupsen/logrus"
+)
+
+type FlagDict struct {
+	helpFlag bool
+	addrFlag string
+}
+
+const helpMessage = `
+Usage:
+  lip cache share [options]
+
+Description:
+  Share the cache with lip instances on the local network until interrupted. Other instances
+  with LANCacheDiscoveryMs set ask the local network for the files they are about to download,
+  and fetch them from this one instead. Files are shared read-only and only by their checksums,
+  which the fetching instances verify.
+
+Options:
+  -h, --help                  Show help.
+  --addr <address>            The address to serve files on. [default: 0.0.0.0:47932]
+`
+
+func Run(ctx *context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("share", flag.ContinueOnError)
+
+	// Rewrite the default usage message.
+	flagSet.Usage = func() {
+		// Do nothing.
+	}
+
+	var flagDict FlagDict
+	flagSet.BoolVar(&flagDict.helpFlag, "help", false, "")
+	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
+	flagSet.StringVar(&flagDict.addrFlag, "addr", "0.0.0.0:47932", "")
+
+	if err := flagSet.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags\n\t%w", err)
+	}
+
+	// Help flag has the highest priority.
+	if flagDict.helpFlag {
+		fmt.Print(helpMessage)
+		return nil
+	}
+
+	// Check if there are unexpected arguments.
+	if flagSet.NArg() != 0 {
+		return errcode.Errorf(errcode.InvalidArgument, "unexpected arguments: %v", flagSet.Args())
+	}
+
+	listener, err := net.Listen("tcp", flagDict.addrFlag)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %v\n\t%w", flagDict.addrFlag, err)
+	}
+	defer listener.Close()
+
+	cacheDir, err := ctx.CacheDir()
+	if err != nil {
+		return fmt.Errorf("failed to get cache directory\n\t%w", err)
+	}
+
+	log.Infof(i18n.T("Sharing %v on the local network, answering at UDP port %v and serving at %v"),
+		cacheDir.LocalString(), lancache.DiscoveryPort, listener.Addr())
+
+	if err := lancache.Share(ctx, listener); err != nil {
+		return fmt.Errorf("failed to share cache\n\t%w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,174 @@
+package cmdlipcompletion
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/registry"
+	"github.com/lippkg/lip/internal/tooth"
+)
+
+type FlagDict struct {
+	helpFlag      bool
+	installedFlag bool
+	availableFlag bool
+}
+
+const helpMessage = `
+Usage:
+  lip completion [options] <shell>
+
+Description:
+  Generate the completion script for a shell. Supported shells are bash, zsh, fish and
+  powershell.
+
+  To load completions in the current session:
+
+    bash:        source <(lip completion bash)
+    zsh:         source <(lip completion zsh)
+    fish:        lip completion fish | source
+    powershell:  lip completion powershell | Out-String | Invoke-Expression
+
+Options:
+  -h, --help                  Show help.
+  --installed                 List installed teeth, for use by completion scripts.
+  --available                 List teeth in the registry, for use by completion scripts.
+`
+
+// commands are the top-level commands of lip.
+var commands = []string{
+	"audit", "autoremove", "build", "cache", "completion", "config", "db", "diff", "env", "export", "history",
+	"import", "index", "install", "lint", "list", "login", "logout", "migrate", "new", "owner", "prune", "publish",
+	"rollback", "search", "self", "show", "snapshot", "stats", "switch", "sync", "tooth", "tui", "undo", "uninstall",
+	"update", "vendor", "verify", "why", "workspace",
+}
+
+// subcommands are the subcommands of command groups.
+var subcommands = map[string][]string{
+	"cache":      {"purge", "serve", "share"},
+	"completion": {"bash", "zsh", "fish", "powershell"},
+	"db":         {"export"},
+	"index":      {"mirror", "serve"},
+	"self":       {"update"},
+	"snapshot":   {"create", "diff", "list", "restore"},
+	"tooth":      {"convert", "init", "pack", "validate"},
+}
+
+// installedToothCommands are the commands taking installed teeth as arguments.
+var installedToothCommands = []string{"diff", "rollback", "show", "uninstall", "update", "verify", "why"}
+
+// availableToothCommands are the commands taking teeth in the registry as arguments.
+var availableToothCommands = []string{"install"}
+
+var scriptTemplates = map[string]string{
+	"bash":       bashTemplate,
+	"zsh":        zshTemplate,
+	"fish":       fishTemplate,
+	"powershell": powerShellTemplate,
+}
+
+func Run(ctx *context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("completion", flag.ContinueOnError)
+
+	// Rewrite the default usage message.
+	flagSet.Usage = func() {
+		// Do nothing.
+	}
+
+	var flagDict FlagDict
+	flagSet.BoolVar(&flagDict.helpFlag, "help", false, "")
+	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
+	flagSet.BoolVar(&flagDict.installedFlag, "installed", false, "")
+	flagSet.BoolVar(&flagDict.availableFlag, "available", false, "")
+
+	if err := flagSet.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags\n\t%w", err)
+	}
+
+	// Help flag has the highest priority.
+	if flagDict.helpFlag {
+		fmt.Print(helpMessage)
+		return nil
+	}
+
+	if flagDict.installedFlag {
+		return listInstalledTeeth(ctx)
+	}
+
+	if flagDict.availableFlag {
+		return listAvailableTeeth(ctx)
+	}
+
+	// Exactly one argument is required.
+	if flagSet.NArg() != 1 {
+		return errcode.Errorf(errcode.InvalidArgument, "invalid number of arguments")
+	}
+
+	scriptTemplate, ok := scriptTemplates[flagSet.Arg(0)]
+	if !ok {
+		return errcode.Errorf(errcode.InvalidArgument, "unsupported shell: %v", flagSet.Arg(0))
+	}
+
+	if err := writeScript(scriptTemplate); err != nil {
+		return fmt.Errorf("failed to generate completion script\n\t%w", err)
+	}
+
+	return nil
+}
+
+// ---------------------------------------------------------------------
+
+// listAvailableTeeth prints the teeth in the registry, one per line. Nothing is printed if no
+// registry is configured.
+func listAvailableTeeth(ctx *context.Context) error {
+	if !registry.IsConfigured(ctx) {
+		return nil
+	}
+
+	toothRepoPaths, err := registry.ListTeeth(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get registry index\n\t%w", err)
+	}
+
+	for _, toothRepoPath := range toothRepoPaths {
+		fmt.Println(toothRepoPath)
+	}
+
+	return nil
+}
+
+// listInstalledTeeth prints the installed teeth, one per line.
+func listInstalledTeeth(ctx *context.Context) error {
+	metadataList, err := tooth.GetAllMetadata(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list all installed teeth\n\t%w", err)
+	}
+
+	for _, metadata := range metadataList {
+		fmt.Println(metadata.ToothRepoPath())
+	}
+
+	return nil
+}
+
+// writeScript renders a completion script template to stdout.
+func writeScript(scriptTemplate string) error {
+	tmpl, err := template.New("completion").Funcs(template.FuncMap{
+		"join": strings.Join,
+	}).Parse(scriptTemplate)
+	if err != nil {
+		return fmt.Errorf("failed to parse template\n\t%w", err)
+	}
+
+	return tmpl.Execute(os.Stdout, map[string]interface{}{
+		"Commands":               commands,
+		"Subcommands":            subcommands,
+		"InstalledToothCommands": installedToothCommands,
+		"AvailableToothCommands": availableToothCommands,
+	})
+}
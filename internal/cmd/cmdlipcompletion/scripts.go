@@ -0,0 +1,107 @@
+package cmdlipcompletion
+
+const bashTemplate = `# bash completion for lip
+
+_lip() {
+    local cur="${COMP_WORDS[COMP_CWORD]}"
+
+    if [ "$COMP_CWORD" -eq 1 ]; then
+        COMPREPLY=($(compgen -W "{{join .Commands " "}}" -- "$cur"))
+        return
+    fi
+
+    case "${COMP_WORDS[1]}" in
+{{- range $command, $names := .Subcommands}}
+        {{$command}})
+            if [ "$COMP_CWORD" -eq 2 ]; then
+                COMPREPLY=($(compgen -W "{{join $names " "}}" -- "$cur"))
+            fi
+            ;;
+{{- end}}
+        {{join .InstalledToothCommands "|"}})
+            COMPREPLY=($(compgen -W "$(lip completion --installed 2>/dev/null)" -- "$cur"))
+            ;;
+        {{join .AvailableToothCommands "|"}})
+            COMPREPLY=($(compgen -W "$(lip completion --available 2>/dev/null)" -- "$cur"))
+            ;;
+    esac
+}
+
+complete -F _lip lip
+`
+
+const zshTemplate = `#compdef lip
+# zsh completion for lip
+
+_lip() {
+    if (( CURRENT == 2 )); then
+        compadd -- {{join .Commands " "}}
+        return
+    fi
+
+    case "${words[2]}" in
+{{- range $command, $names := .Subcommands}}
+        {{$command}})
+            (( CURRENT == 3 )) && compadd -- {{join $names " "}}
+            ;;
+{{- end}}
+        {{join .InstalledToothCommands "|"}})
+            compadd -- ${(f)"$(lip completion --installed 2>/dev/null)"}
+            ;;
+        {{join .AvailableToothCommands "|"}})
+            compadd -- ${(f)"$(lip completion --available 2>/dev/null)"}
+            ;;
+    esac
+}
+
+compdef _lip lip
+`
+
+const fishTemplate = `# fish completion for lip
+
+complete -c lip -f
+complete -c lip -n "__fish_use_subcommand" -a "{{join .Commands " "}}"
+{{- range $command, $names := .Subcommands}}
+complete -c lip -n "__fish_seen_subcommand_from {{$command}}" -a "{{join $names " "}}"
+{{- end}}
+complete -c lip -n "__fish_seen_subcommand_from {{join .InstalledToothCommands " "}}" -a "(lip completion --installed 2>/dev/null)"
+complete -c lip -n "__fish_seen_subcommand_from {{join .AvailableToothCommands " "}}" -a "(lip completion --available 2>/dev/null)"
+`
+
+const powerShellTemplate = `# PowerShell completion for lip
+
+Register-ArgumentCompleter -Native -CommandName lip -ScriptBlock {
+    param($wordToComplete, $commandAst, $cursorPosition)
+
+    $elements = @($commandAst.CommandElements | ForEach-Object { $_.ToString() })
+    $position = $elements.Count
+    if ($wordToComplete -ne '') {
+        $position--
+    }
+
+    $candidates = @()
+    if ($position -le 1) {
+        $candidates = @({{range $i, $name := .Commands}}{{if $i}}, {{end}}'{{$name}}'{{end}})
+    } else {
+        switch ($elements[1]) {
+{{- range $command, $names := .Subcommands}}
+            '{{$command}}' {
+                if ($position -eq 2) {
+                    $candidates = @({{range $i, $name := $names}}{{if $i}}, {{end}}'{{$name}}'{{end}})
+                }
+            }
+{{- end}}
+            { $_ -in @({{range $i, $name := .InstalledToothCommands}}{{if $i}}, {{end}}'{{$name}}'{{end}}) } {
+                $candidates = @(lip completion --installed 2>$null)
+            }
+            { $_ -in @({{range $i, $name := .AvailableToothCommands}}{{if $i}}, {{end}}'{{$name}}'{{end}}) } {
+                $candidates = @(lip completion --available 2>$null)
+            }
+        }
+    }
+
+    $candidates | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+        [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+    }
+}
+`
@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
 	"github.com/olekukonko/tablewriter"
 )
 
@@ -68,7 +69,7 @@ func Run(ctx *context.Context, args []string) error {
 		}
 
 	default:
-		return fmt.Errorf("too many arguments")
+		return errcode.Errorf(errcode.InvalidArgument, "too many arguments")
 	}
 
 	return nil
@@ -128,18 +129,18 @@ func convertStringToType(str string, targetType reflect.Type) (interface{}, erro
 		return value.Interface(), nil
 
 	default:
-		return nil, fmt.Errorf("unsupported type: %v", targetType)
+		return nil, errcode.Errorf(errcode.InvalidArgument, "unsupported type: %v", targetType)
 	}
 }
 
 func setConfig(ctx *context.Context, key string, value string) error {
 	field := reflect.ValueOf(ctx.Config()).Elem().FieldByName(key)
 	if !field.IsValid() {
-		return fmt.Errorf("no such key: %v", key)
+		return errcode.Errorf(errcode.InvalidArgument, "no such key: %v", key)
 	}
 
 	if !field.CanSet() {
-		return fmt.Errorf("cannot set key: %v", key)
+		return errcode.Errorf(errcode.InvalidArgument, "cannot set key: %v", key)
 	}
 
 	fieldType := field.Type()
@@ -153,7 +154,53 @@ func setConfig(ctx *context.Context, key string, value string) error {
 		field.Set(structValue.Convert(fieldType))
 
 	} else {
-		return fmt.Errorf("cannot convert value to type: %v", fieldType)
+		return errcode.Errorf(errcode.InvalidArgument, "cannot convert value to type: %v", fieldType)
+	}
+
+	// Reject configuration that cannot be applied, since lip would fail to start or to
+	// resolve teeth.
+	if _, err := ctx.RateLimit(); err != nil {
+		return errcode.Errorf(errcode.InvalidArgument, "invalid value of %v\n\t%w", key, err)
+	}
+
+	if _, err := ctx.DialerConfig(); err != nil {
+		return errcode.Errorf(errcode.InvalidArgument, "invalid value of %v\n\t%w", key, err)
+	}
+
+	if _, err := ctx.ExtraGitHubMirrorURLs(); err != nil {
+		return errcode.Errorf(errcode.InvalidArgument, "invalid value of %v\n\t%w", key, err)
+	}
+
+	if _, err := ctx.ExtraGoModuleProxyURLs(); err != nil {
+		return errcode.Errorf(errcode.InvalidArgument, "invalid value of %v\n\t%w", key, err)
+	}
+
+	if _, err := ctx.ToothSource(); err != nil {
+		return errcode.Errorf(errcode.InvalidArgument, "invalid value of %v\n\t%w", key, err)
+	}
+
+	if _, err := ctx.CredentialStore(); err != nil {
+		return errcode.Errorf(errcode.InvalidArgument, "invalid value of %v\n\t%w", key, err)
+	}
+
+	if _, err := ctx.UpdatePolicy(); err != nil {
+		return errcode.Errorf(errcode.InvalidArgument, "invalid value of %v\n\t%w", key, err)
+	}
+
+	if _, err := ctx.Quarantine(); err != nil {
+		return errcode.Errorf(errcode.InvalidArgument, "invalid value of %v\n\t%w", key, err)
+	}
+
+	if _, err := ctx.Theme(); err != nil {
+		return errcode.Errorf(errcode.InvalidArgument, "invalid value of %v\n\t%w", key, err)
+	}
+
+	if _, err := ctx.WebhookURLs(); err != nil {
+		return errcode.Errorf(errcode.InvalidArgument, "invalid value of %v\n\t%w", key, err)
+	}
+
+	if _, err := ctx.WorkspaceType(); err != nil {
+		return errcode.Errorf(errcode.InvalidArgument, "invalid value of %v\n\t%w", key, err)
 	}
 
 	if err := ctx.SaveConfigFile(); err != nil {
@@ -191,7 +238,7 @@ func showConfig(ctx *context.Context, key string) error {
 		fmt.Printf("%v\n", value)
 
 	} else {
-		return fmt.Errorf("no such key: %v", key)
+		return errcode.Errorf(errcode.InvalidArgument, "no such key: %v", key)
 	}
 
 	return nil
@@ -0,0 +1,65 @@
+package cmdlipdb
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/lippkg/lip/internal/cmd/cmdlipdbexport"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+)
+
+type FlagDict struct {
+	helpFlag bool
+}
+
+const helpMessage = `
+Usage:
+  lip db [options]
+  lip db <command> [subcommand options] ...
+
+Commands:
+  export                      Print the installed-state database as JSON.
+
+Options:
+  -h, --help                  Show help.
+`
+
+func Run(ctx *context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("db", flag.ContinueOnError)
+
+	// Rewrite the default usage message.
+	flagSet.Usage = func() {
+		// Do nothing.
+	}
+
+	var flagDict FlagDict
+	flagSet.BoolVar(&flagDict.helpFlag, "help", false, "")
+	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
+
+	if err := flagSet.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags\n\t%w", err)
+	}
+
+	// Help flag has the highest priority.
+	if flagDict.helpFlag {
+		fmt.Print(helpMessage)
+		return nil
+	}
+
+	// If there is a subcommand, run it and exit.
+	if flagSet.NArg() >= 1 {
+		switch flagSet.Arg(0) {
+		case "export":
+			if err := cmdlipdbexport.Run(ctx, flagSet.Args()[1:]); err != nil {
+				return err
+			}
+			return nil
+
+		default:
+			return errcode.Errorf(errcode.InvalidArgument, "unknown command: lip db %v", flagSet.Arg(0))
+		}
+	}
+
+	return errcode.Errorf(errcode.InvalidArgument, "no command specified. See 'lip db --help' for more information")
+}
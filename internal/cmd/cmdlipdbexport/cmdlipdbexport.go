@@ -0,0 +1,68 @@
+package cmdlipdbexport
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/statedb"
+)
+
+type FlagDict struct {
+	helpFlag bool
+}
+
+const helpMessage = `
+Usage:
+  lip db export [options]
+
+Description:
+  Print the installed-state database of the workspace as indented JSON: the metadata, record
+  and manifest of each installed tooth, with the files it owns.
+
+Options:
+  -h, --help                  Show help.
+`
+
+func Run(ctx *context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("export", flag.ContinueOnError)
+
+	// Rewrite the default usage message.
+	flagSet.Usage = func() {
+		// Do nothing.
+	}
+
+	var flagDict FlagDict
+	flagSet.BoolVar(&flagDict.helpFlag, "help", false, "")
+	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
+
+	if err := flagSet.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags\n\t%w", err)
+	}
+
+	// Help flag has the highest priority.
+	if flagDict.helpFlag {
+		fmt.Print(helpMessage)
+		return nil
+	}
+
+	// Check if there are unexpected arguments.
+	if flagSet.NArg() != 0 {
+		return errcode.Errorf(errcode.InvalidArgument, "unexpected arguments: %v", flagSet.Args())
+	}
+
+	db, err := statedb.Open(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open state database\n\t%w", err)
+	}
+
+	jsonBytes, err := db.Export()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(jsonBytes))
+
+	return nil
+}
@@ -0,0 +1,224 @@
+package cmdlipdiff
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/cmd/cmdlipinstall"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/diskspace"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/manifest"
+	"github.com/lippkg/lip/internal/network"
+	"github.com/lippkg/lip/internal/render"
+	"github.com/lippkg/lip/internal/tooth"
+	"github.com/olekukonko/tablewriter"
+	log "github.com/sirupsen/logrus"
+)
+
+type FlagDict struct {
+	helpFlag bool
+	jsonFlag bool
+}
+
+const helpMessage = `
+Usage:
+  lip diff [options] <tooth repository URL>[@<version>]
+
+Description:
+  Compare the files placed by an installed tooth with those another version would place, without
+  installing it. Files added, removed and changed are listed with their sizes, followed by the
+  change of the total size. If no version is specified, the latest version is compared.
+
+Options:
+  -h, --help                  Show help.
+  --json                      Output in JSON format.
+`
+
+func Run(ctx *context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("diff", flag.ContinueOnError)
+
+	// Rewrite the default usage message.
+	flagSet.Usage = func() {
+		// Do nothing.
+	}
+
+	var flagDict FlagDict
+	flagSet.BoolVar(&flagDict.helpFlag, "help", false, "")
+	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
+	flagSet.BoolVar(&flagDict.jsonFlag, "json", false, "")
+
+	if err := flagSet.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags\n\t%w", err)
+	}
+
+	// Help flag has the highest priority.
+	if flagDict.helpFlag {
+		fmt.Print(helpMessage)
+		return nil
+	}
+
+	// Exactly one argument is required.
+	if flagSet.NArg() != 1 {
+		return errcode.Errorf(errcode.InvalidArgument, "expected exactly one argument")
+	}
+
+	toothRepoPathString, versionString, isVersionSpecified := strings.Cut(flagSet.Arg(0), "@")
+
+	toothRepoPath := network.NormalizeToothRepoPath(toothRepoPathString)
+	if !tooth.IsValidToothRepoPath(toothRepoPath) {
+		return errcode.Errorf(errcode.InvalidArgument, "invalid tooth repo path %v", toothRepoPathString)
+	}
+
+	isInstalled, err := tooth.IsInstalled(ctx, toothRepoPath)
+	if err != nil {
+		return fmt.Errorf("failed to check if tooth is installed\n\t%w", err)
+	}
+
+	if !isInstalled {
+		return errcode.Errorf(errcode.NotInstalled, "tooth %v is not installed", toothRepoPath)
+	}
+
+	installedManifest, ok, err := manifest.Get(ctx, toothRepoPath)
+	if err != nil {
+		return fmt.Errorf("failed to get manifest of %v\n\t%w", toothRepoPath, err)
+	}
+
+	if !ok {
+		return fmt.Errorf("no manifest of %v is recorded, reinstall it to record one", toothRepoPath)
+	}
+
+	var version semver.Version
+	if isVersionSpecified {
+		version, err = semver.Parse(versionString)
+		if err != nil {
+			return errcode.Errorf(errcode.InvalidArgument, "invalid version %v", versionString)
+		}
+	} else {
+		version, err = cmdlipinstall.GetLatestSupportedVersion(ctx, toothRepoPath, func(semver.Version) bool {
+			return true
+		})
+		if err != nil {
+			return fmt.Errorf("failed to get latest version of %v\n\t%w", toothRepoPath, err)
+		}
+	}
+
+	archive, err := cmdlipinstall.DownloadToothArchive(ctx, toothRepoPath, version)
+	if err != nil {
+		return fmt.Errorf("failed to download %v@%v\n\t%w", toothRepoPath, version, err)
+	}
+
+	candidateFiles, err := getCandidateFiles(ctx, archive)
+	if err != nil {
+		return fmt.Errorf("failed to read files of %v@%v\n\t%w", toothRepoPath, version, err)
+	}
+
+	changes := diffFiles(ctx, installedManifest, candidateFiles)
+
+	if flagDict.jsonFlag {
+		jsonBytes, err := json.Marshal(map[string]interface{}{
+			"tooth": toothRepoPath,
+			"from":  installedManifest.Version,
+			"to":    version.String(),
+			"files": changes,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to marshal JSON\n\t%w", err)
+		}
+
+		fmt.Print(string(jsonBytes))
+		return nil
+	}
+
+	if len(changes) == 0 {
+		log.Infof(i18n.T("No differences between %v@%v and %v."), toothRepoPath, installedManifest.Version, version)
+		return nil
+	}
+
+	printChanges(changes)
+
+	return nil
+}
+
+// ---------------------------------------------------------------------
+
+// changeRoles are the roles the kinds of changes are rendered as.
+var changeRoles = map[changeKind]render.Role{
+	addedChange:   render.AddedRole,
+	removedChange: render.RemovedRole,
+	changedChange: render.ChangedRole,
+}
+
+// printChanges prints the changed files as a table, followed by the counts of the changes and
+// the change of the total size.
+func printChanges(changes []fileChange) {
+	tableString := &strings.Builder{}
+	table := tablewriter.NewWriter(tableString)
+	table.SetHeader([]string{"File", "Change", "Size"})
+
+	counts := make(map[changeKind]int)
+	var sizeDelta int64
+	isSomeUnknown := false
+
+	for _, change := range changes {
+		counts[change.Kind]++
+
+		if change.OldSize != nil {
+			sizeDelta -= *change.OldSize
+		} else if change.Kind != addedChange {
+			isSomeUnknown = true
+		}
+
+		if change.NewSize != nil {
+			sizeDelta += *change.NewSize
+		}
+
+		var size string
+		switch change.Kind {
+		case addedChange:
+			size = formatSize(change.NewSize)
+
+		case removedChange:
+			size = formatSize(change.OldSize)
+
+		default:
+			size = fmt.Sprintf("%v -> %v", formatSize(change.OldSize), formatSize(change.NewSize))
+		}
+
+		table.Append([]string{change.Path, render.Style(changeRoles[change.Kind], string(change.Kind)), size})
+	}
+
+	table.Render()
+
+	fmt.Print(tableString.String())
+
+	sizeDeltaString := formatSizeDelta(sizeDelta)
+	if isSomeUnknown {
+		sizeDeltaString = i18n.Sprintf("%v (some sizes are unknown)", sizeDeltaString)
+	}
+
+	fmt.Printf(i18n.T("%v added, %v removed, %v changed, size delta %v")+"\n", counts[addedChange],
+		counts[removedChange], counts[changedChange], sizeDeltaString)
+}
+
+// formatSize formats the size of a file, which is unknown if nil.
+func formatSize(size *int64) string {
+	if size == nil {
+		return "?"
+	}
+
+	return diskspace.FormatSize(*size)
+}
+
+// formatSizeDelta formats a change of size with its sign.
+func formatSizeDelta(sizeDelta int64) string {
+	if sizeDelta < 0 {
+		return "-" + diskspace.FormatSize(-sizeDelta)
+	}
+
+	return "+" + diskspace.FormatSize(sizeDelta)
+}
@@ -0,0 +1,194 @@
+package cmdlipdiff
+
+import (
+	gozip "archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/install"
+	"github.com/lippkg/lip/internal/manifest"
+	"github.com/lippkg/lip/internal/path"
+	"github.com/lippkg/lip/internal/tooth"
+	"github.com/lippkg/lip/internal/zip"
+)
+
+// changeKind is how a file changes from the installed version to the candidate version.
+type changeKind string
+
+const (
+	addedChange   changeKind = "added"
+	removedChange changeKind = "removed"
+	changedChange changeKind = "changed"
+)
+
+// fileChange is a file placed differently by the candidate version.
+type fileChange struct {
+	// Path is the path of the file relative to the workspace directory.
+	Path string     `json:"path"`
+	Kind changeKind `json:"change"`
+	// OldSize is the size of the installed file. Nil if the file is added or missing.
+	OldSize *int64 `json:"old_size,omitempty"`
+	// NewSize is the size of the file in the candidate version. Nil if the file is removed.
+	NewSize *int64 `json:"new_size,omitempty"`
+}
+
+// candidateFile is a file the candidate version would place.
+type candidateFile struct {
+	sha256   string
+	size     int64
+	userData bool
+}
+
+// getCandidateFiles returns the files a tooth archive with an asset archive would place, by
+// their paths relative to the workspace directory, remapped by the workspace manifest as lip
+// install places them.
+func getCandidateFiles(ctx *context.Context, archive tooth.Archive) (map[string]candidateFile, error) {
+	metadata, _, err := install.RemapDestinations(ctx, archive.Metadata())
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := metadata.Files()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get files from metadata\n\t%w", err)
+	}
+
+	assetFilePath, err := archive.AssetFilePath()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get asset file path of archive %v\n\t%w", archive.FilePath().LocalString(), err)
+	}
+
+	r, err := zip.OpenReader(ctx.FS(), assetFilePath.LocalString())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open zip reader\n\t%w", err)
+	}
+	defer r.Close()
+
+	// Index the files in the archive by path, skipping directories.
+	archiveFileMap := make(map[string]*gozip.File)
+	for _, f := range r.File {
+		if strings.HasSuffix(f.Name, "/") {
+			continue
+		}
+
+		filePath, err := path.ParseRelative(f.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse file path from %v\n\t%w", f.Name, err)
+		}
+
+		archiveFileMap[filePath.String()] = f
+	}
+
+	candidateFiles := make(map[string]candidateFile)
+	for _, place := range files.Place {
+		f, ok := archiveFileMap[place.Src.String()]
+		if !ok {
+			continue
+		}
+
+		checksum, err := hashFileInArchive(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash %v\n\t%w", f.Name, err)
+		}
+
+		candidateFiles[place.Dest.String()] = candidateFile{
+			sha256:   checksum,
+			size:     int64(f.UncompressedSize64),
+			userData: place.UserData,
+		}
+	}
+
+	return candidateFiles, nil
+}
+
+// diffFiles compares the files in the manifest of an installed tooth with the files the
+// candidate version would place, sorted by path. Files holding user data are changed by users
+// and kept on upgrades, so only those added are listed. The sizes of installed files are read
+// from the workspace, since manifests do not record them.
+func diffFiles(ctx *context.Context, installedManifest manifest.Manifest, candidateFiles map[string]candidateFile) []fileChange {
+	changes := make([]fileChange, 0)
+
+	installedFiles := make(map[string]manifest.File)
+	for _, installedFile := range installedManifest.Files {
+		installedFiles[installedFile.Path] = installedFile
+	}
+
+	for filePath, candidate := range candidateFiles {
+		newSize := candidate.size
+
+		installedFile, ok := installedFiles[filePath]
+		if !ok {
+			changes = append(changes, fileChange{Path: filePath, Kind: addedChange, NewSize: &newSize})
+			continue
+		}
+
+		if installedFile.UserData || candidate.userData || installedFile.SHA256 == candidate.sha256 {
+			continue
+		}
+
+		changes = append(changes, fileChange{
+			Path:    filePath,
+			Kind:    changedChange,
+			OldSize: getInstalledFileSize(ctx, filePath),
+			NewSize: &newSize,
+		})
+	}
+
+	for filePath, installedFile := range installedFiles {
+		if _, ok := candidateFiles[filePath]; ok || installedFile.UserData {
+			continue
+		}
+
+		changes = append(changes, fileChange{
+			Path:    filePath,
+			Kind:    removedChange,
+			OldSize: getInstalledFileSize(ctx, filePath),
+		})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+
+	return changes
+}
+
+// getInstalledFileSize returns the size of an installed file by its path relative to the
+// workspace directory. Nil if the file is missing.
+func getInstalledFileSize(ctx *context.Context, filePath string) *int64 {
+	parsedPath, err := path.Parse(filePath)
+	if err != nil {
+		return nil
+	}
+
+	// Links to the store or to the directory of a version are followed.
+	fileInfo, err := ctx.FS().Stat(parsedPath.LocalString())
+	if err != nil {
+		return nil
+	}
+
+	size := fileInfo.Size()
+	return &size
+}
+
+// hashFileInArchive returns the SHA-256 checksum of a file in an archive in hex, as recorded in
+// manifests.
+func hashFileInArchive(f *gozip.File) (string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, rc); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,114 @@
+package cmdlipenv
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/environment"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	log "github.com/sirupsen/logrus"
+)
+
+type FlagDict struct {
+	helpFlag  bool
+	jsonFlag  bool
+	shellFlag string
+	applyFlag bool
+}
+
+const helpMessage = `
+Usage:
+  lip env [options]
+
+Description:
+  Show the environment required by the installed teeth, e.g. directories to add to PATH and
+  environment variables to set. By default, a snippet to run in the current shell is printed.
+
+Options:
+  -h, --help                  Show help.
+  --json                      Output in JSON format.
+  --shell <shell>             Format the snippet for a shell: sh, fish, powershell or cmd.
+  --apply                     Persist the environment for new shells, in the shell profile on
+                              Unix or in the user environment variables on Windows.
+`
+
+func Run(ctx *context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("env", flag.ContinueOnError)
+
+	// Rewrite the default usage message.
+	flagSet.Usage = func() {
+		// Do nothing.
+	}
+
+	var flagDict FlagDict
+	flagSet.BoolVar(&flagDict.helpFlag, "help", false, "")
+	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
+	flagSet.BoolVar(&flagDict.jsonFlag, "json", false, "")
+	flagSet.StringVar(&flagDict.shellFlag, "shell", "", "")
+	flagSet.BoolVar(&flagDict.applyFlag, "apply", false, "")
+
+	if err := flagSet.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags\n\t%w", err)
+	}
+
+	// Help flag has the highest priority.
+	if flagDict.helpFlag {
+		fmt.Print(helpMessage)
+		return nil
+	}
+
+	// Check if there are unexpected arguments.
+	if flagSet.NArg() != 0 {
+		return errcode.Errorf(errcode.InvalidArgument, "unexpected arguments: %v", flagSet.Args())
+	}
+
+	shell := environment.DefaultShell()
+	if flagDict.shellFlag != "" {
+		shell = environment.Shell(flagDict.shellFlag)
+	}
+
+	switch shell {
+	case environment.ShShell, environment.FishShell, environment.PowerShellShell, environment.CmdShell:
+	default:
+		return errcode.Errorf(errcode.InvalidArgument, "unsupported shell: %v", shell)
+	}
+
+	env, err := environment.Get(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get environment\n\t%w", err)
+	}
+
+	if flagDict.applyFlag {
+		appliedTo, err := environment.Apply(env, shell)
+		if err != nil {
+			return fmt.Errorf("failed to apply environment\n\t%w", err)
+		}
+
+		log.Infof(i18n.T("Applied the environment to %v. It takes effect in new shells."), appliedTo)
+
+		return nil
+	}
+
+	if flagDict.jsonFlag {
+		jsonBytes, err := json.Marshal(env)
+		if err != nil {
+			return fmt.Errorf("failed to marshal JSON\n\t%w", err)
+		}
+
+		fmt.Print(string(jsonBytes))
+
+		return nil
+	}
+
+	snippet, err := environment.Format(env, shell)
+	if err != nil {
+		return fmt.Errorf("failed to format environment\n\t%w", err)
+	}
+
+	fmt.Print(snippet)
+
+	return nil
+}
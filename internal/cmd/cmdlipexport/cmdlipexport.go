@@ -0,0 +1,138 @@
+package cmdlipexport
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/lippkg/lip/internal/bundle"
+	"github.com/lippkg/lip/internal/cmd/cmdlipvendor"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/path"
+	"github.com/lippkg/lip/internal/record"
+	"github.com/lippkg/lip/internal/tooth"
+	log "github.com/sirupsen/logrus"
+)
+
+type FlagDict struct {
+	helpFlag bool
+}
+
+const helpMessage = `
+Usage:
+  lip export [options] <bundle path>
+
+Description:
+  Export the installed teeth of the workspace to a bundle, a single file with their tooth
+  archives, their asset archives for the current platform and their installation records.
+  Reproduce the installation on another machine without network access with lip import.
+
+Options:
+  -h, --help                  Show help.
+`
+
+func Run(ctx *context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("export", flag.ContinueOnError)
+
+	// Rewrite the default usage message.
+	flagSet.Usage = func() {
+		// Do nothing.
+	}
+
+	var flagDict FlagDict
+	flagSet.BoolVar(&flagDict.helpFlag, "help", false, "")
+	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
+
+	if err := flagSet.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags\n\t%w", err)
+	}
+
+	// Help flag has the highest priority.
+	if flagDict.helpFlag {
+		fmt.Print(helpMessage)
+		return nil
+	}
+
+	// Exactly one argument is required.
+	if flagSet.NArg() != 1 {
+		return errcode.Errorf(errcode.InvalidArgument, "expected exactly one argument")
+	}
+
+	bundlePath, err := path.Parse(flagSet.Arg(0))
+	if err != nil {
+		return errcode.Errorf(errcode.InvalidArgument, "invalid bundle path %v", flagSet.Arg(0))
+	}
+
+	if _, err := ctx.FS().Stat(bundlePath.LocalString()); err == nil {
+		return errcode.Errorf(errcode.InvalidArgument, "output path %v already exists", bundlePath.LocalString())
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to stat output path %v\n\t%w", bundlePath.LocalString(), err)
+	}
+
+	manifest, err := makeManifest(ctx)
+	if err != nil {
+		return err
+	}
+
+	stagingDir, err := bundle.MakeStagingDir(ctx)
+	if err != nil {
+		return err
+	}
+	defer ctx.FS().RemoveAll(stagingDir.LocalString())
+
+	// Collect the archives like lip vendor does, but into the staging directory.
+	ctx.SetVendorDir(stagingDir.Join(path.MustParse(bundle.VendorDirName)))
+
+	if _, err := cmdlipvendor.VendorInstalledTeeth(ctx); err != nil {
+		return err
+	}
+
+	vendorDir, err := ctx.VendorDir()
+	if err != nil {
+		return fmt.Errorf("failed to get vendor directory\n\t%w", err)
+	}
+
+	if err := bundle.Pack(ctx.FS(), bundlePath, manifest, vendorDir); err != nil {
+		ctx.FS().Remove(bundlePath.LocalString())
+		return err
+	}
+
+	log.Infof(i18n.T("Exported %v teeth to %v."), len(manifest.Teeth), bundlePath.LocalString())
+
+	return nil
+}
+
+// ---------------------------------------------------------------------
+
+// makeManifest makes the bundle manifest of the installed teeth.
+func makeManifest(ctx *context.Context) (bundle.Manifest, error) {
+	metadataList, err := tooth.GetAllMetadata(ctx)
+	if err != nil {
+		return bundle.Manifest{}, fmt.Errorf("failed to list all installed teeth\n\t%w", err)
+	}
+
+	sort.Slice(metadataList, func(i, j int) bool {
+		return metadataList[i].ToothRepoPath() < metadataList[j].ToothRepoPath()
+	})
+
+	teeth := make([]bundle.Tooth, 0, len(metadataList))
+	for _, metadata := range metadataList {
+		currentRecord, err := record.Get(ctx, metadata.ToothRepoPath())
+		if err != nil {
+			return bundle.Manifest{}, fmt.Errorf("failed to get record of tooth %v\n\t%w", metadata.ToothRepoPath(),
+				err)
+		}
+
+		teeth = append(teeth, bundle.Tooth{
+			ToothRepoPath: metadata.ToothRepoPath(),
+			Version:       metadata.Version().String(),
+			IsExplicit:    currentRecord.IsExplicit,
+			Override:      currentRecord.Override,
+		})
+	}
+
+	return bundle.NewManifest(ctx.LipVersion().String(), teeth), nil
+}
@@ -0,0 +1,123 @@
+package cmdliphistory
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/history"
+	"github.com/olekukonko/tablewriter"
+)
+
+type FlagDict struct {
+	helpFlag bool
+}
+
+const helpMessage = `
+Usage:
+  lip history [options]
+
+Description:
+  List the operations on the teeth of the workspace, oldest first, with the time, the user and
+  the changes of each. Operations reverted by lip undo are marked as undone, and operations
+  interrupted by Ctrl-C or SIGTERM as interrupted, with the changes left after rolling back.
+
+Options:
+  -h, --help                  Show help.
+`
+
+func Run(ctx *context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("history", flag.ContinueOnError)
+
+	// Rewrite the default usage message.
+	flagSet.Usage = func() {
+		// Do nothing.
+	}
+
+	var flagDict FlagDict
+	flagSet.BoolVar(&flagDict.helpFlag, "help", false, "")
+	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
+
+	if err := flagSet.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags\n\t%w", err)
+	}
+
+	// Help flag has the highest priority.
+	if flagDict.helpFlag {
+		fmt.Print(helpMessage)
+		return nil
+	}
+
+	// Check if there are unexpected arguments.
+	if flagSet.NArg() != 0 {
+		return errcode.Errorf(errcode.InvalidArgument, "unexpected arguments: %v", flagSet.Args())
+	}
+
+	entries, err := history.List(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read history\n\t%w", err)
+	}
+
+	undone := make(map[int]bool)
+	for _, entry := range entries {
+		if entry.Operation == history.UndoOperation {
+			undone[entry.Undoes] = true
+		}
+	}
+
+	tableString := &strings.Builder{}
+	table := tablewriter.NewWriter(tableString)
+	table.SetHeader([]string{"ID", "Time", "User", "Operation", "Changes"})
+	table.SetAutoWrapText(false)
+	table.SetRowLine(true)
+
+	for _, entry := range entries {
+		operation := string(entry.Operation)
+		switch {
+		case entry.Operation == history.UndoOperation:
+			operation = fmt.Sprintf("undo #%v", entry.Undoes)
+
+		case undone[entry.ID]:
+			operation += " (undone)"
+		}
+
+		if entry.Interrupted {
+			operation += " (interrupted)"
+		}
+
+		changeStrings := make([]string, 0, len(entry.Changes))
+		for _, change := range entry.Changes {
+			changeStrings = append(changeStrings, formatChange(change))
+		}
+
+		table.Append([]string{fmt.Sprint(entry.ID), entry.Time.Local().Format("2006-01-02 15:04:05"), entry.User,
+			operation, strings.Join(changeStrings, "\n")})
+	}
+
+	table.Render()
+
+	fmt.Print(tableString.String())
+
+	return nil
+}
+
+// ---------------------------------------------------------------------
+
+// formatChange returns a change of a tooth as a string.
+func formatChange(change history.Change) string {
+	switch {
+	case change.From == "":
+		return fmt.Sprintf("%v: installed %v", change.ToothRepoPath, change.To)
+
+	case change.To == "":
+		return fmt.Sprintf("%v: uninstalled %v", change.ToothRepoPath, change.From)
+
+	case change.From == change.To:
+		return fmt.Sprintf("%v: reinstalled %v", change.ToothRepoPath, change.To)
+
+	default:
+		return fmt.Sprintf("%v: %v -> %v", change.ToothRepoPath, change.From, change.To)
+	}
+}
@@ -0,0 +1,183 @@
+package cmdlipimport
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/bundle"
+	"github.com/lippkg/lip/internal/cmd/cmdlipinstall"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/history"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/install"
+	"github.com/lippkg/lip/internal/path"
+	"github.com/lippkg/lip/internal/record"
+	"github.com/lippkg/lip/internal/tooth"
+	log "github.com/sirupsen/logrus"
+)
+
+type FlagDict struct {
+	helpFlag bool
+	yesFlag  bool
+}
+
+const helpMessage = `
+Usage:
+  lip import [options] <bundle path>
+
+Description:
+  Install the teeth in a bundle made by lip export, at the exact versions and with the
+  installation records they had, without accessing the network. Installed teeth of other
+  versions are replaced. Installed teeth not in the bundle are kept.
+
+Options:
+  -h, --help                  Show help.
+  -y, --yes                   Assume yes to all prompts and run non-interactively.
+`
+
+func Run(ctx *context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("import", flag.ContinueOnError)
+
+	// Rewrite the default usage message.
+	flagSet.Usage = func() {
+		// Do nothing.
+	}
+
+	var flagDict FlagDict
+	flagSet.BoolVar(&flagDict.helpFlag, "help", false, "")
+	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
+	flagSet.BoolVar(&flagDict.yesFlag, "yes", false, "")
+	flagSet.BoolVar(&flagDict.yesFlag, "y", false, "")
+
+	if err := flagSet.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags\n\t%w", err)
+	}
+
+	// Help flag has the highest priority.
+	if flagDict.helpFlag {
+		fmt.Print(helpMessage)
+		return nil
+	}
+
+	// Exactly one argument is required.
+	if flagSet.NArg() != 1 {
+		return errcode.Errorf(errcode.InvalidArgument, "expected exactly one argument")
+	}
+
+	bundlePath, err := path.Parse(flagSet.Arg(0))
+	if err != nil {
+		return errcode.Errorf(errcode.InvalidArgument, "invalid bundle path %v", flagSet.Arg(0))
+	}
+
+	stagingDir, err := bundle.MakeStagingDir(ctx)
+	if err != nil {
+		return err
+	}
+	defer ctx.FS().RemoveAll(stagingDir.LocalString())
+
+	manifest, err := bundle.Unpack(ctx.FS(), bundlePath, stagingDir)
+	if err != nil {
+		return err
+	}
+
+	if err := uninstallOtherVersions(ctx, manifest); err != nil {
+		return err
+	}
+
+	if len(manifest.Teeth) != 0 {
+		// Install exclusively from the archives in the bundle, like lip install --vendor.
+		ctx.SetVendorDir(stagingDir.Join(path.MustParse(bundle.VendorDirName)))
+
+		installArgs := []string{"--vendor"}
+		if flagDict.yesFlag {
+			installArgs = append(installArgs, "--yes")
+		}
+
+		for _, bundledTooth := range manifest.Teeth {
+			if bundledTooth.Override != "" {
+				installArgs = append(installArgs, "--override",
+					fmt.Sprintf("%v@%v", bundledTooth.ToothRepoPath, bundledTooth.Override))
+			}
+		}
+
+		for _, bundledTooth := range manifest.Teeth {
+			installArgs = append(installArgs, fmt.Sprintf("%v@%v", bundledTooth.ToothRepoPath, bundledTooth.Version))
+		}
+
+		if err := cmdlipinstall.Run(ctx, installArgs); err != nil {
+			return fmt.Errorf("failed to install teeth in bundle\n\t%w", err)
+		}
+	}
+
+	// All teeth were installed as specified, so restore the records in the bundle.
+	for _, bundledTooth := range manifest.Teeth {
+		if err := record.Save(ctx, record.Record{
+			ToothRepoPath: bundledTooth.ToothRepoPath,
+			IsExplicit:    bundledTooth.IsExplicit,
+			Override:      bundledTooth.Override,
+		}); err != nil {
+			return fmt.Errorf("failed to save record of tooth %v\n\t%w", bundledTooth.ToothRepoPath, err)
+		}
+	}
+
+	log.Infof(i18n.T("Imported %v teeth from %v."), len(manifest.Teeth), bundlePath.LocalString())
+
+	return nil
+}
+
+// ---------------------------------------------------------------------
+
+// uninstallOtherVersions uninstalls the teeth installed at versions other than the ones in the
+// bundle, and warns about installed teeth not in the bundle.
+func uninstallOtherVersions(ctx *context.Context, manifest bundle.Manifest) error {
+	bundledVersions := make(map[string]semver.Version)
+	for _, bundledTooth := range manifest.Teeth {
+		version, err := semver.Parse(bundledTooth.Version)
+		if err != nil {
+			return errcode.Errorf(errcode.InvalidArgument, "invalid version %v of %v in bundle",
+				bundledTooth.Version, bundledTooth.ToothRepoPath)
+		}
+
+		bundledVersions[bundledTooth.ToothRepoPath] = version
+	}
+
+	metadataList, err := tooth.GetAllMetadata(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list all installed teeth\n\t%w", err)
+	}
+
+	changes := make([]history.Change, 0)
+	for _, metadata := range metadataList {
+		version, ok := bundledVersions[metadata.ToothRepoPath()]
+		if !ok {
+			log.Warnf(i18n.T("Tooth %v is installed but not in the bundle. It is kept."), metadata.ToothRepoPath())
+			continue
+		}
+
+		if metadata.Version().EQ(version) {
+			continue
+		}
+
+		log.Infof(i18n.T("Replacing %v@%v with %v@%v from the bundle"), metadata.ToothRepoPath(), metadata.Version(),
+			metadata.ToothRepoPath(), version)
+
+		change, err := history.GetUninstallChange(ctx, metadata.ToothRepoPath())
+		if err != nil {
+			return err
+		}
+
+		if err := install.Uninstall(ctx, metadata.ToothRepoPath()); err != nil {
+			return fmt.Errorf("failed to uninstall tooth %v\n\t%w", metadata.ToothRepoPath(), err)
+		}
+
+		changes = append(changes, change)
+	}
+
+	if err := history.Append(ctx, history.UninstallOperation, changes, 0); err != nil {
+		return fmt.Errorf("failed to record history\n\t%w", err)
+	}
+
+	return nil
+}
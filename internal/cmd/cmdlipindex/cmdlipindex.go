@@ -0,0 +1,73 @@
+package cmdlipindex
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/lippkg/lip/internal/cmd/cmdlipindexmirror"
+	"github.com/lippkg/lip/internal/cmd/cmdlipindexserve"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+)
+
+type FlagDict struct {
+	helpFlag bool
+}
+
+const helpMessage = `
+Usage:
+  lip index [options]
+  lip index <command> [subcommand options] ...
+
+Commands:
+  mirror                      Download the registry into a directory.
+  serve                       Serve a mirror over HTTP.
+
+Options:
+  -h, --help                  Show help.
+`
+
+func Run(ctx *context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("index", flag.ContinueOnError)
+
+	// Rewrite the default usage message.
+	flagSet.Usage = func() {
+		// Do nothing.
+	}
+
+	var flagDict FlagDict
+	flagSet.BoolVar(&flagDict.helpFlag, "help", false, "")
+	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
+
+	if err := flagSet.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags\n\t%w", err)
+	}
+
+	// Help flag has the highest priority.
+	if flagDict.helpFlag {
+		fmt.Print(helpMessage)
+		return nil
+	}
+
+	// If there is a subcommand, run it and exit.
+	if flagSet.NArg() >= 1 {
+		switch flagSet.Arg(0) {
+		case "mirror":
+			if err := cmdlipindexmirror.Run(ctx, flagSet.Args()[1:]); err != nil {
+				return err
+			}
+			return nil
+
+		case "serve":
+			if err := cmdlipindexserve.Run(ctx, flagSet.Args()[1:]); err != nil {
+				return err
+			}
+			return nil
+
+		default:
+			return errcode.Errorf(errcode.InvalidArgument, "unknown command: lip index %v", flagSet.Arg(0))
+		}
+	}
+
+	return errcode.Errorf(errcode.InvalidArgument, "no command specified. See 'lip index --help' for more information")
+}
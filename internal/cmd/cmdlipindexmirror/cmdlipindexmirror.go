@@ -0,0 +1,83 @@
+package cmdlipindexmirror
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/mirror"
+	"github.com/lippkg/lip/internal/path"
+	log "github.com/sirupsen/logrus"
+)
+
+type FlagDict struct {
+	helpFlag     bool
+	latestFlag   bool
+	noAssetsFlag bool
+}
+
+const helpMessage = `
+Usage:
+  lip index mirror [options] <directory>
+
+Description:
+  Download the registry index, the registry entries of all teeth, their tooth archives and their
+  asset archives into a directory. The directory can be served as static files, or with
+  lip index serve, as both the registry and the Go module proxy of machines that cannot reach
+  the Internet. Files already in the directory are kept, so running it again only downloads
+  new versions.
+
+Options:
+  -h, --help                  Show help.
+  --latest                    Mirror only the latest version of each tooth that is not yanked.
+  --no-assets                 Do not mirror asset archives.
+`
+
+func Run(ctx *context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("mirror", flag.ContinueOnError)
+
+	// Rewrite the default usage message.
+	flagSet.Usage = func() {
+		// Do nothing.
+	}
+
+	var flagDict FlagDict
+	flagSet.BoolVar(&flagDict.helpFlag, "help", false, "")
+	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
+	flagSet.BoolVar(&flagDict.latestFlag, "latest", false, "")
+	flagSet.BoolVar(&flagDict.noAssetsFlag, "no-assets", false, "")
+
+	if err := flagSet.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags\n\t%w", err)
+	}
+
+	// Help flag has the highest priority.
+	if flagDict.helpFlag {
+		fmt.Print(helpMessage)
+		return nil
+	}
+
+	// Exactly one argument is required.
+	if flagSet.NArg() != 1 {
+		return errcode.Errorf(errcode.InvalidArgument, "expected exactly one argument")
+	}
+
+	dir, err := path.Parse(flagSet.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to parse directory %v\n\t%w", flagSet.Arg(0), err)
+	}
+
+	downloadedCount, err := mirror.Mirror(ctx, dir, mirror.Options{
+		LatestOnly: flagDict.latestFlag,
+		SkipAssets: flagDict.noAssetsFlag,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to mirror the registry\n\t%w", err)
+	}
+
+	log.Infof(i18n.T("Mirrored the registry to %v, %v files downloaded."), dir.LocalString(), downloadedCount)
+
+	return nil
+}
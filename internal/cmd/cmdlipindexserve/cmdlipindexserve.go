@@ -0,0 +1,102 @@
+package cmdlipindexserve
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/mirror"
+	"github.com/lippkg/lip/internal/path"
+	log "github.com/sirupsen/logrus"
+)
+
+type FlagDict struct {
+	helpFlag bool
+	addrFlag string
+}
+
+const helpMessage = `
+Usage:
+  lip index serve [options] <directory>
+
+Description:
+  Serve a directory made by lip index mirror over HTTP until interrupted. To install teeth from
+  it, set RegistryURL and GoModuleProxyURL to the URL it is served at, and GitHubMirrorURL to
+  the same URL followed by /github.
+
+Options:
+  -h, --help                  Show help.
+  --addr <address>            The address to listen on. [default: 127.0.0.1:8080]
+`
+
+func Run(ctx *context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("serve", flag.ContinueOnError)
+
+	// Rewrite the default usage message.
+	flagSet.Usage = func() {
+		// Do nothing.
+	}
+
+	var flagDict FlagDict
+	flagSet.BoolVar(&flagDict.helpFlag, "help", false, "")
+	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
+	flagSet.StringVar(&flagDict.addrFlag, "addr", "127.0.0.1:8080", "")
+
+	if err := flagSet.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags\n\t%w", err)
+	}
+
+	// Help flag has the highest priority.
+	if flagDict.helpFlag {
+		fmt.Print(helpMessage)
+		return nil
+	}
+
+	// Exactly one argument is required.
+	if flagSet.NArg() != 1 {
+		return errcode.Errorf(errcode.InvalidArgument, "expected exactly one argument")
+	}
+
+	dir, err := path.Parse(flagSet.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to parse directory %v\n\t%w", flagSet.Arg(0), err)
+	}
+
+	if _, err := os.Stat(dir.Join(path.MustParse("index.json")).LocalString()); os.IsNotExist(err) {
+		return errcode.Errorf(errcode.InvalidArgument, "%v is not a mirror, run lip index mirror first",
+			dir.LocalString())
+	} else if err != nil {
+		return fmt.Errorf("failed to check if index.json exists\n\t%w", err)
+	}
+
+	listener, err := net.Listen("tcp", flagDict.addrFlag)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %v\n\t%w", flagDict.addrFlag, err)
+	}
+	defer listener.Close()
+
+	baseURL := fmt.Sprintf("http://%v/", listener.Addr())
+
+	log.Infof(i18n.T("Serving %v at %v"), dir.LocalString(), baseURL)
+	log.Infof(i18n.T("Install from it with RegistryURL and GoModuleProxyURL set to %v, and GitHubMirrorURL set to %v%v."),
+		baseURL, baseURL, mirror.GitHubDirName)
+
+	server := &http.Server{Handler: http.FileServer(http.Dir(dir.LocalString()))}
+
+	// Serving stops when interrupted, e.g. by Ctrl-C.
+	go func() {
+		<-ctx.GoContext().Done()
+		server.Close()
+	}()
+
+	if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+		return fmt.Errorf("failed to serve %v\n\t%w", dir.LocalString(), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package cmdlipinstall
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/must"
+	"github.com/lippkg/lip/internal/registry"
+	specifierpkg "github.com/lippkg/lip/internal/specifier"
+	log "github.com/sirupsen/logrus"
+)
+
+// resolveToothAliasSpecifier resolves a tooth alias specifier to the tooth it refers to in the
+// registry. Other specifiers are returned as they are.
+func resolveToothAliasSpecifier(ctx *context.Context, specifier specifierpkg.Specifier,
+	yes bool) (specifierpkg.Specifier, error) {
+	if specifier.Kind() != specifierpkg.ToothAliasKind {
+		return specifier, nil
+	}
+
+	toothRepoPath, err := resolveToothAlias(ctx, must.Must(specifier.ToothAlias()), yes)
+	if err != nil {
+		return specifierpkg.Specifier{}, err
+	}
+
+	return specifier.ToToothRepoResolved(toothRepoPath)
+}
+
+// ---------------------------------------------------------------------
+
+// resolveToothAlias returns the tooth repo path a tooth alias refers to in the registry. If
+// the alias refers to several teeth, the user chooses one of them, unless yes is set, in which
+// case it is an error.
+func resolveToothAlias(ctx *context.Context, alias string, yes bool) (string, error) {
+	if !registry.IsConfigured(ctx) {
+		return "", errcode.Errorf(errcode.InvalidArgument,
+			"cannot resolve %v without a registry. Set registry_url or specify the full tooth repository path", alias)
+	}
+
+	toothRepoPaths, err := registry.FindToothRepoPathsByAlias(ctx, alias)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up alias %v in the registry\n\t%w", alias, err)
+	}
+
+	switch len(toothRepoPaths) {
+	case 0:
+		return "", errcode.Errorf(errcode.InvalidArgument, "no tooth named %v in the registry", alias)
+
+	case 1:
+		log.Infof(i18n.T("Resolved %v to %v"), alias, toothRepoPaths[0])
+		return toothRepoPaths[0], nil
+	}
+
+	if yes {
+		return "", errcode.Errorf(errcode.AmbiguousAlias,
+			"%v refers to multiple teeth: %v. Specify the full tooth repository path",
+			alias, strings.Join(toothRepoPaths, ", "))
+	}
+
+	// Let the user choose.
+	log.Infof(i18n.T("%v refers to multiple teeth:"), alias)
+	for i, toothRepoPath := range toothRepoPaths {
+		log.Infof("  %v) %v", i+1, toothRepoPath)
+	}
+
+	choice, err := ctx.Prompter().Choose(
+		fmt.Sprintf(i18n.T("Which one do you want to install? [1-%v]"), len(toothRepoPaths)), toothRepoPaths)
+	if err != nil {
+		return "", err
+	}
+
+	return toothRepoPaths[choice], nil
+}
@@ -5,15 +5,17 @@ import (
 
 	"github.com/blang/semver/v4"
 	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
 	"github.com/lippkg/lip/internal/install"
-	"github.com/lippkg/lip/internal/network"
-	"github.com/lippkg/lip/internal/path"
+	"github.com/lippkg/lip/internal/record"
+	"github.com/lippkg/lip/internal/snapshot"
 	"github.com/lippkg/lip/internal/tooth"
 	log "github.com/sirupsen/logrus"
 )
 
 func filterInstalledToothArchives(ctx *context.Context, archives []tooth.Archive, upgradeFlag bool,
-	forceReinstallFlag bool) ([]tooth.Archive, error) {
+	forceReinstallFlag bool, overrides map[string]semver.Version) ([]tooth.Archive, error) {
 
 	if forceReinstallFlag {
 		return archives, nil
@@ -28,6 +30,18 @@ func filterInstalledToothArchives(ctx *context.Context, archives []tooth.Archive
 
 		if !isInstalled {
 			filteredArchives = append(filteredArchives, archive)
+		} else if _, ok := overrides[archive.Metadata().ToothRepoPath()]; ok {
+			// Overridden teeth are replaced if another version is installed.
+			currentMetadata, err := tooth.GetMetadata(ctx, archive.Metadata().ToothRepoPath())
+			if err != nil {
+				return nil, fmt.Errorf("failed to find installed tooth metadata\n\t%w", err)
+			}
+
+			if archive.Metadata().Version().NE(currentMetadata.Version()) {
+				filteredArchives = append(filteredArchives, archive)
+			} else {
+				log.Infof(i18n.T("Tooth %v is already installed"), archive.Metadata().ToothRepoPath())
+			}
 		} else if upgradeFlag {
 			currentMetadata, err := tooth.GetMetadata(ctx, archive.Metadata().ToothRepoPath())
 			if err != nil {
@@ -37,10 +51,10 @@ func filterInstalledToothArchives(ctx *context.Context, archives []tooth.Archive
 			if archive.Metadata().Version().GT(currentMetadata.Version()) {
 				filteredArchives = append(filteredArchives, archive)
 			} else {
-				log.Infof("Tooth %v is already up-to-date", archive.Metadata().ToothRepoPath())
+				log.Infof(i18n.T("Tooth %v is already up-to-date"), archive.Metadata().ToothRepoPath())
 			}
 		} else {
-			log.Infof("Tooth %v is already installed", archive.Metadata().ToothRepoPath())
+			log.Infof(i18n.T("Tooth %v is already installed"), archive.Metadata().ToothRepoPath())
 		}
 	}
 
@@ -49,7 +63,7 @@ func filterInstalledToothArchives(ctx *context.Context, archives []tooth.Archive
 
 // installToothArchive installs the tooth archive.
 func installToothArchive(ctx *context.Context, archive tooth.Archive, forceReinstall bool, upgrade bool, yes bool) error {
-	debugLogger := log.WithFields(log.Fields{
+	debugLogger := ctx.Logger().WithFields(log.Fields{
 		"package": "cmdlipinstall",
 		"method":  "installToothArchive",
 	})
@@ -63,7 +77,7 @@ func installToothArchive(ctx *context.Context, archive tooth.Archive, forceReins
 	shouldUninstall := false
 
 	if isInstalled && forceReinstall {
-		log.Infof("Reinstalling tooth %v", archive.Metadata().ToothRepoPath())
+		log.Infof(i18n.T("Reinstalling tooth %v"), archive.Metadata().ToothRepoPath())
 
 		shouldInstall = true
 		shouldUninstall = true
@@ -76,32 +90,42 @@ func installToothArchive(ctx *context.Context, archive tooth.Archive, forceReins
 		}
 
 		if archive.Metadata().Version().GT(currentMetadata.Version()) {
-			log.Infof("Upgrading tooth %v", archive.Metadata().ToothRepoPath())
+			log.Infof(i18n.T("Upgrading tooth %v"), archive.Metadata().ToothRepoPath())
 
 			shouldInstall = true
 			shouldUninstall = true
 		} else {
-			log.Infof("Tooth %v is already up-to-date", archive.Metadata().ToothRepoPath())
+			log.Infof(i18n.T("Tooth %v is already up-to-date"), archive.Metadata().ToothRepoPath())
 
 			shouldInstall = false
 			shouldUninstall = false
 		}
 
 	} else if isInstalled {
-		log.Infof("Tooth %v is already installed", archive.Metadata().ToothRepoPath())
+		log.Infof(i18n.T("Tooth %v is already installed"), archive.Metadata().ToothRepoPath())
 
 		shouldInstall = false
 		shouldUninstall = false
 
 	} else {
-		log.Infof("Installing tooth %v", archive.Metadata().ToothRepoPath())
+		log.Infof(i18n.T("Installing tooth %v"), archive.Metadata().ToothRepoPath())
 
 		shouldInstall = true
 		shouldUninstall = false
 	}
 
+	// Teeth are recorded as dependencies unless they were explicitly installed before.
+	isExplicit := false
+
 	if shouldUninstall {
-		err := install.Uninstall(ctx, archive.Metadata().ToothRepoPath())
+		currentRecord, err := record.Get(ctx, archive.Metadata().ToothRepoPath())
+		if err != nil {
+			return fmt.Errorf("failed to get record of tooth %v\n\t%w", archive.Metadata().ToothRepoPath(), err)
+		}
+
+		isExplicit = currentRecord.IsExplicit
+
+		err = install.Uninstall(ctx, archive.Metadata().ToothRepoPath())
 		if err != nil {
 			return fmt.Errorf("failed to uninstall tooth\n\t%w", err)
 		}
@@ -109,36 +133,12 @@ func installToothArchive(ctx *context.Context, archive tooth.Archive, forceReins
 	}
 
 	if shouldInstall {
-		assetURL, err := archive.Metadata().AssetURL()
+		assetArchiveFilePath, err := getAssetArchiveFilePath(ctx, archive)
 		if err != nil {
-			return fmt.Errorf("failed to get asset URL\n\t%w", err)
-		}
-
-		assetArchiveFilePath := path.MakeEmpty()
-		if assetURL.String() != "" {
-			gitHubMirrorURL, err := ctx.GitHubMirrorURL()
-			if err != nil {
-				return fmt.Errorf("failed to get GitHub mirror URL\n\t%w", err)
-			}
-
-			mirroredURL := assetURL
-			if network.IsGitHubDirectDownloadURL(assetURL) {
-				// Rewrite GitHub URL to GitHub mirror URL if it is set.
-				mirroredURL, err = network.GenerateGitHubMirrorURL(assetURL, gitHubMirrorURL)
-				if err != nil {
-					return fmt.Errorf("failed to generate GitHub mirror URL\n\t%w", err)
-				}
-			}
-
-			cachePath, err := getCachePath(ctx, mirroredURL)
-			if err != nil {
-				return fmt.Errorf("failed to get cache path of asset URL %v\n\t%w", assetURL, err)
-			}
-
-			assetArchiveFilePath = cachePath
+			return err
 		}
 
-		archiveWithAssets, err := archive.ToAssetArchiveAttached(assetArchiveFilePath)
+		archiveWithAssets, err := archive.ToAssetArchiveAttached(ctx.Logger(), assetArchiveFilePath)
 		if err != nil {
 			return fmt.Errorf("failed to attach asset archive %v\n\t%w", assetArchiveFilePath.LocalString(), err)
 		}
@@ -147,6 +147,17 @@ func installToothArchive(ctx *context.Context, archive tooth.Archive, forceReins
 			return fmt.Errorf("failed to install tooth archive %v\n\t%w", archiveWithAssets.FilePath().LocalString(), err)
 		}
 		debugLogger.Debugf("Installed tooth archive %v", archiveWithAssets.FilePath().LocalString())
+
+		if err := record.Save(ctx, record.Record{
+			ToothRepoPath: archive.Metadata().ToothRepoPath(),
+			IsExplicit:    isExplicit,
+		}); err != nil {
+			return fmt.Errorf("failed to save record of tooth %v\n\t%w", archive.Metadata().ToothRepoPath(), err)
+		}
+
+		if err := snapshot.Save(ctx, archiveWithAssets); err != nil {
+			return fmt.Errorf("failed to save snapshot of tooth %v\n\t%w", archive.Metadata().ToothRepoPath(), err)
+		}
 	}
 
 	return nil
@@ -183,7 +194,7 @@ func topoSortVisit(archive tooth.Archive, archiveMap map[string]tooth.Archive,
 	}
 
 	if preVisited[archive.Metadata().ToothRepoPath()] && !visited[archive.Metadata().ToothRepoPath()] {
-		return fmt.Errorf("tooth %s has a circular dependency", archive.Metadata().ToothRepoPath())
+		return errcode.Errorf(errcode.ResolveConflict, "tooth %s has a circular dependency", archive.Metadata().ToothRepoPath())
 	}
 
 	preVisited[archive.Metadata().ToothRepoPath()] = true
@@ -192,12 +203,22 @@ func topoSortVisit(archive tooth.Archive, archiveMap map[string]tooth.Archive,
 		return fmt.Errorf("failed to get dependencies of tooth %s\n\t%w", archive.Metadata().ToothRepoPath(), err)
 	}
 
-	for depToothPath := range dependencies {
+	for depToothPath, versionRange := range dependencies {
 		// Find the tooth archive of the dependency.
 		dep, ok := archiveMap[depToothPath]
 		if !ok {
-			// Ignore the dependency if it is not in the tooth archive list.
-			// sortToothArchives only sorts the tooth archives in the tooth archive list.
+			// The teeth providing the dependency are sorted before instead, if any. Other
+			// dependencies are ignored, since sortToothArchives only sorts the tooth archives
+			// in the tooth archive list.
+			for _, provider := range archiveMap {
+				if !provider.Metadata().ProvidesTooth(depToothPath, versionRange) {
+					continue
+				}
+
+				if err := topoSortVisit(provider, archiveMap, preVisited, visited, sorted); err != nil {
+					return err
+				}
+			}
 			continue
 		}
 
@@ -207,6 +228,26 @@ func topoSortVisit(archive tooth.Archive, archiveMap map[string]tooth.Archive,
 			return err
 		}
 	}
+
+	capabilityDependencies, err := archive.Metadata().CapabilityDependencies()
+	if err != nil {
+		return fmt.Errorf("failed to get capability dependencies of tooth %s\n\t%w", archive.Metadata().ToothRepoPath(), err)
+	}
+
+	// The teeth providing the capabilities depended on are sorted before as well.
+	for capability, versionRange := range capabilityDependencies {
+		for providerToothPath, provider := range archiveMap {
+			if providerToothPath == archive.Metadata().ToothRepoPath() ||
+				!provider.Metadata().ProvidesCapability(capability, versionRange) {
+				continue
+			}
+
+			if err := topoSortVisit(provider, archiveMap, preVisited, visited, sorted); err != nil {
+				return err
+			}
+		}
+	}
+
 	*sorted = append(*sorted, archive)
 	visited[archive.Metadata().ToothRepoPath()] = true
 	return nil
@@ -215,11 +256,11 @@ func topoSortVisit(archive tooth.Archive, archiveMap map[string]tooth.Archive,
 // validateToothArchive validates the archive.
 func validateToothArchive(archive tooth.Archive, toothRepoPath string, version semver.Version) error {
 	if archive.Metadata().ToothRepoPath() != toothRepoPath {
-		return fmt.Errorf("tooth name mismatch: %v != %v", archive.Metadata().ToothRepoPath(), toothRepoPath)
+		return errcode.Errorf(errcode.MetadataInvalid, "tooth name mismatch: %v != %v", archive.Metadata().ToothRepoPath(), toothRepoPath)
 	}
 
 	if archive.Metadata().Version().NE(version) {
-		return fmt.Errorf("tooth version mismatch: %v != %v", archive.Metadata().Version(), version)
+		return errcode.Errorf(errcode.MetadataInvalid, "tooth version mismatch: %v != %v", archive.Metadata().Version(), version)
 	}
 
 	return nil
@@ -0,0 +1,140 @@
+package cmdlipinstall
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/registry"
+	"github.com/lippkg/lip/internal/tooth"
+	log "github.com/sirupsen/logrus"
+)
+
+// capabilityDependency is a dependency of a tooth on a capability, resolved after the teeth
+// reached by tooth repo paths.
+type capabilityDependency struct {
+	dependent          string
+	capability         string
+	versionRangeString string
+	versionRange       semver.Range
+}
+
+// findProvider returns the tooth repo path of a tooth in metadataMap for which provides
+// returns true, e.g. one providing a capability or another tooth. The first one in lexical
+// order is returned if there are several. The second return value is false if there is none.
+func findProvider(metadataMap map[string]tooth.Metadata, provides func(metadata tooth.Metadata) bool) (string, bool) {
+	toothRepoPaths := make([]string, 0, len(metadataMap))
+	for toothRepoPath := range metadataMap {
+		toothRepoPaths = append(toothRepoPaths, toothRepoPath)
+	}
+	sort.Strings(toothRepoPaths)
+
+	for _, toothRepoPath := range toothRepoPaths {
+		if provides(metadataMap[toothRepoPath]) {
+			return toothRepoPath, true
+		}
+	}
+
+	return "", false
+}
+
+// resolveCapabilityProvider finds the tooth providing a capability in the registry and returns
+// the archive of its latest version. It is an error if the registry lists several providers,
+// since lip cannot choose one of them for the user.
+func resolveCapabilityProvider(ctx *context.Context, dep capabilityDependency,
+	fixedToothAndVersionMap map[string]semver.Version) (tooth.Archive, error) {
+	if !registry.IsConfigured(ctx) {
+		return tooth.Archive{}, errcode.Errorf(errcode.ResolveConflict,
+			"no tooth providing capability %v in %v required by %v is installed. Install one, or set registry_url to look it up in the registry",
+			dep.capability, dep.versionRangeString, dep.dependent)
+	}
+
+	toothRepoPaths, err := registry.FindToothRepoPathsByCapability(ctx, dep.capability)
+	if err != nil {
+		return tooth.Archive{}, fmt.Errorf("failed to look up capability %v in the registry\n\t%w", dep.capability, err)
+	}
+
+	if len(toothRepoPaths) == 0 {
+		return tooth.Archive{}, errcode.Errorf(errcode.ResolveConflict,
+			"no tooth in the registry provides capability %v required by %v", dep.capability, dep.dependent)
+	}
+
+	if len(toothRepoPaths) > 1 {
+		return tooth.Archive{}, errcode.Errorf(errcode.ResolveConflict,
+			"multiple teeth provide capability %v required by %v: %v. Install one of them explicitly",
+			dep.capability, dep.dependent, strings.Join(toothRepoPaths, ", "))
+	}
+
+	toothRepoPath := toothRepoPaths[0]
+
+	// A fixed tooth not providing the capability cannot be replaced by another version.
+	if fixedVersion, ok := fixedToothAndVersionMap[toothRepoPath]; ok {
+		return tooth.Archive{}, errcode.Errorf(errcode.ResolveConflict,
+			"fixed tooth %v of version %v does not provide capability %v in %v required by %v",
+			toothRepoPath, fixedVersion, dep.capability, dep.versionRangeString, dep.dependent)
+	}
+
+	version, err := tooth.GetLatestVersion(ctx, toothRepoPath)
+	if err != nil {
+		return tooth.Archive{}, fmt.Errorf("failed to get latest version of %v\n\t%w", toothRepoPath, err)
+	}
+
+	archive, err := downloadToothArchiveIfNotCached(ctx, toothRepoPath, version)
+	if err != nil {
+		return tooth.Archive{}, fmt.Errorf("failed to download tooth\n\t%w", err)
+	}
+
+	if !archive.Metadata().ProvidesCapability(dep.capability, dep.versionRange) {
+		return tooth.Archive{}, errcode.Errorf(errcode.ResolveConflict,
+			"%v@%v does not provide capability %v in %v required by %v",
+			toothRepoPath, version, dep.capability, dep.versionRangeString, dep.dependent)
+	}
+
+	log.Infof(i18n.T("Resolved capability %v to %v@%v"), dep.capability, toothRepoPath, version)
+
+	return archive, nil
+}
+
+// checkDependencyFeatures checks that the teeth depended on have the features their dependents
+// require. Teeth forced to a version by overrides only get a warning.
+func checkDependencyFeatures(archives []tooth.Archive, metadataMap map[string]tooth.Metadata,
+	overrides map[string]semver.Version) error {
+	for _, archive := range archives {
+		for dep, features := range archive.Metadata().DependencyFeatures() {
+			depMetadata, ok := metadataMap[dep]
+			if !ok {
+				// A tooth providing the dependency must have the features instead. Deprecated
+				// teeth replaced by migration are neither installed nor provided.
+				provider, isProvided := findProvider(metadataMap, func(metadata tooth.Metadata) bool {
+					_, ok := metadata.Provides()[dep]
+					return ok
+				})
+				if !isProvided {
+					continue
+				}
+
+				depMetadata = metadataMap[provider]
+			}
+
+			if depMetadata.HasFeatures(features) {
+				continue
+			}
+
+			if _, ok := overrides[dep]; ok {
+				log.Warnf(i18n.T("Overriding %v to version %v, which does not have features %v required by %v"),
+					dep, depMetadata.Version(), strings.Join(features, ", "), archive.Metadata().ToothRepoPath())
+				continue
+			}
+
+			return errcode.Errorf(errcode.ResolveConflict, "%v@%v does not have features %v required by %v",
+				depMetadata.ToothRepoPath(), depMetadata.Version(), strings.Join(features, ", "),
+				archive.Metadata().ToothRepoPath())
+		}
+	}
+
+	return nil
+}
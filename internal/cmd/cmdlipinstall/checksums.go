@@ -0,0 +1,32 @@
+package cmdlipinstall
+
+import (
+	"fmt"
+
+	"github.com/lippkg/lip/internal/checksums"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/path"
+	"github.com/lippkg/lip/internal/sumdb"
+)
+
+// verifyLocalArchiveChecksum verifies a local tooth archive against the SHA256SUMS file next to
+// it, as written by lip tooth pack, and its signature if present. Archives without such a file
+// or without an entry in it are installed unverified.
+func verifyLocalArchiveChecksum(ctx *context.Context, archivePath path.Path) error {
+	checksum, err := sumdb.CalculateChecksum(ctx.FS(), archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to calculate checksum of %v\n\t%w", archivePath.LocalString(), err)
+	}
+
+	ok, err := checksums.Verify(ctx.Logger(), ctx.FS(), archivePath.LocalString(), checksum)
+	if err != nil {
+		return fmt.Errorf("failed to verify checksum of %v\n\t%w", archivePath.LocalString(), err)
+	}
+
+	if ok {
+		ctx.Logger().Infof(i18n.T("Verified %v against %v"), archivePath.LocalString(), checksums.FileName)
+	}
+
+	return nil
+}
@@ -1,33 +1,62 @@
 package cmdlipinstall
 
 import (
+	gocontext "context"
+	"errors"
 	"flag"
 	"fmt"
 
+	"github.com/blang/semver/v4"
 	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/history"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/profiling"
+	"github.com/lippkg/lip/internal/record"
 	"github.com/lippkg/lip/internal/specifier"
+	"github.com/lippkg/lip/internal/stats"
 
 	"github.com/lippkg/lip/internal/tooth"
 	log "github.com/sirupsen/logrus"
 )
 
 type FlagDict struct {
-	helpFlag           bool
-	upgradeFlag        bool
-	forceReinstallFlag bool
-	yesFlag            bool
-	noDependenciesFlag bool
+	helpFlag            bool
+	upgradeFlag         bool
+	forceReinstallFlag  bool
+	yesFlag             bool
+	noDependenciesFlag  bool
+	profileFlag         string
+	allowYankedFlag     bool
+	migrateFlag         bool
+	symlinkFlag         bool
+	hardlinkFlag        bool
+	sideBySideFlag      bool
+	vendorFlag          bool
+	overrideFlag        overrideFlagValue
+	registerServiceFlag bool
+	verifyHealthFlag    bool
+	skipFlag            skipFlagValue
+	failFastFlag        bool
+	keepGoingFlag       bool
+	resumeFlag          bool
+	abortFlag           bool
+	ignoreRuntimesFlag  bool
+	ignoreToolsFlag     bool
 }
 
 const helpMessage = `
 Usage:
   lip install [options] <specifier> [...]
+  lip install [options] --profile <profile>
+  lip install --resume | --abort
 
 Description:
   Install teeth from:
 
   - tooth repositories. (e.g. "github.com/tooth-hub/llbds3@3.1.0")
   - local tooth archives. (e.g. "./foo.tth")
+  - the workspace manifest (workspace.json), with --profile.
 
 Options:
   -h, --help                  Show help.
@@ -35,14 +64,40 @@ Options:
   --force-reinstall           Reinstall the tooth even if they are already up-to-date.
   -y, --yes                   Assume yes to all prompts and run non-interactively.
   --no-dependencies           Do not install dependencies. Also bypass prerequisite checks.
+  --profile <profile>         Install the base teeth and the teeth of the profile in the workspace manifest.
+  --allow-yanked              Allow selecting versions yanked from the registry.
+  --symlink                   Symlink placed files from the store instead of copying them.
+  --hardlink                  Hard-link placed files from the content store shared by all workspaces.
+  --side-by-side              Keep each installed version of the teeth in its own directory and
+                              symlink placed files from the active one. Switch between installed
+                              versions with lip switch.
+  --migrate                   Install replacements of deprecated teeth and uninstall the deprecated teeth.
+  --vendor                    Resolve teeth exclusively from the vendor directory made by lip vendor,
+                              without accessing the network.
+  --override <tooth>@<version>
+                              Force a version of a tooth despite the constraints of its dependents.
+                              Can be repeated. Overrides in the workspace manifest also apply.
+  --register-service          Register the OS services declared by the teeth, as systemd units or
+                              Windows services, after confirmation. They are removed when the
+                              teeth are uninstalled. Usually requires administrator privileges.
+  --verify-health             Run the health checks declared by the teeth after installing them,
+                              and roll back the installation if one does not pass in time.
+  --skip <tag>[,...]          Do not place files tagged with the tags in tooth.json, e.g. docs,
+                              examples or source. Can be repeated.
+  --fail-fast                 Install all teeth at once, and install none if one fails. This is
+                              the default.
+  --keep-going                Install the teeth of each specifier, and of the profile, separately,
+                              continue after failures, and report which succeeded and which failed.
+  --resume                    Resume the installation cut off by a crash or a power loss, after
+                              verifying the teeth it installed.
+  --abort                     Roll back the installation cut off by a crash or a power loss.
+  --ignore-runtimes           Install teeth incompatible with the runtimes in the workspace, e.g.
+                              the installed LeviLamina version, with a warning instead of failing.
+  --ignore-tools              Install teeth whose required tools, e.g. a .NET runtime, are missing
+                              on the host, with a warning instead of failing.
 `
 
 func Run(ctx *context.Context, args []string) error {
-	debugLogger := log.WithFields(log.Fields{
-		"package": "cmdlipinstall",
-		"method":  "Run",
-	})
-
 	flagSet := flag.NewFlagSet("install", flag.ContinueOnError)
 
 	// Rewrite the default usage message.
@@ -58,6 +113,24 @@ func Run(ctx *context.Context, args []string) error {
 	flagSet.BoolVar(&flagDict.yesFlag, "yes", false, "")
 	flagSet.BoolVar(&flagDict.yesFlag, "y", false, "")
 	flagSet.BoolVar(&flagDict.noDependenciesFlag, "no-dependencies", false, "")
+	flagSet.StringVar(&flagDict.profileFlag, "profile", "", "")
+	flagSet.BoolVar(&flagDict.allowYankedFlag, "allow-yanked", false, "")
+	flagSet.BoolVar(&flagDict.symlinkFlag, "symlink", false, "")
+	flagSet.BoolVar(&flagDict.hardlinkFlag, "hardlink", false, "")
+	flagSet.BoolVar(&flagDict.sideBySideFlag, "side-by-side", false, "")
+	flagSet.BoolVar(&flagDict.migrateFlag, "migrate", false, "")
+	flagSet.BoolVar(&flagDict.vendorFlag, "vendor", false, "")
+	flagDict.overrideFlag = make(overrideFlagValue)
+	flagSet.Var(flagDict.overrideFlag, "override", "")
+	flagSet.BoolVar(&flagDict.registerServiceFlag, "register-service", false, "")
+	flagSet.BoolVar(&flagDict.verifyHealthFlag, "verify-health", false, "")
+	flagSet.Var(&flagDict.skipFlag, "skip", "")
+	flagSet.BoolVar(&flagDict.failFastFlag, "fail-fast", false, "")
+	flagSet.BoolVar(&flagDict.keepGoingFlag, "keep-going", false, "")
+	flagSet.BoolVar(&flagDict.resumeFlag, "resume", false, "")
+	flagSet.BoolVar(&flagDict.abortFlag, "abort", false, "")
+	flagSet.BoolVar(&flagDict.ignoreRuntimesFlag, "ignore-runtimes", false, "")
+	flagSet.BoolVar(&flagDict.ignoreToolsFlag, "ignore-tools", false, "")
 
 	if err := flagSet.Parse(args); err != nil {
 		return fmt.Errorf("failed to parse flags\n\t%w", err)
@@ -69,25 +142,134 @@ func Run(ctx *context.Context, args []string) error {
 		return nil
 	}
 
+	// The flags are set on a copy of the context, so that they do not apply to later commands
+	// run with it, e.g. by lip sync and lip undo.
+	ctx = ctx.Copy()
+
+	if flagDict.resumeFlag || flagDict.abortFlag {
+		if flagDict.resumeFlag && flagDict.abortFlag {
+			return errcode.Errorf(errcode.InvalidArgument, "resume and abort flags are mutually exclusive")
+		}
+
+		if flagSet.NArg() != 0 || flagDict.profileFlag != "" {
+			return errcode.Errorf(errcode.InvalidArgument, "resume and abort flags do not take specifiers")
+		}
+
+		return resumeInstallation(ctx, flagDict.abortFlag)
+	}
+
 	// At least one specifier is required.
-	if flagSet.NArg() == 0 {
-		return fmt.Errorf("at least one specifier is required")
+	if flagSet.NArg() == 0 && flagDict.profileFlag == "" {
+		return errcode.Errorf(errcode.InvalidArgument, "at least one specifier is required")
+	}
+
+	if flagDict.failFastFlag && flagDict.keepGoingFlag {
+		return errcode.Errorf(errcode.InvalidArgument, "fail-fast and keep-going flags are mutually exclusive")
+	}
+
+	ctx.SetAllowYanked(flagDict.allowYankedFlag)
+	if flagDict.symlinkFlag && flagDict.hardlinkFlag {
+		return errcode.Errorf(errcode.InvalidArgument, "symlink and hardlink flags are mutually exclusive")
+	}
+
+	if flagDict.sideBySideFlag && (flagDict.symlinkFlag || flagDict.hardlinkFlag) {
+		return errcode.Errorf(errcode.InvalidArgument,
+			"side-by-side flag is mutually exclusive with symlink and hardlink flags")
 	}
 
-	log.Info("Downloading teeth and resolving dependencies...")
+	ctx.SetSymlink(flagDict.symlinkFlag)
+	ctx.SetHardlink(flagDict.hardlinkFlag)
+	ctx.SetSideBySide(flagDict.sideBySideFlag)
+	ctx.SetSkippedTags(flagDict.skipFlag)
+
+	// Vendor mode implies offline mode, so that nothing is fetched from elsewhere.
+	if flagDict.vendorFlag {
+		ctx.SetVendor(true)
+		ctx.SetOffline(true)
+	}
+
+	overrides, err := getOverrides(ctx, flagDict.overrideFlag)
+	if err != nil {
+		return fmt.Errorf("failed to get overrides\n\t%w", err)
+	}
+
+	// An installation cut off by a crash must be resumed or rolled back first, since its teeth
+	// may be partly installed.
+	if hasJournal, err := hasInstallJournal(ctx); err != nil {
+		return err
+	} else if hasJournal {
+		return fmt.Errorf("an installation was cut off. Run lip install --resume to resume it or lip install " +
+			"--abort to roll it back")
+	}
+
+	// The whole installation must finish within the install timeout, after which it is rolled
+	// back like an interrupted one.
+	installTimeout := ctx.InstallTimeout()
+	if installTimeout > 0 {
+		var cancel gocontext.CancelFunc
+		ctx, cancel = ctx.WithTimeout(installTimeout)
+		defer cancel()
+	}
+
+	if flagDict.keepGoingFlag {
+		err = installTeethSeparately(ctx, flagDict, overrides, flagSet.Args())
+	} else {
+		err = installTeeth(ctx, flagDict, overrides, flagSet.Args())
+	}
+
+	if err != nil && errors.Is(ctx.GoContext().Err(), gocontext.DeadlineExceeded) {
+		return fmt.Errorf("installation did not finish within %v\n\t%w", installTimeout, err)
+	}
+
+	return err
+}
+
+// ---------------------------------------------------------------------
+
+// installTeeth installs the teeth of the specifiers and of the profile at once, in a transaction
+// rolled back if one of them fails.
+func installTeeth(ctx *context.Context, flagDict FlagDict, overrides map[string]semver.Version,
+	specifierStrings []string) error {
+	debugLogger := ctx.Logger().WithFields(log.Fields{
+		"package": "cmdlipinstall",
+		"method":  "installTeeth",
+	})
+
+	log.Info(i18n.T("Downloading teeth and resolving dependencies..."))
+
+	resolutionStartTime := ctx.Clock().Now()
+
+	// The time of resolution is timed until the plan is shown, so that confirmation is not
+	// counted.
+	stopResolutionTiming := ctx.Timer().Start(profiling.ResolvePhase)
+	defer stopResolutionTiming()
 
 	// Parse specifiers.
 
 	specifiers := make([]specifier.Specifier, 0)
-	for _, specifierString := range flagSet.Args() {
+	for _, specifierString := range specifierStrings {
 		specifier, err := specifier.Parse(specifierString)
 		if err != nil {
 			return fmt.Errorf("failed to parse specifier\n\t%w", err)
 		}
 
+		specifier, err = resolveToothAliasSpecifier(ctx, specifier, flagDict.yesFlag)
+		if err != nil {
+			return err
+		}
+
 		specifiers = append(specifiers, specifier)
 	}
 
+	if flagDict.profileFlag != "" {
+		workspaceSpecifiers, err := getWorkspaceSpecifiers(ctx, flagDict.profileFlag)
+		if err != nil {
+			return fmt.Errorf("failed to get specifiers from workspace manifest\n\t%w", err)
+		}
+
+		specifiers = append(specifiers, workspaceSpecifiers...)
+	}
+
 	debugLogger.Debug("Got specifiers from arguments:")
 	for _, specifier := range specifiers {
 		debugLogger.Debugf("  %v", specifier)
@@ -105,12 +287,29 @@ func Run(ctx *context.Context, args []string) error {
 		debugLogger.Debugf("  %v@%v: %v", archive.Metadata().ToothRepoPath(), archive.Metadata().Version(), archive.FilePath().LocalString())
 	}
 
+	// Replace deprecated teeth with their replacements if requested.
+
+	replacementMap := make(map[string]string)
+	if flagDict.migrateFlag {
+		for i, archive := range specifiedArchives {
+			replacementArchive, isReplaced, err := getReplacementToothArchive(ctx, archive)
+			if err != nil {
+				return fmt.Errorf("failed to replace deprecated tooth %v\n\t%w", archive.Metadata().ToothRepoPath(), err)
+			}
+
+			if isReplaced {
+				replacementMap[archive.Metadata().ToothRepoPath()] = replacementArchive.Metadata().ToothRepoPath()
+				specifiedArchives[i] = replacementArchive
+			}
+		}
+	}
+
 	// Resolve dependencies and check prerequisites.
 
 	archivesToInstall := specifiedArchives
 	if !flagDict.noDependenciesFlag {
 		archives, err := resolveDependencies(ctx, specifiedArchives, flagDict.upgradeFlag,
-			flagDict.forceReinstallFlag)
+			flagDict.forceReinstallFlag, flagDict.migrateFlag, replacementMap, overrides)
 		if err != nil {
 			return fmt.Errorf("failed to resolve dependencies\n\t%w", err)
 		}
@@ -139,7 +338,7 @@ func Run(ctx *context.Context, args []string) error {
 	// Filter installed teeth.
 
 	filteredArchives, err := filterInstalledToothArchives(ctx, archivesToInstall, flagDict.upgradeFlag,
-		flagDict.forceReinstallFlag)
+		flagDict.forceReinstallFlag, overrides)
 	if err != nil {
 		return fmt.Errorf("failed to filter installed teeth\n\t%w", err)
 	}
@@ -149,7 +348,59 @@ func Run(ctx *context.Context, args []string) error {
 		debugLogger.Debugf("  %v@%v: %v", archive.Metadata().ToothRepoPath(), archive.Metadata().Version(), archive.FilePath().LocalString())
 	}
 
-	// Download tooth assets if necessary.
+	if err := checkPlatforms(filteredArchives); err != nil {
+		return fmt.Errorf("failed to check supported platforms\n\t%w", err)
+	}
+
+	if err := checkPolicy(ctx, specifiedArchives, archivesToInstall, filteredArchives); err != nil {
+		return fmt.Errorf("failed to check installation policy\n\t%w", err)
+	}
+
+	if err := checkRuntimes(ctx, filteredArchives, flagDict.ignoreRuntimesFlag); err != nil {
+		return fmt.Errorf("failed to check runtime compatibility\n\t%w", err)
+	}
+
+	if err := checkTools(ctx, filteredArchives, flagDict.ignoreToolsFlag); err != nil {
+		return fmt.Errorf("failed to check required tools\n\t%w", err)
+	}
+
+	warnDeprecatedToothArchives(filteredArchives)
+	warnHomographToothArchives(filteredArchives)
+
+	if err := checkPermissions(ctx, filteredArchives); err != nil {
+		return fmt.Errorf("failed to check permissions\n\t%w", err)
+	}
+
+	// Show the plan and ask for confirmation before downloading tooth assets, failing early if
+	// there is not enough disk space.
+
+	plan, err := makeInstallPlan(ctx, filteredArchives)
+	if err != nil {
+		return fmt.Errorf("failed to make installation plan\n\t%w", err)
+	}
+
+	if err := checkCacheSpace(ctx, plan); err != nil {
+		return fmt.Errorf("failed to check disk space of the cache\n\t%w", err)
+	}
+
+	resolutionDuration := ctx.Clock().Now().Sub(resolutionStartTime)
+	stopResolutionTiming()
+
+	if len(plan) != 0 {
+		printInstallPlan(plan)
+
+		if !flagDict.yesFlag {
+			if err := askForConfirmation(ctx); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Download tooth assets if necessary. Time spent on confirmation is not counted.
+
+	downloadStartTime := ctx.Clock().Now()
+	stopDownloadTiming := ctx.Timer().Start(profiling.DownloadPhase)
+	defer stopDownloadTiming()
 
 	for _, archive := range filteredArchives {
 		if err := downloadToothAssetArchiveIfNotCached(ctx, archive); err != nil {
@@ -157,10 +408,16 @@ func Run(ctx *context.Context, args []string) error {
 		}
 	}
 
-	// Ask for confirmation.
+	downloadDuration := ctx.Clock().Now().Sub(downloadStartTime)
+	stopDownloadTiming()
 
-	if !flagDict.yesFlag {
-		err := askForConfirmation(ctx, filteredArchives)
+	if err := checkWorkspaceSpace(ctx, filteredArchives); err != nil {
+		return fmt.Errorf("failed to check disk space of the workspace\n\t%w", err)
+	}
+
+	servicesToRegister := make(map[string]tooth.Service)
+	if flagDict.registerServiceFlag {
+		servicesToRegister, err = confirmServicesToRegister(ctx, filteredArchives, flagDict.yesFlag)
 		if err != nil {
 			return err
 		}
@@ -168,36 +425,128 @@ func Run(ctx *context.Context, args []string) error {
 
 	// Install teeth.
 
-	log.Info("Installing teeth...")
+	log.Info(i18n.T("Installing teeth..."))
+
+	installationStartTime := ctx.Clock().Now()
+
+	operation := history.InstallOperation
+	if flagDict.upgradeFlag {
+		operation = history.UpdateOperation
+	}
+
+	// Overridden teeth left after filtering replace the installed version.
+	isForceReinstalled := func(archive tooth.Archive) bool {
+		_, isOverridden := overrides[archive.Metadata().ToothRepoPath()]
+		return flagDict.forceReinstallFlag || isOverridden
+	}
+
+	transaction := newTransaction(ctx, operation)
+	if err := transaction.begin(newInstallJournal(ctx, operation, filteredArchives, isForceReinstalled,
+		flagDict.upgradeFlag, flagDict.yesFlag, specifiedArchives)); err != nil {
+		return err
+	}
 
 	for _, archive := range filteredArchives {
-		if err := installToothArchive(ctx, archive, flagDict.forceReinstallFlag, flagDict.upgradeFlag, flagDict.yesFlag); err != nil {
+		if err := transaction.install(archive, isForceReinstalled(archive), flagDict.upgradeFlag,
+			flagDict.yesFlag); err != nil {
+			if !transaction.rollBack() {
+				log.Warn(i18n.T("Some changes cannot be rolled back. Run lip verify to check the installed teeth."))
+			}
+
 			return fmt.Errorf("failed to install tooth archive %v\n\t%w", archive.FilePath().LocalString(), err)
 		}
 	}
 
-	log.Info("Done.")
+	// Services are registered once all teeth are installed, since they may depend on each other.
+	newlyRegisteredServices, err := registerServices(ctx, servicesToRegister)
+	if err != nil {
+		if !transaction.rollBack() {
+			log.Warn(i18n.T("Some changes cannot be rolled back. Run lip verify to check the installed teeth."))
+		}
+
+		return fmt.Errorf("failed to register OS services\n\t%w", err)
+	}
+
+	// From here on, the transaction is rolled back with the services registered if a step fails,
+	// including recording the installed teeth, so that its install journal is not left behind to
+	// block later installations.
+	rollBack := func() {
+		unregisterServices(ctx, newlyRegisteredServices)
+		if !transaction.rollBack() {
+			log.Warn(i18n.T("Some changes cannot be rolled back. Run lip verify to check the installed teeth."))
+		}
+	}
+
+	if flagDict.verifyHealthFlag {
+		if err := verifyHealth(ctx, filteredArchives); err != nil {
+			rollBack()
+			return err
+		}
+	}
+
+	migrationChanges, err := migrateDeprecatedTeeth(ctx, replacementMap)
+	if err != nil {
+		rollBack()
+		return fmt.Errorf("failed to migrate deprecated teeth\n\t%w", err)
+	}
+
+	if err := recordOverrides(ctx, archivesToInstall, overrides); err != nil {
+		rollBack()
+		return fmt.Errorf("failed to record overrides\n\t%w", err)
+	}
+
+	// Mark specified teeth as explicitly installed, including those already installed as
+	// dependencies.
+
+	specifiedTeeth := make([]string, 0, len(specifiedArchives))
+	for _, archive := range specifiedArchives {
+		specifiedTeeth = append(specifiedTeeth, archive.Metadata().ToothRepoPath())
+	}
+
+	if err := markExplicitlyInstalled(ctx, specifiedTeeth); err != nil {
+		rollBack()
+		return err
+	}
+
+	if err := history.Append(ctx, operation, append(transaction.historyChanges(), migrationChanges...),
+		0); err != nil {
+		rollBack()
+		return fmt.Errorf("failed to record history\n\t%w", err)
+	}
+
+	if err := transaction.commit(); err != nil {
+		return err
+	}
+
+	if len(filteredArchives) != 0 {
+		stats.RecordInstall(ctx, len(filteredArchives), resolutionDuration, downloadDuration,
+			ctx.Clock().Now().Sub(installationStartTime))
+	}
+
+	if err := printEnvironmentInstructions(ctx, filteredArchives); err != nil {
+		return err
+	}
+
+	transaction.printSummary()
+
+	log.Info(i18n.T("Done."))
 
 	return nil
 }
 
-// askForConfirmation asks for confirmation before installing the tooth.
-func askForConfirmation(ctx *context.Context,
-	archiveList []tooth.Archive) error {
+// markExplicitlyInstalled marks installed teeth as explicitly installed.
+func markExplicitlyInstalled(ctx *context.Context, toothRepoPaths []string) error {
+	for _, toothRepoPath := range toothRepoPaths {
+		currentRecord, err := record.Get(ctx, toothRepoPath)
+		if err != nil {
+			return fmt.Errorf("failed to get record of tooth %v\n\t%w", toothRepoPath, err)
+		}
 
-	// Print the list of teeth to be installed.
-	log.Info("The following teeth will be installed:")
-	for _, archive := range archiveList {
-		log.Infof("  %v@%v: %v", archive.Metadata().ToothRepoPath(), archive.Metadata().Version(),
-			archive.Metadata().Info().Name)
-	}
+		currentRecord.IsExplicit = true
 
-	// Ask for confirmation.
-	log.Info("Do you want to continue? [y/N]")
-	var ans string
-	fmt.Scanln(&ans)
-	if ans != "y" && ans != "Y" {
-		return fmt.Errorf("aborted")
+		if err := record.Save(ctx, currentRecord); err != nil {
+			return fmt.Errorf("failed to save record of tooth %v\n\t%w", toothRepoPath, err)
+		}
 	}
 
 	return nil
@@ -0,0 +1,195 @@
+package cmdlipinstall
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/tooth"
+	"github.com/lippkg/lip/internal/vfs"
+)
+
+const testToothRepoPath = "github.com/tooth-hub/example"
+
+const testToothJSON = `{
+    "format_version": 2,
+    "tooth": "github.com/tooth-hub/example",
+    "version": "1.0.0",
+    "info": {
+        "name": "Example",
+        "description": "An example package",
+        "author": "example",
+        "tags": []
+    },
+    "files": {
+        "place": [
+            {
+                "src": "example.txt",
+                "dest": "example.txt"
+            }
+        ]
+    }
+}
+`
+
+func TestInstallRollsBackWhenRecordingHistoryFails(t *testing.T) {
+	ctx, archivePath := newTestWorkspace(t)
+
+	// The history cannot be recorded while its file is a directory.
+	historyFilePath, err := ctx.HistoryFilePath()
+	if err != nil {
+		t.Fatalf("failed to get history file path: %v", err)
+	}
+
+	if err := os.Mkdir(historyFilePath.LocalString(), 0755); err != nil {
+		t.Fatalf("failed to create directory in place of history file: %v", err)
+	}
+
+	if err := Run(ctx, []string{"--yes", archivePath}); err == nil {
+		t.Fatalf("Run succeeded although the history cannot be recorded")
+	}
+
+	if hasJournal, err := hasInstallJournal(ctx); err != nil {
+		t.Fatalf("failed to check install journal: %v", err)
+	} else if hasJournal {
+		t.Errorf("install journal is left behind after the installation failed")
+	}
+
+	if isInstalled, err := tooth.IsInstalled(ctx, testToothRepoPath); err != nil {
+		t.Fatalf("failed to check if tooth is installed: %v", err)
+	} else if isInstalled {
+		t.Errorf("tooth is still installed after the installation was rolled back")
+	}
+
+	// The next installation is not blocked by the failed one.
+	if err := os.Remove(historyFilePath.LocalString()); err != nil {
+		t.Fatalf("failed to remove directory in place of history file: %v", err)
+	}
+
+	if err := Run(ctx, []string{"--yes", archivePath}); err != nil {
+		t.Fatalf("Run failed after a failed installation: %v", err)
+	}
+
+	if isInstalled, err := tooth.IsInstalled(ctx, testToothRepoPath); err != nil {
+		t.Fatalf("failed to check if tooth is installed: %v", err)
+	} else if !isInstalled {
+		t.Errorf("tooth is not installed")
+	}
+}
+
+func TestInstallInMemory(t *testing.T) {
+	ctx, archivePath := newTestWorkspace(t, context.WithFS(vfs.Mem()))
+
+	if err := Run(ctx, []string{"--yes", archivePath}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if content, err := ctx.FS().ReadFile("example.txt"); err != nil {
+		t.Fatalf("failed to read placed file: %v", err)
+	} else if string(content) != "example" {
+		t.Errorf("placed file has content %q, want %q", content, "example")
+	}
+
+	if isInstalled, err := tooth.IsInstalled(ctx, testToothRepoPath); err != nil {
+		t.Fatalf("failed to check if tooth is installed: %v", err)
+	} else if !isInstalled {
+		t.Errorf("tooth is not installed")
+	}
+
+	for _, filePath := range []string{"example.txt", archivePath} {
+		if _, err := os.Lstat(filePath); !os.IsNotExist(err) {
+			t.Errorf("%v is on the disk, want it in memory only", filePath)
+		}
+	}
+}
+
+func TestRunDoesNotLeaveFlagsSet(t *testing.T) {
+	ctx, archivePath := newTestWorkspace(t)
+
+	if err := Run(ctx, []string{"--yes", "--allow-yanked", "--skip", "docs", archivePath}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if ctx.AllowYanked() {
+		t.Errorf("yanked versions are still allowed after Run")
+	}
+
+	if skippedTags := ctx.SkippedTags(); len(skippedTags) != 0 {
+		t.Errorf("tags %v are still skipped after Run", skippedTags)
+	}
+}
+
+// ---------------------------------------------------------------------
+
+// newTestWorkspace makes an empty workspace in a temporary directory, enters it, and writes a
+// tooth archive in it through the file system of the context made with the options. It returns
+// the context of the workspace and the path of the archive.
+func newTestWorkspace(t *testing.T, options ...context.Option) (*context.Context, string) {
+	t.Helper()
+
+	homeDir := t.TempDir()
+	t.Setenv("HOME", homeDir)
+	t.Setenv("USERPROFILE", homeDir)
+
+	workspaceDir := t.TempDir()
+
+	previousDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get working directory: %v", err)
+	}
+
+	if err := os.Chdir(workspaceDir); err != nil {
+		t.Fatalf("failed to enter workspace: %v", err)
+	}
+
+	t.Cleanup(func() {
+		os.Chdir(previousDir)
+	})
+
+	ctx := context.New(context.Config{
+		CredentialStore: "file",
+		Quarantine:      "keep",
+		SnapshotCount:   3,
+		Theme:           "default",
+		ToothSource:     "goproxy",
+		UpdatePolicy:    "all",
+		WorkspaceType:   "auto",
+	}, semver.MustParse("0.21.3"), options...)
+	ctx.SetOffline(true)
+
+	if err := ctx.CreateDirStructure(); err != nil {
+		t.Fatalf("failed to create directory structure: %v", err)
+	}
+
+	archivePath := filepath.Join(workspaceDir, "example.zip")
+
+	archiveFile, err := ctx.FS().Create(archivePath)
+	if err != nil {
+		t.Fatalf("failed to create tooth archive: %v", err)
+	}
+	defer archiveFile.Close()
+
+	zipWriter := zip.NewWriter(archiveFile)
+	for name, content := range map[string]string{
+		"tooth.json":  testToothJSON,
+		"example.txt": "example",
+	} {
+		fileWriter, err := zipWriter.Create(name)
+		if err != nil {
+			t.Fatalf("failed to add %v to tooth archive: %v", name, err)
+		}
+
+		if _, err := fileWriter.Write([]byte(content)); err != nil {
+			t.Fatalf("failed to write %v to tooth archive: %v", name, err)
+		}
+	}
+
+	if err := zipWriter.Close(); err != nil {
+		t.Fatalf("failed to write tooth archive: %v", err)
+	}
+
+	return ctx, archivePath
+}
@@ -6,12 +6,14 @@ import (
 
 	"github.com/blang/semver/v4"
 	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
 	"github.com/lippkg/lip/internal/tooth"
 	log "github.com/sirupsen/logrus"
 )
 
 func getFixedToothAndVersionMap(ctx *context.Context, specifiedArchives []tooth.Archive, upgradeFlag bool,
-	forceReinstallFlag bool) (map[string]semver.Version, error) {
+	forceReinstallFlag bool, overrides map[string]semver.Version) (map[string]semver.Version, error) {
 
 	fixedTeethAndVersions := make(map[string]semver.Version)
 
@@ -24,8 +26,19 @@ func getFixedToothAndVersionMap(ctx *context.Context, specifiedArchives []tooth.
 		fixedTeethAndVersions[installedToothMetadata.ToothRepoPath()] = installedToothMetadata.Version()
 	}
 
+	// Overrides replace installed versions.
+	for toothRepoPath, overrideVersion := range overrides {
+		fixedTeethAndVersions[toothRepoPath] = overrideVersion
+	}
+
 	for _, archive := range specifiedArchives {
-		if fixedVersion, ok := fixedTeethAndVersions[archive.Metadata().ToothRepoPath()]; !ok {
+		if overrideVersion, ok := overrides[archive.Metadata().ToothRepoPath()]; ok {
+			if overrideVersion.NE(archive.Metadata().Version()) {
+				return nil, errcode.Errorf(errcode.ResolveConflict, "tooth %v is overridden to version %v, but version %v is specified",
+					archive.Metadata().ToothRepoPath(), overrideVersion, archive.Metadata().Version())
+			}
+
+		} else if fixedVersion, ok := fixedTeethAndVersions[archive.Metadata().ToothRepoPath()]; !ok {
 			// If not installed, fix it.
 			fixedTeethAndVersions[archive.Metadata().ToothRepoPath()] = archive.Metadata().Version()
 
@@ -39,8 +52,7 @@ func getFixedToothAndVersionMap(ctx *context.Context, specifiedArchives []tooth.
 			fixedTeethAndVersions[archive.Metadata().ToothRepoPath()] = archive.Metadata().Version()
 
 		} else if fixedVersion.NE(archive.Metadata().Version()) {
-			return nil, fmt.Errorf(
-				"trying to fix tooth %v with version %v, but found version %v fixed",
+			return nil, errcode.Errorf(errcode.ResolveConflict, "trying to fix tooth %v with version %v, but found version %v fixed",
 				archive.Metadata().ToothRepoPath(), archive.Metadata().Version(), fixedVersion)
 		}
 	}
@@ -52,27 +64,122 @@ func getFixedToothAndVersionMap(ctx *context.Context, specifiedArchives []tooth.
 // specifier and returns the paths to the downloaded teeth. rootArchiveList
 // contains the root tooth archives to resolve dependencies.
 // The first return value indicates whether the dependencies are resolved.
+// If migrate is true, deprecated dependencies are replaced by their replacements, which are
+// recorded in replacementMap. Teeth in overrides are forced to the given versions even if
+// these do not satisfy the constraints of their dependents.
 func resolveDependencies(ctx *context.Context, rootArchiveList []tooth.Archive,
-	upgradeFlag bool, forceReinstallFlag bool) ([]tooth.Archive, error) {
-	debugLogger := log.WithFields(log.Fields{
+	upgradeFlag bool, forceReinstallFlag bool, migrate bool,
+	replacementMap map[string]string, overrides map[string]semver.Version) ([]tooth.Archive, error) {
+	debugLogger := ctx.Logger().WithFields(log.Fields{
 		"package": "cmdlipinstall",
 		"method":  "resolveDependencies",
 	})
 
 	fixedToothAndVersionMap, err := getFixedToothAndVersionMap(ctx, rootArchiveList, upgradeFlag,
-		forceReinstallFlag)
+		forceReinstallFlag, overrides)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get fixed tooth and version map\n\t%w", err)
 	}
 
+	// fixedMetadataMap is the metadata of the installed teeth and the teeth to install, to
+	// look up their features and capabilities.
+	fixedMetadataMap := make(map[string]tooth.Metadata)
+
+	installedMetadataList, err := tooth.GetAllMetadata(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get all installed tooth metadata\n\t%w", err)
+	}
+
+	for _, installedMetadata := range installedMetadataList {
+		fixedMetadataMap[installedMetadata.ToothRepoPath()] = installedMetadata
+	}
+
 	notResolvedArchiveQueue := list.New()
+	pushArchive := func(archive tooth.Archive) {
+		notResolvedArchiveQueue.PushBack(archive)
+		fixedMetadataMap[archive.Metadata().ToothRepoPath()] = archive.Metadata()
+	}
+
+	for _, rootArchive := range rootArchiveList {
+		pushArchive(rootArchive)
+	}
+
+	// Overridden teeth are queued once, whether they are specified or reached as dependencies.
+	queuedOverrides := make(map[string]bool)
 	for _, rootArchive := range rootArchiveList {
-		notResolvedArchiveQueue.PushBack(rootArchive)
+		if _, ok := overrides[rootArchive.Metadata().ToothRepoPath()]; ok {
+			queuedOverrides[rootArchive.Metadata().ToothRepoPath()] = true
+		}
+	}
+
+	// Installed teeth of other versions than their overrides are replaced, even if they are not
+	// reached from the specified teeth.
+	for toothRepoPath, overrideVersion := range overrides {
+		if queuedOverrides[toothRepoPath] {
+			continue
+		}
+
+		isInstalled, err := tooth.IsInstalled(ctx, toothRepoPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check if tooth is installed\n\t%w", err)
+		}
+
+		if !isInstalled {
+			continue
+		}
+
+		currentMetadata, err := tooth.GetMetadata(ctx, toothRepoPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find installed tooth metadata\n\t%w", err)
+		}
+
+		if currentMetadata.Version().EQ(overrideVersion) {
+			continue
+		}
+
+		overrideArchive, err := downloadToothArchiveIfNotCached(ctx, toothRepoPath, overrideVersion)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download tooth\n\t%w", err)
+		}
+
+		pushArchive(overrideArchive)
+		queuedOverrides[toothRepoPath] = true
 	}
 
 	resolvedArchiveList := make([]tooth.Archive, 0)
 
-	for notResolvedArchiveQueue.Len() > 0 {
+	// Capabilities are resolved once the queue is drained, so that providers among the teeth
+	// reached by tooth repo paths are preferred over the registry.
+	pendingCapabilityDeps := make([]capabilityDependency, 0)
+
+	for notResolvedArchiveQueue.Len() > 0 || len(pendingCapabilityDeps) > 0 {
+		if err := ctx.GoContext().Err(); err != nil {
+			return nil, fmt.Errorf("failed to resolve dependencies\n\t%w", err)
+		}
+
+		if notResolvedArchiveQueue.Len() == 0 {
+			capabilityDep := pendingCapabilityDeps[0]
+			pendingCapabilityDeps = pendingCapabilityDeps[1:]
+
+			if provider, ok := findProvider(fixedMetadataMap, func(metadata tooth.Metadata) bool {
+				return metadata.ProvidesCapability(capabilityDep.capability, capabilityDep.versionRange)
+			}); ok {
+				debugLogger.Debugf("Capability %v of range %v is provided by %v", capabilityDep.capability,
+					capabilityDep.versionRangeString, provider)
+				continue
+			}
+
+			providerArchive, err := resolveCapabilityProvider(ctx, capabilityDep, fixedToothAndVersionMap)
+			if err != nil {
+				return nil, err
+			}
+
+			pushArchive(providerArchive)
+
+			fixedToothAndVersionMap[providerArchive.Metadata().ToothRepoPath()] = providerArchive.Metadata().Version()
+			continue
+		}
+
 		archive := notResolvedArchiveQueue.Front().Value.(tooth.Archive)
 		notResolvedArchiveQueue.Remove(notResolvedArchiveQueue.Front())
 
@@ -84,20 +191,54 @@ func resolveDependencies(ctx *context.Context, rootArchiveList []tooth.Archive,
 		depStrMap := archive.Metadata().DependenciesAsStrings()
 
 		for dep, versionRange := range depMap {
+			if overrideVersion, ok := overrides[dep]; ok {
+				if !versionRange(overrideVersion) {
+					log.Warnf(i18n.T("Overriding %v to version %v, which does not satisfy %v required by %v"),
+						dep, overrideVersion, depStrMap[dep], archive.Metadata().ToothRepoPath())
+				}
+
+				if queuedOverrides[dep] {
+					continue
+				}
+
+				overrideArchive, err := downloadToothArchiveIfNotCached(ctx, dep, overrideVersion)
+				if err != nil {
+					return nil, fmt.Errorf("failed to download tooth\n\t%w", err)
+				}
+
+				debugLogger.Debugf("Dependency %v is overridden to version %v", dep, overrideVersion)
+
+				pushArchive(overrideArchive)
+				queuedOverrides[dep] = true
+				continue
+			}
+
 			if fixedVersion, ok := fixedToothAndVersionMap[dep]; ok {
 				if !versionRange(fixedToothAndVersionMap[dep]) {
-					return nil, fmt.Errorf("fixed tooth %v of version %v does not satisfy the version range %v",
+					return nil, errcode.Errorf(errcode.ResolveConflict, "fixed tooth %v of version %v does not satisfy the version range %v",
 						dep, fixedVersion.String(), depStrMap[dep])
 				}
 
+				warnIfYanked(ctx, dep, fixedVersion)
+
 				// Avoid downloading the same tooth multiple times.
 				debugLogger.Debugf("Dependency %v@%v is already fixed, skip", dep, fixedVersion)
 				continue
 			}
 
-			targetVersion, err := tooth.GetLatestVersionInVersionRange(ctx, dep, versionRange)
-			if err != nil {
-				return nil, fmt.Errorf("no available version in %v found for dependency %v", depStrMap[dep], dep)
+			// A fork or a bundle being installed or already installed may provide the tooth.
+			if provider, ok := findProvider(fixedMetadataMap, func(metadata tooth.Metadata) bool {
+				return metadata.ProvidesTooth(dep, versionRange)
+			}); ok {
+				debugLogger.Debugf("Dependency %v of range %v is provided by %v", dep, depStrMap[dep], provider)
+				continue
+			}
+
+			targetVersion, err := resolveVersion(ctx, dep, depStrMap[dep], versionRange)
+			if code, ok := errcode.GetCode(err); ok && code == errcode.PlatformUnsupported {
+				return nil, err
+			} else if err != nil {
+				return nil, errcode.Errorf(errcode.ResolveConflict, "no available version in %v found for dependency %v", depStrMap[dep], dep)
 			}
 
 			debugLogger.Debugf("Dependency %v of range %v is resolved to version %v", dep, depStrMap[dep], targetVersion)
@@ -109,14 +250,56 @@ func resolveDependencies(ctx *context.Context, rootArchiveList []tooth.Archive,
 
 			debugLogger.Debugf("Downloaded tooth archive %v", currentArchive.FilePath().LocalString())
 
-			notResolvedArchiveQueue.PushBack(currentArchive)
+			if migrate {
+				replacementArchive, isReplaced, err := getReplacementToothArchive(ctx, currentArchive)
+				if err != nil {
+					return nil, fmt.Errorf("failed to replace deprecated tooth %v\n\t%w", dep, err)
+				}
+
+				if isReplaced {
+					replacementToothRepoPath := replacementArchive.Metadata().ToothRepoPath()
+					replacementMap[dep] = replacementToothRepoPath
+					fixedToothAndVersionMap[dep] = targetVersion
+
+					if _, ok := fixedToothAndVersionMap[replacementToothRepoPath]; ok {
+						continue
+					}
+
+					pushArchive(replacementArchive)
+
+					fixedToothAndVersionMap[replacementToothRepoPath] = replacementArchive.Metadata().Version()
+					continue
+				}
+			}
+
+			pushArchive(currentArchive)
 
 			fixedToothAndVersionMap[dep] = targetVersion
 		}
 
+		capabilityDepMap, err := archive.Metadata().CapabilityDependencies()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get capability dependencies of %v\n\t%w", archive.FilePath().LocalString(), err)
+		}
+
+		capabilityDepStrMap := archive.Metadata().CapabilityDependenciesAsStrings()
+
+		for capability, versionRange := range capabilityDepMap {
+			pendingCapabilityDeps = append(pendingCapabilityDeps, capabilityDependency{
+				dependent:          archive.Metadata().ToothRepoPath(),
+				capability:         capability,
+				versionRangeString: capabilityDepStrMap[capability],
+				versionRange:       versionRange,
+			})
+		}
+
 		resolvedArchiveList = append(resolvedArchiveList, archive)
 	}
 
+	if err := checkDependencyFeatures(resolvedArchiveList, fixedMetadataMap, overrides); err != nil {
+		return nil, err
+	}
+
 	sortedArchives, err := topoSortToothArchives(resolvedArchiveList)
 	if err != nil {
 		return nil, fmt.Errorf("failed to sort teeth\n\t%w", err)
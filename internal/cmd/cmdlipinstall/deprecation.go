@@ -0,0 +1,111 @@
+package cmdlipinstall
+
+import (
+	"fmt"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/history"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/install"
+	"github.com/lippkg/lip/internal/record"
+	"github.com/lippkg/lip/internal/service"
+	"github.com/lippkg/lip/internal/snapshot"
+	"github.com/lippkg/lip/internal/tooth"
+	log "github.com/sirupsen/logrus"
+)
+
+// getReplacementToothArchive downloads the latest version of the replacement of a deprecated
+// tooth. The second return value indicates whether the archive is replaced.
+func getReplacementToothArchive(ctx *context.Context, archive tooth.Archive) (tooth.Archive, bool, error) {
+	deprecation, isDeprecated := archive.Metadata().Deprecation()
+	if !isDeprecated || deprecation.Replacement == "" {
+		return archive, false, nil
+	}
+
+	latestVersion, err := tooth.GetLatestVersion(ctx, deprecation.Replacement)
+	if err != nil {
+		return tooth.Archive{}, false, fmt.Errorf("failed to look up version of replacement %v\n\t%w",
+			deprecation.Replacement, err)
+	}
+
+	replacementArchive, err := downloadToothArchiveIfNotCached(ctx, deprecation.Replacement, latestVersion)
+	if err != nil {
+		return tooth.Archive{}, false, fmt.Errorf("failed to download replacement %v@%v\n\t%w",
+			deprecation.Replacement, latestVersion, err)
+	}
+
+	log.Infof(i18n.T("Migrating deprecated tooth %v to %v@%v"), archive.Metadata().ToothRepoPath(),
+		deprecation.Replacement, latestVersion)
+
+	return replacementArchive, true, nil
+}
+
+// migrateDeprecatedTeeth uninstalls the deprecated teeth that have been replaced and marks
+// their replacements as explicitly installed, since nothing declares a dependency on the
+// replacements yet. It returns the changes of the uninstalled teeth for the history.
+func migrateDeprecatedTeeth(ctx *context.Context, replacementMap map[string]string) ([]history.Change, error) {
+	changes := make([]history.Change, 0)
+
+	for deprecatedToothRepoPath, replacementToothRepoPath := range replacementMap {
+		isInstalled, err := tooth.IsInstalled(ctx, deprecatedToothRepoPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check if tooth is installed\n\t%w", err)
+		}
+
+		if isInstalled {
+			change, err := history.GetUninstallChange(ctx, deprecatedToothRepoPath)
+			if err != nil {
+				return nil, err
+			}
+
+			if serviceName, err := service.Unregister(ctx, deprecatedToothRepoPath); err != nil {
+				return nil, fmt.Errorf("failed to unregister OS service of %v\n\t%w", deprecatedToothRepoPath, err)
+			} else if serviceName != "" {
+				log.Infof(i18n.T("Removed OS service %v"), serviceName)
+			}
+
+			if err := install.Uninstall(ctx, deprecatedToothRepoPath); err != nil {
+				return nil, fmt.Errorf("failed to uninstall deprecated tooth %v\n\t%w", deprecatedToothRepoPath, err)
+			}
+
+			changes = append(changes, change)
+
+			if err := snapshot.RemoveAll(ctx, deprecatedToothRepoPath); err != nil {
+				return nil, fmt.Errorf("failed to remove snapshots of tooth %v\n\t%w", deprecatedToothRepoPath, err)
+			}
+
+			if err := install.RemoveVersions(ctx, deprecatedToothRepoPath); err != nil {
+				return nil, fmt.Errorf("failed to remove versions of tooth %v\n\t%w", deprecatedToothRepoPath, err)
+			}
+		}
+
+		if err := record.Save(ctx, record.Record{
+			ToothRepoPath: replacementToothRepoPath,
+			IsExplicit:    true,
+		}); err != nil {
+			return nil, fmt.Errorf("failed to save record of tooth %v\n\t%w", replacementToothRepoPath, err)
+		}
+	}
+
+	return changes, nil
+}
+
+// warnDeprecatedToothArchives warns about deprecated teeth to install.
+func warnDeprecatedToothArchives(archives []tooth.Archive) {
+	for _, archive := range archives {
+		deprecation, isDeprecated := archive.Metadata().Deprecation()
+		if !isDeprecated {
+			continue
+		}
+
+		message := i18n.Sprintf("Tooth %v is deprecated", archive.Metadata().ToothRepoPath())
+		if deprecation.Reason != "" {
+			message += fmt.Sprintf(": %v", deprecation.Reason)
+		}
+		if deprecation.Replacement != "" {
+			message += i18n.Sprintf(". Use %v instead, or run with --migrate", deprecation.Replacement)
+		}
+
+		log.Warn(message)
+	}
+}
@@ -0,0 +1,168 @@
+package cmdlipinstall
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/diskspace"
+	"github.com/lippkg/lip/internal/path"
+	"github.com/lippkg/lip/internal/tooth"
+	"github.com/lippkg/lip/internal/zip"
+	log "github.com/sirupsen/logrus"
+)
+
+// checkCacheSpace checks that the cache directory has enough free space for the asset archives
+// to download in the plan, estimated from the sizes the servers tell. Archives whose sizes are
+// unknown are not counted.
+func checkCacheSpace(ctx *context.Context, plan []installPlanItem) error {
+	debugLogger := ctx.Logger().WithFields(log.Fields{
+		"package": "cmdlipinstall",
+		"method":  "checkCacheSpace",
+	})
+
+	var required int64
+	for _, item := range plan {
+		if item.downloadSize == unknownSize {
+			debugLogger.Debugf("Size of asset archive of %v is unknown, not counted",
+				item.archive.Metadata().ToothRepoPath())
+			continue
+		}
+
+		required += item.downloadSize
+	}
+
+	if required == 0 {
+		return nil
+	}
+
+	cacheDir, err := ctx.CacheDir()
+	if err != nil {
+		return fmt.Errorf("failed to get cache directory\n\t%w", err)
+	}
+
+	debugLogger.Debugf("Asset archives to download take %v", diskspace.FormatSize(required))
+
+	return diskspace.Check(cacheDir.LocalString(), required)
+}
+
+// checkWorkspaceSpace checks that the directory files are extracted to, i.e. the workspace or
+// the content store with hard links, has enough free space for the files to place, estimated
+// from their uncompressed sizes in the archives. The asset archives must be downloaded. Space
+// freed by replacing installed teeth is not counted.
+func checkWorkspaceSpace(ctx *context.Context, archives []tooth.Archive) error {
+	debugLogger := ctx.Logger().WithFields(log.Fields{
+		"package": "cmdlipinstall",
+		"method":  "checkWorkspaceSpace",
+	})
+
+	var required int64
+	for _, archive := range archives {
+		assetArchiveFilePath, err := getAssetArchiveFilePath(ctx, archive)
+		if err != nil {
+			return err
+		}
+
+		archiveWithAssets, err := archive.ToAssetArchiveAttached(ctx.Logger(), assetArchiveFilePath)
+		if err != nil {
+			return fmt.Errorf("failed to attach asset archive %v\n\t%w", assetArchiveFilePath.LocalString(), err)
+		}
+
+		size, err := getPlacedSize(ctx, archiveWithAssets)
+		if err != nil {
+			return err
+		}
+
+		required += size
+	}
+
+	var dir string
+	if ctx.Hardlink() {
+		contentStoreDir, err := ctx.ContentStoreDir()
+		if err != nil {
+			return fmt.Errorf("failed to get content store directory\n\t%w", err)
+		}
+
+		dir = contentStoreDir.LocalString()
+
+	} else {
+		workspaceDir, err := ctx.WorkspaceDir()
+		if err != nil {
+			return fmt.Errorf("failed to get workspace directory\n\t%w", err)
+		}
+
+		dir = workspaceDir.LocalString()
+	}
+
+	debugLogger.Debugf("Files to place take %v", diskspace.FormatSize(required))
+
+	return diskspace.Check(dir, required)
+}
+
+// ---------------------------------------------------------------------
+
+// getDownloadSize asks the server for the size of a file to download with a HEAD request. The
+// second return value is false if the size is unknown.
+func getDownloadSize(ctx *context.Context, downloadURL *url.URL) (int64, bool) {
+	proxyURL, err := ctx.ProxyURL()
+	if err != nil {
+		return 0, false
+	}
+
+	req, err := http.NewRequestWithContext(ctx.GoContext(), http.MethodHead, downloadURL.String(), nil)
+	if err != nil {
+		return 0, false
+	}
+
+	resp, err := ctx.Network().SendRequest(req, proxyURL)
+	if err != nil {
+		return 0, false
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 || resp.ContentLength < 0 {
+		return 0, false
+	}
+
+	return resp.ContentLength, true
+}
+
+// getPlacedSize returns the total uncompressed size of the files of an archive with its asset
+// archive attached that are placed when it is installed.
+func getPlacedSize(ctx *context.Context, archive tooth.Archive) (int64, error) {
+	assetFilePath, err := archive.AssetFilePath()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get asset file path of archive %v\n\t%w", archive.FilePath().LocalString(), err)
+	}
+
+	files, err := archive.Metadata().Files()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get files from metadata\n\t%w", err)
+	}
+
+	placedSrcSet := make(map[string]bool)
+	for _, place := range files.Place {
+		placedSrcSet[place.Src.String()] = true
+	}
+
+	r, err := zip.OpenReader(ctx.FS(), assetFilePath.LocalString())
+	if err != nil {
+		return 0, fmt.Errorf("failed to open zip reader %v\n\t%w", assetFilePath.LocalString(), err)
+	}
+	defer r.Close()
+
+	var size int64
+	for _, f := range r.File {
+		filePath, err := path.ParseRelative(f.Name)
+		if err != nil {
+			continue
+		}
+
+		if placedSrcSet[filePath.String()] {
+			size += int64(f.UncompressedSize64)
+		}
+	}
+
+	return size, nil
+}
@@ -2,20 +2,39 @@ package cmdlipinstall
 
 import (
 	"fmt"
+	"net/http"
 	"net/url"
 	"os"
+	"time"
 
 	"github.com/blang/semver/v4"
 	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/githubrelease"
+	"github.com/lippkg/lip/internal/i18n"
 	"github.com/lippkg/lip/internal/network"
+	"github.com/lippkg/lip/internal/oci"
 	"github.com/lippkg/lip/internal/path"
+	"github.com/lippkg/lip/internal/profiling"
+	"github.com/lippkg/lip/internal/stats"
+	"github.com/lippkg/lip/internal/sumdb"
+	"github.com/lippkg/lip/internal/tarzst"
 	"github.com/lippkg/lip/internal/tooth"
+	"github.com/lippkg/lip/internal/vendoring"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/mod/module"
 )
 
-func downloadFileIfNotCached(ctx *context.Context, downloadURL *url.URL) (path.Path, error) {
-	debugLogger := log.WithFields(log.Fields{
+// downloadFileIfNotCached downloads a file of a tooth version to the cache if it is not cached,
+// verifies it against the checksum database, and returns the cache path. A download is hashed
+// while it streams to a partial file, which is moved into the cache only after its checksum is
+// verified, so that an interrupted or tampered download is never cached. If the source tells
+// the hex-encoded SHA-256 checksum of the file, e.g. the digest of an OCI layer or the checksum
+// an asset archive is pinned to in the metadata, the download or the cached file must match it as
+// well. Otherwise, the checksum is empty.
+func downloadFileIfNotCached(ctx *context.Context, downloadURL *url.URL, header http.Header, checksum string,
+	toothRepoPath string, toothVersion semver.Version, kind sumdb.Kind) (path.Path, error) {
+	debugLogger := ctx.Logger().WithFields(log.Fields{
 		"package": "cmdlipinstall",
 		"method":  "downloadFileIfNotCached",
 	})
@@ -26,62 +45,160 @@ func downloadFileIfNotCached(ctx *context.Context, downloadURL *url.URL) (path.P
 	}
 
 	// Skip downloading if the file is already in the cache.
-	if _, err := os.Stat(cachePath.LocalString()); os.IsNotExist(err) {
-		log.Infof("Downloading %v", downloadURL)
-
-		var enableProgressBar bool
-		if log.GetLevel() == log.PanicLevel || log.GetLevel() == log.FatalLevel ||
-			log.GetLevel() == log.ErrorLevel || log.GetLevel() == log.WarnLevel {
-			enableProgressBar = false
-		} else {
-			enableProgressBar = true
+	if fileInfo, err := ctx.FS().Stat(cachePath.LocalString()); os.IsNotExist(err) {
+		if ctx.Offline() {
+			return path.Path{}, errcode.Errorf(errcode.Offline,
+				"%v is not cached and cannot be downloaded in offline mode", downloadURL)
 		}
 
-		proxyURL, err := ctx.ProxyURL()
+		stats.RecordCacheMiss(ctx)
+
+		cacheDir, err := ctx.CacheDir()
 		if err != nil {
-			return path.Path{}, fmt.Errorf("failed to get proxy URL\n\t%w", err)
+			return path.Path{}, fmt.Errorf("failed to get cache directory\n\t%w", err)
+		}
+
+		partPath := cacheDir.Join(path.MustParse(cachePath.Base() + ".part"))
+
+		// A file may be fetched from a lip instance on the local network instead.
+		downloadedChecksum, isFetched := fetchFromLANCache(ctx, checksum, toothRepoPath, toothVersion, kind,
+			partPath)
+		if !isFetched {
+			downloadedChecksum, err = downloadToPartialFile(ctx, downloadURL, header, partPath)
+			if err != nil {
+				return path.Path{}, err
+			}
 		}
 
-		if err := network.DownloadFile(downloadURL, proxyURL, cachePath, enableProgressBar); err != nil {
-			return path.Path{}, fmt.Errorf("failed to download file\n\t%w", err)
+		if checksum != "" && downloadedChecksum != checksum {
+			ctx.FS().Remove(partPath.LocalString())
+			return path.Path{}, errcode.Errorf(errcode.ChecksumMismatch,
+				"checksum mismatch of %v: expected %v, got %v", downloadURL, checksum, downloadedChecksum)
+		}
+
+		if err := sumdb.VerifyChecksum(ctx, toothRepoPath, toothVersion, kind, downloadedChecksum); err != nil {
+			ctx.FS().Remove(partPath.LocalString())
+			return path.Path{}, fmt.Errorf("failed to verify %v archive of %v@%v\n\t%w", kind, toothRepoPath,
+				toothVersion, err)
+		}
+
+		if err := ctx.FS().Rename(partPath.LocalString(), cachePath.LocalString()); err != nil {
+			return path.Path{}, fmt.Errorf("failed to move downloaded file to the cache\n\t%w", err)
 		}
 
 	} else if err != nil {
 		return path.Path{}, fmt.Errorf("failed to check if file exists\n\t%w", err)
 	} else {
 		debugLogger.Debugf("File %v already exists in the cache, skip downloading", cachePath.LocalString())
+
+		stats.RecordCacheHit(ctx, fileInfo.Size())
+
+		// A cached file is verified as well, since the cache may be shared or modified.
+		cachedChecksum, err := sumdb.CalculateChecksum(ctx.FS(), cachePath)
+		if err != nil {
+			return path.Path{}, fmt.Errorf("failed to calculate checksum of %v\n\t%w", cachePath.LocalString(), err)
+		}
+
+		if checksum != "" && cachedChecksum != checksum {
+			return path.Path{}, errcode.Errorf(errcode.ChecksumMismatch,
+				"checksum mismatch of cached %v: expected %v, got %v", downloadURL, checksum, cachedChecksum)
+		}
+
+		if err := sumdb.VerifyChecksum(ctx, toothRepoPath, toothVersion, kind, cachedChecksum); err != nil {
+			return path.Path{}, fmt.Errorf("failed to verify %v archive of %v@%v\n\t%w", kind, toothRepoPath,
+				toothVersion, err)
+		}
 	}
 
 	return cachePath, nil
 }
 
-// downloadToothArchiveIfNotCached downloads the tooth archive from the Go module proxy
-// if it is not cached, and returns the path to the downloaded tooth archive.
+// downloadToPartialFile downloads a file from the fastest source hosting it to a partial file in
+// the cache, records the download in the usage statistics, and returns the checksum of the file.
+func downloadToPartialFile(ctx *context.Context, downloadURL *url.URL, header http.Header,
+	partPath path.Path) (string, error) {
+	log.Infof(i18n.T("Downloading %v"), downloadURL)
+
+	var enableProgressBar bool
+	if log.GetLevel() == log.PanicLevel || log.GetLevel() == log.FatalLevel ||
+		log.GetLevel() == log.ErrorLevel || log.GetLevel() == log.WarnLevel {
+		enableProgressBar = false
+	} else {
+		enableProgressBar = true
+	}
+
+	proxyURL, err := ctx.ProxyURL()
+	if err != nil {
+		return "", fmt.Errorf("failed to get proxy URL\n\t%w", err)
+	}
+
+	startTime := ctx.Clock().Now()
+	stopTiming := ctx.Timer().Start(profiling.DownloadPhase)
+	downloadedChecksum, sourceURL, err := downloadFromFastestSource(ctx, downloadURL, proxyURL, header, partPath,
+		enableProgressBar)
+	stopTiming()
+	if err != nil {
+		ctx.FS().Remove(partPath.LocalString())
+		stats.RecordDownload(ctx, downloadURL, 0, 0, false)
+		return "", fmt.Errorf("failed to download file\n\t%w", err)
+	}
+
+	recordDownload(ctx, sourceURL, partPath, ctx.Clock().Now().Sub(startTime))
+
+	return downloadedChecksum, nil
+}
+
+// downloadToothArchiveIfNotCached downloads the tooth archive from the Go module proxy, or
+// from GitHub Releases or an OCI registry if it is the tooth source, if it is not cached, and
+// returns the path to the downloaded tooth archive. An archive in an OCI registry is patched
+// from the installed version instead if patches are published. In vendor mode, the vendored
+// tooth archive is used instead.
 func downloadToothArchiveIfNotCached(ctx *context.Context, toothRepoPath string,
 	toothVersion semver.Version) (tooth.Archive, error) {
-	debugLogger := log.WithFields(log.Fields{
+	debugLogger := ctx.Logger().WithFields(log.Fields{
 		"package": "cmdlipinstall",
 		"method":  "downloadToothArchiveIfNotCached",
 	})
 
-	goModuleProxyURL, err := ctx.GoModuleProxyURL()
-	if err != nil {
-		return tooth.Archive{}, fmt.Errorf("failed to get Go module proxy URL\n\t%w", err)
-	}
+	var cachePath path.Path
+	if ctx.Vendor() {
+		vendoredPath, err := vendoring.GetArchivePath(ctx, toothRepoPath, toothVersion)
+		if err != nil {
+			return tooth.Archive{}, err
+		}
 
-	downloadURL, err := network.GenerateGoModuleZipFileURL(toothRepoPath, toothVersion, goModuleProxyURL)
-	if err != nil {
-		return tooth.Archive{}, fmt.Errorf("failed to generate Go module zip file URL\n\t%w", err)
+		cachePath = vendoredPath
+
+	} else {
+		downloadURL, header, checksum, kind, err := getToothArchiveURL(ctx, toothRepoPath, toothVersion)
+		if err != nil {
+			return tooth.Archive{}, err
+		}
+
+		if err := patchToothArchiveIfNotCached(ctx, downloadURL, checksum, toothRepoPath, toothVersion,
+			kind); err != nil {
+			return tooth.Archive{}, err
+		}
+
+		downloadedPath, err := downloadFileIfNotCached(ctx, downloadURL, header, checksum, toothRepoPath,
+			toothVersion, kind)
+		if err != nil {
+			return tooth.Archive{}, fmt.Errorf("failed to download file\n\t%w", err)
+		}
+
+		debugLogger.Debugf("Downloaded tooth archive from %v to %v", downloadURL, downloadedPath.LocalString())
+
+		cachePath = downloadedPath
 	}
 
-	cachePath, err := downloadFileIfNotCached(ctx, downloadURL)
+	// Teeth may be published as .tar.zst archives, which are installed from zip archives
+	// converted from them.
+	zipPath, err := tarzst.ToZipIfTarZst(ctx, cachePath)
 	if err != nil {
-		return tooth.Archive{}, fmt.Errorf("failed to download file\n\t%w", err)
+		return tooth.Archive{}, err
 	}
 
-	debugLogger.Debugf("Downloaded tooth archive from %v to %v", downloadURL, cachePath.LocalString())
-
-	archive, err := tooth.MakeArchive(cachePath)
+	archive, err := tooth.MakeArchiveOfTooth(ctx.FS(), zipPath, toothRepoPath)
 	if err != nil {
 		return tooth.Archive{}, fmt.Errorf("failed to open archive %v\n\t%w", cachePath.LocalString(), err)
 	}
@@ -95,69 +212,242 @@ func downloadToothArchiveIfNotCached(ctx *context.Context, toothRepoPath string,
 	return archive, nil
 }
 
+// DownloadToothArchive downloads the tooth archive and the asset archive of a tooth version if
+// they are not cached, e.g. for lip vendor, and returns the archive with the asset archive
+// attached.
+func DownloadToothArchive(ctx *context.Context, toothRepoPath string, toothVersion semver.Version) (tooth.Archive,
+	error) {
+	archive, err := downloadToothArchiveIfNotCached(ctx, toothRepoPath, toothVersion)
+	if err != nil {
+		return tooth.Archive{}, err
+	}
+
+	if err := downloadToothAssetArchiveIfNotCached(ctx, archive); err != nil {
+		return tooth.Archive{}, fmt.Errorf("failed to download asset archive of %v@%v\n\t%w", toothRepoPath,
+			toothVersion, err)
+	}
+
+	assetArchiveFilePath, err := getAssetArchiveFilePath(ctx, archive)
+	if err != nil {
+		return tooth.Archive{}, err
+	}
+
+	archiveWithAssets, err := archive.ToAssetArchiveAttached(ctx.Logger(), assetArchiveFilePath)
+	if err != nil {
+		return tooth.Archive{}, fmt.Errorf("failed to attach asset archive %v\n\t%w", assetArchiveFilePath.LocalString(),
+			err)
+	}
+
+	return archiveWithAssets, nil
+}
+
+// DownloadToothArchiveWithoutAssets downloads the tooth archive of a tooth version if it is not
+// cached, but not its asset archive, e.g. for lip new, which needs the files of the tooth only.
+func DownloadToothArchiveWithoutAssets(ctx *context.Context, toothRepoPath string,
+	toothVersion semver.Version) (tooth.Archive, error) {
+	return downloadToothArchiveIfNotCached(ctx, toothRepoPath, toothVersion)
+}
+
 func downloadToothAssetArchiveIfNotCached(ctx *context.Context, archive tooth.Archive) error {
 	metadata := archive.Metadata()
-	assetURL, err := metadata.AssetURL()
+
+	// Vendored asset archives are verified when they are attached.
+	if ctx.Vendor() {
+		return nil
+	}
+
+	downloadURL, err := getAssetArchiveURL(ctx, archive)
 	if err != nil {
-		return fmt.Errorf("failed to get asset URL\n\t%w", err)
+		return err
 	}
 
-	if assetURL.String() == "" {
+	if downloadURL == nil {
 		return nil
 	}
 
-	// Rewrite GitHub URL to GitHub mirror URL if it is set.
+	// The asset archive is checked against the checksum pinned in the metadata, if any.
+	if _, err := downloadFileIfNotCached(ctx, downloadURL, nil, metadata.AssetSHA256(), metadata.ToothRepoPath(),
+		metadata.Version(), sumdb.AssetArchiveKind); err != nil {
+		return fmt.Errorf("failed to download file\n\t%w", err)
+	}
+
+	return nil
+}
 
-	gitHubMirrorURL, err := ctx.GitHubMirrorURL()
+// getAssetArchiveFilePath returns the path of the downloaded asset archive of a tooth archive,
+// or the path of the vendored one in vendor mode, converted to a zip archive if it is a .tar.zst
+// archive. It is empty if the tooth has no asset archive.
+func getAssetArchiveFilePath(ctx *context.Context, archive tooth.Archive) (path.Path, error) {
+	if ctx.Vendor() {
+		assetURL, err := archive.Metadata().AssetURL()
+		if err != nil {
+			return path.Path{}, fmt.Errorf("failed to get asset URL\n\t%w", err)
+		}
+
+		if assetURL.String() == "" {
+			return path.MakeEmpty(), nil
+		}
+
+		vendoredPath, err := vendoring.GetAssetPath(ctx, archive.Metadata().ToothRepoPath(), assetURL.String())
+		if err != nil {
+			return path.Path{}, err
+		}
+
+		return tarzst.ToZipIfTarZst(ctx, vendoredPath)
+	}
+
+	downloadURL, err := getAssetArchiveURL(ctx, archive)
 	if err != nil {
-		return fmt.Errorf("failed to get GitHub mirror URL\n\t%w", err)
+		return path.Path{}, err
+	}
+
+	if downloadURL == nil {
+		return path.MakeEmpty(), nil
+	}
+
+	cachePath, err := getCachePath(ctx, downloadURL)
+	if err != nil {
+		return path.Path{}, fmt.Errorf("failed to get cache path of asset URL %v\n\t%w", downloadURL, err)
+	}
+
+	return tarzst.ToZipIfTarZst(ctx, cachePath)
+}
+
+// GetCachedAssetArchivePath returns the path the asset archive of a tooth archive is cached at,
+// e.g. for lip prune. The second return value is false if the tooth has no asset archive.
+func GetCachedAssetArchivePath(ctx *context.Context, archive tooth.Archive) (path.Path, bool, error) {
+	downloadURL, err := getAssetArchiveURL(ctx, archive)
+	if err != nil {
+		return path.Path{}, false, err
+	}
+
+	if downloadURL == nil {
+		return path.Path{}, false, nil
+	}
+
+	cachePath, err := getCachePath(ctx, downloadURL)
+	if err != nil {
+		return path.Path{}, false, fmt.Errorf("failed to get cache path of %v\n\t%w", downloadURL, err)
+	}
+
+	return cachePath, true, nil
+}
+
+// getAssetArchiveURL returns the URL to download the asset archive of a tooth archive from. GitHub
+// URLs are rewritten to the GitHub mirror, and Go module paths to the Go module proxy. It is nil
+// if the tooth has no asset archive.
+func getAssetArchiveURL(ctx *context.Context, archive tooth.Archive) (*url.URL, error) {
+	metadata := archive.Metadata()
+	assetURL, err := metadata.AssetURL()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get asset URL\n\t%w", err)
+	}
+
+	if assetURL.String() == "" {
+		return nil, nil
 	}
 
 	if network.IsGitHubDirectDownloadURL(assetURL) {
 		// HTTP or HTTPS URL from GitHub.
 
-		mirroredURL, err := network.GenerateGitHubMirrorURL(assetURL, gitHubMirrorURL)
+		gitHubMirrorURL, err := ctx.GitHubMirrorURL()
 		if err != nil {
-			return fmt.Errorf("failed to generate GitHub mirror URL\n\t%w", err)
+			return nil, fmt.Errorf("failed to get GitHub mirror URL\n\t%w", err)
 		}
 
-		if _, err := downloadFileIfNotCached(ctx, mirroredURL); err != nil {
-			return fmt.Errorf("failed to download file\n\t%w", err)
+		mirroredURL, err := network.GenerateGitHubMirrorURL(assetURL, gitHubMirrorURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate GitHub mirror URL\n\t%w", err)
 		}
 
+		return mirroredURL, nil
+
 	} else if assetURL.Scheme == "http" || assetURL.Scheme == "https" {
 		// Other HTTP or HTTPS URL.
 
-		if _, err := downloadFileIfNotCached(ctx, assetURL); err != nil {
-			return fmt.Errorf("failed to download file\n\t%w", err)
-		}
+		return assetURL, nil
 
 	} else if err := module.CheckPath(assetURL.String()); err == nil {
 		// Go module path.
 
 		goModuleProxyURL, err := ctx.GoModuleProxyURL()
 		if err != nil {
-			return fmt.Errorf("failed to get Go module proxy URL\n\t%w", err)
+			return nil, fmt.Errorf("failed to get Go module proxy URL\n\t%w", err)
 		}
 
-		downloadURL, err := network.GenerateGoModuleZipFileURL(assetURL.String(), archive.Metadata().Version(), goModuleProxyURL)
+		downloadURL, err := network.GenerateGoModuleZipFileURL(assetURL.String(), metadata.Version(), goModuleProxyURL)
 		if err != nil {
-			return fmt.Errorf("failed to generate Go module zip file URL\n\t%w", err)
+			return nil, fmt.Errorf("failed to generate Go module zip file URL\n\t%w", err)
 		}
 
-		if _, err := downloadFileIfNotCached(ctx, downloadURL); err != nil {
-			return fmt.Errorf("failed to download file\n\t%w", err)
+		return downloadURL, nil
+
+	}
+
+	return nil, i18n.Errorf("unsupported asset URL: %v", assetURL)
+}
+
+// getToothArchiveURL returns the URL to download the tooth archive of a version of a tooth
+// from, the headers to send, the checksum the archive must have if the source tells it, and
+// the kind of the archive in the checksum database.
+func getToothArchiveURL(ctx *context.Context, toothRepoPath string, toothVersion semver.Version) (*url.URL,
+	http.Header, string, sumdb.Kind, error) {
+	isGitHubReleasesUsed, err := githubrelease.IsUsed(ctx, toothRepoPath)
+	if err != nil {
+		return nil, nil, "", "", err
+	}
+
+	if isGitHubReleasesUsed {
+		downloadURL, header, err := githubrelease.GetArchiveURL(ctx, toothRepoPath, toothVersion)
+		if err != nil {
+			return nil, nil, "", "", fmt.Errorf("failed to get GitHub release archive URL\n\t%w", err)
 		}
 
-	} else {
-		return fmt.Errorf("unsupported asset URL: %v", assetURL)
+		return downloadURL, header, "", sumdb.GitHubReleaseArchiveKind, nil
 	}
 
-	return nil
+	isOCIUsed, err := oci.IsUsed(ctx, toothRepoPath)
+	if err != nil {
+		return nil, nil, "", "", err
+	}
+
+	if isOCIUsed {
+		downloadURL, header, checksum, err := oci.GetArchiveURL(ctx, toothRepoPath, toothVersion)
+		if err != nil {
+			return nil, nil, "", "", fmt.Errorf("failed to get OCI archive URL\n\t%w", err)
+		}
+
+		return downloadURL, header, checksum, sumdb.OCIArchiveKind, nil
+	}
+
+	goModuleProxyURL, err := ctx.GoModuleProxyURL()
+	if err != nil {
+		return nil, nil, "", "", fmt.Errorf("failed to get Go module proxy URL\n\t%w", err)
+	}
+
+	// A tooth in a subdirectory is downloaded with the Go module of its repository.
+	goModulePath, _ := network.SplitSubtooth(toothRepoPath)
+
+	downloadURL, err := network.GenerateGoModuleZipFileURL(goModulePath, toothVersion, goModuleProxyURL)
+	if err != nil {
+		return nil, nil, "", "", fmt.Errorf("failed to generate Go module zip file URL\n\t%w", err)
+	}
+
+	return downloadURL, nil, "", sumdb.ToothArchiveKind, nil
+}
+
+// recordDownload records a successful download to a file in the usage statistics.
+func recordDownload(ctx *context.Context, downloadURL *url.URL, filePath path.Path, duration time.Duration) {
+	var size int64
+	if fileInfo, err := ctx.FS().Stat(filePath.LocalString()); err == nil {
+		size = fileInfo.Size()
+	}
+
+	stats.RecordDownload(ctx, downloadURL, size, duration, true)
 }
 
 func getCachePath(ctx *context.Context, u *url.URL) (path.Path, error) {
-	debugLogger := log.WithFields(log.Fields{
+	debugLogger := ctx.Logger().WithFields(log.Fields{
 		"package": "cmdlipinstall",
 		"method":  "getCachePath",
 	})
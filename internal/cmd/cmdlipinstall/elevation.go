@@ -0,0 +1,10 @@
+//go:build !windows
+
+package cmdlipinstall
+
+import "os"
+
+// isElevated returns whether lip runs as root.
+func isElevated() bool {
+	return os.Geteuid() == 0
+}
@@ -0,0 +1,8 @@
+package cmdlipinstall
+
+import "golang.org/x/sys/windows"
+
+// isElevated returns whether lip runs with administrator privileges granted by UAC.
+func isElevated() bool {
+	return windows.GetCurrentProcessToken().IsElevated()
+}
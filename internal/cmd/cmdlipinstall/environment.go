@@ -0,0 +1,49 @@
+package cmdlipinstall
+
+import (
+	"fmt"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/environment"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/tooth"
+	log "github.com/sirupsen/logrus"
+)
+
+// printEnvironmentInstructions tells how to set up the environment if any of the installed
+// teeth requires one.
+func printEnvironmentInstructions(ctx *context.Context, archives []tooth.Archive) error {
+	requiresEnvironment := false
+	for _, archive := range archives {
+		toothEnv, err := archive.Metadata().Environment()
+		if err != nil {
+			return fmt.Errorf("failed to get environment of %v\n\t%w", archive.Metadata().ToothRepoPath(), err)
+		}
+
+		if len(toothEnv.Path) != 0 || len(toothEnv.Variables) != 0 {
+			requiresEnvironment = true
+			break
+		}
+	}
+
+	if !requiresEnvironment {
+		return nil
+	}
+
+	// Show the whole environment of the workspace, since the one of other teeth might not be
+	// set up either.
+	env, err := environment.Get(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get environment\n\t%w", err)
+	}
+
+	snippet, err := environment.Format(env, environment.DefaultShell())
+	if err != nil {
+		return fmt.Errorf("failed to format environment\n\t%w", err)
+	}
+
+	log.Info(i18n.T("The installed teeth require the following environment. Run lip env --apply to set it up for new shells, or run this in the current shell:"))
+	fmt.Print(snippet)
+
+	return nil
+}
@@ -0,0 +1,37 @@
+package cmdlipinstall
+
+import (
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/install"
+	"github.com/lippkg/lip/internal/tooth"
+	log "github.com/sirupsen/logrus"
+)
+
+// verifyHealth runs the health checks declared by the installed tooth archives, in the order
+// they were installed. It fails at the first check not passing within its timeout.
+func verifyHealth(ctx *context.Context, archives []tooth.Archive) error {
+	commandEnvirons, err := install.GetCommandEnvirons(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, archive := range archives {
+		healthCheck, ok := archive.Metadata().HealthCheck()
+		if !ok {
+			continue
+		}
+
+		log.Infof(i18n.T("Checking health of %v..."), archive.Metadata().ToothRepoPath())
+
+		if err := install.CheckHealth(ctx, healthCheck, commandEnvirons); err != nil {
+			return errcode.Errorf(errcode.HealthCheckFailed, "health check of %v failed\n\t%w",
+				archive.Metadata().ToothRepoPath(), err)
+		}
+
+		log.Infof(i18n.T("Health check of %v passed"), archive.Metadata().ToothRepoPath())
+	}
+
+	return nil
+}
@@ -0,0 +1,29 @@
+package cmdlipinstall
+
+import (
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/network"
+	"github.com/lippkg/lip/internal/tooth"
+	log "github.com/sirupsen/logrus"
+)
+
+// warnHomographToothArchives warns about teeth whose hosts are internationalized domain names
+// that may be mistaken for other hosts, e.g. to impersonate a well-known tooth.
+func warnHomographToothArchives(archives []tooth.Archive) {
+	for _, archive := range archives {
+		toothRepoPath := archive.Metadata().ToothRepoPath()
+
+		homograph, isHomograph := network.DetectHomograph(toothRepoPath)
+		if !isHomograph {
+			continue
+		}
+
+		if homograph.Lookalike != "" {
+			log.Warnf(i18n.T("The host of %v is displayed as %v, which looks like %v. Make sure it is the tooth you want."),
+				toothRepoPath, homograph.UnicodeHost, homograph.Lookalike)
+		} else {
+			log.Warnf(i18n.T("The host of %v is displayed as %v, which mixes scripts. Make sure it is the tooth you want."),
+				toothRepoPath, homograph.UnicodeHost)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package cmdlipinstall
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/history"
+	"github.com/lippkg/lip/internal/record"
+	"github.com/lippkg/lip/internal/tooth"
+)
+
+// installJournal is the plan and progress of an installation, saved in the workspace while the
+// teeth are installed. If lip crashes or the machine loses power meanwhile, it is left behind,
+// and lip install --resume continues the installation from it or lip install --abort rolls it
+// back.
+type installJournal struct {
+	Operation history.Operation `json:"operation"`
+	Upgrade   bool              `json:"upgrade,omitempty"`
+	Yes       bool              `json:"yes,omitempty"`
+
+	// Symlink, Hardlink, SideBySide and SkippedTags are how files are placed, so that resumed
+	// teeth are placed like the others.
+	Symlink     bool     `json:"symlink,omitempty"`
+	Hardlink    bool     `json:"hardlink,omitempty"`
+	SideBySide  bool     `json:"side_by_side,omitempty"`
+	SkippedTags []string `json:"skipped_tags,omitempty"`
+
+	// SpecifiedTeeth are the teeth to mark as explicitly installed once all are installed.
+	SpecifiedTeeth []string             `json:"specified_teeth"`
+	Steps          []installJournalStep `json:"steps"`
+}
+
+// installJournalStep is a tooth archive to install, with the change made by installing it.
+type installJournalStep struct {
+	ToothRepoPath  string           `json:"tooth"`
+	Version        string           `json:"version"`
+	ArchivePath    string           `json:"archive"`
+	ForceReinstall bool             `json:"force_reinstall,omitempty"`
+	State          installStepState `json:"state"`
+
+	// Changed is whether installing the tooth changes it, i.e. the tooth is not kept as it is.
+	// The fields below describe the change.
+	Changed         bool          `json:"changed,omitempty"`
+	WasInstalled    bool          `json:"was_installed,omitempty"`
+	PreviousVersion string        `json:"previous_version,omitempty"`
+	PreviousRecord  record.Record `json:"previous_record"`
+	HadSnapshot     bool          `json:"had_snapshot,omitempty"`
+}
+
+// installStepState is how far a step of an installation went.
+type installStepState string
+
+const (
+	stepPending installStepState = "pending"
+	// stepStarted marks a step whose changes may be partly made.
+	stepStarted installStepState = "started"
+	stepDone    installStepState = "done"
+)
+
+// newInstallJournal makes the journal of installing tooth archives, with all steps pending. The
+// placement of files is taken from the context.
+func newInstallJournal(ctx *context.Context, operation history.Operation, archives []tooth.Archive,
+	forceReinstall func(tooth.Archive) bool, upgrade bool, yes bool, specifiedArchives []tooth.Archive,
+) installJournal {
+	journal := installJournal{
+		Operation:      operation,
+		Upgrade:        upgrade,
+		Yes:            yes,
+		Symlink:        ctx.Symlink(),
+		Hardlink:       ctx.Hardlink(),
+		SideBySide:     ctx.SideBySide(),
+		SkippedTags:    ctx.SkippedTags(),
+		SpecifiedTeeth: make([]string, 0, len(specifiedArchives)),
+		Steps:          make([]installJournalStep, 0, len(archives)),
+	}
+
+	for _, archive := range specifiedArchives {
+		journal.SpecifiedTeeth = append(journal.SpecifiedTeeth, archive.Metadata().ToothRepoPath())
+	}
+
+	for _, archive := range archives {
+		journal.Steps = append(journal.Steps, installJournalStep{
+			ToothRepoPath:  archive.Metadata().ToothRepoPath(),
+			Version:        archive.Metadata().Version().String(),
+			ArchivePath:    archive.FilePath().LocalString(),
+			ForceReinstall: forceReinstall(archive),
+			State:          stepPending,
+		})
+	}
+
+	return journal
+}
+
+// step returns the step of installing a tooth, or nil if the tooth is not in the journal.
+func (j *installJournal) step(toothRepoPath string) *installJournalStep {
+	for i := range j.Steps {
+		if j.Steps[i].ToothRepoPath == toothRepoPath {
+			return &j.Steps[i]
+		}
+	}
+
+	return nil
+}
+
+// setChange records the change made by a step.
+func (s *installJournalStep) setChange(change toothChange) {
+	s.Changed = true
+	s.WasInstalled = change.wasInstalled
+	s.PreviousVersion = ""
+	if change.wasInstalled {
+		s.PreviousVersion = change.previousVersion.String()
+	}
+	s.PreviousRecord = change.previousRecord
+	s.HadSnapshot = change.hadSnapshot
+}
+
+// change returns the change made by a step.
+func (s installJournalStep) change() (toothChange, error) {
+	version, err := semver.Parse(s.Version)
+	if err != nil {
+		return toothChange{}, fmt.Errorf("failed to parse version %v of %v\n\t%w", s.Version, s.ToothRepoPath, err)
+	}
+
+	change := toothChange{
+		toothRepoPath:  s.ToothRepoPath,
+		version:        version,
+		wasInstalled:   s.WasInstalled,
+		previousRecord: s.PreviousRecord,
+		hadSnapshot:    s.HadSnapshot,
+	}
+
+	if s.WasInstalled {
+		previousVersion, err := semver.Parse(s.PreviousVersion)
+		if err != nil {
+			return toothChange{}, fmt.Errorf("failed to parse version %v of %v\n\t%w", s.PreviousVersion,
+				s.ToothRepoPath, err)
+		}
+
+		change.previousVersion = previousVersion
+	}
+
+	return change, nil
+}
+
+// hasInstallJournal returns whether an installation left its journal in the workspace.
+func hasInstallJournal(ctx *context.Context) (bool, error) {
+	journalFilePath, err := ctx.InstallJournalFilePath()
+	if err != nil {
+		return false, fmt.Errorf("failed to get install journal file path\n\t%w", err)
+	}
+
+	if _, err := ctx.FS().Stat(journalFilePath.LocalString()); os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to stat install journal file %v\n\t%w", journalFilePath.LocalString(), err)
+	}
+
+	return true, nil
+}
+
+// loadInstallJournal loads the journal left in the workspace. It returns false if there is none.
+func loadInstallJournal(ctx *context.Context) (installJournal, bool, error) {
+	journalFilePath, err := ctx.InstallJournalFilePath()
+	if err != nil {
+		return installJournal{}, false, fmt.Errorf("failed to get install journal file path\n\t%w", err)
+	}
+
+	jsonBytes, err := ctx.FS().ReadFile(journalFilePath.LocalString())
+	if os.IsNotExist(err) {
+		return installJournal{}, false, nil
+	} else if err != nil {
+		return installJournal{}, false, fmt.Errorf("failed to read install journal file %v\n\t%w",
+			journalFilePath.LocalString(), err)
+	}
+
+	var journal installJournal
+	if err := json.Unmarshal(jsonBytes, &journal); err != nil {
+		return installJournal{}, false, fmt.Errorf("failed to parse install journal file %v\n\t%w",
+			journalFilePath.LocalString(), err)
+	}
+
+	return journal, true, nil
+}
+
+// saveInstallJournal saves the journal in the workspace. It is written to a temporary file
+// first and renamed, so that a crash leaves either the previous journal or the new one.
+func saveInstallJournal(ctx *context.Context, journal installJournal) error {
+	journalFilePath, err := ctx.InstallJournalFilePath()
+	if err != nil {
+		return fmt.Errorf("failed to get install journal file path\n\t%w", err)
+	}
+
+	jsonBytes, err := json.MarshalIndent(journal, "", "    ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal install journal\n\t%w", err)
+	}
+
+	tempFilePath := journalFilePath.LocalString() + ".tmp"
+
+	if err := ctx.FS().WriteFile(tempFilePath, jsonBytes, 0644); err != nil {
+		return fmt.Errorf("failed to write install journal file %v\n\t%w", tempFilePath, err)
+	}
+
+	if err := ctx.FS().Rename(tempFilePath, journalFilePath.LocalString()); err != nil {
+		return fmt.Errorf("failed to rename %v to %v\n\t%w", tempFilePath, journalFilePath.LocalString(), err)
+	}
+
+	return nil
+}
+
+// removeInstallJournal removes the journal from the workspace once the installation ends. It
+// does nothing if there is none.
+func removeInstallJournal(ctx *context.Context) error {
+	journalFilePath, err := ctx.InstallJournalFilePath()
+	if err != nil {
+		return fmt.Errorf("failed to get install journal file path\n\t%w", err)
+	}
+
+	if err := ctx.FS().Remove(journalFilePath.LocalString()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove install journal file %v\n\t%w", journalFilePath.LocalString(), err)
+	}
+
+	return nil
+}
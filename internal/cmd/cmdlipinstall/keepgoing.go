@@ -0,0 +1,106 @@
+package cmdlipinstall
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/olekukonko/tablewriter"
+	log "github.com/sirupsen/logrus"
+)
+
+// installGroup is a part of the teeth to install with --keep-going, i.e. a specifier or the
+// profile, installed in its own transaction.
+type installGroup struct {
+	name             string
+	specifierStrings []string
+	profile          string
+}
+
+// installTeethSeparately installs the teeth of each specifier, and of the profile, separately,
+// continuing after failures. It reports which succeeded and which failed, and fails with
+// E_PARTIAL_FAILURE if some failed.
+func installTeethSeparately(ctx *context.Context, flagDict FlagDict, overrides map[string]semver.Version,
+	specifierStrings []string) error {
+	groups := make([]installGroup, 0, len(specifierStrings)+1)
+	for _, specifierString := range specifierStrings {
+		groups = append(groups, installGroup{
+			name:             specifierString,
+			specifierStrings: []string{specifierString},
+		})
+	}
+
+	if flagDict.profileFlag != "" {
+		groups = append(groups, installGroup{
+			name:    fmt.Sprintf("--profile %v", flagDict.profileFlag),
+			profile: flagDict.profileFlag,
+		})
+	}
+
+	errs := make([]error, len(groups))
+	failureCount := 0
+
+	for i, group := range groups {
+		// Groups left once the operation is interrupted are not installed.
+		if err := ctx.GoContext().Err(); err != nil {
+			errs[i] = err
+			failureCount++
+			continue
+		}
+
+		log.Infof(i18n.T("Installing %v (%v/%v)..."), group.name, i+1, len(groups))
+
+		groupFlagDict := flagDict
+		groupFlagDict.profileFlag = group.profile
+
+		if err := installTeeth(ctx, groupFlagDict, overrides, group.specifierStrings); err != nil {
+			log.Errorf(i18n.T("Failed to install %v:\n\t%v"), group.name, err)
+			errs[i] = err
+			failureCount++
+		}
+	}
+
+	printGroupSummary(groups, errs)
+
+	if err := ctx.GoContext().Err(); err != nil {
+		return errcode.Errorf(errcode.PartialFailure, "failed to install %v of %v\n\t%w", failureCount, len(groups),
+			err)
+	}
+
+	if failureCount != 0 {
+		return errcode.Errorf(errcode.PartialFailure, "failed to install %v of %v", failureCount, len(groups))
+	}
+
+	return nil
+}
+
+// ---------------------------------------------------------------------
+
+// printGroupSummary prints which groups were installed and which failed, with the codes of the
+// errors if any.
+func printGroupSummary(groups []installGroup, errs []error) {
+	tableString := &strings.Builder{}
+	table := tablewriter.NewWriter(tableString)
+	table.SetHeader([]string{
+		"Specifier", "Result",
+	})
+
+	for i, group := range groups {
+		result := "installed"
+		if errs[i] != nil {
+			result = "failed"
+			if code, ok := errcode.GetCode(errs[i]); ok {
+				result = fmt.Sprintf("failed (%v)", code)
+			}
+		}
+
+		table.Append([]string{group.name, result})
+	}
+
+	table.Render()
+
+	fmt.Print(tableString.String())
+}
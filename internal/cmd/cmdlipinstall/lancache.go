@@ -0,0 +1,64 @@
+package cmdlipinstall
+
+import (
+	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/lancache"
+	"github.com/lippkg/lip/internal/path"
+	"github.com/lippkg/lip/internal/sumdb"
+	log "github.com/sirupsen/logrus"
+)
+
+// fetchFromLANCache fetches a file of a tooth version from a lip instance on the local network
+// sharing its cache, if LANCacheDiscoveryMs is set, to a local path. Since the instances are not
+// trusted, a file is fetched only if its checksum is known beforehand, i.e. the source tells
+// it or it is recorded in the checksum database. Failures are warned about, so that the file
+// is downloaded as usual instead. It returns the checksum of the file. The second return value
+// is false if the file is not fetched.
+func fetchFromLANCache(ctx *context.Context, checksum string, toothRepoPath string, toothVersion semver.Version,
+	kind sumdb.Kind, filePath path.Path) (string, bool) {
+	debugLogger := ctx.Logger().WithFields(log.Fields{
+		"package": "cmdlipinstall",
+		"method":  "fetchFromLANCache",
+	})
+
+	timeout := ctx.LANCacheDiscoveryTimeout()
+	if timeout == 0 {
+		return "", false
+	}
+
+	expectedChecksum := checksum
+	if expectedChecksum == "" {
+		recordedChecksum, ok, err := sumdb.Lookup(ctx, toothRepoPath, toothVersion, kind)
+		if err != nil {
+			log.Warnf(i18n.T("Failed to fetch from the local network, downloading instead\n\t%v"), err)
+			return "", false
+		}
+
+		if !ok {
+			debugLogger.Debugf("Checksum of %v archive of %v@%v is unknown, skip fetching from the local network",
+				kind, toothRepoPath, toothVersion)
+			return "", false
+		}
+
+		expectedChecksum = recordedChecksum
+	}
+
+	peerAddr, ok, err := lancache.Fetch(ctx, expectedChecksum, filePath, timeout)
+	if err != nil {
+		ctx.FS().Remove(filePath.LocalString())
+		log.Warnf(i18n.T("Failed to fetch from the local network, downloading instead\n\t%v"), err)
+		return "", false
+	}
+
+	if !ok {
+		debugLogger.Debugf("No lip instance on the local network has %v", expectedChecksum)
+		return "", false
+	}
+
+	log.Infof(i18n.T("Fetched %v archive of %v@%v from %v on the local network"), kind, toothRepoPath, toothVersion,
+		peerAddr)
+
+	return expectedChecksum, true
+}
@@ -0,0 +1,134 @@
+package cmdlipinstall
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/diskspace"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/path"
+	log "github.com/sirupsen/logrus"
+)
+
+// downloadFromFastestSource downloads a file from the fastest of the sources hosting it, i.e.
+// its URL and the same URL on the extra GitHub mirrors or Go module proxies, to a local path.
+// The speeds of the sources are measured by downloading the first bytes of the file from all of
+// them at the same time. If the download from a source fails, the next fastest one is tried.
+// It returns the checksum of the file and the URL it is downloaded from.
+func downloadFromFastestSource(ctx *context.Context, downloadURL *url.URL, proxyURL *url.URL, header http.Header,
+	filePath path.Path, enableProgressBar bool) (string, *url.URL, error) {
+	debugLogger := ctx.Logger().WithFields(log.Fields{
+		"package": "cmdlipinstall",
+		"method":  "downloadFromFastestSource",
+	})
+
+	sourceURLs, err := getDownloadSources(ctx, downloadURL)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Extra headers may hold credentials of the source, which must not be sent to mirrors.
+	if len(sourceURLs) == 1 || header != nil {
+		checksum, err := ctx.Network().DownloadFile(ctx.GoContext(), downloadURL, proxyURL, header, filePath,
+			enableProgressBar, ctx.RetryPolicy())
+		return checksum, downloadURL, err
+	}
+
+	speeds := ctx.Network().RankSourcesBySpeed(ctx.GoContext(), sourceURLs, proxyURL, nil)
+	for _, speed := range speeds {
+		debugLogger.Debugf("Measured %v/s from %v", diskspace.FormatSize(int64(speed.BytesPerSecond)), speed.URL)
+	}
+
+	// If no source responds in time, they are tried in order, so that the errors are reported.
+	rankedURLs := make([]*url.URL, 0, len(sourceURLs))
+	for _, speed := range speeds {
+		rankedURLs = append(rankedURLs, speed.URL)
+	}
+	if len(rankedURLs) == 0 {
+		rankedURLs = sourceURLs
+	}
+
+	var lastErr error
+	for _, sourceURL := range rankedURLs {
+		if sourceURL.String() != downloadURL.String() {
+			log.Infof(i18n.T("Downloading from mirror %v"), sourceURL)
+		}
+
+		checksum, err := ctx.Network().DownloadFile(ctx.GoContext(), sourceURL, proxyURL, nil, filePath,
+			enableProgressBar, ctx.RetryPolicy())
+		if err == nil {
+			return checksum, sourceURL, nil
+		}
+
+		// A canceled download is not retried from other sources.
+		if ctx.GoContext().Err() != nil {
+			return "", nil, err
+		}
+
+		ctx.FS().Remove(filePath.LocalString())
+		log.Warnf(i18n.T("Failed to download from %v, trying the next mirror\n\t%v"), sourceURL, err)
+		lastErr = err
+	}
+
+	return "", nil, lastErr
+}
+
+// ---------------------------------------------------------------------
+
+// getDownloadSources returns the URLs a file is hosted at: its URL, made with GitHubMirrorURL
+// or GoModuleProxyURL, followed by the same URL on each of ExtraGitHubMirrorURLs or
+// ExtraGoModuleProxyURLs respectively. Other URLs have no other sources.
+func getDownloadSources(ctx *context.Context, downloadURL *url.URL) ([]*url.URL, error) {
+	gitHubMirrorURL, err := ctx.GitHubMirrorURL()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get GitHub mirror URL\n\t%w", err)
+	}
+
+	extraGitHubMirrorURLs, err := ctx.ExtraGitHubMirrorURLs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get extra GitHub mirror URLs\n\t%w", err)
+	}
+
+	goModuleProxyURL, err := ctx.GoModuleProxyURL()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Go module proxy URL\n\t%w", err)
+	}
+
+	extraGoModuleProxyURLs, err := ctx.ExtraGoModuleProxyURLs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get extra Go module proxy URLs\n\t%w", err)
+	}
+
+	sourceURLs := []*url.URL{downloadURL}
+
+	for _, mirrorSet := range []struct {
+		primaryURL *url.URL
+		extraURLs  []*url.URL
+	}{
+		{gitHubMirrorURL, extraGitHubMirrorURLs},
+		{goModuleProxyURL, extraGoModuleProxyURLs},
+	} {
+		prefix := strings.TrimSuffix(mirrorSet.primaryURL.String(), "/") + "/"
+		if !strings.HasPrefix(downloadURL.String(), prefix) {
+			continue
+		}
+
+		relativePath := strings.TrimPrefix(downloadURL.String(), prefix)
+
+		for _, extraURL := range mirrorSet.extraURLs {
+			sourceURL, err := url.Parse(strings.TrimSuffix(extraURL.String(), "/") + "/" + relativePath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to make URL on mirror %v\n\t%w", extraURL, err)
+			}
+
+			sourceURLs = append(sourceURLs, sourceURL)
+		}
+
+		break
+	}
+
+	return sourceURLs, nil
+}
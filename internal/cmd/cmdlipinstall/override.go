@@ -0,0 +1,101 @@
+package cmdlipinstall
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/record"
+	"github.com/lippkg/lip/internal/tooth"
+	"github.com/lippkg/lip/internal/workspace"
+)
+
+// overrideFlagValue collects the --override flags, each of the form <tooth>@<version>.
+type overrideFlagValue map[string]semver.Version
+
+func (v overrideFlagValue) String() string {
+	overrides := make([]string, 0, len(v))
+	for toothRepoPath, version := range v {
+		overrides = append(overrides, fmt.Sprintf("%v@%v", toothRepoPath, version))
+	}
+	sort.Strings(overrides)
+
+	return strings.Join(overrides, ",")
+}
+
+func (v overrideFlagValue) Set(s string) error {
+	toothRepoPath, versionString, ok := strings.Cut(s, "@")
+	if !ok {
+		return fmt.Errorf("override %v is not of the form <tooth>@<version>", s)
+	}
+
+	if !tooth.IsValidToothRepoPath(toothRepoPath) {
+		return fmt.Errorf("invalid tooth repo path %v", toothRepoPath)
+	}
+
+	version, err := semver.Parse(versionString)
+	if err != nil {
+		return fmt.Errorf("failed to parse override version %v\n\t%w", versionString, err)
+	}
+
+	v[toothRepoPath] = version
+
+	return nil
+}
+
+// getOverrides returns the versions teeth are forced to, declared in the workspace manifest and
+// by the --override flags. The flags take precedence over the workspace manifest.
+func getOverrides(ctx *context.Context, flagOverrides overrideFlagValue) (map[string]semver.Version, error) {
+	overrides := make(map[string]semver.Version)
+
+	isManifestPresent, err := workspace.IsManifestPresent(ctx.FS())
+	if err != nil {
+		return nil, err
+	}
+
+	if isManifestPresent {
+		manifest, err := workspace.LoadManifest(ctx.FS())
+		if err != nil {
+			return nil, fmt.Errorf("failed to load workspace manifest\n\t%w", err)
+		}
+
+		for toothRepoPath, version := range manifest.OverrideVersions() {
+			overrides[toothRepoPath] = version
+		}
+	}
+
+	for toothRepoPath, version := range flagOverrides {
+		overrides[toothRepoPath] = version
+	}
+
+	return overrides, nil
+}
+
+// recordOverrides records the overrides of the installed teeth, so that it is visible later
+// that their versions do not come from resolving constraints.
+func recordOverrides(ctx *context.Context, archives []tooth.Archive,
+	overrides map[string]semver.Version) error {
+	for _, archive := range archives {
+		toothRepoPath := archive.Metadata().ToothRepoPath()
+
+		overrideVersion, ok := overrides[toothRepoPath]
+		if !ok || overrideVersion.NE(archive.Metadata().Version()) {
+			continue
+		}
+
+		currentRecord, err := record.Get(ctx, toothRepoPath)
+		if err != nil {
+			return fmt.Errorf("failed to get record of tooth %v\n\t%w", toothRepoPath, err)
+		}
+
+		currentRecord.Override = overrideVersion.String()
+
+		if err := record.Save(ctx, currentRecord); err != nil {
+			return fmt.Errorf("failed to save record of tooth %v\n\t%w", toothRepoPath, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,311 @@
+package cmdlipinstall
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/delta"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/oci"
+	"github.com/lippkg/lip/internal/path"
+	"github.com/lippkg/lip/internal/profiling"
+	"github.com/lippkg/lip/internal/snapshot"
+	"github.com/lippkg/lip/internal/stats"
+	"github.com/lippkg/lip/internal/sumdb"
+	"github.com/lippkg/lip/internal/tooth"
+	"github.com/lippkg/lip/internal/vfs"
+	log "github.com/sirupsen/logrus"
+)
+
+// patchStep is a patch between two consecutive versions of a tooth in a patch chain.
+type patchStep struct {
+	from semver.Version
+	to   semver.Version
+}
+
+// patchToothArchiveIfNotCached tries to patch the tooth archive downloaded from a URL into the
+// cache if it is not cached.
+func patchToothArchiveIfNotCached(ctx *context.Context, downloadURL *url.URL, checksum string,
+	toothRepoPath string, toothVersion semver.Version, kind sumdb.Kind) error {
+	cachePath, err := getCachePath(ctx, downloadURL)
+	if err != nil {
+		return fmt.Errorf("failed to get cache path of %v\n\t%w", downloadURL, err)
+	}
+
+	if _, err := ctx.FS().Stat(cachePath.LocalString()); !os.IsNotExist(err) {
+		return nil
+	}
+
+	if err := patchToothArchive(ctx, toothRepoPath, toothVersion, cachePath, checksum, kind); err != nil {
+		return fmt.Errorf("failed to patch tooth archive of %v@%v\n\t%w", toothRepoPath, toothVersion, err)
+	}
+
+	return nil
+}
+
+// ---------------------------------------------------------------------
+
+// patchToothArchive tries to make the tooth archive of a version in the cache by applying the
+// chain of patches published in the OCI registry between the consecutive versions from the
+// installed version, whose archive is kept as a snapshot. The result is verified like a
+// downloaded archive. Nothing is done if the archive cannot be patched, e.g. the tooth is not
+// installed or a patch in the chain is not published, so that the archive is downloaded.
+func patchToothArchive(ctx *context.Context, toothRepoPath string, toothVersion semver.Version,
+	cachePath path.Path, checksum string, kind sumdb.Kind) error {
+	debugLogger := ctx.Logger().WithFields(log.Fields{
+		"package": "cmdlipinstall",
+		"method":  "patchToothArchive",
+	})
+
+	if ctx.Offline() || kind != sumdb.OCIArchiveKind {
+		return nil
+	}
+
+	baseArchive, ok, err := getPatchBase(ctx, toothRepoPath, toothVersion)
+	if err != nil || !ok {
+		return err
+	}
+
+	steps, err := getPatchChain(ctx, toothRepoPath, baseArchive.Metadata().Version(), toothVersion)
+	if err != nil || len(steps) == 0 {
+		return err
+	}
+
+	// Look up all patches first, so that nothing is downloaded if the chain is broken.
+	downloads := make([]patchDownload, 0, len(steps))
+	for _, step := range steps {
+		patchURL, header, patchChecksum, err := oci.GetPatchURL(ctx, toothRepoPath, step.from, step.to)
+		if err != nil {
+			return fmt.Errorf("failed to get patch URL\n\t%w", err)
+		}
+
+		if patchURL == nil {
+			debugLogger.Debugf("No patch from %v to %v of %v, downloading the full archive", step.from, step.to,
+				toothRepoPath)
+			return nil
+		}
+
+		downloads = append(downloads, patchDownload{
+			step:     step,
+			url:      patchURL,
+			header:   header,
+			checksum: patchChecksum,
+		})
+	}
+
+	log.Infof(i18n.T("Patching %v from %v to %v with %v patches"), toothRepoPath, baseArchive.Metadata().Version(),
+		toothVersion, len(steps))
+
+	patchedPath, patchedChecksum, err := applyPatchChain(ctx, baseArchive.FilePath(), downloads, cachePath)
+	if err != nil {
+		// A broken patch is not fatal, since the archive can still be downloaded.
+		log.Warnf(i18n.T("Failed to patch %v, downloading the full archive:\n\t%v"), toothRepoPath, err)
+		return nil
+	}
+	defer ctx.FS().Remove(patchedPath.LocalString())
+
+	if checksum != "" && patchedChecksum != checksum {
+		return errcode.Errorf(errcode.ChecksumMismatch,
+			"checksum mismatch of the patched archive of %v@%v: expected %v, got %v", toothRepoPath, toothVersion,
+			checksum, patchedChecksum)
+	}
+
+	if err := sumdb.VerifyChecksum(ctx, toothRepoPath, toothVersion, kind, patchedChecksum); err != nil {
+		return fmt.Errorf("failed to verify %v archive of %v@%v\n\t%w", kind, toothRepoPath, toothVersion,
+			err)
+	}
+
+	if err := ctx.FS().Rename(patchedPath.LocalString(), cachePath.LocalString()); err != nil {
+		return fmt.Errorf("failed to move patched file to the cache\n\t%w", err)
+	}
+
+	return nil
+}
+
+// patchDownload is where to download the patch of a step from.
+type patchDownload struct {
+	step     patchStep
+	url      *url.URL
+	header   http.Header
+	checksum string
+}
+
+// getPatchBase returns the snapshot of the installed version of a tooth to patch, if the tooth
+// is installed at an earlier version and the snapshot is kept.
+func getPatchBase(ctx *context.Context, toothRepoPath string, toothVersion semver.Version) (tooth.Archive, bool,
+	error) {
+	isInstalled, err := tooth.IsInstalled(ctx, toothRepoPath)
+	if err != nil || !isInstalled {
+		return tooth.Archive{}, false, err
+	}
+
+	metadata, err := tooth.GetMetadata(ctx, toothRepoPath)
+	if err != nil {
+		return tooth.Archive{}, false, fmt.Errorf("failed to find installed tooth metadata\n\t%w", err)
+	}
+
+	if !metadata.Version().LT(toothVersion) {
+		return tooth.Archive{}, false, nil
+	}
+
+	versions, err := snapshot.List(ctx, toothRepoPath)
+	if err != nil {
+		return tooth.Archive{}, false, fmt.Errorf("failed to list snapshots\n\t%w", err)
+	}
+
+	for _, version := range versions {
+		if version.EQ(metadata.Version()) {
+			archive, err := snapshot.Get(ctx, toothRepoPath, version)
+			if err != nil {
+				return tooth.Archive{}, false, err
+			}
+
+			return archive, true, nil
+		}
+	}
+
+	return tooth.Archive{}, false, nil
+}
+
+// getPatchChain returns the steps between the consecutive published versions from one version
+// to another.
+func getPatchChain(ctx *context.Context, toothRepoPath string, from semver.Version,
+	to semver.Version) ([]patchStep, error) {
+	versions, err := tooth.GetAvailableVersions(ctx, toothRepoPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get available versions of %v\n\t%w", toothRepoPath, err)
+	}
+
+	semver.Sort(versions)
+
+	steps := make([]patchStep, 0)
+	previous := from
+	for _, version := range versions {
+		if version.LTE(from) || version.GT(to) {
+			continue
+		}
+
+		steps = append(steps, patchStep{from: previous, to: version})
+		previous = version
+	}
+
+	// The chain is broken if the target version is not listed.
+	if previous.NE(to) {
+		return nil, nil
+	}
+
+	return steps, nil
+}
+
+// applyPatchChain downloads and applies the patches of a chain one by one, starting from the
+// base archive. It returns the path of the patched archive next to the cache path, to be moved
+// there once verified, and its hex-encoded SHA-256 checksum.
+func applyPatchChain(ctx *context.Context, basePath path.Path, downloads []patchDownload,
+	cachePath path.Path) (path.Path, string, error) {
+	cacheDir, err := ctx.CacheDir()
+	if err != nil {
+		return path.Path{}, "", fmt.Errorf("failed to get cache directory\n\t%w", err)
+	}
+
+	proxyURL, err := ctx.ProxyURL()
+	if err != nil {
+		return path.Path{}, "", fmt.Errorf("failed to get proxy URL\n\t%w", err)
+	}
+
+	// Convert to strings, since joining paths may reuse their underlying arrays.
+	patchPathStr := cacheDir.Join(path.MustParse(cachePath.Base() + ".patch.part")).LocalString()
+	currentPathStr := basePath.LocalString()
+	checksum := ""
+
+	for i, download := range downloads {
+		log.Infof(i18n.T("Downloading %v"), download.url)
+
+		patchPath, err := path.Parse(patchPathStr)
+		if err != nil {
+			return path.Path{}, "", fmt.Errorf("failed to parse patch path\n\t%w", err)
+		}
+
+		startTime := ctx.Clock().Now()
+		stopTiming := ctx.Timer().Start(profiling.DownloadPhase)
+		downloadedChecksum, err := ctx.Network().DownloadFile(ctx.GoContext(), download.url, proxyURL, download.header,
+			patchPath, false, ctx.RetryPolicy())
+		stopTiming()
+		if err != nil {
+			ctx.FS().Remove(patchPathStr)
+			stats.RecordDownload(ctx, download.url, 0, 0, false)
+			return path.Path{}, "", fmt.Errorf("failed to download patch\n\t%w", err)
+		}
+
+		recordDownload(ctx, download.url, patchPath, ctx.Clock().Now().Sub(startTime))
+
+		if download.checksum != "" && downloadedChecksum != download.checksum {
+			ctx.FS().Remove(patchPathStr)
+			return path.Path{}, "", errcode.Errorf(errcode.ChecksumMismatch,
+				"checksum mismatch of %v: expected %v, got %v", download.url, download.checksum, downloadedChecksum)
+		}
+
+		outputPathStr := cacheDir.Join(path.MustParse(fmt.Sprintf("%v.%v.part", cachePath.Base(), i))).LocalString()
+
+		checksum, err = applyPatch(ctx.FS(), currentPathStr, patchPathStr, outputPathStr)
+		ctx.FS().Remove(patchPathStr)
+		if i != 0 {
+			ctx.FS().Remove(currentPathStr)
+		}
+		if err != nil {
+			ctx.FS().Remove(outputPathStr)
+			return path.Path{}, "", fmt.Errorf("failed to apply patch from %v to %v\n\t%w", download.step.from,
+				download.step.to, err)
+		}
+
+		currentPathStr = outputPathStr
+	}
+
+	currentPath, err := path.Parse(currentPathStr)
+	if err != nil {
+		return path.Path{}, "", fmt.Errorf("failed to parse patched file path\n\t%w", err)
+	}
+
+	return currentPath, checksum, nil
+}
+
+// applyPatch applies a patch to a file on a file system and returns the hex-encoded SHA-256
+// checksum of the output.
+func applyPatch(fs vfs.FS, oldPathStr string, patchPathStr string, outputPathStr string) (string, error) {
+	oldFile, err := fs.Open(oldPathStr)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %v\n\t%w", oldPathStr, err)
+	}
+	defer oldFile.Close()
+
+	oldInfo, err := oldFile.Stat()
+	if err != nil {
+		return "", fmt.Errorf("failed to stat %v\n\t%w", oldPathStr, err)
+	}
+
+	patchFile, err := fs.Open(patchPathStr)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %v\n\t%w", patchPathStr, err)
+	}
+	defer patchFile.Close()
+
+	outputFile, err := fs.Create(outputPathStr)
+	if err != nil {
+		return "", fmt.Errorf("failed to create %v\n\t%w", outputPathStr, err)
+	}
+	defer outputFile.Close()
+
+	hash := sha256.New()
+	if err := delta.Apply(oldFile, oldInfo.Size(), patchFile, io.MultiWriter(outputFile, hash)); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,138 @@
+package cmdlipinstall
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"sort"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/install"
+	"github.com/lippkg/lip/internal/tooth"
+	"github.com/lippkg/lip/internal/vfs"
+	log "github.com/sirupsen/logrus"
+)
+
+// checkPermissions checks that lip can write to the directories files of the tooth archives
+// are placed in, and to the directories lip keeps its state in, before anything is changed.
+// If not, it lists the directories and tells how to get the permissions.
+func checkPermissions(ctx *context.Context, archives []tooth.Archive) error {
+	// The workspace is the working directory, so paths in it are relative.
+	targets := []string{".lip"}
+
+	if ctx.Hardlink() {
+		contentStoreDir, err := ctx.ContentStoreDir()
+		if err != nil {
+			return fmt.Errorf("failed to get content store directory\n\t%w", err)
+		}
+
+		targets = append(targets, contentStoreDir.LocalString())
+	}
+
+	for _, archive := range archives {
+		// Files are checked where they are placed, i.e. after remapping.
+		metadata, _, err := install.RemapDestinations(ctx, archive.Metadata())
+		if err != nil {
+			return err
+		}
+
+		files, err := metadata.Files()
+		if err != nil {
+			return fmt.Errorf("failed to get files from metadata\n\t%w", err)
+		}
+
+		for _, place := range files.Place {
+			targets = append(targets, place.Dest.LocalString())
+		}
+	}
+
+	unwritableDirSet := make(map[string]bool)
+	checkedDirSet := make(map[string]bool)
+	for _, target := range targets {
+		dir := getExistingDir(ctx.FS(), target)
+		if checkedDirSet[dir] {
+			continue
+		}
+		checkedDirSet[dir] = true
+
+		if isDirWritable(ctx.FS(), dir) {
+			continue
+		}
+
+		// Directories in the workspace are shown by their absolute paths.
+		if absDir, err := filepath.Abs(dir); err == nil {
+			dir = absDir
+		}
+		unwritableDirSet[dir] = true
+	}
+
+	if len(unwritableDirSet) == 0 {
+		return nil
+	}
+
+	unwritableDirs := make([]string, 0, len(unwritableDirSet))
+	for dir := range unwritableDirSet {
+		unwritableDirs = append(unwritableDirs, dir)
+	}
+	sort.Strings(unwritableDirs)
+
+	log.Error(i18n.T("lip cannot write to these directories:"))
+	for _, dir := range unwritableDirs {
+		log.Errorf("  %v", dir)
+	}
+
+	switch {
+	case isElevated():
+		log.Info(i18n.T("They cannot be written even with administrator privileges. Check whether the file system is read-only or the directories are locked by another program."))
+	case runtime.GOOS == "windows":
+		log.Info(i18n.T("Run lip as administrator, e.g. in a terminal opened with Run as administrator, or grant your user write access to the directories."))
+	default:
+		log.Infof(i18n.T("Run the command again with sudo, or make the directories writable by your user, e.g. with sudo chown -R $(id -un) %v"),
+			unwritableDirs[0])
+	}
+	log.Info(i18n.T("To leave a read-only workspace untouched, run lip with --overlay <dir> instead."))
+
+	return errcode.Errorf(errcode.PermissionDenied, "no permission to write to %v directories", len(unwritableDirs))
+}
+
+// ---------------------------------------------------------------------
+
+// getExistingDir returns the directory the target is written in: the target itself if it is an
+// existing directory, or otherwise its closest existing ancestor, which new directories are
+// created in.
+func getExistingDir(fs vfs.FS, target string) string {
+	dir := target
+	for {
+		if fileInfo, err := fs.Stat(dir); err == nil && fileInfo.IsDir() {
+			return dir
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
+
+// isDirWritable returns whether a file can be created in a directory, by creating and deleting
+// one. Permission bits are not enough, e.g. for ACLs on Windows or read-only file systems.
+func isDirWritable(fs vfs.FS, dir string) bool {
+	debugLogger := log.WithFields(log.Fields{
+		"package": "cmdlipinstall",
+		"method":  "isDirWritable",
+	})
+
+	file, err := vfs.CreateTemp(fs, dir, ".lip-permission-check-*")
+	if err != nil {
+		debugLogger.Debugf("Cannot write to %v: %v", dir, err)
+		return false
+	}
+
+	file.Close()
+	fs.Remove(file.Name())
+
+	return true
+}
@@ -0,0 +1,221 @@
+package cmdlipinstall
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/diskspace"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/render"
+	"github.com/lippkg/lip/internal/tooth"
+	"github.com/olekukonko/tablewriter"
+	log "github.com/sirupsen/logrus"
+)
+
+// installPlanItem is a tooth to install, with what installing it takes.
+type installPlanItem struct {
+	archive tooth.Archive
+	// installedVersion is the installed version replaced by the installation. Nil if the tooth
+	// is not installed.
+	installedVersion *semver.Version
+	// downloadSize is the size of the asset archive to download, 0 if it is not downloaded, or
+	// unknownSize if the server does not tell.
+	downloadSize int64
+	// installedSize is the uncompressed size of the files to place, or unknownSize if the asset
+	// archive is not downloaded yet.
+	installedSize int64
+}
+
+// unknownSize marks a size that is unknown before downloading.
+const unknownSize = -1
+
+// makeInstallPlan makes the plan of installing the tooth archives. Sizes of asset archives to
+// download are asked from the servers, and installed sizes are read from the asset archives
+// already available.
+func makeInstallPlan(ctx *context.Context, archives []tooth.Archive) ([]installPlanItem, error) {
+	plan := make([]installPlanItem, 0, len(archives))
+
+	for _, archive := range archives {
+		item := installPlanItem{
+			archive:       archive,
+			installedSize: unknownSize,
+		}
+
+		isInstalled, err := tooth.IsInstalled(ctx, archive.Metadata().ToothRepoPath())
+		if err != nil {
+			return nil, fmt.Errorf("failed to check if %v is installed\n\t%w", archive.Metadata().ToothRepoPath(), err)
+		}
+
+		if isInstalled {
+			metadata, err := tooth.GetMetadata(ctx, archive.Metadata().ToothRepoPath())
+			if err != nil {
+				return nil, fmt.Errorf("failed to get metadata of %v\n\t%w", archive.Metadata().ToothRepoPath(), err)
+			}
+
+			installedVersion := metadata.Version()
+			item.installedVersion = &installedVersion
+		}
+
+		downloadSize, isAssetAvailable, err := getAssetDownloadSize(ctx, archive)
+		if err != nil {
+			return nil, err
+		}
+
+		item.downloadSize = downloadSize
+
+		if isAssetAvailable {
+			assetArchiveFilePath, err := getAssetArchiveFilePath(ctx, archive)
+			if err != nil {
+				return nil, err
+			}
+
+			archiveWithAssets, err := archive.ToAssetArchiveAttached(ctx.Logger(), assetArchiveFilePath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to attach asset archive %v\n\t%w", assetArchiveFilePath.LocalString(),
+					err)
+			}
+
+			item.installedSize, err = getPlacedSize(ctx, archiveWithAssets)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		plan = append(plan, item)
+	}
+
+	return plan, nil
+}
+
+// printInstallPlan prints the teeth to install with their versions and sizes, and the total
+// sizes.
+func printInstallPlan(plan []installPlanItem) {
+	log.Info(render.Label(render.AddedRole, i18n.T("The following teeth will be installed:")))
+
+	tableString := &strings.Builder{}
+	table := tablewriter.NewWriter(tableString)
+	table.SetHeader([]string{
+		"Tooth", "Name", "Version", "Download Size", "Installed Size",
+	})
+
+	var totalDownloadSize, totalInstalledSize int64
+	isDownloadSizeUnknown, isInstalledSizeUnknown := false, false
+
+	for _, item := range plan {
+		metadata := item.archive.Metadata()
+
+		version := metadata.Version().String()
+		if item.installedVersion != nil {
+			role := render.ChangedRole
+			if metadata.Version().EQ(*item.installedVersion) {
+				role = render.UnchangedRole
+			} else if metadata.Version().LT(*item.installedVersion) {
+				role = render.DowngradedRole
+			}
+
+			version = render.Style(role, fmt.Sprintf("%v -> %v", item.installedVersion, metadata.Version()))
+		}
+
+		table.Append([]string{
+			metadata.ToothRepoPath(),
+			metadata.Info().Name,
+			version,
+			formatPlanSize(item.downloadSize),
+			formatPlanSize(item.installedSize),
+		})
+
+		if item.downloadSize == unknownSize {
+			isDownloadSizeUnknown = true
+		} else {
+			totalDownloadSize += item.downloadSize
+		}
+
+		if item.installedSize == unknownSize {
+			isInstalledSizeUnknown = true
+		} else {
+			totalInstalledSize += item.installedSize
+		}
+	}
+
+	table.Render()
+
+	fmt.Print(tableString.String())
+
+	log.Infof(i18n.T("Total download size: %v"), formatPlanTotalSize(totalDownloadSize, isDownloadSizeUnknown))
+	log.Infof(i18n.T("Total installed size: %v"), formatPlanTotalSize(totalInstalledSize, isInstalledSizeUnknown))
+}
+
+// askForConfirmation asks for confirmation before downloading and installing the teeth. Like
+// apt, an empty answer continues, but no answer at all, e.g. when the standard input is not a
+// terminal, aborts.
+func askForConfirmation(ctx *context.Context) error {
+	if ok, err := ctx.Prompter().Confirm(i18n.T("Do you want to continue? [Y/n]"), true); err != nil {
+		return err
+	} else if !ok {
+		return errcode.Errorf(errcode.Aborted, "aborted")
+	}
+
+	return nil
+}
+
+// ---------------------------------------------------------------------
+
+// getAssetDownloadSize returns the size of the asset archive of a tooth archive to download.
+// The second return value is true if the asset archive is available without downloading, i.e.
+// the tooth archive is the asset archive, or the asset archive is cached or vendored.
+func getAssetDownloadSize(ctx *context.Context, archive tooth.Archive) (int64, bool, error) {
+	if ctx.Vendor() {
+		return 0, true, nil
+	}
+
+	downloadURL, err := getAssetArchiveURL(ctx, archive)
+	if err != nil {
+		return 0, false, err
+	}
+
+	if downloadURL == nil {
+		return 0, true, nil
+	}
+
+	cachePath, err := getCachePath(ctx, downloadURL)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to get cache path of %v\n\t%w", downloadURL, err)
+	}
+
+	if _, err := ctx.FS().Stat(cachePath.LocalString()); err == nil {
+		return 0, true, nil
+	}
+
+	// Nothing is downloaded in offline mode.
+	if ctx.Offline() {
+		return 0, false, nil
+	}
+
+	size, ok := getDownloadSize(ctx, downloadURL)
+	if !ok {
+		return unknownSize, false, nil
+	}
+
+	return size, false, nil
+}
+
+// formatPlanSize formats a size in the plan.
+func formatPlanSize(size int64) string {
+	if size == unknownSize {
+		return "?"
+	}
+
+	return diskspace.FormatSize(size)
+}
+
+// formatPlanTotalSize formats a total size in the plan, noting if some sizes are not counted.
+func formatPlanTotalSize(size int64, isSomeUnknown bool) string {
+	if isSomeUnknown {
+		return i18n.Sprintf("%v (some sizes are unknown)", diskspace.FormatSize(size))
+	}
+
+	return diskspace.FormatSize(size)
+}
@@ -0,0 +1,93 @@
+package cmdlipinstall
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/tooth"
+	log "github.com/sirupsen/logrus"
+)
+
+// GetLatestSupportedVersion returns the latest version of a tooth in a version range that
+// supports the current platform. Stable versions are preferred over pre-release versions. The
+// tooth archives of the candidates are downloaded, without their assets, to read the platforms
+// they support.
+func GetLatestSupportedVersion(ctx *context.Context, toothRepoPath string,
+	versionRange semver.Range) (semver.Version, error) {
+	return getLatestSupportedVersion(ctx, toothRepoPath, versionRange, false)
+}
+
+// checkPlatforms checks that the teeth to install support the current platform, e.g. those
+// specified with exact versions, which are not resolved.
+func checkPlatforms(archives []tooth.Archive) error {
+	for _, archive := range archives {
+		metadata := archive.Metadata()
+		if !metadata.SupportsPlatform(runtime.GOOS, runtime.GOARCH) {
+			return errcode.Errorf(errcode.PlatformUnsupported, "%v@%v does not support %v-%v, only %v",
+				metadata.ToothRepoPath(), metadata.Version(), runtime.GOOS, runtime.GOARCH,
+				strings.Join(metadata.SupportedPlatforms(), ", "))
+		}
+	}
+
+	return nil
+}
+
+// ---------------------------------------------------------------------
+
+// getLatestSupportedVersion returns the latest version of a tooth in a version range that
+// supports the current platform, skipping the versions that do not. Pre-release versions are
+// considered as stable ones if includingPrerelease is set.
+func getLatestSupportedVersion(ctx *context.Context, toothRepoPath string, versionRange semver.Range,
+	includingPrerelease bool) (semver.Version, error) {
+	debugLogger := ctx.Logger().WithFields(log.Fields{
+		"package": "cmdlipinstall",
+		"method":  "getLatestSupportedVersion",
+	})
+
+	isUnsupported := make(map[string]bool)
+	candidateRange := versionRange.AND(func(version semver.Version) bool {
+		return !isUnsupported[version.String()]
+	})
+
+	// The latest version skipped, for the error message if no version is left.
+	var latestUnsupported *tooth.Metadata
+
+	for {
+		var version semver.Version
+		var err error
+		if includingPrerelease {
+			version, err = tooth.GetLatestVersionInVersionRangeIncludingPrerelease(ctx, toothRepoPath, candidateRange)
+		} else {
+			version, err = tooth.GetLatestVersionInVersionRange(ctx, toothRepoPath, candidateRange)
+		}
+		if err != nil && latestUnsupported != nil {
+			return semver.Version{}, errcode.Errorf(errcode.PlatformUnsupported,
+				"no version of %v in the range supports %v-%v, e.g. %v@%v supports only %v", toothRepoPath,
+				runtime.GOOS, runtime.GOARCH, toothRepoPath, latestUnsupported.Version(),
+				strings.Join(latestUnsupported.SupportedPlatforms(), ", "))
+		} else if err != nil {
+			return semver.Version{}, err
+		}
+
+		archive, err := downloadToothArchiveIfNotCached(ctx, toothRepoPath, version)
+		if err != nil {
+			return semver.Version{}, fmt.Errorf("failed to download archive of %v@%v\n\t%w", toothRepoPath, version, err)
+		}
+
+		metadata := archive.Metadata()
+		if metadata.SupportsPlatform(runtime.GOOS, runtime.GOARCH) {
+			return version, nil
+		}
+
+		debugLogger.Debugf("%v@%v does not support %v-%v, skip", toothRepoPath, version, runtime.GOOS, runtime.GOARCH)
+
+		isUnsupported[version.String()] = true
+		if latestUnsupported == nil {
+			latestUnsupported = &metadata
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package cmdlipinstall
+
+import (
+	"fmt"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/policy"
+	"github.com/lippkg/lip/internal/tooth"
+)
+
+// checkPolicy checks the teeth to install against the installation policy, if any. The
+// dependency depths are measured from the specified teeth over the teeth to install and the
+// installed teeth.
+func checkPolicy(ctx *context.Context, specifiedArchives []tooth.Archive, archivesToInstall []tooth.Archive,
+	filteredArchives []tooth.Archive) error {
+	installPolicy, ok, err := policy.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load installation policy\n\t%w", err)
+	}
+
+	if !ok {
+		return nil
+	}
+
+	depths, err := getDependencyDepths(ctx, specifiedArchives, archivesToInstall)
+	if err != nil {
+		return err
+	}
+
+	// Installed teeth are not checked again, except for their dependency depths.
+	metadataList := make([]tooth.Metadata, 0, len(filteredArchives))
+	for _, archive := range filteredArchives {
+		metadataList = append(metadataList, archive.Metadata())
+	}
+
+	filteredDepths := make(map[string]int)
+	for _, metadata := range metadataList {
+		if depth, ok := depths[metadata.ToothRepoPath()]; ok {
+			filteredDepths[metadata.ToothRepoPath()] = depth
+		}
+	}
+
+	return installPolicy.Check(metadataList, filteredDepths)
+}
+
+// getDependencyDepths returns the shortest dependency depths of the teeth reachable from the
+// specified teeth, which are at depth 0.
+func getDependencyDepths(ctx *context.Context, specifiedArchives []tooth.Archive,
+	archivesToInstall []tooth.Archive) (map[string]int, error) {
+	metadataMap := make(map[string]tooth.Metadata)
+	for _, archive := range archivesToInstall {
+		metadataMap[archive.Metadata().ToothRepoPath()] = archive.Metadata()
+	}
+
+	depths := make(map[string]int)
+	queue := make([]string, 0)
+	for _, archive := range specifiedArchives {
+		depths[archive.Metadata().ToothRepoPath()] = 0
+		metadataMap[archive.Metadata().ToothRepoPath()] = archive.Metadata()
+		queue = append(queue, archive.Metadata().ToothRepoPath())
+	}
+
+	for len(queue) != 0 {
+		toothRepoPath := queue[0]
+		queue = queue[1:]
+
+		metadata, ok := metadataMap[toothRepoPath]
+		if !ok {
+			// Dependencies already installed are not in the list to install.
+			isInstalled, err := tooth.IsInstalled(ctx, toothRepoPath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to check if tooth is installed\n\t%w", err)
+			}
+
+			if !isInstalled {
+				continue
+			}
+
+			metadata, err = tooth.GetMetadata(ctx, toothRepoPath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to find installed tooth metadata\n\t%w", err)
+			}
+		}
+
+		dependencies, err := metadata.Dependencies()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get dependencies of %v\n\t%w", toothRepoPath, err)
+		}
+
+		for dependency := range dependencies {
+			if _, ok := depths[dependency]; ok {
+				continue
+			}
+
+			depths[dependency] = depths[toothRepoPath] + 1
+			queue = append(queue, dependency)
+		}
+	}
+
+	return depths, nil
+}
@@ -0,0 +1,37 @@
+package cmdlipinstall
+
+import (
+	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/resolution"
+	log "github.com/sirupsen/logrus"
+)
+
+// latestVersionRange is the version range string used to cache the resolution of the latest
+// version of a tooth.
+const latestVersionRange = "*"
+
+// resolveVersion returns the latest version of a tooth in a version range that supports the
+// current platform. The result of an earlier resolution of the same constraint is reused if the registry index has not changed
+// since then.
+func resolveVersion(ctx *context.Context, toothRepoPath string, versionRangeString string,
+	versionRange semver.Range) (semver.Version, error) {
+	debugLogger := ctx.Logger().WithFields(log.Fields{
+		"package": "cmdlipinstall",
+		"method":  "resolveVersion",
+	})
+
+	if version, ok := resolution.GetResolution(ctx, toothRepoPath, versionRangeString); ok {
+		debugLogger.Debugf("Reused cached resolution of %v in %v: %v", toothRepoPath, versionRangeString, version)
+		return version, nil
+	}
+
+	version, err := getLatestSupportedVersion(ctx, toothRepoPath, versionRange, false)
+	if err != nil {
+		return semver.Version{}, err
+	}
+
+	resolution.SetResolution(ctx, toothRepoPath, versionRangeString, version)
+
+	return version, nil
+}
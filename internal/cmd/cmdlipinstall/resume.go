@@ -0,0 +1,126 @@
+package cmdlipinstall
+
+import (
+	"fmt"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/history"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/path"
+	"github.com/lippkg/lip/internal/tooth"
+	log "github.com/sirupsen/logrus"
+)
+
+// resumeInstallation resumes the installation whose install journal was left in the workspace
+// by a crash, with --resume, or rolls it back, with --abort. The teeth it installed are verified
+// first, and those cut off halfway are rolled back. If resuming fails, the whole installation is
+// rolled back.
+func resumeInstallation(ctx *context.Context, abort bool) error {
+	journal, ok, err := loadInstallJournal(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load install journal\n\t%w", err)
+	}
+
+	if !ok {
+		return errcode.Errorf(errcode.InvalidArgument, "there is no cut off installation to resume")
+	}
+
+	ctx.SetSymlink(journal.Symlink)
+	ctx.SetHardlink(journal.Hardlink)
+	ctx.SetSideBySide(journal.SideBySide)
+	ctx.SetSkippedTags(journal.SkippedTags)
+
+	log.Info(i18n.T("Verifying teeth installed before the installation was cut off..."))
+
+	transaction := newTransaction(ctx, journal.Operation)
+	if err := transaction.begin(journal); err != nil {
+		return err
+	}
+
+	if err := transaction.recover(); err != nil {
+		if !transaction.rollBack() {
+			log.Warn(i18n.T("Some changes cannot be rolled back. Run lip verify to check the installed teeth."))
+		}
+
+		return fmt.Errorf("failed to verify installed teeth\n\t%w", err)
+	}
+
+	if abort {
+		log.Info(i18n.T("Rolling back the installation..."))
+
+		if !transaction.undo(false) {
+			log.Warn(i18n.T("Some changes cannot be rolled back. Run lip verify to check the installed teeth."))
+		}
+
+		log.Info(i18n.T("Done."))
+
+		return nil
+	}
+
+	log.Info(i18n.T("Installing remaining teeth..."))
+
+	for i := range journal.Steps {
+		step := transaction.journal.Steps[i]
+		if step.State != stepPending {
+			continue
+		}
+
+		if err := resumeStep(transaction, step, journal.Upgrade, journal.Yes); err != nil {
+			if !transaction.rollBack() {
+				log.Warn(i18n.T("Some changes cannot be rolled back. Run lip verify to check the installed teeth."))
+			}
+
+			return fmt.Errorf("failed to install tooth archive %v\n\t%w", step.ArchivePath, err)
+		}
+	}
+
+	if err := markExplicitlyInstalled(ctx, journal.SpecifiedTeeth); err != nil {
+		if !transaction.rollBack() {
+			log.Warn(i18n.T("Some changes cannot be rolled back. Run lip verify to check the installed teeth."))
+		}
+
+		return err
+	}
+
+	if err := history.Append(ctx, journal.Operation, transaction.historyChanges(), 0); err != nil {
+		if !transaction.rollBack() {
+			log.Warn(i18n.T("Some changes cannot be rolled back. Run lip verify to check the installed teeth."))
+		}
+
+		return fmt.Errorf("failed to record history\n\t%w", err)
+	}
+
+	if err := transaction.commit(); err != nil {
+		return err
+	}
+
+	transaction.printSummary()
+
+	log.Info(i18n.T("Done."))
+
+	return nil
+}
+
+// ---------------------------------------------------------------------
+
+// resumeStep installs the tooth archive of a pending step in a resumed transaction. The archive
+// must still be the version planned.
+func resumeStep(transaction *transaction, step installJournalStep, upgrade bool, yes bool) error {
+	archivePath, err := path.Parse(step.ArchivePath)
+	if err != nil {
+		return fmt.Errorf("failed to parse archive path %v\n\t%w", step.ArchivePath, err)
+	}
+
+	archive, err := tooth.MakeArchiveOfTooth(transaction.ctx.FS(), archivePath, step.ToothRepoPath)
+	if err != nil {
+		return fmt.Errorf("failed to open tooth archive %v\n\t%w", step.ArchivePath, err)
+	}
+
+	if archive.Metadata().Version().String() != step.Version {
+		return fmt.Errorf("tooth archive %v is of version %v instead of %v", step.ArchivePath,
+			archive.Metadata().Version(), step.Version)
+	}
+
+	return transaction.install(archive, step.ForceReinstall, upgrade, yes)
+}
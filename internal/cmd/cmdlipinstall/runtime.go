@@ -0,0 +1,113 @@
+package cmdlipinstall
+
+import (
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/tooth"
+	log "github.com/sirupsen/logrus"
+)
+
+// checkRuntimes checks that the teeth to install are compatible with the runtimes in the
+// workspace, e.g. the installed LeviLamina version, as they will be after the installation. The
+// installed teeth are checked as well if the installation changes a runtime. Incompatible teeth
+// fail the installation, unless ignore is set, in which case they are only warned about.
+// Runtimes not found in the workspace are warned about and not checked.
+func checkRuntimes(ctx *context.Context, archives []tooth.Archive, ignore bool) error {
+	installedMetadataList, err := tooth.GetAllMetadata(ctx)
+	if err != nil {
+		return err
+	}
+
+	metadataList := make([]tooth.Metadata, 0, len(archives)+len(installedMetadataList))
+	isInstalling := make(map[string]bool)
+	for _, archive := range archives {
+		metadataList = append(metadataList, archive.Metadata())
+		isInstalling[archive.Metadata().ToothRepoPath()] = true
+	}
+
+	// Versions of the runtimes after the installation, and which of them it changes.
+	runtimeVersions := make(map[string]semver.Version)
+	isRuntimeChanged := make(map[string]bool)
+	for _, runtime := range tooth.RuntimeNames() {
+		for _, metadata := range metadataList {
+			if version, ok := metadata.RuntimeVersion(runtime); ok {
+				runtimeVersions[runtime] = version
+				isRuntimeChanged[runtime] = true
+				break
+			}
+		}
+
+		if isRuntimeChanged[runtime] {
+			continue
+		}
+
+		for _, metadata := range installedMetadataList {
+			if isInstalling[metadata.ToothRepoPath()] {
+				continue
+			}
+
+			if version, ok := metadata.RuntimeVersion(runtime); ok {
+				runtimeVersions[runtime] = version
+				break
+			}
+		}
+	}
+
+	incompatibilities := make([]string, 0)
+
+	checkMetadata := func(metadata tooth.Metadata, isInstalled bool) {
+		runtimeRanges := metadata.Runtimes()
+		runtimeRangesAsStrings := metadata.RuntimesAsStrings()
+
+		for _, runtime := range tooth.RuntimeNames() {
+			versionRange, ok := runtimeRanges[runtime]
+			if !ok || (isInstalled && !isRuntimeChanged[runtime]) {
+				continue
+			}
+
+			version, ok := runtimeVersions[runtime]
+			if !ok {
+				if !isInstalled {
+					log.Warnf(i18n.T("%v requires %v %v, which is not found in the workspace. Its compatibility is not checked."),
+						metadata.ToothRepoPath(), runtime, runtimeRangesAsStrings[runtime])
+				}
+				continue
+			}
+
+			if !versionRange(version) {
+				incompatibilities = append(incompatibilities, i18n.Sprintf("%v@%v requires %v %v, but it is %v",
+					metadata.ToothRepoPath(), metadata.Version(), runtime, runtimeRangesAsStrings[runtime], version))
+			}
+		}
+	}
+
+	for _, metadata := range metadataList {
+		checkMetadata(metadata, false)
+	}
+
+	for _, metadata := range installedMetadataList {
+		if !isInstalling[metadata.ToothRepoPath()] {
+			checkMetadata(metadata, true)
+		}
+	}
+
+	if len(incompatibilities) == 0 {
+		return nil
+	}
+
+	if ignore {
+		for _, incompatibility := range incompatibilities {
+			log.Warnf(i18n.T("Ignoring incompatible runtime: %v"), incompatibility)
+		}
+
+		return nil
+	}
+
+	return errcode.Errorf(errcode.RuntimeIncompatible,
+		"incompatible runtimes. Pass --ignore-runtimes to install anyway:\n\t  %v",
+		strings.Join(incompatibilities, "\n\t  "))
+}
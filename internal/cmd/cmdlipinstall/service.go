@@ -0,0 +1,106 @@
+package cmdlipinstall
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/service"
+	"github.com/lippkg/lip/internal/tooth"
+	log "github.com/sirupsen/logrus"
+)
+
+// confirmServicesToRegister lists the OS services declared by the tooth archives and asks
+// whether to register them, unless yes is set. It returns the services to register keyed by
+// the tooth repo paths, which is empty if the user declines.
+func confirmServicesToRegister(ctx *context.Context, archives []tooth.Archive, yes bool) (map[string]tooth.Service, error) {
+	services := make(map[string]tooth.Service)
+	for _, archive := range archives {
+		toothService, ok, err := archive.Metadata().Service()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get service of %v\n\t%w", archive.Metadata().ToothRepoPath(), err)
+		}
+
+		if ok {
+			services[archive.Metadata().ToothRepoPath()] = toothService
+		}
+	}
+
+	if len(services) == 0 {
+		log.Info(i18n.T("None of the teeth to install declares an OS service."))
+		return services, nil
+	}
+
+	if yes {
+		return services, nil
+	}
+
+	log.Info(i18n.T("The following OS services will be registered and started on boot:"))
+	for _, toothRepoPath := range sortedServiceToothRepoPaths(services) {
+		toothService := services[toothRepoPath]
+		log.Infof("  %v (%v): %v", toothService.Name, toothRepoPath,
+			strings.Join(append([]string{toothService.Command.LocalString()}, toothService.Args...), " "))
+	}
+
+	if ok, err := ctx.Prompter().Confirm(i18n.T("Do you want to register them? [y/N]"), false); err != nil {
+		return nil, err
+	} else if !ok {
+		log.Info(i18n.T("Skipped registering OS services."))
+		return make(map[string]tooth.Service), nil
+	}
+
+	return services, nil
+}
+
+// registerServices registers the OS services of installed teeth and returns the tooth repo
+// paths of those newly registered. If one fails, the services newly registered before are
+// unregistered again.
+func registerServices(ctx *context.Context, services map[string]tooth.Service) ([]string, error) {
+	newlyRegistered := make([]string, 0)
+
+	for _, toothRepoPath := range sortedServiceToothRepoPaths(services) {
+		toothService := services[toothRepoPath]
+
+		_, wasRegistered, err := service.Get(ctx, toothRepoPath)
+		if err != nil {
+			unregisterServices(ctx, newlyRegistered)
+			return nil, fmt.Errorf("failed to get service registration of %v\n\t%w", toothRepoPath, err)
+		}
+
+		log.Infof(i18n.T("Registering OS service %v of %v"), toothService.Name, toothRepoPath)
+
+		if err := service.Register(ctx, toothRepoPath, toothService); err != nil {
+			unregisterServices(ctx, newlyRegistered)
+			return nil, err
+		}
+
+		if !wasRegistered {
+			newlyRegistered = append(newlyRegistered, toothRepoPath)
+		}
+	}
+
+	return newlyRegistered, nil
+}
+
+// unregisterServices unregisters the OS services of teeth, warning about those failing.
+func unregisterServices(ctx *context.Context, toothRepoPaths []string) {
+	for _, toothRepoPath := range toothRepoPaths {
+		if _, err := service.Unregister(ctx, toothRepoPath); err != nil {
+			log.Warnf(i18n.T("Failed to unregister OS service of %v\n\t%v"), toothRepoPath, err)
+		}
+	}
+}
+
+// ---------------------------------------------------------------------
+
+func sortedServiceToothRepoPaths(services map[string]tooth.Service) []string {
+	toothRepoPaths := make([]string, 0, len(services))
+	for toothRepoPath := range services {
+		toothRepoPaths = append(toothRepoPaths, toothRepoPath)
+	}
+	sort.Strings(toothRepoPaths)
+
+	return toothRepoPaths
+}
@@ -0,0 +1,31 @@
+package cmdlipinstall
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// placementTagPattern matches a tag of a files.place item, as in the JSON schema.
+var placementTagPattern = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// skipFlagValue collects the --skip flags, each of which is a comma-separated list of tags of
+// files.place items whose files are not placed, e.g. docs,examples.
+type skipFlagValue []string
+
+func (v *skipFlagValue) String() string {
+	return strings.Join(*v, ",")
+}
+
+func (v *skipFlagValue) Set(s string) error {
+	for _, tag := range strings.Split(s, ",") {
+		tag = strings.TrimSpace(tag)
+		if !placementTagPattern.MatchString(tag) {
+			return fmt.Errorf("invalid tag %v to skip", tag)
+		}
+
+		*v = append(*v, tag)
+	}
+
+	return nil
+}
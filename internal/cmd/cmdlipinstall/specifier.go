@@ -2,42 +2,95 @@ package cmdlipinstall
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/blang/semver/v4"
 	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
 	"github.com/lippkg/lip/internal/must"
 	specifierpkg "github.com/lippkg/lip/internal/specifier"
+	"github.com/lippkg/lip/internal/tarzst"
 	"github.com/lippkg/lip/internal/tooth"
+	"github.com/lippkg/lip/internal/versionmatch"
+	log "github.com/sirupsen/logrus"
 )
 
+// versionConstraint is a version range a specified tooth declares on another specified tooth
+// as a dependency.
+type versionConstraint struct {
+	dependentToothRepoPath string
+	versionRangeString     string
+	versionRange           semver.Range
+}
+
 // downloadToothRepoSpecifier downloads the tooth specified by the specifier and returns
-// the path to the downloaded tooth.
+// the path to the downloaded tooth. Unless the version is exact, the selected version also
+// satisfies the constraints.
 func downloadToothRepoSpecifier(ctx *context.Context,
-	specifier specifierpkg.Specifier) (tooth.Archive, error) {
+	specifier specifierpkg.Specifier, constraints []versionConstraint) (tooth.Archive, error) {
 	if specifier.Kind() != specifierpkg.ToothRepoKind {
-		return tooth.Archive{}, fmt.Errorf("invalid specifier kind %v", specifier.Kind())
+		return tooth.Archive{}, i18n.Errorf("invalid specifier kind %v", specifier.Kind())
 	}
 
 	toothRepoPath := must.Must(specifier.ToothRepoPath())
 
-	// Parse or get the tooth version.
+	// Select the tooth version.
 
-	var toothVersion semver.Version
-	isToothVersionSpecified, err := specifier.IsToothVersionSpecified()
+	versionMatch, err := specifier.VersionMatch()
 	if err != nil {
-		return tooth.Archive{}, fmt.Errorf("failed to get is tooth version specified\n\t%w", err)
+		return tooth.Archive{}, fmt.Errorf("failed to get version match\n\t%w", err)
+	}
+
+	// Join the constraints with the version match. The joined string is only used as the key of
+	// the resolution cache.
+	versionRangeStrings := make([]string, 0)
+	versionRange := semver.Range(func(semver.Version) bool { return true })
+	for _, constraint := range constraints {
+		versionRangeStrings = append(versionRangeStrings, constraint.versionRangeString)
+		versionRange = versionRange.AND(constraint.versionRange)
 	}
 
-	if isToothVersionSpecified {
-		toothVersion = must.Must(specifier.ToothVersion())
+	var toothVersion semver.Version
+	switch versionMatch.Kind() {
+	case versionmatch.ExactKind:
+		// Exact versions are checked against the constraints when resolving dependencies.
+		toothVersion = must.Must(versionMatch.Version())
+
+		warnIfYanked(ctx, toothRepoPath, toothVersion)
 
-	} else {
-		latestVersion, err := tooth.GetLatestVersion(ctx, toothRepoPath)
+	case versionmatch.PrereleaseKind:
+		version, err := getLatestSupportedVersion(ctx, toothRepoPath, versionRange, true)
 		if err != nil {
 			return tooth.Archive{}, fmt.Errorf("failed to look up tooth version\n\t%w", err)
 		}
 
-		toothVersion = latestVersion
+		toothVersion = version
+
+	default:
+		if versionMatch.Kind() == versionmatch.RangeKind {
+			versionRangeStrings = append([]string{must.Must(versionMatch.RangeString())}, versionRangeStrings...)
+			versionRange = versionRange.AND(must.Must(versionMatch.Range()))
+		}
+
+		versionRangeString := latestVersionRange
+		if len(versionRangeStrings) != 0 {
+			versionRangeString = strings.Join(versionRangeStrings, "; ")
+		}
+
+		version, err := resolveVersion(ctx, toothRepoPath, versionRangeString, versionRange)
+		if code, ok := errcode.GetCode(err); ok && code == errcode.PlatformUnsupported {
+			return tooth.Archive{}, err
+		} else if err != nil && len(constraints) != 0 {
+			return tooth.Archive{}, errcode.Errorf(errcode.ResolveConflict,
+				"no version of %v matching %v satisfies the dependencies of %v", toothRepoPath, versionMatch,
+				getDependentToothRepoPaths(constraints))
+		} else if err != nil {
+			return tooth.Archive{}, fmt.Errorf("failed to look up tooth version in %v\n\t%w", versionMatch, err)
+		}
+
+		toothVersion = version
 	}
 
 	archive, err := downloadToothArchiveIfNotCached(ctx, toothRepoPath, toothVersion)
@@ -52,38 +105,162 @@ func downloadToothRepoSpecifier(ctx *context.Context,
 // resolveSpecifiers parses the specifier string list and
 // downloads the tooth specified by the specifier, and returns the list of
 // downloaded tooth archives.
+//
+// The specified teeth are resolved jointly: if a specified tooth depends on another specified
+// tooth without an exact version, the version selected for the latter satisfies the dependency.
 func resolveSpecifiers(ctx *context.Context,
 	specifiers []specifierpkg.Specifier) ([]tooth.Archive, error) {
+	debugLogger := ctx.Logger().WithFields(log.Fields{
+		"package": "cmdlipinstall",
+		"method":  "resolveSpecifiers",
+	})
 
-	archiveList := make([]tooth.Archive, 0)
+	archiveMap := make(map[int]tooth.Archive)
+	jointSpecifierIndexes := make([]int, 0)
 
-	for _, specifier := range specifiers {
-		var archive tooth.Archive
+	// Local archives and exact versions are fixed, so they are opened first.
 
+	for i, specifier := range specifiers {
 		switch specifier.Kind() {
 		case specifierpkg.ToothArchiveKind:
 			archivePath := must.Must(specifier.ToothArchivePath())
-			localArchive, err := tooth.MakeArchive(archivePath)
+
+			if err := verifyLocalArchiveChecksum(ctx, archivePath); err != nil {
+				return nil, err
+			}
+
+			zipPath, err := tarzst.ToZipIfTarZst(ctx, archivePath)
+			if err != nil {
+				return nil, err
+			}
+
+			localArchive, err := tooth.MakeArchive(ctx.FS(), zipPath)
 			if err != nil {
 				return nil, fmt.Errorf("failed to open archive %v\n\t%w", archivePath.LocalString(), err)
 			}
 
-			archive = localArchive
+			archiveMap[i] = localArchive
 
 		case specifierpkg.ToothRepoKind:
-			downloadedArchive, err := downloadToothRepoSpecifier(ctx, specifier)
+			versionMatch, err := specifier.VersionMatch()
+			if err != nil {
+				return nil, fmt.Errorf("failed to get version match\n\t%w", err)
+			}
+
+			if versionMatch.Kind() != versionmatch.ExactKind {
+				jointSpecifierIndexes = append(jointSpecifierIndexes, i)
+				continue
+			}
+
+			downloadedArchive, err := downloadToothRepoSpecifier(ctx, specifier, nil)
 			if err != nil {
 				return nil, fmt.Errorf("failed to download specifier %v\n\t%w", specifier, err)
 			}
 
-			archive = downloadedArchive
+			archiveMap[i] = downloadedArchive
 
 		default:
 			panic("unreachable")
 		}
+	}
+
+	// Selecting a version of one tooth may change the constraints on another, so the versions
+	// are selected again until none of them changes. If they still change after as many passes
+	// as there are teeth, the constraints are considered unsatisfiable.
+
+	for pass := 0; ; pass++ {
+		if pass > len(jointSpecifierIndexes) {
+			return nil, errcode.Errorf(errcode.ResolveConflict,
+				"cannot find versions of the specified teeth that satisfy their dependencies on each other")
+		}
+
+		isChanged := false
+		for _, i := range jointSpecifierIndexes {
+			toothRepoPath := must.Must(specifiers[i].ToothRepoPath())
+
+			constraints, err := getVersionConstraints(archiveMap, i, toothRepoPath)
+			if err != nil {
+				return nil, err
+			}
+
+			downloadedArchive, err := downloadToothRepoSpecifier(ctx, specifiers[i], constraints)
+			if err != nil {
+				return nil, fmt.Errorf("failed to download specifier %v\n\t%w", specifiers[i], err)
+			}
+
+			if previousArchive, ok := archiveMap[i]; ok &&
+				previousArchive.Metadata().Version().EQ(downloadedArchive.Metadata().Version()) {
+				continue
+			}
+
+			debugLogger.Debugf("Selected %v@%v for specifier %v in pass %v", toothRepoPath,
+				downloadedArchive.Metadata().Version(), specifiers[i], pass)
+
+			archiveMap[i] = downloadedArchive
+			isChanged = true
+		}
+
+		if !isChanged {
+			break
+		}
+	}
 
-		archiveList = append(archiveList, archive)
+	archiveList := make([]tooth.Archive, 0, len(specifiers))
+	for i := range specifiers {
+		archiveList = append(archiveList, archiveMap[i])
 	}
 
 	return archiveList, nil
 }
+
+// ---------------------------------------------------------------------
+
+// getVersionConstraints returns the version ranges the selected archives, except the one of
+// the specifier at skippedIndex, declare on a tooth as dependencies.
+func getVersionConstraints(archiveMap map[int]tooth.Archive, skippedIndex int,
+	toothRepoPath string) ([]versionConstraint, error) {
+	constraints := make([]versionConstraint, 0)
+
+	// Iterate in order of specifiers to keep the resolution cache key stable.
+	indexes := make([]int, 0, len(archiveMap))
+	for i := range archiveMap {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	for _, i := range indexes {
+		if i == skippedIndex {
+			continue
+		}
+
+		archive := archiveMap[i]
+
+		depMap, err := archive.Metadata().Dependencies()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get dependencies of %v\n\t%w", archive.FilePath().LocalString(), err)
+		}
+
+		versionRange, ok := depMap[toothRepoPath]
+		if !ok {
+			continue
+		}
+
+		constraints = append(constraints, versionConstraint{
+			dependentToothRepoPath: archive.Metadata().ToothRepoPath(),
+			versionRangeString:     archive.Metadata().DependenciesAsStrings()[toothRepoPath],
+			versionRange:           versionRange,
+		})
+	}
+
+	return constraints, nil
+}
+
+// getDependentToothRepoPaths returns the teeth declaring the constraints, separated by commas.
+func getDependentToothRepoPaths(constraints []versionConstraint) string {
+	toothRepoPaths := make([]string, 0, len(constraints))
+	for _, constraint := range constraints {
+		toothRepoPaths = append(toothRepoPaths, constraint.dependentToothRepoPath)
+	}
+
+	return strings.Join(toothRepoPaths, ", ")
+}
@@ -0,0 +1,76 @@
+package cmdlipinstall
+
+import (
+	"strings"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/install"
+	"github.com/lippkg/lip/internal/tooth"
+	log "github.com/sirupsen/logrus"
+)
+
+// checkTools checks that the programs the teeth to install require on the host, e.g. a .NET
+// runtime, are present in the required versions. Missing tools fail the installation with the
+// hints of how to get them, unless ignore is set, in which case they are only warned about.
+func checkTools(ctx *context.Context, archives []tooth.Archive, ignore bool) error {
+	debugLogger := ctx.Logger().WithFields(log.Fields{
+		"package": "cmdlipinstall",
+		"method":  "checkTools",
+	})
+
+	commandEnvirons, err := install.GetCommandEnvirons(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Results of the tools already checked, since several teeth may require the same one.
+	type toolKey struct {
+		name    string
+		command string
+		version string
+	}
+	checkErrors := make(map[toolKey]error)
+
+	problems := make([]string, 0)
+	for _, archive := range archives {
+		for _, tool := range archive.Metadata().Tools() {
+			key := toolKey{name: tool.Name, command: tool.Command, version: tool.VersionAsString}
+
+			checkErr, ok := checkErrors[key]
+			if !ok {
+				checkErr = install.CheckTool(ctx.GoContext(), tool, commandEnvirons)
+				checkErrors[key] = checkErr
+			}
+
+			if checkErr == nil {
+				debugLogger.Debugf("Found %v required by %v", tool.Name, archive.Metadata().ToothRepoPath())
+				continue
+			}
+
+			problem := i18n.Sprintf("%v requires %v", archive.Metadata().ToothRepoPath(), checkErr.Error())
+			if tool.Hint != "" {
+				problem += "\n\t    " + tool.Hint
+			}
+
+			problems = append(problems, problem)
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	if ignore {
+		for _, problem := range problems {
+			log.Warnf(i18n.T("Ignoring missing tool: %v"), problem)
+		}
+
+		return nil
+	}
+
+	return errcode.Errorf(errcode.ToolMissing,
+		"missing tools. Install them, or pass --ignore-tools to install anyway:\n\t  %v",
+		strings.Join(problems, "\n\t  "))
+}
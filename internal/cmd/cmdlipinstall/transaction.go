@@ -0,0 +1,425 @@
+package cmdlipinstall
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/history"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/install"
+	"github.com/lippkg/lip/internal/interrupt"
+	"github.com/lippkg/lip/internal/record"
+	"github.com/lippkg/lip/internal/render"
+	"github.com/lippkg/lip/internal/snapshot"
+	"github.com/lippkg/lip/internal/tooth"
+	"github.com/olekukonko/tablewriter"
+	log "github.com/sirupsen/logrus"
+)
+
+// transaction installs tooth archives as a whole. If installing one of them fails, the changes
+// made so far are rolled back: newly installed teeth are uninstalled and replaced versions are
+// reinstated from their snapshots. Its plan and progress are kept in the install journal until
+// it ends, so that it can be resumed or rolled back after a crash.
+type transaction struct {
+	ctx       *context.Context
+	operation history.Operation
+	changes   []toothChange
+	journal   installJournal
+}
+
+// toothChange is a change made to a tooth in a transaction.
+type toothChange struct {
+	toothRepoPath   string
+	version         semver.Version
+	wasInstalled    bool
+	previousVersion semver.Version
+	previousRecord  record.Record
+	hadSnapshot     bool
+}
+
+func newTransaction(ctx *context.Context, operation history.Operation) *transaction {
+	return &transaction{
+		ctx:       ctx,
+		operation: operation,
+		changes:   make([]toothChange, 0),
+	}
+}
+
+// begin saves the install journal of the transaction before anything is installed.
+func (t *transaction) begin(journal installJournal) error {
+	t.journal = journal
+
+	if err := saveInstallJournal(t.ctx, t.journal); err != nil {
+		return fmt.Errorf("failed to save install journal\n\t%w", err)
+	}
+
+	return nil
+}
+
+// commit ends the transaction once everything is installed and recorded.
+func (t *transaction) commit() error {
+	if err := removeInstallJournal(t.ctx); err != nil {
+		return fmt.Errorf("failed to remove install journal\n\t%w", err)
+	}
+
+	return nil
+}
+
+// install installs a tooth archive in the transaction.
+func (t *transaction) install(archive tooth.Archive, forceReinstall bool, upgrade bool, yes bool) error {
+	// Nothing more is installed once the operation is interrupted.
+	if err := t.ctx.GoContext().Err(); err != nil {
+		return err
+	}
+
+	toothRepoPath := archive.Metadata().ToothRepoPath()
+
+	change := toothChange{
+		toothRepoPath: toothRepoPath,
+		version:       archive.Metadata().Version(),
+	}
+
+	isInstalled, err := tooth.IsInstalled(t.ctx, toothRepoPath)
+	if err != nil {
+		return fmt.Errorf("failed to check if tooth is installed\n\t%w", err)
+	}
+
+	if isInstalled {
+		currentMetadata, err := tooth.GetMetadata(t.ctx, toothRepoPath)
+		if err != nil {
+			return fmt.Errorf("failed to find installed tooth metadata\n\t%w", err)
+		}
+
+		currentRecord, err := record.Get(t.ctx, toothRepoPath)
+		if err != nil {
+			return fmt.Errorf("failed to get record of tooth %v\n\t%w", toothRepoPath, err)
+		}
+
+		change.wasInstalled = true
+		change.previousVersion = currentMetadata.Version()
+		change.previousRecord = currentRecord
+
+		// Nothing changes if the tooth is kept as it is.
+		if !forceReinstall && (!upgrade || !change.version.GT(change.previousVersion)) {
+			if err := installToothArchive(t.ctx, archive, forceReinstall, upgrade, yes); err != nil {
+				return err
+			}
+
+			return t.saveStep(toothRepoPath, stepDone, nil)
+		}
+	}
+
+	hadSnapshot, err := hasSnapshot(t.ctx, toothRepoPath, change.version)
+	if err != nil {
+		return err
+	}
+
+	change.hadSnapshot = hadSnapshot
+
+	// The change is recorded before installing, so that a partly installed tooth is rolled
+	// back as well, even after a crash.
+	t.changes = append(t.changes, change)
+
+	if err := t.saveStep(toothRepoPath, stepStarted, &change); err != nil {
+		return err
+	}
+
+	if err := installToothArchive(t.ctx, archive, forceReinstall, upgrade, yes); err != nil {
+		return err
+	}
+
+	return t.saveStep(toothRepoPath, stepDone, nil)
+}
+
+// rollBack undoes the changes of the transaction after it failed or was interrupted, and
+// returns whether all changes were undone. If the operation was interrupted, it is recorded in
+// the history with the changes that cannot be undone.
+func (t *transaction) rollBack() bool {
+	isInterrupted := interrupt.IsInterrupted(t.ctx.GoContext())
+	if isInterrupted {
+		log.Warn(i18n.T("Installation interrupted. Rolling back the changes..."))
+	} else {
+		log.Warn(i18n.T("Installation failed. Rolling back the changes..."))
+	}
+
+	return t.undo(isInterrupted)
+}
+
+// recover verifies the steps of a transaction resumed from an install journal left by a crash.
+// The changes of completed steps are taken over if the teeth are installed as recorded. Steps
+// cut off halfway, and completed steps that cannot be verified, are rolled back to be done
+// again.
+func (t *transaction) recover() error {
+	for i := range t.journal.Steps {
+		step := &t.journal.Steps[i]
+
+		if step.State == stepPending {
+			continue
+		}
+
+		if step.State == stepDone {
+			isVerified, err := isStepVerified(t.ctx, *step)
+			if err != nil {
+				return err
+			}
+
+			if isVerified {
+				if step.Changed {
+					change, err := step.change()
+					if err != nil {
+						return err
+					}
+
+					t.changes = append(t.changes, change)
+				}
+
+				log.Infof(i18n.T("Verified tooth %v"), step.ToothRepoPath)
+				continue
+			}
+		}
+
+		if step.Changed {
+			change, err := step.change()
+			if err != nil {
+				return err
+			}
+
+			if err := t.rollBackChange(change); err != nil {
+				return fmt.Errorf("failed to roll back partly installed tooth %v\n\t%w", step.ToothRepoPath, err)
+			}
+
+			log.Infof(i18n.T("Rolled back partly installed tooth %v"), step.ToothRepoPath)
+		}
+
+		*step = installJournalStep{
+			ToothRepoPath:  step.ToothRepoPath,
+			Version:        step.Version,
+			ArchivePath:    step.ArchivePath,
+			ForceReinstall: step.ForceReinstall,
+			State:          stepPending,
+		}
+
+		if err := saveInstallJournal(t.ctx, t.journal); err != nil {
+			return fmt.Errorf("failed to save install journal\n\t%w", err)
+		}
+	}
+
+	return nil
+}
+
+// undo undoes the changes of the transaction in reverse order and ends it. It goes on when
+// undoing a change fails, and returns whether all changes were undone. The changes that cannot
+// be undone are kept in the transaction, and recorded in the history if the operation was
+// interrupted.
+func (t *transaction) undo(isInterrupted bool) bool {
+	// The changes are rolled back even if the operation is interrupted.
+	t.ctx = t.ctx.WithoutCancel()
+
+	changesLeft := make([]toothChange, 0)
+	for i := len(t.changes) - 1; i >= 0; i-- {
+		change := t.changes[i]
+
+		if err := t.rollBackChange(change); err != nil {
+			log.Warnf(i18n.T("Cannot roll back tooth %v\n\t%v"), change.toothRepoPath, err)
+			changesLeft = append([]toothChange{change}, changesLeft...)
+			continue
+		}
+
+		log.Infof(i18n.T("Rolled back tooth %v"), change.toothRepoPath)
+	}
+
+	t.changes = changesLeft
+
+	// The transaction ends here. Changes left are reported rather than resumed.
+	if err := removeInstallJournal(t.ctx); err != nil {
+		log.Warnf(i18n.T("Cannot remove the install journal\n\t%v"), err)
+	}
+
+	if isInterrupted {
+		if err := history.AppendInterrupted(t.ctx, t.operation, t.historyChanges()); err != nil {
+			log.Warnf(i18n.T("Cannot record the interruption in the history\n\t%v"), err)
+		}
+	}
+
+	return len(changesLeft) == 0
+}
+
+// historyChanges returns the changes made by the transaction, to be recorded in the history.
+func (t *transaction) historyChanges() []history.Change {
+	changes := make([]history.Change, 0, len(t.changes))
+	for _, change := range t.changes {
+		historyChange := history.Change{
+			ToothRepoPath: change.toothRepoPath,
+			To:            change.version.String(),
+		}
+
+		if change.wasInstalled {
+			historyChange.From = change.previousVersion.String()
+			historyChange.WasExplicit = change.previousRecord.IsExplicit
+		}
+
+		changes = append(changes, historyChange)
+	}
+
+	return changes
+}
+
+// printSummary prints the changes made by the transaction.
+func (t *transaction) printSummary() {
+	if len(t.changes) == 0 {
+		return
+	}
+
+	tableString := &strings.Builder{}
+	table := tablewriter.NewWriter(tableString)
+	table.SetHeader([]string{
+		"Tooth", "Change", "Version",
+	})
+
+	for _, change := range t.changes {
+		switch {
+		case !change.wasInstalled:
+			table.Append([]string{change.toothRepoPath, render.Style(render.AddedRole, "installed"),
+				change.version.String()})
+
+		case change.version.EQ(change.previousVersion):
+			table.Append([]string{change.toothRepoPath, render.Style(render.UnchangedRole, "reinstalled"),
+				change.version.String()})
+
+		case change.version.GT(change.previousVersion):
+			table.Append([]string{change.toothRepoPath, render.Style(render.ChangedRole, "upgraded"),
+				fmt.Sprintf("%v -> %v", change.previousVersion, change.version)})
+
+		default:
+			table.Append([]string{change.toothRepoPath, render.Style(render.DowngradedRole, "downgraded"),
+				fmt.Sprintf("%v -> %v", change.previousVersion, change.version)})
+		}
+	}
+
+	table.Render()
+
+	log.Info(render.Style(render.HeadingRole, i18n.T("Summary:")))
+	fmt.Print(tableString.String())
+}
+
+// ---------------------------------------------------------------------
+
+// saveStep records the state of the step of installing a tooth, and the change made by it if
+// any, in the install journal.
+func (t *transaction) saveStep(toothRepoPath string, state installStepState, change *toothChange) error {
+	step := t.journal.step(toothRepoPath)
+	if step == nil {
+		return nil
+	}
+
+	step.State = state
+	if change != nil {
+		step.setChange(*change)
+	}
+
+	if err := saveInstallJournal(t.ctx, t.journal); err != nil {
+		return fmt.Errorf("failed to save install journal\n\t%w", err)
+	}
+
+	return nil
+}
+
+// rollBackChange undoes a change made to a tooth.
+func (t *transaction) rollBackChange(change toothChange) error {
+	isInstalled, err := tooth.IsInstalled(t.ctx, change.toothRepoPath)
+	if err != nil {
+		return fmt.Errorf("failed to check if tooth is installed\n\t%w", err)
+	}
+
+	if isInstalled {
+		currentMetadata, err := tooth.GetMetadata(t.ctx, change.toothRepoPath)
+		if err != nil {
+			return fmt.Errorf("failed to find installed tooth metadata\n\t%w", err)
+		}
+
+		// The installation failed before the previous version was uninstalled.
+		if change.wasInstalled && currentMetadata.Version().EQ(change.previousVersion) &&
+			change.version.NE(change.previousVersion) {
+			return nil
+		}
+
+		if err := install.Uninstall(t.ctx, change.toothRepoPath); err != nil {
+			return fmt.Errorf("failed to uninstall tooth\n\t%w", err)
+		}
+	}
+
+	// The snapshot saved for a reinstalled version is kept, since it is the one to reinstate.
+	isReinstalled := change.wasInstalled && change.version.EQ(change.previousVersion)
+	if !change.hadSnapshot && !isReinstalled {
+		hasSnapshotNow, err := hasSnapshot(t.ctx, change.toothRepoPath, change.version)
+		if err != nil {
+			return err
+		}
+
+		if hasSnapshotNow {
+			if err := snapshot.Remove(t.ctx, change.toothRepoPath, change.version); err != nil {
+				return fmt.Errorf("failed to remove snapshot of %v@%v\n\t%w", change.toothRepoPath,
+					change.version, err)
+			}
+		}
+	}
+
+	if !change.wasInstalled {
+		return nil
+	}
+
+	archive, err := snapshot.Get(t.ctx, change.toothRepoPath, change.previousVersion)
+	if err != nil {
+		return fmt.Errorf("failed to get snapshot of %v@%v\n\t%w", change.toothRepoPath,
+			change.previousVersion, err)
+	}
+
+	if err := install.Install(t.ctx, archive, true); err != nil {
+		return fmt.Errorf("failed to install snapshot of %v@%v\n\t%w", change.toothRepoPath,
+			change.previousVersion, err)
+	}
+
+	if err := record.Save(t.ctx, change.previousRecord); err != nil {
+		return fmt.Errorf("failed to save record of tooth %v\n\t%w", change.toothRepoPath, err)
+	}
+
+	return nil
+}
+
+// isStepVerified returns whether a completed step of an installation is in effect, i.e. its
+// tooth is installed, at the version it installed if it changed the tooth.
+func isStepVerified(ctx *context.Context, step installJournalStep) (bool, error) {
+	isInstalled, err := tooth.IsInstalled(ctx, step.ToothRepoPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if tooth is installed\n\t%w", err)
+	}
+
+	if !isInstalled || !step.Changed {
+		return isInstalled, nil
+	}
+
+	metadata, err := tooth.GetMetadata(ctx, step.ToothRepoPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to find installed tooth metadata\n\t%w", err)
+	}
+
+	return metadata.Version().String() == step.Version, nil
+}
+
+// hasSnapshot returns whether a snapshot of a version of a tooth is kept.
+func hasSnapshot(ctx *context.Context, toothRepoPath string, version semver.Version) (bool, error) {
+	versions, err := snapshot.List(ctx, toothRepoPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to list snapshots of tooth %v\n\t%w", toothRepoPath, err)
+	}
+
+	for _, v := range versions {
+		if v.EQ(version) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,69 @@
+package cmdlipinstall
+
+import (
+	"fmt"
+
+	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	specifierpkg "github.com/lippkg/lip/internal/specifier"
+	"github.com/lippkg/lip/internal/tooth"
+	"github.com/lippkg/lip/internal/workspace"
+)
+
+// getWorkspaceSpecifiers returns specifiers of the base teeth and the teeth of the given
+// profile declared in the workspace manifest. Installed versions satisfying the declared
+// ranges are kept.
+func getWorkspaceSpecifiers(ctx *context.Context, profileName string) ([]specifierpkg.Specifier, error) {
+	manifest, err := workspace.LoadManifest(ctx.FS())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load workspace manifest\n\t%w", err)
+	}
+
+	requirements, requirementsAsStrings, err := manifest.Requirements(profileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get requirements of profile %v\n\t%w", profileName, err)
+	}
+
+	specifiers := make([]specifierpkg.Specifier, 0)
+	for toothRepoPath, versionRange := range requirements {
+		var toothVersion semver.Version
+
+		isInstalled, err := tooth.IsInstalled(ctx, toothRepoPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check if tooth is installed\n\t%w", err)
+		}
+
+		currentMetadata := tooth.Metadata{}
+		if isInstalled {
+			currentMetadata, err = tooth.GetMetadata(ctx, toothRepoPath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to find installed tooth metadata\n\t%w", err)
+			}
+		}
+
+		if isInstalled && versionRange(currentMetadata.Version()) {
+			toothVersion = currentMetadata.Version()
+
+		} else {
+			latestVersion, err := GetLatestSupportedVersion(ctx, toothRepoPath, versionRange)
+			if code, ok := errcode.GetCode(err); ok && code == errcode.PlatformUnsupported {
+				return nil, err
+			} else if err != nil {
+				return nil, errcode.Errorf(errcode.ResolveConflict, "no available version in %v found for tooth %v\n\t%w",
+					requirementsAsStrings[toothRepoPath], toothRepoPath, err)
+			}
+
+			toothVersion = latestVersion
+		}
+
+		specifier, err := specifierpkg.Parse(fmt.Sprintf("%v@%v", toothRepoPath, toothVersion))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse specifier\n\t%w", err)
+		}
+
+		specifiers = append(specifiers, specifier)
+	}
+
+	return specifiers, nil
+}
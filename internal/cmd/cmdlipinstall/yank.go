@@ -0,0 +1,29 @@
+package cmdlipinstall
+
+import (
+	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/registry"
+	log "github.com/sirupsen/logrus"
+)
+
+// warnIfYanked warns if a version fixed by the user or by an installed tooth is yanked.
+func warnIfYanked(ctx *context.Context, toothRepoPath string, version semver.Version) {
+	yankedVersions, err := registry.GetYankedVersions(ctx, toothRepoPath)
+	if err != nil {
+		log.Warnf(i18n.T("Failed to look up yanked versions of %v\n\t%v"), toothRepoPath, err)
+		return
+	}
+
+	reason, isYanked := yankedVersions[version.String()]
+	if !isYanked {
+		return
+	}
+
+	if reason != "" {
+		log.Warnf(i18n.T("%v@%v is yanked: %v"), toothRepoPath, version, reason)
+	} else {
+		log.Warnf(i18n.T("%v@%v is yanked"), toothRepoPath, version)
+	}
+}
@@ -0,0 +1,151 @@
+package cmdliplint
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/tooth"
+	log "github.com/sirupsen/logrus"
+)
+
+type FlagDict struct {
+	helpFlag   bool
+	fixFlag    bool
+	strictFlag bool
+}
+
+const helpMessage = `
+Usage:
+  lip lint [options] [<path>]
+
+Description:
+  Check a valid tooth.json, tooth.yaml or tooth.toml file for problems that may trouble the users
+  of the tooth, e.g. a missing description, license or homepage, a tooth repo path that is not
+  lowercase, dependencies without upper bounds on their versions, or files placed to the same
+  destination more than once. If no path is specified, the metadata file in the current
+  directory is checked.
+
+Options:
+  -h, --help                  Show help.
+  --fix                       Fix the problems that can be fixed and rewrite the file.
+  --strict                    Fail if any problem is left.
+`
+
+func Run(ctx *context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("lint", flag.ContinueOnError)
+
+	// Rewrite the default usage message.
+	flagSet.Usage = func() {
+		// Do nothing.
+	}
+
+	var flagDict FlagDict
+	flagSet.BoolVar(&flagDict.helpFlag, "help", false, "")
+	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
+	flagSet.BoolVar(&flagDict.fixFlag, "fix", false, "")
+	flagSet.BoolVar(&flagDict.strictFlag, "strict", false, "")
+
+	if err := flagSet.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags\n\t%w", err)
+	}
+
+	// Help flag has the highest priority.
+	if flagDict.helpFlag {
+		fmt.Print(helpMessage)
+		return nil
+	}
+
+	if flagSet.NArg() > 1 {
+		return errcode.Errorf(errcode.InvalidArgument, "expected at most one argument")
+	}
+
+	var filePath string
+	if flagSet.NArg() == 1 {
+		filePath = flagSet.Arg(0)
+	} else {
+		metadataFilePath, ok, err := tooth.FindMetadataFile(".")
+		if err != nil {
+			return fmt.Errorf("failed to find the metadata file\n\t%w", err)
+		}
+
+		if !ok {
+			return errcode.Errorf(errcode.MetadataInvalid, "no tooth.json, tooth.yaml or tooth.toml in the current directory")
+		}
+
+		filePath = metadataFilePath
+	}
+
+	format, err := tooth.ParseMetadataFormat(filePath)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read %v\n\t%w", filePath, err)
+	}
+
+	// Only valid metadata is linted. Use lip tooth validate to find what is invalid.
+	if _, err := tooth.MakeMetadataInFormat(data, format); err != nil {
+		return fmt.Errorf("failed to parse %v\n\t%w", filePath, err)
+	}
+
+	warnings, fixedData, err := tooth.LintMetadata(data, format, flagDict.fixFlag)
+	if err != nil {
+		return fmt.Errorf("failed to lint %v\n\t%w", filePath, err)
+	}
+
+	if fixedData != nil {
+		// The fixes must not break the metadata, e.g. by lowercasing the tooth repo path.
+		if _, err := tooth.MakeMetadataInFormat(fixedData, format); err != nil {
+			return fmt.Errorf("failed to fix %v\n\t%w", filePath, err)
+		}
+
+		fileInfo, err := os.Stat(filePath)
+		if err != nil {
+			return fmt.Errorf("failed to stat %v\n\t%w", filePath, err)
+		}
+
+		if err := os.WriteFile(filePath, fixedData, fileInfo.Mode().Perm()); err != nil {
+			return fmt.Errorf("failed to write %v\n\t%w", filePath, err)
+		}
+	}
+
+	// Print problems in the form of compiler diagnostics, like lip tooth validate.
+	leftCount := 0
+	for _, warning := range warnings {
+		switch {
+		case warning.Fixed:
+			fmt.Printf("%v: %v: %v (fixed)\n", filePath, warning.Field, warning.Description)
+		case warning.Fixable:
+			fmt.Printf("%v: %v: %v (fixable with --fix)\n", filePath, warning.Field, warning.Description)
+			leftCount++
+		default:
+			fmt.Printf("%v: %v: %v\n", filePath, warning.Field, warning.Description)
+			leftCount++
+		}
+	}
+
+	if fixedData != nil {
+		log.Infof(i18n.T("Fixed %v problems in %v."), len(warnings)-leftCount, filePath)
+	}
+
+	if leftCount == 0 {
+		if fixedData == nil {
+			log.Infof(i18n.T("No problems found in %v."), filePath)
+		}
+		return nil
+	}
+
+	if flagDict.strictFlag {
+		return errcode.Errorf(errcode.MetadataInvalid, "%v problems found in %v", leftCount, filePath)
+	}
+
+	log.Warnf(i18n.T("%v problems found in %v."), leftCount, filePath)
+
+	return nil
+}
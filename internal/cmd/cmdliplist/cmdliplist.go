@@ -9,6 +9,7 @@ import (
 	"github.com/lippkg/lip/internal/context"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/lippkg/lip/internal/errcode"
 	"github.com/lippkg/lip/internal/tooth"
 	"github.com/olekukonko/tablewriter"
 )
@@ -59,7 +60,7 @@ func Run(ctx *context.Context, args []string) error {
 
 	// Check if there are unexpected arguments.
 	if flagSet.NArg() != 0 {
-		return fmt.Errorf("unexpected arguments: %v", flagSet.Args())
+		return errcode.Errorf(errcode.InvalidArgument, "unexpected arguments: %v", flagSet.Args())
 	}
 
 	if flagDict.upgradableFlag {
@@ -0,0 +1,136 @@
+package cmdliplogin
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/credential"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/term"
+)
+
+type FlagDict struct {
+	helpFlag     bool
+	usernameFlag string
+}
+
+const helpMessage = `
+Usage:
+  lip login [options] <host>
+
+Description:
+  Save a credential for a host, e.g. a private registry, the GitHub API or an authenticated
+  mirror, and authenticate requests to it. The host may be given as a URL.
+
+  The secret is read from the standard input, without echo in a terminal. By default, it is a
+  token sent as a bearer token. With --username, it is a password sent with basic
+  authentication.
+
+  Credentials are kept where the CredentialStore configuration says: in a file only readable by
+  the user (file), or in the keychain of the operating system (keychain).
+
+Options:
+  -h, --help                  Show help.
+  --username <username>       Authenticate with basic authentication as the user.
+`
+
+func Run(ctx *context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("login", flag.ContinueOnError)
+
+	// Rewrite the default usage message.
+	flagSet.Usage = func() {
+		// Do nothing.
+	}
+
+	var flagDict FlagDict
+	flagSet.BoolVar(&flagDict.helpFlag, "help", false, "")
+	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
+	flagSet.StringVar(&flagDict.usernameFlag, "username", "", "")
+
+	if err := flagSet.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags\n\t%w", err)
+	}
+
+	// Help flag has the highest priority.
+	if flagDict.helpFlag {
+		fmt.Print(helpMessage)
+		return nil
+	}
+
+	if flagSet.NArg() != 1 {
+		return errcode.Errorf(errcode.InvalidArgument, "expected exactly one host")
+	}
+
+	host, err := credential.ParseHost(flagSet.Arg(0))
+	if err != nil {
+		return errcode.Errorf(errcode.InvalidArgument, "invalid host\n\t%w", err)
+	}
+
+	var c credential.Credential
+	if flagDict.usernameFlag != "" {
+		log.Info(i18n.T("What is the password?"))
+		password, err := readSecret()
+		if err != nil {
+			return err
+		}
+
+		c = credential.Credential{
+			Username: flagDict.usernameFlag,
+			Password: password,
+		}
+	} else {
+		log.Info(i18n.T("What is the token?"))
+		token, err := readSecret()
+		if err != nil {
+			return err
+		}
+
+		c = credential.Credential{
+			Token: token,
+		}
+	}
+
+	if err := credential.Set(ctx, host, c); err != nil {
+		return fmt.Errorf("failed to save credential\n\t%w", err)
+	}
+
+	log.Infof(i18n.T("Logged in to %v."), host)
+
+	return nil
+}
+
+// ---------------------------------------------------------------------
+
+// readSecret reads a line from the standard input, without echo if it is a terminal.
+func readSecret() (string, error) {
+	var secret string
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		secretBytes, err := term.ReadPassword(int(os.Stdin.Fd()))
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret\n\t%w", err)
+		}
+
+		secret = string(secretBytes)
+	} else {
+		scanner := bufio.NewScanner(os.Stdin)
+		scanner.Scan()
+		if err := scanner.Err(); err != nil {
+			return "", fmt.Errorf("failed to read secret\n\t%w", err)
+		}
+
+		secret = scanner.Text()
+	}
+
+	secret = strings.TrimSpace(secret)
+	if secret == "" {
+		return "", errcode.Errorf(errcode.InvalidArgument, "empty secret")
+	}
+
+	return secret, nil
+}
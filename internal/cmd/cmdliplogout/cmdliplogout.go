@@ -0,0 +1,73 @@
+package cmdliplogout
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/credential"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	log "github.com/sirupsen/logrus"
+)
+
+type FlagDict struct {
+	helpFlag bool
+}
+
+const helpMessage = `
+Usage:
+  lip logout [options] <host>
+
+Description:
+  Remove the credential saved for a host by lip login. The host may be given as a URL.
+
+Options:
+  -h, --help                  Show help.
+`
+
+func Run(ctx *context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("logout", flag.ContinueOnError)
+
+	// Rewrite the default usage message.
+	flagSet.Usage = func() {
+		// Do nothing.
+	}
+
+	var flagDict FlagDict
+	flagSet.BoolVar(&flagDict.helpFlag, "help", false, "")
+	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
+
+	if err := flagSet.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags\n\t%w", err)
+	}
+
+	// Help flag has the highest priority.
+	if flagDict.helpFlag {
+		fmt.Print(helpMessage)
+		return nil
+	}
+
+	if flagSet.NArg() != 1 {
+		return errcode.Errorf(errcode.InvalidArgument, "expected exactly one host")
+	}
+
+	host, err := credential.ParseHost(flagSet.Arg(0))
+	if err != nil {
+		return errcode.Errorf(errcode.InvalidArgument, "invalid host\n\t%w", err)
+	}
+
+	removed, err := credential.Remove(ctx, host)
+	if err != nil {
+		return fmt.Errorf("failed to remove credential\n\t%w", err)
+	}
+
+	if !removed {
+		log.Infof(i18n.T("Not logged in to %v."), host)
+		return nil
+	}
+
+	log.Infof(i18n.T("Logged out of %v."), host)
+
+	return nil
+}
@@ -0,0 +1,227 @@
+package cmdlipmigrate
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/manifest"
+	"github.com/lippkg/lip/internal/path"
+	"github.com/lippkg/lip/internal/record"
+	"github.com/lippkg/lip/internal/registry"
+	"github.com/lippkg/lip/internal/statedb"
+	"github.com/lippkg/lip/internal/tooth"
+	log "github.com/sirupsen/logrus"
+)
+
+type FlagDict struct {
+	helpFlag bool
+}
+
+const helpMessage = `
+Usage:
+  lip migrate [options]
+
+Description:
+  Upgrade what older versions of lip left on disk to the current layout: metadata, records and
+  manifests kept in separate files, metadata of installed teeth in older tooth.json formats, and
+  the registry index cached under its old name. Every migrated record is verified, and the
+  files replaced are kept in .lip/backups. Running it again does nothing.
+
+Options:
+  -h, --help                  Show help.
+`
+
+func Run(ctx *context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("migrate", flag.ContinueOnError)
+
+	// Rewrite the default usage message.
+	flagSet.Usage = func() {
+		// Do nothing.
+	}
+
+	var flagDict FlagDict
+	flagSet.BoolVar(&flagDict.helpFlag, "help", false, "")
+	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
+
+	if err := flagSet.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags\n\t%w", err)
+	}
+
+	// Help flag has the highest priority.
+	if flagDict.helpFlag {
+		fmt.Print(helpMessage)
+		return nil
+	}
+
+	// Check if there are unexpected arguments.
+	if flagSet.NArg() != 0 {
+		return errcode.Errorf(errcode.InvalidArgument, "unexpected arguments: %v", flagSet.Args())
+	}
+
+	backupDir, err := ctx.BackupDir()
+	if err != nil {
+		return fmt.Errorf("failed to get backup directory\n\t%w", err)
+	}
+
+	backupDir = backupDir.Join(path.MustParse("migrate-" + ctx.Clock().Now().Format("20060102-150405")))
+
+	// 1. Migrate separate files of installed teeth to the state database.
+
+	stateFileCount, err := statedb.Migrate(ctx, backupDir)
+	if err != nil {
+		return err
+	}
+
+	// 2. Migrate metadata of installed teeth to the current tooth.json format.
+
+	metadataCount, err := migrateMetadata(ctx, backupDir)
+	if err != nil {
+		return err
+	}
+
+	if metadataCount != 0 {
+		log.Infof(i18n.T("Migrated metadata of %v teeth to the current tooth.json format."), metadataCount)
+	}
+
+	// 3. Migrate the cached registry index to the current cache layout.
+
+	isIndexMigrated, err := registry.MigrateIndexCache(ctx, backupDir)
+	if err != nil {
+		return err
+	}
+
+	if isIndexMigrated {
+		log.Info(i18n.T("Migrated the cached registry index."))
+	}
+
+	// 4. Verify the installed state as read by lip.
+
+	if err := verifyInstalledState(ctx); err != nil {
+		return err
+	}
+
+	if stateFileCount == 0 && metadataCount == 0 && !isIndexMigrated {
+		log.Info(i18n.T("Nothing to migrate."))
+		return nil
+	}
+
+	log.Infof(i18n.T("Done. Backups are kept in %v."), backupDir.LocalString())
+
+	return nil
+}
+
+// ---------------------------------------------------------------------
+
+// migrateMetadata rewrites metadata of installed teeth in older tooth.json formats in the
+// current format, so that it is no longer migrated each time it is loaded. The old metadata is
+// backed up, and the new one is verified to describe the same tooth and version before it is
+// saved. It returns the number of teeth migrated.
+func migrateMetadata(ctx *context.Context, backupDir path.Path) (int, error) {
+	db, err := statedb.Open(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open state database\n\t%w", err)
+	}
+
+	count := 0
+
+	for _, installedTooth := range db.List() {
+		if installedTooth.Metadata == nil {
+			continue
+		}
+
+		isCurrent, err := tooth.IsCurrentFormat(installedTooth.Metadata)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get format version of metadata of %v\n\t%w",
+				installedTooth.ToothRepoPath, err)
+		}
+
+		if isCurrent {
+			continue
+		}
+
+		metadata, err := tooth.MakeMetadata(installedTooth.Metadata)
+		if err != nil {
+			return 0, fmt.Errorf("failed to migrate metadata of %v\n\t%w", installedTooth.ToothRepoPath, err)
+		}
+
+		jsonBytes, err := metadata.MarshalJSON()
+		if err != nil {
+			return 0, fmt.Errorf("failed to marshal metadata of %v\n\t%w", installedTooth.ToothRepoPath, err)
+		}
+
+		if err := verifyMigratedMetadata(metadata, jsonBytes); err != nil {
+			return 0, fmt.Errorf("failed to verify migrated metadata of %v\n\t%w", installedTooth.ToothRepoPath, err)
+		}
+
+		metadataBackupDir := backupDir.Join(path.MustParse("metadata"))
+		if err := ctx.FS().MkdirAll(metadataBackupDir.LocalString(), 0755); err != nil {
+			return 0, fmt.Errorf("failed to create backup directory %v\n\t%w", metadataBackupDir.LocalString(), err)
+		}
+
+		backupFilePath := metadataBackupDir.Join(path.MustParse(url.QueryEscape(installedTooth.ToothRepoPath) + ".json"))
+		if err := ctx.FS().WriteFile(backupFilePath.LocalString(), installedTooth.Metadata, 0644); err != nil {
+			return 0, fmt.Errorf("failed to write backup %v\n\t%w", backupFilePath.LocalString(), err)
+		}
+
+		err = statedb.Update(ctx, installedTooth.ToothRepoPath, func(t *statedb.Tooth) {
+			t.Version = metadata.Version().String()
+			t.Metadata = jsonBytes
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to save metadata of %v\n\t%w", installedTooth.ToothRepoPath, err)
+		}
+
+		count++
+	}
+
+	return count, nil
+}
+
+// verifyMigratedMetadata checks that migrated metadata is of the current format and loads as
+// the tooth and version it was migrated from.
+func verifyMigratedMetadata(metadata tooth.Metadata, jsonBytes []byte) error {
+	isCurrent, err := tooth.IsCurrentFormat(jsonBytes)
+	if err != nil {
+		return err
+	} else if !isCurrent {
+		return fmt.Errorf("metadata is not of the current format")
+	}
+
+	migratedMetadata, err := tooth.MakeMetadata(jsonBytes)
+	if err != nil {
+		return err
+	}
+
+	if migratedMetadata.ToothRepoPath() != metadata.ToothRepoPath() ||
+		!migratedMetadata.Version().Equals(metadata.Version()) {
+		return fmt.Errorf("metadata is of %v@%v instead of %v@%v", migratedMetadata.ToothRepoPath(),
+			migratedMetadata.Version(), metadata.ToothRepoPath(), metadata.Version())
+	}
+
+	return nil
+}
+
+// verifyInstalledState checks that the metadata, record and manifest of every installed tooth
+// load.
+func verifyInstalledState(ctx *context.Context) error {
+	metadataList, err := tooth.GetAllMetadata(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to verify metadata of installed teeth\n\t%w", err)
+	}
+
+	for _, metadata := range metadataList {
+		if _, err := record.Get(ctx, metadata.ToothRepoPath()); err != nil {
+			return fmt.Errorf("failed to verify record of %v\n\t%w", metadata.ToothRepoPath(), err)
+		}
+
+		if _, _, err := manifest.Get(ctx, metadata.ToothRepoPath()); err != nil {
+			return fmt.Errorf("failed to verify manifest of %v\n\t%w", metadata.ToothRepoPath(), err)
+		}
+	}
+
+	return nil
+}
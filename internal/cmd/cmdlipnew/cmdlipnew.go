@@ -0,0 +1,189 @@
+package cmdlipnew
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/cmd/cmdlipinstall"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/tooth"
+	log "github.com/sirupsen/logrus"
+)
+
+type FlagDict struct {
+	helpFlag     bool
+	templateFlag string
+	toothFlag    string
+	nameFlag     string
+	authorFlag   string
+}
+
+const helpMessage = `
+Usage:
+  lip new [options] <directory>
+
+Description:
+  Create a new tooth in a new directory from a template tooth. The files of the template are
+  copied with {{tooth}}, {{name}} and {{author}} in their contents and paths replaced, and the
+  metadata file is set to the new tooth repo path, name and author at version 0.0.0.
+
+Options:
+  -h, --help                  Show help.
+  --template, --from <tooth repo path[@version]>
+                              The template tooth. The latest version is used if no version is
+                              specified.
+  --tooth <tooth repo path>   The tooth repo path of the new tooth. Asked if not specified.
+  --name <name>               The name of the new tooth. Defaults to the name of the directory.
+  --author <author>           The author of the new tooth. Asked if not specified.
+`
+
+func Run(ctx *context.Context, args []string) error {
+
+	flagSet := flag.NewFlagSet("new", flag.ContinueOnError)
+
+	// Rewrite the default usage message.
+	flagSet.Usage = func() {
+		// Do nothing.
+	}
+
+	var flagDict FlagDict
+	flagSet.BoolVar(&flagDict.helpFlag, "help", false, "")
+	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
+	flagSet.StringVar(&flagDict.templateFlag, "template", "", "")
+	flagSet.StringVar(&flagDict.templateFlag, "from", "", "")
+	flagSet.StringVar(&flagDict.toothFlag, "tooth", "", "")
+	flagSet.StringVar(&flagDict.nameFlag, "name", "", "")
+	flagSet.StringVar(&flagDict.authorFlag, "author", "", "")
+	err := flagSet.Parse(args)
+	if err != nil {
+		return fmt.Errorf("failed to parse flags\n\t%w", err)
+	}
+
+	// Help flag has the highest priority.
+	if flagDict.helpFlag {
+		fmt.Print(helpMessage)
+		return nil
+	}
+
+	// Exactly one argument is required.
+	if flagSet.NArg() != 1 {
+		return errcode.Errorf(errcode.InvalidArgument, "expected exactly one argument")
+	}
+
+	if flagDict.templateFlag == "" {
+		return errcode.Errorf(errcode.InvalidArgument, "a template is required, e.g. --template %v",
+			"github.com/tooth-hub/template")
+	}
+
+	dir := flagSet.Arg(0)
+	if _, err := os.Lstat(dir); err == nil {
+		return errcode.Errorf(errcode.InvalidArgument, "%v already exists", dir)
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to get info of %v\n\t%w", dir, err)
+	}
+
+	variables, err := askVariables(flagDict, dir)
+	if err != nil {
+		return err
+	}
+
+	archive, err := downloadTemplate(ctx, flagDict.templateFlag)
+	if err != nil {
+		return fmt.Errorf("failed to download template %v\n\t%w", flagDict.templateFlag, err)
+	}
+
+	log.Infof(i18n.T("Creating %v from template %v@%v..."), variables.tooth, archive.Metadata().ToothRepoPath(),
+		archive.Metadata().Version())
+
+	if err := instantiateTemplate(ctx, archive, dir, variables); err != nil {
+		// Nothing was in the directory before, so nothing is lost.
+		if removeErr := os.RemoveAll(dir); removeErr != nil {
+			log.Warnf(i18n.T("Failed to remove %v: %v"), dir, removeErr)
+		}
+
+		return fmt.Errorf("failed to create tooth from template\n\t%w", err)
+	}
+
+	log.Infof(i18n.T("Created %v in %v"), variables.tooth, dir)
+
+	return nil
+}
+
+// ---------------------------------------------------------------------
+
+// askVariables returns the variables of the new tooth given by the flags, and asks for the
+// missing ones.
+func askVariables(flagDict FlagDict, dir string) (templateVariables, error) {
+	variables := templateVariables{
+		tooth:  flagDict.toothFlag,
+		name:   flagDict.nameFlag,
+		author: flagDict.authorFlag,
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+
+	if variables.tooth == "" {
+		log.Info(i18n.T("What is the tooth repo path? (e.g. github.com/tooth-hub/llbds3)"))
+		scanner.Scan()
+		variables.tooth = strings.TrimSpace(scanner.Text())
+	}
+
+	if !tooth.IsValidToothRepoPath(variables.tooth) {
+		return templateVariables{}, errcode.Errorf(errcode.InvalidArgument, "invalid tooth repo path %v",
+			variables.tooth)
+	}
+
+	if variables.name == "" {
+		absDir, err := filepath.Abs(dir)
+		if err != nil {
+			return templateVariables{}, fmt.Errorf("failed to get absolute path of %v\n\t%w", dir, err)
+		}
+
+		variables.name = filepath.Base(absDir)
+	}
+
+	if variables.author == "" {
+		log.Info(i18n.T("What is the author? Please input your GitHub username."))
+		scanner.Scan()
+		variables.author = strings.TrimSpace(scanner.Text())
+	}
+
+	return variables, nil
+}
+
+// downloadTemplate downloads the template tooth, given as a tooth repo path with an optional
+// version, e.g. github.com/tooth-hub/template@1.0.0.
+func downloadTemplate(ctx *context.Context, templateSpecifier string) (tooth.Archive, error) {
+	toothRepoPath, versionString, isVersionSpecified := strings.Cut(templateSpecifier, "@")
+
+	if !tooth.IsValidToothRepoPath(toothRepoPath) {
+		return tooth.Archive{}, errcode.Errorf(errcode.InvalidArgument, "invalid tooth repo path %v", toothRepoPath)
+	}
+
+	var version semver.Version
+	if isVersionSpecified {
+		parsedVersion, err := semver.Parse(versionString)
+		if err != nil {
+			return tooth.Archive{}, errcode.Errorf(errcode.InvalidArgument, "invalid version %v", versionString)
+		}
+
+		version = parsedVersion
+
+	} else {
+		latestVersion, err := tooth.GetLatestVersion(ctx, toothRepoPath)
+		if err != nil {
+			return tooth.Archive{}, fmt.Errorf("failed to get latest version of %v\n\t%w", toothRepoPath, err)
+		}
+
+		version = latestVersion
+	}
+
+	return cmdlipinstall.DownloadToothArchiveWithoutAssets(ctx, toothRepoPath, version)
+}
@@ -0,0 +1,177 @@
+package cmdlipnew
+
+import (
+	gozip "archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/path"
+	"github.com/lippkg/lip/internal/tooth"
+	"github.com/lippkg/lip/internal/zip"
+	log "github.com/sirupsen/logrus"
+)
+
+// templateVariables are the values replacing the placeholders in the files of a template.
+type templateVariables struct {
+	tooth  string
+	name   string
+	author string
+}
+
+// newToothVersion is the version the new tooth starts at.
+const newToothVersion = "0.0.0"
+
+// replace replaces the placeholders in a string with the values of the variables.
+func (v templateVariables) replace(s string) string {
+	return strings.NewReplacer(
+		"{{tooth}}", v.tooth,
+		"{{name}}", v.name,
+		"{{author}}", v.author,
+	).Replace(s)
+}
+
+// instantiateTemplate copies the files of the tooth in a template archive into a new directory,
+// with the placeholders in text files and in paths replaced, and sets the metadata file to the
+// new tooth. The template archive is read from the file system of the context, and the new
+// tooth is written to the file system of the operating system like other tooth sources.
+func instantiateTemplate(ctx *context.Context, archive tooth.Archive, dir string, variables templateVariables) error {
+	debugLogger := ctx.Logger().WithFields(log.Fields{
+		"package": "cmdlipnew",
+		"method":  "instantiateTemplate",
+	})
+
+	rootDir, err := archive.RootDir()
+	if err != nil {
+		return fmt.Errorf("failed to get root directory of template\n\t%w", err)
+	}
+
+	r, err := zip.OpenReader(ctx.FS(), archive.FilePath().LocalString())
+	if err != nil {
+		return fmt.Errorf("failed to open zip reader %v\n\t%w", archive.FilePath().LocalString(), err)
+	}
+	defer r.Close()
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %v\n\t%w", dir, err)
+	}
+
+	for _, file := range r.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+
+		filePath, err := path.ParseRelative(file.Name)
+		if err != nil {
+			return fmt.Errorf("failed to parse file path %v\n\t%w", file.Name, err)
+		}
+
+		if !rootDir.IsAncestorOf(filePath) {
+			continue
+		}
+
+		relPathString := variables.replace(filePath.TrimPrefix(rootDir).String())
+		relPath, err := path.Parse(relPathString)
+		if err != nil || relPath.IsEmpty() || strings.HasPrefix(relPathString, "/") {
+			return fmt.Errorf("invalid file path %v in template", relPathString)
+		}
+
+		destPath := filepath.Join(dir, relPath.LocalString())
+		if err := copyTemplateFile(file, destPath, variables); err != nil {
+			return err
+		}
+
+		debugLogger.Debugf("Copied %v to %v", file.Name, destPath)
+	}
+
+	if err := setMetadataFile(dir, variables); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ---------------------------------------------------------------------
+
+// copyTemplateFile copies a file of a template to a path, with the placeholders replaced if it
+// is a text file. Executable files are kept executable.
+func copyTemplateFile(file *gozip.File, destPath string, variables templateVariables) error {
+	rc, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open %v in template\n\t%w", file.Name, err)
+	}
+	defer rc.Close()
+
+	content, err := io.ReadAll(rc)
+	if err != nil {
+		return fmt.Errorf("failed to read %v in template\n\t%w", file.Name, err)
+	}
+
+	// Binary files are copied as is.
+	if utf8.Valid(content) && !bytes.ContainsRune(content, 0) {
+		content = []byte(variables.replace(string(content)))
+	}
+
+	if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory %v\n\t%w", filepath.Dir(destPath), err)
+	}
+
+	mode := os.FileMode(0644)
+	if file.Mode()&0111 != 0 {
+		mode = 0755
+	}
+
+	if err := os.WriteFile(destPath, content, mode); err != nil {
+		return fmt.Errorf("failed to write %v\n\t%w", destPath, err)
+	}
+
+	return nil
+}
+
+// setMetadataFile sets the tooth repo path, the version, the name and the author in the
+// metadata file of the new tooth, keeping its format, and checks that it is valid.
+func setMetadataFile(dir string, variables templateVariables) error {
+	metadataFilePath, ok, err := tooth.FindMetadataFile(dir)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return fmt.Errorf("no metadata file in template")
+	}
+
+	format, err := tooth.ParseMetadataFormat(metadataFilePath)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(metadataFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to read %v\n\t%w", metadataFilePath, err)
+	}
+
+	data, err = tooth.SetMetadataFields(data, format, map[string]string{
+		"tooth":       variables.tooth,
+		"version":     newToothVersion,
+		"info.name":   variables.name,
+		"info.author": variables.author,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set fields of %v\n\t%w", metadataFilePath, err)
+	}
+
+	if err := os.WriteFile(metadataFilePath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %v\n\t%w", metadataFilePath, err)
+	}
+
+	if _, err := tooth.ReadMetadataFile(metadataFilePath); err != nil {
+		return fmt.Errorf("invalid metadata file of new tooth\n\t%w", err)
+	}
+
+	return nil
+}
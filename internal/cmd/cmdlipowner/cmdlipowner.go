@@ -0,0 +1,261 @@
+package cmdlipowner
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/statedb"
+	"github.com/lippkg/lip/internal/tooth"
+	"github.com/olekukonko/tablewriter"
+)
+
+type FlagDict struct {
+	helpFlag  bool
+	filesFlag bool
+	jsonFlag  bool
+}
+
+const helpMessage = `
+Usage:
+  lip owner [options] <path>...
+  lip owner [options] --files <tooth repository path>
+
+Description:
+  Show which installed tooth placed each file. Paths are relative to the workspace directory,
+  or absolute paths in it. A directory shows every file under it placed by a tooth, e.g. to
+  find out which teeth share a plugin directory. With --files, list the files placed by a
+  tooth instead.
+
+Options:
+  -h, --help                  Show help.
+  --files                     List the files owned by a tooth.
+  --json                      Output in JSON format.
+`
+
+// ownership is a file and the tooth owning it.
+type ownership struct {
+	Path string `json:"path"`
+	// Tooth is the repository path of the tooth owning the file, or empty if no tooth owns it.
+	Tooth string `json:"tooth"`
+}
+
+func Run(ctx *context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("owner", flag.ContinueOnError)
+
+	// Rewrite the default usage message.
+	flagSet.Usage = func() {
+		// Do nothing.
+	}
+
+	var flagDict FlagDict
+	flagSet.BoolVar(&flagDict.helpFlag, "help", false, "")
+	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
+	flagSet.BoolVar(&flagDict.filesFlag, "files", false, "")
+	flagSet.BoolVar(&flagDict.jsonFlag, "json", false, "")
+
+	if err := flagSet.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags\n\t%w", err)
+	}
+
+	// Help flag has the highest priority.
+	if flagDict.helpFlag {
+		fmt.Print(helpMessage)
+		return nil
+	}
+
+	if flagSet.NArg() == 0 {
+		return errcode.Errorf(errcode.InvalidArgument, "no path specified")
+	}
+
+	if flagDict.filesFlag && flagSet.NArg() != 1 {
+		return errcode.Errorf(errcode.InvalidArgument, "--files takes exactly one tooth repository path")
+	}
+
+	db, err := statedb.Open(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open state database\n\t%w", err)
+	}
+
+	legacyOwners, err := getLegacyOwners(db)
+	if err != nil {
+		return err
+	}
+
+	var ownerships []ownership
+	if flagDict.filesFlag {
+		ownerships, err = listOwnedFiles(db, legacyOwners, flagSet.Arg(0))
+	} else {
+		ownerships, err = findOwners(db, legacyOwners, flagSet.Args())
+	}
+	if err != nil {
+		return err
+	}
+
+	if flagDict.jsonFlag {
+		jsonBytes, err := json.Marshal(ownerships)
+		if err != nil {
+			return fmt.Errorf("failed to marshal JSON\n\t%w", err)
+		}
+
+		fmt.Print(string(jsonBytes))
+
+		return nil
+	}
+
+	tableString := &strings.Builder{}
+	table := tablewriter.NewWriter(tableString)
+	table.SetHeader([]string{"File", "Tooth"})
+
+	for _, item := range ownerships {
+		toothRepoPath := item.Tooth
+		if toothRepoPath == "" {
+			toothRepoPath = "-"
+		}
+
+		table.Append([]string{item.Path, toothRepoPath})
+	}
+
+	table.Render()
+
+	fmt.Print(tableString.String())
+
+	return nil
+}
+
+// ---------------------------------------------------------------------
+
+// findOwners finds the teeth owning files, or the files under directories.
+func findOwners(db *statedb.DB, legacyOwners map[string]string, args []string) ([]ownership, error) {
+	ownerships := make([]ownership, 0)
+
+	for _, arg := range args {
+		filePath, err := toWorkspacePath(arg)
+		if err != nil {
+			return nil, err
+		}
+
+		if toothRepoPath, ok := db.FindOwner(filePath); ok {
+			ownerships = append(ownerships, ownership{Path: filePath, Tooth: toothRepoPath})
+			continue
+		}
+
+		if toothRepoPath, ok := legacyOwners[filePath]; ok {
+			ownerships = append(ownerships, ownership{Path: filePath, Tooth: toothRepoPath})
+			continue
+		}
+
+		// Not a file owned by a tooth, so it may be a directory.
+		owners := db.FindOwnersInDir(filePath)
+		for legacyFilePath, toothRepoPath := range legacyOwners {
+			if filePath == "." || strings.HasPrefix(legacyFilePath, filePath+"/") {
+				owners[legacyFilePath] = toothRepoPath
+			}
+		}
+
+		if len(owners) == 0 {
+			ownerships = append(ownerships, ownership{Path: filePath})
+			continue
+		}
+
+		ownedFilePaths := make([]string, 0, len(owners))
+		for ownedFilePath := range owners {
+			ownedFilePaths = append(ownedFilePaths, ownedFilePath)
+		}
+		sort.Strings(ownedFilePaths)
+
+		for _, ownedFilePath := range ownedFilePaths {
+			ownerships = append(ownerships, ownership{Path: ownedFilePath, Tooth: owners[ownedFilePath]})
+		}
+	}
+
+	return ownerships, nil
+}
+
+// listOwnedFiles lists the files owned by an installed tooth.
+func listOwnedFiles(db *statedb.DB, legacyOwners map[string]string, toothRepoPath string) ([]ownership, error) {
+	installedTooth, ok := db.Get(toothRepoPath)
+	if !ok || installedTooth.Metadata == nil {
+		return nil, errcode.Errorf(errcode.NotInstalled, "tooth %v is not installed", toothRepoPath)
+	}
+
+	filePaths := make([]string, 0)
+	if installedTooth.Manifest != nil {
+		filePaths = append(filePaths, installedTooth.Files...)
+	} else {
+		for filePath, owner := range legacyOwners {
+			if owner == toothRepoPath {
+				filePaths = append(filePaths, filePath)
+			}
+		}
+	}
+
+	sort.Strings(filePaths)
+
+	ownerships := make([]ownership, 0, len(filePaths))
+	for _, filePath := range filePaths {
+		ownerships = append(ownerships, ownership{Path: filePath, Tooth: toothRepoPath})
+	}
+
+	return ownerships, nil
+}
+
+// getLegacyOwners returns the files placed by teeth installed by older versions of lip, which
+// have no manifest, as declared by their metadata.
+func getLegacyOwners(db *statedb.DB) (map[string]string, error) {
+	owners := make(map[string]string)
+
+	for _, installedTooth := range db.List() {
+		if installedTooth.Metadata == nil || installedTooth.Manifest != nil {
+			continue
+		}
+
+		metadata, err := tooth.MakeMetadata(installedTooth.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse metadata of %v\n\t%w", installedTooth.ToothRepoPath, err)
+		}
+
+		files, err := metadata.Files()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get files of %v\n\t%w", installedTooth.ToothRepoPath, err)
+		}
+
+		for _, place := range files.Place {
+			owners[place.Dest.String()] = installedTooth.ToothRepoPath
+		}
+	}
+
+	return owners, nil
+}
+
+// toWorkspacePath converts a path given on the command line to a slash-separated path relative
+// to the workspace directory, which is the working directory.
+func toWorkspacePath(arg string) (string, error) {
+	filePath := filepath.Clean(arg)
+
+	if filepath.IsAbs(filePath) {
+		workspaceDir, err := os.Getwd()
+		if err != nil {
+			return "", fmt.Errorf("failed to get workspace directory\n\t%w", err)
+		}
+
+		relPath, err := filepath.Rel(workspaceDir, filePath)
+		if err != nil {
+			return "", errcode.Errorf(errcode.InvalidArgument, "%v is not in the workspace", arg)
+		}
+
+		filePath = relPath
+	}
+
+	if filePath == ".." || strings.HasPrefix(filePath, ".."+string(filepath.Separator)) {
+		return "", errcode.Errorf(errcode.InvalidArgument, "%v is not in the workspace", arg)
+	}
+
+	return filepath.ToSlash(filePath), nil
+}
@@ -0,0 +1,190 @@
+package cmdlipprune
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/install"
+	"github.com/lippkg/lip/internal/service"
+	"github.com/lippkg/lip/internal/statedb"
+	log "github.com/sirupsen/logrus"
+)
+
+type FlagDict struct {
+	helpFlag   bool
+	yesFlag    bool
+	dryRunFlag bool
+}
+
+const helpMessage = `
+Usage:
+  lip prune [options]
+
+Description:
+  Remove what lip no longer needs:
+
+  - metadata of installed teeth whose files no longer exist.
+  - records and manifests of teeth that are not installed.
+  - cached archives of teeth that are not installed.
+  - temporary files left by aborted operations.
+
+Options:
+  -h, --help                  Show help.
+  -y, --yes                   Skip confirmation.
+  --dry-run                   Only list what would be removed.
+`
+
+func Run(ctx *context.Context, args []string) error {
+
+	flagSet := flag.NewFlagSet("prune", flag.ContinueOnError)
+
+	// Rewrite the default usage message.
+	flagSet.Usage = func() {
+		// Do nothing.
+	}
+
+	var flagDict FlagDict
+	flagSet.BoolVar(&flagDict.helpFlag, "help", false, "")
+	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
+	flagSet.BoolVar(&flagDict.yesFlag, "yes", false, "")
+	flagSet.BoolVar(&flagDict.yesFlag, "y", false, "")
+	flagSet.BoolVar(&flagDict.dryRunFlag, "dry-run", false, "")
+	err := flagSet.Parse(args)
+	if err != nil {
+		return fmt.Errorf("failed to parse flags\n\t%w", err)
+	}
+
+	// Help flag has the highest priority.
+	if flagDict.helpFlag {
+		fmt.Print(helpMessage)
+		return nil
+	}
+
+	// Check if there are unexpected arguments.
+	if flagSet.NArg() != 0 {
+		return errcode.Errorf(errcode.InvalidArgument, "unexpected arguments: %v", flagSet.Args())
+	}
+
+	// 1. Find what to remove.
+
+	items, err := findStaleItems(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to find what to prune\n\t%w", err)
+	}
+
+	if len(items) == 0 {
+		log.Info(i18n.T("Nothing to prune."))
+		return nil
+	}
+
+	printStaleItems(items)
+
+	if flagDict.dryRunFlag {
+		return nil
+	}
+
+	// 2. Prompt for confirmation.
+
+	if !flagDict.yesFlag {
+		if ok, err := ctx.Prompter().Confirm(i18n.T("Do you want to continue? [y/N]"), false); err != nil {
+			return err
+		} else if !ok {
+			return errcode.Errorf(errcode.Aborted, "aborted")
+		}
+	}
+
+	// 3. Remove them.
+
+	for _, item := range items {
+		if err := removeStaleItem(ctx, item); err != nil {
+			return err
+		}
+	}
+
+	log.Infof(i18n.T("Pruned %v items."), len(items))
+
+	return nil
+}
+
+// ---------------------------------------------------------------------
+
+// printStaleItems lists the items to remove by their kinds.
+func printStaleItems(items []staleItem) {
+	headings := map[staleItemKind]string{
+		staleToothKind:    i18n.T("Installed teeth whose files no longer exist:"),
+		orphanedStateKind: i18n.T("Records and manifests of teeth not installed:"),
+		staleCacheKind:    i18n.T("Cached archives of teeth not installed:"),
+		tempLeftoverKind:  i18n.T("Temporary files left by aborted operations:"),
+	}
+
+	for _, kind := range []staleItemKind{staleToothKind, orphanedStateKind, staleCacheKind, tempLeftoverKind} {
+		isHeadingPrinted := false
+		for _, item := range items {
+			if item.kind != kind {
+				continue
+			}
+
+			if !isHeadingPrinted {
+				log.Info(headings[kind])
+				isHeadingPrinted = true
+			}
+
+			if item.kind == staleToothKind || item.kind == orphanedStateKind {
+				log.Infof("  %v", item.toothRepoPath)
+			} else {
+				log.Infof("  %v", item.filePath)
+			}
+		}
+	}
+}
+
+// removeStaleItem removes an item. Stale teeth are forgotten together with their OS services
+// and versions installed side by side.
+func removeStaleItem(ctx *context.Context, item staleItem) error {
+	debugLogger := ctx.Logger().WithFields(log.Fields{
+		"package": "cmdlipprune",
+		"method":  "removeStaleItem",
+	})
+
+	if item.kind == orphanedStateKind {
+		if err := statedb.Delete(ctx, item.toothRepoPath); err != nil {
+			return fmt.Errorf("failed to delete state of %v\n\t%w", item.toothRepoPath, err)
+		}
+
+		debugLogger.Debugf("Deleted state of %v", item.toothRepoPath)
+
+		return nil
+	}
+
+	if item.kind != staleToothKind {
+		if err := os.RemoveAll(item.filePath); err != nil {
+			return fmt.Errorf("failed to remove %v\n\t%w", item.filePath, err)
+		}
+
+		debugLogger.Debugf("Removed %v", item.filePath)
+
+		return nil
+	}
+
+	if serviceName, err := service.Unregister(ctx, item.toothRepoPath); err != nil {
+		return fmt.Errorf("failed to unregister OS service of %v\n\t%w", item.toothRepoPath, err)
+	} else if serviceName != "" {
+		log.Infof(i18n.T("Removed OS service %v"), serviceName)
+	}
+
+	if err := install.Forget(ctx, item.toothRepoPath); err != nil {
+		return fmt.Errorf("failed to forget tooth %v\n\t%w", item.toothRepoPath, err)
+	}
+
+	if err := install.RemoveVersions(ctx, item.toothRepoPath); err != nil {
+		return fmt.Errorf("failed to remove versions of tooth %v\n\t%w", item.toothRepoPath, err)
+	}
+
+	debugLogger.Debugf("Forgot %v", item.toothRepoPath)
+
+	return nil
+}
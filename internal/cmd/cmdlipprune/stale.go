@@ -0,0 +1,334 @@
+package cmdlipprune
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lippkg/lip/internal/cmd/cmdlipinstall"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/manifest"
+	"github.com/lippkg/lip/internal/path"
+	"github.com/lippkg/lip/internal/statedb"
+	"github.com/lippkg/lip/internal/tooth"
+	log "github.com/sirupsen/logrus"
+)
+
+// staleItemKind is why an item is removed.
+type staleItemKind int
+
+const (
+	// staleToothKind is an installed tooth whose files no longer exist.
+	staleToothKind staleItemKind = iota
+	// orphanedStateKind is the record or the manifest of a tooth not installed, kept in the state
+	// database.
+	orphanedStateKind
+	// staleCacheKind is a cached tooth archive or asset archive of a tooth not installed.
+	staleCacheKind
+	// tempLeftoverKind is a temporary file or directory left by an aborted operation.
+	tempLeftoverKind
+)
+
+// tempLeftoverMinAge is how old temporary files must be to be removed, so that those of
+// operations still running are kept.
+const tempLeftoverMinAge = time.Hour
+
+// staleItem is something lip prune removes.
+type staleItem struct {
+	kind staleItemKind
+	// toothRepoPath is the stale tooth, or the tooth of orphaned state. Empty for other kinds.
+	toothRepoPath string
+	// filePath is the file or directory to remove. Empty for stale teeth and orphaned state.
+	filePath string
+}
+
+// findStaleItems finds all items to remove, ordered by their kinds.
+func findStaleItems(ctx *context.Context) ([]staleItem, error) {
+	metadataList, err := tooth.GetAllMetadata(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list all installed teeth\n\t%w", err)
+	}
+
+	items := make([]staleItem, 0)
+
+	staleTeeth, err := findStaleTeeth(ctx, metadataList)
+	if err != nil {
+		return nil, err
+	}
+	items = append(items, staleTeeth...)
+
+	orphanedState, err := findOrphanedState(ctx)
+	if err != nil {
+		return nil, err
+	}
+	items = append(items, orphanedState...)
+
+	staleCache, err := findStaleCache(ctx, metadataList)
+	if err != nil {
+		return nil, err
+	}
+	items = append(items, staleCache...)
+
+	tempLeftovers, err := findTempLeftovers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	items = append(items, tempLeftovers...)
+
+	return items, nil
+}
+
+// ---------------------------------------------------------------------
+
+// findStaleTeeth finds installed teeth none of whose placed files exist. Teeth placing no files
+// are never stale.
+func findStaleTeeth(ctx *context.Context, metadataList []tooth.Metadata) ([]staleItem, error) {
+	items := make([]staleItem, 0)
+
+	for _, metadata := range metadataList {
+		filePaths := make([]string, 0)
+
+		toothManifest, ok, err := manifest.Get(ctx, metadata.ToothRepoPath())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get manifest of %v\n\t%w", metadata.ToothRepoPath(), err)
+		}
+
+		if ok {
+			for _, file := range toothManifest.Files {
+				filePaths = append(filePaths, file.Path)
+			}
+
+		} else {
+			// Teeth installed by older versions of lip have no manifest.
+			files, err := metadata.Files()
+			if err != nil {
+				return nil, fmt.Errorf("failed to get files of %v\n\t%w", metadata.ToothRepoPath(), err)
+			}
+
+			for _, place := range files.Place {
+				filePaths = append(filePaths, place.Dest.String())
+			}
+		}
+
+		if len(filePaths) == 0 {
+			continue
+		}
+
+		isStale := true
+		for _, filePath := range filePaths {
+			// The workspace is the working directory, so placed files are relative to it.
+			if _, err := os.Lstat(filepath.FromSlash(filePath)); err == nil {
+				isStale = false
+				break
+			}
+		}
+
+		if isStale {
+			items = append(items, staleItem{kind: staleToothKind, toothRepoPath: metadata.ToothRepoPath()})
+		}
+	}
+
+	return items, nil
+}
+
+// findOrphanedState finds records and manifests of teeth that are not installed. Originals of
+// config files are kept, since they are kept on purpose with the edited config files.
+func findOrphanedState(ctx *context.Context) ([]staleItem, error) {
+	db, err := statedb.Open(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open state database\n\t%w", err)
+	}
+
+	items := make([]staleItem, 0)
+
+	for _, tooth := range db.List() {
+		if tooth.Metadata == nil {
+			items = append(items, staleItem{kind: orphanedStateKind, toothRepoPath: tooth.ToothRepoPath})
+		}
+	}
+
+	return items, nil
+}
+
+// findStaleCache finds cached tooth archives of versions of teeth that are not installed, and
+// their cached asset archives. Tooth archives are recognized by their contents, since they are
+// cached by their URLs. The cache is shared by all workspaces, so archives of teeth installed
+// only in other workspaces are found too.
+func findStaleCache(ctx *context.Context, metadataList []tooth.Metadata) ([]staleItem, error) {
+	debugLogger := ctx.Logger().WithFields(log.Fields{
+		"package": "cmdlipprune",
+		"method":  "findStaleCache",
+	})
+
+	installedVersions := make(map[string]string)
+	for _, metadata := range metadataList {
+		installedVersions[metadata.ToothRepoPath()] = metadata.Version().String()
+	}
+
+	cacheDir, err := ctx.CacheDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cache directory\n\t%w", err)
+	}
+
+	staleFileSet := make(map[string]bool)
+	keptFileSet := make(map[string]bool)
+
+	err = filepath.WalkDir(cacheDir.LocalString(), func(filePath string, entry fs.DirEntry, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		// Staging directories and partial downloads are temporary files.
+		if entry.IsDir() && strings.HasPrefix(entry.Name(), "bundle-") {
+			return filepath.SkipDir
+		}
+
+		if !entry.Type().IsRegular() || strings.HasSuffix(entry.Name(), ".part") {
+			return nil
+		}
+
+		archiveFilePath, err := path.Parse(filePath)
+		if err != nil {
+			return nil
+		}
+
+		archive, err := tooth.MakeArchive(ctx.FS(), archiveFilePath)
+		if err != nil {
+			// Not a tooth archive, e.g. an asset archive or a version list.
+			return nil
+		}
+
+		metadata := archive.Metadata()
+		isInstalled := installedVersions[metadata.ToothRepoPath()] == metadata.Version().String()
+
+		fileSet := staleFileSet
+		if isInstalled {
+			fileSet = keptFileSet
+		}
+
+		fileSet[filePath] = true
+
+		assetArchiveFilePath, ok, err := cmdlipinstall.GetCachedAssetArchivePath(ctx, archive)
+		if err != nil {
+			debugLogger.Debugf("Cannot get asset archive of %v: %v", filePath, err)
+		} else if ok {
+			fileSet[assetArchiveFilePath.LocalString()] = true
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk cache directory %v\n\t%w", cacheDir.LocalString(), err)
+	}
+
+	staleFiles := make([]string, 0)
+	for filePath := range staleFileSet {
+		if keptFileSet[filePath] {
+			continue
+		}
+
+		if _, err := os.Stat(filePath); err != nil {
+			continue
+		}
+
+		staleFiles = append(staleFiles, filePath)
+	}
+	sort.Strings(staleFiles)
+
+	items := make([]staleItem, 0, len(staleFiles))
+	for _, filePath := range staleFiles {
+		items = append(items, staleItem{kind: staleCacheKind, filePath: filePath})
+	}
+
+	return items, nil
+}
+
+// findTempLeftovers finds temporary files and directories left by aborted operations: partial
+// downloads and bundle staging directories in the cache, partial copies in the vendor
+// directory, links of versions installed side by side being replaced, and files checking
+// write permissions.
+func findTempLeftovers(ctx *context.Context) ([]staleItem, error) {
+	cacheDir, err := ctx.CacheDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cache directory\n\t%w", err)
+	}
+
+	vendorDir, err := ctx.VendorDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get vendor directory\n\t%w", err)
+	}
+
+	versionsDir, err := ctx.VersionsDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get versions directory\n\t%w", err)
+	}
+
+	candidates := make([]string, 0)
+
+	for _, dir := range []string{cacheDir.LocalString(), vendorDir.LocalString()} {
+		partFiles, err := findPartFiles(dir)
+		if err != nil {
+			return nil, err
+		}
+
+		candidates = append(candidates, partFiles...)
+	}
+
+	for _, pattern := range []string{
+		filepath.Join(cacheDir.LocalString(), "bundle-*"),
+		filepath.Join(versionsDir.LocalString(), "*", "*.tmp"),
+		".lip-permission-check-*",
+		filepath.Join(".lip", ".lip-permission-check-*"),
+	} {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find files matching %v\n\t%w", pattern, err)
+		}
+
+		candidates = append(candidates, matches...)
+	}
+
+	sort.Strings(candidates)
+
+	items := make([]staleItem, 0)
+	for _, candidate := range candidates {
+		fileInfo, err := os.Lstat(candidate)
+		if err != nil || ctx.Clock().Now().Sub(fileInfo.ModTime()) < tempLeftoverMinAge {
+			continue
+		}
+
+		items = append(items, staleItem{kind: tempLeftoverKind, filePath: candidate})
+	}
+
+	return items, nil
+}
+
+// findPartFiles finds partial downloads and copies in a directory and its subdirectories.
+func findPartFiles(dir string) ([]string, error) {
+	partFiles := make([]string, 0)
+
+	err := filepath.WalkDir(dir, func(filePath string, entry fs.DirEntry, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if entry.Type().IsRegular() && strings.HasSuffix(entry.Name(), ".part") {
+			partFiles = append(partFiles, filePath)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk directory %v\n\t%w", dir, err)
+	}
+
+	return partFiles, nil
+}
@@ -0,0 +1,273 @@
+package cmdlippublish
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/delta"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/oci"
+	"github.com/lippkg/lip/internal/path"
+	"github.com/lippkg/lip/internal/tarzst"
+	"github.com/lippkg/lip/internal/tooth"
+	"github.com/lippkg/lip/internal/vfs"
+	log "github.com/sirupsen/logrus"
+)
+
+type FlagDict struct {
+	helpFlag      bool
+	ociFlag       bool
+	patchFromFlag string
+}
+
+const helpMessage = `
+Usage:
+  lip publish [options] <tooth archive or directory>
+
+Description:
+  Publish a tooth archive, e.g. one made by lip tooth pack. The tooth repo path and the version
+  are read from its tooth.json. Both zip and .tar.zst archives are supported.
+
+  If a directory is given, e.g. one made by lip tooth pack in a monorepo, every tooth archive in
+  it is published.
+
+Options:
+  -h, --help                  Show help.
+  --oci                       Push the archive to the OCI registry at OCIRegistryURL, as an
+                              artifact tagged with the version. Log in to the registry with
+                              lip login first.
+  --patch-from <tooth archive>
+                              Also push a binary patch from the given archive of an earlier
+                              version, so that lip install --upgrade downloads only the patch.
+                              Only zip archives can be patched.
+`
+
+func Run(ctx *context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("publish", flag.ContinueOnError)
+
+	// Rewrite the default usage message.
+	flagSet.Usage = func() {
+		// Do nothing.
+	}
+
+	var flagDict FlagDict
+	flagSet.BoolVar(&flagDict.helpFlag, "help", false, "")
+	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
+	flagSet.BoolVar(&flagDict.ociFlag, "oci", false, "")
+	flagSet.StringVar(&flagDict.patchFromFlag, "patch-from", "", "")
+
+	if err := flagSet.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags\n\t%w", err)
+	}
+
+	// Help flag has the highest priority.
+	if flagDict.helpFlag {
+		fmt.Print(helpMessage)
+		return nil
+	}
+
+	// Exactly one argument is required.
+	if flagSet.NArg() != 1 {
+		return errcode.Errorf(errcode.InvalidArgument, "expected exactly one argument")
+	}
+
+	// OCI registries are the only target for now.
+	if !flagDict.ociFlag {
+		return errcode.Errorf(errcode.InvalidArgument, "no target to publish to, specify --oci")
+	}
+
+	if ctx.Offline() {
+		return errcode.Errorf(errcode.Offline, "cannot publish in offline mode")
+	}
+
+	fileInfo, err := ctx.FS().Stat(flagSet.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to get info of %v\n\t%w", flagSet.Arg(0), err)
+	}
+
+	// A directory, e.g. one made by lip tooth pack in a monorepo, has its archives published.
+	if fileInfo.IsDir() {
+		if flagDict.patchFromFlag != "" {
+			return errcode.Errorf(errcode.InvalidArgument, "cannot use --patch-from with a directory")
+		}
+
+		archivePaths, err := findArchives(ctx.FS(), flagSet.Arg(0))
+		if err != nil {
+			return err
+		}
+
+		if len(archivePaths) == 0 {
+			return errcode.Errorf(errcode.InvalidArgument, "no tooth archives in %v", flagSet.Arg(0))
+		}
+
+		for _, archivePath := range archivePaths {
+			if err := publishArchive(ctx, archivePath, ""); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	archivePath, err := path.Parse(flagSet.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to parse archive path %v\n\t%w", flagSet.Arg(0), err)
+	}
+
+	return publishArchive(ctx, archivePath, flagDict.patchFromFlag)
+}
+
+// ---------------------------------------------------------------------
+
+// publishArchive pushes a tooth archive, and the binary patch to it from the archive at
+// patchFrom if patchFrom is not empty.
+func publishArchive(ctx *context.Context, archivePath path.Path, patchFrom string) error {
+	// The metadata of a .tar.zst archive is read from the zip archive converted from it, but
+	// the .tar.zst archive itself is pushed.
+	zipPath, err := tarzst.ToZipIfTarZst(ctx, archivePath)
+	if err != nil {
+		return err
+	}
+
+	archive, err := tooth.MakeArchive(ctx.FS(), zipPath)
+	if err != nil {
+		return fmt.Errorf("failed to open archive %v\n\t%w", archivePath.LocalString(), err)
+	}
+
+	metadata := archive.Metadata()
+
+	// Make the patch before pushing anything, so that an invalid previous archive fails early.
+	var patch []byte
+	var previousMetadata tooth.Metadata
+	if patchFrom != "" {
+		patch, previousMetadata, err = makePatch(ctx, patchFrom, archivePath, metadata)
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Infof(i18n.T("Publishing %v@%v..."), metadata.ToothRepoPath(), metadata.Version())
+
+	reference, err := oci.Push(ctx, archivePath, metadata.ToothRepoPath(), metadata.Version())
+	if err != nil {
+		return fmt.Errorf("failed to push %v@%v\n\t%w", metadata.ToothRepoPath(), metadata.Version(), err)
+	}
+
+	log.Infof(i18n.T("Published %v@%v as %v"), metadata.ToothRepoPath(), metadata.Version(), reference)
+
+	if patch != nil {
+		reference, err := oci.PushPatch(ctx, patch, metadata.ToothRepoPath(), previousMetadata.Version(),
+			metadata.Version())
+		if err != nil {
+			return fmt.Errorf("failed to push patch from %v to %v\n\t%w", previousMetadata.Version(),
+				metadata.Version(), err)
+		}
+
+		log.Infof(i18n.T("Published the patch from %v to %v (%v bytes) as %v"), previousMetadata.Version(),
+			metadata.Version(), len(patch), reference)
+	}
+
+	return nil
+}
+
+// findArchives finds the zip and .tar.zst archives in a directory on a file system, sorted by
+// their names. Checksums and signatures are skipped.
+func findArchives(fs vfs.FS, dir string) ([]path.Path, error) {
+	entries, err := fs.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory %v\n\t%w", dir, err)
+	}
+
+	archivePaths := make([]path.Path, 0)
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+
+		if !strings.HasSuffix(entry.Name(), ".zip") && !strings.HasSuffix(entry.Name(), ".tar.zst") {
+			continue
+		}
+
+		archivePath, err := path.Parse(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse archive path %v\n\t%w", entry.Name(), err)
+		}
+
+		archivePaths = append(archivePaths, archivePath)
+	}
+
+	return archivePaths, nil
+}
+
+// makePatch makes the binary patch to a tooth archive from the archive of an earlier version of
+// the same tooth, and returns it with the metadata of the earlier version.
+func makePatch(ctx *context.Context, previousArchivePathStr string, archivePath path.Path,
+	metadata tooth.Metadata) ([]byte, tooth.Metadata, error) {
+	previousArchivePath, err := path.Parse(previousArchivePathStr)
+	if err != nil {
+		return nil, tooth.Metadata{}, fmt.Errorf("failed to parse archive path %v\n\t%w", previousArchivePathStr, err)
+	}
+
+	// Patches are made between zip archives. Installed archives are kept as zip archives, so a
+	// patch from or to a .tar.zst archive would never apply.
+	for _, p := range []path.Path{previousArchivePath, archivePath} {
+		isTarZst, err := tarzst.IsTarZst(ctx.FS(), p)
+		if err != nil {
+			return nil, tooth.Metadata{}, err
+		}
+
+		if isTarZst {
+			return nil, tooth.Metadata{}, errcode.Errorf(errcode.InvalidArgument,
+				"cannot make a patch with .tar.zst archive %v, use zip archives", p.LocalString())
+		}
+	}
+
+	previousArchive, err := tooth.MakeArchive(ctx.FS(), previousArchivePath)
+	if err != nil {
+		return nil, tooth.Metadata{}, fmt.Errorf("failed to open archive %v\n\t%w",
+			previousArchivePath.LocalString(), err)
+	}
+
+	previousMetadata := previousArchive.Metadata()
+
+	if previousMetadata.ToothRepoPath() != metadata.ToothRepoPath() {
+		return nil, tooth.Metadata{}, errcode.Errorf(errcode.InvalidArgument,
+			"cannot patch from an archive of another tooth: %v != %v", previousMetadata.ToothRepoPath(),
+			metadata.ToothRepoPath())
+	}
+
+	if !previousMetadata.Version().LT(metadata.Version()) {
+		return nil, tooth.Metadata{}, errcode.Errorf(errcode.InvalidArgument,
+			"cannot patch from version %v, which is not earlier than %v", previousMetadata.Version(),
+			metadata.Version())
+	}
+
+	previousFile, err := ctx.FS().Open(previousArchivePath.LocalString())
+	if err != nil {
+		return nil, tooth.Metadata{}, fmt.Errorf("failed to open %v\n\t%w", previousArchivePath.LocalString(), err)
+	}
+	defer previousFile.Close()
+
+	previousInfo, err := previousFile.Stat()
+	if err != nil {
+		return nil, tooth.Metadata{}, fmt.Errorf("failed to stat %v\n\t%w", previousArchivePath.LocalString(), err)
+	}
+
+	file, err := ctx.FS().Open(archivePath.LocalString())
+	if err != nil {
+		return nil, tooth.Metadata{}, fmt.Errorf("failed to open %v\n\t%w", archivePath.LocalString(), err)
+	}
+	defer file.Close()
+
+	var patch bytes.Buffer
+	if err := delta.Diff(previousFile, previousInfo.Size(), file, &patch); err != nil {
+		return nil, tooth.Metadata{}, fmt.Errorf("failed to make patch\n\t%w", err)
+	}
+
+	return patch.Bytes(), previousMetadata, nil
+}
@@ -0,0 +1,213 @@
+package cmdliprollback
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/history"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/install"
+	"github.com/lippkg/lip/internal/record"
+	"github.com/lippkg/lip/internal/snapshot"
+	"github.com/lippkg/lip/internal/tooth"
+	log "github.com/sirupsen/logrus"
+)
+
+type FlagDict struct {
+	helpFlag bool
+	yesFlag  bool
+	listFlag bool
+}
+
+const helpMessage = `
+Usage:
+  lip rollback [options] <tooth repository URL>[@<version>]
+
+Description:
+  Reinstate the previously installed version of a tooth from the local snapshots. If a version
+  is specified, reinstate that version instead.
+
+Options:
+  -h, --help                  Show help.
+  -y, --yes                   Skip confirmation.
+  --list                      List the versions available for rollback.
+`
+
+func Run(ctx *context.Context, args []string) error {
+
+	flagSet := flag.NewFlagSet("rollback", flag.ContinueOnError)
+
+	// Rewrite the default usage message.
+	flagSet.Usage = func() {
+		// Do nothing.
+	}
+
+	var flagDict FlagDict
+	flagSet.BoolVar(&flagDict.helpFlag, "help", false, "")
+	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
+	flagSet.BoolVar(&flagDict.yesFlag, "yes", false, "")
+	flagSet.BoolVar(&flagDict.yesFlag, "y", false, "")
+	flagSet.BoolVar(&flagDict.listFlag, "list", false, "")
+	err := flagSet.Parse(args)
+	if err != nil {
+		return fmt.Errorf("failed to parse flags\n\t%w", err)
+	}
+
+	// Help flag has the highest priority.
+	if flagDict.helpFlag {
+		fmt.Print(helpMessage)
+		return nil
+	}
+
+	// Exactly one argument is required.
+	if flagSet.NArg() != 1 {
+		return errcode.Errorf(errcode.InvalidArgument, "invalid number of arguments")
+	}
+
+	toothRepoPath, targetVersionString, isVersionSpecified := strings.Cut(flagSet.Arg(0), "@")
+
+	isInstalled, err := tooth.IsInstalled(ctx, toothRepoPath)
+	if err != nil {
+		return fmt.Errorf("failed to check if tooth is installed\n\t%w", err)
+	}
+
+	if !isInstalled {
+		return errcode.Errorf(errcode.NotInstalled, "tooth %v is not installed", toothRepoPath)
+	}
+
+	currentMetadata, err := tooth.GetMetadata(ctx, toothRepoPath)
+	if err != nil {
+		return fmt.Errorf("failed to find installed tooth metadata\n\t%w", err)
+	}
+
+	versions, err := snapshot.List(ctx, toothRepoPath)
+	if err != nil {
+		return fmt.Errorf("failed to list snapshots of tooth %v\n\t%w", toothRepoPath, err)
+	}
+
+	if flagDict.listFlag {
+		for _, version := range versions {
+			if version.EQ(currentMetadata.Version()) {
+				fmt.Printf("%v (installed)\n", version)
+			} else {
+				fmt.Printf("%v\n", version)
+			}
+		}
+		return nil
+	}
+
+	// 1. Find the version to reinstate.
+
+	var targetVersion semver.Version
+	if isVersionSpecified {
+		targetVersion, err = semver.Parse(targetVersionString)
+		if err != nil {
+			return fmt.Errorf("failed to parse version %v\n\t%w", targetVersionString, err)
+		}
+
+		isFound := false
+		for _, version := range versions {
+			if version.EQ(targetVersion) {
+				isFound = true
+				break
+			}
+		}
+
+		if !isFound {
+			return i18n.Errorf("no snapshot of %v@%v found", toothRepoPath, targetVersion)
+		}
+
+	} else {
+		previousVersion, err := getPreviousVersion(versions, currentMetadata.Version())
+		if err != nil {
+			return err
+		}
+
+		targetVersion = previousVersion
+	}
+
+	if targetVersion.EQ(currentMetadata.Version()) {
+		return i18n.Errorf("tooth %v@%v is already installed", toothRepoPath, targetVersion)
+	}
+
+	archive, err := snapshot.Get(ctx, toothRepoPath, targetVersion)
+	if err != nil {
+		return fmt.Errorf("failed to get snapshot of %v@%v\n\t%w", toothRepoPath, targetVersion, err)
+	}
+
+	// 2. Prompt for confirmation.
+
+	if !flagDict.yesFlag {
+		log.Infof(i18n.T("Tooth %v will be rolled back from %v to %v."), toothRepoPath, currentMetadata.Version(),
+			targetVersion)
+		if ok, err := ctx.Prompter().Confirm(i18n.T("Do you want to continue? [y/N]"), false); err != nil {
+			return err
+		} else if !ok {
+			return errcode.Errorf(errcode.Aborted, "aborted")
+		}
+	}
+
+	// 3. Reinstate the version and keep the record.
+
+	currentRecord, err := record.Get(ctx, toothRepoPath)
+	if err != nil {
+		return fmt.Errorf("failed to get record of tooth %v\n\t%w", toothRepoPath, err)
+	}
+
+	if err := install.Uninstall(ctx, toothRepoPath); err != nil {
+		return fmt.Errorf("failed to uninstall tooth %v\n\t%w", toothRepoPath, err)
+	}
+
+	if err := install.Install(ctx, archive, flagDict.yesFlag); err != nil {
+		return fmt.Errorf("failed to install snapshot of %v@%v\n\t%w", toothRepoPath, targetVersion, err)
+	}
+
+	if err := record.Save(ctx, currentRecord); err != nil {
+		return fmt.Errorf("failed to save record of tooth %v\n\t%w", toothRepoPath, err)
+	}
+
+	// The rolled back version is dropped from the history, so that rolling back again goes
+	// further back.
+	if err := snapshot.Remove(ctx, toothRepoPath, currentMetadata.Version()); err != nil {
+		return fmt.Errorf("failed to remove snapshot of %v@%v\n\t%w", toothRepoPath,
+			currentMetadata.Version(), err)
+	}
+
+	if err := history.Append(ctx, history.RollbackOperation, []history.Change{{
+		ToothRepoPath: toothRepoPath,
+		From:          currentMetadata.Version().String(),
+		To:            targetVersion.String(),
+		WasExplicit:   currentRecord.IsExplicit,
+	}}, 0); err != nil {
+		return fmt.Errorf("failed to record history\n\t%w", err)
+	}
+
+	log.Info(i18n.T("Done."))
+
+	return nil
+}
+
+// ---------------------------------------------------------------------
+
+// getPreviousVersion returns the version installed before the current version.
+func getPreviousVersion(versions semver.Versions, currentVersion semver.Version) (semver.Version, error) {
+	// Find the position of the current version. If it is not in the history, the latest
+	// snapshot is the previous version.
+	position := len(versions)
+	for i, version := range versions {
+		if version.EQ(currentVersion) {
+			position = i
+			break
+		}
+	}
+
+	if position == 0 {
+		return semver.Version{}, i18n.Errorf("no previous version to roll back to")
+	}
+
+	return versions[position-1], nil
+}
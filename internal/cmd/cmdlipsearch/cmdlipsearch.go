@@ -0,0 +1,111 @@
+package cmdlipsearch
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/registry"
+	"github.com/olekukonko/tablewriter"
+)
+
+type FlagDict struct {
+	helpFlag bool
+	sortFlag string
+	jsonFlag bool
+}
+
+const helpMessage = `
+Usage:
+  lip search [options] <query>
+
+Description:
+  Search the registry for teeth whose repo paths or aliases contain the query. Download counts,
+  stars and maintenance status are shown if the registry provides them.
+
+Options:
+  -h, --help                  Show help.
+  --sort <key>                Sort by name (default), downloads or stars. Teeth whose stats the
+                              registry does not provide come last.
+  --json                      Output in JSON format.
+`
+
+func Run(ctx *context.Context, args []string) error {
+
+	flagSet := flag.NewFlagSet("search", flag.ContinueOnError)
+
+	// Rewrite the default usage message.
+	flagSet.Usage = func() {
+		// Do nothing.
+	}
+
+	var flagDict FlagDict
+	flagSet.BoolVar(&flagDict.helpFlag, "help", false, "")
+	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
+	flagSet.StringVar(&flagDict.sortFlag, "sort", string(registry.NameSortKey), "")
+	flagSet.BoolVar(&flagDict.jsonFlag, "json", false, "")
+	err := flagSet.Parse(args)
+	if err != nil {
+		return fmt.Errorf("failed to parse flags\n\t%w", err)
+	}
+
+	// Help flag has the highest priority.
+	if flagDict.helpFlag {
+		fmt.Print(helpMessage)
+		return nil
+	}
+
+	// Exactly one argument is required.
+	if flagSet.NArg() != 1 {
+		return errcode.Errorf(errcode.InvalidArgument, "expected exactly one argument")
+	}
+
+	sortKey, err := registry.ParseSortKey(flagDict.sortFlag)
+	if err != nil {
+		return err
+	}
+
+	if !registry.IsConfigured(ctx) {
+		return i18n.Errorf("no registry is configured. Set registry_url with lip config")
+	}
+
+	results, err := registry.Search(ctx, flagSet.Arg(0), sortKey)
+	if err != nil {
+		return fmt.Errorf("failed to search the registry\n\t%w", err)
+	}
+
+	if flagDict.jsonFlag {
+		jsonBytes, err := json.Marshal(results)
+		if err != nil {
+			return fmt.Errorf("failed to marshal JSON\n\t%w", err)
+		}
+
+		fmt.Print(string(jsonBytes))
+
+	} else {
+		tableString := &strings.Builder{}
+		table := tablewriter.NewWriter(tableString)
+		table.SetHeader([]string{
+			"Tooth", "Downloads", "Stars", "Maintenance",
+		})
+
+		for _, result := range results {
+			table.Append([]string{
+				result.ToothRepoPath,
+				registry.FormatCount(result.Downloads),
+				registry.FormatCount(result.Stars),
+				result.Maintenance,
+			})
+		}
+
+		table.Render()
+
+		fmt.Print(tableString.String())
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package cmdlipself
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/lippkg/lip/internal/cmd/cmdlipselfupdate"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+)
+
+type FlagDict struct {
+	helpFlag bool
+}
+
+const helpMessage = `
+Usage:
+  lip self [options]
+  lip self <command> [subcommand options] ...
+
+Commands:
+  update                      Update lip to the latest version.
+
+Options:
+  -h, --help                  Show help.
+`
+
+func Run(ctx *context.Context, args []string) error {
+
+	flagSet := flag.NewFlagSet("self", flag.ContinueOnError)
+
+	// Rewrite the default usage message.
+	flagSet.Usage = func() {
+		// Do nothing.
+	}
+
+	var flagDict FlagDict
+	flagSet.BoolVar(&flagDict.helpFlag, "help", false, "")
+	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
+	err := flagSet.Parse(args)
+	if err != nil {
+		return fmt.Errorf("failed to parse flags\n\t%w", err)
+	}
+
+	// Help flag has the highest priority.
+	if flagDict.helpFlag {
+		fmt.Print(helpMessage)
+		return nil
+	}
+
+	// If there is a subcommand, run it and exit.
+	if flagSet.NArg() >= 1 {
+		switch flagSet.Arg(0) {
+		case "update":
+			err := cmdlipselfupdate.Run(ctx, flagSet.Args()[1:])
+			if err != nil {
+				return err
+			}
+			return nil
+
+		default:
+			return errcode.Errorf(errcode.InvalidArgument, "unknown command: lip self %v", flagSet.Arg(0))
+		}
+	}
+
+	return errcode.Errorf(errcode.InvalidArgument, "no command specified. See 'lip self --help' for more information")
+}
@@ -0,0 +1,133 @@
+package cmdlipselfupdate
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/selfupdate"
+	log "github.com/sirupsen/logrus"
+)
+
+type FlagDict struct {
+	helpFlag  bool
+	yesFlag   bool
+	checkFlag bool
+	forceFlag bool
+}
+
+const helpMessage = `
+Usage:
+  lip self update [options] [<version>]
+
+Description:
+  Update lip to the latest version, or to the specified version. The release archive is
+  verified against its SHA-256 checksum before the running executable is replaced.
+
+Options:
+  -h, --help                  Show help.
+  -y, --yes                   Skip confirmation.
+  --check                     Only check if a newer version is available.
+  --force                     Reinstall even if the version is already installed.
+`
+
+func Run(ctx *context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("update", flag.ContinueOnError)
+
+	// Rewrite the default usage message.
+	flagSet.Usage = func() {
+		// Do nothing.
+	}
+
+	var flagDict FlagDict
+	flagSet.BoolVar(&flagDict.helpFlag, "help", false, "")
+	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
+	flagSet.BoolVar(&flagDict.yesFlag, "yes", false, "")
+	flagSet.BoolVar(&flagDict.yesFlag, "y", false, "")
+	flagSet.BoolVar(&flagDict.checkFlag, "check", false, "")
+	flagSet.BoolVar(&flagDict.forceFlag, "force", false, "")
+
+	if err := flagSet.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags\n\t%w", err)
+	}
+
+	// Help flag has the highest priority.
+	if flagDict.helpFlag {
+		fmt.Print(helpMessage)
+		return nil
+	}
+
+	// At most one argument is allowed.
+	if flagSet.NArg() > 1 {
+		return errcode.Errorf(errcode.InvalidArgument, "unexpected arguments: %v", flagSet.Args()[1:])
+	}
+
+	// 1. Find the version to update to.
+
+	var targetVersion semver.Version
+	if flagSet.NArg() == 1 {
+		version, err := semver.Parse(strings.TrimPrefix(flagSet.Arg(0), "v"))
+		if err != nil {
+			return fmt.Errorf("failed to parse version %v\n\t%w", flagSet.Arg(0), err)
+		}
+		targetVersion = version
+
+	} else {
+		version, err := selfupdate.GetLatestVersion(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to check for updates\n\t%w", err)
+		}
+		targetVersion = version
+	}
+
+	currentVersion := ctx.LipVersion()
+
+	if flagDict.checkFlag {
+		if targetVersion.GT(currentVersion) {
+			log.Infof(i18n.T("A new version of lip is available: %v (current: %v)"), targetVersion, currentVersion)
+		} else {
+			log.Infof(i18n.T("lip %v is up to date."), currentVersion)
+		}
+		return nil
+	}
+
+	if targetVersion.EQ(currentVersion) && !flagDict.forceFlag {
+		log.Infof(i18n.T("lip %v is already installed."), currentVersion)
+		return nil
+	}
+
+	if flagSet.NArg() == 0 && targetVersion.LT(currentVersion) && !flagDict.forceFlag {
+		log.Infof(i18n.T("lip %v is newer than the latest release %v."), currentVersion, targetVersion)
+		return nil
+	}
+
+	// 2. Prompt for confirmation.
+
+	if !flagDict.yesFlag {
+		log.Infof(i18n.T("lip will be updated from %v to %v."), currentVersion, targetVersion)
+		if ok, err := ctx.Prompter().Confirm(i18n.T("Do you want to continue? [y/N]"), false); err != nil {
+			return err
+		} else if !ok {
+			return errcode.Errorf(errcode.Aborted, "aborted")
+		}
+	}
+
+	// 3. Download, verify and replace the executable.
+
+	archivePath, err := selfupdate.Download(ctx, targetVersion)
+	if err != nil {
+		return fmt.Errorf("failed to download lip %v\n\t%w", targetVersion, err)
+	}
+
+	if err := selfupdate.Apply(ctx, archivePath); err != nil {
+		return fmt.Errorf("failed to replace lip executable\n\t%w", err)
+	}
+
+	log.Infof(i18n.T("Updated lip to %v."), targetVersion)
+
+	return nil
+}
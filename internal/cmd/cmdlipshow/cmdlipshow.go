@@ -6,16 +6,24 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/changelog"
+	"github.com/lippkg/lip/internal/cmd/cmdlipinstall"
 	"github.com/lippkg/lip/internal/context"
-
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/registry"
+	"github.com/lippkg/lip/internal/render"
 	"github.com/lippkg/lip/internal/tooth"
 	"github.com/olekukonko/tablewriter"
+	log "github.com/sirupsen/logrus"
 )
 
 type FlagDict struct {
 	helpFlag      bool
 	availableFlag bool
 	jsonFlag      bool
+	changelogFlag bool
 }
 
 const helpMessage = `
@@ -23,12 +31,16 @@ Usage:
   lip show [options] <tooth repository URL>
 
 Description:
-  Show information about an installed tooth.
+  Show information about an installed tooth. Download counts, stars and maintenance status are
+  also shown if the registry provides them.
 
 Options:
   -h, --help                  Show help.
   --available                 Show the full list of available versions.
   --json                      Output in JSON format.
+  --changelog                 Show the changelog entries of the versions after the installed
+                              one up to the latest one, or all of them if the tooth is not
+                              installed.
 `
 
 func Run(ctx *context.Context, args []string) error {
@@ -45,6 +57,7 @@ func Run(ctx *context.Context, args []string) error {
 	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
 	flagSet.BoolVar(&flagDict.availableFlag, "available", false, "")
 	flagSet.BoolVar(&flagDict.jsonFlag, "json", false, "")
+	flagSet.BoolVar(&flagDict.changelogFlag, "changelog", false, "")
 	err := flagSet.Parse(args)
 	if err != nil {
 		return fmt.Errorf("failed to parse flags\n\t%w", err)
@@ -58,12 +71,12 @@ func Run(ctx *context.Context, args []string) error {
 
 	// Exactly one argument is required.
 	if flagSet.NArg() != 1 {
-		return fmt.Errorf("invalid number of arguments")
+		return errcode.Errorf(errcode.InvalidArgument, "invalid number of arguments")
 	}
 
 	toothRepoPath := flagSet.Arg(0)
 
-	if err := show(ctx, toothRepoPath, flagDict.availableFlag, flagDict.jsonFlag); err != nil {
+	if err := show(ctx, toothRepoPath, flagDict.availableFlag, flagDict.jsonFlag, flagDict.changelogFlag); err != nil {
 		return fmt.Errorf("failed to show JSON\n\t%w", err)
 	}
 
@@ -95,7 +108,7 @@ func checkIsInstalledAndGetMetadata(ctx *context.Context,
 }
 
 func show(ctx *context.Context, toothRepoPath string,
-	availableFlag bool, jsonFlag bool) error {
+	availableFlag bool, jsonFlag bool, changelogFlag bool) error {
 
 	isInstalled, metadata, err := checkIsInstalledAndGetMetadata(ctx, toothRepoPath)
 	if err != nil {
@@ -114,10 +127,28 @@ func show(ctx *context.Context, toothRepoPath string,
 		}
 	}
 
-	if !isInstalled && !availableFlag {
-		return fmt.Errorf("tooth is not installed")
+	if !isInstalled && !availableFlag && !changelogFlag {
+		return errcode.Errorf(errcode.NotInstalled, "tooth is not installed")
 	}
 
+	var changelogEntries []changelog.Entry
+	hasChangelog := false
+	if changelogFlag {
+		// Without an installed version, all versions up to the latest one are shown.
+		var installedVersion *semver.Version
+		if isInstalled {
+			version := metadata.Version()
+			installedVersion = &version
+		}
+
+		changelogEntries, hasChangelog, err = getChangelog(ctx, toothRepoPath, installedVersion)
+		if err != nil {
+			return fmt.Errorf("failed to get changelog\n\t%w", err)
+		}
+	}
+
+	stats, hasStats := getRegistryStats(ctx, toothRepoPath)
+
 	if jsonFlag {
 		info := make(map[string]interface{})
 
@@ -129,6 +160,14 @@ func show(ctx *context.Context, toothRepoPath string,
 			info["available_versions"] = availableVersions
 		}
 
+		if hasStats {
+			info["registry"] = stats
+		}
+
+		if hasChangelog {
+			info["changelog"] = changelogEntries
+		}
+
 		jsonBytes, err := json.Marshal(info)
 		if err != nil {
 			return fmt.Errorf("failed to marshal JSON\n\t%w", err)
@@ -148,6 +187,18 @@ func show(ctx *context.Context, toothRepoPath string,
 				{"Tags", strings.Join(metadata.Info().Tags, ", ")},
 				{"Version", metadata.Version().String()},
 			}...)
+
+			if homepage := metadata.Info().Homepage; homepage != "" {
+				tableData = append(tableData, []string{"Homepage", homepage})
+			}
+
+			if deprecation, isDeprecated := metadata.Deprecation(); isDeprecated {
+				tableData = append(tableData, []string{"Deprecated", deprecation.Reason})
+
+				if deprecation.Replacement != "" {
+					tableData = append(tableData, []string{"Replacement", deprecation.Replacement})
+				}
+			}
 		}
 
 		if availableFlag {
@@ -155,6 +206,18 @@ func show(ctx *context.Context, toothRepoPath string,
 				strings.Join(availableVersions, ", ")})
 		}
 
+		if hasStats {
+			for _, row := range [][]string{
+				{"Downloads", registry.FormatCount(stats.Downloads)},
+				{"Stars", registry.FormatCount(stats.Stars)},
+				{"Maintenance", stats.Maintenance},
+			} {
+				if row[1] != "" {
+					tableData = append(tableData, row)
+				}
+			}
+		}
+
 		tableString := &strings.Builder{}
 		table := tablewriter.NewWriter(tableString)
 		table.SetHeader([]string{"Key", "Value"})
@@ -166,7 +229,90 @@ func show(ctx *context.Context, toothRepoPath string,
 		table.Render()
 
 		fmt.Print(tableString.String())
+
+		if changelogFlag {
+			printChangelog(changelogEntries, hasChangelog)
+		}
 	}
 
 	return nil
 }
+
+// getRegistryStats returns the stats of a tooth in the registry. The second return value is
+// false if no registry is configured, the tooth is not in it, or it provides no stats, which
+// are only shown in addition to the other information.
+func getRegistryStats(ctx *context.Context, toothRepoPath string) (registry.Stats, bool) {
+	debugLogger := ctx.Logger().WithFields(log.Fields{
+		"package": "cmdlipshow",
+		"method":  "getRegistryStats",
+	})
+
+	if !registry.IsConfigured(ctx) {
+		return registry.Stats{}, false
+	}
+
+	entry, err := registry.GetEntry(ctx, toothRepoPath)
+	if err != nil {
+		debugLogger.Debugf("Cannot get registry entry of %v: %v", toothRepoPath, err)
+		return registry.Stats{}, false
+	}
+
+	stats := entry.Stats
+	hasStats := stats.Downloads != nil || stats.Stars != nil || stats.Maintenance != ""
+
+	return stats, hasStats
+}
+
+// getChangelog returns the changelog entries of the versions of a tooth after the installed
+// version, if any, up to the latest version, read from the tooth archive of the latest version.
+// The second return value is false if the tooth has no changelog.
+func getChangelog(ctx *context.Context, toothRepoPath string,
+	installedVersion *semver.Version) ([]changelog.Entry, bool, error) {
+	latestVersion, err := cmdlipinstall.GetLatestSupportedVersion(ctx, toothRepoPath,
+		func(semver.Version) bool { return true })
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get latest version\n\t%w", err)
+	}
+
+	archive, err := cmdlipinstall.DownloadToothArchiveWithoutAssets(ctx, toothRepoPath, latestVersion)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to download archive of %v@%v\n\t%w", toothRepoPath, latestVersion, err)
+	}
+
+	entries, ok, err := changelog.Get(ctx, archive)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+
+	if installedVersion != nil {
+		return changelog.Between(entries, *installedVersion, latestVersion), true, nil
+	}
+
+	latestEntries := make([]changelog.Entry, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Version.LTE(latestVersion) {
+			latestEntries = append(latestEntries, entry)
+		}
+	}
+
+	return latestEntries, true, nil
+}
+
+// printChangelog prints changelog entries after the table of information.
+func printChangelog(entries []changelog.Entry, hasChangelog bool) {
+	fmt.Println()
+
+	if !hasChangelog {
+		fmt.Println(i18n.T("The tooth has no changelog."))
+		return
+	}
+
+	if len(entries) == 0 {
+		fmt.Println(i18n.T("No changes after the installed version."))
+		return
+	}
+
+	fmt.Println(render.Style(render.HeadingRole, i18n.T("Changelog:")))
+	fmt.Println()
+	fmt.Print(changelog.Format(entries))
+}
@@ -0,0 +1,89 @@
+package cmdlipsnapshot
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/lippkg/lip/internal/cmd/cmdlipsnapshotcreate"
+	"github.com/lippkg/lip/internal/cmd/cmdlipsnapshotdiff"
+	"github.com/lippkg/lip/internal/cmd/cmdlipsnapshotlist"
+	"github.com/lippkg/lip/internal/cmd/cmdlipsnapshotrestore"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+)
+
+type FlagDict struct {
+	helpFlag bool
+}
+
+const helpMessage = `
+Usage:
+  lip snapshot [options]
+  lip snapshot <command> [subcommand options] ...
+
+Commands:
+  create                      Save the installed state of the workspace.
+  diff                        Show the differences between two snapshots.
+  list                        List the saved snapshots.
+  restore                     Restore the workspace to a snapshot.
+
+Options:
+  -h, --help                  Show help.
+`
+
+func Run(ctx *context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("snapshot", flag.ContinueOnError)
+
+	// Rewrite the default usage message.
+	flagSet.Usage = func() {
+		// Do nothing.
+	}
+
+	var flagDict FlagDict
+	flagSet.BoolVar(&flagDict.helpFlag, "help", false, "")
+	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
+
+	if err := flagSet.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags\n\t%w", err)
+	}
+
+	// Help flag has the highest priority.
+	if flagDict.helpFlag {
+		fmt.Print(helpMessage)
+		return nil
+	}
+
+	// If there is a subcommand, run it and exit.
+	if flagSet.NArg() >= 1 {
+		switch flagSet.Arg(0) {
+		case "create":
+			if err := cmdlipsnapshotcreate.Run(ctx, flagSet.Args()[1:]); err != nil {
+				return err
+			}
+			return nil
+
+		case "diff":
+			if err := cmdlipsnapshotdiff.Run(ctx, flagSet.Args()[1:]); err != nil {
+				return err
+			}
+			return nil
+
+		case "list":
+			if err := cmdlipsnapshotlist.Run(ctx, flagSet.Args()[1:]); err != nil {
+				return err
+			}
+			return nil
+
+		case "restore":
+			if err := cmdlipsnapshotrestore.Run(ctx, flagSet.Args()[1:]); err != nil {
+				return err
+			}
+			return nil
+
+		default:
+			return errcode.Errorf(errcode.InvalidArgument, "unknown command: lip snapshot %v", flagSet.Arg(0))
+		}
+	}
+
+	return errcode.Errorf(errcode.InvalidArgument, "no command specified. See 'lip snapshot --help' for more information")
+}
@@ -0,0 +1,75 @@
+package cmdlipsnapshotcreate
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/envsnapshot"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	log "github.com/sirupsen/logrus"
+)
+
+type FlagDict struct {
+	helpFlag bool
+}
+
+const helpMessage = `
+Usage:
+  lip snapshot create [options] [<name>]
+
+Description:
+  Save the installed state of the workspace as a snapshot: the installed versions of the teeth,
+  their installation records and the checksums of the files they placed. The snapshot is named
+  by the current time if no name is given. Names may contain letters, digits, '.', '_' and '-'.
+
+Options:
+  -h, --help                  Show help.
+`
+
+func Run(ctx *context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("create", flag.ContinueOnError)
+
+	// Rewrite the default usage message.
+	flagSet.Usage = func() {
+		// Do nothing.
+	}
+
+	var flagDict FlagDict
+	flagSet.BoolVar(&flagDict.helpFlag, "help", false, "")
+	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
+
+	if err := flagSet.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags\n\t%w", err)
+	}
+
+	// Help flag has the highest priority.
+	if flagDict.helpFlag {
+		fmt.Print(helpMessage)
+		return nil
+	}
+
+	// At most one argument is allowed.
+	if flagSet.NArg() > 1 {
+		return errcode.Errorf(errcode.InvalidArgument, "expected at most one argument")
+	}
+
+	name := ctx.Clock().Now().UTC().Format("20060102-150405")
+	if flagSet.NArg() == 1 {
+		name = flagSet.Arg(0)
+	}
+
+	snapshot, err := envsnapshot.Capture(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to capture the installed state\n\t%w", err)
+	}
+
+	if err := envsnapshot.Save(ctx, snapshot); err != nil {
+		return fmt.Errorf("failed to save snapshot %v\n\t%w", name, err)
+	}
+
+	log.Infof(i18n.T("Saved snapshot %v with %v teeth."), name, len(snapshot.Teeth))
+
+	return nil
+}
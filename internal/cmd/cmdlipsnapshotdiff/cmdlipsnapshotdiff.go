@@ -0,0 +1,132 @@
+package cmdlipsnapshotdiff
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/envsnapshot"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/render"
+	"github.com/olekukonko/tablewriter"
+	log "github.com/sirupsen/logrus"
+)
+
+type FlagDict struct {
+	helpFlag bool
+}
+
+const helpMessage = `
+Usage:
+  lip snapshot diff [options] <snapshot> [<snapshot>]
+
+Description:
+  Show the teeth added, removed, upgraded, downgraded, or with changed files or installation
+  records from the first snapshot to the second one. If only one snapshot is given, it is
+  compared with the current installed state.
+
+Options:
+  -h, --help                  Show help.
+`
+
+func Run(ctx *context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("diff", flag.ContinueOnError)
+
+	// Rewrite the default usage message.
+	flagSet.Usage = func() {
+		// Do nothing.
+	}
+
+	var flagDict FlagDict
+	flagSet.BoolVar(&flagDict.helpFlag, "help", false, "")
+	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
+
+	if err := flagSet.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags\n\t%w", err)
+	}
+
+	// Help flag has the highest priority.
+	if flagDict.helpFlag {
+		fmt.Print(helpMessage)
+		return nil
+	}
+
+	// One or two arguments are required.
+	if flagSet.NArg() != 1 && flagSet.NArg() != 2 {
+		return errcode.Errorf(errcode.InvalidArgument, "invalid number of arguments")
+	}
+
+	from, err := envsnapshot.Load(ctx, flagSet.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to load snapshot %v\n\t%w", flagSet.Arg(0), err)
+	}
+
+	var to envsnapshot.Snapshot
+	if flagSet.NArg() == 2 {
+		to, err = envsnapshot.Load(ctx, flagSet.Arg(1))
+		if err != nil {
+			return fmt.Errorf("failed to load snapshot %v\n\t%w", flagSet.Arg(1), err)
+		}
+	} else {
+		to, err = envsnapshot.Capture(ctx, "")
+		if err != nil {
+			return fmt.Errorf("failed to capture the installed state\n\t%w", err)
+		}
+	}
+
+	changes, err := envsnapshot.Diff(from, to)
+	if err != nil {
+		return fmt.Errorf("failed to compare snapshots\n\t%w", err)
+	}
+
+	if len(changes) == 0 {
+		log.Info(i18n.T("No differences."))
+		return nil
+	}
+
+	PrintChanges(changes)
+
+	return nil
+}
+
+// changeRoles are the roles the kinds of changes are rendered as.
+var changeRoles = map[envsnapshot.ChangeKind]render.Role{
+	envsnapshot.AddedChange:         render.AddedRole,
+	envsnapshot.RemovedChange:       render.RemovedRole,
+	envsnapshot.UpgradedChange:      render.ChangedRole,
+	envsnapshot.DowngradedChange:    render.DowngradedRole,
+	envsnapshot.FilesChangedChange:  render.WarningRole,
+	envsnapshot.RecordChangedChange: render.ChangedRole,
+}
+
+// PrintChanges prints the changes between two snapshots as a table.
+func PrintChanges(changes []envsnapshot.Change) {
+	tableString := &strings.Builder{}
+	table := tablewriter.NewWriter(tableString)
+	table.SetHeader([]string{"Tooth", "Change", "Version"})
+
+	for _, change := range changes {
+		var version string
+		switch {
+		case change.From == nil:
+			version = change.To.Version
+
+		case change.To == nil:
+			version = change.From.Version
+
+		case change.From.Version != change.To.Version:
+			version = fmt.Sprintf("%v -> %v", change.From.Version, change.To.Version)
+
+		default:
+			version = change.To.Version
+		}
+
+		table.Append([]string{change.ToothRepoPath, render.Style(changeRoles[change.Kind], string(change.Kind)), version})
+	}
+
+	table.Render()
+
+	fmt.Print(tableString.String())
+}
@@ -0,0 +1,85 @@
+package cmdlipsnapshotlist
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/envsnapshot"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/olekukonko/tablewriter"
+)
+
+type FlagDict struct {
+	helpFlag bool
+}
+
+const helpMessage = `
+Usage:
+  lip snapshot list [options]
+
+Description:
+  List the saved snapshots with their creation time and number of teeth.
+
+Options:
+  -h, --help                  Show help.
+`
+
+func Run(ctx *context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("list", flag.ContinueOnError)
+
+	// Rewrite the default usage message.
+	flagSet.Usage = func() {
+		// Do nothing.
+	}
+
+	var flagDict FlagDict
+	flagSet.BoolVar(&flagDict.helpFlag, "help", false, "")
+	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
+
+	if err := flagSet.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags\n\t%w", err)
+	}
+
+	// Help flag has the highest priority.
+	if flagDict.helpFlag {
+		fmt.Print(helpMessage)
+		return nil
+	}
+
+	// Check if there are unexpected arguments.
+	if flagSet.NArg() != 0 {
+		return errcode.Errorf(errcode.InvalidArgument, "unexpected arguments: %v", flagSet.Args())
+	}
+
+	names, err := envsnapshot.List(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list snapshots\n\t%w", err)
+	}
+
+	tableData := make([][]string, 0, len(names))
+	for _, name := range names {
+		snapshot, err := envsnapshot.Load(ctx, name)
+		if err != nil {
+			return fmt.Errorf("failed to load snapshot %v\n\t%w", name, err)
+		}
+
+		tableData = append(tableData, []string{name, snapshot.CreatedAt.Local().Format("2006-01-02 15:04:05"),
+			fmt.Sprint(len(snapshot.Teeth))})
+	}
+
+	tableString := &strings.Builder{}
+	table := tablewriter.NewWriter(tableString)
+	table.SetHeader([]string{"Name", "Created At", "Teeth"})
+
+	for _, v := range tableData {
+		table.Append(v)
+	}
+
+	table.Render()
+
+	fmt.Print(tableString.String())
+
+	return nil
+}
@@ -0,0 +1,178 @@
+package cmdlipsnapshotrestore
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/lippkg/lip/internal/cmd/cmdlipinstall"
+	"github.com/lippkg/lip/internal/cmd/cmdlipsnapshotdiff"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/envsnapshot"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/history"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/install"
+	"github.com/lippkg/lip/internal/record"
+	"github.com/lippkg/lip/internal/snapshot"
+	log "github.com/sirupsen/logrus"
+)
+
+type FlagDict struct {
+	helpFlag bool
+	yesFlag  bool
+}
+
+const helpMessage = `
+Usage:
+  lip snapshot restore [options] <snapshot>
+
+Description:
+  Restore the workspace to a snapshot. Teeth of other versions or with changed files are
+  reinstalled at the versions in the snapshot, teeth not in the snapshot are uninstalled, and
+  the installation records are restored. The current state is saved as a snapshot named
+  pre-restore-<time> first, so that it can be restored if needed.
+
+  The teeth are reinstalled in one transaction, so they are rolled back if any of them fails.
+
+Options:
+  -h, --help                  Show help.
+  -y, --yes                   Assume yes to all prompts and run non-interactively.
+`
+
+func Run(ctx *context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("restore", flag.ContinueOnError)
+
+	// Rewrite the default usage message.
+	flagSet.Usage = func() {
+		// Do nothing.
+	}
+
+	var flagDict FlagDict
+	flagSet.BoolVar(&flagDict.helpFlag, "help", false, "")
+	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
+	flagSet.BoolVar(&flagDict.yesFlag, "yes", false, "")
+	flagSet.BoolVar(&flagDict.yesFlag, "y", false, "")
+
+	if err := flagSet.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags\n\t%w", err)
+	}
+
+	// Help flag has the highest priority.
+	if flagDict.helpFlag {
+		fmt.Print(helpMessage)
+		return nil
+	}
+
+	// Exactly one argument is required.
+	if flagSet.NArg() != 1 {
+		return errcode.Errorf(errcode.InvalidArgument, "expected exactly one argument")
+	}
+
+	target, err := envsnapshot.Load(ctx, flagSet.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to load snapshot %v\n\t%w", flagSet.Arg(0), err)
+	}
+
+	// 1. Compare the current state with the snapshot.
+
+	current, err := envsnapshot.Capture(ctx, "pre-restore-"+ctx.Clock().Now().UTC().Format("20060102-150405.000000"))
+	if err != nil {
+		return fmt.Errorf("failed to capture the installed state\n\t%w", err)
+	}
+
+	changes, err := envsnapshot.Diff(current, target)
+	if err != nil {
+		return fmt.Errorf("failed to compare with snapshot %v\n\t%w", target.Name, err)
+	}
+
+	if len(changes) == 0 {
+		log.Infof(i18n.T("The workspace is already at snapshot %v."), target.Name)
+		return nil
+	}
+
+	// 2. Prompt for confirmation.
+
+	log.Infof(i18n.T("The following changes will be made to restore snapshot %v:"), target.Name)
+	cmdlipsnapshotdiff.PrintChanges(changes)
+
+	if !flagDict.yesFlag {
+		if ok, err := ctx.Prompter().Confirm(i18n.T("Do you want to continue? [y/N]"), false); err != nil {
+			return err
+		} else if !ok {
+			return errcode.Errorf(errcode.Aborted, "aborted")
+		}
+	}
+
+	// 3. Save the current state to go back to.
+
+	if err := envsnapshot.Save(ctx, current); err != nil {
+		return fmt.Errorf("failed to save the current state\n\t%w", err)
+	}
+
+	log.Infof(i18n.T("Saved the current state as snapshot %v."), current.Name)
+
+	// 4. Reinstall the teeth of other versions or with changed files.
+
+	installArgs := []string{"--yes", "--force-reinstall", "--no-dependencies"}
+	for _, change := range changes {
+		if change.To == nil || change.Kind == envsnapshot.RecordChangedChange {
+			continue
+		}
+
+		installArgs = append(installArgs, fmt.Sprintf("%v@%v", change.ToothRepoPath, change.To.Version))
+	}
+
+	if len(installArgs) != 3 {
+		if err := cmdlipinstall.Run(ctx, installArgs); err != nil {
+			return fmt.Errorf("failed to install teeth in snapshot %v\n\t%w", target.Name, err)
+		}
+	}
+
+	// 5. Uninstall the teeth not in the snapshot.
+
+	uninstallChanges := make([]history.Change, 0)
+	for _, change := range changes {
+		if change.Kind != envsnapshot.RemovedChange {
+			continue
+		}
+
+		uninstallChange, err := history.GetUninstallChange(ctx, change.ToothRepoPath)
+		if err != nil {
+			return err
+		}
+
+		if err := install.Uninstall(ctx, change.ToothRepoPath); err != nil {
+			return fmt.Errorf("failed to uninstall tooth %v\n\t%w", change.ToothRepoPath, err)
+		}
+
+		uninstallChanges = append(uninstallChanges, uninstallChange)
+
+		if err := snapshot.RemoveAll(ctx, change.ToothRepoPath); err != nil {
+			return fmt.Errorf("failed to remove snapshots of tooth %v\n\t%w", change.ToothRepoPath, err)
+		}
+
+		if err := install.RemoveVersions(ctx, change.ToothRepoPath); err != nil {
+			return fmt.Errorf("failed to remove versions of tooth %v\n\t%w", change.ToothRepoPath, err)
+		}
+	}
+
+	if err := history.Append(ctx, history.UninstallOperation, uninstallChanges, 0); err != nil {
+		return fmt.Errorf("failed to record history\n\t%w", err)
+	}
+
+	// 6. Restore the records in the snapshot.
+
+	for _, snapshotTooth := range target.Teeth {
+		if err := record.Save(ctx, record.Record{
+			ToothRepoPath: snapshotTooth.ToothRepoPath,
+			IsExplicit:    snapshotTooth.IsExplicit,
+			Override:      snapshotTooth.Override,
+		}); err != nil {
+			return fmt.Errorf("failed to save record of tooth %v\n\t%w", snapshotTooth.ToothRepoPath, err)
+		}
+	}
+
+	log.Infof(i18n.T("Restored snapshot %v."), target.Name)
+
+	return nil
+}
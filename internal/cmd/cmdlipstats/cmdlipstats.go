@@ -0,0 +1,172 @@
+package cmdlipstats
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/diskspace"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/stats"
+	"github.com/olekukonko/tablewriter"
+	log "github.com/sirupsen/logrus"
+)
+
+type FlagDict struct {
+	helpFlag  bool
+	jsonFlag  bool
+	resetFlag bool
+}
+
+const helpMessage = `
+Usage:
+  lip stats [options]
+
+Description:
+  Show the usage statistics collected on this machine: the cache hit rate, the downloads from
+  each host with their sizes and speeds, and the durations of installs. They help tune the
+  proxy and mirror configuration. The statistics never leave the machine.
+
+Options:
+  -h, --help                  Show help.
+  --json                      Output in JSON format.
+  --reset                     Clear the statistics.
+`
+
+func Run(ctx *context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("stats", flag.ContinueOnError)
+
+	// Rewrite the default usage message.
+	flagSet.Usage = func() {
+		// Do nothing.
+	}
+
+	var flagDict FlagDict
+	flagSet.BoolVar(&flagDict.helpFlag, "help", false, "")
+	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
+	flagSet.BoolVar(&flagDict.jsonFlag, "json", false, "")
+	flagSet.BoolVar(&flagDict.resetFlag, "reset", false, "")
+
+	if err := flagSet.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags\n\t%w", err)
+	}
+
+	// Help flag has the highest priority.
+	if flagDict.helpFlag {
+		fmt.Print(helpMessage)
+		return nil
+	}
+
+	// Check if there are unexpected arguments.
+	if flagSet.NArg() != 0 {
+		return errcode.Errorf(errcode.InvalidArgument, "unexpected arguments: %v", flagSet.Args())
+	}
+
+	if flagDict.resetFlag {
+		if err := stats.Reset(ctx); err != nil {
+			return fmt.Errorf("failed to reset usage statistics\n\t%w", err)
+		}
+
+		log.Info(i18n.T("Cleared the usage statistics."))
+
+		return nil
+	}
+
+	currentStats, err := stats.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load usage statistics\n\t%w", err)
+	}
+
+	if flagDict.jsonFlag {
+		jsonBytes, err := json.Marshal(currentStats)
+		if err != nil {
+			return fmt.Errorf("failed to marshal JSON\n\t%w", err)
+		}
+
+		fmt.Print(string(jsonBytes))
+
+		return nil
+	}
+
+	fmt.Print(formatStats(currentStats))
+
+	return nil
+}
+
+// ---------------------------------------------------------------------
+
+// formatDuration returns a duration in milliseconds as a string, rounded to 0.1 seconds if it
+// is longer than a second.
+func formatDuration(ms int64) string {
+	duration := time.Duration(ms) * time.Millisecond
+	if duration < time.Second {
+		return duration.String()
+	}
+
+	return duration.Round(100 * time.Millisecond).String()
+}
+
+// formatStats returns the statistics as a human-readable report.
+func formatStats(currentStats stats.Stats) string {
+	builder := &strings.Builder{}
+
+	fmt.Fprintf(builder, "Since: %v\n\n", currentStats.Since.Local().Format("2006-01-02 15:04:05"))
+
+	cache := currentStats.Cache
+	fmt.Fprintln(builder, "Cache:")
+	fmt.Fprintf(builder, "  Hits: %v (%v served from the cache)\n", cache.Hits, diskspace.FormatSize(cache.HitBytes))
+	fmt.Fprintf(builder, "  Misses: %v\n", cache.Misses)
+	if cache.Hits+cache.Misses != 0 {
+		fmt.Fprintf(builder, "  Hit rate: %.1f%%\n", float64(cache.Hits)*100/float64(cache.Hits+cache.Misses))
+	}
+	fmt.Fprintln(builder)
+
+	fmt.Fprintln(builder, "Downloads:")
+	if len(currentStats.Hosts) == 0 {
+		fmt.Fprintln(builder, "  None")
+	} else {
+		hostNames := make([]string, 0, len(currentStats.Hosts))
+		for hostName := range currentStats.Hosts {
+			hostNames = append(hostNames, hostName)
+		}
+		sort.Strings(hostNames)
+
+		table := tablewriter.NewWriter(builder)
+		table.SetHeader([]string{"Host", "Downloads", "Failures", "Size", "Average Speed"})
+		table.SetAutoFormatHeaders(false)
+
+		for _, hostName := range hostNames {
+			host := currentStats.Hosts[hostName]
+
+			speed := "-"
+			if host.DurationMS != 0 {
+				speed = diskspace.FormatSize(host.Bytes*1000/host.DurationMS) + "/s"
+			}
+
+			table.Append([]string{hostName, fmt.Sprint(host.Downloads), fmt.Sprint(host.Failures),
+				diskspace.FormatSize(host.Bytes), speed})
+		}
+
+		table.Render()
+	}
+	fmt.Fprintln(builder)
+
+	installs := currentStats.Installs
+	fmt.Fprintln(builder, "Installs:")
+	fmt.Fprintf(builder, "  Count: %v (%v teeth installed)\n", installs.Count, installs.Teeth)
+	if installs.Count != 0 {
+		fmt.Fprintf(builder, "  Resolving: %v on average, %v at most\n",
+			formatDuration(installs.ResolutionMS/int64(installs.Count)), formatDuration(installs.MaxResolutionMS))
+		fmt.Fprintf(builder, "  Downloading: %v on average, %v at most\n",
+			formatDuration(installs.DownloadMS/int64(installs.Count)), formatDuration(installs.MaxDownloadMS))
+		fmt.Fprintf(builder, "  Installing: %v on average, %v at most\n",
+			formatDuration(installs.InstallationMS/int64(installs.Count)), formatDuration(installs.MaxInstallationMS))
+	}
+
+	return builder.String()
+}
@@ -0,0 +1,207 @@
+package cmdlipswitch
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/history"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/install"
+	"github.com/lippkg/lip/internal/record"
+	"github.com/lippkg/lip/internal/tooth"
+	log "github.com/sirupsen/logrus"
+)
+
+type FlagDict struct {
+	helpFlag   bool
+	yesFlag    bool
+	listFlag   bool
+	removeFlag bool
+}
+
+const helpMessage = `
+Usage:
+  lip switch [options] <tooth repository URL>@<version>
+  lip switch --list <tooth repository URL>
+
+Description:
+  Switch a tooth to another version installed side by side with lip install --side-by-side,
+  without downloading or extracting it again.
+
+Options:
+  -h, --help                  Show help.
+  -y, --yes                   Skip confirmation.
+  --list                      List the versions installed side by side.
+  --remove                    Remove the version instead of switching to it. The active version
+                              cannot be removed.
+`
+
+func Run(ctx *context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("switch", flag.ContinueOnError)
+
+	// Rewrite the default usage message.
+	flagSet.Usage = func() {
+		// Do nothing.
+	}
+
+	var flagDict FlagDict
+	flagSet.BoolVar(&flagDict.helpFlag, "help", false, "")
+	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
+	flagSet.BoolVar(&flagDict.yesFlag, "yes", false, "")
+	flagSet.BoolVar(&flagDict.yesFlag, "y", false, "")
+	flagSet.BoolVar(&flagDict.listFlag, "list", false, "")
+	flagSet.BoolVar(&flagDict.removeFlag, "remove", false, "")
+
+	if err := flagSet.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags\n\t%w", err)
+	}
+
+	// Help flag has the highest priority.
+	if flagDict.helpFlag {
+		fmt.Print(helpMessage)
+		return nil
+	}
+
+	// Exactly one argument is required.
+	if flagSet.NArg() != 1 {
+		return errcode.Errorf(errcode.InvalidArgument, "expected exactly one argument")
+	}
+
+	toothRepoPath, targetVersionString, isVersionSpecified := strings.Cut(flagSet.Arg(0), "@")
+
+	versions, err := install.ListVersions(ctx, toothRepoPath)
+	if err != nil {
+		return fmt.Errorf("failed to list versions of tooth %v\n\t%w", toothRepoPath, err)
+	}
+
+	// The active version is the installed one, if it is installed side by side.
+	isInstalled, err := tooth.IsInstalled(ctx, toothRepoPath)
+	if err != nil {
+		return fmt.Errorf("failed to check if tooth is installed\n\t%w", err)
+	}
+
+	var currentMetadata tooth.Metadata
+	if isInstalled {
+		currentMetadata, err = tooth.GetMetadata(ctx, toothRepoPath)
+		if err != nil {
+			return fmt.Errorf("failed to find installed tooth metadata\n\t%w", err)
+		}
+	}
+
+	if flagDict.listFlag {
+		for _, version := range versions {
+			if isInstalled && version.EQ(currentMetadata.Version()) {
+				fmt.Printf("%v (active)\n", version)
+			} else {
+				fmt.Printf("%v\n", version)
+			}
+		}
+		return nil
+	}
+
+	if !isVersionSpecified {
+		return errcode.Errorf(errcode.InvalidArgument, "a version is required, e.g. %v@1.0.0", toothRepoPath)
+	}
+
+	targetVersion, err := semver.Parse(targetVersionString)
+	if err != nil {
+		return errcode.Errorf(errcode.InvalidArgument, "invalid version %v", targetVersionString)
+	}
+
+	isFound := false
+	for _, version := range versions {
+		if version.EQ(targetVersion) {
+			isFound = true
+			break
+		}
+	}
+
+	if !isFound {
+		return errcode.Errorf(errcode.NotInstalled, "%v@%v is not installed side by side", toothRepoPath,
+			targetVersion)
+	}
+
+	isActive := isInstalled && currentMetadata.Version().EQ(targetVersion)
+
+	if flagDict.removeFlag {
+		if isActive {
+			return errcode.Errorf(errcode.InvalidArgument, "cannot remove the active version %v of %v",
+				targetVersion, toothRepoPath)
+		}
+
+		if err := install.RemoveVersion(ctx, toothRepoPath, targetVersion); err != nil {
+			return fmt.Errorf("failed to remove %v@%v\n\t%w", toothRepoPath, targetVersion, err)
+		}
+
+		log.Infof(i18n.T("Removed %v@%v."), toothRepoPath, targetVersion)
+
+		return nil
+	}
+
+	if isActive {
+		log.Infof(i18n.T("%v@%v is already active."), toothRepoPath, targetVersion)
+		return nil
+	}
+
+	// 1. Prompt for confirmation.
+
+	if !flagDict.yesFlag && isInstalled {
+		log.Infof(i18n.T("Tooth %v will be switched from %v to %v."), toothRepoPath, currentMetadata.Version(),
+			targetVersion)
+		if ok, err := ctx.Prompter().Confirm(i18n.T("Do you want to continue? [y/N]"), false); err != nil {
+			return err
+		} else if !ok {
+			return errcode.Errorf(errcode.Aborted, "aborted")
+		}
+	}
+
+	// 2. Deactivate the installed version and keep the record.
+
+	currentRecord := record.Record{
+		ToothRepoPath: toothRepoPath,
+		IsExplicit:    true,
+	}
+
+	if isInstalled {
+		currentRecord, err = record.Get(ctx, toothRepoPath)
+		if err != nil {
+			return fmt.Errorf("failed to get record of tooth %v\n\t%w", toothRepoPath, err)
+		}
+
+		if err := install.Uninstall(ctx, toothRepoPath); err != nil {
+			return fmt.Errorf("failed to uninstall tooth %v\n\t%w", toothRepoPath, err)
+		}
+	}
+
+	// 3. Activate the target version.
+
+	if err := install.Activate(ctx, toothRepoPath, targetVersion, flagDict.yesFlag); err != nil {
+		return fmt.Errorf("failed to activate %v@%v\n\t%w", toothRepoPath, targetVersion, err)
+	}
+
+	if err := record.Save(ctx, currentRecord); err != nil {
+		return fmt.Errorf("failed to save record of tooth %v\n\t%w", toothRepoPath, err)
+	}
+
+	change := history.Change{
+		ToothRepoPath: toothRepoPath,
+		To:            targetVersion.String(),
+	}
+
+	if isInstalled {
+		change.From = currentMetadata.Version().String()
+		change.WasExplicit = currentRecord.IsExplicit
+	}
+
+	if err := history.Append(ctx, history.SwitchOperation, []history.Change{change}, 0); err != nil {
+		return fmt.Errorf("failed to record history\n\t%w", err)
+	}
+
+	log.Info(i18n.T("Done."))
+
+	return nil
+}
@@ -0,0 +1,360 @@
+package cmdlipsync
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/cmd/cmdlipautoremove"
+	"github.com/lippkg/lip/internal/cmd/cmdlipinstall"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/history"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/install"
+	"github.com/lippkg/lip/internal/record"
+	"github.com/lippkg/lip/internal/render"
+	"github.com/lippkg/lip/internal/tooth"
+	"github.com/lippkg/lip/internal/workspace"
+	log "github.com/sirupsen/logrus"
+)
+
+type FlagDict struct {
+	helpFlag        bool
+	yesFlag         bool
+	profileFlag     string
+	allowYankedFlag bool
+	symlinkFlag     bool
+	hardlinkFlag    bool
+	vendorFlag      bool
+	skipFlag        string
+}
+
+const helpMessage = `
+Usage:
+  lip sync [options]
+
+Description:
+  Make installed teeth match the workspace manifest (workspace.json):
+
+  - install missing teeth.
+  - upgrade or downgrade teeth whose versions do not satisfy the manifest.
+  - uninstall teeth not in the manifest and not required by any tooth in it.
+
+Options:
+  -h, --help                  Show help.
+  -y, --yes                   Skip confirmation.
+  --profile <profile>         Also sync the teeth of the profile in the workspace manifest.
+  --allow-yanked              Allow selecting versions yanked from the registry.
+  --symlink                   Symlink placed files from the store instead of copying them.
+  --hardlink                  Hard-link placed files from the content store shared by all workspaces.
+  --vendor                    Resolve teeth exclusively from the vendor directory made by lip vendor,
+                              without accessing the network.
+  --skip <tag>[,...]          Do not place files tagged with the tags in tooth.json, e.g. docs,
+                              examples or source.
+`
+
+// syncItem is a tooth to install or to change the version of.
+type syncItem struct {
+	toothRepoPath  string
+	currentVersion *semver.Version
+	targetVersion  semver.Version
+}
+
+func Run(ctx *context.Context, args []string) error {
+
+	flagSet := flag.NewFlagSet("sync", flag.ContinueOnError)
+
+	// Rewrite the default usage message.
+	flagSet.Usage = func() {
+		// Do nothing.
+	}
+
+	var flagDict FlagDict
+	flagSet.BoolVar(&flagDict.helpFlag, "help", false, "")
+	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
+	flagSet.BoolVar(&flagDict.yesFlag, "yes", false, "")
+	flagSet.BoolVar(&flagDict.yesFlag, "y", false, "")
+	flagSet.StringVar(&flagDict.profileFlag, "profile", "", "")
+	flagSet.BoolVar(&flagDict.allowYankedFlag, "allow-yanked", false, "")
+	flagSet.BoolVar(&flagDict.symlinkFlag, "symlink", false, "")
+	flagSet.BoolVar(&flagDict.hardlinkFlag, "hardlink", false, "")
+	flagSet.BoolVar(&flagDict.vendorFlag, "vendor", false, "")
+	flagSet.StringVar(&flagDict.skipFlag, "skip", "", "")
+	err := flagSet.Parse(args)
+	if err != nil {
+		return fmt.Errorf("failed to parse flags\n\t%w", err)
+	}
+
+	// Help flag has the highest priority.
+	if flagDict.helpFlag {
+		fmt.Print(helpMessage)
+		return nil
+	}
+
+	// Check if there are unexpected arguments.
+	if flagSet.NArg() != 0 {
+		return errcode.Errorf(errcode.InvalidArgument, "unexpected arguments: %v", flagSet.Args())
+	}
+
+	// The flags are set on a copy of the context, so that they do not apply to later commands
+	// run with it.
+	ctx = ctx.Copy()
+
+	ctx.SetAllowYanked(flagDict.allowYankedFlag)
+	if flagDict.symlinkFlag && flagDict.hardlinkFlag {
+		return errcode.Errorf(errcode.InvalidArgument, "symlink and hardlink flags are mutually exclusive")
+	}
+
+	ctx.SetSymlink(flagDict.symlinkFlag)
+	ctx.SetHardlink(flagDict.hardlinkFlag)
+
+	// Vendor mode implies offline mode, so that nothing is fetched from elsewhere.
+	if flagDict.vendorFlag {
+		ctx.SetVendor(true)
+		ctx.SetOffline(true)
+	}
+
+	manifest, err := workspace.LoadManifest(ctx.FS())
+	if err != nil {
+		return fmt.Errorf("failed to load workspace manifest\n\t%w", err)
+	}
+
+	requirements, requirementsAsStrings, err := manifest.Requirements(flagDict.profileFlag)
+	if err != nil {
+		return fmt.Errorf("failed to get requirements of workspace manifest\n\t%w", err)
+	}
+
+	// 1. Find teeth to install or change.
+
+	syncItems, err := getSyncItems(ctx, requirements, requirementsAsStrings)
+	if err != nil {
+		return fmt.Errorf("failed to find teeth to sync\n\t%w", err)
+	}
+
+	// 2. Prompt for confirmation.
+
+	if len(syncItems) != 0 && !flagDict.yesFlag {
+		if err := askForConfirmation(ctx, syncItems); err != nil {
+			return err
+		}
+	}
+
+	// 3. Download the target versions first, so that nothing is uninstalled if one of them
+	// cannot be downloaded. Then uninstall teeth of mismatched versions and install the target
+	// versions, reinstalling the uninstalled versions if installation fails.
+
+	for _, item := range syncItems {
+		if _, err := cmdlipinstall.DownloadToothArchive(ctx, item.toothRepoPath, item.targetVersion); err != nil {
+			return fmt.Errorf("failed to download tooth %v@%v\n\t%w", item.toothRepoPath, item.targetVersion, err)
+		}
+	}
+
+	installArgs := getInstallArgs(flagDict)
+
+	specifierStrings := make([]string, 0)
+	uninstalledItems := make([]uninstalledItem, 0)
+	uninstallChanges := make([]history.Change, 0)
+	for _, item := range syncItems {
+		if item.currentVersion != nil {
+			change, err := history.GetUninstallChange(ctx, item.toothRepoPath)
+			if err != nil {
+				rollBackSync(ctx, installArgs, uninstalledItems)
+				return err
+			}
+
+			currentRecord, err := record.Get(ctx, item.toothRepoPath)
+			if err != nil {
+				rollBackSync(ctx, installArgs, uninstalledItems)
+				return fmt.Errorf("failed to get record of tooth %v\n\t%w", item.toothRepoPath, err)
+			}
+
+			if err := install.Uninstall(ctx, item.toothRepoPath); err != nil {
+				rollBackSync(ctx, installArgs, uninstalledItems)
+				return fmt.Errorf("failed to uninstall tooth %v\n\t%w", item.toothRepoPath, err)
+			}
+
+			uninstalledItems = append(uninstalledItems, uninstalledItem{
+				toothRepoPath: item.toothRepoPath,
+				version:       *item.currentVersion,
+				record:        currentRecord,
+			})
+			uninstallChanges = append(uninstallChanges, change)
+		}
+
+		specifierStrings = append(specifierStrings, fmt.Sprintf("%v@%v", item.toothRepoPath, item.targetVersion))
+	}
+
+	if len(specifierStrings) != 0 {
+		if err := cmdlipinstall.Run(ctx, append(installArgs, specifierStrings...)); err != nil {
+			rollBackSync(ctx, installArgs, uninstalledItems)
+			return fmt.Errorf("failed to install teeth\n\t%w", err)
+		}
+	}
+
+	if err := history.Append(ctx, history.UninstallOperation, uninstallChanges, 0); err != nil {
+		return fmt.Errorf("failed to record history\n\t%w", err)
+	}
+
+	// 4. Only teeth in the manifest are regarded as explicitly installed. Then uninstall the
+	// teeth no longer required.
+
+	metadataList, err := tooth.GetAllMetadata(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list all installed teeth\n\t%w", err)
+	}
+
+	for _, metadata := range metadataList {
+		_, isInManifest := requirements[metadata.ToothRepoPath()]
+
+		if err := record.Save(ctx, record.Record{
+			ToothRepoPath: metadata.ToothRepoPath(),
+			IsExplicit:    isInManifest,
+		}); err != nil {
+			return fmt.Errorf("failed to save record of tooth %v\n\t%w", metadata.ToothRepoPath(), err)
+		}
+	}
+
+	autoremoveArgs := make([]string, 0)
+	if flagDict.yesFlag {
+		autoremoveArgs = append(autoremoveArgs, "--yes")
+	}
+
+	if err := cmdlipautoremove.Run(ctx, autoremoveArgs); err != nil {
+		return fmt.Errorf("failed to uninstall teeth not in the workspace manifest\n\t%w", err)
+	}
+
+	return nil
+}
+
+// ---------------------------------------------------------------------
+
+// uninstalledItem is a tooth uninstalled to install another version of it, kept to reinstall it
+// if installation fails.
+type uninstalledItem struct {
+	toothRepoPath string
+	version       semver.Version
+	record        record.Record
+}
+
+// getInstallArgs returns the arguments of lip install for the flags of lip sync.
+func getInstallArgs(flagDict FlagDict) []string {
+	installArgs := []string{"--yes"}
+	if flagDict.allowYankedFlag {
+		installArgs = append(installArgs, "--allow-yanked")
+	}
+	if flagDict.symlinkFlag {
+		installArgs = append(installArgs, "--symlink")
+	}
+	if flagDict.hardlinkFlag {
+		installArgs = append(installArgs, "--hardlink")
+	}
+	if flagDict.vendorFlag {
+		installArgs = append(installArgs, "--vendor")
+	}
+	if flagDict.skipFlag != "" {
+		installArgs = append(installArgs, "--skip", flagDict.skipFlag)
+	}
+
+	return installArgs
+}
+
+// rollBackSync reinstalls the uninstalled versions of teeth and restores their records. Their
+// dependencies are left as they were, since installation rolls back its own changes.
+func rollBackSync(ctx *context.Context, installArgs []string, uninstalledItems []uninstalledItem) {
+	if len(uninstalledItems) == 0 {
+		return
+	}
+
+	// The teeth are reinstalled even if syncing is interrupted.
+	ctx = ctx.WithoutCancel()
+
+	specifierStrings := make([]string, 0, len(uninstalledItems))
+	for _, item := range uninstalledItems {
+		specifierStrings = append(specifierStrings, fmt.Sprintf("%v@%v", item.toothRepoPath, item.version))
+	}
+
+	args := append(append([]string{}, installArgs...), "--no-dependencies")
+	if err := cmdlipinstall.Run(ctx, append(args, specifierStrings...)); err != nil {
+		log.Warnf(i18n.T("Failed to reinstall %v, run lip sync again to install them\n\t%v"), specifierStrings, err)
+		return
+	}
+
+	for _, item := range uninstalledItems {
+		if err := record.Save(ctx, item.record); err != nil {
+			log.Warnf(i18n.T("Failed to restore record of tooth %v\n\t%v"), item.toothRepoPath, err)
+		}
+	}
+}
+
+// askForConfirmation asks for confirmation before syncing the teeth.
+func askForConfirmation(ctx *context.Context, syncItems []syncItem) error {
+
+	log.Info(render.Label(render.AddedRole, i18n.T("The following teeth will be installed:")))
+	for _, item := range syncItems {
+		if item.currentVersion == nil {
+			log.Infof("  %v@%v", item.toothRepoPath, item.targetVersion)
+		} else {
+			log.Infof("  %v@%v (from %v)", item.toothRepoPath, item.targetVersion, item.currentVersion)
+		}
+	}
+
+	// Ask for confirmation.
+	if ok, err := ctx.Prompter().Confirm(i18n.T("Do you want to continue? [y/N]"), false); err != nil {
+		return err
+	} else if !ok {
+		return errcode.Errorf(errcode.Aborted, "aborted")
+	}
+
+	return nil
+}
+
+// getSyncItems returns the teeth that are not installed or whose installed versions do not
+// satisfy the requirements, along with the versions to install.
+func getSyncItems(ctx *context.Context, requirements map[string]semver.Range,
+	requirementsAsStrings map[string]string) ([]syncItem, error) {
+	debugLogger := ctx.Logger().WithFields(log.Fields{
+		"package": "cmdlipsync",
+		"method":  "getSyncItems",
+	})
+
+	syncItems := make([]syncItem, 0)
+
+	for toothRepoPath, versionRange := range requirements {
+		isInstalled, err := tooth.IsInstalled(ctx, toothRepoPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check if tooth is installed\n\t%w", err)
+		}
+
+		var currentVersion *semver.Version
+		if isInstalled {
+			currentMetadata, err := tooth.GetMetadata(ctx, toothRepoPath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to find installed tooth metadata\n\t%w", err)
+			}
+
+			version := currentMetadata.Version()
+			if versionRange(version) {
+				debugLogger.Debugf("Tooth %v@%v satisfies %v, skip", toothRepoPath, version,
+					requirementsAsStrings[toothRepoPath])
+				continue
+			}
+
+			currentVersion = &version
+		}
+
+		targetVersion, err := cmdlipinstall.GetLatestSupportedVersion(ctx, toothRepoPath, versionRange)
+		if err != nil {
+			return nil, errcode.Errorf(errcode.ResolveConflict, "no available version in %v found for tooth %v\n\t%w",
+				requirementsAsStrings[toothRepoPath], toothRepoPath, err)
+		}
+
+		syncItems = append(syncItems, syncItem{
+			toothRepoPath:  toothRepoPath,
+			currentVersion: currentVersion,
+			targetVersion:  targetVersion,
+		})
+	}
+
+	return syncItems, nil
+}
@@ -4,9 +4,12 @@ import (
 	"flag"
 	"fmt"
 
+	"github.com/lippkg/lip/internal/cmd/cmdliptoothconvert"
 	"github.com/lippkg/lip/internal/cmd/cmdliptoothinit"
 	"github.com/lippkg/lip/internal/cmd/cmdliptoothpack"
+	"github.com/lippkg/lip/internal/cmd/cmdliptoothvalidate"
 	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
 )
 
 type FlagDict struct {
@@ -19,8 +22,10 @@ Usage:
   lip tooth <command> [subcommand options] ...
 
 Commands:
+  convert                     Convert tooth metadata between JSON, YAML and TOML.
   init                        Initialize and writes a new tooth.json file in the current directory.
   pack                        Pack the current directory into a tooth file.
+  validate                    Validate tooth.json against the JSON schema.
 
 Options:
   -h, --help                  Show help.
@@ -52,6 +57,13 @@ func Run(ctx *context.Context, args []string) error {
 	// If there is a subcommand, run it and exit.
 	if flagSet.NArg() >= 1 {
 		switch flagSet.Arg(0) {
+		case "convert":
+			err := cmdliptoothconvert.Run(ctx, flagSet.Args()[1:])
+			if err != nil {
+				return err
+			}
+			return nil
+
 		case "init":
 			err := cmdliptoothinit.Run(ctx, flagSet.Args()[1:])
 			if err != nil {
@@ -66,11 +78,17 @@ func Run(ctx *context.Context, args []string) error {
 			}
 			return nil
 
+		case "validate":
+			err := cmdliptoothvalidate.Run(ctx, flagSet.Args()[1:])
+			if err != nil {
+				return err
+			}
+			return nil
+
 		default:
-			return fmt.Errorf("unknown command: lip tooth %v", flagSet.Arg(0))
+			return errcode.Errorf(errcode.InvalidArgument, "unknown command: lip tooth %v", flagSet.Arg(0))
 		}
 	}
 
-	return fmt.Errorf(
-		"no command specified. See 'lip tooth --help' for more information")
+	return errcode.Errorf(errcode.InvalidArgument, "no command specified. See 'lip tooth --help' for more information")
 }
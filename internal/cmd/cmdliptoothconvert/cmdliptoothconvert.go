@@ -0,0 +1,109 @@
+package cmdliptoothconvert
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/tooth"
+	log "github.com/sirupsen/logrus"
+)
+
+type FlagDict struct {
+	helpFlag bool
+}
+
+const helpMessage = `
+Usage:
+  lip tooth convert [options] <input path> <output path>
+
+Description:
+  Convert tooth metadata between tooth.json, tooth.yaml and tooth.toml. The formats are
+  determined by the extensions of the paths. The output path must not already exist.
+
+Options:
+  -h, --help                  Show help.
+`
+
+func Run(ctx *context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("convert", flag.ContinueOnError)
+
+	// Rewrite the default usage message.
+	flagSet.Usage = func() {
+		// Do nothing.
+	}
+
+	var flagDict FlagDict
+	flagSet.BoolVar(&flagDict.helpFlag, "help", false, "")
+	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
+
+	if err := flagSet.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags\n\t%w", err)
+	}
+
+	// Help flag has the highest priority.
+	if flagDict.helpFlag {
+		fmt.Print(helpMessage)
+		return nil
+	}
+
+	if flagSet.NArg() != 2 {
+		return errcode.Errorf(errcode.InvalidArgument, "expected exactly two arguments")
+	}
+
+	inputPath := flagSet.Arg(0)
+	outputPath := flagSet.Arg(1)
+
+	if err := convert(inputPath, outputPath); err != nil {
+		return fmt.Errorf("failed to convert %v to %v\n\t%w", inputPath, outputPath, err)
+	}
+
+	log.Infof(i18n.T("Converted %v to %v."), inputPath, outputPath)
+
+	return nil
+}
+
+// ---------------------------------------------------------------------
+
+// convert converts the metadata file at inputPath to the format of outputPath.
+func convert(inputPath string, outputPath string) error {
+	inputFormat, err := tooth.ParseMetadataFormat(inputPath)
+	if err != nil {
+		return err
+	}
+
+	outputFormat, err := tooth.ParseMetadataFormat(outputPath)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(outputPath); err == nil {
+		return errcode.Errorf(errcode.InvalidArgument, "output path %v already exists", outputPath)
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to stat %v\n\t%w", outputPath, err)
+	}
+
+	data, err := os.ReadFile(inputPath)
+	if err != nil {
+		return fmt.Errorf("failed to read %v\n\t%w", inputPath, err)
+	}
+
+	// Refuse to convert invalid metadata, which would fail later in a less obvious way.
+	if _, err := tooth.MakeMetadataInFormat(data, inputFormat); err != nil {
+		return fmt.Errorf("failed to parse %v\n\t%w", inputPath, err)
+	}
+
+	outputData, err := tooth.ConvertMetadata(data, inputFormat, outputFormat)
+	if err != nil {
+		return fmt.Errorf("failed to convert metadata\n\t%w", err)
+	}
+
+	if err := os.WriteFile(outputPath, outputData, 0644); err != nil {
+		return fmt.Errorf("failed to write %v\n\t%w", outputPath, err)
+	}
+
+	return nil
+}
@@ -7,6 +7,8 @@ import (
 	"os"
 
 	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
 	"github.com/lippkg/lip/internal/path"
 	log "github.com/sirupsen/logrus"
 
@@ -65,7 +67,7 @@ func Run(ctx *context.Context, args []string) error {
 
 	// Check if there are unexpected arguments.
 	if flagSet.NArg() != 0 {
-		return fmt.Errorf("unexpected arguments: %v", flagSet.Args())
+		return errcode.Errorf(errcode.InvalidArgument, "unexpected arguments: %v", flagSet.Args())
 	}
 
 	if err := initTooth(ctx); err != nil {
@@ -83,7 +85,7 @@ func initTooth(ctx *context.Context) error {
 	// Check if tooth.json already exists.
 	_, err := os.Stat("tooth.json")
 	if err == nil {
-		return fmt.Errorf("tooth.json already exists")
+		return i18n.Errorf("tooth.json already exists")
 	}
 
 	rawMetadata := metadataTemplate
@@ -92,27 +94,27 @@ func initTooth(ctx *context.Context) error {
 	var ans string
 	scanner := bufio.NewScanner(os.Stdin)
 
-	log.Info("What is the tooth repo path? (e.g. github.com/tooth-hub/llbds3)")
+	log.Info(i18n.T("What is the tooth repo path? (e.g. github.com/tooth-hub/llbds3)"))
 	scanner.Scan()
 	ans = scanner.Text()
 
 	if !tooth.IsValidToothRepoPath(ans) {
-		return fmt.Errorf("invalid tooth repo path %v\n\t%w", ans, err)
+		return errcode.Errorf(errcode.InvalidArgument, "invalid tooth repo path %v\n\t%w", ans, err)
 	}
 
 	rawMetadata.Tooth = ans
 
-	log.Info("What is the name?")
+	log.Info(i18n.T("What is the name?"))
 	scanner.Scan()
 	ans = scanner.Text()
 	rawMetadata.Info.Name = ans
 
-	log.Info("What is the description?")
+	log.Info(i18n.T("What is the description?"))
 	scanner.Scan()
 	ans = scanner.Text()
 	rawMetadata.Info.Description = ans
 
-	log.Info("What is the author? Please input your GitHub username.")
+	log.Info(i18n.T("What is the author? Please input your GitHub username."))
 	scanner.Scan()
 	ans = scanner.Text()
 	rawMetadata.Info.Author = ans
@@ -149,7 +151,7 @@ func initTooth(ctx *context.Context) error {
 		return fmt.Errorf("failed to write tooth.json\n\t%w", err)
 	}
 
-	log.Info("Successfully initialized a new tooth.")
+	log.Info(i18n.T("Successfully initialized a new tooth."))
 
 	return nil
 }
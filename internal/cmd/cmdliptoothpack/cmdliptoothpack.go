@@ -3,21 +3,40 @@ package cmdliptoothpack
 import (
 	"archive/zip"
 	"compress/flate"
+	"crypto/sha256"
+	"encoding/hex"
 	"flag"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/lippkg/lip/internal/checksums"
 	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/monorepo"
 	"github.com/lippkg/lip/internal/path"
+	"github.com/lippkg/lip/internal/tarzst"
+	"github.com/lippkg/lip/internal/vfs"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/lippkg/lip/internal/tooth"
 )
 
+// defaultModTime is the modification time of packed files if SOURCE_DATE_EPOCH is not set.
+var defaultModTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
 type FlagDict struct {
-	helpFlag bool
+	helpFlag    bool
+	zstdFlag    bool
+	signFlag    bool
+	signKeyFlag string
 }
 
 const helpMessage = `
@@ -25,10 +44,20 @@ Usage:
   lip tooth pack [options] <output path>
 
 Description:
-  Pack the tooth into a tooth archive.
+  Pack the tooth into a tooth archive. If the metadata file whitelists build output, only the
+  metadata file and the build output are packed. The SHA-256 checksum of the archive is recorded in
+  SHA256SUMS next to it, which lip install checks when installing the archive.
+
+  At the root of a monorepo with a monorepo.json instead of a metadata file, every tooth listed
+  in it is packed into the output directory, e.g. teeth-foo@v1.2.3.zip for teeth/foo.
 
 Options:
   -h, --help                  Show help.
+  --zstd                      Pack into a Zstandard-compressed tar archive (.tar.zst) instead
+                              of a zip archive. It is usually smaller.
+  --sign                      Sign SHA256SUMS with GnuPG into SHA256SUMS.asc.
+  --sign-key <key id>         Sign with this GnuPG key instead of the default one. Implies
+                              --sign.
 `
 
 func Run(ctx *context.Context, args []string) error {
@@ -43,6 +72,9 @@ func Run(ctx *context.Context, args []string) error {
 	var flagDict FlagDict
 	flagSet.BoolVar(&flagDict.helpFlag, "help", false, "")
 	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
+	flagSet.BoolVar(&flagDict.zstdFlag, "zstd", false, "")
+	flagSet.BoolVar(&flagDict.signFlag, "sign", false, "")
+	flagSet.StringVar(&flagDict.signKeyFlag, "sign-key", "", "")
 	err := flagSet.Parse(args)
 	if err != nil {
 		return fmt.Errorf("failed to parse flags\n\t%w", err)
@@ -56,22 +88,59 @@ func Run(ctx *context.Context, args []string) error {
 
 	// Exactly one argument is required.
 	if flagSet.NArg() != 1 {
-		return fmt.Errorf("expected exactly one argument")
+		return errcode.Errorf(errcode.InvalidArgument, "expected exactly one argument")
 	}
 
-	// Validate tooth.json.
-	if err := validateToothJSON(ctx); err != nil {
-		return fmt.Errorf("failed to validate tooth.json\n\t%w", err)
-	}
-
-	// Pack the tooth.
 	outputPath, err := path.Parse(flagSet.Arg(0))
 	if err != nil {
 		return fmt.Errorf("failed to parse output path %v\n\t%w", flagSet.Arg(0), err)
 	}
 
-	if err := packTooth(ctx, outputPath); err != nil {
-		return fmt.Errorf("failed to pack tooth\n\t%w", err)
+	// At the root of a monorepo, every tooth in it is packed into the output directory.
+	_, isMetadataFilePresent, err := tooth.FindMetadataFile(".")
+	if err != nil {
+		return fmt.Errorf("failed to find the metadata file\n\t%w", err)
+	}
+
+	manifest, isMonorepo, err := monorepo.LoadManifest(".")
+	if err != nil {
+		return err
+	}
+
+	var checksumsDir string
+	if !isMetadataFilePresent && isMonorepo {
+		if err := packMonorepo(ctx, manifest, outputPath, flagDict.zstdFlag); err != nil {
+			return fmt.Errorf("failed to pack monorepo\n\t%w", err)
+		}
+
+		checksumsDir = outputPath.LocalString()
+
+	} else {
+		// Validate the metadata file.
+		metadata, metadataFilePath, err := readMetadataFile(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to validate the metadata file\n\t%w", err)
+		}
+
+		// Pack the tooth.
+		if err := packTooth(ctx, outputPath, flagDict.zstdFlag, metadata, metadataFilePath); err != nil {
+			return fmt.Errorf("failed to pack tooth\n\t%w", err)
+		}
+
+		// Record the checksum of the tooth archive.
+		if err := writeChecksum(ctx.FS(), outputPath); err != nil {
+			return fmt.Errorf("failed to write checksum of %v\n\t%w", outputPath.LocalString(), err)
+		}
+
+		checksumsDir = filepath.Dir(outputPath.LocalString())
+	}
+
+	if flagDict.signFlag || flagDict.signKeyFlag != "" {
+		if err := checksums.Sign(checksumsDir, flagDict.signKeyFlag); err != nil {
+			return fmt.Errorf("failed to sign checksums\n\t%w", err)
+		}
+
+		log.Infof(i18n.T("Signed %v"), filepath.Join(checksumsDir, checksums.SignatureFileName))
 	}
 
 	return nil
@@ -79,15 +148,15 @@ func Run(ctx *context.Context, args []string) error {
 
 // ---------------------------------------------------------------------
 
-// copyFile copies a file from sourcePath to destinationPath.
-func copyFile(sourcePath, destinationPath path.Path) error {
+// copyFile copies a file from sourcePath to destinationPath on a file system.
+func copyFile(fs vfs.FS, sourcePath, destinationPath path.Path) error {
 	source, err := os.Open(sourcePath.LocalString())
 	if err != nil {
 		return err
 	}
 	defer source.Close()
 
-	destination, err := os.Create(destinationPath.LocalString())
+	destination, err := fs.Create(destinationPath.LocalString())
 	if err != nil {
 		return err
 	}
@@ -111,8 +180,34 @@ func copyFile(sourcePath, destinationPath path.Path) error {
 	return nil
 }
 
+// getModTime returns the modification time of packed files: the time in SOURCE_DATE_EPOCH if
+// set, or otherwise the earliest time a zip archive can hold.
+func getModTime() (time.Time, error) {
+	sourceDateEpoch := os.Getenv("SOURCE_DATE_EPOCH")
+	if sourceDateEpoch == "" {
+		return defaultModTime, nil
+	}
+
+	seconds, err := strconv.ParseInt(sourceDateEpoch, 10, 64)
+	if err != nil {
+		return time.Time{}, errcode.Errorf(errcode.InvalidArgument, "invalid SOURCE_DATE_EPOCH %v", sourceDateEpoch)
+	}
+
+	return time.Unix(seconds, 0).UTC(), nil
+}
+
+// getNormalizedMode returns the permissions a file is packed with. Only whether the file is
+// executable is kept, since other permissions depend on the umask.
+func getNormalizedMode(fileInfo os.FileInfo) os.FileMode {
+	if fileInfo.Mode().Perm()&0111 != 0 {
+		return 0755
+	}
+
+	return 0644
+}
+
 // packFilesToTemp packs files to a temporary zip file.
-func packFilesToTemp(fileList []path.Path) (path.Path, error) {
+func packFilesToTemp(fileList []path.Path, modTime time.Time) (path.Path, error) {
 	zipFile, err := os.CreateTemp("", "*")
 	if err != nil {
 		return path.Path{}, fmt.Errorf("failed to create a temporary zip file\n\t%w", err)
@@ -134,9 +229,21 @@ func packFilesToTemp(fileList []path.Path) (path.Path, error) {
 
 	// Write files to the zip file.
 	for _, file := range fileList {
-		log.Infof("Packing %v...", file.LocalString())
+		log.Infof(i18n.T("Packing %v..."), file.LocalString())
 
-		writer, err := zipWriter.Create(file.String())
+		fileInfo, err := os.Stat(file.LocalString())
+		if err != nil {
+			return path.Path{}, fmt.Errorf("failed to stat %v\n\t%w", file.LocalString(), err)
+		}
+
+		header := &zip.FileHeader{
+			Name:     file.String(),
+			Method:   zip.Deflate,
+			Modified: modTime,
+		}
+		header.SetMode(getNormalizedMode(fileInfo))
+
+		writer, err := zipWriter.CreateHeader(header)
 		if err != nil {
 			return path.Path{}, fmt.Errorf("failed to create %v in zip file\n\t%w", file.String(), err)
 		}
@@ -158,11 +265,115 @@ func packFilesToTemp(fileList []path.Path) (path.Path, error) {
 	return zipFilePath, nil
 }
 
-// packTooth packs the tooth into a tooth archive.
-func packTooth(ctx *context.Context, outputPath path.Path) error {
-	_, err := os.Stat(outputPath.LocalString())
+// packFilesToTempTarZst packs files to a temporary .tar.zst file.
+func packFilesToTempTarZst(fileList []path.Path, modTime time.Time) (path.Path, error) {
+	tarZstFile, err := os.CreateTemp("", "*")
+	if err != nil {
+		return path.Path{}, fmt.Errorf("failed to create a temporary .tar.zst file\n\t%w", err)
+	}
+	defer tarZstFile.Close()
+
+	tarZstFilePath, err := path.Parse(tarZstFile.Name())
+	if err != nil {
+		return path.Path{}, fmt.Errorf("failed to parse the temporary .tar.zst file path %v\n\t%w", tarZstFile.Name(),
+			err)
+	}
+
+	for _, file := range fileList {
+		log.Infof(i18n.T("Packing %v..."), file.LocalString())
+	}
+
+	if err := tarzst.Pack(fileList, modTime, tarZstFile); err != nil {
+		return path.Path{}, err
+	}
+
+	return tarZstFilePath, nil
+}
+
+// packMonorepo packs every tooth listed in the monorepo manifest into the output directory, e.g.
+// teeth-foo@v1.2.3.zip for the tooth in teeth/foo, and records their checksums.
+func packMonorepo(ctx *context.Context, manifest monorepo.Manifest, outputDir path.Path, useZstd bool) error {
+	// Teeth are packed in their directories, so the output directory must not be relative.
+	absOutputDir, err := filepath.Abs(outputDir.LocalString())
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path of %v\n\t%w", outputDir.LocalString(), err)
+	}
+
+	if err := ctx.FS().MkdirAll(absOutputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory %v\n\t%w", absOutputDir, err)
+	}
+
+	rootDir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get current directory\n\t%w", err)
+	}
+
+	for _, subdir := range manifest.Subdirs() {
+		if err := os.Chdir(subdir.LocalString()); err != nil {
+			return fmt.Errorf("failed to enter tooth directory %v\n\t%w", subdir.LocalString(), err)
+		}
+
+		packErr := packSubtooth(ctx, manifest, subdir, absOutputDir, useZstd)
+
+		if err := os.Chdir(rootDir); err != nil {
+			return fmt.Errorf("failed to return to %v\n\t%w", rootDir, err)
+		}
+
+		if packErr != nil {
+			return fmt.Errorf("failed to pack tooth in %v\n\t%w", subdir.LocalString(), packErr)
+		}
+	}
+
+	return nil
+}
+
+// packSubtooth packs the tooth in the current directory, which is a subdirectory of a monorepo,
+// into the output directory, and records its checksum. The tooth must declare the tooth repo
+// path of its subdirectory.
+func packSubtooth(ctx *context.Context, manifest monorepo.Manifest, subdir path.Path, outputDir string,
+	useZstd bool) error {
+	metadata, metadataFilePath, err := readMetadataFile(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to validate the metadata file\n\t%w", err)
+	}
+
+	if expected := manifest.ToothRepoPath(subdir); metadata.ToothRepoPath() != expected {
+		return errcode.Errorf(errcode.MetadataInvalid, "tooth in %v must be %v, not %v", subdir.LocalString(),
+			expected, metadata.ToothRepoPath())
+	}
+
+	extension := ".zip"
+	if useZstd {
+		extension = ".tar.zst"
+	}
+
+	archiveFileName := strings.ReplaceAll(subdir.String(), "/", "-") + "@v" + metadata.Version().String() + extension
+
+	archivePath, err := path.Parse(filepath.Join(outputDir, archiveFileName))
+	if err != nil {
+		return fmt.Errorf("failed to parse archive path of %v\n\t%w", subdir.LocalString(), err)
+	}
+
+	if err := packTooth(ctx, archivePath, useZstd, metadata, metadataFilePath); err != nil {
+		return err
+	}
+
+	if err := writeChecksum(ctx.FS(), archivePath); err != nil {
+		return fmt.Errorf("failed to write checksum of %v\n\t%w", archivePath.LocalString(), err)
+	}
+
+	log.Infof(i18n.T("Packed %v@%v into %v"), metadata.ToothRepoPath(), metadata.Version(), archivePath.LocalString())
+
+	return nil
+}
+
+// packTooth packs the tooth into a tooth archive, a .tar.zst archive if useZstd is set. If the
+// metadata whitelists build output, only the metadata file and the build output are packed.
+func packTooth(ctx *context.Context, outputPath path.Path, useZstd bool, metadata tooth.Metadata,
+	metadataFilePath string) error {
+	_, err := ctx.FS().Stat(outputPath.LocalString())
 	if err == nil {
-		return fmt.Errorf("output path %v already exists", outputPath.LocalString())
+		return i18n.Errorf("output path %v already exists", outputPath.LocalString())
 	} else if !os.IsNotExist(err) {
 		return fmt.Errorf("failed to stat output path %v\n\t%w", outputPath.LocalString(), err)
 	}
@@ -182,45 +393,131 @@ func packTooth(ctx *context.Context, outputPath path.Path) error {
 		return fmt.Errorf("failed to walk through the current directory\n\t%w", err)
 	}
 
-	// Pack files to a temporary zip file.
-	zipFilePath, err := packFilesToTemp(fileList)
+	fileList, err = selectBuildOutput(fileList, metadata, metadataFilePath)
+	if err != nil {
+		return err
+	}
+
+	// Files are packed in a fixed order and with a fixed modification time, so packing the same
+	// files always makes the same archive.
+	sort.Slice(fileList, func(i, j int) bool {
+		return fileList[i].String() < fileList[j].String()
+	})
+
+	modTime, err := getModTime()
+	if err != nil {
+		return err
+	}
+
+	// Pack files to a temporary file.
+	packFunc := packFilesToTemp
+	if useZstd {
+		packFunc = packFilesToTempTarZst
+	}
+
+	packedFilePath, err := packFunc(fileList, modTime)
 	if err != nil {
-		return fmt.Errorf("failed to pack files to a temporary zip file\n\t%w", err)
+		return fmt.Errorf("failed to pack files to a temporary file\n\t%w", err)
 	}
+	defer os.Remove(packedFilePath.LocalString())
 
-	// Copy the zip file to the output path.
+	// Copy the packed file to the output path.
 
-	if err := copyFile(zipFilePath, outputPath); err != nil {
-		return fmt.Errorf("failed to copy the zip file from %v to %v\n\t%w",
-			zipFilePath.LocalString(), outputPath.LocalString(), err)
+	if err := copyFile(ctx.FS(), packedFilePath, outputPath); err != nil {
+		return fmt.Errorf("failed to copy the packed file from %v to %v\n\t%w",
+			packedFilePath.LocalString(), outputPath.LocalString(), err)
 	}
 
 	return nil
 }
 
-// validateToothJSON validates tooth.json.
-func validateToothJSON(ctx *context.Context) error {
+// writeChecksum records the SHA-256 checksum of the tooth archive in the checksums file next to
+// it.
+func writeChecksum(fs vfs.FS, archivePath path.Path) error {
+	archive, err := fs.Open(archivePath.LocalString())
+	if err != nil {
+		return fmt.Errorf("failed to open file\n\t%w", err)
+	}
+	defer archive.Close()
 
-	workspaceDirStr, err := os.Getwd()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, archive); err != nil {
+		return fmt.Errorf("failed to read file\n\t%w", err)
+	}
+
+	checksum := hex.EncodeToString(hash.Sum(nil))
+
+	if err := checksums.Add(fs, archivePath.LocalString(), checksum); err != nil {
+		return err
+	}
+
+	log.Infof(i18n.T("Wrote checksum %v to %v"), checksum,
+		filepath.Join(filepath.Dir(archivePath.LocalString()), checksums.FileName))
+
+	return nil
+}
+
+// readMetadataFile reads and validates the metadata file, i.e. tooth.json, tooth.yaml or
+// tooth.toml. It returns the metadata for the current platform and the path of the file.
+func readMetadataFile(ctx *context.Context) (tooth.Metadata, string, error) {
+	metadataFilePath, ok, err := tooth.FindMetadataFile(".")
 	if err != nil {
-		return fmt.Errorf("failed to get workspace directory\n\t%w", err)
+		return tooth.Metadata{}, "", fmt.Errorf("failed to find the metadata file\n\t%w", err)
 	}
 
-	workspaceDir, err := path.Parse(workspaceDirStr)
+	if !ok {
+		return tooth.Metadata{}, "", errcode.Errorf(errcode.MetadataInvalid, "no tooth.json, tooth.yaml or tooth.toml in the current directory")
+	}
+
+	metadata, err := tooth.ReadMetadataFile(metadataFilePath)
 	if err != nil {
-		return fmt.Errorf("failed to parse workspace directory\n\t%w", err)
+		return tooth.Metadata{}, "", err
 	}
 
-	jsonBytes, err := os.ReadFile(workspaceDir.Join(path.MustParse("tooth.json")).String())
+	metadata, err = metadata.ToPlatformSpecific(runtime.GOOS, runtime.GOARCH)
 	if err != nil {
-		return fmt.Errorf("failed to read tooth.json\n\t%w", err)
+		return tooth.Metadata{}, "", fmt.Errorf("failed to get platform-specific metadata\n\t%w", err)
 	}
 
-	if _, err := tooth.MakeMetadata(jsonBytes); err != nil {
-		return fmt.Errorf("failed to parse tooth.json\n\t%w", err)
+	return metadata, metadataFilePath, nil
+}
+
+// selectBuildOutput keeps only the metadata file and the files in the build output, if the
+// metadata whitelists one. Every whitelisted path must exist, so that lip build has been run.
+func selectBuildOutput(fileList []path.Path, metadata tooth.Metadata, metadataFilePath string) ([]path.Path, error) {
+	build, err := metadata.Build()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get build of metadata\n\t%w", err)
 	}
 
-	return nil
+	if len(build.Output) == 0 {
+		return fileList, nil
+	}
+
+	for _, outputPath := range build.Output {
+		if _, err := os.Stat(outputPath.LocalString()); os.IsNotExist(err) {
+			return nil, i18n.Errorf("build output %v does not exist. Run lip build first.", outputPath.LocalString())
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to stat build output %v\n\t%w", outputPath.LocalString(), err)
+		}
+	}
+
+	selectedFileList := make([]path.Path, 0)
+	for _, file := range fileList {
+		isSelected := file.String() == filepath.ToSlash(metadataFilePath)
+		for _, outputPath := range build.Output {
+			if file.Equal(outputPath) || outputPath.IsAncestorOf(file) {
+				isSelected = true
+				break
+			}
+		}
+
+		if isSelected {
+			selectedFileList = append(selectedFileList, file)
+		}
+	}
+
+	return selectedFileList, nil
 }
 
 // walkDirectory walks the directory and returns a list of files.
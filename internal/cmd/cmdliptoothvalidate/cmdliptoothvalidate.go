@@ -0,0 +1,133 @@
+package cmdliptoothvalidate
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/tooth"
+	log "github.com/sirupsen/logrus"
+)
+
+type FlagDict struct {
+	helpFlag   bool
+	schemaFlag bool
+}
+
+const helpMessage = `
+Usage:
+  lip tooth validate [options] [<path>]
+
+Description:
+  Validate a tooth.json file against the JSON schema of tooth.json and report each problem with
+  its line and column. tooth.yaml and tooth.toml files are converted to JSON before validating,
+  and their problems are reported without lines and columns. If no path is specified, the
+  metadata file in the current directory is validated.
+
+Options:
+  -h, --help                  Show help.
+  --schema                    Print the JSON schema of tooth.json and exit.
+`
+
+func Run(ctx *context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("validate", flag.ContinueOnError)
+
+	// Rewrite the default usage message.
+	flagSet.Usage = func() {
+		// Do nothing.
+	}
+
+	var flagDict FlagDict
+	flagSet.BoolVar(&flagDict.helpFlag, "help", false, "")
+	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
+	flagSet.BoolVar(&flagDict.schemaFlag, "schema", false, "")
+
+	if err := flagSet.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags\n\t%w", err)
+	}
+
+	// Help flag has the highest priority.
+	if flagDict.helpFlag {
+		fmt.Print(helpMessage)
+		return nil
+	}
+
+	if flagDict.schemaFlag {
+		schemaBytes, err := tooth.GenerateJSONSchema()
+		if err != nil {
+			return fmt.Errorf("failed to generate JSON schema\n\t%w", err)
+		}
+
+		fmt.Print(string(schemaBytes))
+		return nil
+	}
+
+	if flagSet.NArg() > 1 {
+		return errcode.Errorf(errcode.InvalidArgument, "expected at most one argument")
+	}
+
+	var filePath string
+	if flagSet.NArg() == 1 {
+		filePath = flagSet.Arg(0)
+	} else {
+		metadataFilePath, ok, err := tooth.FindMetadataFile(".")
+		if err != nil {
+			return fmt.Errorf("failed to find the metadata file\n\t%w", err)
+		}
+
+		if !ok {
+			return errcode.Errorf(errcode.MetadataInvalid, "no tooth.json, tooth.yaml or tooth.toml in the current directory")
+		}
+
+		filePath = metadataFilePath
+	}
+
+	format, err := tooth.ParseMetadataFormat(filePath)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read %v\n\t%w", filePath, err)
+	}
+
+	// The schema is a JSON schema, so other formats are converted, losing the positions.
+	jsonBytes := data
+	if format != tooth.JSONMetadataFormat {
+		jsonBytes, err = tooth.ConvertMetadata(data, format, tooth.JSONMetadataFormat)
+		if err != nil {
+			return errcode.Errorf(errcode.MetadataInvalid, "failed to convert %v to JSON\n\t%w", filePath, err)
+		}
+	}
+
+	validationErrors, err := tooth.Validate(jsonBytes)
+	if err != nil {
+		return fmt.Errorf("failed to validate %v\n\t%w", filePath, err)
+	}
+
+	// Print problems in the form of compiler diagnostics, so that editors can jump to them.
+	for _, validationError := range validationErrors {
+		if format == tooth.JSONMetadataFormat {
+			fmt.Printf("%v:%v\n", filePath, validationError.Error())
+		} else {
+			fmt.Printf("%v: %v: %v\n", filePath, validationError.Field, validationError.Description)
+		}
+	}
+
+	if len(validationErrors) != 0 {
+		return errcode.Errorf(errcode.MetadataInvalid, "%v problems found in %v", len(validationErrors), filePath)
+	}
+
+	// Check what the schema cannot express, e.g. the tooth repo path and the version.
+	if _, err := tooth.MakeMetadata(jsonBytes); err != nil {
+		return fmt.Errorf("failed to parse %v\n\t%w", filePath, err)
+	}
+
+	log.Infof(i18n.T("%v is valid."), filePath)
+
+	return nil
+}
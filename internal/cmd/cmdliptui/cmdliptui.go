@@ -0,0 +1,348 @@
+package cmdliptui
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/cmd/cmdlipinstall"
+	"github.com/lippkg/lip/internal/cmd/cmdlipuninstall"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/registry"
+	"github.com/lippkg/lip/internal/tooth"
+	"github.com/olekukonko/tablewriter"
+	log "github.com/sirupsen/logrus"
+)
+
+type FlagDict struct {
+	helpFlag bool
+}
+
+const helpMessage = `
+Usage:
+  lip tui [options]
+
+Description:
+  Browse installed teeth, available updates and teeth in the registry, and install, uninstall
+  or update them interactively.
+
+Options:
+  -h, --help                  Show help.
+`
+
+const keyHelpMessage = `
+Keys:
+  l                           List installed teeth.
+  r                           Refresh installed teeth and look up updates.
+  / <query>                   Search the registry.
+  i <number|tooth>            Install a search result or a tooth.
+  u <number>                  Update an installed tooth.
+  U                           Update all teeth with available updates.
+  x <number>                  Uninstall an installed tooth.
+  ?                           Show this help.
+  q                           Quit.
+`
+
+// installedItem is an installed tooth shown in the TUI.
+type installedItem struct {
+	metadata      tooth.Metadata
+	latestVersion *semver.Version
+}
+
+// state is the state of the TUI.
+type state struct {
+	ctx           *context.Context
+	reader        *bufio.Reader
+	installed     []installedItem
+	searchResults []string
+}
+
+func Run(ctx *context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("tui", flag.ContinueOnError)
+
+	// Rewrite the default usage message.
+	flagSet.Usage = func() {
+		// Do nothing.
+	}
+
+	var flagDict FlagDict
+	flagSet.BoolVar(&flagDict.helpFlag, "help", false, "")
+	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
+
+	if err := flagSet.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags\n\t%w", err)
+	}
+
+	// Help flag has the highest priority.
+	if flagDict.helpFlag {
+		fmt.Print(helpMessage)
+		return nil
+	}
+
+	// Check if there are unexpected arguments.
+	if flagSet.NArg() != 0 {
+		return errcode.Errorf(errcode.InvalidArgument, "unexpected arguments: %v", flagSet.Args())
+	}
+
+	s := &state{
+		ctx:    ctx,
+		reader: bufio.NewReader(os.Stdin),
+	}
+
+	if err := s.refresh(); err != nil {
+		return err
+	}
+
+	s.printInstalled()
+	fmt.Print(keyHelpMessage)
+
+	for {
+		fmt.Print("lip> ")
+
+		line, err := s.reader.ReadString('\n')
+		if err == io.EOF && line == "" {
+			fmt.Println()
+			return nil
+		} else if err != nil && err != io.EOF {
+			return fmt.Errorf("failed to read input\n\t%w", err)
+		}
+
+		key, argument, _ := strings.Cut(strings.TrimSpace(line), " ")
+		argument = strings.TrimSpace(argument)
+
+		if key == "q" {
+			return nil
+		}
+
+		// Errors of actions are shown without leaving the TUI.
+		if err := s.handle(key, argument); err != nil {
+			log.Errorf("\n\t%v", err.Error())
+		}
+	}
+}
+
+// ---------------------------------------------------------------------
+
+// handle runs the action bound to a key.
+func (s *state) handle(key string, argument string) error {
+	switch key {
+	case "":
+		return nil
+
+	case "?":
+		fmt.Print(keyHelpMessage)
+		return nil
+
+	case "l":
+		s.printInstalled()
+		return nil
+
+	case "r":
+		if err := s.refresh(); err != nil {
+			return err
+		}
+		s.printInstalled()
+		return nil
+
+	case "/":
+		return s.search(argument)
+
+	case "i":
+		toothRepoPath := argument
+		if index, err := strconv.Atoi(argument); err == nil {
+			if index < 1 || index > len(s.searchResults) {
+				return i18n.Errorf("no search result numbered %v", index)
+			}
+			toothRepoPath = s.searchResults[index-1]
+		}
+
+		if toothRepoPath == "" {
+			return i18n.Errorf("no tooth specified")
+		}
+
+		return s.runAction(i18n.Sprintf("Install %v?", toothRepoPath), func() error {
+			return cmdlipinstall.Run(s.ctx, []string{"--yes", toothRepoPath})
+		})
+
+	case "u":
+		item, err := s.getInstalledItem(argument)
+		if err != nil {
+			return err
+		}
+
+		toothRepoPath := item.metadata.ToothRepoPath()
+		return s.runAction(i18n.Sprintf("Update %v?", toothRepoPath), func() error {
+			return cmdlipinstall.Run(s.ctx, []string{"--yes", "--upgrade", toothRepoPath})
+		})
+
+	case "U":
+		toothRepoPaths := make([]string, 0)
+		for _, item := range s.installed {
+			if item.latestVersion != nil {
+				toothRepoPaths = append(toothRepoPaths, item.metadata.ToothRepoPath())
+			}
+		}
+
+		if len(toothRepoPaths) == 0 {
+			log.Info(i18n.T("All teeth are up to date."))
+			return nil
+		}
+
+		return s.runAction(i18n.Sprintf("Update %v?", strings.Join(toothRepoPaths, ", ")), func() error {
+			return cmdlipinstall.Run(s.ctx, append([]string{"--yes", "--upgrade"}, toothRepoPaths...))
+		})
+
+	case "x":
+		item, err := s.getInstalledItem(argument)
+		if err != nil {
+			return err
+		}
+
+		toothRepoPath := item.metadata.ToothRepoPath()
+		return s.runAction(i18n.Sprintf("Uninstall %v?", toothRepoPath), func() error {
+			return cmdlipuninstall.Run(s.ctx, []string{"--yes", toothRepoPath})
+		})
+
+	default:
+		return i18n.Errorf("unknown key: %v. Enter ? for help", key)
+	}
+}
+
+// getInstalledItem returns the installed tooth numbered by the argument.
+func (s *state) getInstalledItem(argument string) (installedItem, error) {
+	index, err := strconv.Atoi(argument)
+	if err != nil || index < 1 || index > len(s.installed) {
+		return installedItem{}, i18n.Errorf("no installed tooth numbered %v", argument)
+	}
+
+	return s.installed[index-1], nil
+}
+
+// printInstalled prints the installed teeth with their available updates.
+func (s *state) printInstalled() {
+	tableString := &strings.Builder{}
+	table := tablewriter.NewWriter(tableString)
+	table.SetHeader([]string{
+		"#", "Tooth", "Name", "Version", "Latest",
+	})
+
+	for i, item := range s.installed {
+		latest := ""
+		if item.latestVersion != nil {
+			latest = item.latestVersion.String()
+		}
+
+		table.Append([]string{
+			strconv.Itoa(i + 1),
+			item.metadata.ToothRepoPath(),
+			item.metadata.Info().Name,
+			item.metadata.Version().String(),
+			latest,
+		})
+	}
+
+	table.Render()
+
+	fmt.Print(tableString.String())
+}
+
+// refresh reloads the installed teeth and looks up their latest versions.
+func (s *state) refresh() error {
+	metadataList, err := tooth.GetAllMetadata(s.ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list all installed teeth\n\t%w", err)
+	}
+
+	s.installed = make([]installedItem, 0)
+	for _, metadata := range metadataList {
+		item := installedItem{metadata: metadata}
+
+		latestVersion, err := tooth.GetLatestVersion(s.ctx, metadata.ToothRepoPath())
+		if err != nil {
+			log.Warnf(i18n.T("Failed to look up latest version for %v"), metadata.ToothRepoPath())
+		} else if latestVersion.GT(metadata.Version()) {
+			item.latestVersion = &latestVersion
+		}
+
+		s.installed = append(s.installed, item)
+	}
+
+	return nil
+}
+
+// runAction asks for confirmation, runs an action and refreshes the installed teeth.
+func (s *state) runAction(question string, action func() error) error {
+	log.Infof("%v [y/N]", question)
+
+	ans, err := s.reader.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("failed to read input\n\t%w", err)
+	}
+
+	ans = strings.TrimSpace(ans)
+	if ans != "y" && ans != "Y" {
+		log.Info(i18n.T("Aborted."))
+		return nil
+	}
+
+	if err := action(); err != nil {
+		return err
+	}
+
+	if err := s.refresh(); err != nil {
+		return err
+	}
+
+	s.printInstalled()
+
+	return nil
+}
+
+// search searches the registry for teeth whose paths or aliases contain the query.
+func (s *state) search(query string) error {
+	if !registry.IsConfigured(s.ctx) {
+		return i18n.Errorf("no registry is configured. Set registry_url with lip config")
+	}
+
+	results, err := registry.Search(s.ctx, query, registry.NameSortKey)
+	if err != nil {
+		return fmt.Errorf("failed to search the registry\n\t%w", err)
+	}
+
+	s.searchResults = make([]string, 0, len(results))
+	for _, result := range results {
+		s.searchResults = append(s.searchResults, result.ToothRepoPath)
+	}
+
+	tableString := &strings.Builder{}
+	table := tablewriter.NewWriter(tableString)
+	table.SetHeader([]string{
+		"#", "Tooth", "Downloads", "Stars", "Installed",
+	})
+
+	for i, result := range results {
+		installed := ""
+		for _, item := range s.installed {
+			if item.metadata.ToothRepoPath() == result.ToothRepoPath {
+				installed = item.metadata.Version().String()
+				break
+			}
+		}
+
+		table.Append([]string{strconv.Itoa(i + 1), result.ToothRepoPath, registry.FormatCount(result.Downloads),
+			registry.FormatCount(result.Stars), installed})
+	}
+
+	table.Render()
+
+	fmt.Print(tableString.String())
+
+	return nil
+}
@@ -0,0 +1,213 @@
+package cmdlipundo
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/lippkg/lip/internal/cmd/cmdlipinstall"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/history"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/install"
+	"github.com/lippkg/lip/internal/record"
+	"github.com/lippkg/lip/internal/snapshot"
+	"github.com/lippkg/lip/internal/tooth"
+	log "github.com/sirupsen/logrus"
+)
+
+type FlagDict struct {
+	helpFlag bool
+	yesFlag  bool
+}
+
+const helpMessage = `
+Usage:
+  lip undo [options]
+
+Description:
+  Revert the most recent operation in lip history that has not been undone. Teeth it installed
+  are uninstalled, and teeth it uninstalled or changed are reinstalled at their previous
+  versions with their previous installation records. Running lip undo again reverts the
+  operation before it.
+
+Options:
+  -h, --help                  Show help.
+  -y, --yes                   Assume yes to all prompts and run non-interactively.
+`
+
+func Run(ctx *context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("undo", flag.ContinueOnError)
+
+	// Rewrite the default usage message.
+	flagSet.Usage = func() {
+		// Do nothing.
+	}
+
+	var flagDict FlagDict
+	flagSet.BoolVar(&flagDict.helpFlag, "help", false, "")
+	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
+	flagSet.BoolVar(&flagDict.yesFlag, "yes", false, "")
+	flagSet.BoolVar(&flagDict.yesFlag, "y", false, "")
+
+	if err := flagSet.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags\n\t%w", err)
+	}
+
+	// Help flag has the highest priority.
+	if flagDict.helpFlag {
+		fmt.Print(helpMessage)
+		return nil
+	}
+
+	// Check if there are unexpected arguments.
+	if flagSet.NArg() != 0 {
+		return errcode.Errorf(errcode.InvalidArgument, "unexpected arguments: %v", flagSet.Args())
+	}
+
+	entry, ok, err := history.GetLastUndoable(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read history\n\t%w", err)
+	}
+
+	if !ok {
+		log.Info(i18n.T("Nothing to undo."))
+		return nil
+	}
+
+	// 1. Prompt for confirmation.
+
+	if !flagDict.yesFlag {
+		log.Infof(i18n.T("Operation #%v (%v at %v) will be undone:"), entry.ID, entry.Operation,
+			entry.Time.Local().Format("2006-01-02 15:04:05"))
+		for _, change := range entry.Changes {
+			switch {
+			case change.From == "":
+				log.Infof("  "+i18n.T("%v@%v will be uninstalled"), change.ToothRepoPath, change.To)
+
+			case change.From != change.To:
+				log.Infof("  "+i18n.T("%v@%v will be installed"), change.ToothRepoPath, change.From)
+			}
+		}
+
+		if ok, err := ctx.Prompter().Confirm(i18n.T("Do you want to continue? [y/N]"), false); err != nil {
+			return err
+		} else if !ok {
+			return errcode.Errorf(errcode.Aborted, "aborted")
+		}
+	}
+
+	undoChanges, err := getUndoChanges(ctx, entry)
+	if err != nil {
+		return err
+	}
+
+	// The operations making up the undo are recorded as the undo itself, so they run with a
+	// copy of the context not recording history.
+	undoCtx := ctx.Copy()
+	undoCtx.SetNoHistory(true)
+
+	// 2. Reinstall the previous versions in one transaction.
+
+	installArgs := []string{"--yes", "--force-reinstall", "--no-dependencies"}
+	for _, change := range entry.Changes {
+		if change.From != "" && change.From != change.To {
+			installArgs = append(installArgs, fmt.Sprintf("%v@%v", change.ToothRepoPath, change.From))
+		}
+	}
+
+	if len(installArgs) != 3 {
+		if err := cmdlipinstall.Run(undoCtx, installArgs); err != nil {
+			return fmt.Errorf("failed to reinstall previous versions\n\t%w", err)
+		}
+	}
+
+	// 3. Uninstall the teeth installed by the operation.
+
+	for _, change := range entry.Changes {
+		if change.From != "" {
+			continue
+		}
+
+		isInstalled, err := tooth.IsInstalled(undoCtx, change.ToothRepoPath)
+		if err != nil {
+			return fmt.Errorf("failed to check if tooth is installed\n\t%w", err)
+		}
+
+		if !isInstalled {
+			continue
+		}
+
+		if err := install.Uninstall(undoCtx, change.ToothRepoPath); err != nil {
+			return fmt.Errorf("failed to uninstall tooth %v\n\t%w", change.ToothRepoPath, err)
+		}
+
+		if err := snapshot.RemoveAll(undoCtx, change.ToothRepoPath); err != nil {
+			return fmt.Errorf("failed to remove snapshots of tooth %v\n\t%w", change.ToothRepoPath, err)
+		}
+
+		if err := install.RemoveVersions(undoCtx, change.ToothRepoPath); err != nil {
+			return fmt.Errorf("failed to remove versions of tooth %v\n\t%w", change.ToothRepoPath, err)
+		}
+	}
+
+	// 4. Restore the previous records.
+
+	for _, change := range entry.Changes {
+		if change.From == "" {
+			continue
+		}
+
+		currentRecord, err := record.Get(undoCtx, change.ToothRepoPath)
+		if err != nil {
+			return fmt.Errorf("failed to get record of tooth %v\n\t%w", change.ToothRepoPath, err)
+		}
+
+		currentRecord.IsExplicit = change.WasExplicit
+
+		if err := record.Save(undoCtx, currentRecord); err != nil {
+			return fmt.Errorf("failed to save record of tooth %v\n\t%w", change.ToothRepoPath, err)
+		}
+	}
+
+	if err := history.Append(ctx, history.UndoOperation, undoChanges, entry.ID); err != nil {
+		return fmt.Errorf("failed to record history\n\t%w", err)
+	}
+
+	log.Infof(i18n.T("Undid operation #%v."), entry.ID)
+
+	return nil
+}
+
+// ---------------------------------------------------------------------
+
+// getUndoChanges returns the changes undoing the changes of an entry, to be recorded in the
+// history.
+func getUndoChanges(ctx *context.Context, entry history.Entry) ([]history.Change, error) {
+	undoChanges := make([]history.Change, 0, len(entry.Changes))
+	for _, change := range entry.Changes {
+		undoChange := history.Change{
+			ToothRepoPath: change.ToothRepoPath,
+			From:          change.To,
+			To:            change.From,
+		}
+
+		isInstalled, err := tooth.IsInstalled(ctx, change.ToothRepoPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check if tooth is installed\n\t%w", err)
+		}
+
+		if isInstalled {
+			currentRecord, err := record.Get(ctx, change.ToothRepoPath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get record of tooth %v\n\t%w", change.ToothRepoPath, err)
+			}
+
+			undoChange.WasExplicit = currentRecord.IsExplicit
+		}
+
+		undoChanges = append(undoChanges, undoChange)
+	}
+
+	return undoChanges, nil
+}
@@ -5,15 +5,23 @@ import (
 	"fmt"
 
 	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/history"
+	"github.com/lippkg/lip/internal/i18n"
 	"github.com/lippkg/lip/internal/install"
+	"github.com/lippkg/lip/internal/interrupt"
+	"github.com/lippkg/lip/internal/render"
+	"github.com/lippkg/lip/internal/service"
+	"github.com/lippkg/lip/internal/snapshot"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/lippkg/lip/internal/tooth"
 )
 
 type FlagDict struct {
-	helpFlag bool
-	yesFlag  bool
+	helpFlag  bool
+	yesFlag   bool
+	purgeFlag bool
 }
 
 const helpMessage = `
@@ -26,6 +34,7 @@ Description:
 Options:
   -h, --help                  Show help.
   -y, --yes                   Skip confirmation.
+  --purge                     Also remove user data, edited config files and preserved files.
 `
 
 func Run(ctx *context.Context, args []string) error {
@@ -42,6 +51,7 @@ func Run(ctx *context.Context, args []string) error {
 	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
 	flagSet.BoolVar(&flagDict.yesFlag, "yes", false, "")
 	flagSet.BoolVar(&flagDict.yesFlag, "y", false, "")
+	flagSet.BoolVar(&flagDict.purgeFlag, "purge", false, "")
 	err := flagSet.Parse(args)
 	if err != nil {
 		return fmt.Errorf("failed to parse flags\n\t%w", err)
@@ -55,9 +65,15 @@ func Run(ctx *context.Context, args []string) error {
 
 	// At least one specifier is required.
 	if flagSet.NArg() == 0 {
-		return fmt.Errorf("at least one specifier is required")
+		return errcode.Errorf(errcode.InvalidArgument, "at least one specifier is required")
 	}
 
+	// The flags are set on a copy of the context, so that they do not apply to later commands
+	// run with it.
+	ctx = ctx.Copy()
+
+	ctx.SetPurge(flagDict.purgeFlag)
+
 	toothRepoPathList := flagSet.Args()
 
 	// 1. Check if all teeth are installed.
@@ -70,7 +86,7 @@ func Run(ctx *context.Context, args []string) error {
 		}
 
 		if !isInstalled {
-			return fmt.Errorf("tooth %v is not installed", toothRepoPath)
+			return errcode.Errorf(errcode.NotInstalled, "tooth %v is not installed", toothRepoPath)
 		}
 	}
 
@@ -85,14 +101,49 @@ func Run(ctx *context.Context, args []string) error {
 
 	// 3. Uninstall all teeth.
 
+	changes := make([]history.Change, 0, len(toothRepoPathList))
 	for _, toothRepoPath := range toothRepoPathList {
-		err := install.Uninstall(ctx, toothRepoPath)
+		// Once interrupted, the teeth uninstalled so far are recorded and the others are left.
+		if interrupt.IsInterrupted(ctx.GoContext()) {
+			if err := history.AppendInterrupted(ctx, history.UninstallOperation, changes); err != nil {
+				log.Warnf(i18n.T("Cannot record the interruption in the history\n\t%v"), err)
+			}
+
+			return fmt.Errorf("failed to uninstall tooth %v\n\t%w", toothRepoPath, ctx.GoContext().Err())
+		}
+
+		change, err := history.GetUninstallChange(ctx, toothRepoPath)
 		if err != nil {
+			return err
+		}
+
+		// The OS service is stopped before its files are removed.
+		if serviceName, err := service.Unregister(ctx, toothRepoPath); err != nil {
+			return fmt.Errorf("failed to unregister OS service of %v\n\t%w", toothRepoPath, err)
+		} else if serviceName != "" {
+			log.Infof(i18n.T("Removed OS service %v"), serviceName)
+		}
+
+		if err := install.Uninstall(ctx, toothRepoPath); err != nil {
 			return fmt.Errorf("failed to uninstall tooth %v\n\t%w", toothRepoPath, err)
 		}
+
+		changes = append(changes, change)
+
+		if err := snapshot.RemoveAll(ctx, toothRepoPath); err != nil {
+			return fmt.Errorf("failed to remove snapshots of tooth %v\n\t%w", toothRepoPath, err)
+		}
+
+		if err := install.RemoveVersions(ctx, toothRepoPath); err != nil {
+			return fmt.Errorf("failed to remove versions of tooth %v\n\t%w", toothRepoPath, err)
+		}
+	}
+
+	if err := history.Append(ctx, history.UninstallOperation, changes, 0); err != nil {
+		return fmt.Errorf("failed to record history\n\t%w", err)
 	}
 
-	log.Info("Done.")
+	log.Info(i18n.T("Done."))
 
 	return nil
 }
@@ -104,23 +155,23 @@ func askForConfirmation(ctx *context.Context,
 	toothRepoPathList []string) error {
 
 	// Print the list of teeth to be installed.
-	log.Info("The following teeth will be uninstalled:")
+	log.Info(render.Label(render.RemovedRole, i18n.T("The following teeth will be uninstalled:")))
 	for _, toothRepoPath := range toothRepoPathList {
 		metadata, err := tooth.GetMetadata(ctx, toothRepoPath)
 		if err != nil {
 			return fmt.Errorf("failed to get installed tooth metadata\n\t%w", err)
 		}
 
-		log.Infof("  %v@%v: %v", toothRepoPath, metadata.Version(),
+		log.Infof("  %v: %v",
+			render.Style(render.RemovedRole, fmt.Sprintf("%v@%v", toothRepoPath, metadata.Version())),
 			metadata.Info().Name)
 	}
 
 	// Ask for confirmation.
-	log.Info("Do you want to continue? [y/N]")
-	var ans string
-	fmt.Scanln(&ans)
-	if ans != "y" && ans != "Y" {
-		return fmt.Errorf("aborted")
+	if ok, err := ctx.Prompter().Confirm(i18n.T("Do you want to continue? [y/N]"), false); err != nil {
+		return err
+	} else if !ok {
+		return errcode.Errorf(errcode.Aborted, "aborted")
 	}
 
 	return nil
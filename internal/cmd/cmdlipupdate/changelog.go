@@ -0,0 +1,54 @@
+package cmdlipupdate
+
+import (
+	"fmt"
+
+	"github.com/lippkg/lip/internal/changelog"
+	"github.com/lippkg/lip/internal/cmd/cmdlipinstall"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/render"
+	log "github.com/sirupsen/logrus"
+)
+
+// printChangelogs prints the changelog entries of the versions each update brings, read from
+// the tooth archives of the target versions. Teeth without changelogs are skipped, and
+// changelogs that cannot be read are only warned about.
+func printChangelogs(ctx *context.Context, updates []plannedUpdate) {
+	debugLogger := ctx.Logger().WithFields(log.Fields{
+		"package": "cmdlipupdate",
+		"method":  "printChangelogs",
+	})
+
+	for _, update := range updates {
+		archive, err := cmdlipinstall.DownloadToothArchiveWithoutAssets(ctx, update.toothRepoPath, update.targetVersion)
+		if err != nil {
+			log.Warnf(i18n.T("Cannot get the changelog of %v\n\t%v"), update.toothRepoPath, err)
+			continue
+		}
+
+		entries, ok, err := changelog.Get(ctx, archive)
+		if err != nil {
+			log.Warnf(i18n.T("Cannot get the changelog of %v\n\t%v"), update.toothRepoPath, err)
+			continue
+		}
+
+		if !ok {
+			debugLogger.Debugf("%v@%v has no changelog", update.toothRepoPath, update.targetVersion)
+			continue
+		}
+
+		updateEntries := changelog.Between(entries, update.currentVersion, update.targetVersion)
+		if len(updateEntries) == 0 {
+			debugLogger.Debugf("Changelog of %v@%v has no entries after %v", update.toothRepoPath,
+				update.targetVersion, update.currentVersion)
+			continue
+		}
+
+		fmt.Println()
+		fmt.Println(render.Style(render.HeadingRole, fmt.Sprintf(i18n.T("Changes of %v from %v to %v:"),
+			update.toothRepoPath, update.currentVersion, update.targetVersion)))
+		fmt.Println()
+		fmt.Print(changelog.Format(updateEntries))
+	}
+}
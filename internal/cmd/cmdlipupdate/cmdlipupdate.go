@@ -0,0 +1,191 @@
+package cmdlipupdate
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/lippkg/lip/internal/cmd/cmdlipinstall"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/render"
+	"github.com/olekukonko/tablewriter"
+	log "github.com/sirupsen/logrus"
+)
+
+type FlagDict struct {
+	helpFlag         bool
+	yesFlag          bool
+	dryRunFlag       bool
+	unattendedFlag   bool
+	policyFlag       string
+	onlyPatchFlag    bool
+	onlySecurityFlag bool
+	logFileFlag      string
+	noChangelogFlag  bool
+}
+
+const helpMessage = `
+Usage:
+  lip update [options] [<tooth repo path> ...]
+
+Description:
+  Update the installed teeth, or the given ones, to the newest versions the update policy
+  allows, showing the changelog entries of the versions between the installed and the new ones
+  before confirming. The update policy is UpdatePolicy unless given by the options:
+
+  - all: the latest versions.
+  - minor: the latest versions within the installed major versions.
+  - patch: the latest versions within the installed minor versions.
+  - security: the lowest versions fixing the security advisories affecting the teeth. See
+    lip audit.
+
+Options:
+  -h, --help                  Show help.
+  -y, --yes                   Skip confirmation.
+  --dry-run                   Only list the updates to apply.
+  --unattended                Never prompt and log the results, e.g. for cron or Task Scheduler.
+                              Implies --yes.
+  --policy <policy>           Apply the updates allowed by the policy: all, minor, patch or
+                              security.
+  --only-patch                Same as --policy patch.
+  --only-security             Same as --policy security.
+  --log-file <path>           Append the results to the file. Defaults to update.log in the
+                              .lip directory of the workspace with --unattended.
+  --no-changelog              Do not show the changelogs of the updates.
+`
+
+func Run(ctx *context.Context, args []string) error {
+
+	flagSet := flag.NewFlagSet("update", flag.ContinueOnError)
+
+	// Rewrite the default usage message.
+	flagSet.Usage = func() {
+		// Do nothing.
+	}
+
+	var flagDict FlagDict
+	flagSet.BoolVar(&flagDict.helpFlag, "help", false, "")
+	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
+	flagSet.BoolVar(&flagDict.yesFlag, "yes", false, "")
+	flagSet.BoolVar(&flagDict.yesFlag, "y", false, "")
+	flagSet.BoolVar(&flagDict.dryRunFlag, "dry-run", false, "")
+	flagSet.BoolVar(&flagDict.unattendedFlag, "unattended", false, "")
+	flagSet.StringVar(&flagDict.policyFlag, "policy", "", "")
+	flagSet.BoolVar(&flagDict.onlyPatchFlag, "only-patch", false, "")
+	flagSet.BoolVar(&flagDict.onlySecurityFlag, "only-security", false, "")
+	flagSet.StringVar(&flagDict.logFileFlag, "log-file", "", "")
+	flagSet.BoolVar(&flagDict.noChangelogFlag, "no-changelog", false, "")
+	err := flagSet.Parse(args)
+	if err != nil {
+		return fmt.Errorf("failed to parse flags\n\t%w", err)
+	}
+
+	// Help flag has the highest priority.
+	if flagDict.helpFlag {
+		fmt.Print(helpMessage)
+		return nil
+	}
+
+	policy, err := getPolicy(ctx, flagDict)
+	if err != nil {
+		return err
+	}
+
+	resultLog, err := openResultLog(ctx, flagDict)
+	if err != nil {
+		return err
+	}
+
+	updates, err := planUpdates(ctx, policy, flagSet.Args())
+	if err != nil {
+		resultLog.writeFailure(err)
+		return fmt.Errorf("failed to find updates\n\t%w", err)
+	}
+
+	if len(updates) == 0 {
+		log.Infof(i18n.T("No updates allowed by update policy %v."), policy)
+		resultLog.writeNoUpdates(policy)
+		return nil
+	}
+
+	printUpdates(updates)
+
+	if !flagDict.noChangelogFlag && !flagDict.unattendedFlag {
+		printChangelogs(ctx, updates)
+	}
+
+	if flagDict.dryRunFlag {
+		return nil
+	}
+
+	installArgs := []string{"--upgrade"}
+	if flagDict.yesFlag || flagDict.unattendedFlag {
+		installArgs = append(installArgs, "--yes")
+	}
+
+	for _, update := range updates {
+		installArgs = append(installArgs, update.toothRepoPath+"@"+update.targetVersion.String())
+	}
+
+	if err := cmdlipinstall.Run(ctx, installArgs); err != nil {
+		resultLog.writeFailure(err)
+		return fmt.Errorf("failed to apply updates\n\t%w", err)
+	}
+
+	resultLog.writeUpdates(updates)
+
+	return nil
+}
+
+// ---------------------------------------------------------------------
+
+// getPolicy returns the update policy given by the flags, or UpdatePolicy if none is given.
+func getPolicy(ctx *context.Context, flagDict FlagDict) (string, error) {
+	policies := make([]string, 0)
+
+	if flagDict.policyFlag != "" {
+		policy, err := context.ParseUpdatePolicy(flagDict.policyFlag)
+		if err != nil {
+			return "", errcode.Wrap(errcode.InvalidArgument, err)
+		}
+		policies = append(policies, policy)
+	}
+
+	if flagDict.onlyPatchFlag {
+		policies = append(policies, context.PatchUpdatePolicy)
+	}
+
+	if flagDict.onlySecurityFlag {
+		policies = append(policies, context.SecurityUpdatePolicy)
+	}
+
+	switch len(policies) {
+	case 0:
+		return ctx.UpdatePolicy()
+	case 1:
+		return policies[0], nil
+	default:
+		return "", errcode.Errorf(errcode.InvalidArgument,
+			"--policy, --only-patch and --only-security are mutually exclusive")
+	}
+}
+
+// printUpdates prints the updates to apply as a table.
+func printUpdates(updates []plannedUpdate) {
+	tableString := &strings.Builder{}
+	table := tablewriter.NewWriter(tableString)
+	table.SetHeader([]string{
+		"Tooth", "Installed", "Update",
+	})
+
+	for _, update := range updates {
+		table.Append([]string{update.toothRepoPath, update.currentVersion.String(),
+			render.Style(render.ChangedRole, update.targetVersion.String())})
+	}
+
+	table.Render()
+
+	fmt.Print(tableString.String())
+}
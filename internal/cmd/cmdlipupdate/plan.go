@@ -0,0 +1,156 @@
+package cmdlipupdate
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/advisory"
+	"github.com/lippkg/lip/internal/cmd/cmdlipinstall"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/tooth"
+	log "github.com/sirupsen/logrus"
+)
+
+// plannedUpdate is an update of an installed tooth allowed by the update policy.
+type plannedUpdate struct {
+	toothRepoPath  string
+	currentVersion semver.Version
+	targetVersion  semver.Version
+}
+
+// planUpdates finds the updates of the given installed teeth, or all installed teeth if none is
+// given, allowed by the update policy.
+func planUpdates(ctx *context.Context, policy string, toothRepoPaths []string) ([]plannedUpdate, error) {
+	metadataList, err := getMetadataList(ctx, toothRepoPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	var advisoryDB advisory.Database
+	if policy == context.SecurityUpdatePolicy {
+		advisoryDB, err = advisory.Fetch(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch advisory database\n\t%w", err)
+		}
+	}
+
+	updates := make([]plannedUpdate, 0)
+
+	for _, metadata := range metadataList {
+		var targetVersion semver.Version
+		isFound := true
+
+		if policy == context.SecurityUpdatePolicy {
+			targetVersion, isFound = getSecurityFixVersion(advisoryDB, metadata)
+		} else {
+			targetVersion, err = getLatestAllowedVersion(ctx, policy, metadata)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if !isFound || !targetVersion.GT(metadata.Version()) {
+			continue
+		}
+
+		updates = append(updates, plannedUpdate{
+			toothRepoPath:  metadata.ToothRepoPath(),
+			currentVersion: metadata.Version(),
+			targetVersion:  targetVersion,
+		})
+	}
+
+	sort.Slice(updates, func(i, j int) bool {
+		return updates[i].toothRepoPath < updates[j].toothRepoPath
+	})
+
+	return updates, nil
+}
+
+// ---------------------------------------------------------------------
+
+// getMetadataList returns the metadata of the given installed teeth, or of all installed teeth
+// if none is given.
+func getMetadataList(ctx *context.Context, toothRepoPaths []string) ([]tooth.Metadata, error) {
+	if len(toothRepoPaths) == 0 {
+		metadataList, err := tooth.GetAllMetadata(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list all installed tooth metadata\n\t%w", err)
+		}
+
+		return metadataList, nil
+	}
+
+	metadataList := make([]tooth.Metadata, 0, len(toothRepoPaths))
+
+	for _, toothRepoPath := range toothRepoPaths {
+		isInstalled, err := tooth.IsInstalled(ctx, toothRepoPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check if %v is installed\n\t%w", toothRepoPath, err)
+		}
+
+		if !isInstalled {
+			return nil, errcode.Errorf(errcode.NotInstalled, "tooth %v is not installed", toothRepoPath)
+		}
+
+		metadata, err := tooth.GetMetadata(ctx, toothRepoPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get metadata of %v\n\t%w", toothRepoPath, err)
+		}
+
+		metadataList = append(metadataList, metadata)
+	}
+
+	return metadataList, nil
+}
+
+// getLatestAllowedVersion returns the latest version of an installed tooth allowed by the all,
+// minor or patch update policy that supports the current platform.
+func getLatestAllowedVersion(ctx *context.Context, policy string, metadata tooth.Metadata) (semver.Version, error) {
+	currentVersion := metadata.Version()
+
+	versionRange := semver.Range(func(version semver.Version) bool {
+		switch policy {
+		case context.MinorUpdatePolicy:
+			return version.Major == currentVersion.Major
+		case context.PatchUpdatePolicy:
+			return version.Major == currentVersion.Major && version.Minor == currentVersion.Minor
+		default:
+			return true
+		}
+	})
+
+	latestVersion, err := cmdlipinstall.GetLatestSupportedVersion(ctx, metadata.ToothRepoPath(), versionRange)
+	if err != nil {
+		return semver.Version{}, fmt.Errorf("failed to get latest version of %v\n\t%w",
+			metadata.ToothRepoPath(), err)
+	}
+
+	return latestVersion, nil
+}
+
+// getSecurityFixVersion returns the lowest version of an installed tooth fixing all the
+// advisories affecting it that have a fix. The second return value is false if no advisory
+// affecting the tooth has a fix.
+func getSecurityFixVersion(advisoryDB advisory.Database, metadata tooth.Metadata) (semver.Version, bool) {
+	var fixVersion semver.Version
+	isFound := false
+
+	for _, match := range advisoryDB.Match(metadata.ToothRepoPath(), metadata.Version()) {
+		if match.FixedVersion == nil {
+			log.Warnf(i18n.T("No fixed version of %v for advisory %v. Skipped."), metadata.ToothRepoPath(),
+				match.Advisory.ID)
+			continue
+		}
+
+		if !isFound || match.FixedVersion.GT(fixVersion) {
+			fixVersion = *match.FixedVersion
+			isFound = true
+		}
+	}
+
+	return fixVersion, isFound
+}
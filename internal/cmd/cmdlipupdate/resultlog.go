@@ -0,0 +1,94 @@
+package cmdlipupdate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/i18n"
+	log "github.com/sirupsen/logrus"
+)
+
+// resultLog appends the results of lip update to a log file, for unattended updates to be
+// reviewed later. A resultLog without a file path writes nothing.
+type resultLog struct {
+	filePath string
+	clock    context.Clock
+}
+
+// openResultLog returns the result log given by --log-file, or the default one with
+// --unattended.
+func openResultLog(ctx *context.Context, flagDict FlagDict) (resultLog, error) {
+	if flagDict.logFileFlag != "" {
+		return resultLog{filePath: flagDict.logFileFlag, clock: ctx.Clock()}, nil
+	}
+
+	if !flagDict.unattendedFlag {
+		return resultLog{}, nil
+	}
+
+	localDotLipDir, err := ctx.LocalDotLipDir()
+	if err != nil {
+		return resultLog{}, fmt.Errorf("failed to get local .lip directory\n\t%w", err)
+	}
+
+	return resultLog{filePath: filepath.Join(localDotLipDir.LocalString(), "update.log"), clock: ctx.Clock()}, nil
+}
+
+// writeUpdates logs the updates applied.
+func (l resultLog) writeUpdates(updates []plannedUpdate) {
+	lines := make([]string, 0, len(updates))
+	for _, update := range updates {
+		lines = append(lines, fmt.Sprintf("updated %v %v -> %v", update.toothRepoPath, update.currentVersion,
+			update.targetVersion))
+	}
+
+	l.write(lines...)
+}
+
+// writeNoUpdates logs that no update is allowed by the policy.
+func (l resultLog) writeNoUpdates(policy string) {
+	l.write(fmt.Sprintf("no updates allowed by update policy %v", policy))
+}
+
+// writeFailure logs that the updates failed.
+func (l resultLog) writeFailure(err error) {
+	// Keep each result on one line.
+	l.write("failed: " + strings.Join(strings.Fields(err.Error()), " "))
+}
+
+// ---------------------------------------------------------------------
+
+// write appends timestamped lines to the log file. Failing to write the log must not fail the
+// update, so errors are only warned about.
+func (l resultLog) write(lines ...string) {
+	if l.filePath == "" {
+		return
+	}
+
+	timestamp := l.clock.Now().Format(time.RFC3339)
+
+	content := &strings.Builder{}
+	for _, line := range lines {
+		fmt.Fprintf(content, "%v %v\n", timestamp, line)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(l.filePath), 0755); err != nil {
+		log.Warnf(i18n.T("Failed to write update log %v: %v"), l.filePath, err)
+		return
+	}
+
+	file, err := os.OpenFile(l.filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Warnf(i18n.T("Failed to write update log %v: %v"), l.filePath, err)
+		return
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString(content.String()); err != nil {
+		log.Warnf(i18n.T("Failed to write update log %v: %v"), l.filePath, err)
+	}
+}
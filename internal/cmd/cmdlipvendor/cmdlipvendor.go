@@ -0,0 +1,146 @@
+package cmdlipvendor
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+
+	"github.com/lippkg/lip/internal/cmd/cmdlipinstall"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/snapshot"
+	"github.com/lippkg/lip/internal/tooth"
+	"github.com/lippkg/lip/internal/vendoring"
+	log "github.com/sirupsen/logrus"
+)
+
+type FlagDict struct {
+	helpFlag bool
+}
+
+const helpMessage = `
+Usage:
+  lip vendor [options]
+
+Description:
+  Copy the tooth archives and the asset archives of all installed teeth into the vendor
+  directory of the workspace, with their checksums in vendor/vendor.json. Teeth that are no
+  longer installed are removed from it. Install exclusively from it with lip install --vendor
+  or lip sync --vendor.
+
+Options:
+  -h, --help                  Show help.
+`
+
+func Run(ctx *context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("vendor", flag.ContinueOnError)
+
+	// Rewrite the default usage message.
+	flagSet.Usage = func() {
+		// Do nothing.
+	}
+
+	var flagDict FlagDict
+	flagSet.BoolVar(&flagDict.helpFlag, "help", false, "")
+	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
+
+	if err := flagSet.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags\n\t%w", err)
+	}
+
+	// Help flag has the highest priority.
+	if flagDict.helpFlag {
+		fmt.Print(helpMessage)
+		return nil
+	}
+
+	// Check if there are unexpected arguments.
+	if flagSet.NArg() != 0 {
+		return errcode.Errorf(errcode.InvalidArgument, "unexpected arguments: %v", flagSet.Args())
+	}
+
+	vendoredCount, err := VendorInstalledTeeth(ctx)
+	if err != nil {
+		return err
+	}
+
+	vendorDir, err := ctx.VendorDir()
+	if err != nil {
+		return fmt.Errorf("failed to get vendor directory\n\t%w", err)
+	}
+
+	log.Infof(i18n.T("Vendored %v teeth to %v."), vendoredCount, vendorDir.LocalString())
+
+	return nil
+}
+
+// VendorInstalledTeeth copies the archives of all installed teeth into the vendor directory
+// and returns the number of vendored teeth.
+func VendorInstalledTeeth(ctx *context.Context) (int, error) {
+	metadataList, err := tooth.GetAllMetadata(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list all installed teeth\n\t%w", err)
+	}
+
+	sort.Slice(metadataList, func(i, j int) bool {
+		return metadataList[i].ToothRepoPath() < metadataList[j].ToothRepoPath()
+	})
+
+	v, err := vendoring.Open(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open the vendor directory\n\t%w", err)
+	}
+
+	for _, metadata := range metadataList {
+		log.Infof(i18n.T("Vendoring %v@%v..."), metadata.ToothRepoPath(), metadata.Version())
+
+		archive, err := getArchive(ctx, metadata)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get archive of %v@%v\n\t%w", metadata.ToothRepoPath(), metadata.Version(),
+				err)
+		}
+
+		assetURL, err := archive.Metadata().AssetURL()
+		if err != nil {
+			return 0, fmt.Errorf("failed to get asset URL\n\t%w", err)
+		}
+
+		assetFilePath, err := archive.AssetFilePath()
+		if err != nil {
+			return 0, fmt.Errorf("failed to get asset file path\n\t%w", err)
+		}
+
+		if err := v.Add(metadata.ToothRepoPath(), metadata.Version(), archive.FilePath(), assetURL.String(),
+			assetFilePath); err != nil {
+			return 0, fmt.Errorf("failed to vendor %v@%v\n\t%w", metadata.ToothRepoPath(), metadata.Version(), err)
+		}
+	}
+
+	vendoredCount, err := v.Close()
+	if err != nil {
+		return 0, fmt.Errorf("failed to save the vendor directory\n\t%w", err)
+	}
+
+	return vendoredCount, nil
+}
+
+// ---------------------------------------------------------------------
+
+// getArchive returns the archive of an installed tooth with the asset archive attached. The
+// snapshot kept for rollback is used if there is one, since it is exactly what is installed,
+// e.g. for teeth installed from local archives. Otherwise, the archive is downloaded.
+func getArchive(ctx *context.Context, metadata tooth.Metadata) (tooth.Archive, error) {
+	versions, err := snapshot.List(ctx, metadata.ToothRepoPath())
+	if err != nil {
+		return tooth.Archive{}, fmt.Errorf("failed to list snapshots\n\t%w", err)
+	}
+
+	for _, version := range versions {
+		if version.EQ(metadata.Version()) {
+			return snapshot.Get(ctx, metadata.ToothRepoPath(), version)
+		}
+	}
+
+	return cmdlipinstall.DownloadToothArchive(ctx, metadata.ToothRepoPath(), metadata.Version())
+}
@@ -0,0 +1,408 @@
+package cmdlipverify
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/manifest"
+	"github.com/lippkg/lip/internal/path"
+	"github.com/lippkg/lip/internal/snapshot"
+	"github.com/lippkg/lip/internal/tooth"
+	"github.com/lippkg/lip/internal/zip"
+	"github.com/olekukonko/tablewriter"
+	log "github.com/sirupsen/logrus"
+)
+
+type FlagDict struct {
+	helpFlag    bool
+	restoreFlag bool
+}
+
+const helpMessage = `
+Usage:
+  lip verify [options] [<tooth repository path>...]
+
+Description:
+  Verify the files of installed teeth against the checksums recorded when they were installed,
+  and report modified, missing and extra files. If no tooth is specified, all installed teeth
+  are verified.
+
+Options:
+  -h, --help                  Show help.
+  --restore                   Restore modified and missing files from the cached archives.
+`
+
+// problemKind is the kind of a problem found in installed files.
+type problemKind string
+
+const (
+	modifiedProblem problemKind = "modified"
+	missingProblem  problemKind = "missing"
+	extraProblem    problemKind = "extra"
+)
+
+// problem is a problem found in the installed files of a tooth.
+type problem struct {
+	toothRepoPath string
+	kind          problemKind
+	file          manifest.File
+}
+
+func Run(ctx *context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("verify", flag.ContinueOnError)
+
+	// Rewrite the default usage message.
+	flagSet.Usage = func() {
+		// Do nothing.
+	}
+
+	var flagDict FlagDict
+	flagSet.BoolVar(&flagDict.helpFlag, "help", false, "")
+	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
+	flagSet.BoolVar(&flagDict.restoreFlag, "restore", false, "")
+
+	if err := flagSet.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags\n\t%w", err)
+	}
+
+	// Help flag has the highest priority.
+	if flagDict.helpFlag {
+		fmt.Print(helpMessage)
+		return nil
+	}
+
+	// 1. Load the manifests of all installed teeth.
+
+	metadataList, err := tooth.GetAllMetadata(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list all installed teeth\n\t%w", err)
+	}
+
+	manifests := make(map[string]manifest.Manifest)
+	for _, metadata := range metadataList {
+		toothManifest, ok, err := manifest.Get(ctx, metadata.ToothRepoPath())
+		if err != nil {
+			return fmt.Errorf("failed to get manifest of %v\n\t%w", metadata.ToothRepoPath(), err)
+		}
+
+		if ok {
+			manifests[metadata.ToothRepoPath()] = toothManifest
+		}
+	}
+
+	// 2. Find the teeth to verify.
+
+	toothRepoPaths := flagSet.Args()
+	if len(toothRepoPaths) == 0 {
+		for _, metadata := range metadataList {
+			toothRepoPaths = append(toothRepoPaths, metadata.ToothRepoPath())
+		}
+	}
+
+	for _, toothRepoPath := range toothRepoPaths {
+		isInstalled, err := tooth.IsInstalled(ctx, toothRepoPath)
+		if err != nil {
+			return fmt.Errorf("failed to check if tooth is installed\n\t%w", err)
+		}
+
+		if !isInstalled {
+			return errcode.Errorf(errcode.NotInstalled, "tooth %v is not installed", toothRepoPath)
+		}
+	}
+
+	// 3. Verify the files.
+
+	problems := make([]problem, 0)
+	for _, toothRepoPath := range toothRepoPaths {
+		toothManifest, ok := manifests[toothRepoPath]
+		if !ok {
+			log.Warnf(i18n.T("No manifest of %v is recorded, skip verifying. Reinstall it to record one."),
+				toothRepoPath)
+			continue
+		}
+
+		toothProblems, err := verifyTooth(ctx, toothManifest, manifests)
+		if err != nil {
+			return fmt.Errorf("failed to verify %v\n\t%w", toothRepoPath, err)
+		}
+
+		problems = append(problems, toothProblems...)
+	}
+
+	if len(problems) == 0 {
+		log.Info(i18n.T("All installed files are intact."))
+		return nil
+	}
+
+	printProblems(problems)
+
+	// 4. Restore modified and missing files if requested.
+
+	unresolvedProblemCount := len(problems)
+
+	if flagDict.restoreFlag {
+		for _, p := range problems {
+			if p.kind == extraProblem {
+				continue
+			}
+
+			if err := restoreFile(ctx, manifests[p.toothRepoPath], p.file); err != nil {
+				return fmt.Errorf("failed to restore %v of %v\n\t%w", p.file.Path, p.toothRepoPath, err)
+			}
+
+			log.Infof(i18n.T("Restored %v"), p.file.Path)
+
+			unresolvedProblemCount--
+		}
+	}
+
+	if unresolvedProblemCount > 0 {
+		return errcode.Errorf(errcode.VerificationFailed, "%v problems found in installed files",
+			unresolvedProblemCount)
+	}
+
+	return nil
+}
+
+// ---------------------------------------------------------------------
+
+// extractFile extracts a file from an archive to a destination and returns the checksum of
+// the extracted file.
+func extractFile(ctx *context.Context, archivePath path.Path, source path.Path, dest path.Path) (string, error) {
+	r, err := zip.OpenReader(ctx.FS(), archivePath.LocalString())
+	if err != nil {
+		return "", fmt.Errorf("failed to open zip reader\n\t%w", err)
+	}
+	defer r.Close()
+
+	for _, f := range r.File {
+		filePath, err := path.ParseRelative(f.Name)
+		if err != nil || !filePath.Equal(source) {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return "", fmt.Errorf("failed to open source file\n\t%w", err)
+		}
+		defer rc.Close()
+
+		if err := ctx.FS().MkdirAll(filepath.Dir(dest.LocalString()), 0755); err != nil {
+			return "", fmt.Errorf("failed to create destination directory\n\t%w", err)
+		}
+
+		fw, err := ctx.FS().Create(dest.LocalString())
+		if err != nil {
+			return "", fmt.Errorf("failed to create destination file\n\t%w", err)
+		}
+		defer fw.Close()
+
+		hash := sha256.New()
+		if _, err := io.Copy(io.MultiWriter(fw, hash), rc); err != nil {
+			return "", fmt.Errorf("failed to copy file\n\t%w", err)
+		}
+
+		return hex.EncodeToString(hash.Sum(nil)), nil
+	}
+
+	return "", fmt.Errorf("file %v not found in archive %v", source, archivePath.LocalString())
+}
+
+// findAssetArchive returns an archive to restore the files of a tooth from. The archive used
+// at installation is preferred, and the snapshot of the installed version is used if the
+// archive has been removed from the cache.
+func findAssetArchive(ctx *context.Context, toothManifest manifest.Manifest) (path.Path, error) {
+	assetArchivePath, err := path.Parse(toothManifest.AssetArchive)
+	if err == nil {
+		if _, err := ctx.FS().Stat(assetArchivePath.LocalString()); err == nil {
+			return assetArchivePath, nil
+		}
+	}
+
+	version, err := semver.Parse(toothManifest.Version)
+	if err != nil {
+		return path.Path{}, fmt.Errorf("failed to parse version %v\n\t%w", toothManifest.Version, err)
+	}
+
+	archive, err := snapshot.Get(ctx, toothManifest.ToothRepoPath, version)
+	if err != nil {
+		return path.Path{}, i18n.Errorf("no cached archive of %v@%v found. Reinstall it with lip install --force-reinstall",
+			toothManifest.ToothRepoPath, toothManifest.Version)
+	}
+
+	return archive.AssetFilePath()
+}
+
+// printProblems prints the problems found in installed files.
+func printProblems(problems []problem) {
+	tableString := &strings.Builder{}
+	table := tablewriter.NewWriter(tableString)
+	table.SetHeader([]string{
+		"Tooth", "Problem", "File",
+	})
+
+	for _, p := range problems {
+		table.Append([]string{p.toothRepoPath, string(p.kind), p.file.Path})
+	}
+
+	table.Render()
+
+	fmt.Print(tableString.String())
+}
+
+// restoreFile places the original of a file of a tooth again.
+func restoreFile(ctx *context.Context, toothManifest manifest.Manifest, file manifest.File) error {
+	assetArchivePath, err := findAssetArchive(ctx, toothManifest)
+	if err != nil {
+		return err
+	}
+
+	source, err := path.Parse(file.Source)
+	if err != nil {
+		return fmt.Errorf("failed to parse source path %v\n\t%w", file.Source, err)
+	}
+
+	relDest, err := path.Parse(file.Path)
+	if err != nil {
+		return fmt.Errorf("failed to parse file path %v\n\t%w", file.Path, err)
+	}
+
+	workspaceDir, err := ctx.WorkspaceDir()
+	if err != nil {
+		return err
+	}
+
+	dest := workspaceDir.Join(relDest)
+
+	checksum, err := extractFile(ctx, assetArchivePath, source, dest)
+	if err != nil {
+		return fmt.Errorf("failed to extract %v\n\t%w", file.Source, err)
+	}
+
+	if checksum != file.SHA256 {
+		return errcode.Errorf(errcode.ChecksumMismatch, "checksum mismatch for %v: recorded %v, got %v",
+			file.Path, file.SHA256, checksum)
+	}
+
+	mode, err := manifest.ParseMode(file.Mode)
+	if err != nil {
+		return err
+	}
+
+	if mode != 0 {
+		if err := ctx.FS().Chmod(dest.LocalString(), mode); err != nil {
+			return fmt.Errorf("failed to set mode of %v\n\t%w", file.Path, err)
+		}
+	}
+
+	return nil
+}
+
+// verifyTooth verifies the files of a tooth. Files in the directories of the tooth that are
+// not placed by any installed tooth are reported as extra files.
+func verifyTooth(ctx *context.Context, toothManifest manifest.Manifest, manifests map[string]manifest.Manifest) ([]problem, error) {
+	workspaceDir, err := ctx.WorkspaceDir()
+	if err != nil {
+		return nil, err
+	}
+
+	problems := make([]problem, 0)
+
+	// Verify the placed files.
+
+	dirSet := make(map[string]path.Path)
+	for _, file := range toothManifest.Files {
+		relFilePath, err := path.Parse(file.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse file path %v\n\t%w", file.Path, err)
+		}
+
+		filePath := workspaceDir.Join(relFilePath)
+
+		if dir, err := filePath.Dir(); err == nil && !dir.Equal(workspaceDir) {
+			dirSet[dir.LocalString()] = dir
+		}
+
+		checksum, err := manifest.HashFile(ctx.FS(), filePath)
+		if os.IsNotExist(err) {
+			problems = append(problems, problem{toothManifest.ToothRepoPath, missingProblem, file})
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to hash file %v\n\t%w", file.Path, err)
+		}
+
+		// Config files and user data are meant to be edited.
+		if checksum != file.SHA256 && file.Config == "" && !file.UserData {
+			problems = append(problems, problem{toothManifest.ToothRepoPath, modifiedProblem, file})
+		}
+	}
+
+	// Find extra files. The workspace directory itself is not checked, since it is shared
+	// with everything else.
+
+	placedFileSet := make(map[string]bool)
+	for _, m := range manifests {
+		for _, file := range m.Files {
+			relFilePath, err := path.Parse(file.Path)
+			if err != nil {
+				continue
+			}
+
+			placedFileSet[workspaceDir.Join(relFilePath).LocalString()] = true
+		}
+	}
+
+	dirStrings := make([]string, 0, len(dirSet))
+	for dirString := range dirSet {
+		dirStrings = append(dirStrings, dirString)
+	}
+	sort.Strings(dirStrings)
+
+	for _, dirString := range dirStrings {
+		dir := dirSet[dirString]
+
+		entries, err := ctx.FS().ReadDir(dirString)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read directory %v\n\t%w", dirString, err)
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+
+			entryPath, err := path.Parse(entry.Name())
+			if err != nil {
+				continue
+			}
+
+			entryPath = dir.Join(entryPath)
+			if placedFileSet[entryPath.LocalString()] {
+				continue
+			}
+
+			entryRelPath := entryPath.TrimPrefix(workspaceDir)
+
+			problems = append(problems, problem{
+				toothRepoPath: toothManifest.ToothRepoPath,
+				kind:          extraProblem,
+				file:          manifest.File{Path: entryRelPath.String()},
+			})
+		}
+	}
+
+	return problems, nil
+}
@@ -0,0 +1,318 @@
+package cmdlipwhy
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/record"
+	"github.com/lippkg/lip/internal/tooth"
+	log "github.com/sirupsen/logrus"
+)
+
+type FlagDict struct {
+	helpFlag bool
+}
+
+const helpMessage = `
+Usage:
+  lip why [options] <tooth repository URL>[@<version>]
+
+Description:
+  Explain why a tooth is installed by printing the chains of dependency constraints that lead
+  from explicitly installed teeth to it. If the constraints on the tooth cannot be satisfied
+  together, or by the specified version, print a minimal set of conflicting constraints.
+
+Options:
+  -h, --help                  Show help.
+`
+
+// constraint is a version range an installed tooth declares on a dependency.
+type constraint struct {
+	dependent          tooth.Metadata
+	versionRangeString string
+	versionRange       semver.Range
+}
+
+func Run(ctx *context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("why", flag.ContinueOnError)
+
+	// Rewrite the default usage message.
+	flagSet.Usage = func() {
+		// Do nothing.
+	}
+
+	var flagDict FlagDict
+	flagSet.BoolVar(&flagDict.helpFlag, "help", false, "")
+	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
+
+	if err := flagSet.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags\n\t%w", err)
+	}
+
+	// Help flag has the highest priority.
+	if flagDict.helpFlag {
+		fmt.Print(helpMessage)
+		return nil
+	}
+
+	// Exactly one argument is required.
+	if flagSet.NArg() != 1 {
+		return errcode.Errorf(errcode.InvalidArgument, "invalid number of arguments")
+	}
+
+	toothRepoPath, versionString, isVersionSpecified := strings.Cut(flagSet.Arg(0), "@")
+
+	if !tooth.IsValidToothRepoPath(toothRepoPath) {
+		return errcode.Errorf(errcode.InvalidArgument, "invalid tooth repository path %v", toothRepoPath)
+	}
+
+	var specifiedVersion semver.Version
+	if isVersionSpecified {
+		version, err := semver.Parse(versionString)
+		if err != nil {
+			return errcode.Errorf(errcode.InvalidArgument, "invalid version %v", versionString)
+		}
+
+		specifiedVersion = version
+	}
+
+	metadataList, err := tooth.GetAllMetadata(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list all installed teeth\n\t%w", err)
+	}
+
+	metadataMap := make(map[string]tooth.Metadata)
+	for _, metadata := range metadataList {
+		metadataMap[metadata.ToothRepoPath()] = metadata
+	}
+
+	constraintMap, err := getConstraintMap(metadataList)
+	if err != nil {
+		return err
+	}
+
+	explicitMap := make(map[string]bool)
+	overrideMap := make(map[string]string)
+	for _, metadata := range metadataList {
+		currentRecord, err := record.Get(ctx, metadata.ToothRepoPath())
+		if err != nil {
+			return fmt.Errorf("failed to get record of tooth %v\n\t%w", metadata.ToothRepoPath(), err)
+		}
+
+		explicitMap[metadata.ToothRepoPath()] = currentRecord.IsExplicit
+		overrideMap[metadata.ToothRepoPath()] = currentRecord.Override
+	}
+
+	// 1. Print how the tooth is installed and the chains requiring it.
+
+	metadata, isInstalled := metadataMap[toothRepoPath]
+	switch {
+	case !isInstalled:
+		fmt.Printf(i18n.T("%v is not installed.")+"\n", toothRepoPath)
+
+	case explicitMap[toothRepoPath]:
+		fmt.Printf(i18n.T("%v@%v is installed explicitly.")+"\n", toothRepoPath, metadata.Version())
+
+	default:
+		fmt.Printf(i18n.T("%v@%v is installed as a dependency.")+"\n", toothRepoPath, metadata.Version())
+	}
+
+	if overrideMap[toothRepoPath] != "" {
+		fmt.Printf(i18n.T("It is overridden to version %v despite the constraints of its dependents.")+"\n",
+			overrideMap[toothRepoPath])
+	}
+
+	chains := getChains(toothRepoPath, constraintMap, explicitMap, map[string]bool{toothRepoPath: true})
+	if len(chains) == 0 {
+		fmt.Println(i18n.T("No installed tooth requires it."))
+	} else {
+		fmt.Println(i18n.T("Required by:"))
+		for _, chain := range chains {
+			fmt.Printf("  %v\n", formatChain(chain, metadataMap, explicitMap, toothRepoPath))
+		}
+	}
+
+	// 2. Check whether the constraints on the tooth can be satisfied.
+
+	constraints := constraintMap[toothRepoPath]
+	if len(constraints) == 0 {
+		return nil
+	}
+
+	var candidateVersions semver.Versions
+	switch {
+	case isVersionSpecified:
+		candidateVersions = semver.Versions{specifiedVersion}
+
+	case isInstalled:
+		candidateVersions = semver.Versions{metadata.Version()}
+
+	default:
+		versions, err := tooth.GetAvailableVersions(ctx, toothRepoPath)
+		if err != nil {
+			log.Warnf(i18n.T("Cannot check the constraints against the available versions of %v\n\t%v"),
+				toothRepoPath, err)
+			return nil
+		}
+
+		candidateVersions = versions
+	}
+
+	conflictingConstraints := findConflictingConstraints(constraints, candidateVersions)
+	if len(conflictingConstraints) == 0 {
+		return nil
+	}
+
+	if len(candidateVersions) == 1 {
+		fmt.Printf(i18n.T("%v@%v conflicts with:")+"\n", toothRepoPath, candidateVersions[0])
+	} else {
+		fmt.Printf(i18n.T("No available version of %v satisfies these constraints together:")+"\n", toothRepoPath)
+	}
+
+	for _, c := range conflictingConstraints {
+		fmt.Printf("  "+i18n.T("%v@%v requires %v")+"\n", c.dependent.ToothRepoPath(), c.dependent.Version(),
+			c.versionRangeString)
+	}
+
+	return nil
+}
+
+// ---------------------------------------------------------------------
+
+// getConstraintMap returns the constraints the installed teeth declare, keyed by the tooth
+// they constrain. Constraints are sorted by dependent to make the output stable.
+func getConstraintMap(metadataList []tooth.Metadata) (map[string][]constraint, error) {
+	constraintMap := make(map[string][]constraint)
+
+	for _, metadata := range metadataList {
+		dependencies, err := metadata.Dependencies()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get dependencies of tooth %v\n\t%w", metadata.ToothRepoPath(), err)
+		}
+
+		dependencyStrings := metadata.DependenciesAsStrings()
+
+		for depToothRepoPath, versionRange := range dependencies {
+			constraintMap[depToothRepoPath] = append(constraintMap[depToothRepoPath], constraint{
+				dependent:          metadata,
+				versionRangeString: dependencyStrings[depToothRepoPath],
+				versionRange:       versionRange,
+			})
+		}
+	}
+
+	for _, constraints := range constraintMap {
+		sort.Slice(constraints, func(i, j int) bool {
+			return constraints[i].dependent.ToothRepoPath() < constraints[j].dependent.ToothRepoPath()
+		})
+	}
+
+	return constraintMap, nil
+}
+
+// getChains returns the chains of constraints that lead from explicitly installed teeth, or
+// from teeth no other tooth requires, to a tooth. Each chain starts with the constraint
+// declared by the root. Teeth in onChain are skipped to avoid cycles.
+func getChains(toothRepoPath string, constraintMap map[string][]constraint, explicitMap map[string]bool,
+	onChain map[string]bool) [][]constraint {
+	chains := make([][]constraint, 0)
+
+	for _, c := range constraintMap[toothRepoPath] {
+		dependentToothRepoPath := c.dependent.ToothRepoPath()
+		if onChain[dependentToothRepoPath] {
+			continue
+		}
+
+		// A chain ends at an explicitly installed tooth, since it is the reason to install the
+		// rest of the chain.
+		if explicitMap[dependentToothRepoPath] || len(constraintMap[dependentToothRepoPath]) == 0 {
+			chains = append(chains, []constraint{c})
+			continue
+		}
+
+		onChain[dependentToothRepoPath] = true
+		for _, chain := range getChains(dependentToothRepoPath, constraintMap, explicitMap, onChain) {
+			chains = append(chains, append(chain, c))
+		}
+		delete(onChain, dependentToothRepoPath)
+	}
+
+	return chains
+}
+
+// formatChain formats a chain as the root followed by each tooth with the constraint its
+// dependent declares on it, e.g. a@1.0.0 -> b@1.2.0 (>=1.0.0 <2.0.0) -> c@1.3.0 (>=1.3.0).
+func formatChain(chain []constraint, metadataMap map[string]tooth.Metadata, explicitMap map[string]bool,
+	toothRepoPath string) string {
+	root := chain[0].dependent
+
+	builder := strings.Builder{}
+	builder.WriteString(fmt.Sprintf("%v@%v", root.ToothRepoPath(), root.Version()))
+	if explicitMap[root.ToothRepoPath()] {
+		builder.WriteString(" " + i18n.T("(explicit)"))
+	}
+
+	for i, c := range chain {
+		dependencyToothRepoPath := toothRepoPath
+		if i+1 < len(chain) {
+			dependencyToothRepoPath = chain[i+1].dependent.ToothRepoPath()
+		}
+
+		dependency := dependencyToothRepoPath
+		if metadata, ok := metadataMap[dependencyToothRepoPath]; ok {
+			dependency = fmt.Sprintf("%v@%v", dependencyToothRepoPath, metadata.Version())
+		}
+
+		builder.WriteString(fmt.Sprintf(" -> %v (%v)", dependency, c.versionRangeString))
+	}
+
+	return builder.String()
+}
+
+// findConflictingConstraints returns a minimal set of constraints no candidate version
+// satisfies together, i.e. removing any of them makes the rest satisfiable. If the
+// constraints are satisfiable, nil is returned.
+func findConflictingConstraints(constraints []constraint, candidateVersions semver.Versions) []constraint {
+	if isSatisfiable(constraints, candidateVersions) {
+		return nil
+	}
+
+	// Drop constraints one by one as long as the rest stays unsatisfiable.
+	core := append([]constraint{}, constraints...)
+	for i := 0; i < len(core); {
+		rest := append(append([]constraint{}, core[:i]...), core[i+1:]...)
+		if !isSatisfiable(rest, candidateVersions) {
+			core = rest
+			continue
+		}
+
+		i++
+	}
+
+	return core
+}
+
+// isSatisfiable returns whether any candidate version satisfies all constraints.
+func isSatisfiable(constraints []constraint, candidateVersions semver.Versions) bool {
+	for _, version := range candidateVersions {
+		isSatisfied := true
+		for _, c := range constraints {
+			if !c.versionRange(version) {
+				isSatisfied = false
+				break
+			}
+		}
+
+		if isSatisfied {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,94 @@
+package cmdlipworkspace
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/layout"
+	"github.com/lippkg/lip/internal/tooth"
+	"github.com/olekukonko/tablewriter"
+)
+
+type FlagDict struct {
+	helpFlag bool
+	jsonFlag bool
+}
+
+const helpMessage = `
+Usage:
+  lip workspace [options]
+
+Description:
+  Show the type of the workspace, detected from its files unless configured, and the
+  directories its layout maps the logical placement roots of teeth to, e.g. plugins.
+
+Options:
+  -h, --help                  Show help.
+  --json                      Output in JSON format.
+`
+
+func Run(ctx *context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("workspace", flag.ContinueOnError)
+
+	// Rewrite the default usage message.
+	flagSet.Usage = func() {
+		// Do nothing.
+	}
+
+	var flagDict FlagDict
+	flagSet.BoolVar(&flagDict.helpFlag, "help", false, "")
+	flagSet.BoolVar(&flagDict.helpFlag, "h", false, "")
+	flagSet.BoolVar(&flagDict.jsonFlag, "json", false, "")
+
+	if err := flagSet.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags\n\t%w", err)
+	}
+
+	// Help flag has the highest priority.
+	if flagDict.helpFlag {
+		fmt.Print(helpMessage)
+		return nil
+	}
+
+	// Check if there are unexpected arguments.
+	if flagSet.NArg() != 0 {
+		return errcode.Errorf(errcode.InvalidArgument, "unexpected arguments: %v", flagSet.Args())
+	}
+
+	workspaceLayout, err := layout.Get(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get workspace layout\n\t%w", err)
+	}
+
+	if flagDict.jsonFlag {
+		jsonBytes, err := json.Marshal(workspaceLayout)
+		if err != nil {
+			return fmt.Errorf("failed to marshal JSON\n\t%w", err)
+		}
+
+		fmt.Print(string(jsonBytes))
+
+		return nil
+	}
+
+	fmt.Println(i18n.Sprintf("Workspace type: %v", workspaceLayout.Type))
+
+	tableString := &strings.Builder{}
+	table := tablewriter.NewWriter(tableString)
+	table.SetHeader([]string{"Root", "Directory"})
+
+	for _, root := range tooth.RootNames() {
+		table.Append([]string{root, workspaceLayout.Roots[root]})
+	}
+
+	table.Render()
+
+	fmt.Print(tableString.String())
+
+	return nil
+}
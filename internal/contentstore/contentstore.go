@@ -0,0 +1,145 @@
+package contentstore
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/path"
+	"github.com/lippkg/lip/internal/vfs"
+	log "github.com/sirupsen/logrus"
+)
+
+// Link deduplicates a placed file with the content store. The content store keeps one copy
+// of each distinct file content, named by its SHA-256 checksum. If the content is already
+// in the store, the file is replaced by a hard link to the stored copy. Otherwise, the file
+// is added to the store.
+func Link(ctx *context.Context, filePath path.Path, checksum string) error {
+	debugLogger := ctx.Logger().WithFields(log.Fields{
+		"package": "contentstore",
+		"method":  "Link",
+	})
+
+	objectPath, err := getObjectPath(ctx, checksum)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.FS().MkdirAll(filepath.Dir(objectPath.LocalString()), 0755); err != nil {
+		return fmt.Errorf("failed to create content store directory\n\t%w", err)
+	}
+
+	if _, err := ctx.FS().Stat(objectPath.LocalString()); os.IsNotExist(err) {
+		err := ctx.FS().Link(filePath.LocalString(), objectPath.LocalString())
+		if err == nil {
+			debugLogger.Debugf("Added %v to the content store as %v", filePath.LocalString(), checksum)
+
+			return nil
+		}
+
+		// Another file of the same content may have been added in the meantime, in which case
+		// the file is linked to it below.
+		if !os.IsExist(err) {
+			return fmt.Errorf("failed to add %v to the content store\n\t%w", filePath.LocalString(), err)
+		}
+
+	} else if err != nil {
+		return fmt.Errorf("failed to check content store object %v\n\t%w", objectPath.LocalString(), err)
+	}
+
+	objectInfo, err := ctx.FS().Stat(objectPath.LocalString())
+	if err != nil {
+		return fmt.Errorf("failed to check content store object %v\n\t%w", objectPath.LocalString(), err)
+	}
+
+	// Linked files share their permission bits, so a file with other permission bits than the
+	// stored copy is kept as a copy.
+	fileInfo, err := ctx.FS().Stat(filePath.LocalString())
+	if err != nil {
+		return fmt.Errorf("failed to get file info of %v\n\t%w", filePath.LocalString(), err)
+	}
+
+	if fileInfo.Mode().Perm() != objectInfo.Mode().Perm() {
+		debugLogger.Debugf("Kept %v as a copy because its mode differs from content store object %v",
+			filePath.LocalString(), checksum)
+
+		return nil
+	}
+
+	// Link to a temporary name first, so that the file is kept if linking fails.
+	tmpPath := filePath.LocalString() + ".lip-link"
+
+	if err := ctx.FS().Link(objectPath.LocalString(), tmpPath); err != nil {
+		return fmt.Errorf("failed to link %v to the content store\n\t%w", filePath.LocalString(), err)
+	}
+
+	if err := ctx.FS().Rename(tmpPath, filePath.LocalString()); err != nil {
+		ctx.FS().Remove(tmpPath)
+		return fmt.Errorf("failed to replace %v with a link\n\t%w", filePath.LocalString(), err)
+	}
+
+	debugLogger.Debugf("Linked %v to content store object %v", filePath.LocalString(), checksum)
+
+	return nil
+}
+
+// Prune removes the files in the content store that are no longer linked from any workspace,
+// and returns the number of removed files.
+func Prune(ctx *context.Context) (int, error) {
+	contentStoreDir, err := ctx.ContentStoreDir()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get content store directory\n\t%w", err)
+	}
+
+	removedCount := 0
+
+	err = vfs.Walk(ctx.FS(), contentStoreDir.LocalString(), func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		linkCount, err := vfs.LinkCount(ctx.FS(), filePath)
+		if err != nil {
+			return fmt.Errorf("failed to get link count of %v\n\t%w", filePath, err)
+		}
+
+		if linkCount > 1 {
+			return nil
+		}
+
+		if err := ctx.FS().Remove(filePath); err != nil {
+			return fmt.Errorf("failed to remove %v\n\t%w", filePath, err)
+		}
+
+		removedCount++
+
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return removedCount, fmt.Errorf("failed to prune content store\n\t%w", err)
+	}
+
+	return removedCount, nil
+}
+
+// ---------------------------------------------------------------------
+
+// getObjectPath returns the path in the content store of a content. Contents are spread over
+// subdirectories by the first two characters of their checksums.
+func getObjectPath(ctx *context.Context, checksum string) (path.Path, error) {
+	if len(checksum) < 2 {
+		return path.Path{}, fmt.Errorf("invalid checksum %v", checksum)
+	}
+
+	contentStoreDir, err := ctx.ContentStoreDir()
+	if err != nil {
+		return path.Path{}, fmt.Errorf("failed to get content store directory\n\t%w", err)
+	}
+
+	return contentStoreDir.Join(path.MustParse(checksum[:2])).Join(path.MustParse(checksum)), nil
+}
@@ -1,7 +1,37 @@
 package context
 
 type Config struct {
-	GitHubMirrorURL  string `json:"github_mirror_url"`
-	GoModuleProxyURL string `json:"go_module_proxy_url"`
-	ProxyURL         string `json:"proxy_url"`
+	AdvisoryDBURL          string `json:"advisory_db_url"`
+	ConnectTimeoutMs       int    `json:"connect_timeout_ms"`
+	CredentialStore        string `json:"credential_store"`
+	DNSServers             string `json:"dns_servers"`
+	DownloadRateLimit      string `json:"download_rate_limit"`
+	DownloadTimeoutMs      int    `json:"download_timeout_ms"`
+	ExtraGitHubMirrorURLs  string `json:"extra_github_mirror_urls"`
+	ExtraGoModuleProxyURLs string `json:"extra_go_module_proxy_urls"`
+	GitHubAPIURL           string `json:"github_api_url"`
+	GitHubMirrorURL        string `json:"github_mirror_url"`
+	GitHubToken            string `json:"github_token"`
+	GoModuleProxyURL       string `json:"go_module_proxy_url"`
+	HappyEyeballsDelayMs   int    `json:"happy_eyeballs_delay_ms"`
+	HostOverrides          string `json:"host_overrides"`
+	InstallTimeoutMs       int    `json:"install_timeout_ms"`
+	LANCacheDiscoveryMs    int    `json:"lan_cache_discovery_ms"`
+	Language               string `json:"language"`
+	OCIRegistryURL         string `json:"oci_registry_url"`
+	PerDownloadRateLimit   string `json:"per_download_rate_limit"`
+	PolicyFile             string `json:"policy_file"`
+	ProxyURL               string `json:"proxy_url"`
+	Quarantine             string `json:"quarantine"`
+	RegistryURL            string `json:"registry_url"`
+	RetryBackoffMs         int    `json:"retry_backoff_ms"`
+	RetryBudgetMs          int    `json:"retry_budget_ms"`
+	RetryMaxAttempts       int    `json:"retry_max_attempts"`
+	SnapshotCount          int    `json:"snapshot_count"`
+	Theme                  string `json:"theme"`
+	ToothSource            string `json:"tooth_source"`
+	UpdateCheckHours       int    `json:"update_check_hours"`
+	UpdatePolicy           string `json:"update_policy"`
+	WebhookURLs            string `json:"webhook_urls"`
+	WorkspaceType          string `json:"workspace_type"`
 }
@@ -1,27 +1,142 @@
 package context
 
 import (
+	gocontext "context"
 	"encoding/json"
 	"fmt"
 	"net/url"
 	"os"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/network"
 	"github.com/lippkg/lip/internal/path"
+	"github.com/lippkg/lip/internal/profiling"
+	"github.com/lippkg/lip/internal/prompt"
+	"github.com/lippkg/lip/internal/vfs"
+	log "github.com/sirupsen/logrus"
+)
+
+// Tooth sources, the ToothSource configuration values.
+const (
+	// GoModuleProxyToothSource resolves teeth from the Go module proxy.
+	GoModuleProxyToothSource = "goproxy"
+	// GitHubReleasesToothSource resolves teeth hosted on GitHub from GitHub Releases, and
+	// other teeth from the Go module proxy.
+	GitHubReleasesToothSource = "github"
+	// OCIToothSource resolves teeth from the OCI registry at OCIRegistryURL, and teeth not
+	// found there from the Go module proxy.
+	OCIToothSource = "oci"
+)
+
+// Update policies, the UpdatePolicy configuration values, which limit the updates lip update
+// applies.
+const (
+	// AllUpdatePolicy applies updates to the latest versions.
+	AllUpdatePolicy = "all"
+	// MinorUpdatePolicy applies updates within the installed major versions.
+	MinorUpdatePolicy = "minor"
+	// PatchUpdatePolicy applies updates within the installed minor versions.
+	PatchUpdatePolicy = "patch"
+	// SecurityUpdatePolicy applies only updates fixing security advisories.
+	SecurityUpdatePolicy = "security"
+)
+
+// Credential stores, the CredentialStore configuration values.
+const (
+	// FileCredentialStore keeps credentials in a file in the global .lip directory.
+	FileCredentialStore = "file"
+	// KeychainCredentialStore keeps credentials in the keychain of the operating system.
+	KeychainCredentialStore = "keychain"
+)
+
+// Quarantine handling, the Quarantine configuration values, which decide how the executables
+// placed by teeth are marked as downloaded from the internet, i.e. the quarantine attribute on
+// macOS and the Mark of the Web on Windows.
+const (
+	// KeepQuarantine leaves the marks as they are, and warns about marked executables.
+	KeepQuarantine = "keep"
+	// ClearQuarantine removes the marks, so that the executables run without being checked.
+	ClearQuarantine = "clear"
+	// SetQuarantine adds the marks, so that the executables are checked on their first run.
+	SetQuarantine = "set"
+)
+
+// Themes, the Theme configuration values.
+const (
+	// DefaultTheme styles output with colors and emoji.
+	DefaultTheme = "default"
+	// MinimalTheme styles output with colors only.
+	MinimalTheme = "minimal"
+	// PlainTheme leaves output unstyled.
+	PlainTheme = "plain"
+)
+
+// Workspace types, the WorkspaceType configuration values besides AutoWorkspaceType.
+const (
+	// AutoWorkspaceType detects the type from the files in the workspace.
+	AutoWorkspaceType = "auto"
+	// BDSWorkspaceType is a Minecraft Bedrock Dedicated Server.
+	BDSWorkspaceType = "bds"
+	// LeviLaminaWorkspaceType is a Bedrock Dedicated Server with the LeviLamina mod loader.
+	LeviLaminaWorkspaceType = "levilamina"
+	// GenericWorkspaceType is any other directory.
+	GenericWorkspaceType = "generic"
 )
 
 // Context is the context of the application.
 type Context struct {
-	config     Config
-	lipVersion semver.Version
+	config      Config
+	lipVersion  semver.Version
+	allowYanked bool
+	offline     bool
+	purge       bool
+	sideBySide  bool
+	skippedTags []string
+	symlink     bool
+	hardlink    bool
+	noHistory   bool
+	vendor      bool
+	vendorDir   path.Path
+	timer       *profiling.Timer
+
+	// credentialCache is shared by the copies of the context, e.g. those with timeouts.
+	credentialCache *sync.Map
+	network         *network.Client
+	defaultNetwork  *defaultNetwork
+
+	// Dependencies, replaceable by options.
+	goCtx      gocontext.Context
+	cacheDir   path.Path
+	fs         vfs.FS
+	httpClient network.HTTPClient
+	logger     log.FieldLogger
+	prompter   prompt.Prompter
+	clock      Clock
 }
 
-// New creates a new context.
-func New(config Config, version semver.Version) *Context {
-	return &Context{
-		config:     config,
-		lipVersion: version,
+// New creates a new context, with the default dependencies unless replaced by options.
+func New(config Config, version semver.Version, options ...Option) *Context {
+	ctx := &Context{
+		config:          config,
+		lipVersion:      version,
+		credentialCache: &sync.Map{},
+		defaultNetwork:  &defaultNetwork{},
+		goCtx:           gocontext.Background(),
+		cacheDir:        path.MakeEmpty(),
+		fs:              vfs.OS(),
+		logger:          log.StandardLogger(),
+		prompter:        prompt.Terminal(),
+		clock:           systemClock{},
+	}
+
+	for _, option := range options {
+		option(ctx)
 	}
+
+	return ctx
 }
 
 // Config returns the config.
@@ -29,6 +144,116 @@ func (ctx *Context) Config() *Config {
 	return &ctx.config
 }
 
+// AdvisoryDBURL returns the URL of the security advisory database. An empty URL means the
+// advisories.json of the registry.
+func (ctx *Context) AdvisoryDBURL() (*url.URL, error) {
+	advisoryDBURL, err := url.Parse(ctx.config.AdvisoryDBURL)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse advisory database URL\n\t%w", err)
+	}
+
+	return advisoryDBURL, nil
+}
+
+// GitHubAPIURL returns the GitHub API URL.
+func (ctx *Context) GitHubAPIURL() (*url.URL, error) {
+	gitHubAPIURL, err := url.Parse(ctx.config.GitHubAPIURL)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse GitHub API URL\n\t%w", err)
+	}
+
+	return gitHubAPIURL, nil
+}
+
+// ToothSource returns where teeth are resolved from.
+func (ctx *Context) ToothSource() (string, error) {
+	switch ctx.config.ToothSource {
+	case GoModuleProxyToothSource, GitHubReleasesToothSource, OCIToothSource:
+		return ctx.config.ToothSource, nil
+	}
+
+	return "", fmt.Errorf("unknown tooth source %v, expected %v, %v or %v", ctx.config.ToothSource,
+		GoModuleProxyToothSource, GitHubReleasesToothSource, OCIToothSource)
+}
+
+// UpdatePolicy returns which updates lip update applies by default.
+func (ctx *Context) UpdatePolicy() (string, error) {
+	return ParseUpdatePolicy(ctx.config.UpdatePolicy)
+}
+
+// ParseUpdatePolicy checks an update policy, e.g. given on the command line.
+func ParseUpdatePolicy(updatePolicy string) (string, error) {
+	switch updatePolicy {
+	case AllUpdatePolicy, MinorUpdatePolicy, PatchUpdatePolicy, SecurityUpdatePolicy:
+		return updatePolicy, nil
+	}
+
+	return "", fmt.Errorf("unknown update policy %v, expected %v, %v, %v or %v", updatePolicy, AllUpdatePolicy,
+		MinorUpdatePolicy, PatchUpdatePolicy, SecurityUpdatePolicy)
+}
+
+// CredentialStore returns where credentials are kept.
+func (ctx *Context) CredentialStore() (string, error) {
+	switch ctx.config.CredentialStore {
+	case FileCredentialStore, KeychainCredentialStore:
+		return ctx.config.CredentialStore, nil
+	}
+
+	return "", fmt.Errorf("unknown credential store %v, expected %v or %v", ctx.config.CredentialStore,
+		FileCredentialStore, KeychainCredentialStore)
+}
+
+// Quarantine returns how the executables placed by teeth are marked as downloaded from the
+// internet. Empty is KeepQuarantine.
+func (ctx *Context) Quarantine() (string, error) {
+	switch ctx.config.Quarantine {
+	case "":
+		return KeepQuarantine, nil
+	case KeepQuarantine, ClearQuarantine, SetQuarantine:
+		return ctx.config.Quarantine, nil
+	}
+
+	return "", fmt.Errorf("unknown quarantine handling %v, expected %v, %v or %v", ctx.config.Quarantine,
+		KeepQuarantine, ClearQuarantine, SetQuarantine)
+}
+
+// Theme returns the theme to style output with. Empty is the default theme.
+func (ctx *Context) Theme() (string, error) {
+	switch ctx.config.Theme {
+	case "":
+		return DefaultTheme, nil
+	case DefaultTheme, MinimalTheme, PlainTheme:
+		return ctx.config.Theme, nil
+	}
+
+	return "", fmt.Errorf("unknown theme %v, expected %v, %v or %v", ctx.config.Theme, DefaultTheme, MinimalTheme,
+		PlainTheme)
+}
+
+// WorkspaceType returns the type of the workspace to assume, or AutoWorkspaceType to detect it.
+// Empty is AutoWorkspaceType.
+func (ctx *Context) WorkspaceType() (string, error) {
+	switch ctx.config.WorkspaceType {
+	case "":
+		return AutoWorkspaceType, nil
+	case AutoWorkspaceType, BDSWorkspaceType, LeviLaminaWorkspaceType, GenericWorkspaceType:
+		return ctx.config.WorkspaceType, nil
+	}
+
+	return "", fmt.Errorf("unknown workspace type %v, expected %v, %v, %v or %v", ctx.config.WorkspaceType,
+		AutoWorkspaceType, BDSWorkspaceType, LeviLaminaWorkspaceType, GenericWorkspaceType)
+}
+
+// GitHubToken returns the token to authenticate to GitHub with, taken from the GITHUB_TOKEN
+// environment variable if it is not configured. An empty token means no authentication.
+func (ctx *Context) GitHubToken() string {
+	if ctx.config.GitHubToken != "" {
+		return ctx.config.GitHubToken
+	}
+
+	return os.Getenv("GITHUB_TOKEN")
+}
+
 // GitHubMirrorURL returns the GitHub mirror URL.
 func (ctx *Context) GitHubMirrorURL() (*url.URL, error) {
 	gitHubMirrorURL, err := url.Parse(ctx.config.GitHubMirrorURL)
@@ -49,6 +274,29 @@ func (ctx *Context) GoModuleProxyURL() (*url.URL, error) {
 	return goModuleProxyURL, nil
 }
 
+// ExtraGitHubMirrorURLs returns the GitHub mirrors besides GitHubMirrorURL to download
+// archives from, whichever is the fastest.
+func (ctx *Context) ExtraGitHubMirrorURLs() ([]*url.URL, error) {
+	return parseMirrorURLs(ctx.config.ExtraGitHubMirrorURLs)
+}
+
+// ExtraGoModuleProxyURLs returns the Go module proxies besides GoModuleProxyURL to download
+// archives from, whichever is the fastest.
+func (ctx *Context) ExtraGoModuleProxyURLs() ([]*url.URL, error) {
+	return parseMirrorURLs(ctx.config.ExtraGoModuleProxyURLs)
+}
+
+// OCIRegistryURL returns the URL of the OCI registry to resolve and publish teeth, whose
+// path is the namespace of the repositories of teeth.
+func (ctx *Context) OCIRegistryURL() (*url.URL, error) {
+	ociRegistryURL, err := url.Parse(ctx.config.OCIRegistryURL)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse OCI registry URL\n\t%w", err)
+	}
+
+	return ociRegistryURL, nil
+}
+
 // ProxyURL returns the proxy URL.
 func (ctx *Context) ProxyURL() (*url.URL, error) {
 	proxyURL, err := url.Parse(ctx.config.ProxyURL)
@@ -59,6 +307,221 @@ func (ctx *Context) ProxyURL() (*url.URL, error) {
 	return proxyURL, nil
 }
 
+// RegistryURL returns the registry URL. An empty URL means no registry is configured.
+func (ctx *Context) RegistryURL() (*url.URL, error) {
+	registryURL, err := url.Parse(ctx.config.RegistryURL)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse registry URL\n\t%w", err)
+	}
+
+	return registryURL, nil
+}
+
+// Offline returns whether lip works offline, using only cached data.
+func (ctx *Context) Offline() bool {
+	return ctx.offline
+}
+
+// SetOffline sets whether lip works offline, using only cached data.
+func (ctx *Context) SetOffline(offline bool) {
+	ctx.offline = offline
+}
+
+// CredentialCache returns the cache of the credentials looked up in this run, keyed by host.
+// It is safe for concurrent use, e.g. by downloads from several mirrors at once.
+func (ctx *Context) CredentialCache() *sync.Map {
+	return ctx.credentialCache
+}
+
+// Timer returns the timer of the phases of installing teeth, or nil unless profiling is enabled.
+func (ctx *Context) Timer() *profiling.Timer {
+	return ctx.timer
+}
+
+// SetTimer sets the timer of the phases of installing teeth.
+func (ctx *Context) SetTimer(timer *profiling.Timer) {
+	ctx.timer = timer
+}
+
+// RetryPolicy returns the retry policy of network operations.
+func (ctx *Context) RetryPolicy() network.RetryPolicy {
+	return network.RetryPolicy{
+		MaxAttempts: ctx.config.RetryMaxAttempts,
+		Backoff:     time.Duration(ctx.config.RetryBackoffMs) * time.Millisecond,
+		Budget:      time.Duration(ctx.config.RetryBudgetMs) * time.Millisecond,
+	}
+}
+
+// RateLimit returns the bandwidth caps of downloads.
+func (ctx *Context) RateLimit() (network.RateLimit, error) {
+	total, err := network.ParseRate(ctx.config.DownloadRateLimit)
+	if err != nil {
+		return network.RateLimit{}, fmt.Errorf("cannot parse download rate limit\n\t%w", err)
+	}
+
+	perDownload, err := network.ParseRate(ctx.config.PerDownloadRateLimit)
+	if err != nil {
+		return network.RateLimit{}, fmt.Errorf("cannot parse per-download rate limit\n\t%w", err)
+	}
+
+	return network.RateLimit{
+		Total:       total,
+		PerDownload: perDownload,
+	}, nil
+}
+
+// DialerConfig returns how network connections are dialed.
+func (ctx *Context) DialerConfig() (network.DialerConfig, error) {
+	dnsServers, err := network.ParseDNSServers(ctx.config.DNSServers)
+	if err != nil {
+		return network.DialerConfig{}, fmt.Errorf("cannot parse DNS servers\n\t%w", err)
+	}
+
+	hostOverrides, err := network.ParseHostOverrides(ctx.config.HostOverrides)
+	if err != nil {
+		return network.DialerConfig{}, fmt.Errorf("cannot parse host overrides\n\t%w", err)
+	}
+
+	return network.DialerConfig{
+		DNSServers:    dnsServers,
+		HostOverrides: hostOverrides,
+		FallbackDelay: time.Duration(ctx.config.HappyEyeballsDelayMs) * time.Millisecond,
+		Timeout:       getTimeout(ctx.config.ConnectTimeoutMs),
+	}, nil
+}
+
+// DownloadTimeout returns how long an attempt to download a file or to get content may take. 0
+// means no limit.
+func (ctx *Context) DownloadTimeout() time.Duration {
+	return getTimeout(ctx.config.DownloadTimeoutMs)
+}
+
+// InstallTimeout returns how long lip install may take in total. 0 means no limit.
+func (ctx *Context) InstallTimeout() time.Duration {
+	return getTimeout(ctx.config.InstallTimeoutMs)
+}
+
+// LANCacheDiscoveryTimeout returns how long to wait for lip instances on the local network
+// sharing their caches to answer whether they have a file. 0 means files are not fetched from
+// the local network.
+func (ctx *Context) LANCacheDiscoveryTimeout() time.Duration {
+	return getTimeout(ctx.config.LANCacheDiscoveryMs)
+}
+
+// WebhookURLs returns the URLs to send notifications of operations on teeth to.
+func (ctx *Context) WebhookURLs() ([]*url.URL, error) {
+	webhookURLs := make([]*url.URL, 0)
+	for _, urlString := range strings.Split(ctx.config.WebhookURLs, ",") {
+		urlString = strings.TrimSpace(urlString)
+		if urlString == "" {
+			continue
+		}
+
+		webhookURL, err := url.Parse(urlString)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse webhook URL %v\n\t%w", urlString, err)
+		}
+
+		if (webhookURL.Scheme != "http" && webhookURL.Scheme != "https") || webhookURL.Host == "" {
+			return nil, fmt.Errorf("webhook URL %v is not an HTTP or HTTPS URL", urlString)
+		}
+
+		webhookURLs = append(webhookURLs, webhookURL)
+	}
+
+	return webhookURLs, nil
+}
+
+// AllowYanked returns whether yanked versions can be selected when resolving versions.
+func (ctx *Context) AllowYanked() bool {
+	return ctx.allowYanked
+}
+
+// SetAllowYanked sets whether yanked versions can be selected when resolving versions.
+func (ctx *Context) SetAllowYanked(allowYanked bool) {
+	ctx.allowYanked = allowYanked
+}
+
+// Hardlink returns whether placed files are hard-linked from the content store.
+func (ctx *Context) Hardlink() bool {
+	return ctx.hardlink
+}
+
+// SetHardlink sets whether placed files are hard-linked from the content store.
+func (ctx *Context) SetHardlink(hardlink bool) {
+	ctx.hardlink = hardlink
+}
+
+// NoHistory returns whether operations are left out of the history, e.g. when they are part
+// of an operation recorded on its own.
+func (ctx *Context) NoHistory() bool {
+	return ctx.noHistory
+}
+
+// SetNoHistory sets whether operations are left out of the history.
+func (ctx *Context) SetNoHistory(noHistory bool) {
+	ctx.noHistory = noHistory
+}
+
+// Purge returns whether uninstalling removes user data, edited config files and preserved
+// files as well.
+func (ctx *Context) Purge() bool {
+	return ctx.purge
+}
+
+// SetPurge sets whether uninstalling removes user data, edited config files and preserved
+// files as well.
+func (ctx *Context) SetPurge(purge bool) {
+	ctx.purge = purge
+}
+
+// SideBySide returns whether teeth are installed side by side, i.e. each version is kept in
+// its own directory and the placed files are symlinked from the active one.
+func (ctx *Context) SideBySide() bool {
+	return ctx.sideBySide
+}
+
+// SetSideBySide sets whether teeth are installed side by side.
+func (ctx *Context) SetSideBySide(sideBySide bool) {
+	ctx.sideBySide = sideBySide
+}
+
+// SkippedTags returns the tags of files.place items whose files are not placed.
+func (ctx *Context) SkippedTags() []string {
+	return ctx.skippedTags
+}
+
+// SetSkippedTags sets the tags of files.place items whose files are not placed.
+func (ctx *Context) SetSkippedTags(skippedTags []string) {
+	ctx.skippedTags = skippedTags
+}
+
+// Symlink returns whether placed files are symlinked from the store instead of copied.
+func (ctx *Context) Symlink() bool {
+	return ctx.symlink
+}
+
+// SetSymlink sets whether placed files are symlinked from the store instead of copied.
+func (ctx *Context) SetSymlink(symlink bool) {
+	ctx.symlink = symlink
+}
+
+// Vendor returns whether teeth are resolved exclusively from the vendor directory.
+func (ctx *Context) Vendor() bool {
+	return ctx.vendor
+}
+
+// SetVendor sets whether teeth are resolved exclusively from the vendor directory.
+func (ctx *Context) SetVendor(vendor bool) {
+	ctx.vendor = vendor
+}
+
+// SetVendorDir sets the vendor directory to use instead of the one of the workspace, e.g. the
+// vendor directory extracted from a bundle.
+func (ctx *Context) SetVendorDir(vendorDir path.Path) {
+	ctx.vendorDir = vendorDir
+}
+
 // LipVersion returns the lip version.
 func (ctx *Context) LipVersion() semver.Version {
 	return ctx.lipVersion
@@ -82,9 +545,76 @@ func (ctx *Context) GlobalDotLipDir() (path.Path, error) {
 	return globalDotLipDir, nil
 }
 
+// PolicyFilePath returns the path of the installation policy file, which is PolicyFile if set
+// and policy.json in the global .lip directory otherwise.
+func (ctx *Context) PolicyFilePath() (path.Path, error) {
+	if ctx.config.PolicyFile != "" {
+		policyFilePath, err := path.Parse(ctx.config.PolicyFile)
+		if err != nil {
+			return path.Path{}, fmt.Errorf("cannot parse policy file path\n\t%w", err)
+		}
+
+		return policyFilePath, nil
+	}
+
+	globalDotLipDir, err := ctx.GlobalDotLipDir()
+	if err != nil {
+		return path.Path{}, fmt.Errorf("cannot get global .lip directory\n\t%w", err)
+	}
+
+	return globalDotLipDir.Join(path.MustParse("policy.json")), nil
+}
+
+// StatsFilePath returns the path of the local usage statistics, shown by lip stats. They are
+// kept in the global .lip directory, since the cache is shared by all workspaces.
+func (ctx *Context) StatsFilePath() (path.Path, error) {
+
+	globalDotLipDir, err := ctx.GlobalDotLipDir()
+	if err != nil {
+		return path.Path{}, fmt.Errorf("cannot get global .lip directory\n\t%w", err)
+	}
+
+	path := globalDotLipDir.Join(path.MustParse("stats.json"))
+
+	return path, nil
+}
+
+// UpdateCheckFilePath returns the path of the state of the automatic update check, kept in the
+// global .lip directory so that the check runs at most once per interval for all workspaces.
+func (ctx *Context) UpdateCheckFilePath() (path.Path, error) {
+
+	globalDotLipDir, err := ctx.GlobalDotLipDir()
+	if err != nil {
+		return path.Path{}, fmt.Errorf("cannot get global .lip directory\n\t%w", err)
+	}
+
+	path := globalDotLipDir.Join(path.MustParse("update_check.json"))
+
+	return path, nil
+}
+
+// UpdateCheckInterval returns the minimum interval between automatic update checks. Zero or
+// negative means automatic update checks are disabled.
+func (ctx *Context) UpdateCheckInterval() time.Duration {
+	return time.Duration(ctx.config.UpdateCheckHours) * time.Hour
+}
+
 // LocalDotLipDir returns the local .lip directory.
 func (ctx *Context) LocalDotLipDir() (path.Path, error) {
 
+	workspaceDir, err := ctx.WorkspaceDir()
+	if err != nil {
+		return path.Path{}, err
+	}
+
+	path := workspaceDir.Join(path.MustParse(".lip"))
+
+	return path, nil
+}
+
+// WorkspaceDir returns the workspace directory, i.e. the working directory, which the files of
+// teeth are placed relative to.
+func (ctx *Context) WorkspaceDir() (path.Path, error) {
 	workspaceDirStr, err := os.Getwd()
 	if err != nil {
 		return path.Path{}, fmt.Errorf("cannot get workspace directory\n\t%w", err)
@@ -95,13 +625,32 @@ func (ctx *Context) LocalDotLipDir() (path.Path, error) {
 		return path.Path{}, fmt.Errorf("cannot parse workspace directory\n\t%w", err)
 	}
 
-	path := workspaceDir.Join(path.MustParse(".lip"))
+	return workspaceDir, nil
+}
+
+// VendorDir returns the vendor directory of the workspace, where lip vendor copies the tooth
+// archives of installed teeth, unless another vendor directory is set.
+func (ctx *Context) VendorDir() (path.Path, error) {
+	if !ctx.vendorDir.IsEmpty() {
+		// Copy the path, since joining to it may reuse its underlying array.
+		return path.MakeEmpty().Join(ctx.vendorDir), nil
+	}
+
+	workspaceDir, err := ctx.WorkspaceDir()
+	if err != nil {
+		return path.Path{}, err
+	}
+
+	path := workspaceDir.Join(path.MustParse("vendor"))
 
 	return path, nil
 }
 
 // CacheDir returns the cache directory.
 func (ctx *Context) CacheDir() (path.Path, error) {
+	if !ctx.cacheDir.IsEmpty() {
+		return ctx.cacheDir, nil
+	}
 
 	globalDotLipDir, err := ctx.GlobalDotLipDir()
 	if err != nil {
@@ -113,7 +662,35 @@ func (ctx *Context) CacheDir() (path.Path, error) {
 	return path, nil
 }
 
-// MetadataDir returns the metadata directory.
+// ContentStoreDir returns the content store directory, shared by all workspaces.
+func (ctx *Context) ContentStoreDir() (path.Path, error) {
+
+	globalDotLipDir, err := ctx.GlobalDotLipDir()
+	if err != nil {
+		return path.Path{}, fmt.Errorf("cannot get global .lip directory\n\t%w", err)
+	}
+
+	path := globalDotLipDir.Join(path.MustParse("content_store"))
+
+	return path, nil
+}
+
+// ManifestDir returns the manifest directory, where the originals of config files of installed
+// teeth are kept. Older versions of lip kept the manifests of installed teeth in it as well.
+func (ctx *Context) ManifestDir() (path.Path, error) {
+
+	localDotLipDir, err := ctx.LocalDotLipDir()
+	if err != nil {
+		return path.Path{}, fmt.Errorf("cannot get local .lip directory\n\t%w", err)
+	}
+
+	path := localDotLipDir.Join(path.MustParse("manifests"))
+
+	return path, nil
+}
+
+// MetadataDir returns the metadata directory, where older versions of lip kept the metadata of
+// installed teeth. It is only read to migrate them to the installed-state database.
 func (ctx *Context) MetadataDir() (path.Path, error) {
 
 	localDotLipDir, err := ctx.LocalDotLipDir()
@@ -126,6 +703,157 @@ func (ctx *Context) MetadataDir() (path.Path, error) {
 	return path, nil
 }
 
+// RecordDir returns the record directory, where older versions of lip kept the records of
+// installed teeth. It is only read to migrate them to the installed-state database.
+func (ctx *Context) RecordDir() (path.Path, error) {
+
+	localDotLipDir, err := ctx.LocalDotLipDir()
+	if err != nil {
+		return path.Path{}, fmt.Errorf("cannot get local .lip directory\n\t%w", err)
+	}
+
+	path := localDotLipDir.Join(path.MustParse("records"))
+
+	return path, nil
+}
+
+// StoreDir returns the store directory, where files placed as symlinks are kept.
+func (ctx *Context) StoreDir() (path.Path, error) {
+
+	localDotLipDir, err := ctx.LocalDotLipDir()
+	if err != nil {
+		return path.Path{}, fmt.Errorf("cannot get local .lip directory\n\t%w", err)
+	}
+
+	path := localDotLipDir.Join(path.MustParse("store"))
+
+	return path, nil
+}
+
+// EnvironmentSnapshotDir returns the directory of the snapshots of the installed state of the
+// workspace, made by lip snapshot create.
+func (ctx *Context) EnvironmentSnapshotDir() (path.Path, error) {
+
+	localDotLipDir, err := ctx.LocalDotLipDir()
+	if err != nil {
+		return path.Path{}, fmt.Errorf("cannot get local .lip directory\n\t%w", err)
+	}
+
+	path := localDotLipDir.Join(path.MustParse("environment_snapshots"))
+
+	return path, nil
+}
+
+// EnvFilePath returns the path of the env file of the workspace, whose variables are passed to
+// the commands of teeth and expanded in their templates.
+func (ctx *Context) EnvFilePath() (path.Path, error) {
+
+	localDotLipDir, err := ctx.LocalDotLipDir()
+	if err != nil {
+		return path.Path{}, fmt.Errorf("cannot get local .lip directory\n\t%w", err)
+	}
+
+	path := localDotLipDir.Join(path.MustParse("env"))
+
+	return path, nil
+}
+
+// HistoryFilePath returns the path of the history of the operations on the teeth of the
+// workspace, listed by lip history.
+func (ctx *Context) HistoryFilePath() (path.Path, error) {
+
+	localDotLipDir, err := ctx.LocalDotLipDir()
+	if err != nil {
+		return path.Path{}, fmt.Errorf("cannot get local .lip directory\n\t%w", err)
+	}
+
+	path := localDotLipDir.Join(path.MustParse("history.jsonl"))
+
+	return path, nil
+}
+
+// BackupDir returns the directory where migrations keep the files they replace, in a directory
+// per migration.
+func (ctx *Context) BackupDir() (path.Path, error) {
+
+	localDotLipDir, err := ctx.LocalDotLipDir()
+	if err != nil {
+		return path.Path{}, fmt.Errorf("cannot get local .lip directory\n\t%w", err)
+	}
+
+	path := localDotLipDir.Join(path.MustParse("backups"))
+
+	return path, nil
+}
+
+// StateDBFilePath returns the path of the installed-state database, which keeps the metadata,
+// records and manifests of the installed teeth of the workspace.
+func (ctx *Context) StateDBFilePath() (path.Path, error) {
+
+	localDotLipDir, err := ctx.LocalDotLipDir()
+	if err != nil {
+		return path.Path{}, fmt.Errorf("cannot get local .lip directory\n\t%w", err)
+	}
+
+	path := localDotLipDir.Join(path.MustParse("state.db"))
+
+	return path, nil
+}
+
+// InstallJournalFilePath returns the path of the plan and progress of the installation in
+// progress in the workspace, kept until it finishes so that lip install --resume can resume it.
+func (ctx *Context) InstallJournalFilePath() (path.Path, error) {
+
+	localDotLipDir, err := ctx.LocalDotLipDir()
+	if err != nil {
+		return path.Path{}, fmt.Errorf("cannot get local .lip directory\n\t%w", err)
+	}
+
+	path := localDotLipDir.Join(path.MustParse("install_journal.json"))
+
+	return path, nil
+}
+
+// VersionsDir returns the directory where the versions of teeth installed side by side are
+// kept.
+func (ctx *Context) VersionsDir() (path.Path, error) {
+
+	localDotLipDir, err := ctx.LocalDotLipDir()
+	if err != nil {
+		return path.Path{}, fmt.Errorf("cannot get local .lip directory\n\t%w", err)
+	}
+
+	path := localDotLipDir.Join(path.MustParse("versions"))
+
+	return path, nil
+}
+
+// ServiceDir returns the directory of the OS services registered for installed teeth.
+func (ctx *Context) ServiceDir() (path.Path, error) {
+
+	localDotLipDir, err := ctx.LocalDotLipDir()
+	if err != nil {
+		return path.Path{}, fmt.Errorf("cannot get local .lip directory\n\t%w", err)
+	}
+
+	path := localDotLipDir.Join(path.MustParse("services"))
+
+	return path, nil
+}
+
+// SnapshotDir returns the snapshot directory.
+func (ctx *Context) SnapshotDir() (path.Path, error) {
+
+	localDotLipDir, err := ctx.LocalDotLipDir()
+	if err != nil {
+		return path.Path{}, fmt.Errorf("cannot get local .lip directory\n\t%w", err)
+	}
+
+	path := localDotLipDir.Join(path.MustParse("snapshots"))
+
+	return path, nil
+}
+
 // CreateDirStructure creates the directory structure.
 func (ctx *Context) CreateDirStructure() error {
 
@@ -134,7 +862,7 @@ func (ctx *Context) CreateDirStructure() error {
 		return fmt.Errorf("cannot get global .lip directory\n\t%w", err)
 	}
 
-	if err := os.MkdirAll(globalDotLipDir.LocalString(), 0755); err != nil {
+	if err := ctx.fs.MkdirAll(globalDotLipDir.LocalString(), 0755); err != nil {
 		return fmt.Errorf("cannot create global .lip directory\n\t%w", err)
 	}
 
@@ -143,7 +871,7 @@ func (ctx *Context) CreateDirStructure() error {
 		return fmt.Errorf("cannot get local .lip directory\n\t%w", err)
 	}
 
-	if err := os.MkdirAll(localDotLipDir.LocalString(), 0755); err != nil {
+	if err := ctx.fs.MkdirAll(localDotLipDir.LocalString(), 0755); err != nil {
 		return fmt.Errorf("cannot create local .lip directory\n\t%w", err)
 	}
 
@@ -152,19 +880,91 @@ func (ctx *Context) CreateDirStructure() error {
 		return fmt.Errorf("cannot get cache directory\n\t%w", err)
 	}
 
-	if err := os.MkdirAll(cacheDir.LocalString(), 0755); err != nil {
+	if err := ctx.fs.MkdirAll(cacheDir.LocalString(), 0755); err != nil {
 		return fmt.Errorf("cannot create cache directory\n\t%w", err)
 	}
 
+	contentStoreDir, err := ctx.ContentStoreDir()
+	if err != nil {
+		return fmt.Errorf("cannot get content store directory\n\t%w", err)
+	}
+
+	if err := ctx.fs.MkdirAll(contentStoreDir.LocalString(), 0755); err != nil {
+		return fmt.Errorf("cannot create content store directory\n\t%w", err)
+	}
+
+	manifestDir, err := ctx.ManifestDir()
+	if err != nil {
+		return fmt.Errorf("cannot get manifest directory\n\t%w", err)
+	}
+
+	if err := ctx.fs.MkdirAll(manifestDir.LocalString(), 0755); err != nil {
+		return fmt.Errorf("cannot create manifest directory\n\t%w", err)
+	}
+
 	metadataDir, err := ctx.MetadataDir()
 	if err != nil {
 		return fmt.Errorf("cannot get metadata directory\n\t%w", err)
 	}
 
-	if err := os.MkdirAll(metadataDir.LocalString(), 0755); err != nil {
+	if err := ctx.fs.MkdirAll(metadataDir.LocalString(), 0755); err != nil {
 		return fmt.Errorf("cannot create metadata directory\n\t%w", err)
 	}
 
+	recordDir, err := ctx.RecordDir()
+	if err != nil {
+		return fmt.Errorf("cannot get record directory\n\t%w", err)
+	}
+
+	if err := ctx.fs.MkdirAll(recordDir.LocalString(), 0755); err != nil {
+		return fmt.Errorf("cannot create record directory\n\t%w", err)
+	}
+
+	snapshotDir, err := ctx.SnapshotDir()
+	if err != nil {
+		return fmt.Errorf("cannot get snapshot directory\n\t%w", err)
+	}
+
+	if err := ctx.fs.MkdirAll(snapshotDir.LocalString(), 0755); err != nil {
+		return fmt.Errorf("cannot create snapshot directory\n\t%w", err)
+	}
+
+	storeDir, err := ctx.StoreDir()
+	if err != nil {
+		return fmt.Errorf("cannot get store directory\n\t%w", err)
+	}
+
+	if err := ctx.fs.MkdirAll(storeDir.LocalString(), 0755); err != nil {
+		return fmt.Errorf("cannot create store directory\n\t%w", err)
+	}
+
+	environmentSnapshotDir, err := ctx.EnvironmentSnapshotDir()
+	if err != nil {
+		return fmt.Errorf("cannot get environment snapshot directory\n\t%w", err)
+	}
+
+	if err := ctx.fs.MkdirAll(environmentSnapshotDir.LocalString(), 0755); err != nil {
+		return fmt.Errorf("cannot create environment snapshot directory\n\t%w", err)
+	}
+
+	versionsDir, err := ctx.VersionsDir()
+	if err != nil {
+		return fmt.Errorf("cannot get versions directory\n\t%w", err)
+	}
+
+	if err := ctx.fs.MkdirAll(versionsDir.LocalString(), 0755); err != nil {
+		return fmt.Errorf("cannot create versions directory\n\t%w", err)
+	}
+
+	serviceDir, err := ctx.ServiceDir()
+	if err != nil {
+		return fmt.Errorf("cannot get service directory\n\t%w", err)
+	}
+
+	if err := ctx.fs.MkdirAll(serviceDir.LocalString(), 0755); err != nil {
+		return fmt.Errorf("cannot create service directory\n\t%w", err)
+	}
+
 	return nil
 }
 
@@ -178,14 +978,19 @@ func (ctx *Context) LoadOrCreateConfigFile() error {
 
 	configFilePath := globalDotLipDir.Join(path.MustParse("config.json"))
 
-	if _, err := os.Stat(configFilePath.LocalString()); os.IsNotExist(err) {
+	if _, err := ctx.fs.Stat(configFilePath.LocalString()); os.IsNotExist(err) {
+		// The config file is loaded before the directory structure is created.
+		if err := ctx.fs.MkdirAll(globalDotLipDir.LocalString(), 0755); err != nil {
+			return fmt.Errorf("cannot create global .lip directory\n\t%w", err)
+		}
+
 		ctx.SaveConfigFile()
 
 	} else if err != nil {
 		return fmt.Errorf("cannot get config file info\n\t%w", err)
 
 	} else {
-		jsonBytes, err := os.ReadFile(configFilePath.LocalString())
+		jsonBytes, err := ctx.fs.ReadFile(configFilePath.LocalString())
 		if err != nil {
 			return fmt.Errorf("cannot read config file at %v\n\t%w", configFilePath.LocalString(), err)
 		}
@@ -213,9 +1018,49 @@ func (ctx *Context) SaveConfigFile() error {
 		return fmt.Errorf("cannot marshal config\n\t%w", err)
 	}
 
-	if err := os.WriteFile(configFilePath.LocalString(), jsonBytes, 0644); err != nil {
+	// The config file may contain GitHubToken, so only the user can read it.
+	if err := ctx.fs.WriteFile(configFilePath.LocalString(), jsonBytes, 0600); err != nil {
 		return fmt.Errorf("cannot write config file\n\t%w", err)
 	}
 
+	// WriteFile keeps the mode of an existing file.
+	if err := ctx.fs.Chmod(configFilePath.LocalString(), 0600); err != nil {
+		return fmt.Errorf("cannot set mode of config file\n\t%w", err)
+	}
+
 	return nil
 }
+
+// getTimeout converts a timeout in milliseconds of the configuration to a duration. Zero or
+// negative means no limit, which is 0.
+func getTimeout(timeoutMs int) time.Duration {
+	if timeoutMs <= 0 {
+		return 0
+	}
+
+	return time.Duration(timeoutMs) * time.Millisecond
+}
+
+// parseMirrorURLs parses comma-separated HTTP or HTTPS URLs of mirrors.
+func parseMirrorURLs(urlList string) ([]*url.URL, error) {
+	mirrorURLs := make([]*url.URL, 0)
+	for _, urlString := range strings.Split(urlList, ",") {
+		urlString = strings.TrimSpace(urlString)
+		if urlString == "" {
+			continue
+		}
+
+		mirrorURL, err := url.Parse(urlString)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse mirror URL %v\n\t%w", urlString, err)
+		}
+
+		if (mirrorURL.Scheme != "http" && mirrorURL.Scheme != "https") || mirrorURL.Host == "" {
+			return nil, fmt.Errorf("mirror URL %v is not an HTTP or HTTPS URL", urlString)
+		}
+
+		mirrorURLs = append(mirrorURLs, mirrorURL)
+	}
+
+	return mirrorURLs, nil
+}
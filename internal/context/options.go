@@ -0,0 +1,181 @@
+package context
+
+import (
+	gocontext "context"
+	"sync"
+	"time"
+
+	"github.com/lippkg/lip/internal/network"
+	"github.com/lippkg/lip/internal/path"
+	"github.com/lippkg/lip/internal/prompt"
+	"github.com/lippkg/lip/internal/vfs"
+	log "github.com/sirupsen/logrus"
+)
+
+// Option configures a context when it is created, e.g. to replace its dependencies with fakes.
+type Option func(*Context)
+
+// Clock tells the current time.
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock is the clock of the system.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// WithCacheDir sets the cache directory, instead of the cache directory in the global .lip
+// directory.
+func WithCacheDir(cacheDir path.Path) Option {
+	return func(ctx *Context) {
+		ctx.cacheDir = cacheDir
+	}
+}
+
+// WithGoContext sets the Go context long operations, e.g. downloads, extraction and resolution,
+// are aborted with once it is canceled, e.g. by Ctrl-C.
+func WithGoContext(goCtx gocontext.Context) Option {
+	return func(ctx *Context) {
+		ctx.goCtx = goCtx
+	}
+}
+
+// WithFS sets the file system lip reads and writes its files through, instead of the one of the
+// operating system, e.g. vfs.Mem() to run operations in memory, or vfs.ReadOnly() to make sure
+// nothing is written. Relative names are resolved against the working directory, which is the
+// workspace. The installed-state database, which bbolt maps into memory, the sources of packed
+// teeth, the commands run by teeth, the executable replaced by lip self-update and the cache
+// shared on the local network are still on the file system of the operating system.
+func WithFS(fs vfs.FS) Option {
+	return func(ctx *Context) {
+		ctx.fs = fs
+	}
+}
+
+// WithHTTPClient sets the client all HTTP requests are sent with, instead of one made from the
+// proxy and the dialer configuration.
+func WithHTTPClient(httpClient network.HTTPClient) Option {
+	return func(ctx *Context) {
+		ctx.httpClient = httpClient
+	}
+}
+
+// WithLogger sets the logger the debug logs of operations are written to, instead of the
+// standard logger.
+func WithLogger(logger log.FieldLogger) Option {
+	return func(ctx *Context) {
+		ctx.logger = logger
+	}
+}
+
+// WithPrompter sets the prompter confirmations are asked with, instead of the terminal, e.g.
+// prompt.Fixed(true) to answer yes without asking.
+func WithPrompter(prompter prompt.Prompter) Option {
+	return func(ctx *Context) {
+		ctx.prompter = prompter
+	}
+}
+
+// WithClock sets the clock times recorded by lip are taken from, e.g. in the history, instead
+// of the clock of the system.
+func WithClock(clock Clock) Option {
+	return func(ctx *Context) {
+		ctx.clock = clock
+	}
+}
+
+// Clock returns the clock times recorded by lip are taken from.
+func (ctx *Context) Clock() Clock {
+	return ctx.clock
+}
+
+// FS returns the file system lip reads and writes its files through.
+func (ctx *Context) FS() vfs.FS {
+	return ctx.fs
+}
+
+// GoContext returns the Go context long operations are aborted with once it is canceled.
+func (ctx *Context) GoContext() gocontext.Context {
+	return ctx.goCtx
+}
+
+// Copy returns a copy of the context for a command to set its flags on, so that they are not
+// left set for the command running it, e.g. lip sync running lip install more than once.
+func (ctx *Context) Copy() *Context {
+	copied := *ctx
+	return &copied
+}
+
+// WithTimeout returns a copy of the context whose Go context is canceled after a timeout, and
+// the function to cancel it earlier, which must be called once the operation finishes.
+func (ctx *Context) WithTimeout(timeout time.Duration) (*Context, gocontext.CancelFunc) {
+	copied := *ctx
+	var cancel gocontext.CancelFunc
+	copied.goCtx, cancel = gocontext.WithTimeout(ctx.goCtx, timeout)
+	return &copied, cancel
+}
+
+// WithoutCancel returns a copy of the context whose Go context is never canceled, e.g. to roll
+// back changes after an operation is interrupted.
+func (ctx *Context) WithoutCancel() *Context {
+	copied := *ctx
+	copied.goCtx = gocontext.Background()
+	return &copied
+}
+
+// Prompter returns the prompter confirmations are asked with.
+func (ctx *Context) Prompter() prompt.Prompter {
+	return ctx.prompter
+}
+
+// SetPrompter sets the prompter confirmations are asked with, e.g. by lip --prompt.
+func (ctx *Context) SetPrompter(prompter prompt.Prompter) {
+	ctx.prompter = prompter
+}
+
+// HTTPClient returns the client all HTTP requests are sent with. Nil means a client made from
+// the proxy and the dialer configuration.
+func (ctx *Context) HTTPClient() network.HTTPClient {
+	return ctx.httpClient
+}
+
+// Network returns the client network requests are sent with. Unless set by SetNetwork, it
+// sends requests with the HTTP client of the context, writes downloads to its file system and
+// logs to its logger, with the default dialer configuration and without rate limits or
+// credentials. That client is made once and shared by the copies of the context.
+func (ctx *Context) Network() *network.Client {
+	if ctx.network != nil {
+		return ctx.network
+	}
+
+	ctx.defaultNetwork.once.Do(func() {
+		ctx.defaultNetwork.client = network.NewClient(network.ClientConfig{
+			HTTPClient:   ctx.httpClient,
+			FS:           ctx.fs,
+			Logger:       ctx.logger,
+			DialerConfig: network.DefaultDialerConfig(),
+		})
+	})
+
+	return ctx.defaultNetwork.client
+}
+
+// defaultNetwork is the client made by Network when none is set by SetNetwork.
+type defaultNetwork struct {
+	once   sync.Once
+	client *network.Client
+}
+
+// SetNetwork sets the client network requests are sent with, e.g. one made from the network
+// configuration and flags by lip. It is shared by the copies of the context made afterwards.
+func (ctx *Context) SetNetwork(client *network.Client) {
+	ctx.network = client
+}
+
+// Logger returns the logger the debug logs of operations are written to.
+func (ctx *Context) Logger() log.FieldLogger {
+	return ctx.logger
+}
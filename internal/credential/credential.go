@@ -0,0 +1,169 @@
+package credential
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"strings"
+
+	"github.com/lippkg/lip/internal/context"
+)
+
+// Credential authenticates requests to a host, with a bearer token if Token is set, or with
+// basic authentication otherwise.
+type Credential struct {
+	Token    string `json:"token,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// store keeps credentials by host.
+type store interface {
+	// get returns the credential of a host. The second return value is false if there is none.
+	get(host string) (Credential, bool, error)
+	set(host string, credential Credential) error
+	// remove removes the credential of a host. The return value is false if there was none.
+	remove(host string) (bool, error)
+}
+
+// ParseHost parses the host a credential is for, given as a host with an optional port, e.g.
+// registry.example.com, or as a URL, e.g. https://registry.example.com/path.
+func ParseHost(s string) (string, error) {
+	host := s
+	if strings.Contains(s, "://") {
+		u, err := url.Parse(s)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse URL %v\n\t%w", s, err)
+		}
+
+		host = u.Host
+	}
+
+	host = strings.ToLower(strings.TrimSuffix(host, "/"))
+	if host == "" || strings.ContainsAny(host, "/ ") {
+		return "", fmt.Errorf("invalid host %v, expected e.g. registry.example.com", s)
+	}
+
+	return host, nil
+}
+
+// Get returns the credential of a host. The second return value is false if there is none.
+// Credentials are cached in ctx, so that requests to the same host do not read the store again.
+// It is safe for concurrent use.
+func Get(ctx *context.Context, host string) (Credential, bool, error) {
+	cache := ctx.CredentialCache()
+
+	if cached, ok := cache.Load(host); ok {
+		credential := cached.(*Credential)
+		if credential == nil {
+			return Credential{}, false, nil
+		}
+
+		return *credential, true, nil
+	}
+
+	s, err := getStore(ctx)
+	if err != nil {
+		return Credential{}, false, err
+	}
+
+	credential, ok, err := s.get(host)
+	if err != nil {
+		// Do not try the store again for every request to the host.
+		cache.Store(host, (*Credential)(nil))
+
+		return Credential{}, false, fmt.Errorf("failed to get credential of %v\n\t%w", host, err)
+	}
+
+	if ok {
+		cache.Store(host, &credential)
+	} else {
+		cache.Store(host, (*Credential)(nil))
+	}
+
+	return credential, ok, nil
+}
+
+// Set saves the credential of a host, replacing any existing one.
+func Set(ctx *context.Context, host string, credential Credential) error {
+	s, err := getStore(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := s.set(host, credential); err != nil {
+		return fmt.Errorf("failed to save credential of %v\n\t%w", host, err)
+	}
+
+	ctx.CredentialCache().Store(host, &credential)
+
+	return nil
+}
+
+// Remove removes the credential of a host. The return value is false if there was none.
+func Remove(ctx *context.Context, host string) (bool, error) {
+	s, err := getStore(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	removed, err := s.remove(host)
+	if err != nil {
+		return false, fmt.Errorf("failed to remove credential of %v\n\t%w", host, err)
+	}
+
+	ctx.CredentialCache().Store(host, (*Credential)(nil))
+
+	return removed, nil
+}
+
+// ---------------------------------------------------------------------
+
+// getStore returns the configured credential store.
+func getStore(ctx *context.Context) (store, error) {
+	credentialStore, err := ctx.CredentialStore()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credential store\n\t%w", err)
+	}
+
+	if credentialStore == context.KeychainCredentialStore {
+		return keychainStore{}, nil
+	}
+
+	return newFileStore(ctx)
+}
+
+// marshalSecret encodes a credential as the secret saved in a keychain.
+func marshalSecret(credential Credential) (string, error) {
+	jsonBytes, err := json.Marshal(credential)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal credential\n\t%w", err)
+	}
+
+	return string(jsonBytes), nil
+}
+
+// unmarshalSecret decodes the secret saved in a keychain.
+func unmarshalSecret(secret string) (Credential, error) {
+	var credential Credential
+	if err := json.Unmarshal([]byte(secret), &credential); err != nil {
+		return Credential{}, fmt.Errorf("failed to unmarshal credential\n\t%w", err)
+	}
+
+	return credential, nil
+}
+
+// commandError describes a failed keychain command, with its error output if any.
+func commandError(action string, stderr string, err error) error {
+	if errors.Is(err, exec.ErrNotFound) {
+		return fmt.Errorf("failed to %v, the keychain command is not available\n\t%w", action, err)
+	}
+
+	if stderr = strings.TrimSpace(stderr); stderr != "" {
+		return fmt.Errorf("failed to %v: %v\n\t%w", action, stderr, err)
+	}
+
+	return fmt.Errorf("failed to %v\n\t%w", action, err)
+}
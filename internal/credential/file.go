@@ -0,0 +1,102 @@
+package credential
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/path"
+)
+
+// fileName is the name of the file in the global .lip directory that the file store keeps
+// credentials in. It is only readable by the user, since the credentials are saved in plain
+// text.
+const fileName = "credentials.json"
+
+// fileStore keeps credentials in a JSON file mapping hosts to credentials.
+type fileStore struct {
+	filePath path.Path
+}
+
+func newFileStore(ctx *context.Context) (fileStore, error) {
+	globalDotLipDir, err := ctx.GlobalDotLipDir()
+	if err != nil {
+		return fileStore{}, fmt.Errorf("failed to get global .lip directory\n\t%w", err)
+	}
+
+	return fileStore{filePath: globalDotLipDir.Join(path.MustParse(fileName))}, nil
+}
+
+func (s fileStore) get(host string) (Credential, bool, error) {
+	credentials, err := s.load()
+	if err != nil {
+		return Credential{}, false, err
+	}
+
+	credential, ok := credentials[host]
+	return credential, ok, nil
+}
+
+func (s fileStore) set(host string, credential Credential) error {
+	credentials, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	credentials[host] = credential
+
+	return s.save(credentials)
+}
+
+func (s fileStore) remove(host string) (bool, error) {
+	credentials, err := s.load()
+	if err != nil {
+		return false, err
+	}
+
+	if _, ok := credentials[host]; !ok {
+		return false, nil
+	}
+
+	delete(credentials, host)
+
+	return true, s.save(credentials)
+}
+
+// load reads the credentials file. A missing file means no credentials.
+func (s fileStore) load() (map[string]Credential, error) {
+	credentials := make(map[string]Credential)
+
+	jsonBytes, err := os.ReadFile(s.filePath.LocalString())
+	if os.IsNotExist(err) {
+		return credentials, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read credentials file %v\n\t%w", s.filePath.LocalString(), err)
+	}
+
+	if err := json.Unmarshal(jsonBytes, &credentials); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal credentials file %v\n\t%w", s.filePath.LocalString(), err)
+	}
+
+	return credentials, nil
+}
+
+func (s fileStore) save(credentials map[string]Credential) error {
+	jsonBytes, err := json.MarshalIndent(credentials, "", "    ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal credentials\n\t%w", err)
+	}
+
+	if err := os.WriteFile(s.filePath.LocalString(), jsonBytes, 0600); err != nil {
+		return fmt.Errorf("failed to write credentials file %v\n\t%w", s.filePath.LocalString(), err)
+	}
+
+	// WriteFile keeps the mode of an existing file.
+	if err := os.Chmod(s.filePath.LocalString(), 0600); err != nil {
+		return fmt.Errorf("failed to restrict permissions of credentials file %v\n\t%w",
+			s.filePath.LocalString(), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package credential
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// keychainService is the service the credentials of lip are saved under in the keychain.
+const keychainService = "lip"
+
+// errSecItemNotFound is the exit code of security when there is no such keychain item.
+const errSecItemNotFound = 44
+
+// keychainStore keeps credentials in the login keychain with the security command.
+type keychainStore struct{}
+
+func (keychainStore) get(host string) (Credential, bool, error) {
+	output, err := exec.Command("security", "find-generic-password", "-s", keychainService, "-a", host,
+		"-w").Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == errSecItemNotFound {
+		return Credential{}, false, nil
+	} else if err != nil {
+		return Credential{}, false, commandError("read keychain", "", err)
+	}
+
+	credential, err := unmarshalSecret(strings.TrimSpace(string(output)))
+	if err != nil {
+		return Credential{}, false, err
+	}
+
+	return credential, true, nil
+}
+
+func (keychainStore) set(host string, credential Credential) error {
+	secret, err := marshalSecret(credential)
+	if err != nil {
+		return err
+	}
+
+	// Run the command interactively so that the secret is not in the arguments of a process.
+	cmd := exec.Command("security", "-i")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %v -a %v -X %v\n",
+		keychainService, host, hex.EncodeToString([]byte(secret))))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	if err == nil && stderr.Len() != 0 {
+		// security succeeds in interactive mode even if the command fails.
+		err = errors.New("security reported an error")
+	}
+	if err != nil {
+		return commandError("write keychain", stderr.String(), err)
+	}
+
+	return nil
+}
+
+func (keychainStore) remove(host string) (bool, error) {
+	err := exec.Command("security", "delete-generic-password", "-s", keychainService, "-a", host).Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == errSecItemNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, commandError("delete from keychain", "", err)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,74 @@
+package credential
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+	"strings"
+)
+
+// keychainService is the service the credentials of lip are saved under in the keyring.
+const keychainService = "lip"
+
+// keychainStore keeps credentials in the Secret Service keyring, e.g. GNOME Keyring or
+// KWallet, with the secret-tool command.
+type keychainStore struct{}
+
+func (keychainStore) get(host string) (Credential, bool, error) {
+	cmd := exec.Command("secret-tool", "lookup", "service", keychainService, "host", host)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+
+	// secret-tool fails without a message if there is no such item.
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && stderr.Len() == 0 {
+		return Credential{}, false, nil
+	} else if err != nil {
+		return Credential{}, false, commandError("read keyring", stderr.String(), err)
+	}
+
+	credential, err := unmarshalSecret(string(output))
+	if err != nil {
+		return Credential{}, false, err
+	}
+
+	return credential, true, nil
+}
+
+func (keychainStore) set(host string, credential Credential) error {
+	secret, err := marshalSecret(credential)
+	if err != nil {
+		return err
+	}
+
+	// secret-tool reads the secret from the standard input, so that it is not in the arguments
+	// of a process.
+	cmd := exec.Command("secret-tool", "store", "--label", "lip credential for "+host,
+		"service", keychainService, "host", host)
+	cmd.Stdin = strings.NewReader(secret)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return commandError("write keyring", stderr.String(), err)
+	}
+
+	return nil
+}
+
+func (s keychainStore) remove(host string) (bool, error) {
+	// secret-tool succeeds whether or not there is such an item.
+	_, ok, err := s.get(host)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	cmd := exec.Command("secret-tool", "clear", "service", keychainService, "host", host)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return false, commandError("delete from keyring", stderr.String(), err)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,27 @@
+//go:build !darwin && !linux && !windows
+
+package credential
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// keychainStore reports that there is no supported keychain on this operating system.
+type keychainStore struct{}
+
+func (keychainStore) get(host string) (Credential, bool, error) {
+	return Credential{}, false, errUnsupportedKeychain()
+}
+
+func (keychainStore) set(host string, credential Credential) error {
+	return errUnsupportedKeychain()
+}
+
+func (keychainStore) remove(host string) (bool, error) {
+	return false, errUnsupportedKeychain()
+}
+
+func errUnsupportedKeychain() error {
+	return fmt.Errorf("the keychain credential store is not supported on %v", runtime.GOOS)
+}
@@ -0,0 +1,127 @@
+package credential
+
+import (
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// Values of the Windows Credential Manager API.
+const (
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2
+)
+
+var (
+	advapi32        = windows.NewLazySystemDLL("advapi32.dll")
+	procCredReadW   = advapi32.NewProc("CredReadW")
+	procCredWriteW  = advapi32.NewProc("CredWriteW")
+	procCredDeleteW = advapi32.NewProc("CredDeleteW")
+	procCredFree    = advapi32.NewProc("CredFree")
+)
+
+// credentialW is the CREDENTIALW structure of the Windows Credential Manager API.
+type credentialW struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        windows.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// keychainStore keeps credentials in the Windows Credential Manager, as generic credentials
+// named lip:<host>.
+type keychainStore struct{}
+
+func (keychainStore) get(host string) (Credential, bool, error) {
+	targetName, err := windows.UTF16PtrFromString(getTargetName(host))
+	if err != nil {
+		return Credential{}, false, fmt.Errorf("failed to encode credential name\n\t%w", err)
+	}
+
+	var cred *credentialW
+	r, _, err := procCredReadW.Call(uintptr(unsafe.Pointer(targetName)), credTypeGeneric, 0,
+		uintptr(unsafe.Pointer(&cred)))
+	if r == 0 {
+		if err == windows.ERROR_NOT_FOUND {
+			return Credential{}, false, nil
+		}
+
+		return Credential{}, false, fmt.Errorf("failed to read Windows Credential Manager\n\t%w", err)
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+
+	secret := string(unsafe.Slice(cred.CredentialBlob, cred.CredentialBlobSize))
+
+	credential, err := unmarshalSecret(secret)
+	if err != nil {
+		return Credential{}, false, err
+	}
+
+	return credential, true, nil
+}
+
+func (keychainStore) set(host string, credential Credential) error {
+	secret, err := marshalSecret(credential)
+	if err != nil {
+		return err
+	}
+
+	targetName, err := windows.UTF16PtrFromString(getTargetName(host))
+	if err != nil {
+		return fmt.Errorf("failed to encode credential name\n\t%w", err)
+	}
+
+	userName, err := windows.UTF16PtrFromString(credential.Username)
+	if err != nil {
+		return fmt.Errorf("failed to encode username\n\t%w", err)
+	}
+
+	blob := []byte(secret)
+	cred := credentialW{
+		Type:               credTypeGeneric,
+		TargetName:         targetName,
+		CredentialBlobSize: uint32(len(blob)),
+		CredentialBlob:     &blob[0],
+		Persist:            credPersistLocalMachine,
+		UserName:           userName,
+	}
+
+	r, _, err := procCredWriteW.Call(uintptr(unsafe.Pointer(&cred)), 0)
+	if r == 0 {
+		return fmt.Errorf("failed to write Windows Credential Manager\n\t%w", err)
+	}
+
+	return nil
+}
+
+func (keychainStore) remove(host string) (bool, error) {
+	targetName, err := windows.UTF16PtrFromString(getTargetName(host))
+	if err != nil {
+		return false, fmt.Errorf("failed to encode credential name\n\t%w", err)
+	}
+
+	r, _, err := procCredDeleteW.Call(uintptr(unsafe.Pointer(targetName)), credTypeGeneric, 0)
+	if r == 0 {
+		if err == windows.ERROR_NOT_FOUND {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("failed to delete from Windows Credential Manager\n\t%w", err)
+	}
+
+	return true, nil
+}
+
+// getTargetName returns the name of the generic credential of a host.
+func getTargetName(host string) string {
+	return "lip:" + host
+}
@@ -0,0 +1,496 @@
+package delta
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/lippkg/lip/internal/errcode"
+)
+
+// A patch starts with the magic, the SHA-256 checksums of the old and the new file and the size
+// of the new file, followed by a deflate stream of instructions. Each instruction either copies
+// a range of the old file or inserts literal bytes.
+//
+// Patches are made by matching blocks of the old file in the new file with a rolling hash. Tooth
+// archives are zip files, in which unchanged files keep their compressed bytes, so the patch
+// between two versions is roughly the size of the changed files.
+const magic = "LIPDELTA1\n"
+
+const (
+	opCopy   = 0
+	opInsert = 1
+)
+
+// blockSize is the size of the blocks of the old file matched in the new file. Shorter
+// matches are inserted as literal bytes.
+const blockSize = 64
+
+// hashBase is the base of the polynomial rolling hash.
+const hashBase = 0x100000001b3
+
+// Header is the header of a patch.
+type Header struct {
+	// OldChecksum and NewChecksum are the SHA-256 checksums of the old and the new file.
+	OldChecksum [sha256.Size]byte
+	NewChecksum [sha256.Size]byte
+	NewSize     uint64
+}
+
+// Diff writes the patch transforming the old file into the new file. The old file is read at
+// random, and the new file is read twice, once to checksum it and once to diff it, keeping only
+// the bytes not covered by instructions yet in memory.
+func Diff(oldContent io.ReaderAt, oldSize int64, newContent io.ReadSeeker, w io.Writer) error {
+	var header Header
+
+	oldHash := sha256.New()
+	if _, err := io.Copy(oldHash, io.NewSectionReader(oldContent, 0, oldSize)); err != nil {
+		return fmt.Errorf("failed to read old file\n\t%w", err)
+	}
+	copy(header.OldChecksum[:], oldHash.Sum(nil))
+
+	newHash := sha256.New()
+	newSize, err := io.Copy(newHash, newContent)
+	if err != nil {
+		return fmt.Errorf("failed to read new file\n\t%w", err)
+	}
+	copy(header.NewChecksum[:], newHash.Sum(nil))
+	header.NewSize = uint64(newSize)
+
+	if _, err := newContent.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek new file\n\t%w", err)
+	}
+
+	if err := writeHeader(w, header); err != nil {
+		return err
+	}
+
+	flateWriter, err := flate.NewWriter(w, flate.BestCompression)
+	if err != nil {
+		return fmt.Errorf("failed to create deflate writer\n\t%w", err)
+	}
+
+	bufferedWriter := bufio.NewWriter(flateWriter)
+	if err := writeInstructions(bufferedWriter, oldContent, oldSize, newContent); err != nil {
+		return err
+	}
+
+	if err := bufferedWriter.Flush(); err != nil {
+		return fmt.Errorf("failed to write patch\n\t%w", err)
+	}
+
+	if err := flateWriter.Close(); err != nil {
+		return fmt.Errorf("failed to write patch\n\t%w", err)
+	}
+
+	return nil
+}
+
+// Apply reads a patch and writes the new file made from the old file. The old file must have
+// the checksum recorded in the patch, and the new file is verified against its checksum.
+func Apply(oldContent io.ReaderAt, oldSize int64, patch io.Reader, w io.Writer) error {
+	bufferedPatch := bufio.NewReader(patch)
+
+	header, err := ReadHeader(bufferedPatch)
+	if err != nil {
+		return err
+	}
+
+	oldHash := sha256.New()
+	if _, err := io.Copy(oldHash, io.NewSectionReader(oldContent, 0, oldSize)); err != nil {
+		return fmt.Errorf("failed to read old file\n\t%w", err)
+	}
+
+	if !bytes.Equal(oldHash.Sum(nil), header.OldChecksum[:]) {
+		return errcode.Errorf(errcode.ChecksumMismatch, "the patch does not apply to the old file")
+	}
+
+	instructions := bufio.NewReader(flate.NewReader(bufferedPatch))
+	newHash := sha256.New()
+	output := io.MultiWriter(w, newHash)
+
+	var written uint64
+	for written < header.NewSize {
+		op, err := instructions.ReadByte()
+		if err != nil {
+			return errcode.Errorf(errcode.MetadataInvalid, "truncated patch: %v", err)
+		}
+
+		length, err := binary.ReadUvarint(instructions)
+		if err != nil {
+			return errcode.Errorf(errcode.MetadataInvalid, "truncated patch: %v", err)
+		}
+
+		if length > header.NewSize-written {
+			return errcode.Errorf(errcode.MetadataInvalid, "invalid patch: output exceeds %v bytes", header.NewSize)
+		}
+
+		switch op {
+		case opCopy:
+			offset, err := binary.ReadUvarint(instructions)
+			if err != nil {
+				return errcode.Errorf(errcode.MetadataInvalid, "truncated patch: %v", err)
+			}
+
+			if offset > uint64(oldSize) || length > uint64(oldSize)-offset {
+				return errcode.Errorf(errcode.MetadataInvalid, "invalid patch: copy out of range of the old file")
+			}
+
+			if _, err := io.Copy(output, io.NewSectionReader(oldContent, int64(offset), int64(length))); err != nil {
+				return fmt.Errorf("failed to copy from old file\n\t%w", err)
+			}
+
+		case opInsert:
+			if _, err := io.CopyN(output, instructions, int64(length)); err != nil {
+				return errcode.Errorf(errcode.MetadataInvalid, "truncated patch: %v", err)
+			}
+
+		default:
+			return errcode.Errorf(errcode.MetadataInvalid, "invalid patch: unknown instruction %v", op)
+		}
+
+		written += length
+	}
+
+	// The instructions end with the new file, and the patch with the instructions, so that a
+	// truncated patch is not taken for a complete one.
+	if _, err := instructions.ReadByte(); err == nil {
+		return errcode.Errorf(errcode.MetadataInvalid, "invalid patch: instructions beyond the new file")
+	} else if err != io.EOF {
+		return errcode.Errorf(errcode.MetadataInvalid, "truncated patch: %v", err)
+	}
+
+	if _, err := bufferedPatch.ReadByte(); err != io.EOF {
+		return errcode.Errorf(errcode.MetadataInvalid, "invalid patch: data after the instructions")
+	}
+
+	if !bytes.Equal(newHash.Sum(nil), header.NewChecksum[:]) {
+		return errcode.Errorf(errcode.ChecksumMismatch, "the patched file does not match the checksum in the patch")
+	}
+
+	return nil
+}
+
+// ReadHeader reads the header of a patch.
+func ReadHeader(r io.Reader) (Header, error) {
+	magicBytes := make([]byte, len(magic))
+	if _, err := io.ReadFull(r, magicBytes); err != nil || string(magicBytes) != magic {
+		return Header{}, errcode.Errorf(errcode.MetadataInvalid, "not a patch made by lip")
+	}
+
+	var header Header
+	if _, err := io.ReadFull(r, header.OldChecksum[:]); err != nil {
+		return Header{}, errcode.Errorf(errcode.MetadataInvalid, "truncated patch: %v", err)
+	}
+
+	if _, err := io.ReadFull(r, header.NewChecksum[:]); err != nil {
+		return Header{}, errcode.Errorf(errcode.MetadataInvalid, "truncated patch: %v", err)
+	}
+
+	if err := binary.Read(r, binary.BigEndian, &header.NewSize); err != nil {
+		return Header{}, errcode.Errorf(errcode.MetadataInvalid, "truncated patch: %v", err)
+	}
+
+	return header, nil
+}
+
+// ---------------------------------------------------------------------
+
+// writeHeader writes the header of a patch.
+func writeHeader(w io.Writer, header Header) error {
+	if _, err := io.WriteString(w, magic); err != nil {
+		return fmt.Errorf("failed to write patch\n\t%w", err)
+	}
+
+	if _, err := w.Write(header.OldChecksum[:]); err != nil {
+		return fmt.Errorf("failed to write patch\n\t%w", err)
+	}
+
+	if _, err := w.Write(header.NewChecksum[:]); err != nil {
+		return fmt.Errorf("failed to write patch\n\t%w", err)
+	}
+
+	if err := binary.Write(w, binary.BigEndian, header.NewSize); err != nil {
+		return fmt.Errorf("failed to write patch\n\t%w", err)
+	}
+
+	return nil
+}
+
+// writeInstructions writes the instructions making the new file from the old file.
+func writeInstructions(w *bufio.Writer, oldContent io.ReaderAt, oldSize int64, newContent io.Reader) error {
+	blocks, err := indexBlocks(oldContent, oldSize)
+	if err != nil {
+		return err
+	}
+
+	// highestPower is hashBase^(blockSize-1), to remove the leaving byte from the rolling hash.
+	var highestPower uint64 = 1
+	for i := 0; i < blockSize-1; i++ {
+		highestPower *= hashBase
+	}
+
+	newReader := newPendingReader(newContent)
+	oldBlock := make([]byte, blockSize)
+
+	// The block of the new file at position in the pending bytes is hashed into hash.
+	position := 0
+
+	if err := newReader.fill(blockSize); err != nil {
+		return err
+	}
+
+	var hash uint64
+	if len(newReader.pending) >= blockSize {
+		hash = hashBlock(newReader.pending[:blockSize])
+	}
+
+	for len(newReader.pending)-position >= blockSize {
+		block := newReader.pending[position : position+blockSize]
+
+		isMatched := false
+		oldOffset, ok := blocks[hash]
+		if ok {
+			if _, err := oldContent.ReadAt(oldBlock, oldOffset); err != nil {
+				return fmt.Errorf("failed to read old file\n\t%w", err)
+			}
+
+			isMatched = bytes.Equal(oldBlock, block)
+		}
+
+		if isMatched {
+			start, oldStart, err := extendBackward(oldContent, oldOffset, newReader.pending, position)
+			if err != nil {
+				return err
+			}
+
+			if err := writeInsert(w, newReader.pending[:start]); err != nil {
+				return err
+			}
+
+			newReader.consume(position + blockSize)
+
+			forwardLength, err := extendForward(oldContent, oldSize, oldOffset+blockSize, newReader)
+			if err != nil {
+				return err
+			}
+
+			length := (oldOffset - oldStart) + blockSize + forwardLength
+			if err := writeCopy(w, oldStart, length); err != nil {
+				return err
+			}
+
+			position = 0
+			if err := newReader.fill(blockSize); err != nil {
+				return err
+			}
+
+			if len(newReader.pending) >= blockSize {
+				hash = hashBlock(newReader.pending[:blockSize])
+			}
+
+			continue
+		}
+
+		if err := newReader.fill(position + blockSize + 1); err != nil {
+			return err
+		}
+
+		if position+blockSize < len(newReader.pending) {
+			hash = (hash-uint64(newReader.pending[position])*highestPower)*hashBase +
+				uint64(newReader.pending[position+blockSize])
+		}
+		position++
+
+		// Do not keep too many unmatched bytes in memory.
+		if position >= maxInsertSize {
+			if err := writeInsert(w, newReader.pending[:position]); err != nil {
+				return err
+			}
+
+			newReader.consume(position)
+			position = 0
+		}
+	}
+
+	return writeInsert(w, newReader.pending)
+}
+
+// maxInsertSize is the most unmatched bytes of the new file kept in memory before they are
+// written as an insert instruction.
+const maxInsertSize = 1 << 20
+
+// indexBlocks indexes the blocks of the old file by their hashes. The first block with a hash
+// wins.
+func indexBlocks(oldContent io.ReaderAt, oldSize int64) (map[uint64]int64, error) {
+	blocks := make(map[uint64]int64, oldSize/blockSize)
+
+	reader := bufio.NewReader(io.NewSectionReader(oldContent, 0, oldSize))
+	block := make([]byte, blockSize)
+
+	for offset := int64(0); offset+blockSize <= oldSize; offset += blockSize {
+		if _, err := io.ReadFull(reader, block); err != nil {
+			return nil, fmt.Errorf("failed to read old file\n\t%w", err)
+		}
+
+		hash := hashBlock(block)
+		if _, ok := blocks[hash]; !ok {
+			blocks[hash] = offset
+		}
+	}
+
+	return blocks, nil
+}
+
+// extendBackward extends a match of the block of the new file at position in the pending bytes
+// and the block of the old file at oldOffset backward into the pending bytes before it. It
+// returns where the match starts in the pending bytes and in the old file.
+func extendBackward(oldContent io.ReaderAt, oldOffset int64, pending []byte, position int) (int, int64, error) {
+	length := int64(position)
+	if oldOffset < length {
+		length = oldOffset
+	}
+
+	before := make([]byte, length)
+	if _, err := oldContent.ReadAt(before, oldOffset-length); err != nil {
+		return 0, 0, fmt.Errorf("failed to read old file\n\t%w", err)
+	}
+
+	start := position
+	for start > position-int(length) && before[int(length)-(position-start)-1] == pending[start-1] {
+		start--
+	}
+
+	return start, oldOffset - int64(position-start), nil
+}
+
+// extendForward extends a match forward from oldOffset in the old file and the start of the
+// pending bytes, consuming the matched bytes of the new file. It returns the length matched.
+func extendForward(oldContent io.ReaderAt, oldSize int64, oldOffset int64, newReader *pendingReader) (int64, error) {
+	chunk := make([]byte, readSize)
+
+	var length int64
+	for oldOffset+length < oldSize {
+		chunkLength := int64(len(chunk))
+		if oldSize-oldOffset-length < chunkLength {
+			chunkLength = oldSize - oldOffset - length
+		}
+
+		if _, err := oldContent.ReadAt(chunk[:chunkLength], oldOffset+length); err != nil {
+			return 0, fmt.Errorf("failed to read old file\n\t%w", err)
+		}
+
+		if err := newReader.fill(int(chunkLength)); err != nil {
+			return 0, err
+		}
+
+		matched := 0
+		for matched < int(chunkLength) && matched < len(newReader.pending) &&
+			chunk[matched] == newReader.pending[matched] {
+			matched++
+		}
+
+		newReader.consume(matched)
+		length += int64(matched)
+
+		if matched < int(chunkLength) {
+			break
+		}
+	}
+
+	return length, nil
+}
+
+// pendingReader reads the new file, keeping the bytes not covered by instructions yet.
+type pendingReader struct {
+	reader  io.Reader
+	pending []byte
+	isEOF   bool
+}
+
+// readSize is how many bytes of the new file are read at once.
+const readSize = 32 * 1024
+
+func newPendingReader(r io.Reader) *pendingReader {
+	return &pendingReader{
+		reader:  r,
+		pending: make([]byte, 0, 2*readSize),
+	}
+}
+
+// fill reads the new file until there are at least n pending bytes, or the new file ends.
+func (r *pendingReader) fill(n int) error {
+	for len(r.pending) < n && !r.isEOF {
+		if cap(r.pending)-len(r.pending) < readSize {
+			// Move the pending bytes to a new buffer, leaving the consumed ones behind.
+			pending := make([]byte, len(r.pending), 2*len(r.pending)+readSize)
+			copy(pending, r.pending)
+			r.pending = pending
+		}
+
+		read, err := r.reader.Read(r.pending[len(r.pending):cap(r.pending)])
+		r.pending = r.pending[:len(r.pending)+read]
+
+		if err == io.EOF {
+			r.isEOF = true
+		} else if err != nil {
+			return fmt.Errorf("failed to read new file\n\t%w", err)
+		}
+	}
+
+	return nil
+}
+
+// consume drops the first n pending bytes.
+func (r *pendingReader) consume(n int) {
+	r.pending = r.pending[n:]
+}
+
+// hashBlock returns the polynomial hash of a block.
+func hashBlock(block []byte) uint64 {
+	var hash uint64
+	for _, b := range block {
+		hash = hash*hashBase + uint64(b)
+	}
+
+	return hash
+}
+
+// writeCopy writes an instruction copying a range of the old file.
+func writeCopy(w *bufio.Writer, offset int64, length int64) error {
+	buf := make([]byte, 0, 1+2*binary.MaxVarintLen64)
+	buf = append(buf, opCopy)
+	buf = binary.AppendUvarint(buf, uint64(length))
+	buf = binary.AppendUvarint(buf, uint64(offset))
+
+	if _, err := w.Write(buf); err != nil {
+		return fmt.Errorf("failed to write patch\n\t%w", err)
+	}
+
+	return nil
+}
+
+// writeInsert writes an instruction inserting literal bytes, if there are any.
+func writeInsert(w *bufio.Writer, literal []byte) error {
+	if len(literal) == 0 {
+		return nil
+	}
+
+	buf := make([]byte, 0, 1+binary.MaxVarintLen64)
+	buf = append(buf, opInsert)
+	buf = binary.AppendUvarint(buf, uint64(len(literal)))
+
+	if _, err := w.Write(buf); err != nil {
+		return fmt.Errorf("failed to write patch\n\t%w", err)
+	}
+
+	if _, err := w.Write(literal); err != nil {
+		return fmt.Errorf("failed to write patch\n\t%w", err)
+	}
+
+	return nil
+}
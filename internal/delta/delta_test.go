@@ -0,0 +1,146 @@
+package delta
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/lippkg/lip/internal/errcode"
+)
+
+func TestDiffAndApply(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	base := randomBytes(random, 1<<20)
+
+	tests := []struct {
+		name       string
+		oldContent []byte
+		newContent []byte
+		// maxSize is the most bytes the patch may take, or 0 if any size will do.
+		maxSize int
+	}{
+		{"identical", base, base, 1024},
+		{"changed byte", base, replace(base, 5000, []byte{^base[5000]}), 1024},
+		{"inserted bytes", base, concat(base[:300000], []byte("inserted"), base[300000:]), 1024},
+		{"removed range", base, concat(base[:300000], base[400000:]), 1024},
+		{"moved blocks", base, concat(base[500000:], base[:500000]), 1024},
+		{"appended", base, concat(base, randomBytes(random, 1000)), 2048},
+		{"empty old", nil, base, 0},
+		{"empty new", base, nil, 1024},
+		{"both empty", nil, nil, 1024},
+		{"shorter than a block", base[:blockSize/2], base[:blockSize-1], 1024},
+		// Unmatched bytes beyond maxInsertSize are written in several insert instructions.
+		{"unrelated", base, randomBytes(random, 3*maxInsertSize), 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			oldContent, newContent := test.oldContent, test.newContent
+
+			patch := diff(t, oldContent, newContent)
+
+			if test.maxSize != 0 && len(patch) > test.maxSize {
+				t.Errorf("patch is %v bytes, want at most %v", len(patch), test.maxSize)
+			}
+
+			var output bytes.Buffer
+			if err := Apply(bytes.NewReader(oldContent), int64(len(oldContent)), bytes.NewReader(patch),
+				&output); err != nil {
+				t.Fatalf("Apply failed: %v", err)
+			}
+
+			if !bytes.Equal(output.Bytes(), newContent) {
+				t.Errorf("Apply made %v bytes differing from the new file of %v bytes", output.Len(),
+					len(newContent))
+			}
+		})
+	}
+}
+
+func TestApplyRejectsTruncatedPatch(t *testing.T) {
+	random := rand.New(rand.NewSource(2))
+	oldContent := randomBytes(random, 64*1024)
+	newContent := concat(oldContent[:10000], randomBytes(random, 5000), oldContent[20000:])
+
+	patch := diff(t, oldContent, newContent)
+
+	for length := 0; length < len(patch); length++ {
+		err := Apply(bytes.NewReader(oldContent), int64(len(oldContent)), bytes.NewReader(patch[:length]),
+			&bytes.Buffer{})
+		if err == nil {
+			t.Fatalf("Apply succeeded with the patch truncated to %v of %v bytes", length, len(patch))
+		}
+	}
+}
+
+func TestApplyRejectsCorruptPatch(t *testing.T) {
+	random := rand.New(rand.NewSource(3))
+	oldContent := randomBytes(random, 64*1024)
+	newContent := concat(oldContent[:10000], randomBytes(random, 5000), oldContent[20000:])
+
+	patch := diff(t, oldContent, newContent)
+
+	for i := range patch {
+		corruptPatch := replace(patch, i, []byte{^patch[i]})
+
+		var output bytes.Buffer
+		err := Apply(bytes.NewReader(oldContent), int64(len(oldContent)), bytes.NewReader(corruptPatch), &output)
+		if err == nil {
+			t.Fatalf("Apply succeeded with byte %v of the patch corrupted", i)
+		}
+	}
+}
+
+func TestApplyRejectsOtherOldFile(t *testing.T) {
+	random := rand.New(rand.NewSource(4))
+	oldContent := randomBytes(random, 64*1024)
+	newContent := concat(oldContent, []byte("appended"))
+
+	patch := diff(t, oldContent, newContent)
+
+	otherContent := replace(oldContent, 100, []byte{^oldContent[100]})
+
+	err := Apply(bytes.NewReader(otherContent), int64(len(otherContent)), bytes.NewReader(patch), &bytes.Buffer{})
+	if code, _ := errcode.GetCode(err); code != errcode.ChecksumMismatch {
+		t.Errorf("Apply to another old file returned %v, want a checksum mismatch", err)
+	}
+}
+
+// ---------------------------------------------------------------------
+
+// diff makes the patch transforming oldContent into newContent.
+func diff(t *testing.T, oldContent []byte, newContent []byte) []byte {
+	t.Helper()
+
+	var patch bytes.Buffer
+	if err := Diff(bytes.NewReader(oldContent), int64(len(oldContent)), bytes.NewReader(newContent),
+		&patch); err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+
+	return patch.Bytes()
+}
+
+func randomBytes(random *rand.Rand, n int) []byte {
+	b := make([]byte, n)
+	random.Read(b)
+
+	return b
+}
+
+func concat(parts ...[]byte) []byte {
+	var b []byte
+	for _, part := range parts {
+		b = append(b, part...)
+	}
+
+	return b
+}
+
+// replace returns a copy of b with the bytes at offset replaced.
+func replace(b []byte, offset int, replacement []byte) []byte {
+	replaced := concat(b)
+	copy(replaced[offset:], replacement)
+
+	return replaced
+}
@@ -0,0 +1,41 @@
+package diskspace
+
+import (
+	"fmt"
+
+	"github.com/lippkg/lip/internal/errcode"
+)
+
+// Check fails if the file system of a directory has less free space available than required.
+// It passes if the operating system does not tell the free space.
+func Check(dir string, required int64) error {
+	available, ok, err := Available(dir)
+	if err != nil {
+		return fmt.Errorf("failed to get free space of %v\n\t%w", dir, err)
+	}
+
+	if ok && required > available {
+		return errcode.Errorf(errcode.InsufficientDiskSpace, "not enough disk space in %v: %v required, %v available",
+			dir, FormatSize(required), FormatSize(available))
+	}
+
+	return nil
+}
+
+// FormatSize returns a size in bytes as a string with a binary unit.
+func FormatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%v B", size)
+	}
+
+	value := float64(size) / unit
+	for _, suffix := range []string{"KiB", "MiB", "GiB"} {
+		if value < unit {
+			return fmt.Sprintf("%.1f %v", value, suffix)
+		}
+		value /= unit
+	}
+
+	return fmt.Sprintf("%.1f TiB", value)
+}
@@ -0,0 +1,18 @@
+//go:build darwin || linux
+
+package diskspace
+
+import (
+	"syscall"
+)
+
+// Available returns the free space in bytes available to the user on the file system of a
+// directory. The second return value is false if the operating system does not tell it.
+func Available(dir string) (int64, bool, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, false, err
+	}
+
+	return int64(stat.Bavail) * int64(stat.Bsize), true, nil
+}
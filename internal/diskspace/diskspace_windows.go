@@ -0,0 +1,21 @@
+package diskspace
+
+import (
+	"golang.org/x/sys/windows"
+)
+
+// Available returns the free space in bytes available to the user on the volume of a
+// directory. The second return value is false if the operating system does not tell it.
+func Available(dir string) (int64, bool, error) {
+	dirPtr, err := windows.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, false, err
+	}
+
+	var freeBytesAvailable uint64
+	if err := windows.GetDiskFreeSpaceEx(dirPtr, &freeBytesAvailable, nil, nil); err != nil {
+		return 0, false, err
+	}
+
+	return int64(freeBytesAvailable), true, nil
+}
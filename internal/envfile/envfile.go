@@ -0,0 +1,146 @@
+package envfile
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/lippkg/lip/internal/context"
+)
+
+// variableNamePattern matches the name of a variable, e.g. SERVER_PORT.
+var variableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Load returns the variables of the env file of the workspace. It returns no variables if the
+// file does not exist.
+func Load(ctx *context.Context) (map[string]string, error) {
+	envFilePath, err := ctx.EnvFilePath()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get env file path\n\t%w", err)
+	}
+
+	data, err := ctx.FS().ReadFile(envFilePath.LocalString())
+	if os.IsNotExist(err) {
+		return make(map[string]string), nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read env file %v\n\t%w", envFilePath.LocalString(), err)
+	}
+
+	variables, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse env file %v\n\t%w", envFilePath.LocalString(), err)
+	}
+
+	return variables, nil
+}
+
+// Parse parses an env file, with a NAME=value variable on each line. Empty lines and lines
+// starting with # are skipped, a leading "export " is allowed as in shell scripts, and values
+// may be quoted with double or single quotes, e.g. to keep surrounding spaces or a #. Within
+// double quotes, \n, \", \\ and \$ are escapes. Later variables override earlier ones of the
+// same name.
+func Parse(data []byte) (map[string]string, error) {
+	variables := make(map[string]string)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "export ")
+
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %v: expected NAME=value", lineNumber)
+		}
+
+		name = strings.TrimSpace(name)
+		if !variableNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("line %v: invalid variable name %q", lineNumber, name)
+		}
+
+		value, err := parseValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("line %v: %w", lineNumber, err)
+		}
+
+		variables[name] = value
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return variables, nil
+}
+
+// ---------------------------------------------------------------------
+
+// parseValue parses the value of a variable, unquoting it if quoted. Unquoted values end at a
+// # preceded by a space, which starts a comment.
+func parseValue(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, `"`):
+		return parseDoubleQuoted(value)
+
+	case strings.HasPrefix(value, "'"):
+		end := strings.Index(value[1:], "'")
+		if end == -1 {
+			return "", fmt.Errorf("unterminated single quote")
+		}
+
+		if rest := strings.TrimSpace(value[end+2:]); rest != "" && !strings.HasPrefix(rest, "#") {
+			return "", fmt.Errorf("unexpected %q after closing quote", rest)
+		}
+
+		return value[1 : end+1], nil
+	}
+
+	if i := strings.Index(value, " #"); i != -1 {
+		value = strings.TrimSpace(value[:i])
+	}
+
+	return value, nil
+}
+
+// parseDoubleQuoted parses a value quoted with double quotes.
+func parseDoubleQuoted(value string) (string, error) {
+	var builder strings.Builder
+
+	for i := 1; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '\\':
+			if i+1 == len(value) {
+				return "", fmt.Errorf("unterminated double quote")
+			}
+
+			i++
+			switch escaped := value[i]; escaped {
+			case 'n':
+				builder.WriteByte('\n')
+			case '"', '\\', '$':
+				builder.WriteByte(escaped)
+			default:
+				builder.WriteByte('\\')
+				builder.WriteByte(escaped)
+			}
+
+		case '"':
+			if rest := strings.TrimSpace(value[i+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+				return "", fmt.Errorf("unexpected %q after closing quote", rest)
+			}
+
+			return builder.String(), nil
+
+		default:
+			builder.WriteByte(c)
+		}
+	}
+
+	return "", fmt.Errorf("unterminated double quote")
+}
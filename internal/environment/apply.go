@@ -0,0 +1,94 @@
+//go:build !windows
+
+package environment
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Apply persists the environment for new shells by adding a snippet to the profile of the
+// shell, and returns where it was applied. The snippet of the workspace replaces the one
+// added before.
+func Apply(env Environment, shell Shell) (string, error) {
+	profilePath, err := getProfilePath(shell)
+	if err != nil {
+		return "", err
+	}
+
+	snippet, err := Format(env, shell)
+	if err != nil {
+		return "", err
+	}
+
+	workspaceDir, err := getWorkspaceDir()
+	if err != nil {
+		return "", err
+	}
+
+	beginMarker := fmt.Sprintf("# >>> lip environment of %v >>>\n", workspaceDir.LocalString())
+	endMarker := fmt.Sprintf("# <<< lip environment of %v <<<\n", workspaceDir.LocalString())
+
+	content, err := os.ReadFile(profilePath)
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to read shell profile %v\n\t%w", profilePath, err)
+	}
+
+	profile := string(content)
+
+	// Remove the snippet added before.
+	if begin := strings.Index(profile, beginMarker); begin != -1 {
+		if end := strings.Index(profile[begin:], endMarker); end != -1 {
+			profile = profile[:begin] + profile[begin+end+len(endMarker):]
+		}
+	}
+
+	if !env.IsEmpty() {
+		if profile != "" && !strings.HasSuffix(profile, "\n") {
+			profile += "\n"
+		}
+
+		profile += beginMarker + snippet + endMarker
+	}
+
+	if err := os.MkdirAll(filepath.Dir(profilePath), 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory of shell profile %v\n\t%w", profilePath, err)
+	}
+
+	if err := os.WriteFile(profilePath, []byte(profile), 0644); err != nil {
+		return "", fmt.Errorf("failed to write shell profile %v\n\t%w", profilePath, err)
+	}
+
+	return profilePath, nil
+}
+
+// ---------------------------------------------------------------------
+
+// getProfilePath returns the path to the profile of a shell, which is run when the shell
+// starts.
+func getProfilePath(shell Shell) (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory\n\t%w", err)
+	}
+
+	switch shell {
+	case ShShell:
+		switch filepath.Base(os.Getenv("SHELL")) {
+		case "bash":
+			return filepath.Join(homeDir, ".bashrc"), nil
+		case "zsh":
+			return filepath.Join(homeDir, ".zshrc"), nil
+		default:
+			return filepath.Join(homeDir, ".profile"), nil
+		}
+
+	case FishShell:
+		return filepath.Join(homeDir, ".config", "fish", "config.fish"), nil
+
+	default:
+		return "", fmt.Errorf("applying environment is not supported for shell %v", shell)
+	}
+}
@@ -0,0 +1,85 @@
+package environment
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+// Apply persists the environment for new processes by setting the user environment variables
+// in the registry, and returns where it was applied. Directories already in the user PATH are
+// not added again. The shell is ignored, since the variables apply to all shells.
+func Apply(env Environment, shell Shell) (string, error) {
+	key, err := registry.OpenKey(registry.CURRENT_USER, "Environment", registry.QUERY_VALUE|registry.SET_VALUE)
+	if err != nil {
+		return "", fmt.Errorf("failed to open user environment registry key\n\t%w", err)
+	}
+	defer key.Close()
+
+	if len(env.Path) != 0 {
+		userPath, _, err := key.GetStringValue("Path")
+		if err != nil && err != registry.ErrNotExist {
+			return "", fmt.Errorf("failed to read user PATH\n\t%w", err)
+		}
+
+		userPathItems := make([]string, 0)
+		if userPath != "" {
+			userPathItems = strings.Split(userPath, string(os.PathListSeparator))
+		}
+
+		for _, dir := range env.Path {
+			if !containsFold(userPathItems, dir) {
+				userPathItems = append([]string{dir}, userPathItems...)
+			}
+		}
+
+		if err := key.SetExpandStringValue("Path", strings.Join(userPathItems, string(os.PathListSeparator))); err != nil {
+			return "", fmt.Errorf("failed to write user PATH\n\t%w", err)
+		}
+	}
+
+	for name, value := range env.Variables {
+		if err := key.SetStringValue(name, value); err != nil {
+			return "", fmt.Errorf("failed to write user environment variable %v\n\t%w", name, err)
+		}
+	}
+
+	broadcastEnvironmentChange()
+
+	return `HKEY_CURRENT_USER\Environment`, nil
+}
+
+// ---------------------------------------------------------------------
+
+// broadcastEnvironmentChange notifies running programs, e.g. Explorer, that the environment
+// has changed, so that programs started by them get the new environment. Failures only mean
+// that the user has to sign in again, so they are ignored.
+func broadcastEnvironmentChange() {
+	const hwndBroadcast = 0xffff
+	const wmSettingChange = 0x001a
+	const smtoAbortIfHung = 0x0002
+
+	environment, err := windows.UTF16PtrFromString("Environment")
+	if err != nil {
+		return
+	}
+
+	sendMessageTimeout := windows.NewLazySystemDLL("user32.dll").NewProc("SendMessageTimeoutW")
+	sendMessageTimeout.Call(hwndBroadcast, wmSettingChange, 0, uintptr(unsafe.Pointer(environment)),
+		smtoAbortIfHung, 5000, 0)
+}
+
+// containsFold returns whether a list contains a string, ignoring case.
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+
+	return false
+}
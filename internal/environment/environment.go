@@ -0,0 +1,195 @@
+package environment
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/path"
+	"github.com/lippkg/lip/internal/tooth"
+)
+
+// workspacePlaceholder is replaced with the workspace directory in variable values.
+const workspacePlaceholder = "${workspace}"
+
+// Shell is a shell to format environment snippets for.
+type Shell string
+
+const (
+	ShShell         Shell = "sh"
+	FishShell       Shell = "fish"
+	PowerShellShell Shell = "powershell"
+	CmdShell        Shell = "cmd"
+)
+
+// Environment is the environment required by the installed teeth of a workspace.
+type Environment struct {
+	// Path is the absolute directories to add to PATH.
+	Path []string `json:"path"`
+	// Variables is the environment variables to set.
+	Variables map[string]string `json:"variables"`
+}
+
+// IsEmpty returns whether nothing is required.
+func (env Environment) IsEmpty() bool {
+	return len(env.Path) == 0 && len(env.Variables) == 0
+}
+
+// Get collects the environment required by the installed teeth of the workspace. If several
+// teeth set a variable to different values, the one of the tooth sorted last wins.
+func Get(ctx *context.Context) (Environment, error) {
+	metadataList, err := tooth.GetAllMetadata(ctx)
+	if err != nil {
+		return Environment{}, fmt.Errorf("failed to list all installed teeth\n\t%w", err)
+	}
+
+	sort.Slice(metadataList, func(i, j int) bool {
+		return metadataList[i].ToothRepoPath() < metadataList[j].ToothRepoPath()
+	})
+
+	workspaceDir, err := getWorkspaceDir()
+	if err != nil {
+		return Environment{}, err
+	}
+
+	env := Environment{
+		Path:      make([]string, 0),
+		Variables: make(map[string]string),
+	}
+
+	pathSet := make(map[string]bool)
+	variableOwners := make(map[string]string)
+
+	for _, metadata := range metadataList {
+		toothEnv, err := metadata.Environment()
+		if err != nil {
+			return Environment{}, fmt.Errorf("failed to get environment of %v\n\t%w", metadata.ToothRepoPath(), err)
+		}
+
+		for _, dir := range toothEnv.Path {
+			dirString := workspaceDir.Join(dir).LocalString()
+
+			if pathSet[dirString] {
+				continue
+			}
+
+			pathSet[dirString] = true
+			env.Path = append(env.Path, dirString)
+		}
+
+		for name, value := range toothEnv.Variables {
+			value = strings.ReplaceAll(value, workspacePlaceholder, workspaceDir.LocalString())
+
+			if owner, ok := variableOwners[name]; ok && env.Variables[name] != value {
+				ctx.Logger().Warnf(i18n.T("Environment variable %v is set by both %v and %v, using the value of %v"),
+					name, owner, metadata.ToothRepoPath(), metadata.ToothRepoPath())
+			}
+
+			env.Variables[name] = value
+			variableOwners[name] = metadata.ToothRepoPath()
+		}
+	}
+
+	return env, nil
+}
+
+// DefaultShell returns the shell to format environment snippets for when none is specified.
+func DefaultShell() Shell {
+	if runtime.GOOS == "windows" {
+		return PowerShellShell
+	}
+
+	shell := os.Getenv("SHELL")
+	if strings.HasSuffix(shell, "/fish") {
+		return FishShell
+	}
+
+	return ShShell
+}
+
+// Format formats the environment as a snippet to run in a shell.
+func Format(env Environment, shell Shell) (string, error) {
+	builder := &strings.Builder{}
+
+	names := make([]string, 0, len(env.Variables))
+	for name := range env.Variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	switch shell {
+	case ShShell:
+		for _, dir := range env.Path {
+			fmt.Fprintf(builder, "export PATH=%v:\"$PATH\"\n", quoteSh(dir))
+		}
+		for _, name := range names {
+			fmt.Fprintf(builder, "export %v=%v\n", name, quoteSh(env.Variables[name]))
+		}
+
+	case FishShell:
+		for _, dir := range env.Path {
+			fmt.Fprintf(builder, "set -gx PATH %v $PATH\n", quoteFish(dir))
+		}
+		for _, name := range names {
+			fmt.Fprintf(builder, "set -gx %v %v\n", name, quoteFish(env.Variables[name]))
+		}
+
+	case PowerShellShell:
+		for _, dir := range env.Path {
+			fmt.Fprintf(builder, "$env:Path = %v + [IO.Path]::PathSeparator + $env:Path\n", quotePowerShell(dir))
+		}
+		for _, name := range names {
+			fmt.Fprintf(builder, "$env:%v = %v\n", name, quotePowerShell(env.Variables[name]))
+		}
+
+	case CmdShell:
+		for _, dir := range env.Path {
+			fmt.Fprintf(builder, "set \"PATH=%v;%%PATH%%\"\n", dir)
+		}
+		for _, name := range names {
+			fmt.Fprintf(builder, "set \"%v=%v\"\n", name, env.Variables[name])
+		}
+
+	default:
+		return "", fmt.Errorf("unsupported shell %v", shell)
+	}
+
+	return builder.String(), nil
+}
+
+// ---------------------------------------------------------------------
+
+// getWorkspaceDir returns the workspace directory.
+func getWorkspaceDir() (path.Path, error) {
+	workspaceDirStr, err := os.Getwd()
+	if err != nil {
+		return path.Path{}, fmt.Errorf("failed to get workspace directory\n\t%w", err)
+	}
+
+	workspaceDir, err := path.Parse(workspaceDirStr)
+	if err != nil {
+		return path.Path{}, fmt.Errorf("failed to parse workspace directory\n\t%w", err)
+	}
+
+	return workspaceDir, nil
+}
+
+// quoteFish quotes a string for fish.
+func quoteFish(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+}
+
+// quotePowerShell quotes a string for PowerShell.
+func quotePowerShell(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// quoteSh quotes a string for POSIX shells.
+func quoteSh(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,319 @@
+package envsnapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/manifest"
+	"github.com/lippkg/lip/internal/path"
+	"github.com/lippkg/lip/internal/record"
+	"github.com/lippkg/lip/internal/tooth"
+)
+
+const expectedFormatVersion = 1
+
+// nameRegexp matches valid snapshot names, which are used as file names.
+var nameRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Snapshot is the installed state of a workspace at a point in time: the installed versions of
+// the teeth, their installation records and the files they placed.
+type Snapshot struct {
+	FormatVersion int       `json:"format_version"`
+	Name          string    `json:"name"`
+	CreatedAt     time.Time `json:"created_at"`
+	LipVersion    string    `json:"lip_version"`
+	Teeth         []Tooth   `json:"teeth"`
+}
+
+// Tooth is an installed tooth in a snapshot.
+type Tooth struct {
+	ToothRepoPath string `json:"tooth"`
+	Version       string `json:"version"`
+	IsExplicit    bool   `json:"is_explicit"`
+
+	// Override is the version the tooth is forced to by an override, or empty if it is not
+	// overridden.
+	Override string `json:"override,omitempty"`
+
+	// Files are the files placed by the tooth with their checksums. Empty if no manifest was
+	// recorded, e.g. for teeth installed by older versions of lip.
+	Files []manifest.File `json:"files,omitempty"`
+}
+
+// ChangeKind is the kind of a change of a tooth between two snapshots.
+type ChangeKind string
+
+const (
+	AddedChange         ChangeKind = "added"
+	RemovedChange       ChangeKind = "removed"
+	UpgradedChange      ChangeKind = "upgraded"
+	DowngradedChange    ChangeKind = "downgraded"
+	FilesChangedChange  ChangeKind = "files changed"
+	RecordChangedChange ChangeKind = "record changed"
+)
+
+// Change is a change of a tooth between two snapshots.
+type Change struct {
+	Kind          ChangeKind
+	ToothRepoPath string
+
+	// From and To are the teeth in the two snapshots. From is nil for added teeth, and To
+	// is nil for removed teeth.
+	From *Tooth
+	To   *Tooth
+}
+
+// Capture captures the current installed state of the workspace as a snapshot.
+func Capture(ctx *context.Context, name string) (Snapshot, error) {
+	metadataList, err := tooth.GetAllMetadata(ctx)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("failed to list all installed teeth\n\t%w", err)
+	}
+
+	teeth := make([]Tooth, 0, len(metadataList))
+	for _, metadata := range metadataList {
+		currentRecord, err := record.Get(ctx, metadata.ToothRepoPath())
+		if err != nil {
+			return Snapshot{}, fmt.Errorf("failed to get record of tooth %v\n\t%w", metadata.ToothRepoPath(), err)
+		}
+
+		currentManifest, _, err := manifest.Get(ctx, metadata.ToothRepoPath())
+		if err != nil {
+			return Snapshot{}, fmt.Errorf("failed to get manifest of tooth %v\n\t%w", metadata.ToothRepoPath(), err)
+		}
+
+		teeth = append(teeth, Tooth{
+			ToothRepoPath: metadata.ToothRepoPath(),
+			Version:       metadata.Version().String(),
+			IsExplicit:    currentRecord.IsExplicit,
+			Override:      currentRecord.Override,
+			Files:         currentManifest.Files,
+		})
+	}
+
+	sort.Slice(teeth, func(i, j int) bool {
+		return teeth[i].ToothRepoPath < teeth[j].ToothRepoPath
+	})
+
+	return Snapshot{
+		FormatVersion: expectedFormatVersion,
+		Name:          name,
+		CreatedAt:     ctx.Clock().Now().UTC(),
+		LipVersion:    ctx.LipVersion().String(),
+		Teeth:         teeth,
+	}, nil
+}
+
+// Diff returns the changes of the teeth from one snapshot to another, sorted by tooth
+// repository path.
+func Diff(from Snapshot, to Snapshot) ([]Change, error) {
+	fromTeeth := make(map[string]*Tooth)
+	for i := range from.Teeth {
+		fromTeeth[from.Teeth[i].ToothRepoPath] = &from.Teeth[i]
+	}
+
+	toTeeth := make(map[string]*Tooth)
+	for i := range to.Teeth {
+		toTeeth[to.Teeth[i].ToothRepoPath] = &to.Teeth[i]
+	}
+
+	changes := make([]Change, 0)
+
+	for toothRepoPath, fromTooth := range fromTeeth {
+		if _, ok := toTeeth[toothRepoPath]; !ok {
+			changes = append(changes, Change{Kind: RemovedChange, ToothRepoPath: toothRepoPath, From: fromTooth})
+		}
+	}
+
+	for toothRepoPath, toTooth := range toTeeth {
+		fromTooth, ok := fromTeeth[toothRepoPath]
+		if !ok {
+			changes = append(changes, Change{Kind: AddedChange, ToothRepoPath: toothRepoPath, To: toTooth})
+			continue
+		}
+
+		kind, err := compareTeeth(fromTooth, toTooth)
+		if err != nil {
+			return nil, err
+		}
+
+		if kind != "" {
+			changes = append(changes, Change{Kind: kind, ToothRepoPath: toothRepoPath, From: fromTooth, To: toTooth})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].ToothRepoPath < changes[j].ToothRepoPath
+	})
+
+	return changes, nil
+}
+
+// IsValidName returns whether a snapshot name is valid.
+func IsValidName(name string) bool {
+	return nameRegexp.MatchString(name)
+}
+
+// List returns the names of the saved snapshots, sorted by name.
+func List(ctx *context.Context) ([]string, error) {
+	snapshotDir, err := ctx.EnvironmentSnapshotDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get environment snapshot directory\n\t%w", err)
+	}
+
+	entries, err := ctx.FS().ReadDir(snapshotDir.LocalString())
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read environment snapshot directory %v\n\t%w", snapshotDir.LocalString(),
+			err)
+	}
+
+	names := make([]string, 0)
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".json")
+		if entry.IsDir() || name == entry.Name() || !IsValidName(name) {
+			continue
+		}
+
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// Load loads a saved snapshot.
+func Load(ctx *context.Context, name string) (Snapshot, error) {
+	if !IsValidName(name) {
+		return Snapshot{}, errcode.Errorf(errcode.InvalidArgument, "invalid snapshot name %v", name)
+	}
+
+	snapshotPath, err := getSnapshotPath(ctx, name)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	jsonBytes, err := ctx.FS().ReadFile(snapshotPath.LocalString())
+	if os.IsNotExist(err) {
+		return Snapshot{}, errcode.Errorf(errcode.InvalidArgument, "snapshot %v does not exist", name)
+	} else if err != nil {
+		return Snapshot{}, fmt.Errorf("failed to read snapshot %v\n\t%w", snapshotPath.LocalString(), err)
+	}
+
+	var snapshot Snapshot
+	if err := json.Unmarshal(jsonBytes, &snapshot); err != nil {
+		return Snapshot{}, fmt.Errorf("failed to parse snapshot %v\n\t%w", snapshotPath.LocalString(), err)
+	}
+
+	if snapshot.FormatVersion != expectedFormatVersion {
+		return Snapshot{}, errcode.Errorf(errcode.MetadataInvalid, "unsupported format version of snapshot %v: %v",
+			name, snapshot.FormatVersion)
+	}
+
+	return snapshot, nil
+}
+
+// Save saves a snapshot under its name. An existing snapshot with the name is not replaced.
+func Save(ctx *context.Context, snapshot Snapshot) error {
+	if !IsValidName(snapshot.Name) {
+		return errcode.Errorf(errcode.InvalidArgument, "invalid snapshot name %v", snapshot.Name)
+	}
+
+	snapshotPath, err := getSnapshotPath(ctx, snapshot.Name)
+	if err != nil {
+		return err
+	}
+
+	jsonBytes, err := json.MarshalIndent(snapshot, "", "    ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal snapshot\n\t%w", err)
+	}
+
+	file, err := ctx.FS().OpenFile(snapshotPath.LocalString(), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if os.IsExist(err) {
+		return errcode.Errorf(errcode.InvalidArgument, "snapshot %v already exists", snapshot.Name)
+	} else if err != nil {
+		return fmt.Errorf("failed to create snapshot %v\n\t%w", snapshotPath.LocalString(), err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(jsonBytes); err != nil {
+		return fmt.Errorf("failed to write snapshot %v\n\t%w", snapshotPath.LocalString(), err)
+	}
+
+	return nil
+}
+
+// ---------------------------------------------------------------------
+
+// compareTeeth returns the kind of the change from one tooth to another in two snapshots, or
+// an empty kind if they are the same.
+func compareTeeth(from *Tooth, to *Tooth) (ChangeKind, error) {
+	fromVersion, err := semver.Parse(from.Version)
+	if err != nil {
+		return "", errcode.Errorf(errcode.MetadataInvalid, "invalid version %v of %v in snapshot", from.Version,
+			from.ToothRepoPath)
+	}
+
+	toVersion, err := semver.Parse(to.Version)
+	if err != nil {
+		return "", errcode.Errorf(errcode.MetadataInvalid, "invalid version %v of %v in snapshot", to.Version,
+			to.ToothRepoPath)
+	}
+
+	switch {
+	case toVersion.GT(fromVersion):
+		return UpgradedChange, nil
+
+	case toVersion.LT(fromVersion):
+		return DowngradedChange, nil
+
+	case !isSameFiles(from.Files, to.Files):
+		return FilesChangedChange, nil
+
+	case from.IsExplicit != to.IsExplicit || from.Override != to.Override:
+		return RecordChangedChange, nil
+	}
+
+	return "", nil
+}
+
+// getSnapshotPath returns the path of a snapshot file.
+func getSnapshotPath(ctx *context.Context, name string) (path.Path, error) {
+	snapshotDir, err := ctx.EnvironmentSnapshotDir()
+	if err != nil {
+		return path.Path{}, fmt.Errorf("failed to get environment snapshot directory\n\t%w", err)
+	}
+
+	return snapshotDir.Join(path.MustParse(name + ".json")), nil
+}
+
+// isSameFiles returns whether two lists of placed files have the same paths and checksums.
+func isSameFiles(a []manifest.File, b []manifest.File) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	checksums := make(map[string]string, len(a))
+	for _, file := range a {
+		checksums[file.Path] = file.SHA256
+	}
+
+	for _, file := range b {
+		if checksum, ok := checksums[file.Path]; !ok || checksum != file.SHA256 {
+			return false
+		}
+	}
+
+	return true
+}
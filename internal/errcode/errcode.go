@@ -0,0 +1,166 @@
+package errcode
+
+import (
+	"context"
+	"errors"
+
+	"github.com/lippkg/lip/internal/i18n"
+)
+
+// Code is a stable identifier of a kind of error, for scripts to react to.
+type Code string
+
+const (
+	Aborted               Code = "E_ABORTED"
+	AdvisoryMatched       Code = "E_ADVISORY_MATCHED"
+	AmbiguousAlias        Code = "E_AMBIGUOUS_ALIAS"
+	ChecksumMismatch      Code = "E_CHECKSUM_MISMATCH"
+	HealthCheckFailed     Code = "E_HEALTH_CHECK_FAILED"
+	InsufficientDiskSpace Code = "E_INSUFFICIENT_DISK_SPACE"
+	InvalidArgument       Code = "E_INVALID_ARGUMENT"
+	MetadataInvalid       Code = "E_METADATA_INVALID"
+	Network               Code = "E_NETWORK"
+	NotInstalled          Code = "E_NOT_INSTALLED"
+	NotVendored           Code = "E_NOT_VENDORED"
+	Offline               Code = "E_OFFLINE"
+	PartialFailure        Code = "E_PARTIAL_FAILURE"
+	PermissionDenied      Code = "E_PERMISSION_DENIED"
+	PlatformUnsupported   Code = "E_PLATFORM_UNSUPPORTED"
+	PolicyViolation       Code = "E_POLICY_VIOLATION"
+	RateLimited           Code = "E_RATE_LIMITED"
+	ResolveConflict       Code = "E_RESOLVE_CONFLICT"
+	RuntimeIncompatible   Code = "E_RUNTIME_INCOMPATIBLE"
+	ToolMissing           Code = "E_TOOL_MISSING"
+	VerificationFailed    Code = "E_VERIFICATION_FAILED"
+)
+
+// Exit codes of lip, one per category of errors. They are part of the interface for scripts,
+// so existing ones must never change.
+const (
+	// GeneralExitCode is for errors without a code, i.e. unexpected ones.
+	GeneralExitCode = 1
+	// InvalidInputExitCode is for invalid command lines and tooth.json files.
+	InvalidInputExitCode = 2
+	// NetworkExitCode is for network failures, including data not available offline.
+	NetworkExitCode = 3
+	// ResolutionExitCode is for teeth or versions that cannot be found or resolved.
+	ResolutionExitCode = 4
+	// IntegrityExitCode is for files failing checksums or verification.
+	IntegrityExitCode = 5
+	// EnvironmentExitCode is for missing permissions, disk space or tools on the machine.
+	EnvironmentExitCode = 6
+	// PolicyExitCode is for teeth violating the installation policy or affected by security
+	// advisories.
+	PolicyExitCode = 7
+	// HealthCheckExitCode is for installed teeth failing their health checks.
+	HealthCheckExitCode = 8
+	// PartialFailureExitCode is for operations on several teeth where some failed.
+	PartialFailureExitCode = 9
+	// AbortedExitCode is for operations declined or interrupted by the user.
+	AbortedExitCode = 10
+)
+
+// exitCodes maps the codes to the exit codes of their categories.
+var exitCodes = map[Code]int{
+	Aborted:               AbortedExitCode,
+	AdvisoryMatched:       PolicyExitCode,
+	AmbiguousAlias:        ResolutionExitCode,
+	ChecksumMismatch:      IntegrityExitCode,
+	HealthCheckFailed:     HealthCheckExitCode,
+	InsufficientDiskSpace: EnvironmentExitCode,
+	InvalidArgument:       InvalidInputExitCode,
+	MetadataInvalid:       InvalidInputExitCode,
+	Network:               NetworkExitCode,
+	NotInstalled:          ResolutionExitCode,
+	NotVendored:           ResolutionExitCode,
+	Offline:               NetworkExitCode,
+	PartialFailure:        PartialFailureExitCode,
+	PermissionDenied:      EnvironmentExitCode,
+	PlatformUnsupported:   ResolutionExitCode,
+	PolicyViolation:       PolicyExitCode,
+	RateLimited:           NetworkExitCode,
+	ResolveConflict:       ResolutionExitCode,
+	RuntimeIncompatible:   ResolutionExitCode,
+	ToolMissing:           EnvironmentExitCode,
+	VerificationFailed:    IntegrityExitCode,
+}
+
+// Error is an error with a code.
+type Error struct {
+	code Code
+	err  error
+}
+
+// Errorf formats an error with the translated format string and attaches a code to it. Like
+// fmt.Errorf, the %w verb wraps an error.
+func Errorf(code Code, format string, a ...interface{}) error {
+	return &Error{
+		code: code,
+		err:  i18n.Errorf(format, a...),
+	}
+}
+
+// GetCode returns the code of the innermost error with a code in the chain of err, which is
+// the closest to the cause. The second return value is false if no error in the chain has a
+// code.
+func GetCode(err error) (Code, bool) {
+	var code Code
+	isFound := false
+
+	for ; err != nil; err = errors.Unwrap(err) {
+		if codeErr, ok := err.(*Error); ok {
+			code = codeErr.code
+			isFound = true
+		}
+	}
+
+	return code, isFound
+}
+
+// ExitCode returns the exit code of lip for an error, by the category of its code. It is 0 if
+// err is nil. Operations interrupted by canceling their context are aborted, whatever the code.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return AbortedExitCode
+	}
+
+	code, ok := GetCode(err)
+	if !ok {
+		return GeneralExitCode
+	}
+
+	exitCode, ok := exitCodes[code]
+	if !ok {
+		return GeneralExitCode
+	}
+
+	return exitCode
+}
+
+// Wrap attaches a code to an error.
+func Wrap(code Code, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &Error{
+		code: code,
+		err:  err,
+	}
+}
+
+func (e *Error) Code() Code {
+	return e.code
+}
+
+func (e *Error) Error() string {
+	return e.err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,291 @@
+package githubrelease
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/network"
+)
+
+// archiveAssetName is the name of the release asset used as the tooth archive. Without it,
+// the source archive of the release is used.
+const archiveAssetName = "tooth.zip"
+
+// release is a GitHub release as returned by the GitHub API.
+type release struct {
+	TagName    string  `json:"tag_name"`
+	Draft      bool    `json:"draft"`
+	ZipballURL string  `json:"zipball_url"`
+	Assets     []asset `json:"assets"`
+}
+
+// asset is an asset of a GitHub release.
+type asset struct {
+	Name               string `json:"name"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+}
+
+// repository is the GitHub repository a tooth is released from.
+type repository struct {
+	owner string
+	repo  string
+	// tagPrefix is the prefix of the tags of a tooth in a subdirectory, e.g. sub/ for
+	// github.com/owner/repo/sub or github.com/owner/repo//sub, whose versions are tagged
+	// sub/v1.2.3.
+	tagPrefix string
+	// isSourceArchiveUsable is whether the source archive of a release can be the tooth
+	// archive, i.e. the tooth is at the root of the repository or in a subdirectory after a
+	// "//", where lip looks for it in the archive of the whole repository.
+	isSourceArchiveUsable bool
+}
+
+// releaseCache keeps the releases fetched in this run by tooth repo path, so that resolving
+// versions and downloading archives do not list the releases again.
+var releaseCache = make(map[string][]release)
+
+// IsUsed returns whether a tooth is resolved from GitHub Releases, i.e. GitHub Releases is
+// the configured tooth source and the tooth is hosted on GitHub.
+func IsUsed(ctx *context.Context, toothRepoPath string) (bool, error) {
+	toothSource, err := ctx.ToothSource()
+	if err != nil {
+		return false, fmt.Errorf("failed to get tooth source\n\t%w", err)
+	}
+
+	_, err = parseToothRepoPath(toothRepoPath)
+	return toothSource == context.GitHubReleasesToothSource && err == nil, nil
+}
+
+// GetVersionStrings returns the versions of a tooth released on GitHub, in the format of the
+// version list of the Go module proxy, e.g. v1.2.3. Draft releases and tags that are not
+// versions are skipped.
+func GetVersionStrings(ctx *context.Context, toothRepoPath string) ([]string, error) {
+	releases, repository, err := getReleases(ctx, toothRepoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	versionStrings := make([]string, 0, len(releases))
+	for _, r := range releases {
+		version, ok := parseTag(r.TagName, repository.tagPrefix)
+		if !ok {
+			continue
+		}
+
+		versionStrings = append(versionStrings, "v"+version.String())
+	}
+
+	return versionStrings, nil
+}
+
+// GetArchiveURL returns the URL to download the archive of a version of a tooth from, and the
+// headers to send with the request. The archive is the tooth.zip asset of the release, or the
+// source archive of the release if there is no such asset. A tooth in a subdirectory of a
+// repository must release a tooth.zip asset, since the source archive contains the whole
+// repository, unless the subdirectory is after a "//", e.g. github.com/owner/repo//sub.
+func GetArchiveURL(ctx *context.Context, toothRepoPath string, version semver.Version) (*url.URL,
+	http.Header, error) {
+	releases, repository, err := getReleases(ctx, toothRepoPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, r := range releases {
+		releaseVersion, ok := parseTag(r.TagName, repository.tagPrefix)
+		if !ok || releaseVersion.NE(version) {
+			continue
+		}
+
+		for _, a := range r.Assets {
+			if a.Name != archiveAssetName {
+				continue
+			}
+
+			return getDownloadURL(ctx, a.BrowserDownloadURL)
+		}
+
+		if !repository.isSourceArchiveUsable {
+			return nil, nil, errcode.Errorf(errcode.MetadataInvalid,
+				"release %v of %v has no %v asset, which is required for a tooth in a subdirectory",
+				r.TagName, toothRepoPath, archiveAssetName)
+		}
+
+		return getDownloadURL(ctx, r.ZipballURL)
+	}
+
+	return nil, nil, fmt.Errorf("no GitHub release of %v@%v", toothRepoPath, version)
+}
+
+// ---------------------------------------------------------------------
+
+// getAPIHeader returns the headers to send to the GitHub API, with the token if one is
+// configured.
+func getAPIHeader(ctx *context.Context) http.Header {
+	header := make(http.Header)
+	header.Set("Accept", "application/vnd.github+json")
+	header.Set("X-GitHub-Api-Version", "2022-11-28")
+
+	if token := ctx.GitHubToken(); token != "" {
+		header.Set("Authorization", "Bearer "+token)
+	}
+
+	return header
+}
+
+// getDownloadURL returns the URL to download a release file from, rewritten to the GitHub
+// mirror if it is on GitHub, and the headers to send. The token is only sent to GitHub and the
+// GitHub API, never to a mirror.
+func getDownloadURL(ctx *context.Context, urlString string) (*url.URL, http.Header, error) {
+	downloadURL, err := url.Parse(urlString)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse download URL %v\n\t%w", urlString, err)
+	}
+
+	gitHubAPIURL, err := ctx.GitHubAPIURL()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get GitHub API URL\n\t%w", err)
+	}
+
+	gitHubMirrorURL, err := ctx.GitHubMirrorURL()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get GitHub mirror URL\n\t%w", err)
+	}
+
+	if network.IsGitHubDirectDownloadURL(downloadURL) {
+		mirroredURL, err := network.GenerateGitHubMirrorURL(downloadURL, gitHubMirrorURL)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to generate GitHub mirror URL\n\t%w", err)
+		}
+
+		downloadURL = mirroredURL
+	}
+
+	header := make(http.Header)
+	if token := ctx.GitHubToken(); token != "" &&
+		(downloadURL.Host == "github.com" || downloadURL.Host == gitHubAPIURL.Host) {
+		header.Set("Authorization", "Bearer "+token)
+	}
+
+	return downloadURL, header, nil
+}
+
+// getReleases fetches all releases of the repository of a tooth, following the pages of the
+// release list.
+func getReleases(ctx *context.Context, toothRepoPath string) ([]release, repository, error) {
+	repository, err := parseToothRepoPath(toothRepoPath)
+	if err != nil {
+		return nil, repository, err
+	}
+
+	gitHubAPIURL, err := ctx.GitHubAPIURL()
+	if err != nil {
+		return nil, repository, fmt.Errorf("failed to get GitHub API URL\n\t%w", err)
+	}
+
+	proxyURL, err := ctx.ProxyURL()
+	if err != nil {
+		return nil, repository, fmt.Errorf("failed to get proxy URL\n\t%w", err)
+	}
+
+	cacheDir, err := ctx.CacheDir()
+	if err != nil {
+		return nil, repository, fmt.Errorf("failed to get cache directory\n\t%w", err)
+	}
+
+	if releases, ok := releaseCache[toothRepoPath]; ok {
+		return releases, repository, nil
+	}
+
+	header := getAPIHeader(ctx)
+
+	releases := make([]release, 0)
+	for page := 1; ; page++ {
+		pageURL, err := network.GenerateGitHubReleaseListURL(repository.owner, repository.repo, page, gitHubAPIURL)
+		if err != nil {
+			return nil, repository, fmt.Errorf("failed to generate GitHub release list URL\n\t%w", err)
+		}
+
+		var content []byte
+		if ctx.Offline() {
+			content, err = ctx.Network().GetCachedContent(pageURL, cacheDir)
+		} else {
+			content, err = ctx.Network().GetContentWithRevalidation(ctx.GoContext(), pageURL, proxyURL, header,
+				ctx.RetryPolicy(), cacheDir)
+		}
+		if err != nil {
+			if network.IsRateLimited(err) && ctx.GitHubToken() == "" && !ctx.Network().HasCredential(gitHubAPIURL.Host) {
+				ctx.Logger().Warn(i18n.T("The GitHub API rate limit is exceeded. Set GitHubToken or GITHUB_TOKEN, or run lip login, to raise the limit."))
+			}
+
+			return nil, repository, fmt.Errorf("failed to fetch GitHub releases of %v\n\t%w", toothRepoPath, err)
+		}
+
+		var pageReleases []release
+		if err := json.Unmarshal(content, &pageReleases); err != nil {
+			return nil, repository, fmt.Errorf("failed to unmarshal GitHub releases of %v\n\t%w", toothRepoPath, err)
+		}
+
+		for _, r := range pageReleases {
+			if !r.Draft {
+				releases = append(releases, r)
+			}
+		}
+
+		if len(pageReleases) < network.GitHubReleasePageSize {
+			break
+		}
+	}
+
+	releaseCache[toothRepoPath] = releases
+
+	return releases, repository, nil
+}
+
+// parseTag parses the version of a tag with a prefix, e.g. sub/v1.2.3 with prefix sub/. The
+// second return value is false if the tag is not a version with the prefix.
+func parseTag(tag string, tagPrefix string) (semver.Version, bool) {
+	if !strings.HasPrefix(tag, tagPrefix) {
+		return semver.Version{}, false
+	}
+
+	version, err := semver.Parse(strings.TrimPrefix(strings.TrimPrefix(tag, tagPrefix), "v"))
+	if err != nil {
+		return semver.Version{}, false
+	}
+
+	return version, true
+}
+
+// parseToothRepoPath parses the GitHub repository of a tooth hosted on GitHub, e.g.
+// github.com/owner/repo, github.com/owner/repo/sub or github.com/owner/repo//sub.
+func parseToothRepoPath(toothRepoPath string) (repository, error) {
+	repoPath, subdir := network.SplitSubtooth(toothRepoPath)
+
+	parts := strings.Split(repoPath, "/")
+	if len(parts) < 3 || parts[0] != "github.com" || parts[1] == "" || parts[2] == "" {
+		return repository{}, fmt.Errorf("%v is not hosted on GitHub", toothRepoPath)
+	}
+
+	dirs := parts[3:]
+	if subdir != "" {
+		dirs = append(dirs, subdir)
+	}
+
+	tagPrefix := ""
+	if len(dirs) > 0 {
+		tagPrefix = strings.Join(dirs, "/") + "/"
+	}
+
+	return repository{
+		owner:                 parts[1],
+		repo:                  parts[2],
+		tagPrefix:             tagPrefix,
+		isSourceArchiveUsable: len(parts) == 3,
+	}, nil
+}
@@ -0,0 +1,254 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"time"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/record"
+	"github.com/lippkg/lip/internal/tooth"
+	"github.com/lippkg/lip/internal/webhook"
+)
+
+// Operation is the kind of an operation in the history.
+type Operation string
+
+const (
+	InstallOperation    Operation = "install"
+	UpdateOperation     Operation = "update"
+	UninstallOperation  Operation = "uninstall"
+	AutoremoveOperation Operation = "autoremove"
+	RollbackOperation   Operation = "rollback"
+	SwitchOperation     Operation = "switch"
+	UndoOperation       Operation = "undo"
+)
+
+// Entry is an operation in the history.
+type Entry struct {
+	// ID is the position of the entry in the history, starting from 1.
+	ID        int       `json:"id"`
+	Time      time.Time `json:"time"`
+	User      string    `json:"user"`
+	Operation Operation `json:"operation"`
+	Changes   []Change  `json:"changes"`
+
+	// Undoes is the ID of the entry undone by an undo entry, or 0 for other entries.
+	Undoes int `json:"undoes,omitempty"`
+
+	// Interrupted is whether the operation was interrupted, e.g. by Ctrl-C. Its changes are
+	// those made before it was interrupted and not rolled back.
+	Interrupted bool `json:"interrupted,omitempty"`
+}
+
+// Change is a change of a tooth made by an operation.
+type Change struct {
+	ToothRepoPath string `json:"tooth"`
+
+	// From is the version installed before the operation, or empty if the tooth was not
+	// installed.
+	From string `json:"from,omitempty"`
+
+	// To is the version installed after the operation, or empty if the tooth was uninstalled.
+	To string `json:"to,omitempty"`
+
+	// WasExplicit is whether the tooth was installed explicitly before the operation.
+	WasExplicit bool `json:"was_explicit,omitempty"`
+}
+
+// Append appends an operation to the history and sends it to the webhooks. Operations without
+// changes are not recorded, nor are operations when the history is disabled for the context.
+func Append(ctx *context.Context, operation Operation, changes []Change, undoes int) error {
+	if len(changes) == 0 || ctx.NoHistory() {
+		return nil
+	}
+
+	entry, err := appendEntry(ctx, Entry{
+		Operation: operation,
+		Changes:   changes,
+		Undoes:    undoes,
+	})
+	if err != nil {
+		return err
+	}
+
+	notify(ctx, entry)
+
+	return nil
+}
+
+// AppendInterrupted appends an interrupted operation to the history, with the changes it made
+// before it was interrupted and not rolled back. It is recorded even without changes, but sent
+// to the webhooks only with changes.
+func AppendInterrupted(ctx *context.Context, operation Operation, changes []Change) error {
+	if ctx.NoHistory() {
+		return nil
+	}
+
+	entry, err := appendEntry(ctx, Entry{
+		Operation:   operation,
+		Changes:     changes,
+		Interrupted: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(changes) != 0 {
+		notify(ctx, entry)
+	}
+
+	return nil
+}
+
+// GetUninstallChange returns the change of uninstalling an installed tooth, to be recorded
+// before uninstalling it.
+func GetUninstallChange(ctx *context.Context, toothRepoPath string) (Change, error) {
+	metadata, err := tooth.GetMetadata(ctx, toothRepoPath)
+	if err != nil {
+		return Change{}, fmt.Errorf("failed to find installed tooth metadata\n\t%w", err)
+	}
+
+	currentRecord, err := record.Get(ctx, toothRepoPath)
+	if err != nil {
+		return Change{}, fmt.Errorf("failed to get record of tooth %v\n\t%w", toothRepoPath, err)
+	}
+
+	return Change{
+		ToothRepoPath: toothRepoPath,
+		From:          metadata.Version().String(),
+		WasExplicit:   currentRecord.IsExplicit,
+	}, nil
+}
+
+// GetLastUndoable returns the most recent entry that is not an undo and has not been undone.
+func GetLastUndoable(ctx *context.Context) (Entry, bool, error) {
+	entries, err := List(ctx)
+	if err != nil {
+		return Entry{}, false, err
+	}
+
+	undone := make(map[int]bool)
+	for _, entry := range entries {
+		if entry.Operation == UndoOperation {
+			undone[entry.Undoes] = true
+		}
+	}
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		// Interrupted operations rolled back have nothing to undo.
+		if entries[i].Operation != UndoOperation && !undone[entries[i].ID] && len(entries[i].Changes) != 0 {
+			return entries[i], true, nil
+		}
+	}
+
+	return Entry{}, false, nil
+}
+
+// List returns the entries in the history, oldest first.
+func List(ctx *context.Context) ([]Entry, error) {
+	historyFilePath, err := ctx.HistoryFilePath()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get history file path\n\t%w", err)
+	}
+
+	file, err := ctx.FS().Open(historyFilePath.LocalString())
+	if os.IsNotExist(err) {
+		return []Entry{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to open history file %v\n\t%w", historyFilePath.LocalString(), err)
+	}
+	defer file.Close()
+
+	entries := make([]Entry, 0)
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, errcode.Errorf(errcode.MetadataInvalid, "invalid history entry at line %v of %v: %v",
+				lineNumber, historyFilePath.LocalString(), err)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history file %v\n\t%w", historyFilePath.LocalString(), err)
+	}
+
+	return entries, nil
+}
+
+// ---------------------------------------------------------------------
+
+// appendEntry completes an entry with its ID, time and user, appends it to the history file
+// and returns it.
+func appendEntry(ctx *context.Context, entry Entry) (Entry, error) {
+	entries, err := List(ctx)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	entry.ID = len(entries) + 1
+	entry.Time = ctx.Clock().Now().UTC()
+	entry.User = getUserName()
+
+	jsonBytes, err := json.Marshal(entry)
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to marshal history entry\n\t%w", err)
+	}
+
+	historyFilePath, err := ctx.HistoryFilePath()
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to get history file path\n\t%w", err)
+	}
+
+	file, err := ctx.FS().OpenFile(historyFilePath.LocalString(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to open history file %v\n\t%w", historyFilePath.LocalString(), err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(jsonBytes, '\n')); err != nil {
+		return Entry{}, fmt.Errorf("failed to write history file %v\n\t%w", historyFilePath.LocalString(), err)
+	}
+
+	return entry, nil
+}
+
+// notify sends an entry to the webhooks.
+func notify(ctx *context.Context, entry Entry) {
+	teeth := make([]webhook.Tooth, 0, len(entry.Changes))
+	for _, change := range entry.Changes {
+		teeth = append(teeth, webhook.Tooth{
+			ToothRepoPath: change.ToothRepoPath,
+			From:          change.From,
+			To:            change.To,
+		})
+	}
+
+	webhook.Notify(ctx, string(entry.Operation), entry.User, teeth)
+}
+
+// getUserName returns the name of the user running lip, or an empty string if it is unknown.
+func getUserName() string {
+	if currentUser, err := user.Current(); err == nil {
+		return currentUser.Username
+	}
+
+	if userName := os.Getenv("USER"); userName != "" {
+		return userName
+	}
+
+	return os.Getenv("USERNAME")
+}
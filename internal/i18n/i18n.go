@@ -0,0 +1,91 @@
+package i18n
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Supported languages.
+const (
+	English           = "en"
+	SimplifiedChinese = "zh-Hans"
+)
+
+// catalogs maps languages to message catalogs. A message catalog maps English messages,
+// which are also the message IDs, to the translated messages. English has no catalog.
+var catalogs = map[string]map[string]string{
+	SimplifiedChinese: simplifiedChineseCatalog,
+}
+
+var currentLanguage = English
+
+// DetectLanguage returns the language configured, or the language of the environment
+// (LC_ALL, LC_MESSAGES or LANG) if none is configured. Unsupported languages fall back to
+// English.
+func DetectLanguage(configuredLanguage string) string {
+	if configuredLanguage != "" {
+		return normalizeLanguage(configuredLanguage)
+	}
+
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if value := os.Getenv(name); value != "" {
+			return normalizeLanguage(value)
+		}
+	}
+
+	return English
+}
+
+// Errorf formats an error with the translated format string.
+func Errorf(format string, a ...interface{}) error {
+	return fmt.Errorf(T(format), a...)
+}
+
+// Language returns the current language.
+func Language() string {
+	return currentLanguage
+}
+
+// SetLanguage sets the current language.
+func SetLanguage(language string) {
+	currentLanguage = normalizeLanguage(language)
+}
+
+// Sprintf formats a string with the translated format string.
+func Sprintf(format string, a ...interface{}) string {
+	return fmt.Sprintf(T(format), a...)
+}
+
+// T translates a message into the current language. The message is returned as is if it has
+// no translation.
+func T(message string) string {
+	catalog, ok := catalogs[currentLanguage]
+	if !ok {
+		return message
+	}
+
+	if translated, ok := catalog[message]; ok {
+		return translated
+	}
+
+	return message
+}
+
+// ---------------------------------------------------------------------
+
+// normalizeLanguage converts a language tag or a POSIX locale (e.g. zh_CN.UTF-8) to a
+// supported language.
+func normalizeLanguage(language string) string {
+	// Strip the encoding and modifier, e.g. zh_CN.UTF-8@pinyin.
+	language, _, _ = strings.Cut(language, ".")
+	language, _, _ = strings.Cut(language, "@")
+	language = strings.ToLower(strings.ReplaceAll(language, "_", "-"))
+
+	switch language {
+	case "zh", "zh-cn", "zh-sg", "zh-hans", "zh-hans-cn":
+		return SimplifiedChinese
+	default:
+		return English
+	}
+}
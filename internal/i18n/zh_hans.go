@@ -0,0 +1,410 @@
+package i18n
+
+var simplifiedChineseCatalog = map[string]string{
+	// Prompts.
+	"%v refers to multiple teeth:":             "%v 对应多个 tooth：",
+	"Which one do you want to install? [1-%v]": "要安装哪一个？[1-%v]",
+	"Do you want to continue? [y/N]":           "是否继续？[y/N]",
+	"Do you want to remove? [y/N]":             "是否删除？[y/N]",
+	"Do you want to register them? [y/N]":      "是否注册它们？[y/N]",
+	"Install %v?":                              "安装 %v？",
+	"Uninstall %v?":                            "卸载 %v？",
+	"Update %v?":                               "更新 %v？",
+	"What is the tooth repo path? (e.g. github.com/tooth-hub/llbds3)": "tooth 仓库路径是什么？（例如 github.com/tooth-hub/llbds3）",
+	"What is the name?":                                      "名称是什么？",
+	"What is the description?":                               "描述是什么？",
+	"What is the author? Please input your GitHub username.": "作者是谁？请输入你的 GitHub 用户名。",
+	"What is the password?":                                  "密码是什么？",
+	"What is the token?":                                     "令牌是什么？",
+
+	// Progress.
+	"%v is not installed.":                                "%v 未安装。",
+	"%v@%v conflicts with:":                               "%v@%v 与以下约束冲突：",
+	"%v@%v is installed as a dependency.":                 "%v@%v 作为依赖安装。",
+	"%v@%v is installed explicitly.":                      "%v@%v 为显式安装。",
+	"%v@%v requires %v":                                   "%v@%v 要求 %v",
+	"(explicit)":                                          "（显式）",
+	"A new version of lip is available: %v (current: %v)": "lip 有新版本可用：%v（当前：%v）",
+	"Publishing %v@%v...":                                 "正在发布 %v@%v...",
+	"Published %v@%v as %v":                               "已将 %v@%v 发布为 %v",
+	"Logged in to %v.":                                    "已登录 %v。",
+	"Logged out of %v.":                                   "已登出 %v。",
+	"Not logged in to %v.":                                "未登录 %v。",
+	"Mirroring %v...":                                     "正在镜像 %v……",
+	"Mirrored the registry to %v, %v files downloaded.":   "已将 registry 镜像到 %v，下载了 %v 个文件。",
+	"Vendoring %v@%v...":                                  "正在将 %v@%v 复制到 vendor 目录……",
+	"Vendored %v teeth to %v.":                            "已将 %v 个 tooth 复制到 %v。",
+	"Exported %v teeth to %v.":                            "已将 %v 个 tooth 导出到 %v。",
+	"Imported %v teeth from %v.":                          "已从 %[2]v 导入 %[1]v 个 tooth。",
+	"Patching %v from %v to %v with %v patches":           "正在用 %[4]v 个补丁将 %[1]v 从 %[2]v 修补到 %[3]v",
+	"Published the patch from %v to %v (%v bytes) as %v":  "已将从 %v 到 %v 的补丁（%v 字节）发布为 %v",
+	"Replacing %v@%v with %v@%v from the bundle":          "正在用包中的 %[3]v@%[4]v 替换 %[1]v@%[2]v",
+	"Serving %v at %v":                                    "正在提供 %v，地址为 %v",
+	"Install from it with RegistryURL and GoModuleProxyURL set to %v, and GitHubMirrorURL set to %v%v.": "将 RegistryURL 和 GoModuleProxyURL 设置为 %v，并将 GitHubMirrorURL 设置为 %v%v，即可从中安装。",
+	"Aborted.":                        "已中止。",
+	"All teeth are up to date.":       "所有 tooth 均已是最新版本。",
+	"Destination %v already exists":   "目标 %v 已存在",
+	"All installed files are intact.": "所有已安装的文件均完好。",
+	"Applied the environment to %v. It takes effect in new shells.": "已将环境应用到 %v，将在新的 shell 中生效。",
+	"Backed up %v to %v": "已将 %v 备份到 %v",
+	"Done.":              "完成。",
+	"Downloading %v":     "正在下载 %v",
+	"Downloading teeth and resolving dependencies...":                           "正在下载 tooth 并解析依赖……",
+	"Kept existing user data %v":                                                "已保留现有的用户数据 %v",
+	"Kept user data %v. Uninstall with --purge to remove it.":                   "已保留用户数据 %v。使用 --purge 卸载以将其删除。",
+	"Kept config file %v with your changes":                                     "已保留包含你的修改的配置文件 %v",
+	"Kept your changes to %v and placed the new version as %v":                  "已保留你对 %v 的修改，并将新版本放置为 %v",
+	"Merged your changes into %v":                                               "已将你的修改合并到 %v",
+	"Installing teeth...":                                                       "正在安装 tooth……",
+	"Installing tooth %v":                                                       "正在安装 tooth %v",
+	"It is overridden to version %v despite the constraints of its dependents.": "它被覆盖为版本 %v，不受依赖它的 tooth 的约束。",
+	"Migrating deprecated tooth %v to %v@%v":                                    "正在将已弃用的 tooth %v 迁移到 %v@%v",
+	"No orphaned teeth to remove.":                                              "没有需要移除的孤立 tooth。",
+	"No available version of %v satisfies these constraints together:":          "%v 没有同时满足以下约束的可用版本：",
+	"No installed tooth requires it.":                                           "没有已安装的 tooth 需要它。",
+	"GnuPG is not installed, so the signature %v is not verified.":              "未安装 GnuPG，因此未校验签名 %v。",
+	"Signed %v":                                       "已签名 %v",
+	"Verified %v against %v":                          "已校验 %v（依据 %v）",
+	"Wrote checksum %v to %v":                         "已将校验和 %v 写入 %v",
+	"Packed %v@%v into %v":                            "已将 %v@%v 打包到 %v",
+	"Creating %v from template %v@%v...":              "正在创建 %v（模板 %v@%v）...",
+	"Created %v in %v":                                "已创建 %v（位于 %v）",
+	"Failed to remove %v: %v":                         "无法移除 %v：%v",
+	"No known vulnerabilities in %v installed teeth.": "%v 个已安装的 tooth 中没有已知漏洞。",
+	"Upgrade the affected teeth to the fixed versions with lip install --upgrade.":              "请使用 lip install --upgrade 将受影响的 tooth 升级到已修复的版本。",
+	"A new version of lip is available: %v -> %v. Run lip self update to update.":               "lip 有新版本可用：%v -> %v。运行 lip self update 以更新。",
+	"Updates of installed teeth are available:":                                                 "已安装的 tooth 有可用更新：",
+	"Run lip install --upgrade <tooth> to update.":                                              "运行 lip install --upgrade <tooth> 以更新。",
+	"No updates allowed by update policy %v.":                                                   "没有更新策略 %v 允许的更新。",
+	"No fixed version of %v for advisory %v. Skipped.":                                          "%v 没有修复公告 %v 的版本，已跳过。",
+	"Failed to write update log %v: %v":                                                         "写入更新日志 %v 失败：%v",
+	"--policy, --only-patch and --only-security are mutually exclusive":                         "--policy、--only-patch 和 --only-security 不能同时使用",
+	"Do you want to continue? [Y/n]":                                                            "是否继续？[Y/n]",
+	"Total download size: %v":                                                                   "总下载大小：%v",
+	"Total installed size: %v":                                                                  "总安装大小：%v",
+	"%v (some sizes are unknown)":                                                               "%v（部分大小未知）",
+	"Installing %v (%v/%v)...":                                                                  "正在安装 %v（%v/%v）...",
+	"Failed to install %v:\n\t%v":                                                               "安装 %v 失败：\n\t%v",
+	"failed to install %v of %v":                                                                "安装失败 %v 个，共 %v 个",
+	"fail-fast and keep-going flags are mutually exclusive":                                     "fail-fast 和 keep-going 选项不能同时使用",
+	"Received %v. Cleaning up... Press Ctrl-C again to exit at once.":                           "收到 %v，正在清理……再次按 Ctrl-C 立即退出。",
+	"Installation interrupted. Rolling back the changes...":                                     "安装被中断。正在回滚更改……",
+	"Cannot record the interruption in the history\n\t%v":                                       "无法在历史中记录中断\n\t%v",
+	"Cannot remove the install journal\n\t%v":                                                   "无法删除安装日志\n\t%v",
+	"Verifying teeth installed before the installation was cut off...":                          "正在校验安装中断前已安装的 tooth……",
+	"Verified tooth %v":                                                                         "已校验 tooth %v",
+	"Rolled back partly installed tooth %v":                                                     "已回滚部分安装的 tooth %v",
+	"Rolling back the installation...":                                                          "正在回滚安装……",
+	"Installing remaining teeth...":                                                             "正在安装剩余的 tooth……",
+	"Cannot write the profiles\n\t%v":                                                           "无法写入性能分析文件\n\t%v",
+	"Wrote CPU and heap profiles to %v":                                                         "已将 CPU 和堆性能分析文件写入 %v",
+	"Timing breakdown:":                                                                         "耗时分布：",
+	"Migrated %v files of installed teeth to the state database. Backups are kept in %v.":       "已将已安装 tooth 的 %v 个文件迁移到状态数据库。备份保存在 %v。",
+	"Migrated metadata of %v teeth to the current tooth.json format.":                           "已将 %v 个 tooth 的元数据迁移到当前的 tooth.json 格式。",
+	"Migrated the cached registry index.":                                                       "已迁移缓存的注册表索引。",
+	"Nothing to migrate.":                                                                       "没有需要迁移的内容。",
+	"Done. Backups are kept in %v.":                                                             "完成。备份保存在 %v。",
+	"\n\tcannot apply theme\n\t%v":                                                              "\n\t无法应用主题\n\t%v",
+	"Cannot load the env file, expanding no env variables in templates\n\t%v":                   "无法加载 env 文件，模板中不会展开 env 变量\n\t%v",
+	"%v requires %v %v, which is not found in the workspace. Its compatibility is not checked.": "%v 需要 %v %v，但工作空间中找不到它。不检查其兼容性。",
+	"%v@%v requires %v %v, but it is %v":                                                        "%v@%v 需要 %v %v，但实际为 %v",
+	"Ignoring incompatible runtime: %v":                                                         "忽略不兼容的运行时：%v",
+	"incompatible runtimes. Pass --ignore-runtimes to install anyway:\n\t  %v":                  "运行时不兼容。传入 --ignore-runtimes 以仍然安装：\n\t  %v",
+	"Cannot get the layout of the workspace, expanding no placement roots in templates\n\t%v":   "无法获取工作空间布局，模板中的放置根将不会展开\n\t%v",
+	"Workspace type: %v":                                                                        "工作空间类型：%v",
+	"Ignoring missing tool: %v":                                                                 "忽略缺少的工具：%v",
+	"missing tools. Install them, or pass --ignore-tools to install anyway:\n\t  %v":            "缺少工具。请安装它们，或传入 --ignore-tools 以仍然安装：\n\t  %v",
+	"%v requires %v":                        "%v 需要 %v",
+	"%v@%v does not support %v-%v, only %v": "%v@%v 不支持 %v-%v，仅支持 %v",
+	"no version of %v in the range supports %v-%v, e.g. %v@%v supports only %v": "%v 在该范围内没有支持 %v-%v 的版本，例如 %v@%v 仅支持 %v",
+	"Fixed %v problems in %v.":                        "已修复 %[2]v 中的 %[1]v 个问题。",
+	"No problems found in %v.":                        "%v 中未发现问题。",
+	"%v problems found in %v.":                        "%[2]v 中发现 %[1]v 个问题。",
+	"Cannot get the changelog of %v\n\t%v":            "无法获取 %v 的更新日志\n\t%v",
+	"Changes of %v from %v to %v:":                    "%v 从 %v 到 %v 的变更：",
+	"The tooth has no changelog.":                     "该 tooth 没有更新日志。",
+	"No changes after the installed version.":         "已安装版本之后没有变更。",
+	"Changelog:":                                      "更新日志：",
+	"No differences between %v@%v and %v.":            "%v@%v 与 %v 之间没有差异。",
+	"%v added, %v removed, %v changed, size delta %v": "新增 %v 个，删除 %v 个，更改 %v 个，大小变化 %v",
+	"Executables of %v are quarantined. Gatekeeper blocks them on their first run unless they are notarized. Allow them in System Settings > Privacy & Security if you trust them.":          "%v 的可执行文件已被隔离。除非经过公证，Gatekeeper 会在首次运行时阻止它们。如果您信任它们，请在“系统设置 > 隐私与安全性”中允许。",
+	"Executables of %v are marked as downloaded from the internet. SmartScreen may warn before running them unless they are signed, and some hosts refuse to load such DLLs.":                "%v 的可执行文件已被标记为从互联网下载。除非经过签名，SmartScreen 可能在运行前发出警告，且部分宿主会拒绝加载此类 DLL。",
+	"Executables of %v are quarantined, so Gatekeeper may block them:\n\t%v\nSet Quarantine to clear with lip config and reinstall the tooth if you trust them.":                             "%v 的可执行文件已被隔离，Gatekeeper 可能会阻止它们：\n\t%v\n如果您信任它们，请用 lip config 将 Quarantine 设为 clear 并重新安装该 tooth。",
+	"Executables of %v are marked as downloaded from the internet, so SmartScreen may block them:\n\t%v\nSet Quarantine to clear with lip config and reinstall the tooth if you trust them.": "%v 的可执行文件已被标记为从互联网下载，SmartScreen 可能会阻止它们：\n\t%v\n如果您信任它们，请用 lip config 将 Quarantine 设为 clear 并重新安装该 tooth。",
+	"Downloading from mirror %v":                                                  "正在从镜像 %v 下载",
+	"Failed to download from %v, trying the next mirror\n\t%v":                    "从 %v 下载失败，正在尝试下一个镜像\n\t%v",
+	"Failed to fetch from the local network, downloading instead\n\t%v":           "从局域网获取失败，改为下载\n\t%v",
+	"Fetched %v archive of %v@%v from %v on the local network":                    "已从局域网中的 %[4]v 获取 %[2]v@%[3]v 的 %[1]v 归档",
+	"%v sent a file not matching checksum %v, skipped it":                         "%v 发送的文件与校验和 %v 不匹配，已跳过",
+	"Sharing %v on the local network, answering at UDP port %v and serving at %v": "正在局域网中共享 %v，在 UDP 端口 %v 应答，在 %v 提供服务",
+	"Install from it with GoModuleProxyURL set to %v.":                            "将 GoModuleProxyURL 设置为 %v，即可从中安装。",
+	"Built %v":                             "已构建 %v",
+	"No build commands for this platform.": "当前平台没有构建命令。",
+	"Running %v":                           "正在运行 %v",
+	"build output %v does not exist after building":        "构建后构建产物 %v 不存在",
+	"build output %v does not exist. Run lip build first.": "构建产物 %v 不存在。请先运行 lip build。",
+	"Packing %v...":        "正在打包 %v……",
+	"Restored %v":          "已恢复 %v",
+	"Rolled back tooth %v": "已回滚 tooth %v",
+	"Removed %v unused files from the content store.": "已从内容存储中删除 %v 个未使用的文件。",
+	"Resolved capability %v to %v@%v":                 "已将能力 %v 解析为 %v@%v",
+	"Resolved %v to %v":                               "已将 %v 解析为 %v",
+	"Reinstalling tooth %v":                           "正在重新安装 tooth %v",
+	"Removing destination %v":                         "正在删除目标 %v",
+	"Required by:":                                    "被以下 tooth 需要：",
+	"Converted %v to %v.":                             "已将 %v 转换为 %v。",
+	"%v is valid.":                                    "%v 有效。",
+	"Successfully initialized a new tooth.":           "已成功初始化新的 tooth。",
+	"Summary:":                                        "摘要：",
+	"The installed teeth require the following environment. Run lip env --apply to set it up for new shells, or run this in the current shell:": "已安装的 tooth 需要以下环境。运行 lip env --apply 为新的 shell 设置，或在当前 shell 中运行：",
+	"The following teeth will be installed:":                            "将安装以下 tooth：",
+	"The following teeth will be uninstalled:":                          "将卸载以下 tooth：",
+	"Tooth %v is already installed":                                     "tooth %v 已安装",
+	"Tooth %v is already up-to-date":                                    "tooth %v 已是最新版本",
+	"Tooth %v will be rolled back from %v to %v.":                       "tooth %v 将从 %v 回滚到 %v。",
+	"Tooth %v will be switched from %v to %v.":                          "tooth %v 将从 %v 切换到 %v。",
+	"%v@%v is already active.":                                          "%v@%v 已是活动版本。",
+	"Removed %v@%v.":                                                    "已移除 %v@%v。",
+	"Updated lip to %v.":                                                "已将 lip 更新到 %v。",
+	"Upgrading tooth %v":                                                "正在升级 tooth %v",
+	"lip %v is already installed.":                                      "lip %v 已安装。",
+	"lip %v is newer than the latest release %v.":                       "lip %v 比最新发布版本 %v 更新。",
+	"lip %v is up to date.":                                             "lip %v 已是最新版本。",
+	"lip will be updated from %v to %v.":                                "lip 将从 %v 更新到 %v。",
+	"No differences.":                                                   "没有差异。",
+	"Saved snapshot %v with %v teeth.":                                  "已保存包含 %[2]v 个 tooth 的快照 %[1]v。",
+	"Saved the current state as snapshot %v.":                           "已将当前状态保存为快照 %v。",
+	"The following changes will be made to restore snapshot %v:":        "将进行以下更改以恢复快照 %v：",
+	"The workspace is already at snapshot %v.":                          "工作区已处于快照 %v 的状态。",
+	"Restored snapshot %v.":                                             "已恢复快照 %v。",
+	"Operation #%v (%v at %v) will be undone:":                          "将撤销操作 #%v（%v，时间 %v）：",
+	"%v@%v will be installed":                                           "将安装 %v@%v",
+	"%v@%v will be uninstalled":                                         "将卸载 %v@%v",
+	"Nothing to undo.":                                                  "没有可撤销的操作。",
+	"Undid operation #%v.":                                              "已撤销操作 #%v。",
+	"Cleared the usage statistics.":                                     "已清除使用统计。",
+	"None of the teeth to install declares an OS service.":              "要安装的 tooth 均未声明系统服务。",
+	"The following OS services will be registered and started on boot:": "将注册以下系统服务，并在开机时启动：",
+	"Skipped registering OS services.":                                  "已跳过注册系统服务。",
+	"They cannot be written even with administrator privileges. Check whether the file system is read-only or the directories are locked by another program.": "即使拥有管理员权限也无法写入这些目录。请检查文件系统是否为只读，或目录是否被其他程序锁定。",
+	"Run lip as administrator, e.g. in a terminal opened with Run as administrator, or grant your user write access to the directories.":                      "请以管理员身份运行 lip，例如在以“以管理员身份运行”打开的终端中运行，或授予当前用户这些目录的写入权限。",
+	"Run the command again with sudo, or make the directories writable by your user, e.g. with sudo chown -R $(id -un) %v":                                    "请使用 sudo 重新运行该命令，或使当前用户可写入这些目录，例如运行 sudo chown -R $(id -un) %v",
+	"To leave a read-only workspace untouched, run lip with --overlay <dir> instead.":                                                                         "如需保持只读工作区不变，请改用 --overlay <dir> 运行 lip。",
+	"Registering OS service %v of %v":               "正在注册 %[2]v 的系统服务 %[1]v",
+	"Checking health of %v...":                      "正在检查 %v 的健康状态...",
+	"Health check of %v passed":                     "%v 的健康检查已通过",
+	"Nothing to prune.":                             "没有需要清理的内容。",
+	"Pruned %v items.":                              "已清理 %v 项。",
+	"Installed teeth whose files no longer exist:":  "文件已不存在的已安装 tooth：",
+	"Records and manifests of teeth not installed:": "未安装的 tooth 的记录和清单：",
+	"Cached archives of teeth not installed:":       "未安装的 tooth 的缓存归档：",
+	"Temporary files left by aborted operations:":   "中断的操作遗留的临时文件：",
+	"Removed OS service %v":                         "已删除系统服务 %v",
+
+	// Warnings.
+	"Failed to get proxy URL:\n\t%v":                           "获取代理 URL 失败：\n\t%v",
+	"Failed to get webhook URLs:\n\t%v":                        "获取 webhook URL 失败：\n\t%v",
+	"Failed to get workspace directory:\n\t%v":                 "获取工作区目录失败：\n\t%v",
+	"Failed to marshal webhook payload:\n\t%v":                 "序列化 webhook 负载失败：\n\t%v",
+	"Failed to notify webhook %v:\n\t%v":                       "通知 webhook %v 失败：\n\t%v",
+	"Failed to patch %v, downloading the full archive:\n\t%v":  "修补 %v 失败，将下载完整归档：\n\t%v",
+	"Tooth %v is installed but not in the bundle. It is kept.": "tooth %v 已安装但不在包中，将保留它。",
+	"%v@%v is yanked":                   "%v@%v 已被撤回",
+	"%v@%v is yanked: %v":               "%v@%v 已被撤回：%v",
+	"Skipped %v@%v: %v":                 "已跳过 %v@%v：%v",
+	"Skipped invalid version %v of %v.": "已跳过 %[2]v 的无效版本 %[1]v。",
+	"Skipped asset archive %v of %v@%v, since only asset archives from GitHub or Go module paths can be mirrored.": "已跳过 %[2]v@%[3]v 的资源归档 %[1]v，因为只能镜像来自 GitHub 或 Go 模块路径的资源归档。",
+	". Use %v instead, or run with --migrate":                                                                                                        "。请改用 %v，或使用 --migrate 运行",
+	"Rate limited, retrying in %v (attempt %v of %v)\n\t%v":                                                                                          "请求受到速率限制，将在 %v 后重试（第 %v 次，共 %v 次）\n\t%v",
+	"The GitHub API rate limit is exceeded. Set GitHubToken or GITHUB_TOKEN, or run lip login, to raise the limit.":                                  "已超出 GitHub API 速率限制。设置 GitHubToken 或 GITHUB_TOKEN，或运行 lip login，以提高限制。",
+	"Cannot get the credential of %v, sending requests without it\n\t%v":                                                                             "无法获取 %v 的凭据，将不带凭据发送请求\n\t%v",
+	"Cannot write the overlay mapping file\n\t%v":                                                                                                    "无法写入覆盖层映射文件\n\t%v",
+	"Network operation failed, retrying in %v (attempt %v of %v)\n\t%v":                                                                              "网络操作失败，将在 %v 后重试（第 %v 次，共 %v 次）\n\t%v",
+	"Overriding %v to version %v, which does not have features %v required by %v":                                                                    "将 %v 覆盖为版本 %v，该版本不具有 %[4]v 要求的特性 %[3]v",
+	"Overriding %v to version %v, which does not satisfy %v required by %v":                                                                          "将 %v 覆盖为版本 %v，该版本不满足 %[4]v 要求的 %[3]v",
+	"Failed to revalidate %v, using the cached copy\n\t%v":                                                                                           "重新验证 %v 失败，使用缓存副本\n\t%v",
+	"Cannot merge your changes into %v because they conflict with the new version":                                                                   "无法将你的修改合并到 %v，因为它们与新版本冲突",
+	"Cannot merge your changes into %v without the original of the installed version":                                                                "缺少已安装版本的原始文件，无法将你的修改合并到 %v",
+	"Cannot create hard links, copying files instead\n\t%v":                                                                                          "无法创建硬链接，改为复制文件\n\t%v",
+	"Cannot create symlinks, copying files instead\n\t%v":                                                                                            "无法创建符号链接，改为复制文件\n\t%v",
+	"Cannot roll back tooth %v\n\t%v":                                                                                                                "无法回滚 tooth %v\n\t%v",
+	"Cannot check the constraints against the available versions of %v\n\t%v":                                                                        "无法根据 %v 的可用版本检查约束\n\t%v",
+	"Installation failed. Rolling back the changes...":                                                                                               "安装失败，正在回滚更改……",
+	"Failed to reinstall %v, run lip sync again to install them\n\t%v":                                                                               "重新安装 %v 失败，请再次运行 lip sync 以安装它们\n\t%v",
+	"Failed to restore record of tooth %v\n\t%v":                                                                                                     "恢复 tooth %v 的记录失败\n\t%v",
+	"Some changes cannot be rolled back. Run lip verify to check the installed teeth.":                                                               "部分更改无法回滚。请运行 lip verify 检查已安装的 tooth。",
+	"Environment variable %v is set by both %v and %v, using the value of %v":                                                                        "环境变量 %v 同时由 %v 和 %v 设置，使用 %v 的值",
+	"Failed to look up latest version for %v":                                                                                                        "查询 %v 的最新版本失败",
+	"Failed to look up yanked versions of %v\n\t%v":                                                                                                  "查询 %v 的已撤回版本失败\n\t%v",
+	"Failed to look up yanked versions of %v, assuming none\n\t%v":                                                                                   "查询 %v 的已撤回版本失败，视为没有已撤回版本\n\t%v",
+	"SECURITY WARNING: the %v archive of %v@%v does not match the checksum recorded when it was first downloaded. It might have been tampered with.": "安全警告：%[2]v@%[3]v 的 %[1]v 归档与首次下载时记录的校验和不一致，可能已被篡改。",
+	"No manifest of %v is recorded, skip verifying. Reinstall it to record one.":                                                                     "未记录 %v 的清单，跳过验证。重新安装以记录清单。",
+	"Tooth %v is deprecated":                     "tooth %v 已弃用",
+	"directory %v does not exist, skip deleting": "目录 %v 不存在，跳过删除",
+	"tooth.json format version %v of %v is deprecated. This tooth might be obsolete.":             "%[2]v 的 tooth.json 格式版本 %[1]v 已弃用。此 tooth 可能已过时。",
+	"Failed to update usage statistics:\n\t%v":                                                    "更新使用统计失败：\n\t%v",
+	"Failed to unregister OS service of %v\n\t%v":                                                 "注销 %v 的系统服务失败\n\t%v",
+	"lip cannot write to these directories:":                                                      "lip 无法写入以下目录：",
+	"The host of %v is displayed as %v, which looks like %v. Make sure it is the tooth you want.": "%v 的主机显示为 %v，看起来像 %v。请确认这是你想要的 tooth。",
+	"The host of %v is displayed as %v, which mixes scripts. Make sure it is the tooth you want.": "%v 的主机显示为 %v，混用了多种文字。请确认这是你想要的 tooth。",
+
+	// Errors.
+	"the patch does not apply to the old file":                                                         "补丁不适用于旧文件",
+	"truncated patch: %v":                                                                              "补丁不完整：%v",
+	"invalid patch: output exceeds %v bytes":                                                           "无效的补丁：输出超过 %v 字节",
+	"invalid patch: copy out of range of the old file":                                                 "无效的补丁：复制超出旧文件的范围",
+	"invalid patch: unknown instruction %v":                                                            "无效的补丁：未知指令 %v",
+	"the patched file does not match the checksum in the patch":                                        "修补后的文件与补丁中的校验和不匹配",
+	"not a patch made by lip":                                                                          "不是由 lip 生成的补丁",
+	"checksum mismatch of the patched archive of %v@%v: expected %v, got %v":                           "%v@%v 修补后的归档校验和不匹配：应为 %v，实为 %v",
+	"cannot patch from an archive of another tooth: %v != %v":                                          "不能从另一个 tooth 的归档生成补丁：%v != %v",
+	"cannot patch from version %v, which is not earlier than %v":                                       "不能从版本 %v 生成补丁，它不早于 %v",
+	"cannot make a patch with .tar.zst archive %v, use zip archives":                                   "不能用 .tar.zst 归档 %v 生成补丁，请使用 zip 归档",
+	"invalid .tar.zst archive %v: %v":                                                                  "无效的 .tar.zst 归档 %v：%v",
+	"invalid file path %v in %v":                                                                       "%[2]v 中的文件路径 %[1]v 无效",
+	"invalid bundle path %v":                                                                           "无效的包路径 %v",
+	"failed to open bundle %v: %v":                                                                     "无法打开包 %v：%v",
+	"unexpected file %v in bundle %v":                                                                  "包 %[2]v 中有意外的文件 %[1]v",
+	"%v is not a bundle created by lip export":                                                         "%v 不是由 lip export 创建的包",
+	"unsupported format version of bundle: %v":                                                         "不支持的包格式版本：%v",
+	"invalid version %v of %v in bundle":                                                               "包中 %[2]v 的版本 %[1]v 无效",
+	"%v refers to multiple teeth: %v. Specify the full tooth repository path":                          "%v 对应多个 tooth：%v。请指定完整的 tooth 仓库路径",
+	"cannot resolve %v without a registry. Set registry_url or specify the full tooth repository path": "没有 registry 时无法解析 %v。请设置 registry_url 或指定完整的 tooth 仓库路径",
+	"no tooth named %v in the registry":                                                                "registry 中没有名为 %v 的 tooth",
+	"\n\tcannot clean up after self update\n\t%v":                                                      "\n\t自更新后的清理失败\n\t%v",
+	"\n\tcannot load or create config file\n\t%v":                                                      "\n\t无法加载或创建配置文件\n\t%v",
+	"aborted":                                                   "已中止",
+	"%v problems found in %v":                                   "%[2]v 中发现 %[1]v 个问题",
+	"%v problems found in installed files":                      "已安装的文件中发现 %v 个问题",
+	"health check of %v failed\n\t%w":                           "%v 的健康检查失败\n\t%w",
+	"not enough disk space in %v: %v required, %v available":    "%v 的磁盘空间不足：需要 %v，可用 %v",
+	"no permission to write to %v directories":                  "没有写入 %v 个目录的权限",
+	"at least one specifier is required":                        "至少需要一个 tooth 说明符",
+	"%v is not cached and cannot be downloaded in offline mode": "%v 未缓存，离线模式下无法下载",
+	"%v is not cached and cannot be fetched in offline mode":    "%v 未缓存，离线模式下无法获取",
+	"cannot download lip in offline mode":                       "离线模式下无法下载 lip",
+	"this build of lip has no public key to verify releases with, download lip from its release page instead": "此版本的 lip 没有用于验证发布的公钥，请从发布页面下载 lip",
+	"malformed signature file":                        "签名文件格式错误",
+	"malformed signature":                             "签名格式错误",
+	"unsupported signature algorithm %v, expected %v": "不支持的签名算法 %v，应为 %v",
+	"signature is not made with the release key":      "签名不是由发布密钥生成的",
+	"invalid signature":                               "签名无效",
+	"malformed signature of trusted comment":          "可信注释的签名格式错误",
+	"invalid signature of trusted comment":            "可信注释的签名无效",
+	"cannot find versions of the specified teeth that satisfy their dependencies on each other": "无法找到满足彼此依赖的指定 tooth 版本",
+	"checksum mismatch for %v: recorded %v, got %v":                                             "%v 的校验和不匹配：记录为 %v，实际为 %v",
+	"cannot convert value to type: %v":                                                          "无法将值转换为类型：%v",
+	"cannot set key: %v":                                                                        "无法设置键：%v",
+	"no target to publish to, specify --oci":                                                    "未指定发布目标，请指定 --oci",
+	"cannot publish in offline mode":                                                            "离线模式下无法发布",
+	"cannot mirror a registry in offline mode":                                                  "离线模式下无法镜像 registry",
+	"RegistryURL must be configured to mirror a registry":                                       "镜像 registry 须先配置 RegistryURL",
+	"%v is not a mirror, run lip index mirror first":                                            "%v 不是镜像，请先运行 lip index mirror",
+	"no vendor directory found. Run lip vendor first":                                           "未找到 vendor 目录。请先运行 lip vendor",
+	"%v is not vendored":                                                                        "%v 不在 vendor 目录中",
+	"%v@%v is not vendored, the vendored version is %v":                                         "%v@%v 不在 vendor 目录中，vendor 目录中的版本为 %v",
+	"asset archive %v of %v is not vendored. Run lip vendor on this platform":                   "资源归档 %v（%v）不在 vendor 目录中。请在此平台上运行 lip vendor",
+	"vendored file %v is missing. Run lip vendor again":                                         "vendor 目录中缺少文件 %v。请重新运行 lip vendor",
+	"OCIRegistryURL must be configured to use OCI registries, e.g. https://ghcr.io/myorg":       "使用 OCI 仓库须先配置 OCIRegistryURL，例如 https://ghcr.io/myorg",
+	"the OCI artifact %v of %v has no layer of media type %v":                                   "%[2]v 的 OCI 制品 %[1]v 没有媒体类型为 %[3]v 的层",
+	"unsupported digest %v of the layer of %v in %v":                                            "不受支持的摘要 %[1]v（%[3]v 中 %[2]v 的层）",
+	"checksum mismatch of %v: expected %v, got %v":                                              "%v 的校验和不匹配：应为 %v，实为 %v",
+	"unexpected response from OCI registry (HTTP %v): %v":                                       "OCI 仓库的响应异常（HTTP %v）：%v",
+	"%v (HTTP %v): %v":               "%v（HTTP %v）：%v",
+	"%v (HTTP %v): %v\n\t%v":         "%v（HTTP %v）：%v\n\t%v",
+	"expected exactly one host":      "需要恰好一个主机",
+	"invalid host\n\t%w":             "主机无效\n\t%w",
+	"empty secret":                   "密钥为空",
+	"expected exactly one argument":  "需要恰好一个参数",
+	"expected exactly two arguments": "需要恰好两个参数",
+	"fixed tooth %v of version %v does not satisfy the version range %v":                   "已固定版本的 tooth %v（版本 %v）不满足版本范围 %v",
+	"invalid --limit-rate\n\t%w":                                                           "无效的 --limit-rate\n\t%w",
+	"invalid --overlay\n\t%w":                                                              "无效的 --overlay\n\t%w",
+	"the overlay directory cannot be the workspace":                                        "覆盖层目录不能是工作区",
+	"rate limited by %v (HTTP %v), the limit resets in %v":                                 "受到 %v 的速率限制（HTTP %v），限制将在 %v 后重置",
+	"release %v of %v has no %v asset, which is required for a tooth in a subdirectory":    "%[2]v 的发布 %[1]v 没有 %[3]v 资源，子目录中的 tooth 需要该资源",
+	"invalid number of arguments":                                                          "参数数量无效",
+	"invalid specifier kind %v":                                                            "无效的说明符类型 %v",
+	"invalid tooth repo path %v\n\t%w":                                                     "无效的 tooth 仓库路径 %v\n\t%w",
+	"invalid tooth repository path %v":                                                     "无效的 tooth 仓库路径 %v",
+	"invalid value of %v\n\t%w":                                                            "%v 的值无效\n\t%w",
+	"invalid version %v":                                                                   "无效的版本 %v",
+	"no available version in %v found for dependency %v":                                   "依赖 %[2]v 在 %[1]v 范围内没有可用版本",
+	"no available version in %v found for tooth %v\n\t%w":                                  "tooth %[2]v 在 %[1]v 范围内没有可用版本\n\t%[3]w",
+	"no version of %v matching %v satisfies the dependencies of %v":                        "%v 中没有匹配 %v 且满足 %v 依赖的版本",
+	"no cached archive of %v@%v found. Reinstall it with lip install --force-reinstall":    "未找到 %v@%v 的缓存归档。请使用 lip install --force-reinstall 重新安装",
+	"no command specified. See 'lip --help' for more information":                          "未指定命令。请参阅 'lip --help' 了解更多信息",
+	"no command specified. See 'lip cache --help' for more information":                    "未指定命令。请参阅 'lip cache --help' 了解更多信息",
+	"no command specified. See 'lip index --help' for more information":                    "未指定命令。请参阅 'lip index --help' 了解更多信息",
+	"no command specified. See 'lip self --help' for more information":                     "未指定命令。请参阅 'lip self --help' 了解更多信息",
+	"no command specified. See 'lip tooth --help' for more information":                    "未指定命令。请参阅 'lip tooth --help' 了解更多信息",
+	"no installed tooth numbered %v":                                                       "没有编号为 %v 的已安装 tooth",
+	"no previous version to roll back to":                                                  "没有可回滚到的先前版本",
+	"no registry is configured. Set registry_url with lip config":                          "未配置注册表。请使用 lip config 设置 registry_url",
+	"no advisory database is configured. Set AdvisoryDBURL or RegistryURL with lip config": "未配置安全公告数据库。请使用 lip config 设置 AdvisoryDBURL 或 RegistryURL",
+	"%v advisories of severity %v or higher affect installed teeth":                        "%v 条严重程度不低于 %v 的安全公告影响已安装的 tooth",
+	"invalid severity %v, expected low, medium, high or critical":                          "无效的严重程度 %v，应为 low、medium、high 或 critical",
+	"no search result numbered %v":                                                         "没有编号为 %v 的搜索结果",
+	"no snapshot of %v@%v found":                                                           "未找到 %v@%v 的快照",
+	"no such key: %v":                                                                      "没有这个键：%v",
+	"no tooth specified":                                                                   "未指定 tooth",
+	"output path %v already exists":                                                        "输出路径 %v 已存在",
+	"tooth %v is overridden to version %v, but version %v is specified":                    "tooth %v 被覆盖为版本 %v，但指定了版本 %v",
+	"too many arguments":                                                                   "参数过多",
+	"tooth %s has a circular dependency":                                                   "tooth %s 存在循环依赖",
+	"tooth %v is not installed":                                                            "tooth %v 未安装",
+	"tooth %v@%v is already installed":                                                     "tooth %v@%v 已安装",
+	"tooth is not installed":                                                               "tooth 未安装",
+	"tooth name mismatch: %v != %v":                                                        "tooth 名称不匹配：%v != %v",
+	"tooth version mismatch: %v != %v":                                                     "tooth 版本不匹配：%v != %v",
+	"tooth.json already exists":                                                            "tooth.json 已存在",
+	"trying to fix tooth %v with version %v, but found version %v fixed":                   "尝试将 tooth %v 固定为版本 %v，但已固定为版本 %v",
+	"unexpected arguments: %v":                                                             "意外的参数：%v",
+	"unknown command: lip %v":                                                              "未知命令：lip %v",
+	"unknown command: lip cache %v":                                                        "未知命令：lip cache %v",
+	"unknown command: lip index %v":                                                        "未知命令：lip index %v",
+	"unknown command: lip self %v":                                                         "未知命令：lip self %v",
+	"unknown command: lip tooth %v":                                                        "未知命令：lip tooth %v",
+	"unknown key: %v. Enter ? for help":                                                    "未知按键：%v。输入 ? 查看帮助",
+	"unsupported asset URL: %v":                                                            "不支持的资源 URL：%v",
+	"unsupported type: %v":                                                                 "不支持的类型：%v",
+	"unsupported shell: %v":                                                                "不支持的 shell：%v",
+	"symlink and hardlink flags are mutually exclusive":                                    "symlink 和 hardlink 选项不能同时使用",
+	"side-by-side flag is mutually exclusive with symlink and hardlink flags":              "side-by-side 选项不能与 symlink 和 hardlink 选项同时使用",
+	"%v@%v is not installed side by side":                                                  "%v@%v 未以并行方式安装",
+	"a version is required, e.g. %v@1.0.0":                                                 "需要指定版本，例如 %v@1.0.0",
+	"cannot use --patch-from with a directory":                                             "不能对目录使用 --patch-from",
+	"no tooth archives in %v":                                                              "%v 中没有 tooth 归档",
+	"tooth in %v must be %v, not %v":                                                       "%v 中的 tooth 必须是 %v，而不是 %v",
+	"a template is required, e.g. --template %v":                                           "需要指定模板，例如 --template %v",
+	"%v already exists":                                                                    "%v 已存在",
+	"invalid tooth repo path %v":                                                           "无效的 tooth 仓库路径 %v",
+	"invalid sort key %v, expected one of %v, %v and %v":                                   "无效的排序键 %v，应为 %v、%v 或 %v",
+	"cannot remove the active version %v of %v":                                            "不能移除 %[2]v 的活动版本 %[1]v",
+	"verbose and quiet flags are mutually exclusive":                                       "verbose 和 quiet 选项不能同时使用",
+	"expected at most one argument":                                                        "最多只能有一个参数",
+	"invalid snapshot name %v":                                                             "无效的快照名称 %v",
+	"invalid version %v of %v in snapshot":                                                 "快照中 %[2]v 的版本 %[1]v 无效",
+	"no command specified. See 'lip snapshot --help' for more information":                 "未指定命令。请参阅 'lip snapshot --help' 了解更多信息",
+	"snapshot %v already exists":                                                           "快照 %v 已存在",
+	"snapshot %v does not exist":                                                           "快照 %v 不存在",
+	"unknown command: lip snapshot %v":                                                     "未知命令：lip snapshot %v",
+	"unsupported format version of snapshot %v: %v":                                        "快照 %v 的格式版本不受支持：%v",
+	"invalid history entry at line %v of %v: %v":                                           "%[2]v 第 %[1]v 行的历史记录无效：%[3]v",
+	"%v does not declare a license":                                                        "%v 未声明许可证",
+	"%v is at dependency depth %v, deeper than the maximum of %v":                          "%v 的依赖深度为 %v，超过了最大值 %v",
+	"%v is licensed under blocked license %v":                                              "%v 使用了被禁止的许可证 %v",
+	"%v is not under an allowed prefix":                                                    "%v 不在允许的前缀下",
+	"%v is under a blocked prefix":                                                         "%v 在被禁止的前缀下",
+	"blocked by the installation policy:\n  %v":                                            "被安装策略阻止：\n  %v",
+	"invalid policy file %v: %v":                                                           "策略文件 %v 无效：%v",
+	"unsupported format version of policy file %v: %v":                                     "策略文件 %v 的格式版本不受支持：%v",
+	"invalid max_dependency_depth in policy file %v: %v":                                   "策略文件 %v 中的 max_dependency_depth 无效：%v",
+	"invalid statistics file %v: %v":                                                       "统计文件 %v 无效：%v",
+	"unsupported format version of statistics file %v: %v":                                 "统计文件 %v 的格式版本不受支持：%v",
+	"%v has a port in its host and cannot be fetched from the Go module proxy. Use an OCI registry instead": "%v 的主机带有端口，无法从 Go 模块代理获取。请改用 OCI 仓库",
+	"unsupported metadata format of %v":                                "%v 的元数据格式不受支持",
+	"no tooth.json, tooth.yaml or tooth.toml in the current directory": "当前目录中没有 tooth.json、tooth.yaml 或 tooth.toml",
+	"failed to convert metadata to JSON\n\t%w":                         "无法将元数据转换为 JSON\n\t%w",
+	"failed to convert %v to JSON\n\t%w":                               "无法将 %v 转换为 JSON\n\t%w",
+}
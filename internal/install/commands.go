@@ -5,13 +5,44 @@ import (
 	"os"
 	"os/exec"
 	"runtime"
+	"sort"
 
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/envfile"
 	log "github.com/sirupsen/logrus"
 )
 
+// GetCommandEnvirons returns the environment variables the commands of teeth run with on top of
+// those of lip: the proxy, and the variables of the env file of the workspace, which override
+// the others.
+func GetCommandEnvirons(ctx *context.Context) (map[string]string, error) {
+	commandEnvirons := make(map[string]string)
+
+	proxyURL, err := ctx.ProxyURL()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get proxy URL\n\t%w", err)
+	}
+
+	if proxyURL.String() != "" {
+		commandEnvirons["HTTP_PROXY"] = proxyURL.String()
+		commandEnvirons["HTTPS_PROXY"] = proxyURL.String()
+	}
+
+	envFileVariables, err := envfile.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, value := range envFileVariables {
+		commandEnvirons[name] = value
+	}
+
+	return commandEnvirons, nil
+}
+
 // runCommands runs the given commands.
-func runCommands(commands []string, environs map[string]string) error {
-	debugLogger := log.WithFields(log.Fields{
+func runCommands(ctx *context.Context, commands []string, environs map[string]string) error {
+	debugLogger := ctx.Logger().WithFields(log.Fields{
 		"package": "install",
 		"method":  "runCommands",
 	})
@@ -29,9 +60,7 @@ func runCommands(commands []string, environs map[string]string) error {
 		cmd.Stdout = os.Stdout
 		cmd.Stderr = os.Stderr
 
-		for key, value := range environs {
-			cmd.Env = append(os.Environ(), fmt.Sprintf("%v=%v", key, value))
-		}
+		cmd.Env = appendEnvirons(os.Environ(), environs)
 
 		if err := cmd.Run(); err != nil {
 			return fmt.Errorf("failed to run command %v\n\t%w", command, err)
@@ -42,3 +71,21 @@ func runCommands(commands []string, environs map[string]string) error {
 
 	return nil
 }
+
+// ---------------------------------------------------------------------
+
+// appendEnvirons appends environment variables to an environment in the form of os.Environ,
+// where they override variables of the same names.
+func appendEnvirons(env []string, environs map[string]string) []string {
+	names := make([]string, 0, len(environs))
+	for name := range environs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		env = append(env, fmt.Sprintf("%v=%v", name, environs[name]))
+	}
+
+	return env
+}
@@ -0,0 +1,183 @@
+package install
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/manifest"
+	"github.com/lippkg/lip/internal/merge"
+	"github.com/lippkg/lip/internal/path"
+	"github.com/lippkg/lip/internal/tooth"
+	log "github.com/sirupsen/logrus"
+)
+
+const configBackupSuffix = ".bak"
+const configNewSuffix = ".new"
+
+// isConfigFileModified returns whether the user has edited an installed config file, i.e. it
+// differs from the original placed by the last installation. Files without a kept original
+// are considered edited.
+func isConfigFileModified(ctx *context.Context, toothRepoPath string, relDest path.Path,
+	dest path.Path) (bool, error) {
+	content, err := ctx.FS().ReadFile(dest.LocalString())
+	if err != nil {
+		return false, fmt.Errorf("failed to read config file %v\n\t%w", dest.LocalString(), err)
+	}
+
+	base, hasBase, err := manifest.GetConfigBase(ctx, toothRepoPath, relDest.String())
+	if err != nil {
+		return false, fmt.Errorf("failed to get original of config file\n\t%w", err)
+	}
+
+	return !hasBase || !bytes.Equal(content, base), nil
+}
+
+// placeConfigFile places a config file at dest. If the user has edited the installed one, the
+// config strategy of the placement decides how the edits are kept.
+func placeConfigFile(ctx *context.Context, toothRepoPath string, r *zip.Reader,
+	place tooth.FilesPlaceItem, dest path.Path) (manifest.File, error) {
+	debugLogger := ctx.Logger().WithFields(log.Fields{
+		"package": "install",
+		"method":  "placeConfigFile",
+	})
+
+	content, err := readFileInArchive(r, place.Src)
+	if err != nil {
+		return manifest.File{}, fmt.Errorf("failed to read source file %v\n\t%w", place.Src, err)
+	}
+
+	if err := ctx.FS().MkdirAll(filepath.Dir(dest.LocalString()), 0755); err != nil {
+		return manifest.File{}, fmt.Errorf("failed to create destination directory\n\t%w", err)
+	}
+
+	existingContent, err := ctx.FS().ReadFile(dest.LocalString())
+	if os.IsNotExist(err) {
+		if err := ctx.FS().WriteFile(dest.LocalString(), content, 0644); err != nil {
+			return manifest.File{}, fmt.Errorf("failed to write config file\n\t%w", err)
+		}
+
+		debugLogger.Debugf("Placed config file %v", dest.LocalString())
+
+	} else if err != nil {
+		return manifest.File{}, fmt.Errorf("failed to read config file %v\n\t%w", dest.LocalString(), err)
+
+	} else if !bytes.Equal(existingContent, content) {
+		base, hasBase, err := manifest.GetConfigBase(ctx, toothRepoPath, place.Dest.String())
+		if err != nil {
+			return manifest.File{}, fmt.Errorf("failed to get original of config file\n\t%w", err)
+		}
+
+		if hasBase && bytes.Equal(existingContent, base) {
+			// Not edited by the user.
+			if err := ctx.FS().WriteFile(dest.LocalString(), content, 0644); err != nil {
+				return manifest.File{}, fmt.Errorf("failed to write config file\n\t%w", err)
+			}
+
+		} else if err := keepConfigFileEdits(ctx, place, dest, existingContent, content, base, hasBase); err != nil {
+			return manifest.File{}, err
+		}
+	}
+
+	if err := manifest.SaveConfigBase(ctx, toothRepoPath, place.Dest.String(), content); err != nil {
+		return manifest.File{}, fmt.Errorf("failed to keep original of config file\n\t%w", err)
+	}
+
+	mode, err := applyFileMode(ctx, dest, place, 0)
+	if err != nil {
+		return manifest.File{}, err
+	}
+
+	checksum := sha256.Sum256(content)
+
+	return manifest.File{
+		Path:   place.Dest.String(),
+		Source: place.Src.String(),
+		SHA256: hex.EncodeToString(checksum[:]),
+		Config: string(place.Config),
+		Mode:   manifest.FormatMode(mode),
+	}, nil
+}
+
+// ---------------------------------------------------------------------
+
+// keepConfigFileEdits places a new version of a config file edited by the user, according to
+// the config strategy of the placement.
+func keepConfigFileEdits(ctx *context.Context, place tooth.FilesPlaceItem, dest path.Path, existingContent []byte,
+	content []byte, base []byte, hasBase bool) error {
+	destString := dest.LocalString()
+
+	switch place.Config {
+	case tooth.BackupConfigStrategy:
+		if err := ctx.FS().Rename(destString, destString+configBackupSuffix); err != nil {
+			return fmt.Errorf("failed to back up config file %v\n\t%w", destString, err)
+		}
+
+		if err := ctx.FS().WriteFile(destString, content, 0644); err != nil {
+			return fmt.Errorf("failed to write config file\n\t%w", err)
+		}
+
+		ctx.Logger().Infof(i18n.T("Backed up %v to %v"), place.Dest.LocalString(), place.Dest.LocalString()+configBackupSuffix)
+
+		return nil
+
+	case tooth.MergeConfigStrategy:
+		if !hasBase {
+			ctx.Logger().Warnf(i18n.T("Cannot merge your changes into %v without the original of the installed version"),
+				place.Dest.LocalString())
+			break
+		}
+
+		merged, ok := merge.ThreeWay(string(base), string(existingContent), string(content))
+		if !ok {
+			ctx.Logger().Warnf(i18n.T("Cannot merge your changes into %v because they conflict with the new version"),
+				place.Dest.LocalString())
+			break
+		}
+
+		if err := ctx.FS().WriteFile(destString, []byte(merged), 0644); err != nil {
+			return fmt.Errorf("failed to write config file\n\t%w", err)
+		}
+
+		ctx.Logger().Infof(i18n.T("Merged your changes into %v"), place.Dest.LocalString())
+
+		return nil
+	}
+
+	// Keep the edited file and place the new version next to it.
+	if err := ctx.FS().WriteFile(destString+configNewSuffix, content, 0644); err != nil {
+		return fmt.Errorf("failed to write config file\n\t%w", err)
+	}
+
+	ctx.Logger().Infof(i18n.T("Kept your changes to %v and placed the new version as %v"), place.Dest.LocalString(),
+		place.Dest.LocalString()+configNewSuffix)
+
+	return nil
+}
+
+// readFileInArchive reads a file in a zip archive.
+func readFileInArchive(r *zip.Reader, filePath path.Path) ([]byte, error) {
+	for _, f := range r.File {
+		fPath, err := path.ParseRelative(f.Name)
+		if err != nil || !fPath.Equal(filePath) {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open file\n\t%w", err)
+		}
+		defer rc.Close()
+
+		return io.ReadAll(rc)
+	}
+
+	return nil, fmt.Errorf("file not found in archive")
+}
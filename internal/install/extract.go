@@ -0,0 +1,264 @@
+package install
+
+import (
+	"archive/zip"
+	gocontext "context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/lippkg/lip/internal/contentstore"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/manifest"
+	"github.com/lippkg/lip/internal/path"
+	"github.com/lippkg/lip/internal/tooth"
+	log "github.com/sirupsen/logrus"
+)
+
+// extractJob is a file in the asset archive to extract to its destination.
+type extractJob struct {
+	file    *zip.File
+	place   tooth.FilesPlaceItem
+	relDest path.Path
+	dest    path.Path
+}
+
+// extractFiles extracts files concurrently with at most one worker per CPU, and returns the
+// placed files in the order of the jobs. All jobs are run even if some fail, and the errors
+// of all failed jobs are returned together. Once the Go context of ctx is canceled, the jobs
+// left are not run and the error of the context is returned.
+func extractFiles(ctx *context.Context, metadata tooth.Metadata, jobs []extractJob) ([]manifest.File, error) {
+	placedFiles := make([]manifest.File, len(jobs))
+	errs := make([]error, len(jobs))
+
+	// Symlinks and hard links fall back to copying for the rest of the files once they fail,
+	// e.g. when the filesystem does not support them.
+	var useSymlink atomic.Bool
+	useSymlink.Store(ctx.Symlink())
+
+	var useHardlink atomic.Bool
+	useHardlink.Store(ctx.Hardlink() && !ctx.Symlink())
+
+	workerCount := runtime.NumCPU()
+	if workerCount > len(jobs) {
+		workerCount = len(jobs)
+	}
+
+	jobIndexes := make(chan int)
+	var wg sync.WaitGroup
+
+	for i := 0; i < workerCount; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for jobIndex := range jobIndexes {
+				if err := ctx.GoContext().Err(); err != nil {
+					errs[jobIndex] = err
+					continue
+				}
+
+				placedFiles[jobIndex], errs[jobIndex] = extractFile(ctx, metadata, jobs[jobIndex],
+					&useSymlink, &useHardlink)
+			}
+		}()
+	}
+
+	for i := range jobs {
+		jobIndexes <- i
+	}
+	close(jobIndexes)
+
+	wg.Wait()
+
+	if err := ctx.GoContext().Err(); err != nil {
+		return nil, fmt.Errorf("failed to place files\n\t%w", err)
+	}
+
+	messages := make([]string, 0)
+	for i, err := range errs {
+		if err != nil {
+			messages = append(messages, fmt.Sprintf("%v: %v", jobs[i].relDest.LocalString(), err))
+		}
+	}
+
+	if len(messages) != 0 {
+		return nil, fmt.Errorf("failed to place %v of %v files\n\t%v", len(messages), len(jobs),
+			strings.Join(messages, "\n\t"))
+	}
+
+	return placedFiles, nil
+}
+
+// ---------------------------------------------------------------------
+
+// extractFile extracts a file to its destination and returns the placed file.
+func extractFile(ctx *context.Context, metadata tooth.Metadata, job extractJob, useSymlink *atomic.Bool,
+	useHardlink *atomic.Bool) (manifest.File, error) {
+	debugLogger := ctx.Logger().WithFields(log.Fields{
+		"package": "install",
+		"method":  "extractFile",
+	})
+
+	// User data is edited in place, so it is always extracted to the destination instead of
+	// being linked.
+	if job.place.UserData {
+		return extractUserData(ctx, metadata, job)
+	}
+
+	// Side by side, the file is extracted to the directory of the version and linked from the
+	// destination through the current link. There is no fallback, since switching versions
+	// relies on the links.
+	if ctx.SideBySide() {
+		return extractVersionFile(ctx, metadata, job)
+	}
+
+	// In symlink mode, the file is extracted to the store and linked from the destination.
+	isSymlinked := useSymlink.Load()
+
+	extractPath := job.dest
+	if isSymlinked {
+		storePath, err := getStorePath(ctx, metadata.ToothRepoPath(), job.relDest)
+		if err != nil {
+			return manifest.File{}, err
+		}
+
+		if err := ctx.FS().MkdirAll(filepath.Dir(storePath.LocalString()), 0755); err != nil {
+			return manifest.File{}, fmt.Errorf("failed to create store directory\n\t%w", err)
+		}
+
+		extractPath = storePath
+	}
+
+	checksum, err := extractToFile(ctx, job.file, extractPath)
+	if err != nil {
+		return manifest.File{}, err
+	}
+
+	mode, err := applyFileMode(ctx, extractPath, job.place, job.file.Mode())
+	if err != nil {
+		return manifest.File{}, err
+	}
+
+	if isSymlinked {
+		if err := linkFile(ctx, extractPath, job.dest); err != nil {
+			if useSymlink.CompareAndSwap(true, false) {
+				ctx.Logger().Warnf(i18n.T("Cannot create symlinks, copying files instead\n\t%v"), err)
+			}
+
+			if err := copyFile(ctx, extractPath, job.dest); err != nil {
+				return manifest.File{}, fmt.Errorf("failed to copy file\n\t%w", err)
+			}
+		}
+	}
+
+	if useHardlink.Load() {
+		if err := contentstore.Link(ctx, job.dest, checksum); err != nil {
+			// Keep the copy and stop linking the rest of the files.
+			if useHardlink.CompareAndSwap(true, false) {
+				ctx.Logger().Warnf(i18n.T("Cannot create hard links, copying files instead\n\t%v"), err)
+			}
+		}
+	}
+
+	debugLogger.Debugf("Placed file %v to %v", job.file.Name, job.dest.LocalString())
+
+	return manifest.File{
+		Path:   job.relDest.String(),
+		Source: job.place.Src.String(),
+		SHA256: checksum,
+		Mode:   manifest.FormatMode(mode),
+	}, nil
+}
+
+// extractUserData extracts a file holding user data to its destination and returns the placed
+// file. Side by side, a copy is also kept with the version, to place it when switching back to
+// the version if it is missing.
+func extractUserData(ctx *context.Context, metadata tooth.Metadata, job extractJob) (manifest.File, error) {
+	checksum, err := extractToFile(ctx, job.file, job.dest)
+	if err != nil {
+		return manifest.File{}, err
+	}
+
+	if ctx.SideBySide() {
+		versionFilePath, err := getVersionFilePath(ctx, metadata.ToothRepoPath(), metadata.Version(), job.relDest)
+		if err != nil {
+			return manifest.File{}, err
+		}
+
+		if err := ctx.FS().MkdirAll(filepath.Dir(versionFilePath.LocalString()), 0755); err != nil {
+			return manifest.File{}, fmt.Errorf("failed to create version directory\n\t%w", err)
+		}
+
+		if err := copyFile(ctx, job.dest, versionFilePath); err != nil {
+			return manifest.File{}, fmt.Errorf("failed to keep user data with the version\n\t%w", err)
+		}
+	}
+
+	mode, err := applyFileMode(ctx, job.dest, job.place, job.file.Mode())
+	if err != nil {
+		return manifest.File{}, err
+	}
+
+	return manifest.File{
+		Path:     job.relDest.String(),
+		Source:   job.place.Src.String(),
+		SHA256:   checksum,
+		Mode:     manifest.FormatMode(mode),
+		UserData: true,
+	}, nil
+}
+
+// extractToFile writes a file in an archive to a path and returns the SHA-256 checksum of its
+// content. The partial file is removed if writing fails or the Go context of ctx is canceled.
+func extractToFile(ctx *context.Context, f *zip.File, filePath path.Path) (string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return "", fmt.Errorf("failed to open source file\n\t%w", err)
+	}
+	defer rc.Close()
+
+	fw, err := ctx.FS().Create(filePath.LocalString())
+	if err != nil {
+		return "", fmt.Errorf("failed to create destination file\n\t%w", err)
+	}
+
+	// Copy the file and calculate its checksum. The file is closed before it is removed on
+	// failure, and an error closing it means its content may not be written.
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(fw, hash), contextReader{goCtx: ctx.GoContext(), reader: rc})
+	if closeErr := fw.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to close destination file\n\t%w", closeErr)
+	} else if err != nil {
+		err = fmt.Errorf("failed to copy file\n\t%w", err)
+	}
+
+	if err != nil {
+		ctx.FS().Remove(filePath.LocalString())
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// contextReader is a reader failing with the error of its Go context once it is canceled, so
+// that copying a large file stops promptly.
+type contextReader struct {
+	goCtx  gocontext.Context
+	reader io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.goCtx.Err(); err != nil {
+		return 0, err
+	}
+
+	return r.reader.Read(p)
+}
@@ -0,0 +1,121 @@
+package install
+
+import (
+	gocontext "context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/network"
+	"github.com/lippkg/lip/internal/tooth"
+	log "github.com/sirupsen/logrus"
+)
+
+// healthCheckInterval is how long to wait before retrying a failed health check.
+const healthCheckInterval = time.Second
+
+// CheckHealth runs the health check of a tooth until it passes, its timeout is reached or the
+// Go context of ctx is canceled. A command runs with the environment variables on top of those of lip, see
+// GetCommandEnvirons. The error of the last attempt is returned if it never passes.
+func CheckHealth(ctx *context.Context, healthCheck tooth.HealthCheck, environs map[string]string) error {
+	debugLogger := ctx.Logger().WithFields(log.Fields{
+		"package": "install",
+		"method":  "CheckHealth",
+	})
+
+	goCtx := ctx.GoContext()
+
+	deadline := ctx.Clock().Now().Add(healthCheck.Timeout)
+
+	for {
+		timeout := deadline.Sub(ctx.Clock().Now())
+
+		var err error
+		if healthCheck.URL != "" {
+			err = probeHTTP(goCtx, ctx.Network(), healthCheck.URL, timeout)
+		} else {
+			err = runHealthCheckCommand(goCtx, healthCheck.Command, environs, timeout)
+		}
+
+		if err == nil {
+			return nil
+		}
+		debugLogger.Debugf("Health check failed: %v", err)
+
+		if goCtx.Err() != nil {
+			return err
+		}
+
+		if ctx.Clock().Now().Add(healthCheckInterval).After(deadline) {
+			return fmt.Errorf("health check did not pass within %v\n\t%w", healthCheck.Timeout, err)
+		}
+
+		select {
+		case <-time.After(healthCheckInterval):
+		case <-goCtx.Done():
+			return goCtx.Err()
+		}
+	}
+}
+
+// ---------------------------------------------------------------------
+
+// probeHTTP sends a GET request to the URL and expects a 2xx status. The request does not go
+// through the proxy, since the endpoint is usually served on the local machine. It gives up
+// after timeout.
+func probeHTTP(goCtx gocontext.Context, client *network.Client, urlStr string, timeout time.Duration) error {
+	requestCtx, cancel := gocontext.WithTimeout(goCtx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(requestCtx, http.MethodGet, urlStr, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request to %v\n\t%w", urlStr, err)
+	}
+
+	resp, err := client.SendRequest(req, &url.URL{})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%v responded with status %v", urlStr, resp.Status)
+	}
+
+	return nil
+}
+
+// runHealthCheckCommand runs the command in the shell and expects it to exit with 0. Its
+// output is reported on failure. It is killed after timeout.
+func runHealthCheckCommand(goCtx gocontext.Context, command string, environs map[string]string,
+	timeout time.Duration) error {
+	commandCtx, cancel := gocontext.WithTimeout(goCtx, timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.CommandContext(commandCtx, "cmd", "/C", command)
+	default:
+		cmd = exec.CommandContext(commandCtx, "sh", "-c", command)
+	}
+
+	cmd.Env = appendEnvirons(os.Environ(), environs)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if message := strings.TrimSpace(string(output)); message != "" {
+			return fmt.Errorf("command %v failed: %v\n\t%w", command, message, err)
+		}
+
+		return fmt.Errorf("command %v failed\n\t%w", command, err)
+	}
+
+	return nil
+}
@@ -1,40 +1,35 @@
 package install
 
 import (
-	"archive/zip"
+	gozip "archive/zip"
 	"fmt"
-	"io"
-	"net/url"
-	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/manifest"
 	"github.com/lippkg/lip/internal/path"
+	"github.com/lippkg/lip/internal/profiling"
+	"github.com/lippkg/lip/internal/statedb"
 	"github.com/lippkg/lip/internal/tooth"
+	"github.com/lippkg/lip/internal/zip"
 	log "github.com/sirupsen/logrus"
 )
 
 // Install installs a tooth archive with an asset archive. If assetArchiveFilePath is empty,
 // will use the tooth archive as the asset archive.
 func Install(ctx *context.Context, archive tooth.Archive, yes bool) error {
-	debugLogger := log.WithFields(log.Fields{
+	debugLogger := ctx.Logger().WithFields(log.Fields{
 		"package": "install",
 		"method":  "Install",
 	})
 
-	commandEnvirons := make(map[string]string)
+	defer ctx.Timer().Start(profiling.PlacePhase)()
 
-	proxyURL, err := ctx.ProxyURL()
+	commandEnvirons, err := GetCommandEnvirons(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to get proxy URL\n\t%w", err)
-	}
-
-	if proxyURL.String() != "" {
-		commandEnvirons = map[string]string{
-			"HTTP_PROXY":  proxyURL.String(),
-			"HTTPS_PROXY": proxyURL.String(),
-		}
+		return err
 	}
 
 	// 1. Check if the tooth is already installed.
@@ -48,155 +43,271 @@ func Install(ctx *context.Context, archive tooth.Archive, yes bool) error {
 
 	// 2. Run pre-install commands.
 
-	if err := runCommands(archive.Metadata().Commands().PreInstall, commandEnvirons); err != nil {
+	if err := runCommands(ctx, archive.Metadata().Commands().PreInstall, commandEnvirons); err != nil {
 		return fmt.Errorf("failed to run pre-install commands\n\t%w", err)
 	}
 	debugLogger.Debug("Ran pre-install commands")
 
 	// 3. Extract and place files.
 
+	// Files remapped by the workspace manifest are placed in the directories they are moved
+	// to. The installed metadata records these, so the files are found when uninstalling even
+	// if the workspace manifest changes later.
+	metadata, remaps, err := RemapDestinations(ctx, archive.Metadata())
+	if err != nil {
+		return err
+	}
+
+	// Files skipped by their tags are left out of the installed metadata as well, so they are
+	// neither expected by lip verify nor removed when uninstalling.
+	if len(ctx.SkippedTags()) != 0 {
+		metadata = metadata.ToPlacementsSkipped(ctx.Logger(), ctx.SkippedTags())
+	}
+
 	assetFilePath, err := archive.AssetFilePath()
 	if err != nil {
 		return fmt.Errorf("failed to get asset file path of archive %v\n\t%w", archive.FilePath().LocalString(), err)
 	}
 
-	if err := placeFiles(ctx, archive.Metadata(), assetFilePath, yes); err != nil {
+	// Side by side, the files are extracted to the directory of the version.
+	if ctx.SideBySide() {
+		if err := prepareVersionDir(ctx, archive.Metadata().ToothRepoPath(), archive.Metadata().Version()); err != nil {
+			return err
+		}
+	}
+
+	placedFiles, err := placeFiles(ctx, metadata, assetFilePath, yes)
+	if err != nil {
 		return fmt.Errorf("failed to place files\n\t%w", err)
 	}
 	debugLogger.Debug("Placed files")
 
+	if err := applyQuarantine(ctx, archive.Metadata().ToothRepoPath(), placedFiles); err != nil {
+		return fmt.Errorf("failed to apply quarantine\n\t%w", err)
+	}
+
+	if ctx.SideBySide() {
+		if err := setCurrentVersion(ctx, archive.Metadata().ToothRepoPath(), archive.Metadata().Version()); err != nil {
+			return err
+		}
+		debugLogger.Debug("Activated version")
+	}
+
+	// Registered after placing its files, so that the tooth is enabled once it is complete.
+	if err := registerTooth(ctx, metadata); err != nil {
+		return err
+	}
+	debugLogger.Debug("Registered tooth")
+
 	// 4. Run post-install commands.
 
-	if err := runCommands(archive.Metadata().Commands().PostInstall, commandEnvirons); err != nil {
+	if err := runCommands(ctx, archive.Metadata().Commands().PostInstall, commandEnvirons); err != nil {
 		return fmt.Errorf("failed to run post-install commands\n\t%w", err)
 	}
 	debugLogger.Debug("Ran post-install commands")
 
 	// 5. Create metadata file.
 
-	jsonBytes, err := archive.Metadata().MarshalJSON()
+	if err := writeMetadataFile(ctx, metadata); err != nil {
+		return err
+	}
+
+	// 6. Record the manifest of placed files.
+
+	placedManifest := manifest.Manifest{
+		ToothRepoPath: archive.Metadata().ToothRepoPath(),
+		Version:       archive.Metadata().Version().String(),
+		AssetArchive:  assetFilePath.LocalString(),
+		Files:         placedFiles,
+		Remaps:        remaps,
+	}
+
+	if err := manifest.Save(ctx, placedManifest); err != nil {
+		return fmt.Errorf("failed to save manifest\n\t%w", err)
+	}
+
+	// 7. Keep the metadata and the manifest with the files of the version, to switch back to it.
+
+	if ctx.SideBySide() {
+		if err := saveVersionInfo(ctx, metadata, placedManifest); err != nil {
+			return fmt.Errorf("failed to save version information\n\t%w", err)
+		}
+	}
+
+	return nil
+}
+
+// writeMetadataFile writes the metadata of an installed tooth to the state database.
+func writeMetadataFile(ctx *context.Context, metadata tooth.Metadata) error {
+	debugLogger := ctx.Logger().WithFields(log.Fields{
+		"package": "install",
+		"method":  "writeMetadataFile",
+	})
+
+	jsonBytes, err := metadata.MarshalJSON()
 	if err != nil {
 		return fmt.Errorf("failed to marshal metadata\n\t%w", err)
 	}
 
-	metadataFileName := url.QueryEscape(archive.Metadata().ToothRepoPath()) + ".json"
-	metadataDir, err := ctx.MetadataDir()
+	err = statedb.Update(ctx, metadata.ToothRepoPath(), func(tooth *statedb.Tooth) {
+		tooth.Version = metadata.Version().String()
+		tooth.Metadata = jsonBytes
+	})
 	if err != nil {
-		return fmt.Errorf("failed to get metadata directory\n\t%w", err)
+		return fmt.Errorf("failed to save metadata\n\t%w", err)
 	}
 
-	metadataPath := metadataDir.Join(path.MustParse(metadataFileName))
+	debugLogger.Debugf("Saved metadata of %v", metadata.ToothRepoPath())
+
+	return nil
+}
 
-	if err := os.WriteFile(metadataPath.LocalString(), jsonBytes, 0644); err != nil {
-		return fmt.Errorf("failed to create metadata file\n\t%w", err)
+// keepUserData keeps existing user data at dest instead of placing the file of the tooth, and
+// returns it as a placed file.
+func keepUserData(ctx *context.Context, place tooth.FilesPlaceItem, dest path.Path) (manifest.File, error) {
+	checksum, err := manifest.HashFile(ctx.FS(), dest)
+	if err != nil {
+		return manifest.File{}, fmt.Errorf("failed to hash user data %v\n\t%w", place.Dest.LocalString(), err)
 	}
 
-	debugLogger.Debugf("Created metadata file %v", metadataPath.LocalString())
+	ctx.Logger().Infof(i18n.T("Kept existing user data %v"), place.Dest.LocalString())
 
-	return nil
+	return manifest.File{
+		Path:     place.Dest.String(),
+		Source:   place.Src.String(),
+		SHA256:   checksum,
+		UserData: true,
+	}, nil
 }
 
-// placeFiles places the files of the tooth.
-func placeFiles(ctx *context.Context, metadata tooth.Metadata, assetArchiveFilePath path.Path, forcePlace bool) error {
-	debugLogger := log.WithFields(log.Fields{
+// placeFiles places the files of the tooth and returns the placed files with their checksums.
+func placeFiles(ctx *context.Context, metadata tooth.Metadata, assetArchiveFilePath path.Path,
+	forcePlace bool) ([]manifest.File, error) {
+	debugLogger := ctx.Logger().WithFields(log.Fields{
 		"package": "install",
 		"method":  "placeFiles",
 	})
 
-	workspaceDirStr, err := os.Getwd()
-	if err != nil {
-		return err
-	}
-
-	workspaceDir, err := path.Parse(workspaceDirStr)
+	workspaceDir, err := ctx.WorkspaceDir()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Open the archive.
-	r, err := zip.OpenReader(assetArchiveFilePath.LocalString())
+	r, err := zip.OpenReader(ctx.FS(), assetArchiveFilePath.LocalString())
 	if err != nil {
-		return fmt.Errorf("failed to open zip reader\n\t%w", err)
+		return nil, fmt.Errorf("failed to open zip reader\n\t%w", err)
 	}
 	defer r.Close()
 
 	files, err := metadata.Files()
 	if err != nil {
-		return fmt.Errorf("failed to get files from metadata\n\t%w", err)
+		return nil, fmt.Errorf("failed to get files from metadata\n\t%w", err)
 	}
 
-	for _, place := range files.Place {
-		relDest := place.Dest
-
-		// Check if the destination exists.
-		if _, err := os.Stat(relDest.LocalString()); err == nil {
-			if !forcePlace {
-				// Ask for confirmation.
-				log.Infof("Destination %v already exists", relDest.LocalString())
-				log.Info("Do you want to remove? [y/N]")
-				var ans string
-				fmt.Scanln(&ans)
-				if ans != "y" && ans != "Y" {
-					return fmt.Errorf("aborted")
-				}
-			}
+	// Index the files in the archive by path, skipping directories.
+	archiveFileMap := make(map[string][]*gozip.File)
+	for _, f := range r.File {
+		if strings.HasSuffix(f.Name, "/") {
+			debugLogger.Debugf("Skipped %v because it is a directory", f.Name)
 
-			log.Infof("Removing destination %v", relDest.LocalString())
+			continue
+		}
 
-			// Remove the destination if it exists.
-			if err := os.RemoveAll(relDest.LocalString()); err != nil {
-				return fmt.Errorf("failed to remove destination %v\n\t%w", relDest.LocalString(), err)
-			}
+		filePath, err := path.ParseRelative(f.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse file path from %v\n\t%w", f.Name, err)
 		}
 
-		dest := workspaceDir.Join(relDest)
+		archiveFileMap[filePath.String()] = append(archiveFileMap[filePath.String()], f)
+	}
 
-		// Create the destination directory.
-		if err := os.MkdirAll(filepath.Dir(dest.LocalString()), 0755); err != nil {
-			return fmt.Errorf("failed to create destination directory\n\t%w", err)
-		}
-		debugLogger.Debugf("Created destination directory %v", filepath.Dir(dest.LocalString()))
+	// Prepare the destinations one by one, since this may ask for confirmation. The files are
+	// extracted concurrently afterwards.
+
+	placedFiles := make([]manifest.File, 0)
+	jobs := make([]extractJob, 0)
+
+	for _, place := range files.Place {
+		relDest := place.Dest
+		dest := workspaceDir.Join(relDest)
 
-		// Iterate through the files in the archive,
-		// and find the source file.
-		for _, f := range r.File {
-			// Skip directories.
-			if strings.HasSuffix(f.Name, "/") {
-				debugLogger.Debugf("Skipped %v because it is a directory", f.Name)
+		// User data is never replaced, so existing user data is kept as it is.
+		if place.UserData {
+			if _, err := ctx.FS().Lstat(dest.LocalString()); err == nil {
+				placedFile, err := keepUserData(ctx, place, dest)
+				if err != nil {
+					return nil, err
+				}
 
+				placedFiles = append(placedFiles, placedFile)
 				continue
 			}
+		}
 
-			filePath, err := path.Parse(f.Name)
+		// Config files keep user edits instead of being replaced.
+		if place.Config != tooth.NoConfigStrategy {
+			placedFile, err := placeConfigFile(ctx, metadata.ToothRepoPath(), r.Reader, place, dest)
 			if err != nil {
-				return fmt.Errorf("failed to parse file path from %v\n\t%w", f.Name, err)
+				return nil, fmt.Errorf("failed to place config file %v\n\t%w", place.Dest.LocalString(), err)
 			}
 
-			if filePath.Equal(place.Src) {
-				// Open the source file.
-				rc, err := f.Open()
-				if err != nil {
-					return fmt.Errorf("failed to open source file\n\t%w", err)
+			// Side by side, the original is kept with the version, to place it when switching
+			// back to the version.
+			if ctx.SideBySide() {
+				if err := keepVersionConfigFile(ctx, metadata, r.Reader, place); err != nil {
+					return nil, err
 				}
+			}
 
-				fw, err := os.Create(dest.LocalString())
-				if err != nil {
-					return fmt.Errorf("failed to create destination file\n\t%w", err)
-				}
+			placedFiles = append(placedFiles, placedFile)
+			continue
+		}
 
-				// Copy the file.
-				if _, err := io.Copy(fw, rc); err != nil {
-					return fmt.Errorf("failed to copy file\n\t%w", err)
+		// Check if the destination exists.
+		if _, err := ctx.FS().Lstat(dest.LocalString()); err == nil {
+			if !forcePlace {
+				// Ask for confirmation.
+				ctx.Logger().Infof(i18n.T("Destination %v already exists"), relDest.LocalString())
+				if ok, err := ctx.Prompter().Confirm(i18n.T("Do you want to remove? [y/N]"), false); err != nil {
+					return nil, err
+				} else if !ok {
+					return nil, fmt.Errorf("aborted")
 				}
+			}
 
-				// Close the files.
-				rc.Close()
-				fw.Close()
+			ctx.Logger().Infof(i18n.T("Removing destination %v"), relDest.LocalString())
 
-				debugLogger.Debugf("Placed file %v to %v", f.Name, dest.LocalString())
+			// Remove the destination if it exists.
+			if err := ctx.FS().RemoveAll(dest.LocalString()); err != nil {
+				return nil, fmt.Errorf("failed to remove destination %v\n\t%w", relDest.LocalString(), err)
 			}
 		}
+
+		// Create the destination directory.
+		if err := ctx.FS().MkdirAll(filepath.Dir(dest.LocalString()), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create destination directory\n\t%w", err)
+		}
+		debugLogger.Debugf("Created destination directory %v", filepath.Dir(dest.LocalString()))
+
+		for _, f := range archiveFileMap[place.Src.String()] {
+			jobs = append(jobs, extractJob{
+				file:    f,
+				place:   place,
+				relDest: relDest,
+				dest:    dest,
+			})
+		}
 	}
 
-	return nil
+	stopTiming := ctx.Timer().Start(profiling.ExtractPhase)
+	extractedFiles, err := extractFiles(ctx, metadata, jobs)
+	stopTiming()
+	if err != nil {
+		return nil, err
+	}
+
+	placedFiles = append(placedFiles, extractedFiles...)
+
+	return placedFiles, nil
 }
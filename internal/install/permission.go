@@ -0,0 +1,60 @@
+package install
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/path"
+	"github.com/lippkg/lip/internal/tooth"
+	log "github.com/sirupsen/logrus"
+)
+
+// applyFileMode sets the permission bits of a placed file and returns them. The mode of the
+// placement takes precedence. Otherwise, the executable bits recorded in the archive are
+// kept. Windows has no permission bits, so the file is left as it is there and zero is
+// returned.
+func applyFileMode(ctx *context.Context, filePath path.Path, place tooth.FilesPlaceItem,
+	archiveMode os.FileMode) (os.FileMode, error) {
+	debugLogger := ctx.Logger().WithFields(log.Fields{
+		"package": "install",
+		"method":  "applyFileMode",
+	})
+
+	if runtime.GOOS == "windows" {
+		if place.Mode != 0 || place.Executable {
+			debugLogger.Debugf("Skipped setting mode of %v because Windows has no permission bits",
+				filePath.LocalString())
+		}
+
+		return 0, nil
+	}
+
+	fileInfo, err := ctx.FS().Stat(filePath.LocalString())
+	if err != nil {
+		return 0, fmt.Errorf("failed to get file info of %v\n\t%w", filePath.LocalString(), err)
+	}
+
+	mode := place.Mode
+	if mode == 0 {
+		mode = fileInfo.Mode().Perm() | archiveMode.Perm()&0111
+	}
+
+	if place.Executable {
+		// Grant execution to whoever can read the file, like chmod +x.
+		mode |= (mode & 0444) >> 2
+	}
+
+	if mode == fileInfo.Mode().Perm() {
+		return mode, nil
+	}
+
+	if err := ctx.FS().Chmod(filePath.LocalString(), mode); err != nil {
+		return 0, fmt.Errorf("failed to set mode of %v\n\t%w", filePath.LocalString(), err)
+	}
+
+	debugLogger.Debugf("Set mode of %v to %v", filePath.LocalString(), mode)
+
+	return mode, nil
+}
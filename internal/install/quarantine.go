@@ -0,0 +1,146 @@
+package install
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/manifest"
+	"github.com/lippkg/lip/internal/network"
+	"github.com/lippkg/lip/internal/vfs"
+	log "github.com/sirupsen/logrus"
+)
+
+// windowsExecutableExtensions are the extensions of the files Windows checks the Mark of the Web
+// of before running or loading them.
+var windowsExecutableExtensions = map[string]bool{
+	".bat": true,
+	".cmd": true,
+	".com": true,
+	".dll": true,
+	".exe": true,
+	".msi": true,
+	".ps1": true,
+	".scr": true,
+	".vbs": true,
+}
+
+// applyQuarantine marks the executables placed by a tooth as downloaded from the internet, or
+// clears their marks, as the Quarantine configuration says. The marks are the quarantine
+// attribute on macOS, which Gatekeeper checks, and the Mark of the Web on Windows, which
+// SmartScreen checks. Other operating systems have no such marks. By default, the marks are
+// left as they are, but executables already marked are warned about, since they may be blocked
+// when run.
+func applyQuarantine(ctx *context.Context, toothRepoPath string, placedFiles []manifest.File) error {
+	debugLogger := ctx.Logger().WithFields(log.Fields{
+		"package": "install",
+		"method":  "applyQuarantine",
+	})
+
+	// The marks are attributes of files on the file system of the operating system.
+	if !isQuarantineSupported || ctx.FS() != vfs.OS() {
+		return nil
+	}
+
+	quarantine, err := ctx.Quarantine()
+	if err != nil {
+		return err
+	}
+
+	// Paths are relative to the workspace directory, which is the working directory. Placed
+	// files linked from the store or from the directory of a version are marked through the
+	// links.
+	executablePaths := make([]string, 0)
+	for _, placedFile := range placedFiles {
+		if isExecutableFile(placedFile) {
+			executablePaths = append(executablePaths, filepath.FromSlash(placedFile.Path))
+		}
+	}
+
+	if len(executablePaths) == 0 {
+		return nil
+	}
+
+	switch quarantine {
+	case context.ClearQuarantine:
+		for _, executablePath := range executablePaths {
+			if err := clearQuarantine(executablePath); err != nil {
+				return fmt.Errorf("failed to clear quarantine of %v\n\t%w", executablePath, err)
+			}
+		}
+		debugLogger.Debugf("Cleared quarantine of %v executables", len(executablePaths))
+
+	case context.SetQuarantine:
+		// The executables are marked as downloaded from the repository of the tooth.
+		repoPath, _ := network.SplitSubtooth(toothRepoPath)
+		origin := "https://" + repoPath
+
+		for _, executablePath := range executablePaths {
+			if err := setQuarantine(executablePath, origin, ctx.Clock().Now()); err != nil {
+				return fmt.Errorf("failed to set quarantine of %v\n\t%w", executablePath, err)
+			}
+		}
+		debugLogger.Debugf("Set quarantine of %v executables", len(executablePaths))
+
+		switch runtime.GOOS {
+		case "darwin":
+			ctx.Logger().Warnf(i18n.T("Executables of %v are quarantined. Gatekeeper blocks them on their first run unless they are notarized. Allow them in System Settings > Privacy & Security if you trust them."),
+				toothRepoPath)
+		case "windows":
+			ctx.Logger().Warnf(i18n.T("Executables of %v are marked as downloaded from the internet. SmartScreen may warn before running them unless they are signed, and some hosts refuse to load such DLLs."),
+				toothRepoPath)
+		}
+
+	default:
+		quarantinedPaths := make([]string, 0)
+		for _, executablePath := range executablePaths {
+			isQuarantined, err := hasQuarantine(executablePath)
+			if err != nil {
+				return fmt.Errorf("failed to check quarantine of %v\n\t%w", executablePath, err)
+			}
+
+			if isQuarantined {
+				quarantinedPaths = append(quarantinedPaths, executablePath)
+			}
+		}
+
+		if len(quarantinedPaths) == 0 {
+			return nil
+		}
+
+		switch runtime.GOOS {
+		case "darwin":
+			ctx.Logger().Warnf(i18n.T("Executables of %v are quarantined, so Gatekeeper may block them:\n\t%v\nSet Quarantine to clear with lip config and reinstall the tooth if you trust them."),
+				toothRepoPath, strings.Join(quarantinedPaths, "\n\t"))
+		case "windows":
+			ctx.Logger().Warnf(i18n.T("Executables of %v are marked as downloaded from the internet, so SmartScreen may block them:\n\t%v\nSet Quarantine to clear with lip config and reinstall the tooth if you trust them."),
+				toothRepoPath, strings.Join(quarantinedPaths, "\n\t"))
+		}
+	}
+
+	return nil
+}
+
+// ---------------------------------------------------------------------
+
+// isExecutableFile returns whether a placed file is run or loaded as code: on Windows, a file
+// with an extension of executables, and elsewhere, a file with any execution bit.
+func isExecutableFile(placedFile manifest.File) bool {
+	if runtime.GOOS == "windows" {
+		return windowsExecutableExtensions[strings.ToLower(filepath.Ext(placedFile.Path))]
+	}
+
+	if placedFile.Mode == "" {
+		return false
+	}
+
+	mode, err := manifest.ParseMode(placedFile.Mode)
+	if err != nil {
+		return false
+	}
+
+	return mode&0111 != 0
+}
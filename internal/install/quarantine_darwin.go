@@ -0,0 +1,48 @@
+package install
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// isQuarantineSupported is whether the operating system marks files downloaded from the
+// internet.
+const isQuarantineSupported = true
+
+// quarantineAttribute is the extended attribute Gatekeeper checks before running a file.
+const quarantineAttribute = "com.apple.quarantine"
+
+// hasQuarantine returns whether a file has the quarantine attribute.
+func hasQuarantine(filePath string) (bool, error) {
+	_, err := unix.Getxattr(filePath, quarantineAttribute, nil)
+	if errors.Is(err, unix.ENOATTR) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// setQuarantine sets the quarantine attribute of a file, as set by browsers on downloads. The
+// origin is not recorded, since the attribute only refers to it through the database of
+// Launch Services.
+func setQuarantine(filePath string, origin string, downloadTime time.Time) error {
+	// The flags, the time of the download in hex, and the agent that downloaded the file.
+	value := fmt.Sprintf("0081;%08x;lip;", downloadTime.Unix())
+
+	return unix.Setxattr(filePath, quarantineAttribute, []byte(value), 0)
+}
+
+// clearQuarantine removes the quarantine attribute of a file if it has one.
+func clearQuarantine(filePath string) error {
+	err := unix.Removexattr(filePath, quarantineAttribute)
+	if errors.Is(err, unix.ENOATTR) {
+		return nil
+	}
+
+	return err
+}
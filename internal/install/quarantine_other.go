@@ -0,0 +1,23 @@
+//go:build !darwin && !windows
+
+package install
+
+import "time"
+
+// isQuarantineSupported is whether the operating system marks files downloaded from the
+// internet.
+const isQuarantineSupported = false
+
+// There are no marks on this operating system, so they are neither found nor set.
+
+func hasQuarantine(filePath string) (bool, error) {
+	return false, nil
+}
+
+func setQuarantine(filePath string, origin string, downloadTime time.Time) error {
+	return nil
+}
+
+func clearQuarantine(filePath string) error {
+	return nil
+}
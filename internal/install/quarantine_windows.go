@@ -0,0 +1,47 @@
+package install
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// isQuarantineSupported is whether the operating system marks files downloaded from the
+// internet.
+const isQuarantineSupported = true
+
+// zoneIdentifierStream is the alternate data stream holding the Mark of the Web of a file.
+const zoneIdentifierStream = ":Zone.Identifier"
+
+// internetZoneID is the ID of the Internet zone in the Mark of the Web.
+const internetZoneID = 3
+
+// hasQuarantine returns whether a file has the Mark of the Web.
+func hasQuarantine(filePath string) (bool, error) {
+	_, err := os.Stat(filePath + zoneIdentifierStream)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// setQuarantine sets the Mark of the Web of a file, as set by browsers on downloads, with the
+// URL the file is from. Windows does not record the time of the download.
+func setQuarantine(filePath string, origin string, downloadTime time.Time) error {
+	content := fmt.Sprintf("[ZoneTransfer]\r\nZoneId=%v\r\nHostUrl=%v\r\n", internetZoneID, origin)
+
+	return os.WriteFile(filePath+zoneIdentifierStream, []byte(content), 0644)
+}
+
+// clearQuarantine removes the Mark of the Web of a file if it has one, like Unblock-File.
+func clearQuarantine(filePath string) error {
+	err := os.Remove(filePath + zoneIdentifierStream)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
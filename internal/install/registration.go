@@ -0,0 +1,287 @@
+package install
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"reflect"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/tooth"
+	log "github.com/sirupsen/logrus"
+)
+
+// jsonMember is a member of a JSON object, with its value kept as read.
+type jsonMember struct {
+	key   string
+	value json.RawMessage
+}
+
+// registerTooth adds the registrations of a tooth to the configuration files of the workspace,
+// creating the files if missing. Registrations already present are left as they are.
+func registerTooth(ctx *context.Context, metadata tooth.Metadata) error {
+	debugLogger := ctx.Logger().WithFields(log.Fields{
+		"package": "install",
+		"method":  "registerTooth",
+	})
+
+	registrations, err := metadata.Registrations()
+	if err != nil {
+		return fmt.Errorf("failed to get registrations\n\t%w", err)
+	}
+
+	for _, registration := range registrations {
+		if err := updateRegistrationFile(ctx, registration, true); err != nil {
+			return fmt.Errorf("failed to register %v in %v\n\t%w", metadata.ToothRepoPath(),
+				registration.File.LocalString(), err)
+		}
+
+		debugLogger.Debugf("Registered %v in %v", metadata.ToothRepoPath(), registration.File.LocalString())
+	}
+
+	return nil
+}
+
+// unregisterTooth removes the registrations of a tooth from the configuration files of the
+// workspace. Entries changed since they were registered are left, since the user owns them.
+func unregisterTooth(ctx *context.Context, metadata tooth.Metadata) error {
+	debugLogger := ctx.Logger().WithFields(log.Fields{
+		"package": "install",
+		"method":  "unregisterTooth",
+	})
+
+	registrations, err := metadata.Registrations()
+	if err != nil {
+		return fmt.Errorf("failed to get registrations\n\t%w", err)
+	}
+
+	for _, registration := range registrations {
+		if err := updateRegistrationFile(ctx, registration, false); err != nil {
+			return fmt.Errorf("failed to unregister %v from %v\n\t%w", metadata.ToothRepoPath(),
+				registration.File.LocalString(), err)
+		}
+
+		debugLogger.Debugf("Unregistered %v from %v", metadata.ToothRepoPath(), registration.File.LocalString())
+	}
+
+	return nil
+}
+
+// ---------------------------------------------------------------------
+
+// updateRegistrationFile adds the registration to its configuration file if register is set,
+// and removes it otherwise. The file is written only if it changes, keeping the order of its
+// members and elements.
+func updateRegistrationFile(ctx *context.Context, registration tooth.Registration, register bool) error {
+	fileName := registration.File.LocalString()
+
+	data, err := ctx.FS().ReadFile(fileName)
+	if os.IsNotExist(err) {
+		if !register {
+			return nil
+		}
+
+		data = nil
+	} else if err != nil {
+		return fmt.Errorf("failed to read file\n\t%w", err)
+	}
+
+	value, err := json.Marshal(registration.Value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal value\n\t%w", err)
+	}
+
+	var updated []byte
+	var changed bool
+	if registration.Key == "" {
+		updated, changed, err = updateRegistrationArray(data, value, register)
+	} else {
+		updated, changed, err = updateRegistrationObject(data, registration.Key, value, register)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !changed {
+		return nil
+	}
+
+	indented := &bytes.Buffer{}
+	if err := json.Indent(indented, updated, "", "    "); err != nil {
+		return fmt.Errorf("failed to indent file\n\t%w", err)
+	}
+	indented.WriteByte('\n')
+
+	perm := fs.FileMode(0644)
+	if fileInfo, err := ctx.FS().Stat(fileName); err == nil {
+		perm = fileInfo.Mode().Perm()
+	}
+
+	dir, err := registration.File.Dir()
+	if err != nil {
+		return fmt.Errorf("failed to get directory of file\n\t%w", err)
+	}
+
+	if !dir.IsEmpty() {
+		if err := ctx.FS().MkdirAll(dir.LocalString(), 0755); err != nil {
+			return fmt.Errorf("failed to create directory %v\n\t%w", dir.LocalString(), err)
+		}
+	}
+
+	if err := ctx.FS().WriteFile(fileName, indented.Bytes(), perm); err != nil {
+		return fmt.Errorf("failed to write file\n\t%w", err)
+	}
+
+	return nil
+}
+
+// updateRegistrationArray adds value to the JSON array in data if register is set, unless an
+// equal element exists, and removes the elements equal to value otherwise. Empty data is an
+// empty array.
+func updateRegistrationArray(data []byte, value []byte, register bool) ([]byte, bool, error) {
+	elements := make([]json.RawMessage, 0)
+	if len(bytes.TrimSpace(data)) != 0 {
+		if err := json.Unmarshal(data, &elements); err != nil {
+			return nil, false, fmt.Errorf("expected a JSON array\n\t%w", err)
+		}
+	}
+
+	kept := make([]json.RawMessage, 0, len(elements))
+	for _, element := range elements {
+		equal, err := isJSONEqual(element, value)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if equal && register {
+			return nil, false, nil
+		}
+
+		if !equal {
+			kept = append(kept, element)
+		}
+	}
+
+	if register {
+		kept = append(kept, value)
+	} else if len(kept) == len(elements) {
+		return nil, false, nil
+	}
+
+	updated, err := json.Marshal(kept)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to marshal array\n\t%w", err)
+	}
+
+	return updated, true, nil
+}
+
+// updateRegistrationObject sets key of the JSON object in data to value if register is set,
+// and removes key if it is still value otherwise. Empty data is an empty object.
+func updateRegistrationObject(data []byte, key string, value []byte, register bool) ([]byte, bool, error) {
+	members, err := decodeJSONObject(data)
+	if err != nil {
+		return nil, false, err
+	}
+
+	index := -1
+	for i, member := range members {
+		if member.key == key {
+			index = i
+		}
+	}
+
+	if index != -1 {
+		equal, err := isJSONEqual(members[index].value, value)
+		if err != nil {
+			return nil, false, err
+		}
+
+		switch {
+		case register && equal, !register && !equal:
+			return nil, false, nil
+		case register:
+			members[index].value = value
+		default:
+			members = append(members[:index], members[index+1:]...)
+		}
+	} else if register {
+		members = append(members, jsonMember{key: key, value: value})
+	} else {
+		return nil, false, nil
+	}
+
+	updated := &bytes.Buffer{}
+	updated.WriteByte('{')
+	for i, member := range members {
+		if i != 0 {
+			updated.WriteByte(',')
+		}
+
+		keyBytes, err := json.Marshal(member.key)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to marshal key\n\t%w", err)
+		}
+
+		updated.Write(keyBytes)
+		updated.WriteByte(':')
+		updated.Write(member.value)
+	}
+	updated.WriteByte('}')
+
+	return updated.Bytes(), true, nil
+}
+
+// decodeJSONObject decodes the members of the JSON object in data in their order. Empty data
+// is an empty object.
+func decodeJSONObject(data []byte) ([]jsonMember, error) {
+	members := make([]jsonMember, 0)
+	if len(bytes.TrimSpace(data)) == 0 {
+		return members, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode JSON\n\t%w", err)
+	}
+
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return nil, fmt.Errorf("expected a JSON object")
+	}
+
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode JSON\n\t%w", err)
+		}
+
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, fmt.Errorf("failed to decode JSON\n\t%w", err)
+		}
+
+		// Keys of objects are always strings.
+		members = append(members, jsonMember{key: token.(string), value: value})
+	}
+
+	return members, nil
+}
+
+// isJSONEqual returns whether two JSON values are equal regardless of formatting and the order
+// of members.
+func isJSONEqual(a []byte, b []byte) (bool, error) {
+	var aValue, bValue interface{}
+	if err := json.Unmarshal(a, &aValue); err != nil {
+		return false, fmt.Errorf("failed to decode JSON\n\t%w", err)
+	}
+
+	if err := json.Unmarshal(b, &bValue); err != nil {
+		return false, fmt.Errorf("failed to decode JSON\n\t%w", err)
+	}
+
+	return reflect.DeepEqual(aValue, bValue), nil
+}
@@ -0,0 +1,50 @@
+package install
+
+import (
+	"fmt"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/tooth"
+	"github.com/lippkg/lip/internal/workspace"
+	log "github.com/sirupsen/logrus"
+)
+
+// RemapDestinations moves the destinations of the files of a tooth as the remaps of the
+// workspace manifest declare. It returns the metadata unchanged and no remaps if there is no
+// workspace manifest or the tooth is not remapped.
+func RemapDestinations(ctx *context.Context, metadata tooth.Metadata) (tooth.Metadata, map[string]string, error) {
+	debugLogger := ctx.Logger().WithFields(log.Fields{
+		"package": "install",
+		"method":  "RemapDestinations",
+	})
+
+	isManifestPresent, err := workspace.IsManifestPresent(ctx.FS())
+	if err != nil {
+		return tooth.Metadata{}, nil, err
+	}
+
+	if !isManifestPresent {
+		return metadata, nil, nil
+	}
+
+	workspaceManifest, err := workspace.LoadManifest(ctx.FS())
+	if err != nil {
+		return tooth.Metadata{}, nil, err
+	}
+
+	remaps := workspaceManifest.RemapsOf(metadata.ToothRepoPath())
+	if len(remaps) == 0 {
+		return metadata, nil, nil
+	}
+
+	remappedMetadata, err := metadata.ToDestinationsRemapped(remaps)
+	if err != nil {
+		return tooth.Metadata{}, nil, fmt.Errorf("failed to remap destinations of %v\n\t%w", metadata.ToothRepoPath(), err)
+	}
+
+	for from, to := range remaps {
+		debugLogger.Debugf("Remapped %v to %v for %v", from, to, metadata.ToothRepoPath())
+	}
+
+	return remappedMetadata, remaps, nil
+}
@@ -0,0 +1,491 @@
+package install
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/manifest"
+	"github.com/lippkg/lip/internal/path"
+	"github.com/lippkg/lip/internal/tooth"
+	log "github.com/sirupsen/logrus"
+)
+
+// Each version of a tooth installed side by side is kept in a directory named by the version,
+// with its metadata, its manifest and its files, e.g. .lip/versions/<tooth>/1.2.0/files/<dest>.
+// The current link in the directory of the tooth points to the active version, and the placed
+// files are symlinks through it, so switching versions only changes the link.
+const (
+	currentVersionLinkName  = "current"
+	versionFilesDirName     = "files"
+	versionManifestFileName = "manifest.json"
+	versionMetadataFileName = "tooth.json"
+)
+
+// Activate installs a version of a tooth installed side by side before, from the files kept in
+// its directory, without the tooth archive. The tooth must not be installed.
+func Activate(ctx *context.Context, toothRepoPath string, version semver.Version, yes bool) error {
+	debugLogger := ctx.Logger().WithFields(log.Fields{
+		"package": "install",
+		"method":  "Activate",
+	})
+
+	commandEnvirons, err := GetCommandEnvirons(ctx)
+	if err != nil {
+		return err
+	}
+
+	// 1. Check if the tooth is already installed.
+
+	if installed, err := tooth.IsInstalled(ctx, toothRepoPath); err != nil {
+		return fmt.Errorf("failed to check if tooth is installed\n\t%w", err)
+	} else if installed {
+		return fmt.Errorf("tooth %v is already installed", toothRepoPath)
+	}
+
+	metadata, versionManifest, err := getVersionInfo(ctx, toothRepoPath, version)
+	if err != nil {
+		return err
+	}
+
+	// 2. Run pre-install commands.
+
+	if err := runCommands(ctx, metadata.Commands().PreInstall, commandEnvirons); err != nil {
+		return fmt.Errorf("failed to run pre-install commands\n\t%w", err)
+	}
+	debugLogger.Debug("Ran pre-install commands")
+
+	// 3. Link files and activate the version.
+
+	if err := linkVersionFiles(ctx, toothRepoPath, version, versionManifest.Files, yes); err != nil {
+		return fmt.Errorf("failed to link files\n\t%w", err)
+	}
+
+	if err := setCurrentVersion(ctx, toothRepoPath, version); err != nil {
+		return err
+	}
+	debugLogger.Debugf("Activated %v@%v", toothRepoPath, version)
+
+	// 4. Run post-install commands.
+
+	if err := runCommands(ctx, metadata.Commands().PostInstall, commandEnvirons); err != nil {
+		return fmt.Errorf("failed to run post-install commands\n\t%w", err)
+	}
+	debugLogger.Debug("Ran post-install commands")
+
+	// 5. Create metadata file and record the manifest of placed files.
+
+	if err := writeMetadataFile(ctx, metadata); err != nil {
+		return err
+	}
+
+	if err := manifest.Save(ctx, versionManifest); err != nil {
+		return fmt.Errorf("failed to save manifest\n\t%w", err)
+	}
+
+	return nil
+}
+
+// ListVersions returns the versions of a tooth installed side by side, in ascending order.
+func ListVersions(ctx *context.Context, toothRepoPath string) (semver.Versions, error) {
+	toothVersionsDir, err := getToothVersionsDir(ctx, toothRepoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := ctx.FS().ReadDir(toothVersionsDir.LocalString())
+	if os.IsNotExist(err) {
+		return semver.Versions{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read versions directory %v\n\t%w", toothVersionsDir.LocalString(), err)
+	}
+
+	versions := make(semver.Versions, 0)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		version, err := semver.Parse(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		versions = append(versions, version)
+	}
+
+	semver.Sort(versions)
+
+	return versions, nil
+}
+
+// RemoveVersion removes a version of a tooth installed side by side.
+func RemoveVersion(ctx *context.Context, toothRepoPath string, version semver.Version) error {
+	versionDir, err := getVersionDir(ctx, toothRepoPath, version)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.FS().RemoveAll(versionDir.LocalString()); err != nil {
+		return fmt.Errorf("failed to remove version directory %v\n\t%w", versionDir.LocalString(), err)
+	}
+
+	return nil
+}
+
+// RemoveVersions removes all versions of a tooth installed side by side. It does nothing if
+// the tooth was not installed side by side.
+func RemoveVersions(ctx *context.Context, toothRepoPath string) error {
+	toothVersionsDir, err := getToothVersionsDir(ctx, toothRepoPath)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.FS().RemoveAll(toothVersionsDir.LocalString()); err != nil {
+		return fmt.Errorf("failed to remove versions directory %v\n\t%w", toothVersionsDir.LocalString(), err)
+	}
+
+	return nil
+}
+
+// ---------------------------------------------------------------------
+
+// extractVersionFile extracts a file of a version of a tooth installed side by side to the
+// directory of the version, links it from its destination through the current link, and
+// returns the placed file.
+func extractVersionFile(ctx *context.Context, metadata tooth.Metadata, job extractJob) (manifest.File, error) {
+	versionFilePath, err := getVersionFilePath(ctx, metadata.ToothRepoPath(), metadata.Version(), job.relDest)
+	if err != nil {
+		return manifest.File{}, err
+	}
+
+	if err := ctx.FS().MkdirAll(filepath.Dir(versionFilePath.LocalString()), 0755); err != nil {
+		return manifest.File{}, fmt.Errorf("failed to create version directory\n\t%w", err)
+	}
+
+	checksum, err := extractToFile(ctx, job.file, versionFilePath)
+	if err != nil {
+		return manifest.File{}, err
+	}
+
+	mode, err := applyFileMode(ctx, versionFilePath, job.place, job.file.Mode())
+	if err != nil {
+		return manifest.File{}, err
+	}
+
+	currentFilePath, err := getCurrentFilePath(ctx, metadata.ToothRepoPath(), job.relDest)
+	if err != nil {
+		return manifest.File{}, err
+	}
+
+	if err := linkFile(ctx, currentFilePath, job.dest); err != nil {
+		return manifest.File{}, fmt.Errorf("failed to link file, installing side by side requires symlinks\n\t%w",
+			err)
+	}
+
+	return manifest.File{
+		Path:   job.relDest.String(),
+		Source: job.place.Src.String(),
+		SHA256: checksum,
+		Mode:   manifest.FormatMode(mode),
+	}, nil
+}
+
+// getCurrentFilePath returns the path through the current link of a file placed by a tooth
+// installed side by side, which placed files link to.
+func getCurrentFilePath(ctx *context.Context, toothRepoPath string, relDest path.Path) (path.Path, error) {
+	toothVersionsDir, err := getToothVersionsDir(ctx, toothRepoPath)
+	if err != nil {
+		return path.Path{}, err
+	}
+
+	return toothVersionsDir.Join(path.MustParse(currentVersionLinkName)).Join(
+		path.MustParse(versionFilesDirName)).Join(relDest), nil
+}
+
+// getToothVersionsDir returns the directory where the versions of a tooth installed side by
+// side are kept.
+func getToothVersionsDir(ctx *context.Context, toothRepoPath string) (path.Path, error) {
+	versionsDir, err := ctx.VersionsDir()
+	if err != nil {
+		return path.Path{}, fmt.Errorf("failed to get versions directory\n\t%w", err)
+	}
+
+	return versionsDir.Join(path.MustParse(url.QueryEscape(toothRepoPath))), nil
+}
+
+// getVersionDir returns the directory of a version of a tooth installed side by side.
+func getVersionDir(ctx *context.Context, toothRepoPath string, version semver.Version) (path.Path, error) {
+	toothVersionsDir, err := getToothVersionsDir(ctx, toothRepoPath)
+	if err != nil {
+		return path.Path{}, err
+	}
+
+	return toothVersionsDir.Join(path.MustParse(version.String())), nil
+}
+
+// getVersionFilePath returns the path where a file placed by a version of a tooth installed
+// side by side is kept.
+func getVersionFilePath(ctx *context.Context, toothRepoPath string, version semver.Version,
+	relDest path.Path) (path.Path, error) {
+	versionDir, err := getVersionDir(ctx, toothRepoPath, version)
+	if err != nil {
+		return path.Path{}, err
+	}
+
+	return versionDir.Join(path.MustParse(versionFilesDirName)).Join(relDest), nil
+}
+
+// getVersionInfo reads the metadata and the manifest kept for a version of a tooth installed
+// side by side.
+func getVersionInfo(ctx *context.Context, toothRepoPath string, version semver.Version) (tooth.Metadata,
+	manifest.Manifest, error) {
+	versionDir, err := getVersionDir(ctx, toothRepoPath, version)
+	if err != nil {
+		return tooth.Metadata{}, manifest.Manifest{}, err
+	}
+
+	// Convert to strings, since joining paths may reuse their underlying arrays.
+	metadataPathStr := versionDir.Join(path.MustParse(versionMetadataFileName)).LocalString()
+	manifestPathStr := versionDir.Join(path.MustParse(versionManifestFileName)).LocalString()
+
+	metadataBytes, err := ctx.FS().ReadFile(metadataPathStr)
+	if os.IsNotExist(err) {
+		return tooth.Metadata{}, manifest.Manifest{}, errcode.Errorf(errcode.NotInstalled,
+			"%v@%v is not installed side by side", toothRepoPath, version)
+	} else if err != nil {
+		return tooth.Metadata{}, manifest.Manifest{}, fmt.Errorf("failed to read %v\n\t%w", metadataPathStr, err)
+	}
+
+	metadata, err := tooth.MakeMetadata(metadataBytes)
+	if err != nil {
+		return tooth.Metadata{}, manifest.Manifest{}, fmt.Errorf("failed to parse %v\n\t%w", metadataPathStr, err)
+	}
+
+	manifestBytes, err := ctx.FS().ReadFile(manifestPathStr)
+	if err != nil {
+		return tooth.Metadata{}, manifest.Manifest{}, fmt.Errorf("failed to read %v\n\t%w", manifestPathStr, err)
+	}
+
+	var versionManifest manifest.Manifest
+	if err := json.Unmarshal(manifestBytes, &versionManifest); err != nil {
+		return tooth.Metadata{}, manifest.Manifest{}, fmt.Errorf("failed to parse %v\n\t%w", manifestPathStr, err)
+	}
+
+	return metadata, versionManifest, nil
+}
+
+// keepVersionConfigFile keeps the original of a config file placed by a version of a tooth
+// installed side by side in the directory of the version.
+func keepVersionConfigFile(ctx *context.Context, metadata tooth.Metadata, r *zip.Reader,
+	place tooth.FilesPlaceItem) error {
+	content, err := readFileInArchive(r, place.Src)
+	if err != nil {
+		return fmt.Errorf("failed to read source file %v\n\t%w", place.Src, err)
+	}
+
+	versionFilePath, err := getVersionFilePath(ctx, metadata.ToothRepoPath(), metadata.Version(), place.Dest)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.FS().MkdirAll(filepath.Dir(versionFilePath.LocalString()), 0755); err != nil {
+		return fmt.Errorf("failed to create version directory\n\t%w", err)
+	}
+
+	if err := ctx.FS().WriteFile(versionFilePath.LocalString(), content, 0644); err != nil {
+		return fmt.Errorf("failed to write %v\n\t%w", versionFilePath.LocalString(), err)
+	}
+
+	return nil
+}
+
+// linkVersionFiles places the files of a version of a tooth installed side by side. Files are
+// linked through the current link, except config files and user data, which are copied if they
+// are missing and kept otherwise.
+func linkVersionFiles(ctx *context.Context, toothRepoPath string, version semver.Version,
+	files []manifest.File, forcePlace bool) error {
+	debugLogger := ctx.Logger().WithFields(log.Fields{
+		"package": "install",
+		"method":  "linkVersionFiles",
+	})
+
+	workspaceDir, err := ctx.WorkspaceDir()
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		relDest, err := path.Parse(file.Path)
+		if err != nil {
+			return fmt.Errorf("failed to parse file path %v\n\t%w", file.Path, err)
+		}
+
+		versionFilePath, err := getVersionFilePath(ctx, toothRepoPath, version, relDest)
+		if err != nil {
+			return err
+		}
+
+		dest := workspaceDir.Join(relDest)
+
+		if err := ctx.FS().MkdirAll(filepath.Dir(dest.LocalString()), 0755); err != nil {
+			return fmt.Errorf("failed to create destination directory\n\t%w", err)
+		}
+
+		if file.UserData {
+			if _, err := ctx.FS().Lstat(dest.LocalString()); err == nil {
+				debugLogger.Debugf("Kept user data %v", dest.LocalString())
+				continue
+			}
+
+			// User data kept from before the version was installed has no copy to place.
+			if _, err := ctx.FS().Stat(versionFilePath.LocalString()); os.IsNotExist(err) {
+				continue
+			}
+
+			if err := copyFile(ctx, versionFilePath, dest); err != nil {
+				return fmt.Errorf("failed to copy user data %v\n\t%w", relDest.LocalString(), err)
+			}
+
+			continue
+		}
+
+		if file.Config != "" {
+			if _, err := ctx.FS().Lstat(dest.LocalString()); err == nil {
+				debugLogger.Debugf("Kept config file %v", dest.LocalString())
+				continue
+			}
+
+			content, err := ctx.FS().ReadFile(versionFilePath.LocalString())
+			if err != nil {
+				return fmt.Errorf("failed to read config file %v\n\t%w", versionFilePath.LocalString(), err)
+			}
+
+			if err := ctx.FS().WriteFile(dest.LocalString(), content, 0644); err != nil {
+				return fmt.Errorf("failed to write config file\n\t%w", err)
+			}
+
+			if err := manifest.SaveConfigBase(ctx, toothRepoPath, file.Path, content); err != nil {
+				return fmt.Errorf("failed to keep original of config file\n\t%w", err)
+			}
+
+			continue
+		}
+
+		if _, err := ctx.FS().Lstat(dest.LocalString()); err == nil {
+			if !forcePlace {
+				// Ask for confirmation.
+				ctx.Logger().Infof(i18n.T("Destination %v already exists"), relDest.LocalString())
+				if ok, err := ctx.Prompter().Confirm(i18n.T("Do you want to remove? [y/N]"), false); err != nil {
+					return err
+				} else if !ok {
+					return fmt.Errorf("aborted")
+				}
+			}
+
+			ctx.Logger().Infof(i18n.T("Removing destination %v"), relDest.LocalString())
+
+			if err := ctx.FS().RemoveAll(dest.LocalString()); err != nil {
+				return fmt.Errorf("failed to remove destination %v\n\t%w", relDest.LocalString(), err)
+			}
+		}
+
+		currentFilePath, err := getCurrentFilePath(ctx, toothRepoPath, relDest)
+		if err != nil {
+			return err
+		}
+
+		if err := linkFile(ctx, currentFilePath, dest); err != nil {
+			return fmt.Errorf("failed to link %v, installing side by side requires symlinks\n\t%w",
+				relDest.LocalString(), err)
+		}
+		debugLogger.Debugf("Linked %v to %v", dest.LocalString(), currentFilePath.LocalString())
+	}
+
+	return nil
+}
+
+// prepareVersionDir empties the directory of a version of a tooth to install side by side.
+func prepareVersionDir(ctx *context.Context, toothRepoPath string, version semver.Version) error {
+	versionDir, err := getVersionDir(ctx, toothRepoPath, version)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.FS().RemoveAll(versionDir.LocalString()); err != nil {
+		return fmt.Errorf("failed to remove version directory %v\n\t%w", versionDir.LocalString(), err)
+	}
+
+	if err := ctx.FS().MkdirAll(versionDir.LocalString(), 0755); err != nil {
+		return fmt.Errorf("failed to create version directory %v\n\t%w", versionDir.LocalString(), err)
+	}
+
+	return nil
+}
+
+// saveVersionInfo keeps the metadata and the manifest of a version of a tooth installed side by
+// side in its directory, to activate it again later.
+func saveVersionInfo(ctx *context.Context, metadata tooth.Metadata, versionManifest manifest.Manifest) error {
+	versionDir, err := getVersionDir(ctx, metadata.ToothRepoPath(), metadata.Version())
+	if err != nil {
+		return err
+	}
+
+	metadataBytes, err := metadata.MarshalJSON()
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata\n\t%w", err)
+	}
+
+	manifestBytes, err := json.MarshalIndent(versionManifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest\n\t%w", err)
+	}
+
+	// Convert to strings, since joining paths may reuse their underlying arrays.
+	metadataPathStr := versionDir.Join(path.MustParse(versionMetadataFileName)).LocalString()
+	manifestPathStr := versionDir.Join(path.MustParse(versionManifestFileName)).LocalString()
+
+	if err := ctx.FS().WriteFile(metadataPathStr, metadataBytes, 0644); err != nil {
+		return fmt.Errorf("failed to write %v\n\t%w", metadataPathStr, err)
+	}
+
+	if err := ctx.FS().WriteFile(manifestPathStr, manifestBytes, 0644); err != nil {
+		return fmt.Errorf("failed to write %v\n\t%w", manifestPathStr, err)
+	}
+
+	return nil
+}
+
+// setCurrentVersion points the current link of a tooth installed side by side to a version.
+// The link is relative, so that the workspace can be moved.
+func setCurrentVersion(ctx *context.Context, toothRepoPath string, version semver.Version) error {
+	toothVersionsDir, err := getToothVersionsDir(ctx, toothRepoPath)
+	if err != nil {
+		return err
+	}
+
+	// Replace the link atomically, so that the placed files always resolve.
+	linkPathStr := toothVersionsDir.Join(path.MustParse(currentVersionLinkName)).LocalString()
+	tempLinkPathStr := linkPathStr + ".tmp"
+
+	ctx.FS().Remove(tempLinkPathStr)
+
+	if err := ctx.FS().Symlink(version.String(), tempLinkPathStr); err != nil {
+		return fmt.Errorf("failed to link current version, installing side by side requires symlinks\n\t%w",
+			err)
+	}
+
+	if err := ctx.FS().Rename(tempLinkPathStr, linkPathStr); err != nil {
+		ctx.FS().Remove(tempLinkPathStr)
+		return fmt.Errorf("failed to activate version %v of %v\n\t%w", version, toothRepoPath, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package install
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/path"
+)
+
+// copyFile copies a file with its permission bits.
+func copyFile(ctx *context.Context, src path.Path, dest path.Path) error {
+	r, err := ctx.FS().Open(src.LocalString())
+	if err != nil {
+		return fmt.Errorf("failed to open file %v\n\t%w", src.LocalString(), err)
+	}
+	defer r.Close()
+
+	w, err := ctx.FS().Create(dest.LocalString())
+	if err != nil {
+		return fmt.Errorf("failed to create file %v\n\t%w", dest.LocalString(), err)
+	}
+	defer w.Close()
+
+	if _, err := io.Copy(w, r); err != nil {
+		return fmt.Errorf("failed to copy file %v to %v\n\t%w", src.LocalString(), dest.LocalString(), err)
+	}
+
+	// Keep the permission bits, e.g. the executable bits.
+	fileInfo, err := r.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to get file info of %v\n\t%w", src.LocalString(), err)
+	}
+
+	if err := ctx.FS().Chmod(dest.LocalString(), fileInfo.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to set mode of %v\n\t%w", dest.LocalString(), err)
+	}
+
+	return nil
+}
+
+// getStorePath returns the path in the store where a file placed by a tooth is kept in
+// symlink mode.
+func getStorePath(ctx *context.Context, toothRepoPath string, relDest path.Path) (path.Path, error) {
+	toothStoreDir, err := getToothStoreDir(ctx, toothRepoPath)
+	if err != nil {
+		return path.Path{}, err
+	}
+
+	return toothStoreDir.Join(relDest), nil
+}
+
+// getToothStoreDir returns the directory in the store where the files of a tooth are kept.
+func getToothStoreDir(ctx *context.Context, toothRepoPath string) (path.Path, error) {
+	storeDir, err := ctx.StoreDir()
+	if err != nil {
+		return path.Path{}, fmt.Errorf("failed to get store directory\n\t%w", err)
+	}
+
+	return storeDir.Join(path.MustParse(url.QueryEscape(toothRepoPath))), nil
+}
+
+// linkFile creates a symlink at dest pointing to the absolute path of src.
+func linkFile(ctx *context.Context, src path.Path, dest path.Path) error {
+	absSrc, err := filepath.Abs(src.LocalString())
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path of %v\n\t%w", src.LocalString(), err)
+	}
+
+	return ctx.FS().Symlink(absSrc, dest.LocalString())
+}
+
+// removeToothStore removes the files of a tooth kept in the store. It does nothing if the
+// tooth has no files in the store.
+func removeToothStore(ctx *context.Context, toothRepoPath string) error {
+	toothStoreDir, err := getToothStoreDir(ctx, toothRepoPath)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.FS().RemoveAll(toothStoreDir.LocalString()); err != nil {
+		return fmt.Errorf("failed to remove store directory %v\n\t%w", toothStoreDir.LocalString(), err)
+	}
+
+	return nil
+}
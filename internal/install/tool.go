@@ -0,0 +1,93 @@
+package install
+
+import (
+	gocontext "context"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/tooth"
+)
+
+// toolCommandTimeout is how long the command detecting a tool may run.
+const toolCommandTimeout = 30 * time.Second
+
+// toolVersionPattern matches a version in the output of the command detecting a tool, e.g.
+// 8.0.1 in "Microsoft.NETCore.App 8.0.1 [...]" or 14.38.33130.00 in a registry value.
+var toolVersionPattern = regexp.MustCompile(`\d+(?:\.\d+)+`)
+
+// CheckTool runs the command detecting a tool required by a tooth, with the environment
+// variables on top of those of lip, see GetCommandEnvirons. It fails if the command fails, or if
+// no version in its output satisfies the version range of the tool.
+func CheckTool(goCtx gocontext.Context, tool tooth.Tool, environs map[string]string) error {
+	commandCtx, cancel := gocontext.WithTimeout(goCtx, toolCommandTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.CommandContext(commandCtx, "cmd", "/C", tool.Command)
+	default:
+		cmd = exec.CommandContext(commandCtx, "sh", "-c", tool.Command)
+	}
+
+	cmd.Env = appendEnvirons(os.Environ(), environs)
+
+	// Some tools print their versions to stderr, e.g. java -version.
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v is not found: command %v failed: %v", tool.Name, tool.Command, err)
+	}
+
+	if tool.Version == nil {
+		return nil
+	}
+
+	versions := parseToolVersions(string(output))
+	for _, version := range versions {
+		if tool.Version(version) {
+			return nil
+		}
+	}
+
+	if len(versions) == 0 {
+		return fmt.Errorf("%v %v is required, but no version is found in the output of %v", tool.Name,
+			tool.VersionAsString, tool.Command)
+	}
+
+	versionStrings := make([]string, 0, len(versions))
+	for _, version := range versions {
+		versionStrings = append(versionStrings, version.String())
+	}
+
+	return fmt.Errorf("%v %v is required, but only %v is found", tool.Name, tool.VersionAsString,
+		strings.Join(versionStrings, ", "))
+}
+
+// ---------------------------------------------------------------------
+
+// parseToolVersions returns the versions in the output of the command detecting a tool. Only
+// the first three parts of a version count, e.g. 14.38.33130.00 is 14.38.33130.
+func parseToolVersions(output string) []semver.Version {
+	versions := make([]semver.Version, 0)
+	for _, match := range toolVersionPattern.FindAllString(output, -1) {
+		parts := strings.Split(match, ".")
+		if len(parts) > 3 {
+			parts = parts[:3]
+		}
+
+		version, err := semver.ParseTolerant(strings.Join(parts, "."))
+		if err != nil {
+			continue
+		}
+
+		versions = append(versions, version)
+	}
+
+	return versions
+}
@@ -2,34 +2,26 @@ package install
 
 import (
 	"fmt"
-	"net/url"
 	"os"
 
 	"github.com/lippkg/lip/internal/context"
-	"github.com/lippkg/lip/internal/path"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/manifest"
+	"github.com/lippkg/lip/internal/statedb"
 	"github.com/lippkg/lip/internal/tooth"
 
 	log "github.com/sirupsen/logrus"
 )
 
 func Uninstall(ctx *context.Context, toothRepoPath string) error {
-	debugLogger := log.WithFields(log.Fields{
+	debugLogger := ctx.Logger().WithFields(log.Fields{
 		"package": "install",
 		"method":  "Uninstall",
 	})
 
-	commandEnvirons := make(map[string]string)
-
-	proxyURL, err := ctx.ProxyURL()
+	commandEnvirons, err := GetCommandEnvirons(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to get proxy URL\n\t%w", err)
-	}
-
-	if proxyURL.String() != "" {
-		commandEnvirons = map[string]string{
-			"HTTP_PROXY":  proxyURL.String(),
-			"HTTPS_PROXY": proxyURL.String(),
-		}
+		return err
 	}
 
 	metadata, err := tooth.GetMetadata(ctx, toothRepoPath)
@@ -39,12 +31,19 @@ func Uninstall(ctx *context.Context, toothRepoPath string) error {
 
 	// 1. Run pre-uninstall commands.
 
-	if err := runCommands(metadata.Commands().PreUninstall, commandEnvirons); err != nil {
+	if err := runCommands(ctx, metadata.Commands().PreUninstall, commandEnvirons); err != nil {
 		return fmt.Errorf("failed to run pre-uninstall commands\n\t%w", err)
 	}
 	debugLogger.Debug("Ran pre-uninstall commands")
 
-	// 2. Delete files.
+	// 2. Unregister the tooth and delete files.
+
+	// Unregistered before deleting its files, so that the tooth is never enabled while
+	// incomplete.
+	if err := unregisterTooth(ctx, metadata); err != nil {
+		return err
+	}
+	debugLogger.Debug("Unregistered tooth")
 
 	if err := removeToothFiles(ctx, metadata); err != nil {
 		return fmt.Errorf("failed to delete files\n\t%w", err)
@@ -53,54 +52,56 @@ func Uninstall(ctx *context.Context, toothRepoPath string) error {
 
 	// 3. Run post-uninstall commands.
 
-	if err := runCommands(metadata.Commands().PostUninstall, commandEnvirons); err != nil {
+	if err := runCommands(ctx, metadata.Commands().PostUninstall, commandEnvirons); err != nil {
 		return fmt.Errorf("failed to run post-uninstall commands\n\t%w", err)
 	}
 	debugLogger.Debug("Ran post-uninstall commands")
 
-	// 4. Delete the metadata file.
+	// 4. Delete the metadata, record and manifest.
 
-	metadataDir, err := ctx.MetadataDir()
-	if err != nil {
-		return fmt.Errorf("failed to get metadata directory\n\t%w", err)
+	if err := statedb.Delete(ctx, toothRepoPath); err != nil {
+		return fmt.Errorf("failed to delete installed state\n\t%w", err)
 	}
 
-	metadataFileName := fmt.Sprintf("%v.json", url.QueryEscape(toothRepoPath))
-	metadataPath := metadataDir.Join(path.MustParse(metadataFileName))
+	debugLogger.Debugf("Deleted installed state of %v", toothRepoPath)
+
+	return nil
+}
 
-	if err := os.Remove(metadataPath.LocalString()); err != nil {
-		return fmt.Errorf("failed to delete metadata file\n\t%w", err)
+// Forget removes what lip keeps about an installed tooth, i.e. its metadata, record, manifest
+// and store, without running its commands or removing its files in the workspace. It is for
+// teeth whose files are already gone, e.g. deleted by hand.
+func Forget(ctx *context.Context, toothRepoPath string) error {
+	if err := statedb.Delete(ctx, toothRepoPath); err != nil {
+		return fmt.Errorf("failed to delete installed state\n\t%w", err)
 	}
 
-	debugLogger.Debugf("Deleted metadata file %v", metadataPath.LocalString())
+	if err := removeToothStore(ctx, toothRepoPath); err != nil {
+		return err
+	}
 
 	return nil
 }
 
 // removeToothFiles removes the files of the tooth.
 func removeToothFiles(ctx *context.Context, metadata tooth.Metadata) error {
-	debugLogger := log.WithFields(log.Fields{
+	debugLogger := ctx.Logger().WithFields(log.Fields{
 		"package": "install",
 		"method":  "removeToothFiles",
 	})
 
-	workspaceDirStr, err := os.Getwd()
+	workspaceDir, err := ctx.WorkspaceDir()
 	if err != nil {
 		return err
 	}
 
-	workspaceDir, err := path.Parse(workspaceDirStr)
-	if err != nil {
-		return fmt.Errorf("failed to parse workspace directory\n\t%w", err)
-	}
-
 	files, err := metadata.Files()
 	if err != nil {
 		return fmt.Errorf("failed to get files from metadata\n\t%w", err)
 	}
 
 	for _, place := range files.Place {
-		// Files marked as "preserve" will not be deleted.
+		// Files marked as "preserve" will not be deleted unless purged.
 		isPreserved := false
 		for _, preserve := range files.Preserve {
 			if place.Dest.Equal(preserve) {
@@ -108,7 +109,7 @@ func removeToothFiles(ctx *context.Context, metadata tooth.Metadata) error {
 				break
 			}
 		}
-		if isPreserved {
+		if isPreserved && !ctx.Purge() {
 			debugLogger.Debugf("Preserved file %v", place.Dest)
 			continue
 		}
@@ -117,8 +118,36 @@ func removeToothFiles(ctx *context.Context, metadata tooth.Metadata) error {
 
 		dest := workspaceDir.Join(relDest)
 
+		// User data is kept unless purged, to be used again when the tooth is installed again.
+		if place.UserData && !ctx.Purge() {
+			if _, err := ctx.FS().Lstat(dest.LocalString()); err == nil {
+				ctx.Logger().Infof(i18n.T("Kept user data %v. Uninstall with --purge to remove it."), relDest.LocalString())
+			}
+			continue
+		}
+
+		// Config files edited by the user are kept with their originals unless purged, so that
+		// the edits can be kept when the tooth is installed again.
+		if place.Config != tooth.NoConfigStrategy {
+			if _, err := ctx.FS().Stat(dest.LocalString()); err == nil && !ctx.Purge() {
+				isModified, err := isConfigFileModified(ctx, metadata.ToothRepoPath(), relDest, dest)
+				if err != nil {
+					return err
+				}
+
+				if isModified {
+					ctx.Logger().Infof(i18n.T("Kept config file %v with your changes"), relDest.LocalString())
+					continue
+				}
+			}
+
+			if err := manifest.DeleteConfigBase(ctx, metadata.ToothRepoPath(), relDest.String()); err != nil {
+				return fmt.Errorf("failed to delete original of config file\n\t%w", err)
+			}
+		}
+
 		// Delete the file.
-		if err := os.RemoveAll(dest.LocalString()); err != nil {
+		if err := ctx.FS().RemoveAll(dest.LocalString()); err != nil {
 			return fmt.Errorf("failed to delete file\n\t%w", err)
 		}
 		debugLogger.Debugf("Deleted file %v", dest.LocalString())
@@ -140,10 +169,10 @@ func removeToothFiles(ctx *context.Context, metadata tooth.Metadata) error {
 				break
 			}
 
-			fileList, err := os.ReadDir(dir.LocalString())
+			fileList, err := ctx.FS().ReadDir(dir.LocalString())
 			if err != nil {
 				if os.IsNotExist(err) {
-					log.Errorf("directory %v does not exist, skip deleting", dir.LocalString())
+					ctx.Logger().Errorf(i18n.T("directory %v does not exist, skip deleting"), dir.LocalString())
 					break
 				} else {
 					return fmt.Errorf("failed to read directory %v\n\t%w", dir.LocalString(), err)
@@ -154,7 +183,7 @@ func removeToothFiles(ctx *context.Context, metadata tooth.Metadata) error {
 				break
 			}
 
-			if err := os.Remove(dir.LocalString()); err != nil {
+			if err := ctx.FS().Remove(dir.LocalString()); err != nil {
 				return fmt.Errorf("failed to delete directory\n\t%w", err)
 			}
 			debugLogger.Debugf("Deleted directory %v", dir.LocalString())
@@ -163,6 +192,11 @@ func removeToothFiles(ctx *context.Context, metadata tooth.Metadata) error {
 		}
 	}
 
+	// Remove the files kept in the store if the tooth was installed in symlink mode.
+	if err := removeToothStore(ctx, metadata.ToothRepoPath()); err != nil {
+		return err
+	}
+
 	// Files marked as "remove" will be deleted regardless of whether they are marked as "preserve".
 	files, err = metadata.Files()
 	if err != nil {
@@ -172,7 +206,7 @@ func removeToothFiles(ctx *context.Context, metadata tooth.Metadata) error {
 	for _, removal := range files.Remove {
 		removalPath := removal
 
-		if err := os.RemoveAll(workspaceDir.Join(removalPath).LocalString()); err != nil {
+		if err := ctx.FS().RemoveAll(workspaceDir.Join(removalPath).LocalString()); err != nil {
 			return fmt.Errorf("failed to delete file\n\t%w", err)
 		}
 		debugLogger.Debugf("Deleted file %v that is marked as \"remove\"", workspaceDir.Join(removalPath).LocalString())
@@ -0,0 +1,40 @@
+package interrupt
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/lippkg/lip/internal/i18n"
+	log "github.com/sirupsen/logrus"
+)
+
+// Notify returns a Go context canceled when lip receives SIGINT, e.g. from Ctrl-C, or SIGTERM,
+// so that the operation in progress finishes or rolls back its current step and cleans up
+// before lip exits. Once it is canceled, another signal terminates lip at once.
+func Notify() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		received := <-signals
+
+		// Restore the default handling, so that the next signal terminates lip.
+		signal.Stop(signals)
+
+		log.Warnf(i18n.T("Received %v. Cleaning up... Press Ctrl-C again to exit at once."), received)
+
+		cancel()
+	}()
+
+	return ctx
+}
+
+// IsInterrupted returns whether the operation of a Go context was interrupted by a signal,
+// rather than failed or timed out.
+func IsInterrupted(ctx context.Context) bool {
+	return ctx.Err() == context.Canceled
+}
@@ -0,0 +1,177 @@
+package lancache
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/path"
+	"github.com/lippkg/lip/internal/sumdb"
+	"github.com/lippkg/lip/internal/vfs"
+	log "github.com/sirupsen/logrus"
+)
+
+// minScanInterval is the minimum time between scans of the cache, so that queries for files not
+// in it, which any instance on the local network may send, do not make the cache read again and
+// again.
+const minScanInterval = 5 * time.Second
+
+// cacheIndex maps the checksums of the files in the cache to their paths. Files are hashed when
+// first seen and hashed again only if their sizes or modification times change. The cache is
+// scanned in the background, so that queries and downloads are never held up by hashing. The
+// cache is read on the file system of the operating system, where the files are served from.
+type cacheIndex struct {
+	cacheDir    path.Path
+	isShareable func(fileName string) bool
+	clock       context.Clock
+	debugLogger *log.Entry
+
+	mu         sync.Mutex
+	entries    map[string]cacheIndexEntry
+	paths      map[string]path.Path
+	lastScan   time.Time
+	isScanning bool
+}
+
+type cacheIndexEntry struct {
+	checksum string
+	size     int64
+	modTime  time.Time
+}
+
+// newCacheIndex makes an empty index of the files in a cache directory that isShareable accepts
+// by their names. The clock times the scans. Call scanInBackground to fill it.
+func newCacheIndex(cacheDir path.Path, isShareable func(fileName string) bool, clock context.Clock,
+	debugLogger *log.Entry) *cacheIndex {
+	return &cacheIndex{
+		cacheDir:    cacheDir,
+		isShareable: isShareable,
+		clock:       clock,
+		debugLogger: debugLogger,
+		entries:     make(map[string]cacheIndexEntry),
+		paths:       make(map[string]path.Path),
+	}
+}
+
+// find returns the path of a file in the cache by its checksum. If the file is not known or has
+// changed, the cache is scanned again in the background, since files are added while sharing,
+// and the file is found by later queries. The second return value is false if no file known has
+// the checksum.
+func (index *cacheIndex) find(checksum string) (path.Path, bool) {
+	index.mu.Lock()
+	filePath, ok := index.paths[checksum]
+	var entry cacheIndexEntry
+	if ok {
+		entry = index.entries[filePath.Base()]
+	}
+	index.mu.Unlock()
+
+	if ok && isUnchanged(filePath, entry) {
+		return filePath, true
+	}
+
+	index.scanInBackground()
+
+	return path.Path{}, false
+}
+
+// scanInBackground starts scanning the cache, unless it is being scanned or was scanned less
+// than minScanInterval ago.
+func (index *cacheIndex) scanInBackground() {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	if index.isScanning || index.clock.Now().Sub(index.lastScan) < minScanInterval {
+		return
+	}
+
+	index.isScanning = true
+
+	go func() {
+		if err := index.scan(); err != nil {
+			index.debugLogger.Debugf("Failed to scan cache: %v", err)
+		}
+	}()
+}
+
+// ---------------------------------------------------------------------
+
+// scan hashes the files in the cache that are new or changed since the last scan, and forgets
+// the removed ones. Partial downloads and files not to share are skipped. The lock is held only
+// to swap in the new index, not while hashing.
+func (index *cacheIndex) scan() error {
+	defer func() {
+		index.mu.Lock()
+		index.isScanning = false
+		index.lastScan = index.clock.Now()
+		index.mu.Unlock()
+	}()
+
+	dirEntries, err := os.ReadDir(index.cacheDir.LocalString())
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to read cache directory\n\t%w", err)
+	}
+
+	// Only scans replace the entries, and they never run at once, so the previous entries can be
+	// read without the lock.
+	index.mu.Lock()
+	previousEntries := index.entries
+	index.mu.Unlock()
+
+	entries := make(map[string]cacheIndexEntry)
+	paths := make(map[string]path.Path)
+
+	for _, dirEntry := range dirEntries {
+		if dirEntry.IsDir() || strings.HasSuffix(dirEntry.Name(), ".part") || !index.isShareable(dirEntry.Name()) {
+			continue
+		}
+
+		fileInfo, err := dirEntry.Info()
+		if err != nil {
+			continue
+		}
+
+		// Files lip cannot name are not cached by it.
+		fileName, err := path.Parse(dirEntry.Name())
+		if err != nil {
+			continue
+		}
+
+		filePath := index.cacheDir.Join(fileName)
+
+		entry, ok := previousEntries[dirEntry.Name()]
+		if !ok || entry.size != fileInfo.Size() || !entry.modTime.Equal(fileInfo.ModTime()) {
+			checksum, err := sumdb.CalculateChecksum(vfs.OS(), filePath)
+			if err != nil {
+				continue
+			}
+
+			entry = cacheIndexEntry{checksum: checksum, size: fileInfo.Size(), modTime: fileInfo.ModTime()}
+		}
+
+		entries[dirEntry.Name()] = entry
+		paths[entry.checksum] = filePath
+	}
+
+	index.mu.Lock()
+	index.entries = entries
+	index.paths = paths
+	index.mu.Unlock()
+
+	return nil
+}
+
+// isUnchanged returns whether a file indexed is still in the cache as it was hashed.
+func isUnchanged(filePath path.Path, entry cacheIndexEntry) bool {
+	fileInfo, err := os.Stat(filePath.LocalString())
+	if err != nil {
+		return false
+	}
+
+	return entry.size == fileInfo.Size() && entry.modTime.Equal(fileInfo.ModTime())
+}
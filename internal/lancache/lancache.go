@@ -0,0 +1,279 @@
+package lancache
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/network"
+	"github.com/lippkg/lip/internal/path"
+	log "github.com/sirupsen/logrus"
+)
+
+// DiscoveryPort is the UDP port lip instances sharing their caches answer queries at.
+const DiscoveryPort = 47931
+
+// protocol identifies the messages of cache sharing, so that other traffic on the port is
+// ignored.
+const protocol = "lip-lan-cache/1"
+
+// maxMessageSize is the maximum size of a message, well within a UDP datagram.
+const maxMessageSize = 1024
+
+// maxFileSize is the maximum size of a file fetched from a lip instance. Instances are not
+// trusted, so a download is aborted once it exceeds the size rather than hashed after filling
+// the disk.
+const maxFileSize = 4 << 30
+
+// filePathPrefix is the path the files are served under by their checksums.
+const filePathPrefix = "/sha256/"
+
+// message is a query for a file, broadcast by a lip instance fetching it, or an answer from a
+// lip instance sharing it, with the port it is served at.
+type message struct {
+	Protocol string `json:"protocol"`
+	SHA256   string `json:"sha256"`
+	Port     int    `json:"port,omitempty"`
+}
+
+// Share serves the files in the cache to lip instances on the local network until the Go
+// context of ctx is canceled. Files are served read-only over HTTP on the listener by their
+// SHA-256 checksums, and queries for them broadcast to DiscoveryPort are answered.
+func Share(ctx *context.Context, listener net.Listener) error {
+	debugLogger := ctx.Logger().WithFields(log.Fields{
+		"package": "lancache",
+		"method":  "Share",
+	})
+
+	cacheDir, err := ctx.CacheDir()
+	if err != nil {
+		return fmt.Errorf("failed to get cache directory\n\t%w", err)
+	}
+
+	gitHubAPIURL, err := ctx.GitHubAPIURL()
+	if err != nil {
+		return fmt.Errorf("failed to get GitHub API URL\n\t%w", err)
+	}
+
+	index := newCacheIndex(cacheDir, func(fileName string) bool {
+		return isShareable(ctx, gitHubAPIURL, fileName)
+	}, ctx.Clock(), debugLogger)
+
+	// The cache is indexed in the background, so that serving starts at once.
+	index.scanInBackground()
+
+	packetConn, err := net.ListenPacket("udp4", fmt.Sprintf(":%v", DiscoveryPort))
+	if err != nil {
+		return fmt.Errorf("failed to listen on UDP port %v\n\t%w", DiscoveryPort, err)
+	}
+	defer packetConn.Close()
+
+	httpPort := listener.Addr().(*net.TCPAddr).Port
+
+	go func() {
+		buffer := make([]byte, maxMessageSize)
+		for {
+			n, addr, err := packetConn.ReadFrom(buffer)
+			if err != nil {
+				// The connection is closed when sharing stops.
+				return
+			}
+
+			checksum, ok := parseMessage(buffer[:n])
+			if !ok {
+				continue
+			}
+
+			if _, ok := index.find(checksum); !ok {
+				continue
+			}
+
+			answer, _ := json.Marshal(message{Protocol: protocol, SHA256: checksum, Port: httpPort})
+			if _, err := packetConn.WriteTo(answer, addr); err != nil {
+				debugLogger.Debugf("Failed to answer %v: %v", addr, err)
+				continue
+			}
+
+			debugLogger.Debugf("Answered %v for %v", addr, checksum)
+		}
+	}()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(filePathPrefix, func(w http.ResponseWriter, r *http.Request) {
+		checksum := strings.TrimPrefix(r.URL.Path, filePathPrefix)
+		if !isValidChecksum(checksum) {
+			http.NotFound(w, r)
+			return
+		}
+
+		filePath, ok := index.find(checksum)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		debugLogger.Debugf("Serving %v to %v", filePath.LocalString(), r.RemoteAddr)
+
+		http.ServeFile(w, r, filePath.LocalString())
+	})
+
+	server := &http.Server{Handler: mux}
+
+	// Sharing stops when interrupted, e.g. by Ctrl-C.
+	go func() {
+		<-ctx.GoContext().Done()
+		server.Close()
+		packetConn.Close()
+	}()
+
+	if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+		return fmt.Errorf("failed to serve cache\n\t%w", err)
+	}
+
+	return nil
+}
+
+// Fetch broadcasts a query for a file by its hex-encoded SHA-256 checksum to the lip instances
+// on the local network sharing their caches, and downloads it to a local path from the first
+// one answering within the timeout that sends the file with the checksum. Instances sending
+// other content are skipped, so the file is as trusted as the checksum. It returns the address
+// of the instance. The second return value is false if no instance has the file.
+func Fetch(ctx *context.Context, checksum string, filePath path.Path, timeout time.Duration) (string, bool, error) {
+	debugLogger := ctx.Logger().WithFields(log.Fields{
+		"package": "lancache",
+		"method":  "Fetch",
+	})
+
+	packetConn, err := net.ListenPacket("udp4", ":0")
+	if err != nil {
+		return "", false, fmt.Errorf("failed to open UDP socket\n\t%w", err)
+	}
+	defer packetConn.Close()
+
+	query, err := json.Marshal(message{Protocol: protocol, SHA256: checksum})
+	if err != nil {
+		return "", false, fmt.Errorf("failed to marshal query\n\t%w", err)
+	}
+
+	broadcastAddr := &net.UDPAddr{IP: net.IPv4bcast, Port: DiscoveryPort}
+	if _, err := packetConn.WriteTo(query, broadcastAddr); err != nil {
+		return "", false, fmt.Errorf("failed to broadcast query\n\t%w", err)
+	}
+
+	if err := packetConn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return "", false, fmt.Errorf("failed to set deadline\n\t%w", err)
+	}
+
+	// Peers are reached directly, not through the proxy.
+	noProxyURL := &url.URL{}
+
+	buffer := make([]byte, maxMessageSize)
+	for {
+		n, addr, err := packetConn.ReadFrom(buffer)
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return "", false, nil
+		} else if err != nil {
+			return "", false, fmt.Errorf("failed to read answers\n\t%w", err)
+		}
+
+		answeredChecksum, ok := parseMessage(buffer[:n])
+		if !ok || answeredChecksum != checksum {
+			continue
+		}
+
+		var answer message
+		if err := json.Unmarshal(buffer[:n], &answer); err != nil || answer.Port <= 0 || answer.Port > 65535 {
+			continue
+		}
+
+		udpAddr, ok := addr.(*net.UDPAddr)
+		if !ok {
+			continue
+		}
+
+		peerAddr := net.JoinHostPort(udpAddr.IP.String(), fmt.Sprint(answer.Port))
+		fileURL := &url.URL{Scheme: "http", Host: peerAddr, Path: filePathPrefix + checksum}
+
+		downloadedChecksum, err := ctx.Network().DownloadFileWithMaxSize(ctx.GoContext(), fileURL, noProxyURL, nil,
+			filePath, maxFileSize, network.NoRetry)
+		if err != nil {
+			ctx.FS().Remove(filePath.LocalString())
+			debugLogger.Debugf("Failed to fetch %v from %v: %v", checksum, peerAddr, err)
+			continue
+		}
+
+		if downloadedChecksum != checksum {
+			ctx.FS().Remove(filePath.LocalString())
+			ctx.Logger().Warnf(i18n.T("%v sent a file not matching checksum %v, skipped it"), peerAddr, checksum)
+			continue
+		}
+
+		return peerAddr, true, nil
+	}
+}
+
+// ---------------------------------------------------------------------
+
+// parseMessage returns the checksum of a message of cache sharing. The second return value is
+// false if the data is not such a message.
+func parseMessage(data []byte) (string, bool) {
+	var msg message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return "", false
+	}
+
+	if msg.Protocol != protocol || !isValidChecksum(msg.SHA256) {
+		return "", false
+	}
+
+	return msg.SHA256, true
+}
+
+// isShareable returns whether a file in the cache may be shared, by its name. Only archives
+// downloaded by lip are shared, i.e. files named by their escaped URLs, and not those from hosts
+// lip authenticates to, i.e. hosts with credentials and GitHub when GitHubToken is set, since
+// they may be of private teeth.
+func isShareable(ctx *context.Context, gitHubAPIURL *url.URL, fileName string) bool {
+	if strings.HasSuffix(fileName, ".revalidation.json") {
+		return false
+	}
+
+	urlString, err := url.QueryUnescape(fileName)
+	if err != nil {
+		return false
+	}
+
+	fileURL, err := url.Parse(urlString)
+	if err != nil || (fileURL.Scheme != "http" && fileURL.Scheme != "https") || fileURL.Host == "" {
+		return false
+	}
+
+	if ctx.Network().HasCredential(fileURL.Host) {
+		return false
+	}
+
+	if ctx.GitHubToken() != "" && (fileURL.Host == "github.com" || fileURL.Host == gitHubAPIURL.Host) {
+		return false
+	}
+
+	return true
+}
+
+// isValidChecksum returns whether a string is a lowercase hex-encoded SHA-256 checksum.
+func isValidChecksum(checksum string) bool {
+	if len(checksum) != 64 || strings.ToLower(checksum) != checksum {
+		return false
+	}
+
+	_, err := hex.DecodeString(checksum)
+	return err == nil
+}
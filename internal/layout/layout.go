@@ -0,0 +1,248 @@
+package layout
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/tooth"
+	"github.com/lippkg/lip/internal/vfs"
+	"github.com/lippkg/lip/internal/workspace"
+)
+
+// Layout is where the logical placement roots of teeth are in a workspace.
+type Layout struct {
+	// Type is the type of the workspace, e.g. levilamina.
+	Type string `json:"type"`
+	// Roots maps each logical placement root to its directory, relative to the workspace and
+	// separated by slashes.
+	Roots map[string]string `json:"roots"`
+}
+
+// defaultLevelName is the world BDS loads if server.properties does not set level-name.
+const defaultLevelName = "Bedrock level"
+
+// levelNamePlaceholder is replaced with the name of the world BDS loads in the directories of
+// layout profiles.
+const levelNamePlaceholder = "<level-name>"
+
+// profiles are the directories of the logical placement roots in each type of workspace. BDS
+// loads packs from the directories of the world, and LeviLamina 1.0 and later loads mods from
+// mods. A generic workspace is its own world.
+var profiles = map[string]map[string]string{
+	context.GenericWorkspaceType: {
+		tooth.PluginsRoot:       "plugins",
+		tooth.BehaviorPacksRoot: "behavior_packs",
+		tooth.ResourcePacksRoot: "resource_packs",
+		tooth.WorldsRoot:        "worlds",
+		tooth.WorldRoot:         ".",
+	},
+	context.BDSWorkspaceType: {
+		tooth.PluginsRoot:       "plugins",
+		tooth.BehaviorPacksRoot: "worlds/" + levelNamePlaceholder + "/behavior_packs",
+		tooth.ResourcePacksRoot: "worlds/" + levelNamePlaceholder + "/resource_packs",
+		tooth.WorldsRoot:        "worlds",
+		tooth.WorldRoot:         "worlds/" + levelNamePlaceholder,
+	},
+	context.LeviLaminaWorkspaceType: {
+		tooth.PluginsRoot:       "mods",
+		tooth.BehaviorPacksRoot: "worlds/" + levelNamePlaceholder + "/behavior_packs",
+		tooth.ResourcePacksRoot: "worlds/" + levelNamePlaceholder + "/resource_packs",
+		tooth.WorldsRoot:        "worlds",
+		tooth.WorldRoot:         "worlds/" + levelNamePlaceholder,
+	},
+}
+
+// leviLaminaModsVersion is the first version of LeviLamina loading mods from mods instead of
+// plugins.
+var leviLaminaModsVersion = semver.MustParse("1.0.0")
+
+// Get returns the layout of the workspace: the layout profile of its type, configured or
+// detected, with the directories overridden by the workspace manifest.
+func Get(ctx *context.Context) (Layout, error) {
+	workspaceType, err := ctx.WorkspaceType()
+	if err != nil {
+		return Layout{}, err
+	}
+
+	if workspaceType == context.AutoWorkspaceType {
+		workspaceType, err = Detect(ctx)
+		if err != nil {
+			return Layout{}, fmt.Errorf("failed to detect workspace type\n\t%w", err)
+		}
+	}
+
+	roots, err := makeProfileRoots(ctx, workspaceType)
+	if err != nil {
+		return Layout{}, err
+	}
+
+	isManifestPresent, err := workspace.IsManifestPresent(ctx.FS())
+	if err != nil {
+		return Layout{}, err
+	}
+
+	if isManifestPresent {
+		workspaceManifest, err := workspace.LoadManifest(ctx.FS())
+		if err != nil {
+			return Layout{}, err
+		}
+
+		for root, dir := range workspaceManifest.Layout {
+			dirPath, err := tooth.ParseWorkspaceDir(dir)
+			if err != nil {
+				return Layout{}, fmt.Errorf("invalid directory of placement root %v\n\t%w", root, err)
+			}
+
+			// The workspace itself is kept as ".", so that {{root.NAME}}/file stays relative.
+			if dirPath.IsEmpty() {
+				roots[root] = "."
+			} else {
+				roots[root] = dirPath.String()
+			}
+		}
+	}
+
+	return Layout{Type: workspaceType, Roots: roots}, nil
+}
+
+// Detect detects the type of the workspace from its files. It is a LeviLamina workspace if
+// LeviLamina is installed, by lip or otherwise, a BDS workspace if there is a BDS executable,
+// and a generic workspace otherwise.
+func Detect(ctx *context.Context) (string, error) {
+	if _, ok, err := getLeviLaminaVersion(ctx); err != nil {
+		return "", err
+	} else if ok {
+		return context.LeviLaminaWorkspaceType, nil
+	}
+
+	if ok, err := isAnyFilePresent(ctx.FS(), "bedrock_server_mod.exe"); err != nil {
+		return "", err
+	} else if ok {
+		return context.LeviLaminaWorkspaceType, nil
+	}
+
+	if ok, err := isAnyFilePresent(ctx.FS(), "bedrock_server.exe", "bedrock_server"); err != nil {
+		return "", err
+	} else if ok {
+		return context.BDSWorkspaceType, nil
+	}
+
+	return context.GenericWorkspaceType, nil
+}
+
+// ---------------------------------------------------------------------
+
+// makeProfileRoots returns the directories of the layout profile of a workspace type, with the
+// placeholders replaced.
+func makeProfileRoots(ctx *context.Context, workspaceType string) (map[string]string, error) {
+	profile, ok := profiles[workspaceType]
+	if !ok {
+		return nil, fmt.Errorf("no layout profile for workspace type %v", workspaceType)
+	}
+
+	roots := make(map[string]string, len(profile))
+	for root, dir := range profile {
+		roots[root] = dir
+	}
+
+	// LeviLamina before 1.0 loads plugins from plugins.
+	if workspaceType == context.LeviLaminaWorkspaceType {
+		version, ok, err := getLeviLaminaVersion(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok && version.LT(leviLaminaModsVersion) {
+			roots[tooth.PluginsRoot] = "plugins"
+		}
+	}
+
+	levelName := ""
+	for root, dir := range roots {
+		if !strings.Contains(dir, levelNamePlaceholder) {
+			continue
+		}
+
+		if levelName == "" {
+			var err error
+			levelName, err = getLevelName(ctx.FS())
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		dir = strings.ReplaceAll(dir, levelNamePlaceholder, levelName)
+		if _, err := tooth.ParseWorkspaceDir(dir); err != nil {
+			return nil, fmt.Errorf("invalid directory of placement root %v\n\t%w", root, err)
+		}
+
+		roots[root] = dir
+	}
+
+	return roots, nil
+}
+
+// getLeviLaminaVersion returns the version of LeviLamina installed by lip, i.e. of its tooth
+// or of a tooth providing it. The second return value is false if it is not installed by lip.
+func getLeviLaminaVersion(ctx *context.Context) (semver.Version, bool, error) {
+	metadataList, err := tooth.GetAllMetadata(ctx)
+	if err != nil {
+		return semver.Version{}, false, fmt.Errorf("failed to get installed teeth\n\t%w", err)
+	}
+
+	for _, metadata := range metadataList {
+		if version, ok := metadata.RuntimeVersion(tooth.LeviLaminaRuntime); ok {
+			return version, true, nil
+		}
+	}
+
+	return semver.Version{}, false, nil
+}
+
+// getLevelName returns the name of the world BDS loads, set by level-name in the
+// server.properties file of the workspace on a file system.
+func getLevelName(fs vfs.FS) (string, error) {
+	data, err := fs.ReadFile("server.properties")
+	if os.IsNotExist(err) {
+		return defaultLevelName, nil
+	} else if err != nil {
+		return "", fmt.Errorf("failed to read server.properties\n\t%w", err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok || strings.TrimSpace(key) != "level-name" {
+			continue
+		}
+
+		if value = strings.TrimSpace(value); value != "" {
+			return value, nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read server.properties\n\t%w", err)
+	}
+
+	return defaultLevelName, nil
+}
+
+// isAnyFilePresent returns whether any of the files exists in the workspace on a file system.
+func isAnyFilePresent(fs vfs.FS, fileNames ...string) (bool, error) {
+	for _, fileName := range fileNames {
+		if _, err := fs.Stat(filepath.FromSlash(fileName)); err == nil {
+			return true, nil
+		} else if !os.IsNotExist(err) {
+			return false, fmt.Errorf("failed to check %v\n\t%w", fileName, err)
+		}
+	}
+
+	return false, nil
+}
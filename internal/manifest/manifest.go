@@ -0,0 +1,217 @@
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/path"
+	"github.com/lippkg/lip/internal/statedb"
+	"github.com/lippkg/lip/internal/vfs"
+)
+
+// Manifest lists the files placed when a tooth was installed, with their checksums.
+type Manifest struct {
+	ToothRepoPath string `json:"tooth"`
+	Version       string `json:"version"`
+	// AssetArchive is the archive the files were extracted from.
+	AssetArchive string `json:"asset_archive"`
+	Files        []File `json:"files"`
+	// Remaps is the directories the files were moved between by the workspace manifest.
+	Remaps map[string]string `json:"remaps,omitempty"`
+}
+
+// File is a file placed by a tooth.
+type File struct {
+	// Path is the path of the file relative to the workspace directory.
+	Path string `json:"path"`
+	// Source is the path of the file in the asset archive.
+	Source string `json:"source"`
+	SHA256 string `json:"sha256"`
+	// Config is the config strategy of the file. Empty if the file is not a config file.
+	Config string `json:"config,omitempty"`
+	// Mode is the permission bits of the file in octal. Empty if not recorded, e.g. on Windows.
+	Mode string `json:"mode,omitempty"`
+	// UserData is whether the file holds user data, which is kept as it is on upgrades.
+	UserData bool `json:"user_data,omitempty"`
+}
+
+// Get returns the manifest of an installed tooth. The second return value is false if no
+// manifest was recorded, e.g. for teeth installed by older versions of lip.
+func Get(ctx *context.Context, toothRepoPath string) (Manifest, bool, error) {
+	db, err := statedb.Open(ctx)
+	if err != nil {
+		return Manifest{}, false, fmt.Errorf("failed to open state database\n\t%w", err)
+	}
+
+	tooth, _ := db.Get(toothRepoPath)
+	if tooth.Manifest == nil {
+		return Manifest{}, false, nil
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(tooth.Manifest, &manifest); err != nil {
+		return Manifest{}, false, fmt.Errorf("failed to unmarshal manifest of %v\n\t%w", toothRepoPath, err)
+	}
+
+	if manifest.ToothRepoPath != toothRepoPath {
+		return Manifest{}, false, fmt.Errorf("manifest of %v is of another tooth: %v", toothRepoPath,
+			manifest.ToothRepoPath)
+	}
+
+	return manifest, true, nil
+}
+
+// Save writes the manifest of an installed tooth. The files it lists become owned by the tooth.
+func Save(ctx *context.Context, manifest Manifest) error {
+	jsonBytes, err := json.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest\n\t%w", err)
+	}
+
+	filePaths := make([]string, 0, len(manifest.Files))
+	for _, file := range manifest.Files {
+		filePaths = append(filePaths, file.Path)
+	}
+
+	err = statedb.Update(ctx, manifest.ToothRepoPath, func(tooth *statedb.Tooth) {
+		tooth.Manifest = jsonBytes
+		tooth.Files = filePaths
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save manifest of %v\n\t%w", manifest.ToothRepoPath, err)
+	}
+
+	return nil
+}
+
+// Delete removes the manifest of a tooth. It does nothing if the manifest does not exist.
+func Delete(ctx *context.Context, toothRepoPath string) error {
+	err := statedb.Update(ctx, toothRepoPath, func(tooth *statedb.Tooth) {
+		tooth.Manifest = nil
+		tooth.Files = nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete manifest of %v\n\t%w", toothRepoPath, err)
+	}
+
+	return nil
+}
+
+// DeleteConfigBase removes the original of a config file of a tooth. It does nothing if the
+// original does not exist.
+func DeleteConfigBase(ctx *context.Context, toothRepoPath string, filePath string) error {
+	configBasePath, err := getConfigBasePath(ctx, toothRepoPath, filePath)
+	if err != nil {
+		return fmt.Errorf("failed to get config base path\n\t%w", err)
+	}
+
+	if err := ctx.FS().Remove(configBasePath.LocalString()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete config base %v\n\t%w", configBasePath.LocalString(), err)
+	}
+
+	return nil
+}
+
+// GetConfigBase returns the original of a config file of a tooth as placed by the last
+// installation. It is the common ancestor when merging user edits into a new version. The
+// second return value is false if no original is kept.
+func GetConfigBase(ctx *context.Context, toothRepoPath string, filePath string) ([]byte, bool, error) {
+	configBasePath, err := getConfigBasePath(ctx, toothRepoPath, filePath)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get config base path\n\t%w", err)
+	}
+
+	content, err := ctx.FS().ReadFile(configBasePath.LocalString())
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, fmt.Errorf("failed to read config base %v\n\t%w", configBasePath.LocalString(), err)
+	}
+
+	return content, true, nil
+}
+
+// SaveConfigBase keeps the original of a config file of a tooth.
+func SaveConfigBase(ctx *context.Context, toothRepoPath string, filePath string, content []byte) error {
+	configBasePath, err := getConfigBasePath(ctx, toothRepoPath, filePath)
+	if err != nil {
+		return fmt.Errorf("failed to get config base path\n\t%w", err)
+	}
+
+	configBaseDir, err := configBasePath.Dir()
+	if err != nil {
+		return fmt.Errorf("failed to get config base directory\n\t%w", err)
+	}
+
+	if err := ctx.FS().MkdirAll(configBaseDir.LocalString(), 0755); err != nil {
+		return fmt.Errorf("failed to create config base directory %v\n\t%w", configBaseDir.LocalString(), err)
+	}
+
+	if err := ctx.FS().WriteFile(configBasePath.LocalString(), content, 0644); err != nil {
+		return fmt.Errorf("failed to write config base %v\n\t%w", configBasePath.LocalString(), err)
+	}
+
+	return nil
+}
+
+// FormatMode formats permission bits to be recorded in a manifest. Zero is formatted as an
+// empty string.
+func FormatMode(mode os.FileMode) string {
+	if mode == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("%04o", uint32(mode.Perm()))
+}
+
+// ParseMode parses permission bits recorded in a manifest. An empty string is parsed as zero.
+func ParseMode(modeString string) (os.FileMode, error) {
+	if modeString == "" {
+		return 0, nil
+	}
+
+	mode, err := strconv.ParseUint(modeString, 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse mode %v\n\t%w", modeString, err)
+	}
+
+	return os.FileMode(mode).Perm(), nil
+}
+
+// HashFile returns the hex-encoded SHA-256 checksum of a file on a file system.
+func HashFile(fs vfs.FS, filePath path.Path) (string, error) {
+	file, err := fs.Open(filePath.LocalString())
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// ---------------------------------------------------------------------
+
+// getConfigBasePath returns the path to the original of a config file. Originals of a tooth
+// are kept in a directory of the manifest directory.
+func getConfigBasePath(ctx *context.Context, toothRepoPath string, filePath string) (path.Path, error) {
+	manifestDir, err := ctx.ManifestDir()
+	if err != nil {
+		return path.Path{}, fmt.Errorf("failed to get manifest directory\n\t%w", err)
+	}
+
+	configBaseDirName := fmt.Sprintf("%v.config", url.QueryEscape(toothRepoPath))
+
+	return manifestDir.Join(path.MustParse(configBaseDirName)).Join(path.MustParse(url.QueryEscape(filePath))), nil
+}
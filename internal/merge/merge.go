@@ -0,0 +1,136 @@
+package merge
+
+import (
+	"strings"
+)
+
+// ThreeWay merges line by line the changes from base to ours and the changes from base to
+// theirs. The second return value is false if both sides change the same lines differently,
+// in which case the merged content is not usable.
+func ThreeWay(base string, ours string, theirs string) (string, bool) {
+	baseLines := splitLines(base)
+	ourLines := splitLines(ours)
+	theirLines := splitLines(theirs)
+
+	ourMatches := matchLines(baseLines, ourLines)
+	theirMatches := matchLines(baseLines, theirLines)
+
+	merged := &strings.Builder{}
+
+	// Walk through the lines of base kept by both sides. The chunks between them are changed
+	// by at least one side.
+	i, j, k := 0, 0, 0
+	for {
+		stable := i
+		for stable < len(baseLines) && (ourMatches[stable] == -1 || theirMatches[stable] == -1) {
+			stable++
+		}
+
+		ourEnd, theirEnd := len(ourLines), len(theirLines)
+		if stable < len(baseLines) {
+			ourEnd, theirEnd = ourMatches[stable], theirMatches[stable]
+		}
+
+		chunk, ok := mergeChunk(baseLines[i:stable], ourLines[j:ourEnd], theirLines[k:theirEnd])
+		if !ok {
+			return "", false
+		}
+
+		for _, line := range chunk {
+			merged.WriteString(line)
+		}
+
+		if stable == len(baseLines) {
+			break
+		}
+
+		merged.WriteString(baseLines[stable])
+
+		i, j, k = stable+1, ourEnd+1, theirEnd+1
+	}
+
+	return merged.String(), true
+}
+
+// ---------------------------------------------------------------------
+
+// equalLines returns whether two lists of lines are equal.
+func equalLines(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchLines finds the longest common subsequence of a and b, and returns for each line of a
+// the index of the matched line of b, or -1 if the line is not matched.
+func matchLines(a []string, b []string) []int {
+	// lengths[i][j] is the length of the longest common subsequence of a[i:] and b[j:].
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else if lengths[i+1][j] >= lengths[i][j+1] {
+				lengths[i][j] = lengths[i+1][j]
+			} else {
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+
+	matches := make([]int, len(a))
+	for i := range matches {
+		matches[i] = -1
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if a[i] == b[j] {
+			matches[i] = j
+			i++
+			j++
+		} else if lengths[i+1][j] >= lengths[i][j+1] {
+			i++
+		} else {
+			j++
+		}
+	}
+
+	return matches
+}
+
+// mergeChunk merges a chunk changed by at least one side.
+func mergeChunk(base []string, ours []string, theirs []string) ([]string, bool) {
+	if equalLines(ours, base) {
+		return theirs, true
+	}
+
+	if equalLines(theirs, base) || equalLines(ours, theirs) {
+		return ours, true
+	}
+
+	return nil, false
+}
+
+// splitLines splits content into lines, keeping the line endings.
+func splitLines(content string) []string {
+	lines := strings.SplitAfter(content, "\n")
+
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
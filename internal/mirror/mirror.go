@@ -0,0 +1,431 @@
+package mirror
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	gopath "path"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/network"
+	"github.com/lippkg/lip/internal/path"
+	"github.com/lippkg/lip/internal/registry"
+	"github.com/lippkg/lip/internal/sumdb"
+	"github.com/lippkg/lip/internal/tooth"
+	"github.com/lippkg/lip/internal/vfs"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/mod/module"
+)
+
+// GitHubDirName is the directory of a mirror where asset archives from GitHub are placed, by
+// their paths on GitHub. A mirror served at http://host/ is a GitHub mirror at
+// http://host/github.
+const GitHubDirName = "github"
+
+// Options controls what is mirrored.
+type Options struct {
+	// LatestOnly mirrors only the latest version of each tooth that is not yanked.
+	LatestOnly bool
+	// SkipAssets skips the asset archives of teeth.
+	SkipAssets bool
+}
+
+// Mirror downloads the registry index, the registry entries of all teeth, their tooth archives
+// and their asset archives to a directory, which can be served as both the registry and the
+// Go module proxy. Downloads are verified against the checksum database. Files already in the
+// directory are kept, so that a mirror can be updated incrementally. It returns the number of
+// files downloaded.
+func Mirror(ctx *context.Context, dir path.Path, options Options) (int, error) {
+	if !registry.IsConfigured(ctx) {
+		return 0, errcode.Errorf(errcode.InvalidArgument, "RegistryURL must be configured to mirror a registry")
+	}
+
+	if ctx.Offline() {
+		return 0, errcode.Errorf(errcode.Offline, "cannot mirror a registry in offline mode")
+	}
+
+	toothRepoPaths, err := registry.ListTeeth(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	downloadedCount := 0
+
+	for _, toothRepoPath := range toothRepoPaths {
+		ctx.Logger().Infof(i18n.T("Mirroring %v..."), toothRepoPath)
+
+		count, err := mirrorTooth(ctx, dir, toothRepoPath, options)
+		downloadedCount += count
+		if err != nil {
+			return downloadedCount, fmt.Errorf("failed to mirror %v\n\t%w", toothRepoPath, err)
+		}
+	}
+
+	// The index is written last, so that an interrupted mirror does not list teeth that are
+	// not mirrored yet. It is copied as is, without holding it in memory.
+	indexURL, err := network.GenerateRegistryIndexURL(&url.URL{Path: "/"})
+	if err != nil {
+		return downloadedCount, fmt.Errorf("failed to generate registry index URL\n\t%w", err)
+	}
+
+	if err := writeIndex(ctx, dir, indexURL); err != nil {
+		return downloadedCount, err
+	}
+
+	return downloadedCount, nil
+}
+
+// ---------------------------------------------------------------------
+
+// downloadFile downloads a file of a tooth version into the mirror, unless it is already there,
+// and verifies it against the checksum database if verify is true. It returns whether the file
+// was downloaded.
+func downloadFile(ctx *context.Context, dir path.Path, downloadURL *url.URL, filePathURL *url.URL,
+	toothRepoPath string, version semver.Version, kind sumdb.Kind, verify bool) (bool, error) {
+	filePath, err := getFilePath(dir, filePathURL)
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := ctx.FS().Stat(filePath.LocalString()); err == nil {
+		return false, nil
+	} else if !os.IsNotExist(err) {
+		return false, fmt.Errorf("failed to check if file exists\n\t%w", err)
+	}
+
+	if err := makeParentDir(ctx.FS(), filePath); err != nil {
+		return false, err
+	}
+
+	ctx.Logger().Infof(i18n.T("Downloading %v"), downloadURL)
+
+	proxyURL, err := ctx.ProxyURL()
+	if err != nil {
+		return false, fmt.Errorf("failed to get proxy URL\n\t%w", err)
+	}
+
+	enableProgressBar := log.GetLevel() >= log.InfoLevel
+
+	partPath := path.MustParse(filePath.String() + ".part")
+
+	checksum, err := ctx.Network().DownloadFile(ctx.GoContext(), downloadURL, proxyURL, nil, partPath, enableProgressBar,
+		ctx.RetryPolicy())
+	if err != nil {
+		ctx.FS().Remove(partPath.LocalString())
+		return false, fmt.Errorf("failed to download file\n\t%w", err)
+	}
+
+	if verify {
+		if err := sumdb.VerifyChecksum(ctx, toothRepoPath, version, kind, checksum); err != nil {
+			ctx.FS().Remove(partPath.LocalString())
+			return false, fmt.Errorf("failed to verify %v archive of %v@%v\n\t%w", kind, toothRepoPath, version, err)
+		}
+	}
+
+	if err := ctx.FS().Rename(partPath.LocalString(), filePath.LocalString()); err != nil {
+		return false, fmt.Errorf("failed to move downloaded file into the mirror\n\t%w", err)
+	}
+
+	return true, nil
+}
+
+// getFilePath returns the path in the mirror of a file served at a URL relative to the root of
+// the mirror.
+func getFilePath(dir path.Path, filePathURL *url.URL) (path.Path, error) {
+	relativePath, err := path.Parse(strings.TrimPrefix(filePathURL.Path, "/"))
+	if err != nil {
+		return path.Path{}, fmt.Errorf("failed to parse path %v\n\t%w", filePathURL.Path, err)
+	}
+
+	return dir.Join(relativePath), nil
+}
+
+// getVersions returns the versions of a registry entry to mirror, sorted in ascending order.
+// The latest version that is not yanked prefers stable versions over pre-release versions.
+func getVersions(entry registry.Entry, latestOnly bool) []semver.Version {
+	versions := make([]semver.Version, 0, len(entry.Versions))
+	candidates := make([]semver.Version, 0, len(entry.Versions))
+
+	for _, entryVersion := range entry.Versions {
+		version, err := semver.Parse(entryVersion.Version)
+		if err != nil {
+			log.Warnf(i18n.T("Skipped invalid version %v of %v."), entryVersion.Version, entry.ToothRepoPath)
+			continue
+		}
+
+		versions = append(versions, version)
+		if !entryVersion.Yanked {
+			candidates = append(candidates, version)
+		}
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].LT(versions[j])
+	})
+
+	if !latestOnly {
+		return versions
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].LT(candidates[j])
+	})
+
+	for i := len(candidates) - 1; i >= 0; i-- {
+		if len(candidates[i].Pre) == 0 {
+			return []semver.Version{candidates[i]}
+		}
+	}
+
+	if len(candidates) != 0 {
+		return []semver.Version{candidates[len(candidates)-1]}
+	}
+
+	return []semver.Version{}
+}
+
+// makeParentDir creates the parent directory of a file on a file system if it does not exist.
+func makeParentDir(fs vfs.FS, filePath path.Path) error {
+	parentDir, err := filePath.Dir()
+	if err != nil {
+		return fmt.Errorf("failed to get parent directory of %v\n\t%w", filePath.LocalString(), err)
+	}
+
+	if err := fs.MkdirAll(parentDir.LocalString(), 0755); err != nil {
+		return fmt.Errorf("failed to create directory %v\n\t%w", parentDir.LocalString(), err)
+	}
+
+	return nil
+}
+
+// mirrorAssets mirrors the asset archives of all platforms of a tooth version. Asset archives
+// from GitHub are placed under GitHubDirName, and those of Go module paths are placed like
+// tooth archives. Other asset archives cannot be mirrored, since their URLs are not rewritten
+// when installing. The checksum database records one asset archive per version, so only the
+// one of the current platform is verified.
+func mirrorAssets(ctx *context.Context, dir path.Path, archivePath path.Path, toothRepoPath string,
+	version semver.Version) (int, error) {
+	metadata, err := tooth.ReadMetadataOfTooth(ctx.FS(), archivePath, toothRepoPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read metadata of %v@%v\n\t%w", toothRepoPath, version, err)
+	}
+
+	assetURLs, err := metadata.AssetURLs()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get asset URLs of %v@%v\n\t%w", toothRepoPath, version, err)
+	}
+
+	platformMetadata, err := metadata.ToPlatformSpecific(runtime.GOOS, runtime.GOARCH)
+	if err != nil {
+		return 0, fmt.Errorf("failed to convert to platform-specific metadata\n\t%w", err)
+	}
+
+	platformAssetURL, err := platformMetadata.AssetURL()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get asset URL of %v@%v\n\t%w", toothRepoPath, version, err)
+	}
+
+	// Asset URLs with template variables of platforms not declared in platforms are only known
+	// for the current platform.
+	isPlatformAssetURLListed := platformAssetURL.String() == ""
+	for _, assetURL := range assetURLs {
+		if assetURL.String() == platformAssetURL.String() {
+			isPlatformAssetURLListed = true
+		}
+	}
+	if !isPlatformAssetURLListed {
+		assetURLs = append(assetURLs, platformAssetURL)
+	}
+
+	downloadedCount := 0
+
+	for _, assetURL := range assetURLs {
+		var downloadURL, filePathURL *url.URL
+
+		if network.IsGitHubDirectDownloadURL(assetURL) {
+			gitHubMirrorURL, err := ctx.GitHubMirrorURL()
+			if err != nil {
+				return downloadedCount, fmt.Errorf("failed to get GitHub mirror URL\n\t%w", err)
+			}
+
+			downloadURL, err = network.GenerateGitHubMirrorURL(assetURL, gitHubMirrorURL)
+			if err != nil {
+				return downloadedCount, fmt.Errorf("failed to generate GitHub mirror URL\n\t%w", err)
+			}
+
+			filePathURL = &url.URL{Path: "/" + GitHubDirName + assetURL.Path}
+
+		} else if assetURL.Scheme == "http" || assetURL.Scheme == "https" {
+			ctx.Logger().Warnf(i18n.T("Skipped asset archive %v of %v@%v, since only asset archives from GitHub or Go module paths can be mirrored."),
+				assetURL, toothRepoPath, version)
+			continue
+
+		} else if err := module.CheckPath(assetURL.String()); err == nil {
+			goModuleProxyURL, err := ctx.GoModuleProxyURL()
+			if err != nil {
+				return downloadedCount, fmt.Errorf("failed to get Go module proxy URL\n\t%w", err)
+			}
+
+			downloadURL, err = network.GenerateGoModuleZipFileURL(assetURL.String(), version, goModuleProxyURL)
+			if err != nil {
+				return downloadedCount, fmt.Errorf("failed to generate Go module zip file URL\n\t%w", err)
+			}
+
+			filePathURL, err = network.GenerateGoModuleZipFileURL(assetURL.String(), version, &url.URL{Path: "/"})
+			if err != nil {
+				return downloadedCount, fmt.Errorf("failed to generate Go module zip file URL\n\t%w", err)
+			}
+
+		} else {
+			return downloadedCount, i18n.Errorf("unsupported asset URL: %v", assetURL)
+		}
+
+		downloaded, err := downloadFile(ctx, dir, downloadURL, filePathURL, toothRepoPath, version,
+			sumdb.AssetArchiveKind, assetURL.String() == platformAssetURL.String())
+		if err != nil {
+			return downloadedCount, fmt.Errorf("failed to mirror asset archive %v\n\t%w", assetURL, err)
+		}
+		if downloaded {
+			downloadedCount++
+		}
+	}
+
+	return downloadedCount, nil
+}
+
+// mirrorTooth mirrors the registry entry of a tooth and the archives of its versions, and
+// writes the version list of the mirrored versions.
+func mirrorTooth(ctx *context.Context, dir path.Path, toothRepoPath string, options Options) (int, error) {
+	entry, err := registry.GetEntry(ctx, toothRepoPath)
+	if err != nil {
+		return 0, err
+	}
+
+	goModuleProxyURL, err := ctx.GoModuleProxyURL()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get Go module proxy URL\n\t%w", err)
+	}
+
+	// A tooth in a subdirectory is mirrored with the Go module of its repository.
+	goModulePath, _ := network.SplitSubtooth(toothRepoPath)
+
+	downloadedCount := 0
+	versionStrings := make([]string, 0)
+
+	for _, version := range getVersions(entry, options.LatestOnly) {
+		downloadURL, err := network.GenerateGoModuleZipFileURL(goModulePath, version, goModuleProxyURL)
+		if err != nil {
+			// e.g. versions with build metadata, which the Go module proxy cannot serve.
+			ctx.Logger().Warnf(i18n.T("Skipped %v@%v: %v"), toothRepoPath, version, err)
+			continue
+		}
+
+		filePathURL, err := network.GenerateGoModuleZipFileURL(goModulePath, version, &url.URL{Path: "/"})
+		if err != nil {
+			return downloadedCount, fmt.Errorf("failed to generate Go module zip file URL\n\t%w", err)
+		}
+
+		downloaded, err := downloadFile(ctx, dir, downloadURL, filePathURL, toothRepoPath, version,
+			sumdb.ToothArchiveKind, true)
+		if err != nil {
+			return downloadedCount, fmt.Errorf("failed to mirror tooth archive of %v@%v\n\t%w", toothRepoPath,
+				version, err)
+		}
+		if downloaded {
+			downloadedCount++
+		}
+
+		if !options.SkipAssets {
+			archivePath, err := getFilePath(dir, filePathURL)
+			if err != nil {
+				return downloadedCount, err
+			}
+
+			count, err := mirrorAssets(ctx, dir, archivePath, toothRepoPath, version)
+			downloadedCount += count
+			if err != nil {
+				return downloadedCount, err
+			}
+		}
+
+		// The version list of the Go module proxy has the same suffix as the zip file name.
+		versionStrings = append(versionStrings,
+			strings.TrimSuffix(gopath.Base(filePathURL.Path), ".zip"))
+	}
+
+	versionListURL, err := network.GenerateGoModuleVersionListURL(goModulePath, &url.URL{Path: "/"})
+	if err != nil {
+		return downloadedCount, fmt.Errorf("failed to generate Go module version list URL\n\t%w", err)
+	}
+
+	versionListContent := strings.Join(versionStrings, "\n")
+	if len(versionStrings) != 0 {
+		versionListContent += "\n"
+	}
+
+	if err := writeFile(ctx.FS(), dir, versionListURL, []byte(versionListContent)); err != nil {
+		return downloadedCount, err
+	}
+
+	entryContent, err := json.Marshal(entry)
+	if err != nil {
+		return downloadedCount, fmt.Errorf("failed to marshal registry entry of %v\n\t%w", toothRepoPath, err)
+	}
+
+	entryURL, err := network.GenerateRegistryToothURL(toothRepoPath, &url.URL{Path: "/"})
+	if err != nil {
+		return downloadedCount, fmt.Errorf("failed to generate registry entry URL\n\t%w", err)
+	}
+
+	if err := writeFile(ctx.FS(), dir, entryURL, entryContent); err != nil {
+		return downloadedCount, err
+	}
+
+	return downloadedCount, nil
+}
+
+// writeIndex writes the registry index into the mirror.
+func writeIndex(ctx *context.Context, dir path.Path, indexURL *url.URL) error {
+	filePath, err := getFilePath(dir, indexURL)
+	if err != nil {
+		return err
+	}
+
+	if err := makeParentDir(ctx.FS(), filePath); err != nil {
+		return err
+	}
+
+	file, err := ctx.FS().Create(filePath.LocalString())
+	if err != nil {
+		return fmt.Errorf("failed to create %v\n\t%w", filePath.LocalString(), err)
+	}
+	defer file.Close()
+
+	return registry.CopyIndex(ctx, file)
+}
+
+// writeFile writes a file served at a URL relative to the root of the mirror on a file system,
+// replacing it if it exists.
+func writeFile(fs vfs.FS, dir path.Path, filePathURL *url.URL, content []byte) error {
+	filePath, err := getFilePath(dir, filePathURL)
+	if err != nil {
+		return err
+	}
+
+	if err := makeParentDir(fs, filePath); err != nil {
+		return err
+	}
+
+	if err := fs.WriteFile(filePath.LocalString(), content, 0644); err != nil {
+		return fmt.Errorf("failed to write %v\n\t%w", filePath.LocalString(), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package monorepo
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/lippkg/lip/internal/network"
+	"github.com/lippkg/lip/internal/path"
+	"github.com/lippkg/lip/internal/tooth"
+)
+
+// Manifest is the monorepo manifest at the root of a repository containing several teeth in
+// subdirectories. The tooth repo path of each tooth is the repository path, a "//" and the
+// subdirectory, e.g. github.com/owner/repo//teeth/foo.
+type Manifest struct {
+	FormatVersion int `json:"format_version"`
+	// Repo is the repository path, e.g. github.com/owner/repo.
+	Repo string `json:"repo"`
+	// Teeth is the subdirectories of the teeth, relative to the root of the repository.
+	Teeth []string `json:"teeth"`
+}
+
+const expectedFormatVersion = 1
+
+// ManifestFileName is the name of the monorepo manifest.
+const ManifestFileName = "monorepo.json"
+
+// MakeManifest parses the given jsonBytes and returns a Manifest.
+func MakeManifest(jsonBytes []byte) (Manifest, error) {
+	var manifest Manifest
+	if err := json.Unmarshal(jsonBytes, &manifest); err != nil {
+		return Manifest{}, fmt.Errorf("failed to unmarshal monorepo manifest\n\t%w", err)
+	}
+
+	if manifest.FormatVersion != expectedFormatVersion {
+		return Manifest{}, fmt.Errorf("unsupported format version: %v", manifest.FormatVersion)
+	}
+
+	if repoPath, subdir := network.SplitSubtooth(manifest.Repo); subdir != "" || repoPath == "" ||
+		!tooth.IsValidToothRepoPath(manifest.Repo) {
+		return Manifest{}, fmt.Errorf("invalid repository path %v", manifest.Repo)
+	}
+
+	if len(manifest.Teeth) == 0 {
+		return Manifest{}, fmt.Errorf("no teeth in monorepo manifest")
+	}
+
+	subdirSet := make(map[string]bool)
+	for _, subdir := range manifest.Teeth {
+		subdirPath, err := tooth.ParseWorkspaceDir(subdir)
+		if err != nil || subdirPath.IsEmpty() {
+			return Manifest{}, fmt.Errorf("invalid tooth subdirectory %v", subdir)
+		}
+
+		if !tooth.IsValidToothRepoPath(manifest.ToothRepoPath(subdirPath)) {
+			return Manifest{}, fmt.Errorf("invalid tooth subdirectory %v", subdir)
+		}
+
+		if subdirSet[subdirPath.String()] {
+			return Manifest{}, fmt.Errorf("tooth subdirectory %v is listed twice", subdir)
+		}
+		subdirSet[subdirPath.String()] = true
+	}
+
+	return manifest, nil
+}
+
+// LoadManifest loads the monorepo manifest in a directory. The second return value is false if
+// there is none.
+func LoadManifest(dir string) (Manifest, bool, error) {
+	manifestPath := filepath.Join(dir, ManifestFileName)
+
+	jsonBytes, err := os.ReadFile(manifestPath)
+	if os.IsNotExist(err) {
+		return Manifest{}, false, nil
+	} else if err != nil {
+		return Manifest{}, false, fmt.Errorf("failed to read monorepo manifest %v\n\t%w", manifestPath, err)
+	}
+
+	manifest, err := MakeManifest(jsonBytes)
+	if err != nil {
+		return Manifest{}, false, fmt.Errorf("failed to parse monorepo manifest %v\n\t%w", manifestPath, err)
+	}
+
+	return manifest, true, nil
+}
+
+// Subdirs returns the subdirectories of the teeth in the order they are listed.
+func (m Manifest) Subdirs() []path.Path {
+	subdirs := make([]path.Path, 0, len(m.Teeth))
+	for _, subdir := range m.Teeth {
+		// Subdirectories are validated by MakeManifest.
+		subdirPath, _ := tooth.ParseWorkspaceDir(subdir)
+		subdirs = append(subdirs, subdirPath)
+	}
+
+	return subdirs
+}
+
+// ToothRepoPath returns the tooth repo path of the tooth in a subdirectory.
+func (m Manifest) ToothRepoPath(subdir path.Path) string {
+	return m.Repo + network.SubtoothSeparator + subdir.String()
+}
@@ -0,0 +1,69 @@
+package network
+
+import (
+	"time"
+
+	"github.com/lippkg/lip/internal/vfs"
+	log "github.com/sirupsen/logrus"
+)
+
+// Client sends requests and downloads files with the network configuration of lip, e.g. the
+// rate limit, the dialer configuration and the credentials of hosts. It is safe for concurrent
+// use, and downloads sent with the same client share its total rate limit.
+type Client struct {
+	httpClient       HTTPClient
+	fs               vfs.FS
+	dialerConfig     DialerConfig
+	rateLimit        RateLimit
+	totalLimiter     *limiter
+	downloadTimeout  time.Duration
+	credentialLookup CredentialLookup
+	logger           log.FieldLogger
+}
+
+// ClientConfig configures a client.
+type ClientConfig struct {
+	// HTTPClient sends all requests, ignoring the proxy and the dialer configuration. Nil means
+	// a client made from them.
+	HTTPClient HTTPClient
+	// FS is the file system downloaded files and cached content are written to. Nil means the
+	// file system of the operating system.
+	FS vfs.FS
+	// Logger is where warnings, e.g. about retries, and debug logs are written. Nil means the
+	// standard logger.
+	Logger log.FieldLogger
+	// DialerConfig controls how connections are made, e.g. DefaultDialerConfig().
+	DialerConfig DialerConfig
+	// RateLimit caps the bandwidth of downloads.
+	RateLimit RateLimit
+	// DownloadTimeout is how long each attempt of downloads and content requests may take. A
+	// timed out attempt is retried like other network failures. 0 means no limit.
+	DownloadTimeout time.Duration
+	// CredentialLookup looks up the credentials attached to requests. Nil means requests are
+	// not authenticated unless they have an Authorization header.
+	CredentialLookup CredentialLookup
+}
+
+// NewClient makes a client with a configuration.
+func NewClient(config ClientConfig) *Client {
+	fs := config.FS
+	if fs == nil {
+		fs = vfs.OS()
+	}
+
+	logger := config.Logger
+	if logger == nil {
+		logger = log.StandardLogger()
+	}
+
+	return &Client{
+		httpClient:       config.HTTPClient,
+		fs:               fs,
+		dialerConfig:     config.DialerConfig,
+		rateLimit:        config.RateLimit,
+		totalLimiter:     newLimiter(config.RateLimit.Total),
+		downloadTimeout:  config.DownloadTimeout,
+		credentialLookup: config.CredentialLookup,
+		logger:           logger,
+	}
+}
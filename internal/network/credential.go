@@ -0,0 +1,65 @@
+package network
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// Credential authenticates requests to a host, with a bearer token if Token is set, or with
+// basic authentication otherwise.
+type Credential struct {
+	Token    string
+	Username string
+	Password string
+}
+
+// CredentialLookup returns the credential of a host, e.g. registry.example.com or
+// 127.0.0.1:8080. The second return value is false if there is none.
+type CredentialLookup func(host string) (Credential, bool)
+
+// HasCredential returns whether requests to a host are authenticated with a credential.
+func (c *Client) HasCredential(host string) bool {
+	_, ok := c.LookupCredential(host)
+	return ok
+}
+
+// LookupCredential returns the credential of a host, or of its hostname without the port if
+// there is none for the host. The second return value is false if there is neither.
+func (c *Client) LookupCredential(host string) (Credential, bool) {
+	if c.credentialLookup == nil || host == "" {
+		return Credential{}, false
+	}
+
+	if credential, ok := c.credentialLookup(host); ok {
+		return credential, true
+	}
+
+	hostURL := url.URL{Host: host}
+	if hostname := hostURL.Hostname(); hostname != host {
+		return c.credentialLookup(hostname)
+	}
+
+	return Credential{}, false
+}
+
+// ---------------------------------------------------------------------
+
+// setAuthorization authenticates a request with the credential of its host, unless it already
+// has an Authorization header. Go drops the header when a redirect leaves the host, so the
+// credential is never sent elsewhere.
+func (c *Client) setAuthorization(req *http.Request) {
+	if req.Header.Get("Authorization") != "" {
+		return
+	}
+
+	credential, ok := c.LookupCredential(req.URL.Host)
+	if !ok {
+		return
+	}
+
+	if credential.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+credential.Token)
+	} else {
+		req.SetBasicAuth(credential.Username, credential.Password)
+	}
+}
@@ -0,0 +1,140 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// DialerConfig controls how connections are made.
+type DialerConfig struct {
+	// DNSServers are the DNS servers to resolve hosts with, as host:port, tried in order. Empty
+	// means the DNS servers of the system.
+	DNSServers []string
+	// HostOverrides maps hosts to the IP addresses to connect to instead of resolving them.
+	HostOverrides map[string]string
+	// FallbackDelay is how long to wait for a connection over the preferred IP version before
+	// racing one over the other (happy eyeballs). 0 means the default of 300ms, and a negative
+	// value disables racing.
+	FallbackDelay time.Duration
+	// Timeout is how long to wait for a connection to be made, including to the proxy. 0 means
+	// no limit.
+	Timeout time.Duration
+}
+
+const defaultDNSPort = "53"
+
+// defaultDialTimeout is the timeout of connections made by http.DefaultTransport.
+const defaultDialTimeout = 30 * time.Second
+
+// DefaultDialerConfig returns the dialer configuration leaving dialing as Go does it by
+// default.
+func DefaultDialerConfig() DialerConfig {
+	return DialerConfig{Timeout: defaultDialTimeout}
+}
+
+// ParseDNSServers parses a comma-separated list of DNS servers, each an IP address with an
+// optional port, e.g. 1.1.1.1,[2606:4700:4700::1111]:53.
+func ParseDNSServers(s string) ([]string, error) {
+	servers := make([]string, 0)
+
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		host, port, err := net.SplitHostPort(item)
+		if err != nil {
+			// No port is specified.
+			host = strings.Trim(item, "[]")
+			port = defaultDNSPort
+		}
+
+		if net.ParseIP(host) == nil {
+			return nil, fmt.Errorf("invalid DNS server %v, expected an IP address with an optional port", item)
+		}
+
+		servers = append(servers, net.JoinHostPort(host, port))
+	}
+
+	return servers, nil
+}
+
+// ParseHostOverrides parses a comma-separated list of host overrides, each of the form
+// <host>=<IP address>, e.g. github.com=140.82.112.3,goproxy.io=2001:db8::1.
+func ParseHostOverrides(s string) (map[string]string, error) {
+	overrides := make(map[string]string)
+
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		host, ip, ok := strings.Cut(item, "=")
+		host = strings.ToLower(strings.TrimSpace(host))
+		ip = strings.TrimSpace(ip)
+		if !ok || host == "" || net.ParseIP(ip) == nil {
+			return nil, fmt.Errorf("invalid host override %v, expected <host>=<IP address>", item)
+		}
+
+		overrides[host] = ip
+	}
+
+	return overrides, nil
+}
+
+// ---------------------------------------------------------------------
+
+// isDefault returns whether the dialer config leaves dialing as Go does it by default.
+func (config DialerConfig) isDefault() bool {
+	return len(config.DNSServers) == 0 && len(config.HostOverrides) == 0 && config.FallbackDelay == 0 &&
+		config.Timeout == defaultDialTimeout
+}
+
+// dialContext dials an address according to the dialer config. It replaces the dialer of
+// http.DefaultTransport, whose keep-alive it keeps.
+func (config DialerConfig) dialContext(ctx context.Context, network string, address string) (net.Conn, error) {
+	dialer := &net.Dialer{
+		Timeout:       config.Timeout,
+		KeepAlive:     30 * time.Second,
+		FallbackDelay: config.FallbackDelay,
+	}
+
+	if len(config.DNSServers) != 0 {
+		dialer.Resolver = &net.Resolver{
+			PreferGo: true,
+			Dial:     config.dialDNSServer,
+		}
+	}
+
+	host, port, err := net.SplitHostPort(address)
+	if err == nil {
+		if ip, ok := config.HostOverrides[strings.ToLower(host)]; ok {
+			address = net.JoinHostPort(ip, port)
+		}
+	}
+
+	return dialer.DialContext(ctx, network, address)
+}
+
+// dialDNSServer connects to the first reachable DNS server in the dialer config, ignoring the
+// DNS servers of the system.
+func (config DialerConfig) dialDNSServer(ctx context.Context, network string, _ string) (net.Conn, error) {
+	dialer := &net.Dialer{}
+
+	var lastErr error
+	for _, server := range config.DNSServers {
+		conn, err := dialer.DialContext(ctx, network, server)
+		if err == nil {
+			return conn, nil
+		}
+
+		lastErr = err
+	}
+
+	return nil, fmt.Errorf("cannot connect to any DNS server\n\t%w", lastErr)
+}
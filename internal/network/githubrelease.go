@@ -0,0 +1,32 @@
+package network
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+)
+
+// GitHubReleasePageSize is the number of releases listed per page, the maximum allowed by the
+// GitHub API.
+const GitHubReleasePageSize = 100
+
+// GenerateGitHubReleaseListURL generates the URL of a page of the releases of a GitHub
+// repository. Pages are numbered from 1.
+func GenerateGitHubReleaseListURL(owner string, repo string, page int, gitHubAPIURL *url.URL) (*url.URL, error) {
+	if owner == "" || repo == "" {
+		return nil, fmt.Errorf("invalid GitHub repository %v/%v", owner, repo)
+	}
+
+	resultURL, err := gitHubAPIURL.Parse(path.Join(gitHubAPIURL.Path, "repos", owner, repo, "releases"))
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse GitHub API URL\n\t%w", err)
+	}
+
+	query := resultURL.Query()
+	query.Set("per_page", strconv.Itoa(GitHubReleasePageSize))
+	query.Set("page", strconv.Itoa(page))
+	resultURL.RawQuery = query.Encode()
+
+	return resultURL, nil
+}
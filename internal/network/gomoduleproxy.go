@@ -6,12 +6,19 @@ import (
 	"path"
 
 	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/errcode"
 	"golang.org/x/mod/module"
 )
 
 // GenerateGoModuleVersionListURL generates the URL of the version list of a Go
 // module.
 func GenerateGoModuleVersionListURL(goModulePath string, goProxyURL *url.URL) (*url.URL, error) {
+	if HasPort(goModulePath) {
+		return nil, errcode.Errorf(errcode.InvalidArgument,
+			"%v has a port in its host and cannot be fetched from the Go module proxy. Use an OCI registry instead",
+			goModulePath)
+	}
+
 	if err := module.CheckPath(goModulePath); err != nil {
 		return nil, fmt.Errorf("%v is not a Go module path", goModulePath)
 	}
@@ -0,0 +1,142 @@
+package network
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/idna"
+)
+
+// confusableScripts are the scripts whose letters are easily mistaken for each other.
+var confusableScripts = map[string]*unicode.RangeTable{
+	"Latin":    unicode.Latin,
+	"Cyrillic": unicode.Cyrillic,
+	"Greek":    unicode.Greek,
+	"Armenian": unicode.Armenian,
+}
+
+// confusables maps letters of other scripts to the Latin letters they look like.
+var confusables = map[rune]rune{
+	// Cyrillic.
+	'а': 'a', 'в': 'b', 'с': 'c', 'ԁ': 'd', 'е': 'e', 'һ': 'h', 'і': 'i', 'ј': 'j', 'к': 'k',
+	'ӏ': 'l', 'м': 'm', 'о': 'o', 'р': 'p', 'ԛ': 'q', 'ѕ': 's', 'т': 't', 'у': 'y', 'ԝ': 'w',
+	'х': 'x', 'ү': 'y',
+	// Greek.
+	'α': 'a', 'ε': 'e', 'ι': 'i', 'κ': 'k', 'ν': 'v', 'ο': 'o', 'ρ': 'p', 'τ': 't', 'υ': 'u',
+	'χ': 'x', 'γ': 'y',
+	// Armenian.
+	'ց': 'g', 'հ': 'h', 'ո': 'n', 'օ': 'o', 'զ': 'q', 'ս': 'u',
+	// Latin letters other than ASCII.
+	'ı': 'i', 'ɑ': 'a', 'ɡ': 'g', 'ɩ': 'i', 'ʋ': 'u',
+}
+
+// Homograph is an internationalized host of a tooth repo path that may be mistaken for
+// another one.
+type Homograph struct {
+	// UnicodeHost is the host as displayed, e.g. аpple.com for xn--pple-43d.com.
+	UnicodeHost string
+
+	// Lookalike is the ASCII host it looks like, e.g. apple.com, or empty if it mixes
+	// scripts without looking like an ASCII host.
+	Lookalike string
+}
+
+// DetectHomograph returns whether the host of a tooth repo path is an internationalized
+// domain name that may be mistaken for another host, since a label mixes Latin, Cyrillic,
+// Greek or Armenian letters, or consists only of letters that look like Latin ones.
+func DetectHomograph(toothRepoPath string) (Homograph, bool) {
+	hostname, port, hasPort, _ := splitToothRepoPath(toothRepoPath)
+	if !strings.Contains(hostname, "xn--") {
+		return Homograph{}, false
+	}
+
+	unicodeHostname, err := idna.Lookup.ToUnicode(hostname)
+	if err != nil {
+		return Homograph{}, false
+	}
+
+	isHomograph := false
+	lookalikeLabels := make([]string, 0)
+	for _, label := range strings.Split(unicodeHostname, ".") {
+		if isMixedScriptLabel(label) {
+			isHomograph = true
+		}
+
+		lookalikeLabel, isLookalike := getLookalikeLabel(label)
+		if isLookalike && lookalikeLabel != label {
+			isHomograph = true
+		}
+
+		lookalikeLabels = append(lookalikeLabels, lookalikeLabel)
+	}
+
+	if !isHomograph {
+		return Homograph{}, false
+	}
+
+	lookalike := strings.Join(lookalikeLabels, ".")
+	if !isASCII(lookalike) {
+		lookalike = ""
+	}
+
+	if hasPort {
+		unicodeHostname += ":" + port
+		if lookalike != "" {
+			lookalike += ":" + port
+		}
+	}
+
+	return Homograph{
+		UnicodeHost: unicodeHostname,
+		Lookalike:   lookalike,
+	}, true
+}
+
+// ---------------------------------------------------------------------
+
+// getLookalikeLabel returns a label with the letters of other scripts that look like Latin
+// ones replaced by them, and whether every letter of it is Latin or looks like one.
+func getLookalikeLabel(label string) (string, bool) {
+	builder := &strings.Builder{}
+	isLookalike := true
+	for _, r := range label {
+		if latin, ok := confusables[r]; ok {
+			builder.WriteRune(latin)
+			continue
+		}
+
+		if r > unicode.MaxASCII {
+			isLookalike = false
+		}
+
+		builder.WriteRune(r)
+	}
+
+	return builder.String(), isLookalike
+}
+
+// isASCII returns whether a string has only ASCII characters.
+func isASCII(s string) bool {
+	for _, r := range s {
+		if r > unicode.MaxASCII {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isMixedScriptLabel returns whether a label has letters of more than one of the confusable
+// scripts.
+func isMixedScriptLabel(label string) bool {
+	scripts := make(map[string]bool)
+	for _, r := range label {
+		for script, rangeTable := range confusableScripts {
+			if unicode.Is(rangeTable, r) {
+				scripts[script] = true
+			}
+		}
+	}
+
+	return len(scripts) > 1
+}
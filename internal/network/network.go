@@ -1,38 +1,135 @@
 package network
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
-	"os"
 
+	"github.com/lippkg/lip/internal/errcode"
 	"github.com/lippkg/lip/internal/path"
 	"github.com/schollz/progressbar/v3"
 )
 
-// DownloadFile downloads a file from a url and saves it to a local path.
-func DownloadFile(url *url.URL, proxyURL *url.URL, filePath path.Path, enableProgressBar bool) error {
-	httpClient := getProxiedHTTPClient(proxyURL)
+// HTTPClient sends HTTP requests. *http.Client implements it.
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// DownloadFile downloads a file from a url and saves it to a local path. Failed downloads are
+// retried according to the retry policy. It returns the hex-encoded SHA-256 checksum of the
+// file, calculated over the stream while downloading, so that the file need not be read again
+// to verify it. The download is aborted when ctx is canceled.
+// Extra headers, e.g. for authentication, may be nil.
+func (c *Client) DownloadFile(ctx context.Context, url *url.URL, proxyURL *url.URL, header http.Header,
+	filePath path.Path, enableProgressBar bool, retryPolicy RetryPolicy) (string, error) {
+	var checksum string
+	err := c.withRetry(ctx, retryPolicy, func() (bool, error) {
+		var isRetryable bool
+		var err error
+		checksum, isRetryable, err = c.downloadFile(ctx, url, proxyURL, header, filePath, enableProgressBar, 0)
+		return isRetryable, err
+	})
+
+	return checksum, err
+}
+
+// DownloadFileWithMaxSize downloads a file like DownloadFile, but fails without retrying if the
+// file is larger than maxSize bytes, e.g. when it comes from an untrusted host. The download is
+// aborted as soon as the size is exceeded, instead of after the whole file is written.
+func (c *Client) DownloadFileWithMaxSize(ctx context.Context, url *url.URL, proxyURL *url.URL, header http.Header,
+	filePath path.Path, maxSize int64, retryPolicy RetryPolicy) (string, error) {
+	var checksum string
+	err := c.withRetry(ctx, retryPolicy, func() (bool, error) {
+		var isRetryable bool
+		var err error
+		checksum, isRetryable, err = c.downloadFile(ctx, url, proxyURL, header, filePath, false, maxSize)
+		return isRetryable, err
+	})
+
+	return checksum, err
+}
+
+// GetContent gets the content at once of a URL. Failed requests are retried according to the
+// retry policy, until ctx is canceled. Extra headers, e.g. for authentication, may be nil.
+func (c *Client) GetContent(ctx context.Context, url *url.URL, proxyURL *url.URL, header http.Header,
+	retryPolicy RetryPolicy) ([]byte, error) {
+	var content []byte
+	err := c.withRetry(ctx, retryPolicy, func() (bool, error) {
+		var isRetryable bool
+		var err error
+		content, isRetryable, err = c.getContent(ctx, url, proxyURL, header)
+		return isRetryable, err
+	})
+
+	return content, err
+}
+
+// IsNotFound returns whether an error is caused by an HTTP 404 Not Found response.
+func IsNotFound(err error) bool {
+	var statusErr *statusError
+	return errors.As(err, &statusErr) && statusErr.statusCode == http.StatusNotFound
+}
+
+// SendRequest sends a request through the proxy and the dialer, authenticated with the
+// credential of its host unless it has an Authorization header. It is not retried, and a
+// response of any status is returned. It is canceled with the context of the request. The
+// caller must close the body of the response.
+func (c *Client) SendRequest(req *http.Request, proxyURL *url.URL) (*http.Response, error) {
+	c.setAuthorization(req)
+
+	resp, err := c.getHTTPClient(proxyURL).Do(req)
+	if err != nil {
+		return nil, errcode.Errorf(errcode.Network, "cannot send HTTP request\n\t%w", err)
+	}
+
+	return resp, nil
+}
 
-	resp, err := httpClient.Get(url.String())
+// ---------------------------------------------------------------------
+
+// downloadFile makes one attempt to download a file and returns its checksum. A maxSize of 0
+// means no limit. The second return value indicates whether the error is retryable.
+func (c *Client) downloadFile(ctx context.Context, url *url.URL, proxyURL *url.URL, header http.Header,
+	filePath path.Path, enableProgressBar bool, maxSize int64) (string, bool, error) {
+	ctx, cancel := c.withDownloadTimeout(ctx)
+	defer cancel()
+
+	resp, err := c.doGetRequest(ctx, url, proxyURL, header)
 	if err != nil {
-		return fmt.Errorf("cannot send HTTP request\n\t%w", err)
+		return "", true, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("cannot download file (HTTP %v): %v", resp.Status, url)
+		if err := getRateLimitError(resp, url); err != nil {
+			return "", true, err
+		}
+
+		return "", isRetryableStatusCode(resp.StatusCode), &statusError{
+			statusCode: resp.StatusCode,
+			err:        errcode.Errorf(errcode.Network, "cannot download file (HTTP %v): %v", resp.Status, url),
+		}
+	}
+
+	if maxSize > 0 && resp.ContentLength > maxSize {
+		return "", false, errcode.Errorf(errcode.Network, "file at %v is larger than %v bytes", url, maxSize)
 	}
 
 	// Create the file
-	file, err := os.Create(filePath.LocalString())
+	file, err := c.fs.Create(filePath.LocalString())
 	if err != nil {
-		return fmt.Errorf("cannot create file\n\t%w", err)
+		return "", false, fmt.Errorf("cannot create file\n\t%w", err)
 	}
 	defer file.Close()
 
-	var writer io.Writer = file
+	// Hash the content as it is written.
+	hash := sha256.New()
+	writer := io.MultiWriter(file, hash)
 
 	if enableProgressBar {
 		bar := progressbar.NewOptions64(
@@ -41,43 +138,132 @@ func DownloadFile(url *url.URL, proxyURL *url.URL, filePath path.Path, enablePro
 			progressbar.OptionShowBytes(true),
 			progressbar.OptionShowCount(),
 		)
-		writer = io.MultiWriter(file, bar)
+		writer = io.MultiWriter(file, hash, bar)
+	}
+
+	reader := c.newRateLimitedReader(resp.Body)
+	if maxSize > 0 {
+		// Read one byte more than allowed to tell files of exactly maxSize bytes from larger ones.
+		reader = io.LimitReader(reader, maxSize+1)
+	}
+
+	size, err := io.Copy(writer, reader)
+	if err != nil {
+		return "", true, errcode.Errorf(errcode.Network, "cannot download file from %v\n\t%w", url, err)
 	}
 
-	if _, err := io.Copy(writer, resp.Body); err != nil {
-		return fmt.Errorf("cannot download file from %v\n\t%w", url, err)
+	if maxSize > 0 && size > maxSize {
+		return "", false, errcode.Errorf(errcode.Network, "file at %v is larger than %v bytes", url, maxSize)
 	}
-	return nil
+
+	return hex.EncodeToString(hash.Sum(nil)), false, nil
+}
+
+// statusError is an error of a request that got a response of an unexpected status.
+type statusError struct {
+	statusCode int
+	err        error
+}
+
+func (e *statusError) Error() string {
+	return e.err.Error()
+}
+
+func (e *statusError) Unwrap() error {
+	return e.err
+}
+
+// getContent makes one attempt to get the content of a URL. The second return value indicates
+// whether the error is retryable.
+func (c *Client) getContent(ctx context.Context, url *url.URL, proxyURL *url.URL, header http.Header) ([]byte, bool,
+	error) {
+	response, isRetryable, err := c.sendGetRequest(ctx, url, proxyURL, header)
+	if err != nil {
+		return nil, isRetryable, err
+	}
+
+	return response.content, false, nil
+}
+
+// getResponse is the result of a GET request.
+type getResponse struct {
+	content       []byte
+	header        http.Header
+	isNotModified bool
 }
 
-// GetContent gets the content at once of a URL.
-func GetContent(url *url.URL, proxyURL *url.URL) ([]byte, error) {
-	httpClient := getProxiedHTTPClient(proxyURL)
+// sendGetRequest makes one attempt to send a GET request with extra headers. A 304 Not
+// Modified response is not an error. The second return value indicates whether the error is
+// retryable.
+func (c *Client) sendGetRequest(ctx context.Context, url *url.URL, proxyURL *url.URL, header http.Header) (getResponse,
+	bool, error) {
+	ctx, cancel := c.withDownloadTimeout(ctx)
+	defer cancel()
 
-	resp, err := httpClient.Get(url.String())
+	resp, err := c.doGetRequest(ctx, url, proxyURL, header)
 	if err != nil {
-		return nil, fmt.Errorf("cannot send HTTP request\n\t%w", err)
+		return getResponse{}, true, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified {
+		return getResponse{header: resp.Header, isNotModified: true}, false, nil
+	}
+
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("cannot get content (HTTP %v): %v", resp.Status, url)
+		if err := getRateLimitError(resp, url); err != nil {
+			return getResponse{}, true, err
+		}
+
+		return getResponse{}, isRetryableStatusCode(resp.StatusCode), &statusError{
+			statusCode: resp.StatusCode,
+			err:        errcode.Errorf(errcode.Network, "cannot get content (HTTP %v): %v", resp.Status, url),
+		}
 	}
 
-	content, err := io.ReadAll(resp.Body)
+	content, err := io.ReadAll(c.newRateLimitedReader(resp.Body))
+	if err != nil {
+		return getResponse{}, true, errcode.Errorf(errcode.Network, "cannot read HTTP response\n\t%w", err)
+	}
+
+	return getResponse{content: content, header: resp.Header}, false, nil
+}
+
+// doGetRequest sends a GET request with extra headers, canceled with ctx. The caller must close
+// the body of the response.
+func (c *Client) doGetRequest(ctx context.Context, url *url.URL, proxyURL *url.URL, header http.Header) (*http.Response,
+	error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url.String(), nil)
 	if err != nil {
-		return nil, fmt.Errorf("cannot read HTTP response\n\t%w", err)
+		return nil, fmt.Errorf("cannot create HTTP request\n\t%w", err)
+	}
+
+	for key, values := range header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
 	}
 
-	return content, nil
+	return c.SendRequest(req, proxyURL)
 }
 
-func getProxiedHTTPClient(proxyURL *url.URL) *http.Client {
-	if proxyURL.String() == "" {
+// getHTTPClient returns the HTTP client of the configuration, or one sending requests through the
+// proxy and the dialer.
+func (c *Client) getHTTPClient(proxyURL *url.URL) HTTPClient {
+	if c.httpClient != nil {
+		return c.httpClient
+	}
+
+	if proxyURL.String() == "" && c.dialerConfig.isDefault() {
 		return http.DefaultClient
 	}
 
 	transport := http.DefaultTransport.(*http.Transport).Clone()
-	transport.Proxy = http.ProxyURL(proxyURL)
+	if proxyURL.String() != "" {
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	if !c.dialerConfig.isDefault() {
+		transport.DialContext = c.dialerConfig.dialContext
+	}
 	return &http.Client{Transport: transport}
 }
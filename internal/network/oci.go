@@ -0,0 +1,71 @@
+package network
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+)
+
+// OCITagPageSize is the number of tags requested per page of a tag list.
+const OCITagPageSize = 1000
+
+// GenerateOCITagListURL generates the URL of a page of the tags of a repository in an OCI
+// registry. The page starts after the tag last, or at the first tag if last is empty.
+func GenerateOCITagListURL(registryURL *url.URL, repository string, last string) (*url.URL, error) {
+	resultURL, err := generateOCIURL(registryURL, repository, "tags", "list")
+	if err != nil {
+		return nil, err
+	}
+
+	query := resultURL.Query()
+	query.Set("n", strconv.Itoa(OCITagPageSize))
+	if last != "" {
+		query.Set("last", last)
+	}
+	resultURL.RawQuery = query.Encode()
+
+	return resultURL, nil
+}
+
+// GenerateOCIManifestURL generates the URL of a manifest in an OCI registry, referenced by a
+// tag or a digest.
+func GenerateOCIManifestURL(registryURL *url.URL, repository string, reference string) (*url.URL, error) {
+	return generateOCIURL(registryURL, repository, "manifests", reference)
+}
+
+// GenerateOCIBlobURL generates the URL of a blob in an OCI registry, e.g. a layer, referenced
+// by its digest.
+func GenerateOCIBlobURL(registryURL *url.URL, repository string, digest string) (*url.URL, error) {
+	return generateOCIURL(registryURL, repository, "blobs", digest)
+}
+
+// GenerateOCIBlobUploadURL generates the URL to start uploading a blob to an OCI registry.
+func GenerateOCIBlobUploadURL(registryURL *url.URL, repository string) (*url.URL, error) {
+	resultURL, err := generateOCIURL(registryURL, repository, "blobs", "uploads")
+	if err != nil {
+		return nil, err
+	}
+
+	// The trailing slash is required by the distribution spec.
+	resultURL.Path += "/"
+
+	return resultURL, nil
+}
+
+// ---------------------------------------------------------------------
+
+// generateOCIURL generates the URL of an endpoint of a repository in an OCI registry, i.e.
+// /v2/<repository>/<elements...>. Only the scheme and host of the registry URL are used.
+func generateOCIURL(registryURL *url.URL, repository string, elements ...string) (*url.URL, error) {
+	if repository == "" {
+		return nil, fmt.Errorf("empty OCI repository")
+	}
+
+	resultURL, err := registryURL.Parse(path.Join(append([]string{"/v2", repository}, elements...)...))
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse OCI registry URL\n\t%w", err)
+	}
+
+	return resultURL, nil
+}
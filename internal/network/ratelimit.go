@@ -0,0 +1,149 @@
+package network
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimit caps the bandwidth of downloads in bytes per second. 0 means no limit.
+type RateLimit struct {
+	// Total is the cap shared by all downloads of lip.
+	Total int64
+	// PerDownload is the cap of each download.
+	PerDownload int64
+}
+
+// minReadSize is the smallest chunk read at a time from a rate-limited response body, so that
+// very low rates do not make reads byte by byte.
+const minReadSize = 512
+
+// ParseRate parses a rate in bytes per second, e.g. 512K, 2M or 1G, where the suffixes are
+// powers of 1024. An empty string or 0 means no limit.
+func ParseRate(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	multipliers := map[string]int64{
+		"K": 1 << 10,
+		"M": 1 << 20,
+		"G": 1 << 30,
+	}
+
+	numberString := s
+	multiplier := int64(1)
+	if m, ok := multipliers[strings.ToUpper(s[len(s)-1:])]; ok {
+		numberString = s[:len(s)-1]
+		multiplier = m
+	}
+
+	number, err := strconv.ParseFloat(numberString, 64)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("invalid rate %v, expected e.g. 512K, 2M or 1G", s)
+	}
+
+	return int64(number * float64(multiplier)), nil
+}
+
+// ---------------------------------------------------------------------
+
+// limiter spaces out reads so that their average rate stays within a cap. Each read reserves
+// the time it takes to transfer its bytes at the cap, and waits until the reservations before
+// it have passed. It is safe for concurrent use.
+type limiter struct {
+	bytesPerSecond int64
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// newLimiter returns a limiter capping the rate in bytes per second, or nil if the rate is not
+// positive, i.e. no limit.
+func newLimiter(bytesPerSecond int64) *limiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+
+	return &limiter{bytesPerSecond: bytesPerSecond}
+}
+
+// reserve reserves the time to transfer n bytes within the cap, and returns how long to wait
+// before the reservation starts.
+func (l *limiter) reserve(n int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(time.Duration(int64(n) * int64(time.Second) / l.bytesPerSecond))
+
+	return delay
+}
+
+// rateLimitedReader is a reader whose reads are capped by the total limiter and a limiter of
+// its own.
+type rateLimitedReader struct {
+	reader   io.Reader
+	limiters []*limiter
+	readSize int
+}
+
+// newRateLimitedReader wraps a response body in the caps of the client. The body is returned as
+// is if there is no cap.
+func (c *Client) newRateLimitedReader(reader io.Reader) io.Reader {
+	limiters := make([]*limiter, 0, 2)
+	minRate := int64(0)
+
+	for _, l := range []*limiter{c.totalLimiter, newLimiter(c.rateLimit.PerDownload)} {
+		if l == nil {
+			continue
+		}
+
+		limiters = append(limiters, l)
+		if minRate == 0 || l.bytesPerSecond < minRate {
+			minRate = l.bytesPerSecond
+		}
+	}
+
+	if len(limiters) == 0 {
+		return reader
+	}
+
+	// Read about a tenth of a second's worth at a time to keep the rate smooth.
+	readSize := int(minRate / 10)
+	if readSize < minReadSize {
+		readSize = minReadSize
+	}
+
+	return &rateLimitedReader{
+		reader:   reader,
+		limiters: limiters,
+		readSize: readSize,
+	}
+}
+
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	if len(p) > r.readSize {
+		p = p[:r.readSize]
+	}
+
+	n, err := r.reader.Read(p)
+
+	// Wait for the slowest of the caps.
+	var delay time.Duration
+	for _, l := range r.limiters {
+		if d := l.reserve(n); d > delay {
+			delay = d
+		}
+	}
+	time.Sleep(delay)
+
+	return n, err
+}
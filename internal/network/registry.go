@@ -0,0 +1,44 @@
+package network
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+)
+
+// GenerateRegistryToothURL generates the URL of the registry entry of a tooth.
+func GenerateRegistryToothURL(toothRepoPath string, registryURL *url.URL) (*url.URL, error) {
+	escapedPath, err := EscapeToothRepoPath(toothRepoPath)
+	if err != nil {
+		return nil, fmt.Errorf("%v is not a tooth repo path\n\t%w", toothRepoPath, err)
+	}
+
+	resultURL, err := registryURL.Parse(path.Join(registryURL.Path, "teeth", escapedPath+".json"))
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse registry URL\n\t%w", err)
+	}
+
+	return resultURL, nil
+}
+
+// GenerateRegistryAdvisoryDBURL generates the URL of the security advisory database of the
+// registry.
+func GenerateRegistryAdvisoryDBURL(registryURL *url.URL) (*url.URL, error) {
+	resultURL, err := registryURL.Parse(path.Join(registryURL.Path, "advisories.json"))
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse registry URL\n\t%w", err)
+	}
+
+	return resultURL, nil
+}
+
+// GenerateRegistryIndexURL generates the URL of the registry index, which lists all teeth in
+// the registry.
+func GenerateRegistryIndexURL(registryURL *url.URL) (*url.URL, error) {
+	resultURL, err := registryURL.Parse(path.Join(registryURL.Path, "index.json"))
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse registry URL\n\t%w", err)
+	}
+
+	return resultURL, nil
+}
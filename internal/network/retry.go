@@ -0,0 +1,152 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+)
+
+// RetryPolicy controls how failed network operations are retried. The backoff doubles after
+// each attempt.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts. 1 or less disables retries.
+	MaxAttempts int
+	// Backoff is the time to wait before the first retry.
+	Backoff time.Duration
+	// Budget is the maximum total time to wait between attempts. 0 means no limit.
+	Budget time.Duration
+}
+
+// NoRetry is a RetryPolicy that makes only one attempt.
+var NoRetry = RetryPolicy{MaxAttempts: 1}
+
+// rateLimitError is an error of a request rejected by rate limiting. It tells how long to wait
+// before the limit resets.
+type rateLimitError struct {
+	wait time.Duration
+	err  error
+}
+
+func (e *rateLimitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *rateLimitError) Unwrap() error {
+	return e.err
+}
+
+// IsRateLimited returns whether an error is caused by a request rejected by rate limiting.
+func IsRateLimited(err error) bool {
+	var rateLimitErr *rateLimitError
+	return errors.As(err, &rateLimitErr)
+}
+
+// getRateLimitError returns an error if a response rejects the request by rate limiting, as
+// told by the Retry-After header or the X-RateLimit-Remaining and X-RateLimit-Reset headers
+// of GitHub. Otherwise nil is returned.
+func getRateLimitError(resp *http.Response, url *url.URL) error {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return nil
+	}
+
+	var wait time.Duration
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		wait = time.Duration(seconds) * time.Second
+
+	} else if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+		if err != nil {
+			return nil
+		}
+
+		wait = time.Until(time.Unix(reset, 0))
+		if wait < 0 {
+			wait = 0
+		}
+
+	} else {
+		return nil
+	}
+
+	return &rateLimitError{
+		wait: wait,
+		err: errcode.Errorf(errcode.RateLimited, "rate limited by %v (HTTP %v), the limit resets in %v",
+			url.Host, resp.Status, wait.Round(time.Second)),
+	}
+}
+
+// isRetryableStatusCode returns whether a request failed with the HTTP status code is worth
+// retrying, i.e. server errors, timeouts and rate limiting.
+func isRetryableStatusCode(statusCode int) bool {
+	return statusCode >= 500 || statusCode == http.StatusRequestTimeout ||
+		statusCode == http.StatusTooManyRequests
+}
+
+// withRetry runs an attempt until it succeeds, fails with a non-retryable error, or the policy
+// runs out of attempts or budget. The attempt returns whether its error is retryable. When
+// rate limited, the attempt is retried after the limit resets instead of after the backoff.
+// Nothing is retried once ctx is canceled.
+func (c *Client) withRetry(ctx context.Context, policy RetryPolicy, attempt func() (bool, error)) error {
+	backoff := policy.Backoff
+	var waited time.Duration
+
+	for i := 1; ; i++ {
+		isRetryable, err := attempt()
+		if err == nil {
+			return nil
+		}
+
+		if ctx.Err() != nil {
+			return err
+		}
+
+		var rateLimitErr *rateLimitError
+		if errors.As(err, &rateLimitErr) {
+			if i >= policy.MaxAttempts || (policy.Budget > 0 && waited+rateLimitErr.wait > policy.Budget) {
+				return err
+			}
+
+			c.logger.Warnf(i18n.T("Rate limited, retrying in %v (attempt %v of %v)\n\t%v"),
+				rateLimitErr.wait.Round(time.Second), i+1, policy.MaxAttempts, err)
+
+			if err := sleep(ctx, rateLimitErr.wait); err != nil {
+				return err
+			}
+			waited += rateLimitErr.wait
+			continue
+		}
+
+		if !isRetryable || i >= policy.MaxAttempts ||
+			(policy.Budget > 0 && waited+backoff > policy.Budget) {
+			return err
+		}
+
+		c.logger.Warnf(i18n.T("Network operation failed, retrying in %v (attempt %v of %v)\n\t%v"), backoff, i+1,
+			policy.MaxAttempts, err)
+
+		if err := sleep(ctx, backoff); err != nil {
+			return err
+		}
+		waited += backoff
+		backoff *= 2
+	}
+}
+
+// sleep waits for a duration, or returns the error of ctx once it is canceled.
+func sleep(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
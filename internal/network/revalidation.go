@@ -0,0 +1,341 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	gourl "net/url"
+	"os"
+
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/path"
+	log "github.com/sirupsen/logrus"
+)
+
+// cachedContent is the content of a URL stored with its validators. The content is empty for
+// a file downloaded with revalidation, whose content is in the file.
+type cachedContent struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	Content      []byte `json:"content,omitempty"`
+}
+
+// GetContentWithRevalidation gets the content of a URL, keeping a copy in the cache directory
+// along with its ETag and Last-Modified validators. When a copy exists, a conditional request
+// is sent, and the copy is used if the content is not modified. If the request fails, the copy
+// is used as well, so that lip keeps working offline, unless ctx is canceled. Extra headers,
+// e.g. for authentication, may be nil.
+func (c *Client) GetContentWithRevalidation(ctx context.Context, url *gourl.URL, proxyURL *gourl.URL,
+	header http.Header, retryPolicy RetryPolicy, cacheDir path.Path) ([]byte, error) {
+	debugLogger := c.logger.WithFields(log.Fields{
+		"package": "network",
+		"method":  "GetContentWithRevalidation",
+	})
+
+	cacheFilePath := cacheDir.Join(path.MustParse(getRevalidationCacheFileName(url)))
+
+	cached, isCached := c.loadCachedContent(cacheFilePath, url)
+
+	requestHeader := header.Clone()
+	if requestHeader == nil {
+		requestHeader = make(http.Header)
+	}
+
+	if isCached {
+		if cached.ETag != "" {
+			requestHeader.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			requestHeader.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	var response getResponse
+	err := c.withRetry(ctx, retryPolicy, func() (bool, error) {
+		var isRetryable bool
+		var err error
+		response, isRetryable, err = c.sendGetRequest(ctx, url, proxyURL, requestHeader)
+		return isRetryable, err
+	})
+
+	if err != nil {
+		if isCached && ctx.Err() == nil {
+			c.logger.Warnf(i18n.T("Failed to revalidate %v, using the cached copy\n\t%v"), url, err)
+			return cached.Content, nil
+		}
+
+		return nil, err
+	}
+
+	if response.isNotModified {
+		if !isCached {
+			return nil, fmt.Errorf("unexpected HTTP 304 Not Modified without a cached copy: %v", url)
+		}
+
+		debugLogger.Debugf("%v is not modified, using the cached copy", url)
+		return cached.Content, nil
+	}
+
+	// A copy is kept even without validators, for offline use.
+	newCached := cachedContent{
+		URL:          url.String(),
+		ETag:         response.header.Get("ETag"),
+		LastModified: response.header.Get("Last-Modified"),
+		Content:      response.content,
+	}
+
+	if err := c.saveCachedContent(cacheFilePath, newCached); err != nil {
+		debugLogger.Debugf("Failed to cache %v: %v", url, err)
+	}
+
+	return response.content, nil
+}
+
+// DownloadFileWithRevalidation is like GetContentWithRevalidation, but streams the content to
+// a file instead of holding it in memory, for large content such as the registry index. The
+// file is the cached copy, and its validators are kept next to it. It returns whether the file
+// was downloaded, rather than kept as it is.
+func (c *Client) DownloadFileWithRevalidation(ctx context.Context, url *gourl.URL, proxyURL *gourl.URL,
+	header http.Header, retryPolicy RetryPolicy, filePath path.Path) (bool, error) {
+	debugLogger := c.logger.WithFields(log.Fields{
+		"package": "network",
+		"method":  "DownloadFileWithRevalidation",
+	})
+
+	validatorsFilePath := path.MustParse(filePath.String() + validatorsFileSuffix)
+
+	cached, isCached := c.loadCachedContent(validatorsFilePath, url)
+	if _, err := c.fs.Stat(filePath.LocalString()); err != nil {
+		isCached = false
+	}
+
+	requestHeader := header.Clone()
+	if requestHeader == nil {
+		requestHeader = make(http.Header)
+	}
+
+	if isCached {
+		if cached.ETag != "" {
+			requestHeader.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			requestHeader.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	var response getResponse
+	err := c.withRetry(ctx, retryPolicy, func() (bool, error) {
+		var isRetryable bool
+		var err error
+		response, isRetryable, err = c.downloadFileIfModified(ctx, url, proxyURL, requestHeader, filePath)
+		return isRetryable, err
+	})
+
+	if err != nil {
+		if isCached && ctx.Err() == nil {
+			c.logger.Warnf(i18n.T("Failed to revalidate %v, using the cached copy\n\t%v"), url, err)
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	if response.isNotModified {
+		if !isCached {
+			return false, fmt.Errorf("unexpected HTTP 304 Not Modified without a cached copy: %v", url)
+		}
+
+		debugLogger.Debugf("%v is not modified, using the cached copy", url)
+		return false, nil
+	}
+
+	// The validators are kept without the content, which is in the file.
+	newCached := cachedContent{
+		URL:          url.String(),
+		ETag:         response.header.Get("ETag"),
+		LastModified: response.header.Get("Last-Modified"),
+	}
+
+	if err := c.saveCachedContent(validatorsFilePath, newCached); err != nil {
+		debugLogger.Debugf("Failed to save validators of %v: %v", url, err)
+	}
+
+	return true, nil
+}
+
+// GetCachedContent gets the copy of the content of a URL kept by GetContentWithRevalidation,
+// without accessing the network.
+func (c *Client) GetCachedContent(url *gourl.URL, cacheDir path.Path) ([]byte, error) {
+	cacheFilePath := cacheDir.Join(path.MustParse(getRevalidationCacheFileName(url)))
+
+	cached, isCached := c.loadCachedContent(cacheFilePath, url)
+	if !isCached {
+		return nil, errcode.Errorf(errcode.Offline, "%v is not cached and cannot be fetched in offline mode", url)
+	}
+
+	return cached.Content, nil
+}
+
+// MigrateCachedContent moves the copy of the content of a URL kept by
+// GetContentWithRevalidation to filePath, with its validators, so that it is revalidated by
+// DownloadFileWithRevalidation instead of downloaded again. The file written is verified
+// against the copy, and the copy is moved to backupDir. If filePath exists already, it is newer,
+// and the copy is only moved. It returns false if there is no copy.
+func (c *Client) MigrateCachedContent(url *gourl.URL, cacheDir path.Path, filePath path.Path,
+	backupDir path.Path) (bool, error) {
+	cacheFilePath := cacheDir.Join(path.MustParse(getRevalidationCacheFileName(url)))
+
+	cached, isCached := c.loadCachedContent(cacheFilePath, url)
+	if !isCached {
+		return false, nil
+	}
+
+	if _, err := c.fs.Stat(filePath.LocalString()); os.IsNotExist(err) {
+		if err := c.fs.WriteFile(filePath.LocalString(), cached.Content, 0644); err != nil {
+			return false, fmt.Errorf("cannot write file %v\n\t%w", filePath.LocalString(), err)
+		}
+
+		written, err := c.fs.ReadFile(filePath.LocalString())
+		if err != nil || !bytes.Equal(written, cached.Content) {
+			c.fs.Remove(filePath.LocalString())
+			return false, fmt.Errorf("file %v does not match the cached copy of %v", filePath.LocalString(), url)
+		}
+
+		validatorsFilePath := path.MustParse(filePath.String() + validatorsFileSuffix)
+		if err := c.saveCachedContent(validatorsFilePath, cachedContent{
+			URL:          cached.URL,
+			ETag:         cached.ETag,
+			LastModified: cached.LastModified,
+		}); err != nil {
+			return false, err
+		}
+	} else if err != nil {
+		return false, fmt.Errorf("cannot stat file %v\n\t%w", filePath.LocalString(), err)
+	}
+
+	if err := c.fs.MkdirAll(backupDir.LocalString(), 0755); err != nil {
+		return false, fmt.Errorf("cannot create backup directory %v\n\t%w", backupDir.LocalString(), err)
+	}
+
+	// The copy is copied rather than renamed, since the backup directory may be on another
+	// filesystem than the cache directory.
+	jsonBytes, err := c.fs.ReadFile(cacheFilePath.LocalString())
+	if err != nil {
+		return false, fmt.Errorf("cannot read %v\n\t%w", cacheFilePath.LocalString(), err)
+	}
+
+	backupFilePath := backupDir.Join(path.MustParse(cacheFilePath.Base()))
+	if err := c.fs.WriteFile(backupFilePath.LocalString(), jsonBytes, 0644); err != nil {
+		return false, fmt.Errorf("cannot write backup %v\n\t%w", backupFilePath.LocalString(), err)
+	}
+
+	if err := c.fs.Remove(cacheFilePath.LocalString()); err != nil {
+		return false, fmt.Errorf("cannot remove %v\n\t%w", cacheFilePath.LocalString(), err)
+	}
+
+	return true, nil
+}
+
+// ---------------------------------------------------------------------
+
+// validatorsFileSuffix is appended to the name of a file downloaded with revalidation to name
+// the file keeping its validators.
+const validatorsFileSuffix = ".validators.json"
+
+// downloadFileIfModified makes one attempt to download the content of a URL to a file with
+// extra headers, e.g. conditional ones. The file is written to a temporary file first, so that
+// it is left as it was if the attempt fails. A 304 Not Modified response is not an error. The
+// second return value indicates whether the error is retryable.
+func (c *Client) downloadFileIfModified(ctx context.Context, url *gourl.URL, proxyURL *gourl.URL, header http.Header,
+	filePath path.Path) (getResponse, bool, error) {
+	ctx, cancel := c.withDownloadTimeout(ctx)
+	defer cancel()
+
+	resp, err := c.doGetRequest(ctx, url, proxyURL, header)
+	if err != nil {
+		return getResponse{}, true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return getResponse{header: resp.Header, isNotModified: true}, false, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		if err := getRateLimitError(resp, url); err != nil {
+			return getResponse{}, true, err
+		}
+
+		return getResponse{}, isRetryableStatusCode(resp.StatusCode), &statusError{
+			statusCode: resp.StatusCode,
+			err:        errcode.Errorf(errcode.Network, "cannot download file (HTTP %v): %v", resp.Status, url),
+		}
+	}
+
+	partFilePath := filePath.LocalString() + ".part"
+
+	file, err := c.fs.Create(partFilePath)
+	if err != nil {
+		return getResponse{}, false, fmt.Errorf("cannot create file %v\n\t%w", partFilePath, err)
+	}
+
+	if _, err := io.Copy(file, c.newRateLimitedReader(resp.Body)); err != nil {
+		file.Close()
+		c.fs.Remove(partFilePath)
+		return getResponse{}, true, errcode.Errorf(errcode.Network, "cannot read HTTP response\n\t%w", err)
+	}
+
+	if err := file.Close(); err != nil {
+		c.fs.Remove(partFilePath)
+		return getResponse{}, false, fmt.Errorf("cannot write file %v\n\t%w", partFilePath, err)
+	}
+
+	if err := c.fs.Rename(partFilePath, filePath.LocalString()); err != nil {
+		c.fs.Remove(partFilePath)
+		return getResponse{}, false, fmt.Errorf("cannot rename %v to %v\n\t%w", partFilePath,
+			filePath.LocalString(), err)
+	}
+
+	return getResponse{header: resp.Header}, false, nil
+}
+
+// getRevalidationCacheFileName returns the name of the file keeping the content of a URL with
+// its validators.
+func getRevalidationCacheFileName(url *gourl.URL) string {
+	return gourl.QueryEscape(url.String()) + ".revalidation.json"
+}
+
+// loadCachedContent loads the cached content of a URL. The second return value is false if
+// there is no valid cached copy.
+func (c *Client) loadCachedContent(cacheFilePath path.Path, url *gourl.URL) (cachedContent, bool) {
+	jsonBytes, err := c.fs.ReadFile(cacheFilePath.LocalString())
+	if err != nil {
+		return cachedContent{}, false
+	}
+
+	var cached cachedContent
+	if err := json.Unmarshal(jsonBytes, &cached); err != nil || cached.URL != url.String() {
+		return cachedContent{}, false
+	}
+
+	return cached, true
+}
+
+// saveCachedContent saves the cached content of a URL.
+func (c *Client) saveCachedContent(cacheFilePath path.Path, cached cachedContent) error {
+	jsonBytes, err := json.Marshal(cached)
+	if err != nil {
+		return fmt.Errorf("cannot marshal cached content\n\t%w", err)
+	}
+
+	if err := c.fs.WriteFile(cacheFilePath.LocalString(), jsonBytes, 0644); err != nil {
+		return fmt.Errorf("cannot write cached content\n\t%w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// probeSize is how many bytes of a file are downloaded from each of its sources to measure
+// their speeds.
+const probeSize = 256 * 1024
+
+// probeTimeout is how long a source may take to send the bytes probed. Slower sources are left
+// out.
+const probeTimeout = 10 * time.Second
+
+// SourceSpeed is the speed measured of a source of a file.
+type SourceSpeed struct {
+	URL *url.URL
+	// BytesPerSecond is the speed of the source, including the time to respond.
+	BytesPerSecond float64
+}
+
+// RankSourcesBySpeed downloads the first bytes of a file from each of the URLs it is hosted at,
+// e.g. the same archive on several mirrors, at the same time, and returns the sources that
+// respond in time, fastest first. Extra headers, e.g. for authentication, are sent to all
+// sources and may be nil. The probes are not retried nor limited by the download rate limit,
+// which would make the sources look equally fast.
+func (c *Client) RankSourcesBySpeed(ctx context.Context, urls []*url.URL, proxyURL *url.URL,
+	header http.Header) []SourceSpeed {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	speeds := make([]*SourceSpeed, len(urls))
+
+	var wg sync.WaitGroup
+	for i, sourceURL := range urls {
+		wg.Add(1)
+		go func(i int, sourceURL *url.URL) {
+			defer wg.Done()
+
+			bytesPerSecond, err := c.probeSpeed(ctx, sourceURL, proxyURL, header)
+			if err != nil {
+				return
+			}
+
+			speeds[i] = &SourceSpeed{URL: sourceURL, BytesPerSecond: bytesPerSecond}
+		}(i, sourceURL)
+	}
+	wg.Wait()
+
+	rankedSpeeds := make([]SourceSpeed, 0, len(urls))
+	for _, speed := range speeds {
+		if speed != nil {
+			rankedSpeeds = append(rankedSpeeds, *speed)
+		}
+	}
+
+	sort.SliceStable(rankedSpeeds, func(i, j int) bool {
+		return rankedSpeeds[i].BytesPerSecond > rankedSpeeds[j].BytesPerSecond
+	})
+
+	return rankedSpeeds
+}
+
+// ---------------------------------------------------------------------
+
+// probeSpeed requests the first bytes of a file and returns how fast they arrive. Sources
+// ignoring the range send the whole file, of which only the bytes probed are read.
+func (c *Client) probeSpeed(ctx context.Context, sourceURL *url.URL, proxyURL *url.URL, header http.Header) (float64,
+	error) {
+	probeHeader := header.Clone()
+	if probeHeader == nil {
+		probeHeader = make(http.Header)
+	}
+	probeHeader.Set("Range", fmt.Sprintf("bytes=0-%v", probeSize-1))
+
+	startTime := time.Now()
+
+	resp, err := c.doGetRequest(ctx, sourceURL, proxyURL, probeHeader)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return 0, fmt.Errorf("cannot probe %v (HTTP %v)", sourceURL, resp.Status)
+	}
+
+	size, err := io.Copy(io.Discard, io.LimitReader(resp.Body, probeSize))
+	if err != nil {
+		return 0, fmt.Errorf("cannot probe %v\n\t%w", sourceURL, err)
+	}
+
+	return float64(size) / time.Since(startTime).Seconds(), nil
+}
@@ -0,0 +1,93 @@
+package network_test
+
+import (
+	gocontext "context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/credential"
+	"github.com/lippkg/lip/internal/network"
+)
+
+func TestRankSourcesBySpeedAuthenticatesConcurrentProbes(t *testing.T) {
+	homeDir := t.TempDir()
+	t.Setenv("HOME", homeDir)
+	t.Setenv("USERPROFILE", homeDir)
+
+	const mirrorCount = 8
+
+	credentials := make(map[string]credential.Credential)
+	var unauthorizedCount int32
+	mirrorURLs := make([]*url.URL, 0, mirrorCount)
+	for i := 0; i < mirrorCount; i++ {
+		token := fmt.Sprintf("token-%v", i)
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer "+token {
+				atomic.AddInt32(&unauthorizedCount, 1)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			w.Write(make([]byte, 1024))
+		}))
+		defer server.Close()
+
+		mirrorURL, err := url.Parse(server.URL + "/archive.zip")
+		if err != nil {
+			t.Fatalf("failed to parse mirror URL: %v", err)
+		}
+
+		credentials[mirrorURL.Host] = credential.Credential{Token: token}
+		mirrorURLs = append(mirrorURLs, mirrorURL)
+	}
+
+	// Write the file directly so that no credential is cached before the probes look them up.
+	credentialsJSON, err := json.Marshal(credentials)
+	if err != nil {
+		t.Fatalf("failed to marshal credentials: %v", err)
+	}
+
+	if err := os.Mkdir(filepath.Join(homeDir, ".lip"), 0755); err != nil {
+		t.Fatalf("failed to create global .lip directory: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(homeDir, ".lip", "credentials.json"), credentialsJSON, 0600); err != nil {
+		t.Fatalf("failed to write credentials file: %v", err)
+	}
+
+	// The probes of all mirrors look up and cache credentials at once, as lip does.
+	ctx := context.New(context.Config{CredentialStore: context.FileCredentialStore}, semver.Version{})
+	client := network.NewClient(network.ClientConfig{
+		DialerConfig: network.DefaultDialerConfig(),
+		CredentialLookup: func(host string) (network.Credential, bool) {
+			c, ok, err := credential.Get(ctx, host)
+			if err != nil {
+				t.Errorf("failed to get credential of %v: %v", host, err)
+				return network.Credential{}, false
+			}
+
+			return network.Credential(c), ok
+		},
+	})
+
+	for round := 0; round < 3; round++ {
+		speeds := client.RankSourcesBySpeed(gocontext.Background(), mirrorURLs, &url.URL{}, nil)
+		if len(speeds) != mirrorCount {
+			t.Errorf("round %v: ranked %v mirrors, want %v", round, len(speeds), mirrorCount)
+		}
+	}
+
+	if n := atomic.LoadInt32(&unauthorizedCount); n != 0 {
+		t.Errorf("%v probes were sent without the credential of their mirror", n)
+	}
+}
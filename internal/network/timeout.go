@@ -0,0 +1,13 @@
+package network
+
+import "context"
+
+// withDownloadTimeout returns a context canceled when the download timeout is reached, and the
+// function to release it.
+func (c *Client) withDownloadTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.downloadTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, c.downloadTimeout)
+}
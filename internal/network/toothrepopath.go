@@ -0,0 +1,169 @@
+package network
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/net/idna"
+)
+
+// SubtoothSeparator separates the repository path of a tooth in a subdirectory of a repository,
+// e.g. a monorepo, from the subdirectory, e.g. github.com/owner/repo//teeth/foo.
+const SubtoothSeparator = "//"
+
+// CheckToothRepoPath checks if a tooth repository path is valid. It follows the rules of Go
+// module paths, e.g. github.com/owner/repo or gitlab.com/group/subgroup/repo, except that the
+// host may have a port, e.g. git.example.com:8443/owner/repo. The host must be in lower case,
+// while the rest of the path keeps its case. A tooth in a subdirectory of a repository has the
+// subdirectory after a "//", e.g. github.com/owner/repo//teeth/foo.
+func CheckToothRepoPath(toothRepoPath string) error {
+	repoPath, subdir := SplitSubtooth(toothRepoPath)
+
+	if strings.HasSuffix(toothRepoPath, SubtoothSeparator) {
+		return fmt.Errorf("empty subdirectory in tooth repo path %v", toothRepoPath)
+	}
+
+	if err := checkSubdir(subdir); err != nil {
+		return fmt.Errorf("invalid subdirectory in tooth repo path %v\n\t%w", toothRepoPath, err)
+	}
+
+	hostname, port, hasPort, rest := splitToothRepoPath(repoPath)
+
+	if hasPort {
+		if number, err := strconv.Atoi(port); err != nil || number < 1 || number > 65535 ||
+			port[0] == '0' {
+			return fmt.Errorf("invalid port %v in tooth repo path %v", port, toothRepoPath)
+		}
+	}
+
+	if err := module.CheckPath(hostname + rest); err != nil {
+		return fmt.Errorf("invalid tooth repo path %v\n\t%w", toothRepoPath, err)
+	}
+
+	return nil
+}
+
+// EscapeToothRepoPath escapes a tooth repository path for URLs and file names like
+// module.EscapePath, so that paths differing only in case do not collide. A port is written
+// after a "!" instead of a ":", e.g. git.example.com!8443/owner/repo, which cannot be confused
+// with an escaped upper-case letter.
+func EscapeToothRepoPath(toothRepoPath string) (string, error) {
+	if err := CheckToothRepoPath(toothRepoPath); err != nil {
+		return "", err
+	}
+
+	repoPath, subdir := SplitSubtooth(toothRepoPath)
+	if subdir != "" {
+		escapedRepoPath, err := EscapeToothRepoPath(repoPath)
+		if err != nil {
+			return "", err
+		}
+
+		// The subdirectory is kept apart by a "!" element, which escaped paths cannot contain,
+		// so that github.com/owner/repo//sub and github.com/owner/repo/sub do not collide.
+		escapedElems := make([]string, 0)
+		for _, elem := range strings.Split(subdir, "/") {
+			escapedElem, err := module.EscapeVersion(elem)
+			if err != nil {
+				return "", fmt.Errorf("cannot escape tooth repo path %v\n\t%w", toothRepoPath, err)
+			}
+
+			escapedElems = append(escapedElems, escapedElem)
+		}
+
+		return escapedRepoPath + "/!/" + strings.Join(escapedElems, "/"), nil
+	}
+
+	hostname, port, hasPort, rest := splitToothRepoPath(toothRepoPath)
+
+	escapedPath, err := module.EscapePath(hostname + rest)
+	if err != nil {
+		return "", fmt.Errorf("cannot escape tooth repo path %v\n\t%w", toothRepoPath, err)
+	}
+
+	if !hasPort {
+		return escapedPath, nil
+	}
+
+	return hostname + "!" + port + strings.TrimPrefix(escapedPath, hostname), nil
+}
+
+// SplitSubtooth splits a tooth repository path into the path of the repository and the
+// subdirectory of the tooth in it, e.g. github.com/owner/repo and teeth/foo for
+// github.com/owner/repo//teeth/foo. The subdirectory is empty if the tooth is at the root of
+// the repository.
+func SplitSubtooth(toothRepoPath string) (string, string) {
+	repoPath, subdir, found := strings.Cut(toothRepoPath, SubtoothSeparator)
+	if !found {
+		return toothRepoPath, ""
+	}
+
+	return repoPath, subdir
+}
+
+// HasPort returns whether the host of a tooth repository path has a port. Such teeth cannot
+// be fetched from the Go module proxy.
+func HasPort(toothRepoPath string) bool {
+	_, _, hasPort, _ := splitToothRepoPath(toothRepoPath)
+	return hasPort
+}
+
+// NormalizeToothRepoPath returns a tooth repository path with its host in lower case, since
+// hosts are case-insensitive, and an internationalized host converted to Punycode, e.g.
+// xn--bcher-kva.example/owner/repo for bücher.example/owner/repo, after Unicode normalization.
+// The rest of the path is kept as is, since it may be case-sensitive, e.g. on the Go module
+// proxy. A path without a "/" is returned as is, since it is more likely a file name than a
+// bare host.
+func NormalizeToothRepoPath(toothRepoPath string) string {
+	host, rest, found := strings.Cut(toothRepoPath, "/")
+	if !found {
+		return toothRepoPath
+	}
+
+	hostname, port, hasPort := strings.Cut(host, ":")
+
+	// Invalid hosts are left for validation to reject.
+	asciiHostname, err := idna.Lookup.ToASCII(hostname)
+	if err != nil {
+		asciiHostname = strings.ToLower(hostname)
+	}
+
+	if hasPort {
+		return asciiHostname + ":" + port + "/" + rest
+	}
+
+	return asciiHostname + "/" + rest
+}
+
+// ---------------------------------------------------------------------
+
+// checkSubdir checks the subdirectory of a tooth in a repository. Each element must be a valid
+// file name. An empty subdirectory is valid, meaning the root of the repository.
+func checkSubdir(subdir string) error {
+	if subdir == "" {
+		return nil
+	}
+
+	for _, elem := range strings.Split(subdir, "/") {
+		if err := module.CheckFilePath(elem); err != nil {
+			return fmt.Errorf("invalid element %q\n\t%w", elem, err)
+		}
+	}
+
+	return nil
+}
+
+// splitToothRepoPath splits a tooth repository path into the host name, the port and whether
+// there is one, and the rest of the path starting with a "/", which is empty if there is none.
+func splitToothRepoPath(toothRepoPath string) (string, string, bool, string) {
+	host, rest := toothRepoPath, ""
+	if index := strings.Index(toothRepoPath, "/"); index != -1 {
+		host, rest = toothRepoPath[:index], toothRepoPath[index:]
+	}
+
+	hostname, port, hasPort := strings.Cut(host, ":")
+
+	return hostname, port, hasPort, rest
+}
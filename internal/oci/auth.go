@@ -0,0 +1,175 @@
+package oci
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+)
+
+// tokenUsername is the username sent with a token credential to a token service, which
+// expects basic authentication. Registries taking tokens, e.g. ghcr.io, ignore it.
+const tokenUsername = "lip"
+
+// tokenResponse is the response of a token service.
+type tokenResponse struct {
+	Token       string `json:"token"`
+	AccessToken string `json:"access_token"`
+}
+
+// authHeaderCache keeps the headers to authenticate with in this run by registry host,
+// repository and actions.
+var authHeaderCache = make(map[string]http.Header)
+
+// ---------------------------------------------------------------------
+
+// getAuthHeader returns the headers to authenticate to a repository for actions, e.g. pull or
+// pull,push. If the registry asks for a bearer token, one is requested from its token
+// service with the credential saved by lip login, or anonymously if there is none. Otherwise,
+// no headers are needed, since the credential is attached to requests to the registry anyway.
+func getAuthHeader(ctx *context.Context, registryURL *url.URL, repository string,
+	actions string) (http.Header, error) {
+	header := make(http.Header)
+
+	// Cached content is used as is in offline mode.
+	if ctx.Offline() {
+		return header, nil
+	}
+
+	cacheKey := registryURL.Host + "/" + repository + ":" + actions
+	if cached, ok := authHeaderCache[cacheKey]; ok {
+		return cached.Clone(), nil
+	}
+
+	proxyURL, err := ctx.ProxyURL()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get proxy URL\n\t%w", err)
+	}
+
+	// Ask the registry how to authenticate.
+	baseURL, err := registryURL.Parse("/v2/")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse OCI registry URL\n\t%w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx.GoContext(), http.MethodGet, baseURL.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request\n\t%w", err)
+	}
+
+	resp, err := ctx.Network().SendRequest(req, proxyURL)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		scheme, params := parseChallenge(resp.Header.Get("WWW-Authenticate"))
+		if strings.EqualFold(scheme, "Bearer") {
+			token, err := getToken(ctx, registryURL, params, "repository:"+repository+":"+actions)
+			if err != nil {
+				return nil, err
+			}
+
+			header.Set("Authorization", "Bearer "+token)
+		}
+	} else if resp.StatusCode != http.StatusOK {
+		return nil, errcode.Errorf(errcode.Network, "unexpected response from OCI registry (HTTP %v): %v",
+			resp.Status, baseURL)
+	}
+
+	authHeaderCache[cacheKey] = header
+
+	return header.Clone(), nil
+}
+
+// getToken requests a bearer token for a scope from the token service told by a challenge of
+// the registry, authenticated with the credential of the registry if there is one.
+func getToken(ctx *context.Context, registryURL *url.URL, params map[string]string,
+	scope string) (string, error) {
+	realmURL, err := url.Parse(params["realm"])
+	if err != nil || realmURL.Host == "" {
+		return "", fmt.Errorf("invalid token service %v of OCI registry %v", params["realm"], registryURL.Host)
+	}
+
+	query := realmURL.Query()
+	if params["service"] != "" {
+		query.Set("service", params["service"])
+	}
+	query.Set("scope", scope)
+	realmURL.RawQuery = query.Encode()
+
+	// The token service may be on another host than the registry, so the credential of the
+	// registry is sent explicitly.
+	header := make(http.Header)
+	if credential, ok := ctx.Network().LookupCredential(registryURL.Host); ok {
+		username, password := credential.Username, credential.Password
+		if credential.Token != "" {
+			username, password = tokenUsername, credential.Token
+		}
+
+		header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(username+":"+password)))
+	}
+
+	proxyURL, err := ctx.ProxyURL()
+	if err != nil {
+		return "", fmt.Errorf("failed to get proxy URL\n\t%w", err)
+	}
+
+	content, err := ctx.Network().GetContent(ctx.GoContext(), realmURL, proxyURL, header, ctx.RetryPolicy())
+	if err != nil {
+		return "", fmt.Errorf("failed to get token from %v\n\t%w", realmURL.Host, err)
+	}
+
+	var response tokenResponse
+	if err := json.Unmarshal(content, &response); err != nil {
+		return "", fmt.Errorf("failed to unmarshal token response\n\t%w", err)
+	}
+
+	if response.Token != "" {
+		return response.Token, nil
+	}
+	if response.AccessToken != "" {
+		return response.AccessToken, nil
+	}
+
+	return "", fmt.Errorf("no token in the response of %v", realmURL.Host)
+}
+
+// parseChallenge parses a WWW-Authenticate header, e.g.
+// Bearer realm="https://ghcr.io/token",service="ghcr.io",scope="repository:org/repo:pull",
+// into its scheme and parameters.
+func parseChallenge(challenge string) (string, map[string]string) {
+	params := make(map[string]string)
+
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(challenge), " ")
+
+	for rest = strings.TrimSpace(rest); rest != ""; rest = strings.TrimSpace(rest) {
+		key, value, ok := strings.Cut(rest, "=")
+		if !ok {
+			break
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+
+		if strings.HasPrefix(value, `"`) {
+			// Quoted values may contain commas, e.g. scope="repository:org/repo:pull,push".
+			end := strings.Index(value[1:], `"`)
+			if end < 0 {
+				params[key] = value[1:]
+				break
+			}
+
+			params[key] = value[1 : end+1]
+			rest = strings.TrimPrefix(strings.TrimSpace(value[end+2:]), ",")
+		} else {
+			params[key], rest, _ = strings.Cut(value, ",")
+		}
+	}
+
+	return scheme, params
+}
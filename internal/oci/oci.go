@@ -0,0 +1,320 @@
+package oci
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/network"
+)
+
+// Media types of tooth artifacts. A tooth version is an OCI artifact with the empty config and
+// the tooth archive as its only layer, tagged with the version. A patch between two versions is
+// an artifact with the patch as its only layer, tagged by patchTag.
+const (
+	ArtifactType         = "application/vnd.lippkg.tooth.v1"
+	LayerMediaType       = "application/vnd.lippkg.tooth.archive.v1+zip"
+	TarZstLayerMediaType = "application/vnd.lippkg.tooth.archive.v1.tar+zstd"
+	PatchArtifactType    = "application/vnd.lippkg.tooth.patch.v1"
+	PatchLayerMediaType  = "application/vnd.lippkg.tooth.patch.v1"
+	manifestMediaType    = "application/vnd.oci.image.manifest.v1+json"
+	emptyMediaType       = "application/vnd.oci.empty.v1+json"
+)
+
+// emptyConfig is the content of the empty config blob of artifacts.
+const emptyConfig = "{}"
+
+// descriptor describes a blob in an OCI registry.
+type descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// manifest is an OCI image manifest.
+type manifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType"`
+	ArtifactType  string            `json:"artifactType,omitempty"`
+	Config        descriptor        `json:"config"`
+	Layers        []descriptor      `json:"layers"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+// tagList is a page of the tags of a repository.
+type tagList struct {
+	Tags []string `json:"tags"`
+}
+
+// tagCache keeps the tags fetched in this run by tooth repo path, so that resolving versions
+// and downloading archives do not list the tags again.
+var tagCache = make(map[string][]string)
+
+// missingCache keeps the tooth repo paths whose repositories were not found in this run.
+var missingCache = make(map[string]bool)
+
+// IsUsed returns whether a tooth is resolved from an OCI registry, i.e. OCI is the configured
+// tooth source and the repository of the tooth exists in the registry. Teeth not found in the
+// registry are resolved from the Go module proxy.
+func IsUsed(ctx *context.Context, toothRepoPath string) (bool, error) {
+	toothSource, err := ctx.ToothSource()
+	if err != nil {
+		return false, fmt.Errorf("failed to get tooth source\n\t%w", err)
+	}
+
+	if toothSource != context.OCIToothSource {
+		return false, nil
+	}
+
+	if missingCache[toothRepoPath] {
+		return false, nil
+	}
+
+	if _, err := getTags(ctx, toothRepoPath); network.IsNotFound(err) {
+		missingCache[toothRepoPath] = true
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// GetVersionStrings returns the versions of a tooth in an OCI registry, in the format of the
+// version list of the Go module proxy, e.g. v1.2.3. Tags that are not versions are skipped.
+func GetVersionStrings(ctx *context.Context, toothRepoPath string) ([]string, error) {
+	tags, err := getTags(ctx, toothRepoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	versionStrings := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		version, ok := parseTag(tag)
+		if !ok {
+			continue
+		}
+
+		versionStrings = append(versionStrings, "v"+version.String())
+	}
+
+	return versionStrings, nil
+}
+
+// GetArchiveURL resolves the tag of a version of a tooth to its manifest, and returns the URL
+// to download the tooth archive layer from by its digest, the headers to send with the
+// request, and the hex-encoded SHA-256 checksum the archive must have. The layer is either a
+// zip archive or a .tar.zst archive.
+func GetArchiveURL(ctx *context.Context, toothRepoPath string, version semver.Version) (*url.URL,
+	http.Header, string, error) {
+	return getLayerURL(ctx, toothRepoPath, formatTag(version), LayerMediaType, TarZstLayerMediaType)
+}
+
+// GetPatchURL returns the URL to download the patch from one version of a tooth to another
+// from, the headers to send with the request, and the hex-encoded SHA-256 checksum the patch
+// must have. The URL is nil if no such patch is published.
+func GetPatchURL(ctx *context.Context, toothRepoPath string, from semver.Version, to semver.Version) (*url.URL,
+	http.Header, string, error) {
+	tags, err := getTags(ctx, toothRepoPath)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	tag := patchTag(from, to)
+	for _, t := range tags {
+		if t == tag {
+			return getLayerURL(ctx, toothRepoPath, tag, PatchLayerMediaType)
+		}
+	}
+
+	return nil, nil, "", nil
+}
+
+// ---------------------------------------------------------------------
+
+// getContent gets a URL with revalidation, or from the cache in offline mode.
+func getContent(ctx *context.Context, u *url.URL, header http.Header) ([]byte, error) {
+	cacheDir, err := ctx.CacheDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cache directory\n\t%w", err)
+	}
+
+	if ctx.Offline() {
+		return ctx.Network().GetCachedContent(u, cacheDir)
+	}
+
+	proxyURL, err := ctx.ProxyURL()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get proxy URL\n\t%w", err)
+	}
+
+	return ctx.Network().GetContentWithRevalidation(ctx.GoContext(), u, proxyURL, header, ctx.RetryPolicy(), cacheDir)
+}
+
+// getLayerURL resolves a tag of the repository of a tooth to its manifest, and returns the URL
+// to download the first layer of one of the media types from by its digest, the headers to send
+// with the request, and the hex-encoded SHA-256 checksum of the layer.
+func getLayerURL(ctx *context.Context, toothRepoPath string, tag string, mediaTypes ...string) (*url.URL,
+	http.Header, string, error) {
+	registryURL, repository, err := getRepository(ctx, toothRepoPath)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	header, err := getAuthHeader(ctx, registryURL, repository, "pull")
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("failed to authenticate to %v\n\t%w", registryURL.Host, err)
+	}
+
+	manifestURL, err := network.GenerateOCIManifestURL(registryURL, repository, tag)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("failed to generate OCI manifest URL\n\t%w", err)
+	}
+
+	manifestHeader := header.Clone()
+	manifestHeader.Set("Accept", manifestMediaType)
+
+	content, err := getContent(ctx, manifestURL, manifestHeader)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("failed to fetch OCI manifest %v of %v\n\t%w", tag, toothRepoPath, err)
+	}
+
+	var m manifest
+	if err := json.Unmarshal(content, &m); err != nil {
+		return nil, nil, "", fmt.Errorf("failed to unmarshal OCI manifest %v of %v\n\t%w", tag, toothRepoPath, err)
+	}
+
+	for _, layer := range m.Layers {
+		isWanted := false
+		for _, mediaType := range mediaTypes {
+			if layer.MediaType == mediaType {
+				isWanted = true
+			}
+		}
+
+		if !isWanted {
+			continue
+		}
+
+		if !strings.HasPrefix(layer.Digest, "sha256:") {
+			return nil, nil, "", errcode.Errorf(errcode.MetadataInvalid,
+				"unsupported digest %v of the layer of %v in %v", layer.Digest, tag, toothRepoPath)
+		}
+
+		blobURL, err := network.GenerateOCIBlobURL(registryURL, repository, layer.Digest)
+		if err != nil {
+			return nil, nil, "", fmt.Errorf("failed to generate OCI blob URL\n\t%w", err)
+		}
+
+		return blobURL, header, strings.TrimPrefix(layer.Digest, "sha256:"), nil
+	}
+
+	return nil, nil, "", errcode.Errorf(errcode.MetadataInvalid,
+		"the OCI artifact %v of %v has no layer of media type %v", tag, toothRepoPath,
+		strings.Join(mediaTypes, ", "))
+}
+
+// getRepository returns the registry and the repository of a tooth. The repository is the
+// tooth repo path in lower case under the path of the registry URL, e.g. myorg/github.com/owner/repo
+// for https://ghcr.io/myorg. Repository names allow neither upper-case letters nor colons, so a
+// port in the host is written after a "_", which host names cannot contain. Nor do they allow
+// empty path components, so the "//" before the subdirectory of a tooth is written as a "/".
+func getRepository(ctx *context.Context, toothRepoPath string) (*url.URL, string, error) {
+	ociRegistryURL, err := ctx.OCIRegistryURL()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get OCI registry URL\n\t%w", err)
+	}
+
+	if ociRegistryURL.Host == "" {
+		return nil, "", errcode.Errorf(errcode.InvalidArgument,
+			"OCIRegistryURL must be configured to use OCI registries, e.g. https://ghcr.io/myorg")
+	}
+
+	repository := strings.Replace(strings.ToLower(toothRepoPath), ":", "_", 1)
+	repository = strings.Replace(repository, network.SubtoothSeparator, "/", 1)
+	if prefix := strings.Trim(ociRegistryURL.Path, "/"); prefix != "" {
+		repository = prefix + "/" + repository
+	}
+
+	return ociRegistryURL, repository, nil
+}
+
+// getTags fetches all tags of the repository of a tooth, following the pages of the tag list.
+func getTags(ctx *context.Context, toothRepoPath string) ([]string, error) {
+	if tags, ok := tagCache[toothRepoPath]; ok {
+		return tags, nil
+	}
+
+	registryURL, repository, err := getRepository(ctx, toothRepoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	header, err := getAuthHeader(ctx, registryURL, repository, "pull")
+	if err != nil {
+		return nil, fmt.Errorf("failed to authenticate to %v\n\t%w", registryURL.Host, err)
+	}
+
+	tags := make([]string, 0)
+	last := ""
+	for {
+		pageURL, err := network.GenerateOCITagListURL(registryURL, repository, last)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate OCI tag list URL\n\t%w", err)
+		}
+
+		content, err := getContent(ctx, pageURL, header)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch OCI tags of %v\n\t%w", toothRepoPath, err)
+		}
+
+		var page tagList
+		if err := json.Unmarshal(content, &page); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal OCI tags of %v\n\t%w", toothRepoPath, err)
+		}
+
+		tags = append(tags, page.Tags...)
+
+		if len(page.Tags) < network.OCITagPageSize {
+			break
+		}
+		last = page.Tags[len(page.Tags)-1]
+	}
+
+	tagCache[toothRepoPath] = tags
+
+	return tags, nil
+}
+
+// formatTag returns the tag of a version, e.g. v1.2.3. Build metadata is separated by _
+// instead of +, which is not allowed in tags.
+func formatTag(version semver.Version) string {
+	return "v" + strings.ReplaceAll(version.String(), "+", "_")
+}
+
+// patchTag returns the tag of the patch from one version to another, e.g. patch-v1.2.3-v1.3.0.
+// It does not start with v, so that it is not taken as a version.
+func patchTag(from semver.Version, to semver.Version) string {
+	return "patch-" + formatTag(from) + "-" + formatTag(to)
+}
+
+// parseTag parses the version of a tag formatted by formatTag. The second return value is
+// false if the tag is not a version.
+func parseTag(tag string) (semver.Version, bool) {
+	if !strings.HasPrefix(tag, "v") {
+		return semver.Version{}, false
+	}
+
+	version, err := semver.Parse(strings.ReplaceAll(strings.TrimPrefix(tag, "v"), "_", "+"))
+	if err != nil {
+		return semver.Version{}, false
+	}
+
+	return version, true
+}
@@ -0,0 +1,259 @@
+package oci
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/network"
+	"github.com/lippkg/lip/internal/path"
+	"github.com/lippkg/lip/internal/tarzst"
+)
+
+// archiveTitle and patchTitle are the file names of the tooth archive layer and the patch
+// layer, shown by tools like oras.
+const (
+	archiveTitle       = "tooth.zip"
+	tarZstArchiveTitle = "tooth.tar.zst"
+	patchTitle         = "tooth.patch"
+)
+
+// patchFromAnnotation is the annotation of patch artifacts telling the version patched from.
+const patchFromAnnotation = "io.github.lippkg.patch.from"
+
+// Push pushes a tooth archive to the OCI registry as an artifact tagged with the version of
+// the tooth, replacing the tag if it exists. It returns the reference of the pushed
+// manifest by digest, e.g. ghcr.io/myorg/github.com/owner/repo@sha256:.... A .tar.zst archive
+// is pushed as is, as a layer of TarZstLayerMediaType.
+func Push(ctx *context.Context, archivePath path.Path, toothRepoPath string, version semver.Version) (string, error) {
+	archiveBytes, err := ctx.FS().ReadFile(archivePath.LocalString())
+	if err != nil {
+		return "", fmt.Errorf("failed to read tooth archive %v\n\t%w", archivePath.LocalString(), err)
+	}
+
+	isTarZst, err := tarzst.IsTarZst(ctx.FS(), archivePath)
+	if err != nil {
+		return "", err
+	}
+
+	layerMediaType, title := LayerMediaType, archiveTitle
+	if isTarZst {
+		layerMediaType, title = TarZstLayerMediaType, tarZstArchiveTitle
+	}
+
+	return pushArtifact(ctx, toothRepoPath, formatTag(version), ArtifactType, layerMediaType, title,
+		archiveBytes, map[string]string{
+			"org.opencontainers.image.source":  "https://" + toothRepoPath,
+			"org.opencontainers.image.version": version.String(),
+		})
+}
+
+// PushPatch pushes the patch from one version of a tooth to another to the OCI registry, as an
+// artifact tagged by patchTag. It returns the reference of the pushed manifest by digest.
+func PushPatch(ctx *context.Context, patch []byte, toothRepoPath string, from semver.Version,
+	to semver.Version) (string, error) {
+	return pushArtifact(ctx, toothRepoPath, patchTag(from, to), PatchArtifactType, PatchLayerMediaType,
+		patchTitle, patch, map[string]string{
+			"org.opencontainers.image.source":  "https://" + toothRepoPath,
+			"org.opencontainers.image.version": to.String(),
+			patchFromAnnotation:                from.String(),
+		})
+}
+
+// ---------------------------------------------------------------------
+
+// getDigest returns the SHA-256 digest of content, e.g. sha256:....
+func getDigest(content []byte) string {
+	hash := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(hash[:])
+}
+
+// getResponseError returns an error of an unexpected response of the registry, with the
+// messages of the registry if any.
+func getResponseError(resp *http.Response, message string) error {
+	var registryErrors struct {
+		Errors []struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+
+	details := make([]string, 0)
+	if body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<16)); err == nil &&
+		json.Unmarshal(body, &registryErrors) == nil {
+		for _, e := range registryErrors.Errors {
+			details = append(details, e.Code+": "+e.Message)
+		}
+	}
+
+	if len(details) == 0 {
+		return errcode.Errorf(errcode.Network, "%v (HTTP %v): %v", message, resp.Status, resp.Request.URL)
+	}
+
+	return errcode.Errorf(errcode.Network, "%v (HTTP %v): %v\n\t%v", message, resp.Status, resp.Request.URL,
+		strings.Join(details, "\n\t"))
+}
+
+// pushArtifact pushes an artifact with the empty config and a single layer to the repository
+// of a tooth, tagged with tag, replacing the tag if it exists. It returns the reference of the
+// pushed manifest by digest.
+func pushArtifact(ctx *context.Context, toothRepoPath string, tag string, artifactType string,
+	layerMediaType string, title string, content []byte, annotations map[string]string) (string, error) {
+	registryURL, repository, err := getRepository(ctx, toothRepoPath)
+	if err != nil {
+		return "", err
+	}
+
+	header, err := getAuthHeader(ctx, registryURL, repository, "pull,push")
+	if err != nil {
+		return "", fmt.Errorf("failed to authenticate to %v\n\t%w", registryURL.Host, err)
+	}
+
+	configDescriptor := descriptor{
+		MediaType: emptyMediaType,
+		Digest:    getDigest([]byte(emptyConfig)),
+		Size:      int64(len(emptyConfig)),
+	}
+
+	if err := pushBlob(ctx, registryURL, repository, header, []byte(emptyConfig)); err != nil {
+		return "", fmt.Errorf("failed to push config\n\t%w", err)
+	}
+
+	layerDescriptor := descriptor{
+		MediaType: layerMediaType,
+		Digest:    getDigest(content),
+		Size:      int64(len(content)),
+		Annotations: map[string]string{
+			"org.opencontainers.image.title": title,
+		},
+	}
+
+	if err := pushBlob(ctx, registryURL, repository, header, content); err != nil {
+		return "", fmt.Errorf("failed to push %v\n\t%w", title, err)
+	}
+
+	m := manifest{
+		SchemaVersion: 2,
+		MediaType:     manifestMediaType,
+		ArtifactType:  artifactType,
+		Config:        configDescriptor,
+		Layers:        []descriptor{layerDescriptor},
+		Annotations:   annotations,
+	}
+
+	manifestBytes, err := json.Marshal(m)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal OCI manifest\n\t%w", err)
+	}
+
+	manifestURL, err := network.GenerateOCIManifestURL(registryURL, repository, tag)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate OCI manifest URL\n\t%w", err)
+	}
+
+	resp, err := sendRequest(ctx, http.MethodPut, manifestURL, header, manifestMediaType,
+		bytes.NewReader(manifestBytes), int64(len(manifestBytes)))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return "", getResponseError(resp, "cannot push OCI manifest")
+	}
+
+	// Pushing changes the tags, so list them again when needed.
+	delete(tagCache, toothRepoPath)
+
+	return registryURL.Host + "/" + repository + "@" + getDigest(manifestBytes), nil
+}
+
+// pushBlob uploads a blob in a single request, unless the repository already has it.
+func pushBlob(ctx *context.Context, registryURL *url.URL, repository string, header http.Header,
+	content []byte) error {
+	digest := getDigest(content)
+
+	blobURL, err := network.GenerateOCIBlobURL(registryURL, repository, digest)
+	if err != nil {
+		return fmt.Errorf("failed to generate OCI blob URL\n\t%w", err)
+	}
+
+	resp, err := sendRequest(ctx, http.MethodHead, blobURL, header, "", nil, 0)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	uploadURL, err := network.GenerateOCIBlobUploadURL(registryURL, repository)
+	if err != nil {
+		return fmt.Errorf("failed to generate OCI blob upload URL\n\t%w", err)
+	}
+
+	resp, err = sendRequest(ctx, http.MethodPost, uploadURL, header, "", nil, 0)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		return getResponseError(resp, "cannot start uploading blob")
+	}
+
+	// The location may be relative, and may have a query of its own.
+	location, err := uploadURL.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return fmt.Errorf("failed to parse upload location\n\t%w", err)
+	}
+
+	query := location.Query()
+	query.Set("digest", digest)
+	location.RawQuery = query.Encode()
+
+	resp, err = sendRequest(ctx, http.MethodPut, location, header, "application/octet-stream",
+		bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return getResponseError(resp, "cannot upload blob")
+	}
+
+	return nil
+}
+
+// sendRequest sends a request to the registry with the authentication headers.
+func sendRequest(ctx *context.Context, method string, u *url.URL, header http.Header, contentType string,
+	body io.Reader, contentLength int64) (*http.Response, error) {
+	proxyURL, err := ctx.ProxyURL()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get proxy URL\n\t%w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx.GoContext(), method, u.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request\n\t%w", err)
+	}
+
+	req.Header = header.Clone()
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.ContentLength = contentLength
+
+	return ctx.Network().SendRequest(req, proxyURL)
+}
@@ -0,0 +1,93 @@
+package overlay
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/manifest"
+	"github.com/lippkg/lip/internal/tooth"
+)
+
+// MappingFileName is the name of the mapping file in the overlay directory.
+const MappingFileName = "lip_overlay.json"
+
+const mappingFormatVersion = 1
+
+// Mapping tells mod loaders which files placed in the overlay directory stand for files of
+// the read-only workspace. A file at a path in the overlay directory is meant to be loaded as
+// if it were at the same path in the workspace.
+type Mapping struct {
+	FormatVersion int `json:"format_version"`
+	// Workspace is the absolute path of the read-only workspace.
+	Workspace string `json:"workspace"`
+	// Overlay is the absolute path of the overlay directory.
+	Overlay string `json:"overlay"`
+	Files   []File `json:"files"`
+}
+
+// File is a file placed in the overlay directory.
+type File struct {
+	// Path is the path of the file relative to both the workspace and the overlay directory,
+	// with forward slashes.
+	Path  string `json:"path"`
+	Tooth string `json:"tooth"`
+}
+
+// WriteMapping writes the mapping file of the files placed by the installed teeth into the
+// overlay directory, which must be the working directory.
+func WriteMapping(ctx *context.Context, workspaceDir string) error {
+	overlayDir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get overlay directory\n\t%w", err)
+	}
+
+	metadataList, err := tooth.GetAllMetadata(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list installed teeth\n\t%w", err)
+	}
+
+	files := make([]File, 0)
+	for _, metadata := range metadataList {
+		// Teeth installed by older versions of lip have no manifest, so their files are unknown.
+		placedManifest, ok, err := manifest.Get(ctx, metadata.ToothRepoPath())
+		if err != nil {
+			return fmt.Errorf("failed to get manifest of %v\n\t%w", metadata.ToothRepoPath(), err)
+		}
+
+		if !ok {
+			continue
+		}
+
+		for _, placedFile := range placedManifest.Files {
+			files = append(files, File{
+				Path:  placedFile.Path,
+				Tooth: metadata.ToothRepoPath(),
+			})
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+
+	jsonBytes, err := json.MarshalIndent(Mapping{
+		FormatVersion: mappingFormatVersion,
+		Workspace:     workspaceDir,
+		Overlay:       overlayDir,
+		Files:         files,
+	}, "", "    ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal mapping\n\t%w", err)
+	}
+
+	mappingFilePath := filepath.Join(overlayDir, MappingFileName)
+	if err := os.WriteFile(mappingFilePath, jsonBytes, 0644); err != nil {
+		return fmt.Errorf("failed to write mapping file %v\n\t%w", mappingFilePath, err)
+	}
+
+	return nil
+}
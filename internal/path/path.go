@@ -49,6 +49,13 @@ func Parse(path string) (Path, error) {
 	}, nil
 }
 
+// ParseRelative parses a path string relative to a directory, e.g. a file in an archive or a
+// destination in the workspace, into a Path. Leading slashes are dropped, so that the path stays
+// relative to the directory.
+func ParseRelative(path string) (Path, error) {
+	return Parse(strings.TrimLeft(filepath.ToSlash(path), "/"))
+}
+
 // MustParse parses a path string into a Path. It panics if the path is invalid.
 func MustParse(path string) Path {
 	p, err := Parse(path)
@@ -197,8 +204,13 @@ func (f Path) TrimSuffix(suffix Path) Path {
 	}
 }
 
-// String returns the string representation of a Path.
+// String returns the string representation of a Path. An absolute path on Unix keeps its
+// leading slash.
 func (f Path) String() string {
+	if len(f.pathItems) > 0 && f.pathItems[0] == "" {
+		return "/" + gopath.Join(f.pathItems[1:]...)
+	}
+
 	return gopath.Join(f.pathItems...)
 }
 
@@ -0,0 +1,82 @@
+package path
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"/home/user/.lip", "/home/user/.lip"},
+		{"/home/user/.lip/", "/home/user/.lip"},
+		{"/", "/"},
+		{"C:/Users/user/.lip", "C:/Users/user/.lip"},
+		{"tooth/example.txt", "tooth/example.txt"},
+		{"./tooth/example.txt", "tooth/example.txt"},
+		{"example.txt", "example.txt"},
+	}
+
+	for _, test := range tests {
+		p, err := Parse(test.path)
+		if err != nil {
+			t.Fatalf("Parse(%q) returned error: %v", test.path, err)
+		}
+
+		if s := p.String(); s != test.expected {
+			t.Errorf("Parse(%q).String() = %q, want %q", test.path, s, test.expected)
+		}
+
+		if s := p.LocalString(); s != filepath.FromSlash(test.expected) {
+			t.Errorf("Parse(%q).LocalString() = %q, want %q", test.path, s, filepath.FromSlash(test.expected))
+		}
+	}
+}
+
+func TestStringOfJoinedPaths(t *testing.T) {
+	tests := []struct {
+		base     string
+		other    string
+		expected string
+	}{
+		{"/home/user", "tooth/example.txt", "/home/user/tooth/example.txt"},
+		{"workspace", "tooth/example.txt", "workspace/tooth/example.txt"},
+	}
+
+	for _, test := range tests {
+		p := MustParse(test.base).Join(MustParse(test.other))
+		if s := p.String(); s != test.expected {
+			t.Errorf("%q joined with %q = %q, want %q", test.base, test.other, s, test.expected)
+		}
+	}
+
+	// A relative path trimmed from an absolute one stays relative.
+	p := MustParse("/home/user/tooth/example.txt").TrimPrefix(MustParse("/home/user"))
+	if s := p.String(); s != "tooth/example.txt" {
+		t.Errorf("trimmed path = %q, want %q", s, "tooth/example.txt")
+	}
+}
+
+func TestParseRelative(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"/tooth/example.txt", "tooth/example.txt"},
+		{"//tooth/example.txt", "tooth/example.txt"},
+		{"tooth/example.txt", "tooth/example.txt"},
+	}
+
+	for _, test := range tests {
+		p, err := ParseRelative(test.path)
+		if err != nil {
+			t.Fatalf("ParseRelative(%q) returned error: %v", test.path, err)
+		}
+
+		if s := p.String(); s != test.expected {
+			t.Errorf("ParseRelative(%q).String() = %q, want %q", test.path, s, test.expected)
+		}
+	}
+}
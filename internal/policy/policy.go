@@ -0,0 +1,191 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/tooth"
+	log "github.com/sirupsen/logrus"
+)
+
+const expectedFormatVersion = 1
+
+// Policy is the rules teeth must follow to be installed, e.g. deployed by an administrator to
+// the global .lip directory of the machines of an organization.
+type Policy struct {
+	FormatVersion int `json:"format_version"`
+
+	// AllowedToothPrefixes are the prefixes of the tooth repository paths allowed to be
+	// installed. Empty to allow all teeth.
+	AllowedToothPrefixes []string `json:"allowed_tooth_prefixes,omitempty"`
+
+	// BlockedToothPrefixes are the prefixes of the tooth repository paths not allowed to be
+	// installed, even if allowed by AllowedToothPrefixes.
+	BlockedToothPrefixes []string `json:"blocked_tooth_prefixes,omitempty"`
+
+	// BlockedLicenses are the SPDX license identifiers not allowed, compared case-insensitively.
+	BlockedLicenses []string `json:"blocked_licenses,omitempty"`
+
+	// RequireLicense is whether teeth not declaring a license are blocked.
+	RequireLicense bool `json:"require_license,omitempty"`
+
+	// MaxDependencyDepth is the maximum depth of dependencies from the specified teeth, which
+	// are at depth 0. 0 for no limit.
+	MaxDependencyDepth int `json:"max_dependency_depth,omitempty"`
+}
+
+// Load loads the installation policy. The second return value is false if there is no policy
+// file, in which case everything is allowed.
+func Load(ctx *context.Context) (Policy, bool, error) {
+	debugLogger := ctx.Logger().WithFields(log.Fields{
+		"package": "policy",
+		"method":  "Load",
+	})
+
+	policyFilePath, err := ctx.PolicyFilePath()
+	if err != nil {
+		return Policy{}, false, fmt.Errorf("failed to get policy file path\n\t%w", err)
+	}
+
+	jsonBytes, err := ctx.FS().ReadFile(policyFilePath.LocalString())
+	if os.IsNotExist(err) {
+		return Policy{}, false, nil
+	} else if err != nil {
+		return Policy{}, false, fmt.Errorf("failed to read policy file %v\n\t%w", policyFilePath.LocalString(), err)
+	}
+
+	var policy Policy
+	if err := json.Unmarshal(jsonBytes, &policy); err != nil {
+		return Policy{}, false, errcode.Errorf(errcode.MetadataInvalid, "invalid policy file %v: %v",
+			policyFilePath.LocalString(), err)
+	}
+
+	if policy.FormatVersion != expectedFormatVersion {
+		return Policy{}, false, errcode.Errorf(errcode.MetadataInvalid,
+			"unsupported format version of policy file %v: %v", policyFilePath.LocalString(), policy.FormatVersion)
+	}
+
+	if policy.MaxDependencyDepth < 0 {
+		return Policy{}, false, errcode.Errorf(errcode.MetadataInvalid,
+			"invalid max_dependency_depth in policy file %v: %v", policyFilePath.LocalString(),
+			policy.MaxDependencyDepth)
+	}
+
+	debugLogger.Debugf("Loaded installation policy from %v", policyFilePath.LocalString())
+
+	return policy, true, nil
+}
+
+// Check checks the teeth to install against the policy. depths are the dependency depths of
+// the teeth from the specified teeth. All violations are reported together.
+func (p Policy) Check(metadataList []tooth.Metadata, depths map[string]int) error {
+	violations := make([]string, 0)
+
+	for _, metadata := range metadataList {
+		violations = append(violations, p.checkTooth(metadata)...)
+	}
+
+	if p.MaxDependencyDepth != 0 {
+		toothRepoPaths := make([]string, 0, len(depths))
+		for toothRepoPath := range depths {
+			toothRepoPaths = append(toothRepoPaths, toothRepoPath)
+		}
+		sort.Strings(toothRepoPaths)
+
+		for _, toothRepoPath := range toothRepoPaths {
+			if depths[toothRepoPath] > p.MaxDependencyDepth {
+				violations = append(violations, fmt.Sprintf(
+					i18n.T("%v is at dependency depth %v, deeper than the maximum of %v"), toothRepoPath,
+					depths[toothRepoPath], p.MaxDependencyDepth))
+			}
+		}
+	}
+
+	if len(violations) != 0 {
+		return errcode.Errorf(errcode.PolicyViolation, "blocked by the installation policy:\n  %v",
+			strings.Join(violations, "\n  "))
+	}
+
+	return nil
+}
+
+// ---------------------------------------------------------------------
+
+// checkTooth returns the violations of the policy by a tooth, except for its dependency depth.
+func (p Policy) checkTooth(metadata tooth.Metadata) []string {
+	violations := make([]string, 0)
+	toothRepoPath := metadata.ToothRepoPath()
+
+	if len(p.AllowedToothPrefixes) != 0 && !hasAnyPrefix(toothRepoPath, p.AllowedToothPrefixes) {
+		violations = append(violations, fmt.Sprintf(i18n.T("%v is not under an allowed prefix"), toothRepoPath))
+	}
+
+	if hasAnyPrefix(toothRepoPath, p.BlockedToothPrefixes) {
+		violations = append(violations, fmt.Sprintf(i18n.T("%v is under a blocked prefix"), toothRepoPath))
+	}
+
+	license := metadata.Info().License
+	if license == "" {
+		if p.RequireLicense {
+			violations = append(violations, fmt.Sprintf(i18n.T("%v does not declare a license"), toothRepoPath))
+		}
+
+		return violations
+	}
+
+	for _, licenseID := range getLicenseIDs(license) {
+		for _, blockedLicense := range p.BlockedLicenses {
+			if strings.EqualFold(licenseID, blockedLicense) {
+				violations = append(violations, fmt.Sprintf(i18n.T("%v is licensed under blocked license %v"),
+					toothRepoPath, licenseID))
+			}
+		}
+	}
+
+	return violations
+}
+
+// getLicenseIDs returns the license identifiers in an SPDX license expression, e.g. MIT and
+// Apache-2.0 in "(MIT OR Apache-2.0)".
+func getLicenseIDs(expression string) []string {
+	fields := strings.FieldsFunc(expression, func(r rune) bool {
+		return r == ' ' || r == '(' || r == ')'
+	})
+
+	licenseIDs := make([]string, 0, len(fields))
+	for i := 0; i < len(fields); i++ {
+		switch strings.ToUpper(fields[i]) {
+		case "AND", "OR":
+			continue
+
+		case "WITH":
+			// Skip the license exception.
+			i++
+			continue
+		}
+
+		licenseIDs = append(licenseIDs, fields[i])
+	}
+
+	return licenseIDs
+}
+
+// hasAnyPrefix returns whether a tooth repository path is under any of the prefixes. A prefix
+// matches whole path segments, so github.com/org matches github.com/org/tooth but not
+// github.com/organization/tooth.
+func hasAnyPrefix(toothRepoPath string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		prefix = strings.TrimSuffix(prefix, "/")
+		if toothRepoPath == prefix || strings.HasPrefix(toothRepoPath, prefix+"/") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,186 @@
+package profiling
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"sync"
+	"time"
+)
+
+// Phase is a phase of installing teeth, timed separately in the timing breakdown.
+type Phase string
+
+const (
+	// ResolvePhase is resolving specifiers and dependencies, and checking the plan.
+	ResolvePhase Phase = "resolve"
+	// DownloadPhase is downloading tooth archives, asset archives and patches.
+	DownloadPhase Phase = "download"
+	// ExtractPhase is extracting files from asset archives to their destinations.
+	ExtractPhase Phase = "extract"
+	// PlacePhase is the rest of installing teeth, e.g. preparing destinations, placing config
+	// files, running install commands and writing metadata.
+	PlacePhase Phase = "place"
+)
+
+// Phases are the phases in the order they happen.
+var Phases = []Phase{ResolvePhase, DownloadPhase, ExtractPhase, PlacePhase}
+
+// CPUProfileFileName and HeapProfileFileName are the names of the profiles written to the
+// profile directory.
+const (
+	CPUProfileFileName  = "cpu.pprof"
+	HeapProfileFileName = "heap.pprof"
+)
+
+// Timer accumulates the time spent in each phase. Phases may be nested, e.g. downloading a tooth
+// archive while resolving dependencies, in which case the time of the inner phase is not counted
+// in the outer one. A nil timer does nothing, so that timing costs nothing unless profiling is
+// enabled.
+type Timer struct {
+	mu        sync.Mutex
+	startTime time.Time
+	durations map[Phase]time.Duration
+	spans     []span
+}
+
+// span is a phase being timed.
+type span struct {
+	phase     Phase
+	startTime time.Time
+}
+
+// NewTimer creates a timer. The total time is counted from now.
+func NewTimer() *Timer {
+	return &Timer{
+		startTime: time.Now(),
+		durations: make(map[Phase]time.Duration),
+		spans:     make([]span, 0),
+	}
+}
+
+// Start starts timing a phase, pausing the phase it is nested in, and returns a function that
+// stops it. Phases must be stopped in reverse order of starting. Stopping a phase again does
+// nothing, so the function can be deferred as well as called when the phase ends.
+func (t *Timer) Start(phase Phase) func() {
+	if t == nil {
+		return func() {}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	t.pause(now)
+	t.spans = append(t.spans, span{phase: phase, startTime: now})
+
+	var once sync.Once
+
+	return func() {
+		once.Do(t.stop)
+	}
+}
+
+// Duration returns the time spent in a phase.
+func (t *Timer) Duration(phase Phase) time.Duration {
+	if t == nil {
+		return 0
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.durations[phase]
+}
+
+// Total returns the time since the timer was created.
+func (t *Timer) Total() time.Duration {
+	if t == nil {
+		return 0
+	}
+
+	return time.Since(t.startTime)
+}
+
+// StartProfiles starts writing a CPU profile to a directory, creating it if needed, and returns
+// a function that stops it and writes a heap profile next to it.
+func StartProfiles(dir string) (func() error, error) {
+	// The working directory may change meanwhile, e.g. in overlay mode.
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path of %v\n\t%w", dir, err)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create profile directory %v\n\t%w", dir, err)
+	}
+
+	cpuProfilePath := filepath.Join(dir, CPUProfileFileName)
+
+	cpuProfileFile, err := os.Create(cpuProfilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CPU profile %v\n\t%w", cpuProfilePath, err)
+	}
+
+	if err := pprof.StartCPUProfile(cpuProfileFile); err != nil {
+		cpuProfileFile.Close()
+		return nil, fmt.Errorf("failed to start CPU profile\n\t%w", err)
+	}
+
+	return func() error {
+		pprof.StopCPUProfile()
+
+		if err := cpuProfileFile.Close(); err != nil {
+			return fmt.Errorf("failed to close CPU profile %v\n\t%w", cpuProfilePath, err)
+		}
+
+		return writeHeapProfile(filepath.Join(dir, HeapProfileFileName))
+	}, nil
+}
+
+// ---------------------------------------------------------------------
+
+// stop stops timing the innermost phase and resumes the phase it is nested in.
+func (t *Timer) stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	t.pause(now)
+	t.spans = t.spans[:len(t.spans)-1]
+
+	if len(t.spans) != 0 {
+		t.spans[len(t.spans)-1].startTime = now
+	}
+}
+
+// pause adds the time of the innermost phase being timed until now. The caller must hold the
+// lock.
+func (t *Timer) pause(now time.Time) {
+	if len(t.spans) == 0 {
+		return
+	}
+
+	innermost := t.spans[len(t.spans)-1]
+	t.durations[innermost.phase] += now.Sub(innermost.startTime)
+}
+
+// writeHeapProfile writes a profile of the live heap after a garbage collection.
+func writeHeapProfile(filePath string) error {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to create heap profile %v\n\t%w", filePath, err)
+	}
+	defer file.Close()
+
+	// Collect garbage so that the profile shows the memory still in use.
+	runtime.GC()
+
+	if err := pprof.WriteHeapProfile(file); err != nil {
+		return fmt.Errorf("failed to write heap profile %v\n\t%w", filePath, err)
+	}
+
+	return nil
+}
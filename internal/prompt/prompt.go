@@ -0,0 +1,248 @@
+package prompt
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/lippkg/lip/internal/errcode"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/term"
+)
+
+// Prompter asks the user questions, e.g. for confirmation before changing the workspace.
+// Prompters never wait for an answer no one can give, so that automation never hangs on a
+// prompt it cannot see: they fail with E_ABORTED instead.
+type Prompter interface {
+	// Confirm asks a yes-or-no question. An empty answer is the default answer.
+	Confirm(question string, defaultAnswer bool) (bool, error)
+
+	// Choose asks to choose one of the choices, by its number from 1 or by itself, and returns
+	// its index.
+	Choose(question string, choices []string) (int, error)
+}
+
+// Modes of the prompter, the values of lip --prompt.
+const (
+	// TerminalMode asks on the terminal.
+	TerminalMode = "tty"
+	// YesMode answers yes to every confirmation.
+	YesMode = "yes"
+	// NoMode answers no to every confirmation.
+	NoMode = "no"
+)
+
+// New returns the prompter of a mode.
+func New(mode string) (Prompter, error) {
+	switch mode {
+	case TerminalMode:
+		return Terminal(), nil
+	case YesMode:
+		return Fixed(true), nil
+	case NoMode:
+		return Fixed(false), nil
+	}
+
+	return nil, errcode.Errorf(errcode.InvalidArgument, "unknown prompt mode %v, expected %v, %v or %v", mode,
+		TerminalMode, YesMode, NoMode)
+}
+
+// ---------------------------------------------------------------------
+
+// terminalPrompter asks on the terminal.
+type terminalPrompter struct {
+	mu     sync.Mutex
+	reader *bufio.Reader
+}
+
+// Terminal returns a prompter asking on the terminal, the default one. If the standard input is
+// not a terminal, e.g. when lip runs from a script, it fails instead of asking.
+func Terminal() Prompter {
+	return &terminalPrompter{
+		reader: bufio.NewReader(os.Stdin),
+	}
+}
+
+func (p *terminalPrompter) Confirm(question string, defaultAnswer bool) (bool, error) {
+	answer, err := p.ask(question)
+	if err != nil {
+		return false, err
+	}
+
+	return parseConfirmation(answer, defaultAnswer), nil
+}
+
+func (p *terminalPrompter) Choose(question string, choices []string) (int, error) {
+	answer, err := p.ask(question)
+	if err != nil {
+		return 0, err
+	}
+
+	return parseChoice(answer, choices)
+}
+
+// ask shows a question and reads a line of answer.
+func (p *terminalPrompter) ask(question string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", errcode.Errorf(errcode.Aborted,
+			"cannot ask %q: the standard input is not a terminal. Pass --prompt yes, --prompt no or --answers-file",
+			question)
+	}
+
+	log.Info(question)
+
+	line, err := p.reader.ReadString('\n')
+	if err == io.EOF && line == "" {
+		return "", errcode.Errorf(errcode.Aborted, "aborted")
+	} else if err != nil && err != io.EOF {
+		return "", fmt.Errorf("failed to read answer\n\t%w", err)
+	}
+
+	return strings.TrimSpace(line), nil
+}
+
+// ---------------------------------------------------------------------
+
+// fixedPrompter gives the same answer to every confirmation.
+type fixedPrompter struct {
+	answer bool
+}
+
+// Fixed returns a prompter answering every confirmation with the answer without asking. It
+// cannot choose, since no answer fits every choice.
+func Fixed(answer bool) Prompter {
+	return fixedPrompter{answer: answer}
+}
+
+func (p fixedPrompter) Confirm(question string, defaultAnswer bool) (bool, error) {
+	log.Infof("%v %v", question, formatConfirmation(p.answer))
+
+	return p.answer, nil
+}
+
+func (p fixedPrompter) Choose(question string, choices []string) (int, error) {
+	return 0, errcode.Errorf(errcode.Aborted, "cannot answer %q with --prompt %v. Pass --answers-file instead",
+		question, formatConfirmation(p.answer))
+}
+
+// ---------------------------------------------------------------------
+
+// scriptedPrompter answers with the lines of a file in order.
+type scriptedPrompter struct {
+	mu      sync.Mutex
+	name    string
+	scanner *bufio.Scanner
+}
+
+// Scripted returns a prompter answering with the lines read from a reader in order, one per
+// question. Lines starting with # are skipped, and empty lines are the default answers. Once
+// the lines run out, it fails. The name of the reader is shown in errors.
+func Scripted(reader io.Reader, name string) Prompter {
+	return &scriptedPrompter{
+		name:    name,
+		scanner: bufio.NewScanner(reader),
+	}
+}
+
+// FromFile returns a prompter answering with the lines of a file, or of the standard input if
+// the file path is -. See Scripted.
+func FromFile(filePath string) (Prompter, error) {
+	if filePath == "-" {
+		return Scripted(os.Stdin, "the standard input"), nil
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open answers file %v\n\t%w", filePath, err)
+	}
+
+	// The file is read as questions are asked until lip exits, so it is never closed.
+	return Scripted(file, filePath), nil
+}
+
+func (p *scriptedPrompter) Confirm(question string, defaultAnswer bool) (bool, error) {
+	answer, err := p.next(question)
+	if err != nil {
+		return false, err
+	}
+
+	return parseConfirmation(answer, defaultAnswer), nil
+}
+
+func (p *scriptedPrompter) Choose(question string, choices []string) (int, error) {
+	answer, err := p.next(question)
+	if err != nil {
+		return 0, err
+	}
+
+	return parseChoice(answer, choices)
+}
+
+// next returns the next answer, and shows it with the question.
+func (p *scriptedPrompter) next(question string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for p.scanner.Scan() {
+		line := strings.TrimSpace(p.scanner.Text())
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		log.Infof("%v %v", question, line)
+
+		return line, nil
+	}
+
+	if err := p.scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read answers from %v\n\t%w", p.name, err)
+	}
+
+	return "", errcode.Errorf(errcode.Aborted, "no answer left in %v to %q", p.name, question)
+}
+
+// ---------------------------------------------------------------------
+
+// parseConfirmation parses an answer to a yes-or-no question. Any answer other than yes or an
+// empty one is no.
+func parseConfirmation(answer string, defaultAnswer bool) bool {
+	switch strings.ToLower(answer) {
+	case "":
+		return defaultAnswer
+	case "y", "yes":
+		return true
+	}
+
+	return false
+}
+
+// formatConfirmation formats an answer to a yes-or-no question.
+func formatConfirmation(answer bool) string {
+	if answer {
+		return "y"
+	}
+
+	return "n"
+}
+
+// parseChoice parses an answer choosing one of the choices, by its number from 1 or by itself.
+func parseChoice(answer string, choices []string) (int, error) {
+	if number, err := strconv.Atoi(answer); err == nil && number >= 1 && number <= len(choices) {
+		return number - 1, nil
+	}
+
+	for i, choice := range choices {
+		if answer == choice {
+			return i, nil
+		}
+	}
+
+	return 0, errcode.Errorf(errcode.Aborted, "aborted")
+}
@@ -0,0 +1,76 @@
+package record
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/statedb"
+)
+
+// Record holds the installation state of a tooth that is not part of its metadata.
+type Record struct {
+	ToothRepoPath string `json:"tooth"`
+	IsExplicit    bool   `json:"is_explicit"`
+
+	// Override is the version the tooth is forced to by an override despite the constraints
+	// of its dependents, or empty if it is not overridden.
+	Override string `json:"override,omitempty"`
+}
+
+// Get returns the record of an installed tooth. Teeth installed without a record
+// are considered explicitly installed.
+func Get(ctx *context.Context, toothRepoPath string) (Record, error) {
+	db, err := statedb.Open(ctx)
+	if err != nil {
+		return Record{}, fmt.Errorf("failed to open state database\n\t%w", err)
+	}
+
+	tooth, _ := db.Get(toothRepoPath)
+	if tooth.Record == nil {
+		return Record{
+			ToothRepoPath: toothRepoPath,
+			IsExplicit:    true,
+		}, nil
+	}
+
+	var record Record
+	if err := json.Unmarshal(tooth.Record, &record); err != nil {
+		return Record{}, fmt.Errorf("failed to unmarshal record of %v\n\t%w", toothRepoPath, err)
+	}
+
+	if record.ToothRepoPath != toothRepoPath {
+		return Record{}, fmt.Errorf("record of %v is of another tooth: %v", toothRepoPath, record.ToothRepoPath)
+	}
+
+	return record, nil
+}
+
+// Save writes the record of an installed tooth.
+func Save(ctx *context.Context, record Record) error {
+	jsonBytes, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal record\n\t%w", err)
+	}
+
+	err = statedb.Update(ctx, record.ToothRepoPath, func(tooth *statedb.Tooth) {
+		tooth.Record = jsonBytes
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save record of %v\n\t%w", record.ToothRepoPath, err)
+	}
+
+	return nil
+}
+
+// Delete removes the record of a tooth. It does nothing if the record does not exist.
+func Delete(ctx *context.Context, toothRepoPath string) error {
+	err := statedb.Update(ctx, toothRepoPath, func(tooth *statedb.Tooth) {
+		tooth.Record = nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete record of %v\n\t%w", toothRepoPath, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,548 @@
+package registry
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	gourl "net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/network"
+	"github.com/lippkg/lip/internal/path"
+	"github.com/lippkg/lip/internal/vfs"
+)
+
+// The registry index is kept in the cache directory as downloaded, along with an on-disk index
+// of it, so that it is never unmarshaled into memory as a whole. The on-disk index is a flat
+// file of records, one per line, whose fields are separated by tabs:
+//
+//	t <tooth>                      a tooth in the registry
+//	s <tooth> <offset> <length>    the byte range of the stats of a tooth in the registry index
+//	a <alias> <tooth>              an alias referring to a tooth
+//	c <capability> <tooth>         a capability provided by a tooth
+//	d <digest>                     the SHA-256 digest of the registry index, the last record
+//
+// It is built by decoding the registry index as a stream whenever the registry index is
+// downloaded again.
+const (
+	toothRecordKind      = "t"
+	statsRecordKind      = "s"
+	aliasRecordKind      = "a"
+	capabilityRecordKind = "c"
+	digestRecordKind     = "d"
+)
+
+// indexFiles are the files of the registry index in the cache directory.
+type indexFiles struct {
+	// indexFilePath is the registry index as downloaded.
+	indexFilePath path.Path
+	// recordsFilePath is the on-disk index of the registry index.
+	recordsFilePath path.Path
+}
+
+// statsRange is the byte range of the stats of a tooth in the registry index.
+type statsRange struct {
+	toothRepoPath string
+	offset        int64
+	length        int64
+}
+
+// ListTeeth returns the teeth in the registry, in the order of the registry index.
+func ListTeeth(ctx *context.Context) ([]string, error) {
+	files, err := getIndexFiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	toothRepoPaths := make([]string, 0)
+	err = scanRecords(ctx.FS(), files.recordsFilePath, func(fields []string) {
+		if fields[0] == toothRecordKind && len(fields) == 2 {
+			toothRepoPaths = append(toothRepoPaths, fields[1])
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return toothRepoPaths, nil
+}
+
+// CopyIndex writes the registry index as downloaded, e.g. to mirror it.
+func CopyIndex(ctx *context.Context, writer io.Writer) error {
+	files, err := getIndexFiles(ctx)
+	if err != nil {
+		return err
+	}
+
+	file, err := ctx.FS().Open(files.indexFilePath.LocalString())
+	if err != nil {
+		return fmt.Errorf("failed to open registry index %v\n\t%w", files.indexFilePath.LocalString(), err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(writer, file); err != nil {
+		return fmt.Errorf("failed to copy registry index %v\n\t%w", files.indexFilePath.LocalString(), err)
+	}
+
+	return nil
+}
+
+// MigrateIndexCache moves the registry index cached in memory form by older versions of lip to
+// the file it is streamed to, and moves the old copy to backupDir. It returns false if there is
+// no old copy.
+func MigrateIndexCache(ctx *context.Context, backupDir path.Path) (bool, error) {
+	if !IsConfigured(ctx) {
+		return false, nil
+	}
+
+	registryURL, err := ctx.RegistryURL()
+	if err != nil {
+		return false, fmt.Errorf("failed to get registry URL\n\t%w", err)
+	}
+
+	indexURL, err := network.GenerateRegistryIndexURL(registryURL)
+	if err != nil {
+		return false, fmt.Errorf("failed to generate registry index URL\n\t%w", err)
+	}
+
+	cacheDir, err := ctx.CacheDir()
+	if err != nil {
+		return false, fmt.Errorf("failed to get cache directory\n\t%w", err)
+	}
+
+	indexFilePath := cacheDir.Join(path.MustParse(gourl.QueryEscape(indexURL.String()) + ".index.json"))
+
+	isMigrated, err := ctx.Network().MigrateCachedContent(indexURL, cacheDir, indexFilePath, backupDir)
+	if err != nil {
+		return false, fmt.Errorf("failed to migrate cached registry index\n\t%w", err)
+	}
+
+	return isMigrated, nil
+}
+
+// ---------------------------------------------------------------------
+
+// getIndexFiles downloads the registry index into the cache directory unless it is not
+// modified, and builds its on-disk index if the registry index is newer.
+func getIndexFiles(ctx *context.Context) (indexFiles, error) {
+	if !IsConfigured(ctx) {
+		return indexFiles{}, fmt.Errorf("no registry is configured")
+	}
+
+	registryURL, err := ctx.RegistryURL()
+	if err != nil {
+		return indexFiles{}, fmt.Errorf("failed to get registry URL\n\t%w", err)
+	}
+
+	indexURL, err := network.GenerateRegistryIndexURL(registryURL)
+	if err != nil {
+		return indexFiles{}, fmt.Errorf("failed to generate registry index URL\n\t%w", err)
+	}
+
+	cacheDir, err := ctx.CacheDir()
+	if err != nil {
+		return indexFiles{}, fmt.Errorf("failed to get cache directory\n\t%w", err)
+	}
+
+	// Files are named after the URL, so that each registry has its own.
+	fileName := gourl.QueryEscape(indexURL.String())
+	files := indexFiles{
+		indexFilePath:   cacheDir.Join(path.MustParse(fileName + ".index.json")),
+		recordsFilePath: cacheDir.Join(path.MustParse(fileName + ".index.records")),
+	}
+
+	isDownloaded := false
+	if ctx.Offline() {
+		if err := ensureIndexCachedOffline(ctx, indexURL, cacheDir, files.indexFilePath); err != nil {
+			return indexFiles{}, err
+		}
+	} else {
+		proxyURL, err := ctx.ProxyURL()
+		if err != nil {
+			return indexFiles{}, fmt.Errorf("failed to get proxy URL\n\t%w", err)
+		}
+
+		isDownloaded, err = ctx.Network().DownloadFileWithRevalidation(ctx.GoContext(), indexURL, proxyURL, nil,
+			ctx.RetryPolicy(), files.indexFilePath)
+		if err != nil {
+			return indexFiles{}, fmt.Errorf("failed to fetch registry index\n\t%w", err)
+		}
+	}
+
+	isOutdated, err := isRecordsFileOutdated(ctx.FS(), files)
+	if err != nil {
+		return indexFiles{}, err
+	}
+
+	if isDownloaded || isOutdated {
+		if err := buildRecordsFile(ctx.FS(), files); err != nil {
+			return indexFiles{}, fmt.Errorf("failed to index registry index\n\t%w", err)
+		}
+	}
+
+	return files, nil
+}
+
+// ensureIndexCachedOffline makes sure the registry index is in the cache directory in offline
+// mode. A registry index cached in memory form by earlier versions of lip is written out.
+func ensureIndexCachedOffline(ctx *context.Context, indexURL *gourl.URL, cacheDir path.Path,
+	indexFilePath path.Path) error {
+	if _, err := ctx.FS().Stat(indexFilePath.LocalString()); err == nil {
+		return nil
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to stat registry index %v\n\t%w", indexFilePath.LocalString(), err)
+	}
+
+	content, err := ctx.Network().GetCachedContent(indexURL, cacheDir)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.FS().WriteFile(indexFilePath.LocalString(), content, 0644); err != nil {
+		return fmt.Errorf("failed to write registry index %v\n\t%w", indexFilePath.LocalString(), err)
+	}
+
+	return nil
+}
+
+// isRecordsFileOutdated returns whether the on-disk index is missing or older than the registry
+// index, e.g. if lip stopped before indexing a downloaded registry index.
+func isRecordsFileOutdated(fs vfs.FS, files indexFiles) (bool, error) {
+	indexFileInfo, err := fs.Stat(files.indexFilePath.LocalString())
+	if err != nil {
+		return false, fmt.Errorf("failed to stat registry index %v\n\t%w", files.indexFilePath.LocalString(), err)
+	}
+
+	recordsFileInfo, err := fs.Stat(files.recordsFilePath.LocalString())
+	if os.IsNotExist(err) {
+		return true, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to stat %v\n\t%w", files.recordsFilePath.LocalString(), err)
+	}
+
+	return recordsFileInfo.ModTime().Before(indexFileInfo.ModTime()), nil
+}
+
+// buildRecordsFile builds the on-disk index of the registry index, decoding the registry index
+// as a stream. The records are written to a temporary file first, so that a partial on-disk
+// index is never used.
+func buildRecordsFile(fs vfs.FS, files indexFiles) error {
+	indexFile, err := fs.Open(files.indexFilePath.LocalString())
+	if err != nil {
+		return fmt.Errorf("failed to open registry index %v\n\t%w", files.indexFilePath.LocalString(), err)
+	}
+	defer indexFile.Close()
+
+	tempFilePath := files.recordsFilePath.LocalString() + ".part"
+
+	recordsFile, err := fs.Create(tempFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to create %v\n\t%w", tempFilePath, err)
+	}
+
+	hash := sha256.New()
+	writer := bufio.NewWriter(recordsFile)
+
+	err = decodeIndex(io.TeeReader(indexFile, hash), func(fields ...string) error {
+		return writeRecord(writer, fields)
+	})
+	if err == nil {
+		err = writeRecord(writer, []string{digestRecordKind, hex.EncodeToString(hash.Sum(nil))})
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+
+	if closeErr := recordsFile.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		fs.Remove(tempFilePath)
+		return err
+	}
+
+	if err := fs.Rename(tempFilePath, files.recordsFilePath.LocalString()); err != nil {
+		return fmt.Errorf("failed to rename %v to %v\n\t%w", tempFilePath, files.recordsFilePath.LocalString(), err)
+	}
+
+	return nil
+}
+
+// decodeIndex decodes the registry index as a stream token by token, emitting the records of
+// its teeth, stats, aliases and capabilities. The reader is read to the end.
+func decodeIndex(reader io.Reader, emit func(fields ...string) error) error {
+	decoder := json.NewDecoder(reader)
+
+	if isNull, err := readDelim(decoder, '{'); err != nil {
+		return err
+	} else if isNull {
+		return errcode.Errorf(errcode.MetadataInvalid, "registry index is null")
+	}
+
+	for decoder.More() {
+		key, err := readString(decoder)
+		if err != nil {
+			return err
+		}
+
+		switch key {
+		case "teeth":
+			err = decodeArray(decoder, func() error {
+				toothRepoPath, err := readString(decoder)
+				if err != nil {
+					return err
+				}
+
+				return emit(toothRecordKind, toothRepoPath)
+			})
+
+		case "aliases", "capabilities":
+			kind := aliasRecordKind
+			if key == "capabilities" {
+				kind = capabilityRecordKind
+			}
+
+			err = decodeObject(decoder, func(name string) error {
+				var toothRepoPaths []string
+				if err := decoder.Decode(&toothRepoPaths); err != nil {
+					return errcode.Errorf(errcode.MetadataInvalid, "invalid %v of %v in registry index: %v", key,
+						name, err)
+				}
+
+				for _, toothRepoPath := range toothRepoPaths {
+					if err := emit(kind, name, toothRepoPath); err != nil {
+						return err
+					}
+				}
+
+				return nil
+			})
+
+		case "stats":
+			err = decodeObject(decoder, func(toothRepoPath string) error {
+				var stats json.RawMessage
+				if err := decoder.Decode(&stats); err != nil {
+					return errcode.Errorf(errcode.MetadataInvalid, "invalid stats of %v in registry index: %v",
+						toothRepoPath, err)
+				}
+
+				// The decoder is right after the stats, whose raw bytes are exactly as in the
+				// registry index.
+				offset := decoder.InputOffset() - int64(len(stats))
+
+				return emit(statsRecordKind, toothRepoPath, strconv.FormatInt(offset, 10),
+					strconv.Itoa(len(stats)))
+			})
+
+		default:
+			var skipped json.RawMessage
+			err = decoder.Decode(&skipped)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, err := readDelim(decoder, '}'); err != nil {
+		return err
+	}
+
+	// Read the rest, so that the whole registry index is hashed.
+	if _, err := io.Copy(io.Discard, reader); err != nil {
+		return fmt.Errorf("failed to read registry index\n\t%w", err)
+	}
+
+	return nil
+}
+
+// decodeArray decodes a JSON array element by element. Null is an empty array.
+func decodeArray(decoder *json.Decoder, decodeElement func() error) error {
+	if isNull, err := readDelim(decoder, '['); err != nil || isNull {
+		return err
+	}
+
+	for decoder.More() {
+		if err := decodeElement(); err != nil {
+			return err
+		}
+	}
+
+	_, err := readDelim(decoder, ']')
+	return err
+}
+
+// decodeObject decodes a JSON object member by member. The value of each member is left to be
+// decoded by decodeMember. Null is an empty object.
+func decodeObject(decoder *json.Decoder, decodeMember func(name string) error) error {
+	if isNull, err := readDelim(decoder, '{'); err != nil || isNull {
+		return err
+	}
+
+	for decoder.More() {
+		name, err := readString(decoder)
+		if err != nil {
+			return err
+		}
+
+		if err := decodeMember(name); err != nil {
+			return err
+		}
+	}
+
+	_, err := readDelim(decoder, '}')
+	return err
+}
+
+// readDelim reads a delimiter of the registry index, or null instead of an opening one. It
+// returns whether it read null.
+func readDelim(decoder *json.Decoder, delim json.Delim) (bool, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return false, errcode.Errorf(errcode.MetadataInvalid, "invalid registry index: %v", err)
+	}
+
+	if token == nil && (delim == '{' || delim == '[') {
+		return true, nil
+	}
+
+	if token != delim {
+		return false, errcode.Errorf(errcode.MetadataInvalid, "invalid registry index: expected %v, got %v", delim,
+			token)
+	}
+
+	return false, nil
+}
+
+// readString reads a string of the registry index, e.g. the name of a member.
+func readString(decoder *json.Decoder) (string, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return "", errcode.Errorf(errcode.MetadataInvalid, "invalid registry index: %v", err)
+	}
+
+	s, ok := token.(string)
+	if !ok {
+		return "", errcode.Errorf(errcode.MetadataInvalid, "invalid registry index: expected a string, got %v", token)
+	}
+
+	return s, nil
+}
+
+// writeRecord writes a record of the on-disk index. Records with fields that cannot be written,
+// i.e. with tabs or line breaks, are skipped, since no tooth, alias or capability has them.
+func writeRecord(writer *bufio.Writer, fields []string) error {
+	for _, field := range fields {
+		if strings.ContainsAny(field, "\t\r\n") {
+			return nil
+		}
+	}
+
+	if _, err := writer.WriteString(strings.Join(fields, "\t") + "\n"); err != nil {
+		return fmt.Errorf("failed to write record\n\t%w", err)
+	}
+
+	return nil
+}
+
+// scanRecords calls visit with the fields of each record of the on-disk index.
+func scanRecords(fs vfs.FS, recordsFilePath path.Path, visit func(fields []string)) error {
+	file, err := fs.Open(recordsFilePath.LocalString())
+	if err != nil {
+		return fmt.Errorf("failed to open %v\n\t%w", recordsFilePath.LocalString(), err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		visit(strings.Split(scanner.Text(), "\t"))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read %v\n\t%w", recordsFilePath.LocalString(), err)
+	}
+
+	return nil
+}
+
+// readStats reads the stats of teeth at their byte ranges in the registry index, in one pass
+// over it.
+func readStats(fs vfs.FS, indexFilePath path.Path, ranges []statsRange) (map[string]Stats, error) {
+	statsMap := make(map[string]Stats)
+
+	if len(ranges) == 0 {
+		return statsMap, nil
+	}
+
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].offset < ranges[j].offset
+	})
+
+	file, err := fs.Open(indexFilePath.LocalString())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open registry index %v\n\t%w", indexFilePath.LocalString(), err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	position := int64(0)
+
+	for _, r := range ranges {
+		if r.offset < position {
+			continue
+		}
+
+		if _, err := io.CopyN(io.Discard, reader, r.offset-position); err != nil {
+			return nil, fmt.Errorf("failed to read registry index %v\n\t%w", indexFilePath.LocalString(), err)
+		}
+
+		statsBytes := make([]byte, r.length)
+		if _, err := io.ReadFull(reader, statsBytes); err != nil {
+			return nil, fmt.Errorf("failed to read registry index %v\n\t%w", indexFilePath.LocalString(), err)
+		}
+
+		position = r.offset + r.length
+
+		var stats Stats
+		if err := json.Unmarshal(statsBytes, &stats); err != nil {
+			return nil, errcode.Errorf(errcode.MetadataInvalid, "invalid stats of %v in registry index: %v",
+				r.toothRepoPath, err)
+		}
+
+		statsMap[r.toothRepoPath] = stats
+	}
+
+	return statsMap, nil
+}
+
+// parseStatsRange parses the fields of a stats record.
+func parseStatsRange(fields []string) (statsRange, bool) {
+	if len(fields) != 4 {
+		return statsRange{}, false
+	}
+
+	offset, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return statsRange{}, false
+	}
+
+	length, err := strconv.ParseInt(fields[3], 10, 64)
+	if err != nil {
+		return statsRange{}, false
+	}
+
+	return statsRange{toothRepoPath: fields[1], offset: offset, length: length}, true
+}
@@ -0,0 +1,228 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	gopath "path"
+	"sort"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/network"
+)
+
+// Index is the list of all teeth in the registry. It is the format of the registry index, which
+// is decoded as a stream rather than unmarshaled into an Index, since it may be large.
+type Index struct {
+	Teeth []string `json:"teeth"`
+	// Aliases maps short names to the teeth they refer to, e.g. levilamina to
+	// github.com/LiteLDev/LeviLamina.
+	Aliases map[string][]string `json:"aliases,omitempty"`
+	// Capabilities maps capabilities to the teeth providing them, e.g. levilamina-loader to
+	// github.com/LiteLDev/LeviLamina.
+	Capabilities map[string][]string `json:"capabilities,omitempty"`
+	// Stats maps teeth to their stats, so that search results can show and be sorted by them
+	// without fetching the entry of every tooth.
+	Stats map[string]Stats `json:"stats,omitempty"`
+}
+
+// Entry is the registry entry of a tooth.
+type Entry struct {
+	ToothRepoPath string         `json:"tooth"`
+	Versions      []EntryVersion `json:"versions"`
+	Stats
+}
+
+// Stats is what a registry may tell about a tooth besides its versions. Each field is nil or
+// empty if the registry does not provide it.
+type Stats struct {
+	Downloads *int64 `json:"downloads,omitempty"`
+	Stars     *int64 `json:"stars,omitempty"`
+	// Maintenance is the maintenance status of the tooth, e.g. active, passive or unmaintained.
+	Maintenance string `json:"maintenance,omitempty"`
+}
+
+// EntryVersion is the registry information of a version of a tooth.
+type EntryVersion struct {
+	Version      string `json:"version"`
+	Yanked       bool   `json:"yanked,omitempty"`
+	YankedReason string `json:"yanked_reason,omitempty"`
+}
+
+// IsConfigured returns whether a registry is configured. The registry is not used in vendor
+// mode, where teeth are resolved exclusively from the vendor directory.
+func IsConfigured(ctx *context.Context) bool {
+	return ctx.Config().RegistryURL != "" && !ctx.Vendor()
+}
+
+// FindToothRepoPathsByAlias returns the teeth in the registry an alias refers to, sorted. Besides
+// the aliases declared in the registry index, an alias refers to the teeth whose repo paths end
+// with it. Aliases are case-insensitive.
+func FindToothRepoPathsByAlias(ctx *context.Context, alias string) ([]string, error) {
+	files, err := getIndexFiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	toothRepoPathSet := make(map[string]bool)
+
+	err = scanRecords(ctx.FS(), files.recordsFilePath, func(fields []string) {
+		switch {
+		case fields[0] == aliasRecordKind && len(fields) == 3 && strings.EqualFold(fields[1], alias):
+			toothRepoPathSet[fields[2]] = true
+
+		case fields[0] == toothRecordKind && len(fields) == 2 && strings.EqualFold(gopath.Base(fields[1]), alias):
+			toothRepoPathSet[fields[1]] = true
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	toothRepoPaths := make([]string, 0, len(toothRepoPathSet))
+	for toothRepoPath := range toothRepoPathSet {
+		toothRepoPaths = append(toothRepoPaths, toothRepoPath)
+	}
+	sort.Strings(toothRepoPaths)
+
+	return toothRepoPaths, nil
+}
+
+// FindToothRepoPathsByCapability returns the teeth in the registry providing a capability,
+// sorted.
+func FindToothRepoPathsByCapability(ctx *context.Context, capability string) ([]string, error) {
+	files, err := getIndexFiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	toothRepoPaths := make([]string, 0)
+	err = scanRecords(ctx.FS(), files.recordsFilePath, func(fields []string) {
+		if fields[0] == capabilityRecordKind && len(fields) == 3 && fields[1] == capability {
+			toothRepoPaths = append(toothRepoPaths, fields[2])
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(toothRepoPaths)
+
+	return toothRepoPaths, nil
+}
+
+// GetEntry fetches the registry entry of a tooth.
+func GetEntry(ctx *context.Context, toothRepoPath string) (Entry, error) {
+	if !IsConfigured(ctx) {
+		return Entry{}, fmt.Errorf("no registry is configured")
+	}
+
+	registryURL, err := ctx.RegistryURL()
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to get registry URL\n\t%w", err)
+	}
+
+	entryURL, err := network.GenerateRegistryToothURL(toothRepoPath, registryURL)
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to generate registry entry URL\n\t%w", err)
+	}
+
+	proxyURL, err := ctx.ProxyURL()
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to get proxy URL\n\t%w", err)
+	}
+
+	cacheDir, err := ctx.CacheDir()
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to get cache directory\n\t%w", err)
+	}
+
+	var content []byte
+	if ctx.Offline() {
+		content, err = ctx.Network().GetCachedContent(entryURL, cacheDir)
+	} else {
+		content, err = ctx.Network().GetContentWithRevalidation(ctx.GoContext(), entryURL, proxyURL, nil,
+			ctx.RetryPolicy(), cacheDir)
+	}
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to fetch registry entry of %v\n\t%w", toothRepoPath, err)
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(content, &entry); err != nil {
+		return Entry{}, fmt.Errorf("failed to unmarshal registry entry of %v\n\t%w", toothRepoPath, err)
+	}
+
+	if entry.ToothRepoPath != toothRepoPath {
+		return Entry{}, fmt.Errorf("registry entry mismatch: %v != %v", entry.ToothRepoPath, toothRepoPath)
+	}
+
+	return entry, nil
+}
+
+// GetIndexDigest returns the SHA-256 digest of the registry index. It changes whenever the
+// registry index is updated.
+func GetIndexDigest(ctx *context.Context) (string, error) {
+	files, err := getIndexFiles(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	digest := ""
+	err = scanRecords(ctx.FS(), files.recordsFilePath, func(fields []string) {
+		if fields[0] == digestRecordKind && len(fields) == 2 {
+			digest = fields[1]
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return digest, nil
+}
+
+// GetYankedVersions returns the yanked versions of a tooth mapped to the reasons. If no
+// registry is configured, no version is yanked.
+func GetYankedVersions(ctx *context.Context, toothRepoPath string) (map[string]string, error) {
+	yankedVersions := make(map[string]string)
+
+	if !IsConfigured(ctx) {
+		return yankedVersions, nil
+	}
+
+	entry, err := GetEntry(ctx, toothRepoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entryVersion := range entry.Versions {
+		if !entryVersion.Yanked {
+			continue
+		}
+
+		version, err := semver.Parse(entryVersion.Version)
+		if err != nil {
+			continue
+		}
+
+		yankedVersions[version.String()] = entryVersion.YankedReason
+	}
+
+	return yankedVersions, nil
+}
+
+// FormatCount formats a count in stats, e.g. 1234 as 1.2k. It is empty if the registry does not
+// provide the count.
+func FormatCount(count *int64) string {
+	switch {
+	case count == nil:
+		return ""
+	case *count >= 1000000:
+		return fmt.Sprintf("%.1fM", float64(*count)/1000000)
+	case *count >= 1000:
+		return fmt.Sprintf("%.1fk", float64(*count)/1000)
+	default:
+		return fmt.Sprintf("%v", *count)
+	}
+}
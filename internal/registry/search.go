@@ -0,0 +1,132 @@
+package registry
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+)
+
+// SearchResult is a tooth found in the registry with its stats.
+type SearchResult struct {
+	ToothRepoPath string `json:"tooth"`
+	Stats
+}
+
+// SortKey is what search results are sorted by.
+type SortKey string
+
+const (
+	// NameSortKey sorts by tooth repo paths, in ascending order.
+	NameSortKey SortKey = "name"
+	// DownloadsSortKey sorts by download counts, in descending order.
+	DownloadsSortKey SortKey = "downloads"
+	// StarsSortKey sorts by stars, in descending order.
+	StarsSortKey SortKey = "stars"
+)
+
+// ParseSortKey parses a sort key.
+func ParseSortKey(s string) (SortKey, error) {
+	switch SortKey(s) {
+	case NameSortKey, DownloadsSortKey, StarsSortKey:
+		return SortKey(s), nil
+	default:
+		return "", errcode.Errorf(errcode.InvalidArgument, "invalid sort key %v, expected one of %v, %v and %v", s,
+			NameSortKey, DownloadsSortKey, StarsSortKey)
+	}
+}
+
+// Search finds the teeth in the registry whose repo paths or aliases contain the query,
+// case-insensitively, sorted by the sort key. Teeth whose stats are not provided are sorted
+// after the others.
+func Search(ctx *context.Context, query string, sortKey SortKey) ([]SearchResult, error) {
+	files, err := getIndexFiles(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get registry index\n\t%w", err)
+	}
+
+	query = strings.ToLower(query)
+
+	// Find the matching teeth first, then read the stats of only these.
+
+	toothRepoPathSet := make(map[string]bool)
+
+	err = scanRecords(ctx.FS(), files.recordsFilePath, func(fields []string) {
+		switch {
+		case fields[0] == toothRecordKind && len(fields) == 2 && strings.Contains(strings.ToLower(fields[1]), query):
+			toothRepoPathSet[fields[1]] = true
+
+		case fields[0] == aliasRecordKind && len(fields) == 3 && strings.Contains(strings.ToLower(fields[1]), query):
+			toothRepoPathSet[fields[2]] = true
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ranges := make([]statsRange, 0)
+	err = scanRecords(ctx.FS(), files.recordsFilePath, func(fields []string) {
+		if fields[0] != statsRecordKind {
+			return
+		}
+
+		if r, ok := parseStatsRange(fields); ok && toothRepoPathSet[r.toothRepoPath] {
+			ranges = append(ranges, r)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	statsMap, err := readStats(ctx.FS(), files.indexFilePath, ranges)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]SearchResult, 0, len(toothRepoPathSet))
+	for toothRepoPath := range toothRepoPathSet {
+		results = append(results, SearchResult{
+			ToothRepoPath: toothRepoPath,
+			Stats:         statsMap[toothRepoPath],
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		switch sortKey {
+		case DownloadsSortKey:
+			if c := compareCounts(results[i].Downloads, results[j].Downloads); c != 0 {
+				return c > 0
+			}
+		case StarsSortKey:
+			if c := compareCounts(results[i].Stars, results[j].Stars); c != 0 {
+				return c > 0
+			}
+		}
+
+		return results[i].ToothRepoPath < results[j].ToothRepoPath
+	})
+
+	return results, nil
+}
+
+// ---------------------------------------------------------------------
+
+// compareCounts compares two counts in stats. A count not provided is less than any other.
+func compareCounts(a *int64, b *int64) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	case *a > *b:
+		return 1
+	case *a < *b:
+		return -1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package render
+
+import "os"
+
+// enableEscapeSequences reports whether a terminal shows ANSI escape sequences, which every
+// terminal does outside Windows.
+func enableEscapeSequences(file *os.File) bool {
+	return true
+}
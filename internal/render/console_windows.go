@@ -0,0 +1,25 @@
+package render
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// enableEscapeSequences turns on virtual terminal processing of a console, so that it shows
+// ANSI escape sequences. It reports false on consoles without it, e.g. the legacy console of
+// older Windows versions.
+func enableEscapeSequences(file *os.File) bool {
+	handle := windows.Handle(file.Fd())
+
+	var mode uint32
+	if err := windows.GetConsoleMode(handle, &mode); err != nil {
+		return false
+	}
+
+	if mode&windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING != 0 {
+		return true
+	}
+
+	return windows.SetConsoleMode(handle, mode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING) == nil
+}
@@ -0,0 +1,141 @@
+package render
+
+import (
+	"os"
+	"sync"
+
+	"github.com/lippkg/lip/internal/context"
+	"golang.org/x/term"
+)
+
+// Role is what a piece of output means, which decides how a theme styles it.
+type Role int
+
+const (
+	// AddedRole is something added, e.g. a tooth to install.
+	AddedRole Role = iota
+	// RemovedRole is something removed, e.g. a tooth to uninstall.
+	RemovedRole
+	// ChangedRole is something changed, e.g. a tooth to upgrade.
+	ChangedRole
+	// DowngradedRole is something changed to an older version.
+	DowngradedRole
+	// UnchangedRole is something kept or done again as it is, e.g. a tooth to reinstall.
+	UnchangedRole
+	// HeadingRole is the heading of a list or a table.
+	HeadingRole
+	// WarningRole is something that needs attention.
+	WarningRole
+	// ErrorRole is a failure.
+	ErrorRole
+)
+
+// theme is how each role is styled: the SGR parameters of its color and its emoji. Roles
+// without either are left as they are.
+type theme struct {
+	colors map[Role]string
+	emoji  map[Role]string
+}
+
+var defaultColors = map[Role]string{
+	AddedRole:      "32",
+	RemovedRole:    "31",
+	ChangedRole:    "36",
+	DowngradedRole: "33",
+	UnchangedRole:  "2",
+	HeadingRole:    "1",
+	WarningRole:    "33",
+	ErrorRole:      "1;31",
+}
+
+var themes = map[string]theme{
+	context.DefaultTheme: {
+		colors: defaultColors,
+		emoji: map[Role]string{
+			AddedRole:      "✨",
+			RemovedRole:    "🗑️",
+			ChangedRole:    "⬆️",
+			DowngradedRole: "⬇️",
+			UnchangedRole:  "🔁",
+			WarningRole:    "⚠️",
+			ErrorRole:      "❌",
+		},
+	},
+	context.MinimalTheme: {
+		colors: defaultColors,
+	},
+	context.PlainTheme: {},
+}
+
+var (
+	mu           sync.Mutex
+	currentTheme = themes[context.DefaultTheme]
+	isEnabled    = false
+)
+
+// Init enables styling if the standard output is a terminal that supports it, and NO_COLOR is
+// not set. On Windows, escape sequences are enabled in the console first, and styling stays
+// disabled on consoles without them. Emoji need them as well, since such consoles cannot show
+// them either.
+func Init() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	isEnabled = os.Getenv("NO_COLOR") == "" && term.IsTerminal(int(os.Stdout.Fd())) &&
+		enableEscapeSequences(os.Stdout)
+}
+
+// Disable disables styling, e.g. for --no-color.
+func Disable() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	isEnabled = false
+}
+
+// IsEnabled returns whether output is styled.
+func IsEnabled() bool {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return isEnabled
+}
+
+// SetTheme sets the theme by its name, one of the Theme configuration values. Unknown names
+// are ignored.
+func SetTheme(name string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if newTheme, ok := themes[name]; ok {
+		currentTheme = newTheme
+	}
+}
+
+// Style colors text by its role.
+func Style(role Role, text string) string {
+	mu.Lock()
+	defer mu.Unlock()
+
+	color, ok := currentTheme.colors[role]
+	if !isEnabled || !ok || text == "" {
+		return text
+	}
+
+	return "\x1b[" + color + "m" + text + "\x1b[0m"
+}
+
+// Label colors text by its role, and puts the emoji of the role before it.
+func Label(role Role, text string) string {
+	styledText := Style(role, text)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	emoji, ok := currentTheme.emoji[role]
+	if !isEnabled || !ok {
+		return styledText
+	}
+
+	return emoji + " " + styledText
+}
@@ -0,0 +1,178 @@
+package resolution
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/path"
+	"github.com/lippkg/lip/internal/registry"
+	log "github.com/sirupsen/logrus"
+)
+
+const cacheFileName = "resolution.json"
+
+// cacheData is the content of the resolution cache file. The version lists and resolution
+// results are valid as long as the registry index has the digest IndexDigest.
+type cacheData struct {
+	IndexDigest string              `json:"index_digest"`
+	Versions    map[string][]string `json:"versions"`
+	Resolutions map[string]string   `json:"resolutions"`
+}
+
+// cache is the resolution cache loaded for the current run. It is nil if the cache is not
+// loaded yet or not usable.
+var cache *cacheData
+var cacheFilePath path.Path
+var isCacheLoaded bool
+var cacheMutex sync.Mutex
+
+// GetVersions returns the cached version list of a tooth.
+func GetVersions(ctx *context.Context, toothRepoPath string) ([]string, bool) {
+	cacheMutex.Lock()
+	defer cacheMutex.Unlock()
+
+	if !loadCache(ctx) {
+		return nil, false
+	}
+
+	versions, ok := cache.Versions[toothRepoPath]
+	return versions, ok
+}
+
+// SetVersions caches the version list of a tooth.
+func SetVersions(ctx *context.Context, toothRepoPath string, versions []string) {
+	cacheMutex.Lock()
+	defer cacheMutex.Unlock()
+
+	if !loadCache(ctx) {
+		return
+	}
+
+	cache.Versions[toothRepoPath] = versions
+	saveCache(ctx)
+}
+
+// GetResolution returns the version a tooth was resolved to for a version range.
+func GetResolution(ctx *context.Context, toothRepoPath string, versionRange string) (semver.Version, bool) {
+	cacheMutex.Lock()
+	defer cacheMutex.Unlock()
+
+	if !loadCache(ctx) {
+		return semver.Version{}, false
+	}
+
+	versionString, ok := cache.Resolutions[getResolutionKey(ctx, toothRepoPath, versionRange)]
+	if !ok {
+		return semver.Version{}, false
+	}
+
+	version, err := semver.Parse(versionString)
+	if err != nil {
+		return semver.Version{}, false
+	}
+
+	return version, true
+}
+
+// SetResolution caches the version a tooth is resolved to for a version range.
+func SetResolution(ctx *context.Context, toothRepoPath string, versionRange string, version semver.Version) {
+	cacheMutex.Lock()
+	defer cacheMutex.Unlock()
+
+	if !loadCache(ctx) {
+		return
+	}
+
+	cache.Resolutions[getResolutionKey(ctx, toothRepoPath, versionRange)] = version.String()
+	saveCache(ctx)
+}
+
+// ---------------------------------------------------------------------
+
+// getResolutionKey returns the key of a resolution result. Results differ when yanked versions
+// are allowed, so it is part of the key.
+func getResolutionKey(ctx *context.Context, toothRepoPath string, versionRange string) string {
+	if ctx.AllowYanked() {
+		return fmt.Sprintf("%v %v allow-yanked", toothRepoPath, versionRange)
+	}
+
+	return fmt.Sprintf("%v %v", toothRepoPath, versionRange)
+}
+
+// loadCache loads the resolution cache once per run and returns whether it is usable. The
+// cache is only usable when a registry is configured, since the registry index is what tells
+// whether the cached results are stale. A stale cache is discarded.
+func loadCache(ctx *context.Context) bool {
+	debugLogger := ctx.Logger().WithFields(log.Fields{
+		"package": "resolution",
+		"method":  "loadCache",
+	})
+
+	if isCacheLoaded {
+		return cache != nil
+	}
+	isCacheLoaded = true
+
+	if !registry.IsConfigured(ctx) {
+		debugLogger.Debug("No registry is configured, resolution cache disabled")
+		return false
+	}
+
+	indexDigest, err := registry.GetIndexDigest(ctx)
+	if err != nil {
+		debugLogger.Debugf("Failed to get registry index digest, resolution cache disabled: %v", err)
+		return false
+	}
+
+	cacheDir, err := ctx.CacheDir()
+	if err != nil {
+		debugLogger.Debugf("Failed to get cache directory, resolution cache disabled: %v", err)
+		return false
+	}
+
+	cacheFilePath = cacheDir.Join(path.MustParse(cacheFileName))
+
+	data := cacheData{}
+	jsonBytes, err := ctx.FS().ReadFile(cacheFilePath.LocalString())
+	if err == nil {
+		if err := json.Unmarshal(jsonBytes, &data); err != nil {
+			debugLogger.Debugf("Failed to unmarshal resolution cache, discarding it: %v", err)
+			data = cacheData{}
+		}
+	}
+
+	if data.IndexDigest != indexDigest || data.Versions == nil || data.Resolutions == nil {
+		debugLogger.Debug("Registry index changed, discarding resolution cache")
+		data = cacheData{
+			IndexDigest: indexDigest,
+			Versions:    make(map[string][]string),
+			Resolutions: make(map[string]string),
+		}
+	}
+
+	cache = &data
+
+	return true
+}
+
+// saveCache writes the resolution cache to the cache directory. Failures only mean that the
+// results are resolved again next time, so they are not reported.
+func saveCache(ctx *context.Context) {
+	debugLogger := ctx.Logger().WithFields(log.Fields{
+		"package": "resolution",
+		"method":  "saveCache",
+	})
+
+	jsonBytes, err := json.MarshalIndent(cache, "", "    ")
+	if err != nil {
+		debugLogger.Debugf("Failed to marshal resolution cache: %v", err)
+		return
+	}
+
+	if err := ctx.FS().WriteFile(cacheFilePath.LocalString(), jsonBytes, 0644); err != nil {
+		debugLogger.Debugf("Failed to write resolution cache: %v", err)
+	}
+}
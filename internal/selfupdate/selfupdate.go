@@ -0,0 +1,348 @@
+package selfupdate
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/network"
+	"github.com/lippkg/lip/internal/path"
+	"github.com/lippkg/lip/internal/tooth"
+	"github.com/lippkg/lip/internal/vfs"
+	"github.com/lippkg/lip/internal/zip"
+	log "github.com/sirupsen/logrus"
+)
+
+// LipRepoPath is the repository path where lip is released.
+const LipRepoPath = "github.com/lippkg/lip"
+
+const oldExecutableSuffix = ".old"
+
+// GetLatestVersion returns the latest released version of lip.
+func GetLatestVersion(ctx *context.Context) (semver.Version, error) {
+	version, err := tooth.GetLatestVersion(ctx, LipRepoPath)
+	if err != nil {
+		return semver.Version{}, fmt.Errorf("failed to get latest version of lip\n\t%w", err)
+	}
+
+	return version, nil
+}
+
+// Download downloads the release archive of lip of a version for the current platform into
+// the cache directory, and verifies it against the checksum published with the release, whose
+// signature is verified against the public key embedded in lip first.
+func Download(ctx *context.Context, version semver.Version) (path.Path, error) {
+	debugLogger := ctx.Logger().WithFields(log.Fields{
+		"package": "selfupdate",
+		"method":  "Download",
+	})
+
+	if ctx.Offline() {
+		return path.Path{}, errcode.Errorf(errcode.Offline, "cannot download lip in offline mode")
+	}
+
+	archiveFileName := getArchiveFileName()
+
+	archiveURL, err := generateReleaseFileURL(ctx, version, archiveFileName)
+	if err != nil {
+		return path.Path{}, fmt.Errorf("failed to generate release archive URL\n\t%w", err)
+	}
+
+	checksumURL, err := generateReleaseFileURL(ctx, version, archiveFileName+".sha256")
+	if err != nil {
+		return path.Path{}, fmt.Errorf("failed to generate checksum URL\n\t%w", err)
+	}
+
+	signatureURL, err := generateReleaseFileURL(ctx, version, archiveFileName+".sha256"+signatureSuffix)
+	if err != nil {
+		return path.Path{}, fmt.Errorf("failed to generate signature URL\n\t%w", err)
+	}
+
+	proxyURL, err := ctx.ProxyURL()
+	if err != nil {
+		return path.Path{}, fmt.Errorf("failed to get proxy URL\n\t%w", err)
+	}
+
+	checksumContent, err := ctx.Network().GetContent(ctx.GoContext(), checksumURL, proxyURL, nil, ctx.RetryPolicy())
+	if err != nil {
+		return path.Path{}, fmt.Errorf("failed to fetch checksum of lip %v\n\t%w", version, err)
+	}
+
+	// The checksum comes from the same mirror as the archive, so it is trusted only if it is
+	// signed with the release key.
+	signatureContent, err := ctx.Network().GetContent(ctx.GoContext(), signatureURL, proxyURL, nil, ctx.RetryPolicy())
+	if err != nil {
+		return path.Path{}, fmt.Errorf("failed to fetch signature of checksum of lip %v\n\t%w", version, err)
+	}
+
+	if err := verifySignature(releasePublicKey, checksumContent, signatureContent); err != nil {
+		return path.Path{}, fmt.Errorf("failed to verify signature of checksum of lip %v\n\t%w", version, err)
+	}
+
+	debugLogger.Debugf("Verified signature of checksum of lip %v", version)
+
+	expectedChecksum, err := parseChecksum(checksumContent)
+	if err != nil {
+		return path.Path{}, fmt.Errorf("failed to parse checksum of lip %v\n\t%w", version, err)
+	}
+
+	cacheDir, err := ctx.CacheDir()
+	if err != nil {
+		return path.Path{}, fmt.Errorf("failed to get cache directory\n\t%w", err)
+	}
+
+	archivePath := cacheDir.Join(path.MustParse(url.QueryEscape(archiveURL.String())))
+
+	debugLogger.Debugf("Downloading %v to %v", archiveURL, archivePath.LocalString())
+
+	checksum, err := ctx.Network().DownloadFile(ctx.GoContext(), archiveURL, proxyURL, nil, archivePath, true,
+		ctx.RetryPolicy())
+	if err != nil {
+		return path.Path{}, fmt.Errorf("failed to download lip %v\n\t%w", version, err)
+	}
+
+	if checksum != expectedChecksum {
+		ctx.FS().Remove(archivePath.LocalString())
+		return path.Path{}, errcode.Errorf(errcode.ChecksumMismatch, "checksum mismatch for lip %v: expected %v, got %v", version,
+			expectedChecksum, checksum)
+	}
+
+	return archivePath, nil
+}
+
+// Apply extracts the lip executable from a release archive and replaces the running
+// executable with it.
+//
+// The new executable is first written next to the running one, then moved into place by
+// renaming. The running executable is renamed aside rather than overwritten, because Windows
+// does not allow overwriting an executable in use. The renamed one is removed by CleanUp.
+// The archive is read from the file system of the context, but the executable is always
+// replaced on the file system of the operating system.
+func Apply(ctx *context.Context, archivePath path.Path) error {
+	executablePath, err := getExecutablePath()
+	if err != nil {
+		return fmt.Errorf("failed to get executable path\n\t%w", err)
+	}
+
+	newExecutablePath := executablePath + ".new"
+	oldExecutablePath := executablePath + oldExecutableSuffix
+
+	if err := extractExecutable(ctx.FS(), archivePath, newExecutablePath); err != nil {
+		os.Remove(newExecutablePath)
+		return fmt.Errorf("failed to extract lip executable\n\t%w", err)
+	}
+
+	// A leftover from a previous update may exist on Windows.
+	if err := os.Remove(oldExecutablePath); err != nil && !os.IsNotExist(err) {
+		os.Remove(newExecutablePath)
+		return fmt.Errorf("failed to remove %v\n\t%w", oldExecutablePath, err)
+	}
+
+	if err := os.Rename(executablePath, oldExecutablePath); err != nil {
+		os.Remove(newExecutablePath)
+		return fmt.Errorf("failed to move aside the running executable\n\t%w", err)
+	}
+
+	if err := os.Rename(newExecutablePath, executablePath); err != nil {
+		// Restore the running executable.
+		if restoreErr := os.Rename(oldExecutablePath, executablePath); restoreErr != nil {
+			return fmt.Errorf("failed to restore the executable from %v\n\t%w", oldExecutablePath, restoreErr)
+		}
+
+		os.Remove(newExecutablePath)
+		return fmt.Errorf("failed to move the new executable into place\n\t%w", err)
+	}
+
+	// On Windows, the running executable cannot be removed. It will be removed next time.
+	if runtime.GOOS != "windows" {
+		os.Remove(oldExecutablePath)
+	}
+
+	return nil
+}
+
+// CleanUp removes the executable left behind by a previous update.
+func CleanUp() error {
+	executablePath, err := getExecutablePath()
+	if err != nil {
+		return fmt.Errorf("failed to get executable path\n\t%w", err)
+	}
+
+	if err := os.Remove(executablePath + oldExecutableSuffix); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove old executable\n\t%w", err)
+	}
+
+	return nil
+}
+
+// ---------------------------------------------------------------------
+
+// extractExecutable extracts the lip executable from a release archive on a file system to a
+// file of the operating system.
+func extractExecutable(fs vfs.FS, archivePath path.Path, destPath string) error {
+	executableName := getExecutableName()
+
+	var content []byte
+	var err error
+	if strings.HasSuffix(archivePath.LocalString(), ".zip") {
+		content, err = readFileFromZip(fs, archivePath, executableName)
+	} else {
+		content, err = readFileFromTarGz(fs, archivePath, executableName)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(destPath, content, 0755); err != nil {
+		return fmt.Errorf("failed to write %v\n\t%w", destPath, err)
+	}
+
+	return nil
+}
+
+// generateReleaseFileURL generates the URL of a file attached to a lip release, with the
+// GitHub mirror applied.
+func generateReleaseFileURL(ctx *context.Context, version semver.Version, fileName string) (*url.URL, error) {
+	releaseFileURL, err := url.Parse(fmt.Sprintf("https://%v/releases/download/v%v/%v", LipRepoPath, version,
+		fileName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse release file URL\n\t%w", err)
+	}
+
+	gitHubMirrorURL, err := ctx.GitHubMirrorURL()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get GitHub mirror URL\n\t%w", err)
+	}
+
+	mirroredURL, err := network.GenerateGitHubMirrorURL(releaseFileURL, gitHubMirrorURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate GitHub mirror URL\n\t%w", err)
+	}
+
+	return mirroredURL, nil
+}
+
+// getArchiveFileName returns the file name of the release archive for the current platform.
+func getArchiveFileName() string {
+	if runtime.GOOS == "windows" {
+		return fmt.Sprintf("lip-%v-%v.zip", runtime.GOOS, runtime.GOARCH)
+	}
+
+	return fmt.Sprintf("lip-%v-%v.tar.gz", runtime.GOOS, runtime.GOARCH)
+}
+
+// getExecutableName returns the file name of the lip executable for the current platform.
+func getExecutableName() string {
+	if runtime.GOOS == "windows" {
+		return "lip.exe"
+	}
+
+	return "lip"
+}
+
+// getExecutablePath returns the path of the running executable with symlinks resolved.
+func getExecutablePath() (string, error) {
+	executablePath, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.EvalSymlinks(executablePath)
+}
+
+// parseChecksum parses a checksum file in the format of sha256sum, whose first field is the
+// hex-encoded checksum.
+func parseChecksum(content []byte) (string, error) {
+	fields := strings.Fields(string(content))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("empty checksum file")
+	}
+
+	checksum := strings.ToLower(fields[0])
+	if decoded, err := hex.DecodeString(checksum); err != nil || len(decoded) != sha256.Size {
+		return "", fmt.Errorf("invalid SHA-256 checksum: %v", fields[0])
+	}
+
+	return checksum, nil
+}
+
+// readFileFromTarGz reads a file at the root of a .tar.gz archive on a file system.
+func readFileFromTarGz(fs vfs.FS, archivePath path.Path, fileName string) ([]byte, error) {
+	file, err := fs.Open(archivePath.LocalString())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %v\n\t%w", archivePath.LocalString(), err)
+	}
+	defer file.Close()
+
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read gzip stream\n\t%w", err)
+	}
+	defer gzipReader.Close()
+
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tar archive\n\t%w", err)
+		}
+
+		if header.Typeflag != tar.TypeReg || filepath.Clean(header.Name) != fileName {
+			continue
+		}
+
+		var buffer bytes.Buffer
+		if _, err := io.Copy(&buffer, tarReader); err != nil {
+			return nil, fmt.Errorf("failed to read %v from archive\n\t%w", fileName, err)
+		}
+
+		return buffer.Bytes(), nil
+	}
+
+	return nil, fmt.Errorf("%v not found in %v", fileName, archivePath.LocalString())
+}
+
+// readFileFromZip reads a file at the root of a .zip archive on a file system.
+func readFileFromZip(fs vfs.FS, archivePath path.Path, fileName string) ([]byte, error) {
+	r, err := zip.OpenReader(fs, archivePath.LocalString())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %v\n\t%w", archivePath.LocalString(), err)
+	}
+	defer r.Close()
+
+	for _, file := range r.File {
+		if file.FileInfo().IsDir() || file.Name != fileName {
+			continue
+		}
+
+		rc, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %v in archive\n\t%w", fileName, err)
+		}
+		defer rc.Close()
+
+		content, err := io.ReadAll(rc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %v from archive\n\t%w", fileName, err)
+		}
+
+		return content, nil
+	}
+
+	return nil, fmt.Errorf("%v not found in %v", fileName, archivePath.LocalString())
+}
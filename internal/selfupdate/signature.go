@@ -0,0 +1,87 @@
+package selfupdate
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/lippkg/lip/internal/errcode"
+)
+
+// releasePublicKey is the minisign public key the checksum files of lip releases are signed
+// with, i.e. the second line of the .pub file made by minisign -G. It is embedded when releases
+// are built:
+//
+//	go build -ldflags "-X github.com/lippkg/lip/internal/selfupdate.releasePublicKey=<key>"
+//
+// Builds without it cannot update themselves, since downloads could not be verified.
+var releasePublicKey = ""
+
+// signatureSuffix is appended to the name of a checksum file to get the name of its signature.
+const signatureSuffix = ".minisig"
+
+// minisignAlgorithm identifies Ed25519 signatures of whole files in minisign public keys and
+// signatures. Prehashed signatures are not supported, since checksum files are small and are
+// signed with minisign -S -l.
+const minisignAlgorithm = "Ed"
+
+const minisignKeyIDSize = 8
+
+// verifySignature verifies the minisign signature of the content of a file, and the trusted
+// comment signed with it, against a minisign public key.
+func verifySignature(publicKeyString string, content []byte, signatureContent []byte) error {
+	if publicKeyString == "" {
+		return errcode.Errorf(errcode.VerificationFailed,
+			"this build of lip has no public key to verify releases with, download lip from its release page instead")
+	}
+
+	publicKeyBytes, err := base64.StdEncoding.DecodeString(strings.TrimSpace(publicKeyString))
+	if err != nil || len(publicKeyBytes) != len(minisignAlgorithm)+minisignKeyIDSize+ed25519.PublicKeySize ||
+		string(publicKeyBytes[:len(minisignAlgorithm)]) != minisignAlgorithm {
+		return fmt.Errorf("invalid release public key")
+	}
+
+	keyID := publicKeyBytes[len(minisignAlgorithm) : len(minisignAlgorithm)+minisignKeyIDSize]
+	publicKey := ed25519.PublicKey(publicKeyBytes[len(minisignAlgorithm)+minisignKeyIDSize:])
+
+	// A signature file has an untrusted comment, the signature, a trusted comment and the
+	// signature of the signature and the trusted comment, one per line.
+	lines := strings.Split(strings.ReplaceAll(string(signatureContent), "\r\n", "\n"), "\n")
+	if len(lines) < 4 || !strings.HasPrefix(lines[0], "untrusted comment: ") ||
+		!strings.HasPrefix(lines[2], "trusted comment: ") {
+		return errcode.Errorf(errcode.VerificationFailed, "malformed signature file")
+	}
+
+	signatureBytes, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil || len(signatureBytes) != len(minisignAlgorithm)+minisignKeyIDSize+ed25519.SignatureSize {
+		return errcode.Errorf(errcode.VerificationFailed, "malformed signature")
+	}
+
+	if string(signatureBytes[:len(minisignAlgorithm)]) != minisignAlgorithm {
+		return errcode.Errorf(errcode.VerificationFailed, "unsupported signature algorithm %v, expected %v",
+			string(signatureBytes[:len(minisignAlgorithm)]), minisignAlgorithm)
+	}
+
+	if !bytes.Equal(signatureBytes[len(minisignAlgorithm):len(minisignAlgorithm)+minisignKeyIDSize], keyID) {
+		return errcode.Errorf(errcode.VerificationFailed, "signature is not made with the release key")
+	}
+
+	signature := signatureBytes[len(minisignAlgorithm)+minisignKeyIDSize:]
+	if !ed25519.Verify(publicKey, content, signature) {
+		return errcode.Errorf(errcode.VerificationFailed, "invalid signature")
+	}
+
+	globalSignature, err := base64.StdEncoding.DecodeString(lines[3])
+	if err != nil || len(globalSignature) != ed25519.SignatureSize {
+		return errcode.Errorf(errcode.VerificationFailed, "malformed signature of trusted comment")
+	}
+
+	trustedComment := strings.TrimPrefix(lines[2], "trusted comment: ")
+	if !ed25519.Verify(publicKey, append(append([]byte{}, signature...), trustedComment...), globalSignature) {
+		return errcode.Errorf(errcode.VerificationFailed, "invalid signature of trusted comment")
+	}
+
+	return nil
+}
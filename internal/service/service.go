@@ -0,0 +1,169 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/path"
+	"github.com/lippkg/lip/internal/tooth"
+)
+
+// Registration is an OS service registered for an installed tooth. It is kept until the tooth
+// is uninstalled, also when the tooth is upgraded, since the service runs the program at the
+// same place in the workspace.
+type Registration struct {
+	ToothRepoPath string `json:"tooth"`
+	Name          string `json:"name"`
+}
+
+// unitConfig is what an OS service is registered with.
+type unitConfig struct {
+	name        string
+	description string
+	// execPath is the absolute path of the program to run.
+	execPath string
+	args     []string
+	// workDir is the absolute path of the directory to run the program in.
+	workDir string
+}
+
+// Register registers the service of a tooth with the OS, replacing the one registered before
+// under the same name, and records the registration. It usually requires administrator
+// privileges.
+func Register(ctx *context.Context, toothRepoPath string, service tooth.Service) error {
+	// The workspace directory is used as a string, since the OS needs absolute paths.
+	workspaceDir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get workspace directory\n\t%w", err)
+	}
+
+	config := unitConfig{
+		name:        service.Name,
+		description: service.Description,
+		execPath:    filepath.Join(workspaceDir, service.Command.LocalString()),
+		args:        service.Args,
+		workDir:     workspaceDir,
+	}
+
+	if config.description == "" {
+		config.description = fmt.Sprintf("%v installed by lip", toothRepoPath)
+	}
+
+	if err := registerUnit(config); err != nil {
+		return fmt.Errorf("failed to register service %v\n\t%w", service.Name, err)
+	}
+
+	if err := save(ctx, Registration{ToothRepoPath: toothRepoPath, Name: service.Name}); err != nil {
+		return fmt.Errorf("failed to save registration of service %v\n\t%w", service.Name, err)
+	}
+
+	return nil
+}
+
+// Get returns the registration of the service of a tooth. The second return value is false if
+// no service is registered for the tooth.
+func Get(ctx *context.Context, toothRepoPath string) (Registration, bool, error) {
+	registrationPath, err := getRegistrationPath(ctx, toothRepoPath)
+	if err != nil {
+		return Registration{}, false, err
+	}
+
+	jsonBytes, err := os.ReadFile(registrationPath.LocalString())
+	if os.IsNotExist(err) {
+		return Registration{}, false, nil
+	} else if err != nil {
+		return Registration{}, false, fmt.Errorf("failed to read registration file %v\n\t%w",
+			registrationPath.LocalString(), err)
+	}
+
+	var registration Registration
+	if err := json.Unmarshal(jsonBytes, &registration); err != nil {
+		return Registration{}, false, fmt.Errorf("failed to unmarshal registration file %v\n\t%w",
+			registrationPath.LocalString(), err)
+	}
+
+	return registration, true, nil
+}
+
+// Unregister stops and removes the service registered for a tooth, if any, and deletes the
+// registration. The first return value is the name of the removed service, or empty if no
+// service is registered for the tooth.
+func Unregister(ctx *context.Context, toothRepoPath string) (string, error) {
+	registration, ok, err := Get(ctx, toothRepoPath)
+	if err != nil {
+		return "", err
+	}
+
+	if !ok {
+		return "", nil
+	}
+
+	if err := unregisterUnit(registration.Name); err != nil {
+		return "", fmt.Errorf("failed to unregister service %v\n\t%w", registration.Name, err)
+	}
+
+	registrationPath, err := getRegistrationPath(ctx, toothRepoPath)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.Remove(registrationPath.LocalString()); err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to delete registration file %v\n\t%w", registrationPath.LocalString(), err)
+	}
+
+	return registration.Name, nil
+}
+
+// ---------------------------------------------------------------------
+
+func save(ctx *context.Context, registration Registration) error {
+	registrationPath, err := getRegistrationPath(ctx, registration.ToothRepoPath)
+	if err != nil {
+		return err
+	}
+
+	jsonBytes, err := json.MarshalIndent(registration, "", "    ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal registration\n\t%w", err)
+	}
+
+	if err := os.WriteFile(registrationPath.LocalString(), jsonBytes, 0644); err != nil {
+		return fmt.Errorf("failed to write registration file %v\n\t%w", registrationPath.LocalString(), err)
+	}
+
+	return nil
+}
+
+func getRegistrationPath(ctx *context.Context, toothRepoPath string) (path.Path, error) {
+	serviceDir, err := ctx.ServiceDir()
+	if err != nil {
+		return path.Path{}, fmt.Errorf("failed to get service directory\n\t%w", err)
+	}
+
+	registrationFileName := fmt.Sprintf("%v.json", url.QueryEscape(toothRepoPath))
+
+	return serviceDir.Join(path.MustParse(registrationFileName)), nil
+}
+
+// runServiceCommand runs a command managing OS services and reports its output on failure.
+func runServiceCommand(name string, args ...string) error {
+	output, err := exec.Command(name, args...).CombinedOutput()
+	if errors.Is(err, exec.ErrNotFound) {
+		return fmt.Errorf("%v is not available\n\t%w", name, err)
+	} else if err != nil {
+		if message := strings.TrimSpace(string(output)); message != "" {
+			return fmt.Errorf("failed to run %v %v: %v\n\t%w", name, strings.Join(args, " "), message, err)
+		}
+
+		return fmt.Errorf("failed to run %v %v\n\t%w", name, strings.Join(args, " "), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// systemdUnitDir is where the systemd units of services registered by lip are written.
+const systemdUnitDir = "/etc/systemd/system"
+
+// registerUnit writes a systemd unit for the service and enables it to start on boot.
+func registerUnit(config unitConfig) error {
+	execStart := make([]string, 0, len(config.args)+1)
+	for _, arg := range append([]string{config.execPath}, config.args...) {
+		execStart = append(execStart, quoteSystemdArg(arg))
+	}
+
+	unit := fmt.Sprintf(`[Unit]
+Description=%v
+After=network.target
+
+[Service]
+Type=simple
+WorkingDirectory=%v
+ExecStart=%v
+Restart=on-failure
+
+[Install]
+WantedBy=multi-user.target
+`, escapeSystemdSpecifiers(config.description), quoteSystemdArg(config.workDir), strings.Join(execStart, " "))
+
+	unitPath := filepath.Join(systemdUnitDir, config.name+".service")
+	if err := os.WriteFile(unitPath, []byte(unit), 0644); err != nil {
+		return fmt.Errorf("failed to write systemd unit %v\n\t%w", unitPath, err)
+	}
+
+	if err := runServiceCommand("systemctl", "daemon-reload"); err != nil {
+		return err
+	}
+
+	return runServiceCommand("systemctl", "enable", config.name+".service")
+}
+
+// unregisterUnit stops and disables the service and removes its systemd unit.
+func unregisterUnit(name string) error {
+	if err := runServiceCommand("systemctl", "disable", "--now", name+".service"); err != nil {
+		return err
+	}
+
+	unitPath := filepath.Join(systemdUnitDir, name+".service")
+	if err := os.Remove(unitPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete systemd unit %v\n\t%w", unitPath, err)
+	}
+
+	return runServiceCommand("systemctl", "daemon-reload")
+}
+
+// quoteSystemdArg quotes an argument of a systemd command line if necessary.
+func quoteSystemdArg(arg string) string {
+	arg = escapeSystemdSpecifiers(arg)
+
+	if arg != "" && !strings.ContainsAny(arg, " \t\"'\\;") {
+		return arg
+	}
+
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg) + `"`
+}
+
+// escapeSystemdSpecifiers escapes the characters systemd expands in unit files.
+func escapeSystemdSpecifiers(s string) string {
+	return strings.NewReplacer("%", "%%", "$", "$$", "\n", " ").Replace(s)
+}
@@ -0,0 +1,22 @@
+//go:build !linux && !windows
+
+package service
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// registerUnit reports that OS services are not supported on this operating system.
+func registerUnit(config unitConfig) error {
+	return errUnsupportedService()
+}
+
+// unregisterUnit reports that OS services are not supported on this operating system.
+func unregisterUnit(name string) error {
+	return errUnsupportedService()
+}
+
+func errUnsupportedService() error {
+	return fmt.Errorf("OS services are not supported on %v", runtime.GOOS)
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"strings"
+	"syscall"
+)
+
+// registerUnit creates a Windows service starting automatically with sc.exe, replacing the
+// one of the same name. The program must be able to run as a Windows service.
+func registerUnit(config unitConfig) error {
+	// A service of the same name is replaced, and there may be none.
+	_ = unregisterUnit(config.name)
+
+	binPath := make([]string, 0, len(config.args)+1)
+	for _, arg := range append([]string{config.execPath}, config.args...) {
+		binPath = append(binPath, syscall.EscapeArg(arg))
+	}
+
+	if err := runServiceCommand("sc.exe", "create", config.name, "binPath=", strings.Join(binPath, " "),
+		"start=", "auto", "DisplayName=", config.name); err != nil {
+		return err
+	}
+
+	return runServiceCommand("sc.exe", "description", config.name, config.description)
+}
+
+// unregisterUnit stops and deletes the Windows service.
+func unregisterUnit(name string) error {
+	// The service may not be running.
+	_ = runServiceCommand("sc.exe", "stop", name)
+
+	return runServiceCommand("sc.exe", "delete", name)
+}
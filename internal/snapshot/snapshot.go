@@ -0,0 +1,279 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+
+	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/path"
+	"github.com/lippkg/lip/internal/tooth"
+	"github.com/lippkg/lip/internal/vfs"
+	log "github.com/sirupsen/logrus"
+)
+
+const indexFileName = "index.json"
+const archiveFileName = "archive.zip"
+const assetArchiveFileName = "asset.zip"
+
+// Save keeps a copy of the tooth archive and the asset archive of an installed tooth, so that
+// the version can be reinstated later. The oldest snapshots are removed when the number of
+// snapshots of the tooth exceeds the configured limit.
+func Save(ctx *context.Context, archive tooth.Archive) error {
+	debugLogger := ctx.Logger().WithFields(log.Fields{
+		"package": "snapshot",
+		"method":  "Save",
+	})
+
+	limit := ctx.Config().SnapshotCount
+	if limit <= 0 {
+		return nil
+	}
+
+	toothRepoPath := archive.Metadata().ToothRepoPath()
+	version := archive.Metadata().Version()
+
+	versionDir, err := getVersionDir(ctx, toothRepoPath, version)
+	if err != nil {
+		return fmt.Errorf("failed to get snapshot directory\n\t%w", err)
+	}
+
+	if err := ctx.FS().RemoveAll(versionDir.LocalString()); err != nil {
+		return fmt.Errorf("failed to remove snapshot directory %v\n\t%w", versionDir.LocalString(), err)
+	}
+
+	if err := ctx.FS().MkdirAll(versionDir.LocalString(), 0755); err != nil {
+		return fmt.Errorf("failed to create snapshot directory %v\n\t%w", versionDir.LocalString(), err)
+	}
+
+	if err := copyFile(ctx.FS(), archive.FilePath(), versionDir.Join(path.MustParse(archiveFileName))); err != nil {
+		return fmt.Errorf("failed to copy tooth archive\n\t%w", err)
+	}
+
+	assetFilePath, err := archive.AssetFilePath()
+	if err != nil {
+		return fmt.Errorf("failed to get asset file path\n\t%w", err)
+	}
+
+	if !assetFilePath.Equal(archive.FilePath()) {
+		if err := copyFile(ctx.FS(), assetFilePath, versionDir.Join(path.MustParse(assetArchiveFileName))); err != nil {
+			return fmt.Errorf("failed to copy asset archive\n\t%w", err)
+		}
+	}
+
+	// Move the version to the end of the history and drop the oldest snapshots.
+
+	versions, err := List(ctx, toothRepoPath)
+	if err != nil {
+		return fmt.Errorf("failed to list snapshots\n\t%w", err)
+	}
+
+	newVersions := make(semver.Versions, 0)
+	for _, v := range versions {
+		if v.NE(version) {
+			newVersions = append(newVersions, v)
+		}
+	}
+	newVersions = append(newVersions, version)
+
+	for len(newVersions) > limit {
+		if err := removeVersionDir(ctx, toothRepoPath, newVersions[0]); err != nil {
+			return err
+		}
+
+		debugLogger.Debugf("Removed snapshot %v@%v", toothRepoPath, newVersions[0])
+
+		newVersions = newVersions[1:]
+	}
+
+	if err := saveIndex(ctx, toothRepoPath, newVersions); err != nil {
+		return fmt.Errorf("failed to save snapshot index\n\t%w", err)
+	}
+
+	debugLogger.Debugf("Saved snapshot %v@%v", toothRepoPath, version)
+
+	return nil
+}
+
+// List returns the versions of the snapshots of a tooth, from the earliest installed to the
+// latest installed.
+func List(ctx *context.Context, toothRepoPath string) (semver.Versions, error) {
+	toothDir, err := getToothDir(ctx, toothRepoPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get snapshot directory\n\t%w", err)
+	}
+
+	indexPath := toothDir.Join(path.MustParse(indexFileName))
+
+	jsonBytes, err := ctx.FS().ReadFile(indexPath.LocalString())
+	if os.IsNotExist(err) {
+		return semver.Versions{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot index %v\n\t%w", indexPath.LocalString(), err)
+	}
+
+	var versionStrings []string
+	if err := json.Unmarshal(jsonBytes, &versionStrings); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal snapshot index %v\n\t%w", indexPath.LocalString(), err)
+	}
+
+	versions := make(semver.Versions, 0)
+	for _, versionString := range versionStrings {
+		version, err := semver.Parse(versionString)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse version %v in snapshot index\n\t%w", versionString, err)
+		}
+
+		versions = append(versions, version)
+	}
+
+	return versions, nil
+}
+
+// Get returns the archive of a snapshot with the asset archive attached.
+func Get(ctx *context.Context, toothRepoPath string, version semver.Version) (tooth.Archive, error) {
+	versionDir, err := getVersionDir(ctx, toothRepoPath, version)
+	if err != nil {
+		return tooth.Archive{}, fmt.Errorf("failed to get snapshot directory\n\t%w", err)
+	}
+
+	archive, err := tooth.MakeArchiveOfTooth(ctx.FS(), versionDir.Join(path.MustParse(archiveFileName)), toothRepoPath)
+	if err != nil {
+		return tooth.Archive{}, fmt.Errorf("failed to open snapshot of %v@%v\n\t%w", toothRepoPath, version, err)
+	}
+
+	assetFilePath := path.MakeEmpty()
+	if _, err := ctx.FS().Stat(versionDir.Join(path.MustParse(assetArchiveFileName)).LocalString()); err == nil {
+		assetFilePath = versionDir.Join(path.MustParse(assetArchiveFileName))
+	} else if !os.IsNotExist(err) {
+		return tooth.Archive{}, fmt.Errorf("failed to check asset archive of snapshot\n\t%w", err)
+	}
+
+	archive, err = archive.ToAssetArchiveAttached(ctx.Logger(), assetFilePath)
+	if err != nil {
+		return tooth.Archive{}, fmt.Errorf("failed to attach asset archive of snapshot\n\t%w", err)
+	}
+
+	return archive, nil
+}
+
+// Remove removes the snapshot of a version from the history.
+func Remove(ctx *context.Context, toothRepoPath string, version semver.Version) error {
+	versions, err := List(ctx, toothRepoPath)
+	if err != nil {
+		return fmt.Errorf("failed to list snapshots\n\t%w", err)
+	}
+
+	newVersions := make(semver.Versions, 0)
+	for _, v := range versions {
+		if v.NE(version) {
+			newVersions = append(newVersions, v)
+		}
+	}
+
+	if err := removeVersionDir(ctx, toothRepoPath, version); err != nil {
+		return err
+	}
+
+	if err := saveIndex(ctx, toothRepoPath, newVersions); err != nil {
+		return fmt.Errorf("failed to save snapshot index\n\t%w", err)
+	}
+
+	return nil
+}
+
+// RemoveAll removes all snapshots of a tooth.
+func RemoveAll(ctx *context.Context, toothRepoPath string) error {
+	toothDir, err := getToothDir(ctx, toothRepoPath)
+	if err != nil {
+		return fmt.Errorf("failed to get snapshot directory\n\t%w", err)
+	}
+
+	if err := ctx.FS().RemoveAll(toothDir.LocalString()); err != nil {
+		return fmt.Errorf("failed to remove snapshot directory %v\n\t%w", toothDir.LocalString(), err)
+	}
+
+	return nil
+}
+
+// ---------------------------------------------------------------------
+
+// copyFile copies a file from sourcePath to destinationPath on a file system.
+func copyFile(fs vfs.FS, sourcePath, destinationPath path.Path) error {
+	source, err := fs.Open(sourcePath.LocalString())
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	destination, err := fs.Create(destinationPath.LocalString())
+	if err != nil {
+		return err
+	}
+	defer destination.Close()
+
+	if _, err := io.Copy(destination, source); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func getToothDir(ctx *context.Context, toothRepoPath string) (path.Path, error) {
+	snapshotDir, err := ctx.SnapshotDir()
+	if err != nil {
+		return path.Path{}, fmt.Errorf("failed to get snapshot directory\n\t%w", err)
+	}
+
+	return snapshotDir.Join(path.MustParse(url.QueryEscape(toothRepoPath))), nil
+}
+
+func getVersionDir(ctx *context.Context, toothRepoPath string, version semver.Version) (path.Path, error) {
+	toothDir, err := getToothDir(ctx, toothRepoPath)
+	if err != nil {
+		return path.Path{}, err
+	}
+
+	return toothDir.Join(path.MustParse(url.QueryEscape(version.String()))), nil
+}
+
+func removeVersionDir(ctx *context.Context, toothRepoPath string, version semver.Version) error {
+	versionDir, err := getVersionDir(ctx, toothRepoPath, version)
+	if err != nil {
+		return fmt.Errorf("failed to get snapshot directory\n\t%w", err)
+	}
+
+	if err := ctx.FS().RemoveAll(versionDir.LocalString()); err != nil {
+		return fmt.Errorf("failed to remove snapshot directory %v\n\t%w", versionDir.LocalString(), err)
+	}
+
+	return nil
+}
+
+func saveIndex(ctx *context.Context, toothRepoPath string, versions semver.Versions) error {
+	toothDir, err := getToothDir(ctx, toothRepoPath)
+	if err != nil {
+		return fmt.Errorf("failed to get snapshot directory\n\t%w", err)
+	}
+
+	versionStrings := make([]string, 0)
+	for _, version := range versions {
+		versionStrings = append(versionStrings, version.String())
+	}
+
+	jsonBytes, err := json.MarshalIndent(versionStrings, "", "    ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal snapshot index\n\t%w", err)
+	}
+
+	indexPath := toothDir.Join(path.MustParse(indexFileName))
+
+	if err := ctx.FS().WriteFile(indexPath.LocalString(), jsonBytes, 0644); err != nil {
+		return fmt.Errorf("failed to write snapshot index %v\n\t%w", indexPath.LocalString(), err)
+	}
+
+	return nil
+}
@@ -2,11 +2,15 @@ package specifier
 
 import (
 	"fmt"
+	"os"
+	"regexp"
 	"strings"
 
 	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/network"
 	"github.com/lippkg/lip/internal/path"
 	"github.com/lippkg/lip/internal/tooth"
+	"github.com/lippkg/lip/internal/versionmatch"
 )
 
 // KindType is an enum that represents the type of a specifier.
@@ -15,16 +19,22 @@ type KindType int
 const (
 	ToothArchiveKind KindType = iota
 	ToothRepoKind
+	// ToothAliasKind is a short name of a tooth, which the registry maps to tooth repo paths.
+	ToothAliasKind
 )
 
+// toothAliasRegexp matches tooth aliases, e.g. levilamina.
+var toothAliasRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
 // Specifier is a type that can be used to specify a tooth url/file or a requirement.
 type Specifier struct {
 	kind             KindType
 	toothArchivePath path.Path
 	toothRepoPath    string
+	toothAlias       string
 
-	isToothVersionSpecified bool
-	toothVersion            semver.Version
+	isVersionMatchSpecified bool
+	versionMatch            versionmatch.VersionMatch
 }
 
 // Parse creates a new specifier from the given string.
@@ -49,7 +59,7 @@ func Parse(specifierString string) (Specifier, error) {
 		// Parse the tooth repo and version.
 		splittedSpecifier := strings.Split(specifierString, "@")
 
-		toothRepoPath := splittedSpecifier[0]
+		toothRepoPath := network.NormalizeToothRepoPath(splittedSpecifier[0])
 
 		if !tooth.IsValidToothRepoPath(toothRepoPath) {
 			return Specifier{}, fmt.Errorf("invalid requirement specifier %v: invalid tooth repo path",
@@ -57,7 +67,7 @@ func Parse(specifierString string) (Specifier, error) {
 		}
 
 		if len(splittedSpecifier) == 2 {
-			toothVersion, err := semver.Parse(splittedSpecifier[1])
+			versionMatch, err := versionmatch.Parse(splittedSpecifier[1])
 			if err != nil {
 				return Specifier{}, fmt.Errorf("invalid requirement specifier %v\n\t%w",
 					specifierString, err)
@@ -66,20 +76,45 @@ func Parse(specifierString string) (Specifier, error) {
 			return Specifier{
 				kind:                    specifierType,
 				toothRepoPath:           toothRepoPath,
-				isToothVersionSpecified: true,
-				toothVersion:            toothVersion,
+				isVersionMatchSpecified: true,
+				versionMatch:            versionMatch,
 			}, nil
 
 		} else if len(splittedSpecifier) == 1 {
 			return Specifier{
 				kind:                    specifierType,
 				toothRepoPath:           toothRepoPath,
-				isToothVersionSpecified: false,
+				isVersionMatchSpecified: false,
+				versionMatch:            versionmatch.Latest(),
 			}, nil
 		} else {
 			return Specifier{}, fmt.Errorf("invalid requirement specifier: %v: too many \"@\"s",
 				specifierString)
 		}
+
+	case ToothAliasKind:
+		splittedSpecifier := strings.Split(specifierString, "@")
+
+		if len(splittedSpecifier) == 2 {
+			versionMatch, err := versionmatch.Parse(splittedSpecifier[1])
+			if err != nil {
+				return Specifier{}, fmt.Errorf("invalid requirement specifier %v\n\t%w",
+					specifierString, err)
+			}
+
+			return Specifier{
+				kind:                    specifierType,
+				toothAlias:              splittedSpecifier[0],
+				isVersionMatchSpecified: true,
+				versionMatch:            versionMatch,
+			}, nil
+		}
+
+		return Specifier{
+			kind:         specifierType,
+			toothAlias:   splittedSpecifier[0],
+			versionMatch: versionmatch.Latest(),
+		}, nil
 	}
 
 	// Never reached.
@@ -109,26 +144,60 @@ func (s Specifier) ToothRepoPath() (string, error) {
 	return s.toothRepoPath, nil
 }
 
-// IsToothVersionSpecified returns whether the specifier has a tooth version.
+// ToothAlias returns the tooth alias of the specifier.
+func (s Specifier) ToothAlias() (string, error) {
+	if s.Kind() != ToothAliasKind {
+		return "", fmt.Errorf("specifier is not a tooth alias")
+	}
+
+	return s.toothAlias, nil
+}
+
+// ToToothRepoResolved returns a tooth repo specifier of the tooth an alias specifier is
+// resolved to, with the same version.
+func (s Specifier) ToToothRepoResolved(toothRepoPath string) (Specifier, error) {
+	if s.Kind() != ToothAliasKind {
+		return Specifier{}, fmt.Errorf("specifier is not a tooth alias")
+	}
+
+	return Specifier{
+		kind:                    ToothRepoKind,
+		toothRepoPath:           toothRepoPath,
+		isVersionMatchSpecified: s.isVersionMatchSpecified,
+		versionMatch:            s.versionMatch,
+	}, nil
+}
+
+// IsToothVersionSpecified returns whether the specifier has an exact tooth version.
 func (s Specifier) IsToothVersionSpecified() (bool, error) {
 	if s.Kind() != ToothRepoKind {
 		return false, fmt.Errorf("specifier is not a tooth repo")
 	}
 
-	return s.isToothVersionSpecified, nil
+	return s.versionMatch.Kind() == versionmatch.ExactKind, nil
 }
 
-// ToothVersion returns the version of the tooth.
+// ToothVersion returns the exact version of the tooth.
 func (s Specifier) ToothVersion() (semver.Version, error) {
 	if s.Kind() != ToothRepoKind {
 		return semver.Version{}, fmt.Errorf("specifier is not a tooth repo")
 	}
 
-	if !s.isToothVersionSpecified {
+	if s.versionMatch.Kind() != versionmatch.ExactKind {
 		return semver.Version{}, fmt.Errorf("tooth version is not specified")
 	}
 
-	return s.toothVersion, nil
+	return s.versionMatch.Version()
+}
+
+// VersionMatch returns how the version of the tooth is selected. It selects the latest version
+// if none is specified.
+func (s Specifier) VersionMatch() (versionmatch.VersionMatch, error) {
+	if s.Kind() != ToothRepoKind {
+		return versionmatch.VersionMatch{}, fmt.Errorf("specifier is not a tooth repo")
+	}
+
+	return s.versionMatch, nil
 }
 
 // String returns the string representation of the specifier.
@@ -138,11 +207,18 @@ func (s Specifier) String() string {
 		return s.toothArchivePath.LocalString()
 
 	case ToothRepoKind:
-		if s.isToothVersionSpecified {
-			return s.toothRepoPath + "@" + s.toothVersion.String()
+		if s.isVersionMatchSpecified {
+			return s.toothRepoPath + "@" + s.versionMatch.String()
 		} else {
 			return s.toothRepoPath
 		}
+
+	case ToothAliasKind:
+		if s.isVersionMatchSpecified {
+			return s.toothAlias + "@" + s.versionMatch.String()
+		} else {
+			return s.toothAlias
+		}
 	}
 
 	// Never reached.
@@ -153,12 +229,19 @@ func getSpecifierType(specifier string) KindType {
 	// Prefer tooth repo specifier over tooth archive specifier.
 	// This means that if a specifier is both a tooth repo specifier and a tooth archive
 	// specifier, it will be treated as a tooth repo specifier.
+	// The host of a tooth repo path is case-insensitive.
 	splittedSpecifier := strings.Split(specifier, "@")
-	if len(splittedSpecifier) == 1 && tooth.IsValidToothRepoPath(specifier) {
-		return ToothRepoKind
-	} else if len(splittedSpecifier) == 2 && tooth.IsValidToothRepoPath(splittedSpecifier[0]) {
+	toothRepoPath := network.NormalizeToothRepoPath(splittedSpecifier[0])
+	if len(splittedSpecifier) <= 2 && tooth.IsValidToothRepoPath(toothRepoPath) {
 		return ToothRepoKind
 	}
 
+	// A short name is an alias unless there is a tooth file with that name.
+	if len(splittedSpecifier) <= 2 && toothAliasRegexp.MatchString(splittedSpecifier[0]) {
+		if _, err := os.Stat(specifier); err != nil {
+			return ToothAliasKind
+		}
+	}
+
 	return ToothArchiveKind
 }
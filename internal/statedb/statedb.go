@@ -0,0 +1,751 @@
+package statedb
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/path"
+	log "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+// SchemaVersion is the version of the format of the database file.
+const SchemaVersion = 1
+
+// Tooth is the installed state of a tooth: its metadata, record and manifest, kept as JSON by
+// the packages owning them. Any of them may be missing, e.g. the manifest of a tooth installed
+// by an older version of lip, or the record of a tooth being reinstated by a rollback.
+type Tooth struct {
+	ToothRepoPath string `json:"tooth"`
+
+	// Version is the installed version, or empty if the tooth has no metadata.
+	Version  string          `json:"version,omitempty"`
+	Metadata json.RawMessage `json:"metadata,omitempty"`
+	Record   json.RawMessage `json:"record,omitempty"`
+	Manifest json.RawMessage `json:"manifest,omitempty"`
+
+	// Files are the paths of the files owned by the tooth, relative to the workspace directory,
+	// as listed by its manifest.
+	Files []string `json:"files,omitempty"`
+}
+
+// DB is a snapshot of the installed-state database of a workspace. The database is a bbolt
+// file, with a bucket of the states of teeth keyed by tooth repository path and index buckets of
+// installed versions and owned files, kept up to date in the same transaction as the states.
+// bbolt locks the file while it is open, so lip processes running in the same workspace at the
+// same time wait for each other instead of overwriting each other's changes. The file is on the
+// file system of the operating system, as bbolt maps it into memory, even if the context has
+// another file system.
+type DB struct {
+	teeth []Tooth
+
+	byToothRepoPath map[string]int
+	byVersion       map[string][]string
+	byFile          map[string]string
+}
+
+// Open loads a snapshot of the installed-state database of the workspace. If there is none
+// yet, the metadata, records and manifests kept by older versions of lip in separate files are
+// migrated to it, and backed up in the backup directory.
+func Open(ctx *context.Context) (*DB, error) {
+	dbFilePath, err := ctx.StateDBFilePath()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get state database file path\n\t%w", err)
+	}
+
+	if _, err := os.Stat(dbFilePath.LocalString()); err == nil {
+		boltDB, err := openBoltDB(dbFilePath, true)
+		if err != nil {
+			return nil, err
+		}
+		defer boltDB.Close()
+
+		var db *DB
+		err = boltDB.View(func(tx *bolt.Tx) error {
+			if !isInitialized(tx) {
+				return nil
+			}
+
+			db, err = read(tx)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read state database file %v\n\t%w", dbFilePath.LocalString(), err)
+		} else if db != nil {
+			return db, nil
+		}
+
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to stat state database file %v\n\t%w", dbFilePath.LocalString(), err)
+	}
+
+	// Do not create the database file only to find out that nothing is installed.
+	legacyFiles, err := listAllLegacyFiles(ctx)
+	if err != nil {
+		return nil, err
+	} else if len(legacyFiles) == 0 {
+		return newDB(), nil
+	}
+
+	boltDB, err := openWritable(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer boltDB.Close()
+
+	var db *DB
+	err = boltDB.View(func(tx *bolt.Tx) error {
+		db, err = read(tx)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state database file %v\n\t%w", dbFilePath.LocalString(), err)
+	}
+
+	return db, nil
+}
+
+// Migrate migrates the metadata, records and manifests kept by older versions of lip in
+// separate files to the database, like Open does, and moves the files to backupDir. It does
+// nothing if the database exists. It returns the number of files migrated.
+func Migrate(ctx *context.Context, backupDir path.Path) (int, error) {
+	dbFilePath, err := ctx.StateDBFilePath()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get state database file path\n\t%w", err)
+	}
+
+	legacyFiles, err := listAllLegacyFiles(ctx)
+	if err != nil {
+		return 0, err
+	} else if len(legacyFiles) == 0 {
+		return 0, nil
+	}
+
+	boltDB, err := openBoltDB(dbFilePath, false)
+	if err != nil {
+		return 0, err
+	}
+	defer boltDB.Close()
+
+	isMigrated := false
+	if err := boltDB.View(func(tx *bolt.Tx) error {
+		isMigrated = isInitialized(tx)
+		return nil
+	}); err != nil {
+		return 0, fmt.Errorf("failed to read state database file %v\n\t%w", dbFilePath.LocalString(), err)
+	} else if isMigrated {
+		return 0, nil
+	}
+
+	count, err := migrate(ctx, boltDB, legacyFiles, backupDir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to migrate installed state\n\t%w", err)
+	}
+
+	return count, nil
+}
+
+// Get returns the state of a tooth. The second return value is false if nothing is kept about
+// the tooth.
+func (db *DB) Get(toothRepoPath string) (Tooth, bool) {
+	i, ok := db.byToothRepoPath[toothRepoPath]
+	if !ok {
+		return Tooth{ToothRepoPath: toothRepoPath}, false
+	}
+
+	return db.teeth[i], true
+}
+
+// List returns the states of all teeth, sorted by tooth repository path.
+func (db *DB) List() []Tooth {
+	teeth := make([]Tooth, len(db.teeth))
+	copy(teeth, db.teeth)
+
+	return teeth
+}
+
+// FindByVersion returns the repository paths of the teeth installed at a version, sorted.
+func (db *DB) FindByVersion(version string) []string {
+	toothRepoPaths := make([]string, len(db.byVersion[version]))
+	copy(toothRepoPaths, db.byVersion[version])
+
+	return toothRepoPaths
+}
+
+// FindOwner returns the repository path of the tooth owning a file, given relative to the
+// workspace directory. The second return value is false if no tooth owns the file.
+func (db *DB) FindOwner(filePath string) (string, bool) {
+	toothRepoPath, ok := db.byFile[cleanFilePath(filePath)]
+	return toothRepoPath, ok
+}
+
+// FindOwnersInDir returns the files under a directory, given relative to the workspace
+// directory, mapped to the repository paths of the teeth owning them. The workspace directory
+// itself is ".".
+func (db *DB) FindOwnersInDir(dirPath string) map[string]string {
+	prefix := cleanFilePath(dirPath) + "/"
+	if prefix == "./" {
+		prefix = ""
+	}
+
+	owners := make(map[string]string)
+	for filePath, toothRepoPath := range db.byFile {
+		if strings.HasPrefix(filePath, prefix) {
+			owners[filePath] = toothRepoPath
+		}
+	}
+
+	return owners
+}
+
+// Export returns the database as indented JSON.
+func (db *DB) Export() ([]byte, error) {
+	jsonBytes, err := json.MarshalIndent(exportedDB{
+		SchemaVersion: SchemaVersion,
+		Teeth:         db.teeth,
+	}, "", "    ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal state database\n\t%w", err)
+	}
+
+	return jsonBytes, nil
+}
+
+// Update changes the state of a tooth in the database. The change is made in a single
+// transaction, while the database is locked against other lip processes, so that changes made
+// by them meanwhile are not lost.
+func Update(ctx *context.Context, toothRepoPath string, change func(tooth *Tooth)) error {
+	boltDB, err := openWritable(ctx)
+	if err != nil {
+		return err
+	}
+	defer boltDB.Close()
+
+	err = boltDB.Update(func(tx *bolt.Tx) error {
+		tooth, _, err := get(tx, toothRepoPath)
+		if err != nil {
+			return err
+		}
+
+		change(&tooth)
+
+		return put(tx, tooth)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update state of %v\n\t%w", toothRepoPath, err)
+	}
+
+	return nil
+}
+
+// Delete removes everything kept about a tooth from the database.
+func Delete(ctx *context.Context, toothRepoPath string) error {
+	return Update(ctx, toothRepoPath, func(tooth *Tooth) {
+		*tooth = Tooth{ToothRepoPath: toothRepoPath}
+	})
+}
+
+// ---------------------------------------------------------------------
+
+// lockTimeout is how long to wait for other lip processes to release the database.
+const lockTimeout = time.Minute
+
+// The buckets of the database. The states of teeth are kept as JSON in teethBucket, keyed by
+// tooth repository path. versionBucket indexes them by version, with keys of the version and the
+// tooth repository path separated by versionKeySeparator, and fileBucket maps owned files to
+// the tooth repository paths of their owners.
+var (
+	metaBucket    = []byte("meta")
+	teethBucket   = []byte("teeth")
+	versionBucket = []byte("versions")
+	fileBucket    = []byte("files")
+
+	schemaVersionKey = []byte("schema_version")
+)
+
+const versionKeySeparator = "\x00"
+
+// exportedDB is the database as exported.
+type exportedDB struct {
+	SchemaVersion int     `json:"schema_version"`
+	Teeth         []Tooth `json:"teeth"`
+}
+
+// newDB makes an empty database.
+func newDB() *DB {
+	return &DB{
+		teeth:           make([]Tooth, 0),
+		byToothRepoPath: make(map[string]int),
+		byVersion:       make(map[string][]string),
+		byFile:          make(map[string]string),
+	}
+}
+
+// cleanFilePath normalizes a file path relative to the workspace directory, so that paths
+// given in either form of separators are found.
+func cleanFilePath(filePath string) string {
+	return strings.TrimPrefix(filepath.ToSlash(filepath.Clean(filePath)), "./")
+}
+
+// openBoltDB opens the database file, waiting for other lip processes to release it. A read-only
+// database may be open in several processes at the same time.
+func openBoltDB(dbFilePath path.Path, readOnly bool) (*bolt.DB, error) {
+	boltDB, err := bolt.Open(dbFilePath.LocalString(), 0644, &bolt.Options{
+		Timeout:  lockTimeout,
+		ReadOnly: readOnly,
+	})
+	if errors.Is(err, bolt.ErrTimeout) {
+		return nil, fmt.Errorf("state database file %v is locked by another lip process", dbFilePath.LocalString())
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to open state database file %v\n\t%w", dbFilePath.LocalString(), err)
+	}
+
+	return boltDB, nil
+}
+
+// openWritable opens the database file to change it, creating it if there is none. A new
+// database is initialized, and the files kept by older versions of lip are migrated to it.
+func openWritable(ctx *context.Context) (*bolt.DB, error) {
+	dbFilePath, err := ctx.StateDBFilePath()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get state database file path\n\t%w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dbFilePath.LocalString()), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create state database directory\n\t%w", err)
+	}
+
+	boltDB, err := openBoltDB(dbFilePath, false)
+	if err != nil {
+		return nil, err
+	}
+
+	isMigrated := false
+	if err := boltDB.View(func(tx *bolt.Tx) error {
+		isMigrated = isInitialized(tx)
+		return nil
+	}); err != nil {
+		boltDB.Close()
+		return nil, fmt.Errorf("failed to read state database file %v\n\t%w", dbFilePath.LocalString(), err)
+	} else if isMigrated {
+		return boltDB, nil
+	}
+
+	legacyFiles, err := listAllLegacyFiles(ctx)
+	if err != nil {
+		boltDB.Close()
+		return nil, err
+	}
+
+	backupDir, err := ctx.BackupDir()
+	if err != nil {
+		boltDB.Close()
+		return nil, fmt.Errorf("failed to get backup directory\n\t%w", err)
+	}
+
+	_, err = migrate(ctx, boltDB, legacyFiles,
+		backupDir.Join(path.MustParse("state-"+ctx.Clock().Now().Format("20060102-150405"))))
+	if err != nil {
+		boltDB.Close()
+		return nil, fmt.Errorf("failed to migrate installed state\n\t%w", err)
+	}
+
+	return boltDB, nil
+}
+
+// isInitialized returns whether the database has its buckets, i.e. it is not a new file.
+func isInitialized(tx *bolt.Tx) bool {
+	return tx.Bucket(metaBucket) != nil
+}
+
+// initialize creates the buckets of a new database.
+func initialize(tx *bolt.Tx) error {
+	meta, err := tx.CreateBucketIfNotExists(metaBucket)
+	if err != nil {
+		return err
+	}
+
+	if err := meta.Put(schemaVersionKey, []byte(strconv.Itoa(SchemaVersion))); err != nil {
+		return err
+	}
+
+	for _, name := range [][]byte{teethBucket, versionBucket, fileBucket} {
+		if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkSchemaVersion checks that the database is of the format of this version of lip.
+func checkSchemaVersion(tx *bolt.Tx) error {
+	schemaVersion := string(tx.Bucket(metaBucket).Get(schemaVersionKey))
+	if schemaVersion != strconv.Itoa(SchemaVersion) {
+		return fmt.Errorf("unsupported schema version %v of state database", schemaVersion)
+	}
+
+	return nil
+}
+
+// read reads the states of all teeth and the indexes of the database.
+func read(tx *bolt.Tx) (*DB, error) {
+	if err := checkSchemaVersion(tx); err != nil {
+		return nil, err
+	}
+
+	db := newDB()
+
+	// Keys are sorted, so are the teeth and the teeth of each version.
+	err := tx.Bucket(teethBucket).ForEach(func(key []byte, value []byte) error {
+		var tooth Tooth
+		if err := json.Unmarshal(value, &tooth); err != nil {
+			return fmt.Errorf("failed to parse state of %v\n\t%w", string(key), err)
+		}
+
+		db.byToothRepoPath[tooth.ToothRepoPath] = len(db.teeth)
+		db.teeth = append(db.teeth, tooth)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Bucket(versionBucket).ForEach(func(key []byte, value []byte) error {
+		version, toothRepoPath, ok := strings.Cut(string(key), versionKeySeparator)
+		if ok {
+			db.byVersion[version] = append(db.byVersion[version], toothRepoPath)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Bucket(fileBucket).ForEach(func(key []byte, value []byte) error {
+		db.byFile[string(key)] = string(value)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// get reads the state of a tooth. The second return value is false if nothing is kept about
+// the tooth.
+func get(tx *bolt.Tx, toothRepoPath string) (Tooth, bool, error) {
+	if err := checkSchemaVersion(tx); err != nil {
+		return Tooth{}, false, err
+	}
+
+	jsonBytes := tx.Bucket(teethBucket).Get([]byte(toothRepoPath))
+	if jsonBytes == nil {
+		return Tooth{ToothRepoPath: toothRepoPath}, false, nil
+	}
+
+	var tooth Tooth
+	if err := json.Unmarshal(jsonBytes, &tooth); err != nil {
+		return Tooth{}, false, fmt.Errorf("failed to parse state of %v\n\t%w", toothRepoPath, err)
+	}
+
+	return tooth, true, nil
+}
+
+// put sets the state of a tooth and updates the indexes. A tooth without metadata, record and
+// manifest is removed.
+func put(tx *bolt.Tx, tooth Tooth) error {
+	previous, ok, err := get(tx, tooth.ToothRepoPath)
+	if err != nil {
+		return err
+	}
+
+	versions := tx.Bucket(versionBucket)
+	files := tx.Bucket(fileBucket)
+
+	if ok {
+		if err := versions.Delete(versionKey(previous)); err != nil {
+			return err
+		}
+
+		for _, filePath := range previous.Files {
+			key := []byte(cleanFilePath(filePath))
+			if string(files.Get(key)) != previous.ToothRepoPath {
+				continue
+			}
+
+			if err := files.Delete(key); err != nil {
+				return err
+			}
+		}
+	}
+
+	if tooth.Metadata == nil {
+		tooth.Version = ""
+	}
+
+	if tooth.Metadata == nil && tooth.Record == nil && tooth.Manifest == nil {
+		return tx.Bucket(teethBucket).Delete([]byte(tooth.ToothRepoPath))
+	}
+
+	jsonBytes, err := json.Marshal(tooth)
+	if err != nil {
+		return fmt.Errorf("failed to marshal state of %v\n\t%w", tooth.ToothRepoPath, err)
+	}
+
+	if err := tx.Bucket(teethBucket).Put([]byte(tooth.ToothRepoPath), jsonBytes); err != nil {
+		return err
+	}
+
+	if tooth.Version != "" {
+		if err := versions.Put(versionKey(tooth), []byte{}); err != nil {
+			return err
+		}
+	}
+
+	for _, filePath := range tooth.Files {
+		if err := files.Put([]byte(cleanFilePath(filePath)), []byte(tooth.ToothRepoPath)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// versionKey returns the key of a tooth in the version index.
+func versionKey(tooth Tooth) []byte {
+	return []byte(tooth.Version + versionKeySeparator + tooth.ToothRepoPath)
+}
+
+// legacyFile is a file of the metadata, record or manifest of a tooth kept by older versions of
+// lip.
+type legacyFile struct {
+	toothRepoPath string
+	// kind is the name of the directory the file is in, e.g. "metadata".
+	kind      string
+	filePath  string
+	jsonBytes []byte
+}
+
+// migrate initializes a new database and migrates the metadata, records and manifests kept by
+// older versions of lip in separate files to it. Every migrated file is read back from the
+// database and compared with the file before the transaction is committed, and the files are
+// moved to backupDir only once it is. Originals of config files are still kept in the manifest
+// directory. It returns the number of files migrated.
+func migrate(ctx *context.Context, boltDB *bolt.DB, legacyFiles []legacyFile, backupDir path.Path) (int, error) {
+	debugLogger := ctx.Logger().WithFields(log.Fields{
+		"package": "statedb",
+		"method":  "migrate",
+	})
+
+	teeth := make(map[string]*Tooth)
+	for _, file := range legacyFiles {
+		if _, ok := teeth[file.toothRepoPath]; !ok {
+			teeth[file.toothRepoPath] = &Tooth{ToothRepoPath: file.toothRepoPath}
+		}
+
+		if err := setLegacyFile(teeth[file.toothRepoPath], file); err != nil {
+			return 0, fmt.Errorf("failed to migrate %v\n\t%w", file.filePath, err)
+		}
+	}
+
+	err := boltDB.Update(func(tx *bolt.Tx) error {
+		if err := initialize(tx); err != nil {
+			return err
+		}
+
+		for _, tooth := range teeth {
+			if err := put(tx, *tooth); err != nil {
+				return err
+			}
+		}
+
+		// Leave the files as they are if the database does not hold them.
+		if err := verifyMigration(tx, legacyFiles); err != nil {
+			return fmt.Errorf("failed to verify migrated state database\n\t%w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if len(legacyFiles) == 0 {
+		return 0, nil
+	}
+
+	for _, file := range legacyFiles {
+		kindBackupDir := backupDir.Join(path.MustParse(file.kind))
+		if err := ctx.FS().MkdirAll(kindBackupDir.LocalString(), 0755); err != nil {
+			return 0, fmt.Errorf("failed to create backup directory %v\n\t%w", kindBackupDir.LocalString(), err)
+		}
+
+		backupFilePath := filepath.Join(kindBackupDir.LocalString(), filepath.Base(file.filePath))
+		if err := ctx.FS().Rename(file.filePath, backupFilePath); err != nil {
+			debugLogger.Debugf("Failed to back up migrated file %v: %v", file.filePath, err)
+		}
+	}
+
+	ctx.Logger().Infof(i18n.T("Migrated %v files of installed teeth to the state database. Backups are kept in %v."),
+		len(legacyFiles), backupDir.LocalString())
+
+	return len(legacyFiles), nil
+}
+
+// setLegacyFile sets the metadata, record or manifest of a tooth to a legacy file.
+func setLegacyFile(tooth *Tooth, file legacyFile) error {
+	var content struct {
+		ToothRepoPath string `json:"tooth"`
+		Version       string `json:"version"`
+		Files         []struct {
+			Path string `json:"path"`
+		} `json:"files"`
+	}
+	if err := json.Unmarshal(file.jsonBytes, &content); err != nil {
+		return err
+	}
+
+	if content.ToothRepoPath != tooth.ToothRepoPath {
+		return fmt.Errorf("file name does not match tooth %v", content.ToothRepoPath)
+	}
+
+	switch file.kind {
+	case legacyMetadataKind:
+		tooth.Version = content.Version
+		tooth.Metadata = file.jsonBytes
+
+	case legacyRecordKind:
+		tooth.Record = file.jsonBytes
+
+	case legacyManifestKind:
+		tooth.Manifest = file.jsonBytes
+		for _, placedFile := range content.Files {
+			tooth.Files = append(tooth.Files, placedFile.Path)
+		}
+	}
+
+	return nil
+}
+
+// verifyMigration checks that the database holds every legacy file as it is.
+func verifyMigration(tx *bolt.Tx, legacyFiles []legacyFile) error {
+	for _, file := range legacyFiles {
+		tooth, _, err := get(tx, file.toothRepoPath)
+		if err != nil {
+			return err
+		}
+
+		var migrated json.RawMessage
+		switch file.kind {
+		case legacyMetadataKind:
+			migrated = tooth.Metadata
+		case legacyRecordKind:
+			migrated = tooth.Record
+		case legacyManifestKind:
+			migrated = tooth.Manifest
+		}
+
+		var expected, actual bytes.Buffer
+		if err := json.Compact(&expected, file.jsonBytes); err != nil {
+			return fmt.Errorf("failed to parse %v\n\t%w", file.filePath, err)
+		}
+
+		if err := json.Compact(&actual, migrated); err != nil || !bytes.Equal(expected.Bytes(), actual.Bytes()) {
+			return fmt.Errorf("%v of %v does not match %v", file.kind, file.toothRepoPath, file.filePath)
+		}
+	}
+
+	return nil
+}
+
+// The kinds of legacy files are the names of the directories they are in.
+const (
+	legacyMetadataKind = "metadata"
+	legacyRecordKind   = "records"
+	legacyManifestKind = "manifests"
+)
+
+// listAllLegacyFiles lists the metadata, records and manifests kept by older versions of lip in
+// separate files.
+func listAllLegacyFiles(ctx *context.Context) ([]legacyFile, error) {
+	metadataDir, err := ctx.MetadataDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get metadata directory\n\t%w", err)
+	}
+
+	recordDir, err := ctx.RecordDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get record directory\n\t%w", err)
+	}
+
+	manifestDir, err := ctx.ManifestDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get manifest directory\n\t%w", err)
+	}
+
+	legacyFiles := make([]legacyFile, 0)
+	for _, dir := range []path.Path{metadataDir, recordDir, manifestDir} {
+		files, err := listLegacyFiles(ctx, dir)
+		if err != nil {
+			return nil, err
+		}
+
+		legacyFiles = append(legacyFiles, files...)
+	}
+
+	return legacyFiles, nil
+}
+
+// listLegacyFiles lists the JSON files kept by older versions of lip in a directory, named after
+// the escaped repository paths of their teeth.
+func listLegacyFiles(ctx *context.Context, dir path.Path) ([]legacyFile, error) {
+	dirEntries, err := ctx.FS().ReadDir(dir.LocalString())
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read directory %v\n\t%w", dir.LocalString(), err)
+	}
+
+	files := make([]legacyFile, 0)
+
+	for _, dirEntry := range dirEntries {
+		if dirEntry.IsDir() || filepath.Ext(dirEntry.Name()) != ".json" {
+			continue
+		}
+
+		toothRepoPath, err := url.QueryUnescape(strings.TrimSuffix(dirEntry.Name(), ".json"))
+		if err != nil {
+			continue
+		}
+
+		filePath := filepath.Join(dir.LocalString(), dirEntry.Name())
+
+		jsonBytes, err := ctx.FS().ReadFile(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %v\n\t%w", filePath, err)
+		}
+
+		files = append(files, legacyFile{
+			toothRepoPath: toothRepoPath,
+			kind:          dir.Base(),
+			filePath:      filePath,
+			jsonBytes:     jsonBytes,
+		})
+	}
+
+	return files, nil
+}
@@ -0,0 +1,155 @@
+package statedb
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/context"
+)
+
+func TestUpdateKeepsConcurrentChanges(t *testing.T) {
+	ctx := newTestContext(t)
+
+	const toothCount = 8
+
+	var wg sync.WaitGroup
+	errs := make(chan error, toothCount)
+
+	for i := 0; i < toothCount; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			toothRepoPath := fmt.Sprintf("github.com/tooth-hub/example%v", i)
+			errs <- Update(ctx, toothRepoPath, func(tooth *Tooth) {
+				tooth.Record = json.RawMessage(fmt.Sprintf(`{"tooth":%q}`, toothRepoPath))
+			})
+		}(i)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+	}
+
+	db, err := Open(ctx)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	if teeth := db.List(); len(teeth) != toothCount {
+		t.Errorf("database has %v teeth, want %v", len(teeth), toothCount)
+	}
+}
+
+func TestUpdateUpdatesIndexes(t *testing.T) {
+	ctx := newTestContext(t)
+
+	const toothRepoPath = "github.com/tooth-hub/example"
+
+	err := Update(ctx, toothRepoPath, func(tooth *Tooth) {
+		tooth.Version = "1.0.0"
+		tooth.Metadata = json.RawMessage(`{"tooth":"github.com/tooth-hub/example","version":"1.0.0"}`)
+		tooth.Files = []string{"plugins/a.dll", "plugins/b.dll"}
+	})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	err = Update(ctx, toothRepoPath, func(tooth *Tooth) {
+		tooth.Version = "2.0.0"
+		tooth.Metadata = json.RawMessage(`{"tooth":"github.com/tooth-hub/example","version":"2.0.0"}`)
+		tooth.Files = []string{"plugins/b.dll", "plugins/c.dll"}
+	})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	db, err := Open(ctx)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	if toothRepoPaths := db.FindByVersion("1.0.0"); len(toothRepoPaths) != 0 {
+		t.Errorf("FindByVersion(1.0.0) = %v, want none", toothRepoPaths)
+	}
+
+	if toothRepoPaths := db.FindByVersion("2.0.0"); len(toothRepoPaths) != 1 || toothRepoPaths[0] != toothRepoPath {
+		t.Errorf("FindByVersion(2.0.0) = %v, want [%v]", toothRepoPaths, toothRepoPath)
+	}
+
+	if owner, ok := db.FindOwner("plugins/a.dll"); ok {
+		t.Errorf("plugins/a.dll is still owned by %v", owner)
+	}
+
+	if owners := db.FindOwnersInDir("plugins"); len(owners) != 2 {
+		t.Errorf("FindOwnersInDir(plugins) = %v, want plugins/b.dll and plugins/c.dll", owners)
+	}
+
+	if err := Delete(ctx, toothRepoPath); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	db, err = Open(ctx)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	if _, ok := db.Get(toothRepoPath); ok {
+		t.Errorf("%v is still in the database after it was deleted", toothRepoPath)
+	}
+
+	if owner, ok := db.FindOwner("plugins/b.dll"); ok {
+		t.Errorf("plugins/b.dll is still owned by %v after it was deleted", owner)
+	}
+}
+
+// ---------------------------------------------------------------------
+
+// newTestContext makes the context of an empty workspace in a temporary directory and enters it.
+func newTestContext(t *testing.T) *context.Context {
+	t.Helper()
+
+	homeDir := t.TempDir()
+	t.Setenv("HOME", homeDir)
+	t.Setenv("USERPROFILE", homeDir)
+
+	workspaceDir := t.TempDir()
+
+	previousDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get working directory: %v", err)
+	}
+
+	if err := os.Chdir(workspaceDir); err != nil {
+		t.Fatalf("failed to enter workspace: %v", err)
+	}
+
+	t.Cleanup(func() {
+		os.Chdir(previousDir)
+	})
+
+	ctx := context.New(context.Config{
+		CredentialStore: "file",
+		Quarantine:      "keep",
+		SnapshotCount:   3,
+		Theme:           "default",
+		ToothSource:     "goproxy",
+		UpdatePolicy:    "all",
+		WorkspaceType:   "auto",
+	}, semver.MustParse("0.21.3"))
+
+	if err := ctx.CreateDirStructure(); err != nil {
+		t.Fatalf("failed to create directory structure: %v", err)
+	}
+
+	return ctx
+}
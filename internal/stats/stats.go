@@ -0,0 +1,215 @@
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+)
+
+const expectedFormatVersion = 1
+
+// mutex serializes updates of the statistics file within the process, since files may be
+// downloaded concurrently.
+var mutex sync.Mutex
+
+// Stats is the usage statistics collected on this machine, to help tune the proxy and mirror
+// configuration. They are kept in the global .lip directory and never sent anywhere.
+type Stats struct {
+	FormatVersion int `json:"format_version"`
+
+	// Since is when the statistics started to be collected, i.e. first recorded or reset.
+	Since time.Time `json:"since"`
+
+	Cache    Cache           `json:"cache"`
+	Hosts    map[string]Host `json:"hosts"`
+	Installs Installs        `json:"installs"`
+}
+
+// Cache is the statistics of the cache of downloaded tooth archives and asset archives.
+type Cache struct {
+	Hits   int `json:"hits"`
+	Misses int `json:"misses"`
+
+	// HitBytes is the total size of the files served from the cache instead of downloaded.
+	HitBytes int64 `json:"hit_bytes"`
+}
+
+// Host is the statistics of the downloads from a host, e.g. a Go module proxy or a GitHub
+// mirror.
+type Host struct {
+	Downloads int   `json:"downloads"`
+	Failures  int   `json:"failures"`
+	Bytes     int64 `json:"bytes"`
+
+	// DurationMS is the total time spent on successful downloads, in milliseconds.
+	DurationMS int64 `json:"duration_ms"`
+}
+
+// Installs is the statistics of lip install runs that installed teeth.
+type Installs struct {
+	Count int `json:"count"`
+	Teeth int `json:"teeth"`
+
+	// ResolutionMS is the total time spent on resolving dependencies, DownloadMS on downloading
+	// asset archives, and InstallationMS on placing files, all in milliseconds. The time waiting
+	// for confirmation is not counted.
+	ResolutionMS      int64 `json:"resolution_ms"`
+	MaxResolutionMS   int64 `json:"max_resolution_ms"`
+	DownloadMS        int64 `json:"download_ms"`
+	MaxDownloadMS     int64 `json:"max_download_ms"`
+	InstallationMS    int64 `json:"installation_ms"`
+	MaxInstallationMS int64 `json:"max_installation_ms"`
+}
+
+// Load loads the statistics. Empty statistics are returned if none have been recorded.
+func Load(ctx *context.Context) (Stats, error) {
+	statsFilePath, err := ctx.StatsFilePath()
+	if err != nil {
+		return Stats{}, fmt.Errorf("failed to get statistics file path\n\t%w", err)
+	}
+
+	jsonBytes, err := ctx.FS().ReadFile(statsFilePath.LocalString())
+	if os.IsNotExist(err) {
+		return newStats(ctx), nil
+	} else if err != nil {
+		return Stats{}, fmt.Errorf("failed to read statistics file %v\n\t%w", statsFilePath.LocalString(), err)
+	}
+
+	var stats Stats
+	if err := json.Unmarshal(jsonBytes, &stats); err != nil {
+		return Stats{}, errcode.Errorf(errcode.MetadataInvalid, "invalid statistics file %v: %v",
+			statsFilePath.LocalString(), err)
+	}
+
+	if stats.FormatVersion != expectedFormatVersion {
+		return Stats{}, errcode.Errorf(errcode.MetadataInvalid,
+			"unsupported format version of statistics file %v: %v", statsFilePath.LocalString(), stats.FormatVersion)
+	}
+
+	if stats.Hosts == nil {
+		stats.Hosts = make(map[string]Host)
+	}
+
+	return stats, nil
+}
+
+// Reset clears the statistics.
+func Reset(ctx *context.Context) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	return save(ctx, newStats(ctx))
+}
+
+// RecordCacheHit records a file of a size served from the cache.
+func RecordCacheHit(ctx *context.Context, size int64) {
+	update(ctx, func(stats *Stats) {
+		stats.Cache.Hits++
+		stats.Cache.HitBytes += size
+	})
+}
+
+// RecordCacheMiss records a file not found in the cache, which is then downloaded.
+func RecordCacheMiss(ctx *context.Context) {
+	update(ctx, func(stats *Stats) {
+		stats.Cache.Misses++
+	})
+}
+
+// RecordDownload records a download from a URL, of a size and taking a duration if it
+// succeeded.
+func RecordDownload(ctx *context.Context, downloadURL *url.URL, size int64, duration time.Duration, succeeded bool) {
+	update(ctx, func(stats *Stats) {
+		host := stats.Hosts[downloadURL.Host]
+		if succeeded {
+			host.Downloads++
+			host.Bytes += size
+			host.DurationMS += duration.Milliseconds()
+		} else {
+			host.Failures++
+		}
+
+		stats.Hosts[downloadURL.Host] = host
+	})
+}
+
+// RecordInstall records a lip install run installing a number of teeth, and the durations of
+// its resolution, download and installation.
+func RecordInstall(ctx *context.Context, teeth int, resolutionDuration time.Duration, downloadDuration time.Duration,
+	installationDuration time.Duration) {
+	update(ctx, func(stats *Stats) {
+		stats.Installs.Count++
+		stats.Installs.Teeth += teeth
+		stats.Installs.ResolutionMS += resolutionDuration.Milliseconds()
+		stats.Installs.DownloadMS += downloadDuration.Milliseconds()
+		stats.Installs.InstallationMS += installationDuration.Milliseconds()
+
+		if resolutionDuration.Milliseconds() > stats.Installs.MaxResolutionMS {
+			stats.Installs.MaxResolutionMS = resolutionDuration.Milliseconds()
+		}
+
+		if downloadDuration.Milliseconds() > stats.Installs.MaxDownloadMS {
+			stats.Installs.MaxDownloadMS = downloadDuration.Milliseconds()
+		}
+
+		if installationDuration.Milliseconds() > stats.Installs.MaxInstallationMS {
+			stats.Installs.MaxInstallationMS = installationDuration.Milliseconds()
+		}
+	})
+}
+
+// ---------------------------------------------------------------------
+
+// newStats returns empty statistics starting now.
+func newStats(ctx *context.Context) Stats {
+	return Stats{
+		FormatVersion: expectedFormatVersion,
+		Since:         ctx.Clock().Now().UTC(),
+		Hosts:         make(map[string]Host),
+	}
+}
+
+// save saves the statistics.
+func save(ctx *context.Context, stats Stats) error {
+	statsFilePath, err := ctx.StatsFilePath()
+	if err != nil {
+		return fmt.Errorf("failed to get statistics file path\n\t%w", err)
+	}
+
+	jsonBytes, err := json.MarshalIndent(stats, "", "    ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal statistics\n\t%w", err)
+	}
+
+	if err := ctx.FS().WriteFile(statsFilePath.LocalString(), jsonBytes, 0644); err != nil {
+		return fmt.Errorf("failed to write statistics file %v\n\t%w", statsFilePath.LocalString(), err)
+	}
+
+	return nil
+}
+
+// update applies a change to the statistics. Failures are logged as warnings, since the
+// statistics are not worth failing an operation for.
+func update(ctx *context.Context, change func(stats *Stats)) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	stats, err := Load(ctx)
+	if err != nil {
+		ctx.Logger().Warnf(i18n.T("Failed to update usage statistics:\n\t%v"), err)
+		return
+	}
+
+	change(&stats)
+
+	if err := save(ctx, stats); err != nil {
+		ctx.Logger().Warnf(i18n.T("Failed to update usage statistics:\n\t%v"), err)
+	}
+}
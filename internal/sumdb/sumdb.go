@@ -0,0 +1,188 @@
+package sumdb
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/path"
+	"github.com/lippkg/lip/internal/vfs"
+	log "github.com/sirupsen/logrus"
+)
+
+const sumDBFileName = "sumdb.json"
+
+// Kind is the kind of a file whose checksum is recorded.
+type Kind string
+
+const (
+	ToothArchiveKind Kind = "tooth"
+	AssetArchiveKind Kind = "asset"
+	// GitHubReleaseArchiveKind is a tooth archive downloaded from GitHub Releases, which is
+	// not the same file as the one from the Go module proxy.
+	GitHubReleaseArchiveKind Kind = "github-release"
+	// OCIArchiveKind is a tooth archive pulled from an OCI registry.
+	OCIArchiveKind Kind = "oci"
+)
+
+// Verify checks a downloaded file of a tooth version against the checksum database. The
+// checksum is recorded the first time a version is seen (trust on first use). Afterwards, the
+// file must match the recorded checksum, otherwise the file might have been tampered with and
+// an error is returned.
+func Verify(ctx *context.Context, toothRepoPath string, version semver.Version, kind Kind,
+	filePath path.Path) error {
+	checksum, err := CalculateChecksum(ctx.FS(), filePath)
+	if err != nil {
+		return fmt.Errorf("failed to calculate checksum of %v\n\t%w", filePath.LocalString(), err)
+	}
+
+	return VerifyChecksum(ctx, toothRepoPath, version, kind, checksum)
+}
+
+// VerifyChecksum is like Verify, but takes the hex-encoded SHA-256 checksum of the file, e.g.
+// one calculated while downloading it.
+func VerifyChecksum(ctx *context.Context, toothRepoPath string, version semver.Version, kind Kind,
+	checksum string) error {
+	debugLogger := ctx.Logger().WithFields(log.Fields{
+		"package": "sumdb",
+		"method":  "VerifyChecksum",
+	})
+
+	checksum = "sha256:" + checksum
+
+	sums, err := load(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load checksum database\n\t%w", err)
+	}
+
+	key := getKey(toothRepoPath, version, kind)
+
+	recordedChecksum, ok := sums[key]
+	if !ok {
+		sums[key] = checksum
+
+		if err := save(ctx, sums); err != nil {
+			return fmt.Errorf("failed to save checksum database\n\t%w", err)
+		}
+
+		debugLogger.Debugf("Recorded checksum of %v: %v", key, checksum)
+
+		return nil
+	}
+
+	if recordedChecksum != checksum {
+		ctx.Logger().Warnf(i18n.T("SECURITY WARNING: the %v archive of %v@%v does not match the checksum recorded when it was first downloaded. It might have been tampered with."),
+			kind, toothRepoPath, version)
+
+		return errcode.Errorf(errcode.ChecksumMismatch, "checksum mismatch for %v: recorded %v, got %v",
+			key, recordedChecksum, checksum)
+	}
+
+	debugLogger.Debugf("Checksum of %v matches: %v", key, checksum)
+
+	return nil
+}
+
+// Lookup returns the hex-encoded SHA-256 checksum recorded of a file of a tooth version, e.g.
+// to verify a file fetched from an untrusted source. The second return value is false if no
+// checksum is recorded.
+func Lookup(ctx *context.Context, toothRepoPath string, version semver.Version, kind Kind) (string, bool, error) {
+	sums, err := load(ctx)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to load checksum database\n\t%w", err)
+	}
+
+	recordedChecksum, ok := sums[getKey(toothRepoPath, version, kind)]
+	if !ok {
+		return "", false, nil
+	}
+
+	return strings.TrimPrefix(recordedChecksum, "sha256:"), true, nil
+}
+
+// CalculateChecksum returns the hex-encoded SHA-256 checksum of a file on a file system.
+func CalculateChecksum(fs vfs.FS, filePath path.Path) (string, error) {
+	file, err := fs.Open(filePath.LocalString())
+	if err != nil {
+		return "", fmt.Errorf("failed to open file\n\t%w", err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("failed to read file\n\t%w", err)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// ---------------------------------------------------------------------
+
+// getKey returns the key of a file in the checksum database.
+func getKey(toothRepoPath string, version semver.Version, kind Kind) string {
+	if kind == ToothArchiveKind {
+		return fmt.Sprintf("%v@%v", toothRepoPath, version)
+	}
+
+	return fmt.Sprintf("%v@%v/%v", toothRepoPath, version, kind)
+}
+
+// getSumDBPath returns the path to the checksum database. It is kept outside the cache
+// directory so that purging the cache does not discard the recorded checksums.
+func getSumDBPath(ctx *context.Context) (path.Path, error) {
+	globalDotLipDir, err := ctx.GlobalDotLipDir()
+	if err != nil {
+		return path.Path{}, fmt.Errorf("failed to get global .lip directory\n\t%w", err)
+	}
+
+	return globalDotLipDir.Join(path.MustParse(sumDBFileName)), nil
+}
+
+// load reads the checksum database.
+func load(ctx *context.Context) (map[string]string, error) {
+	sumDBPath, err := getSumDBPath(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	jsonBytes, err := ctx.FS().ReadFile(sumDBPath.LocalString())
+	if os.IsNotExist(err) {
+		return make(map[string]string), nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read checksum database %v\n\t%w", sumDBPath.LocalString(), err)
+	}
+
+	sums := make(map[string]string)
+	if err := json.Unmarshal(jsonBytes, &sums); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal checksum database %v\n\t%w", sumDBPath.LocalString(), err)
+	}
+
+	return sums, nil
+}
+
+// save writes the checksum database.
+func save(ctx *context.Context, sums map[string]string) error {
+	sumDBPath, err := getSumDBPath(ctx)
+	if err != nil {
+		return err
+	}
+
+	jsonBytes, err := json.MarshalIndent(sums, "", "    ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal checksum database\n\t%w", err)
+	}
+
+	if err := ctx.FS().WriteFile(sumDBPath.LocalString(), jsonBytes, 0644); err != nil {
+		return fmt.Errorf("failed to write checksum database %v\n\t%w", sumDBPath.LocalString(), err)
+	}
+
+	return nil
+}
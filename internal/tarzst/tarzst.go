@@ -0,0 +1,274 @@
+package tarzst
+
+import (
+	"archive/tar"
+	gozip "archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/path"
+	"github.com/lippkg/lip/internal/vfs"
+)
+
+// magic is the magic number at the start of a Zstandard frame.
+var magic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// IsTarZst returns whether a file on a file system is Zstandard-compressed, i.e. a .tar.zst archive rather than
+// a zip archive. Files are told apart by their content, since downloaded files have no
+// meaningful names.
+func IsTarZst(fs vfs.FS, filePath path.Path) (bool, error) {
+	file, err := fs.Open(filePath.LocalString())
+	if err != nil {
+		return false, fmt.Errorf("failed to open %v\n\t%w", filePath.LocalString(), err)
+	}
+	defer file.Close()
+
+	header := make([]byte, len(magic))
+	if _, err := io.ReadFull(file, header); err == io.EOF || err == io.ErrUnexpectedEOF {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to read %v\n\t%w", filePath.LocalString(), err)
+	}
+
+	return bytes.Equal(header, magic), nil
+}
+
+// cacheDirName is the name of the directory in the cache directory holding the zip archives
+// converted from .tar.zst archives.
+const cacheDirName = "tarzst"
+
+// ToZipIfTarZst returns the path of a zip archive with the content of an archive. A .tar.zst
+// archive is converted to a zip archive in the cache, and other archives are returned as is.
+func ToZipIfTarZst(ctx *context.Context, archivePath path.Path) (path.Path, error) {
+	isTarZst, err := IsTarZst(ctx.FS(), archivePath)
+	if err != nil {
+		return path.Path{}, err
+	}
+
+	if !isTarZst {
+		return archivePath, nil
+	}
+
+	cacheDir, err := ctx.CacheDir()
+	if err != nil {
+		return path.Path{}, fmt.Errorf("failed to get cache directory\n\t%w", err)
+	}
+
+	zipPath, err := ToZip(ctx.FS(), archivePath, cacheDir.Join(path.MustParse(cacheDirName)))
+	if err != nil {
+		return path.Path{}, fmt.Errorf("failed to convert %v to zip archive\n\t%w", archivePath.LocalString(), err)
+	}
+
+	return zipPath, nil
+}
+
+// ToZip converts a .tar.zst archive on a file system to a zip archive in a directory, named by the SHA-256
+// checksum of the .tar.zst archive, and returns its path. A zip archive converted before from
+// the same content is reused.
+func ToZip(fs vfs.FS, srcPath path.Path, dstDir path.Path) (path.Path, error) {
+	checksum, err := getChecksum(fs, srcPath)
+	if err != nil {
+		return path.Path{}, err
+	}
+
+	if err := fs.MkdirAll(dstDir.LocalString(), 0755); err != nil {
+		return path.Path{}, fmt.Errorf("failed to create directory %v\n\t%w", dstDir.LocalString(), err)
+	}
+
+	// Convert to strings, since joining paths may reuse their underlying arrays.
+	dstPathStr := dstDir.Join(path.MustParse(checksum + ".zip")).LocalString()
+	partPathStr := dstDir.Join(path.MustParse(checksum + ".zip.part")).LocalString()
+
+	dstPath, err := path.Parse(dstPathStr)
+	if err != nil {
+		return path.Path{}, fmt.Errorf("failed to parse path %v\n\t%w", dstPathStr, err)
+	}
+
+	if _, err := fs.Stat(dstPathStr); err == nil {
+		return dstPath, nil
+	} else if !os.IsNotExist(err) {
+		return path.Path{}, fmt.Errorf("failed to stat %v\n\t%w", dstPathStr, err)
+	}
+
+	if err := convert(fs, srcPath, partPathStr); err != nil {
+		fs.Remove(partPathStr)
+		return path.Path{}, err
+	}
+
+	if err := fs.Rename(partPathStr, dstPathStr); err != nil {
+		return path.Path{}, fmt.Errorf("failed to move %v to %v\n\t%w", partPathStr, dstPathStr, err)
+	}
+
+	return dstPath, nil
+}
+
+// Pack writes the files to a .tar.zst archive, with their paths relative to the current
+// directory as names. Files are written in the given order, with modTime as their modification
+// times and without owners, so the same files always make the same archive.
+func Pack(fileList []path.Path, modTime time.Time, w io.Writer) error {
+	// A single encoder goroutine keeps the output the same on any machine.
+	zstdWriter, err := zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.SpeedBestCompression),
+		zstd.WithEncoderConcurrency(1))
+	if err != nil {
+		return fmt.Errorf("failed to create zstd writer\n\t%w", err)
+	}
+
+	tarWriter := tar.NewWriter(zstdWriter)
+
+	for _, file := range fileList {
+		if err := packFile(tarWriter, file, modTime); err != nil {
+			zstdWriter.Close()
+			return err
+		}
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		zstdWriter.Close()
+		return fmt.Errorf("failed to write tar archive\n\t%w", err)
+	}
+
+	if err := zstdWriter.Close(); err != nil {
+		return fmt.Errorf("failed to write zstd stream\n\t%w", err)
+	}
+
+	return nil
+}
+
+// ---------------------------------------------------------------------
+
+// convert extracts the regular files of a .tar.zst archive into a zip archive.
+func convert(fs vfs.FS, srcPath path.Path, dstPathStr string) error {
+	srcFile, err := fs.Open(srcPath.LocalString())
+	if err != nil {
+		return fmt.Errorf("failed to open %v\n\t%w", srcPath.LocalString(), err)
+	}
+	defer srcFile.Close()
+
+	zstdReader, err := zstd.NewReader(srcFile)
+	if err != nil {
+		return errcode.Errorf(errcode.MetadataInvalid, "invalid .tar.zst archive %v: %v", srcPath.LocalString(), err)
+	}
+	defer zstdReader.Close()
+
+	dstFile, err := fs.Create(dstPathStr)
+	if err != nil {
+		return fmt.Errorf("failed to create %v\n\t%w", dstPathStr, err)
+	}
+	defer dstFile.Close()
+
+	zipWriter := gozip.NewWriter(dstFile)
+	tarReader := tar.NewReader(zstdReader)
+
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return errcode.Errorf(errcode.MetadataInvalid, "invalid .tar.zst archive %v: %v", srcPath.LocalString(),
+				err)
+		}
+
+		// Only regular files are placed by lip. Directories are implied by the paths of files.
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := strings.TrimPrefix(header.Name, "./")
+		if _, err := path.Parse(name); err != nil || strings.HasPrefix(name, "/") {
+			return errcode.Errorf(errcode.MetadataInvalid, "invalid file path %v in %v", header.Name,
+				srcPath.LocalString())
+		}
+
+		// The zip archive stays in the cache, so it is stored without compression to convert
+		// quickly.
+		zipHeader := &gozip.FileHeader{
+			Name:     name,
+			Method:   gozip.Store,
+			Modified: header.ModTime,
+		}
+		zipHeader.SetMode(header.FileInfo().Mode())
+
+		writer, err := zipWriter.CreateHeader(zipHeader)
+		if err != nil {
+			return fmt.Errorf("failed to create %v in zip archive\n\t%w", name, err)
+		}
+
+		if _, err := io.Copy(writer, tarReader); err != nil {
+			return errcode.Errorf(errcode.MetadataInvalid, "invalid .tar.zst archive %v: %v", srcPath.LocalString(),
+				err)
+		}
+	}
+
+	if err := zipWriter.Close(); err != nil {
+		return fmt.Errorf("failed to write zip archive %v\n\t%w", dstPathStr, err)
+	}
+
+	return nil
+}
+
+// getChecksum returns the hex-encoded SHA-256 checksum of a file on a file system.
+func getChecksum(fs vfs.FS, filePath path.Path) (string, error) {
+	file, err := fs.Open(filePath.LocalString())
+	if err != nil {
+		return "", fmt.Errorf("failed to open %v\n\t%w", filePath.LocalString(), err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("failed to read %v\n\t%w", filePath.LocalString(), err)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// packFile writes a file to a tar archive.
+func packFile(tarWriter *tar.Writer, filePath path.Path, modTime time.Time) error {
+	file, err := os.Open(filePath.LocalString())
+	if err != nil {
+		return fmt.Errorf("failed to open %v\n\t%w", filePath.LocalString(), err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat %v\n\t%w", filePath.LocalString(), err)
+	}
+
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%v is not a regular file", filePath.LocalString())
+	}
+
+	// Only whether a file is executable is kept, since other permissions depend on the umask.
+	mode := int64(0644)
+	if info.Mode().Perm()&0111 != 0 {
+		mode = 0755
+	}
+
+	header := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     filePath.String(),
+		Size:     info.Size(),
+		Mode:     mode,
+		ModTime:  modTime,
+	}
+
+	if err := tarWriter.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write tar header of %v\n\t%w", filePath.LocalString(), err)
+	}
+
+	if _, err := io.Copy(tarWriter, file); err != nil {
+		return fmt.Errorf("failed to write %v to tar archive\n\t%w", filePath.LocalString(), err)
+	}
+
+	return nil
+}
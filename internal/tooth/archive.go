@@ -6,71 +6,39 @@ import (
 	"io"
 	"runtime"
 
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/network"
 	"github.com/lippkg/lip/internal/path"
+	"github.com/lippkg/lip/internal/vfs"
 	"github.com/lippkg/lip/internal/zip"
+	log "github.com/sirupsen/logrus"
 )
 
 // Archive is an archive containing a tooth.
 type Archive struct {
+	fs            vfs.FS
 	metadata      Metadata
 	filePath      path.Path
 	assetFilePath path.Path
+	// subtoothRoot is the directory of the tooth in the archive if the archive is of the whole
+	// repository of a tooth in a subdirectory. Empty otherwise.
+	subtoothRoot path.Path
 }
 
-// MakeArchive creates a new archive. It will automatically convert metadata to platform-specific.
-func MakeArchive(archiveFilePath path.Path) (Archive, error) {
-	r, err := gozip.OpenReader(archiveFilePath.LocalString())
-	if err != nil {
-		return Archive{}, fmt.Errorf("failed to open zip reader %v\n\t%w", archiveFilePath.LocalString(), err)
-	}
-	defer r.Close()
-
-	filePaths, err := zip.GetFilePaths(r)
-	if err != nil {
-		return Archive{}, fmt.Errorf("failed to extract file paths from %v\n\t%w", archiveFilePath.LocalString(), err)
-	}
-
-	filePathRoot := path.ExtractLongestCommonPath(filePaths...)
-
-	// If only one file, it must be tooth.json. Then we should use the directory of the file as the root.
-	if len(filePaths) == 1 {
-		filePathRootDir, err := filePathRoot.Dir()
-		if err != nil {
-			return Archive{}, fmt.Errorf("failed to get directory of tooth.json\n\t%w", err)
-		}
-
-		filePathRoot = filePathRootDir
-	}
-
-	// Find tooth.json.
-	toothJSONFilePath := filePathRoot.Join(path.MustParse("tooth.json"))
-	var toothJSONFile *gozip.File = nil
-	for _, file := range r.File {
-		if file.Name == toothJSONFilePath.String() {
-			toothJSONFile = file
-			break
-		}
-	}
-	if toothJSONFile == nil {
-		return Archive{}, fmt.Errorf("archive does not contain tooth.json")
-	}
-
-	// Read tooth.json.
-	toothJSONFileReader, err := toothJSONFile.Open()
-	if err != nil {
-		return Archive{}, fmt.Errorf("failed to open tooth.json\n\t%w", err)
-	}
-	defer toothJSONFileReader.Close()
-
-	toothJSONBytes, err := io.ReadAll(toothJSONFileReader)
-	if err != nil {
-		return Archive{}, fmt.Errorf("failed to read tooth.json\n\t%w", err)
-	}
+// MakeArchive creates a new archive of a tooth archive on a file system, where its asset
+// archive is read too. It will automatically convert metadata to platform-specific.
+func MakeArchive(fs vfs.FS, archiveFilePath path.Path) (Archive, error) {
+	return MakeArchiveOfTooth(fs, archiveFilePath, "")
+}
 
-	// Parse tooth.json.
-	metadata, err := MakeMetadata(toothJSONBytes)
+// MakeArchiveOfTooth is like MakeArchive, but reads the tooth of a tooth repo path from the
+// archive. For a tooth in a subdirectory of a repository, e.g. github.com/owner/repo//sub, the
+// archive may be of the whole repository, in which case only the subdirectory is the tooth, or
+// of the tooth alone, e.g. published by lip publish.
+func MakeArchiveOfTooth(fs vfs.FS, archiveFilePath path.Path, toothRepoPath string) (Archive, error) {
+	metadata, subtoothRoot, err := readMetadata(fs, archiveFilePath, toothRepoPath)
 	if err != nil {
-		return Archive{}, fmt.Errorf("failed to parse tooth.json\n\t%w", err)
+		return Archive{}, err
 	}
 
 	// Convert to platform-specific metadata.
@@ -80,12 +48,28 @@ func MakeArchive(archiveFilePath path.Path) (Archive, error) {
 	}
 
 	return Archive{
+		fs:            fs,
 		metadata:      metadata,
 		filePath:      archiveFilePath,
 		assetFilePath: path.MakeEmpty(),
+		subtoothRoot:  subtoothRoot,
 	}, nil
 }
 
+// ReadMetadata reads the metadata in the metadata file (tooth.json, tooth.yaml or tooth.toml) of
+// a tooth archive on a file system, as is, i.e. not converted to platform-specific.
+func ReadMetadata(fs vfs.FS, archiveFilePath path.Path) (Metadata, error) {
+	metadata, _, err := readMetadata(fs, archiveFilePath, "")
+	return metadata, err
+}
+
+// ReadMetadataOfTooth is like ReadMetadata, but reads the metadata of a tooth repo path as
+// MakeArchiveOfTooth does.
+func ReadMetadataOfTooth(fs vfs.FS, archiveFilePath path.Path, toothRepoPath string) (Metadata, error) {
+	metadata, _, err := readMetadata(fs, archiveFilePath, toothRepoPath)
+	return metadata, err
+}
+
 func (ar Archive) AssetFilePath() (path.Path, error) {
 	if ar.assetFilePath.IsEmpty() {
 		return path.MakeEmpty(), fmt.Errorf("asset file path is empty")
@@ -104,9 +88,68 @@ func (ar Archive) Metadata() Metadata {
 	return ar.metadata
 }
 
+// RootDir returns the directory of the tooth in the tooth archive, where its metadata file is.
+// Files outside it are not part of the tooth, e.g. other teeth in the archive of a monorepo.
+func (ar Archive) RootDir() (path.Path, error) {
+	if !ar.subtoothRoot.IsEmpty() {
+		return ar.subtoothRoot, nil
+	}
+
+	r, err := zip.OpenReader(ar.fs, ar.filePath.LocalString())
+	if err != nil {
+		return path.Path{}, fmt.Errorf("failed to open zip reader %v\n\t%w", ar.filePath.LocalString(), err)
+	}
+	defer r.Close()
+
+	filePaths, err := zip.GetFilePaths(r.Reader)
+	if err != nil {
+		return path.Path{}, fmt.Errorf("failed to extract file paths from %v\n\t%w", ar.filePath.LocalString(), err)
+	}
+
+	return getFilePathRoot(filePaths)
+}
+
+// ReadFile reads a file of the tooth in the tooth archive, relative to its root directory,
+// e.g. CHANGELOG.md. The second return value is false if the file does not exist.
+func (ar Archive) ReadFile(filePath path.Path) ([]byte, bool, error) {
+	rootDir, err := ar.RootDir()
+	if err != nil {
+		return nil, false, err
+	}
+
+	r, err := zip.OpenReader(ar.fs, ar.filePath.LocalString())
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to open zip reader %v\n\t%w", ar.filePath.LocalString(), err)
+	}
+	defer r.Close()
+
+	fileName := path.MakeEmpty().Join(rootDir).Join(filePath).String()
+	for _, file := range r.File {
+		if file.Name != fileName {
+			continue
+		}
+
+		rc, err := file.Open()
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to open %v in %v\n\t%w", file.Name, ar.filePath.LocalString(), err)
+		}
+		defer rc.Close()
+
+		content, err := io.ReadAll(rc)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to read %v in %v\n\t%w", file.Name, ar.filePath.LocalString(), err)
+		}
+
+		return content, true, nil
+	}
+
+	return nil, false, nil
+}
+
 // ToAssetArchiveAttached converts the archive to an archive with asset archive attached.
-// If assetArchivePath is empty, the tooth archive will be used as the asset archive.
-func (ar Archive) ToAssetArchiveAttached(assetArchiveFilePath path.Path) (Archive, error) {
+// If assetArchivePath is empty, the tooth archive will be used as the asset archive. Debug logs
+// are written to logger.
+func (ar Archive) ToAssetArchiveAttached(logger log.FieldLogger, assetArchiveFilePath path.Path) (Archive, error) {
 	// Validate consistency of asset archive file path and asset URL.
 	assetURL, err := ar.Metadata().AssetURL()
 	if err != nil {
@@ -121,54 +164,180 @@ func (ar Archive) ToAssetArchiveAttached(assetArchiveFilePath path.Path) (Archiv
 	if assetArchiveFilePath.IsEmpty() {
 		// Extract common prefix and prepend it to all file paths in file.place.
 
-		r, err := gozip.OpenReader(ar.filePath.LocalString())
+		r, err := zip.OpenReader(ar.fs, ar.filePath.LocalString())
 		if err != nil {
 			return Archive{}, fmt.Errorf("failed to open zip reader %v\n\t%w", assetArchiveFilePath.LocalString(), err)
 		}
 		defer r.Close()
 
-		filePaths, err := zip.GetFilePaths(r)
+		filePaths, err := zip.GetFilePaths(r.Reader)
 		if err != nil {
 			return Archive{}, fmt.Errorf("failed to extract file paths from %v\n\t%w", assetArchiveFilePath.LocalString(), err)
 		}
 
 		filePathRoot := path.ExtractLongestCommonPath(filePaths...)
 
+		// Only the files in the subdirectory of a tooth in a repository archive are the tooth.
+		if !ar.subtoothRoot.IsEmpty() {
+			filePathRoot = ar.subtoothRoot
+
+			subtoothFilePaths := make([]path.Path, 0)
+			for _, filePath := range filePaths {
+				if filePathRoot.IsAncestorOf(filePath) {
+					subtoothFilePaths = append(subtoothFilePaths, filePath)
+				}
+			}
+			filePaths = subtoothFilePaths
+		}
+
 		newMetadata := ar.metadata
 		newMetadataPrefixPrepended := newMetadata.ToFilePathPrefixPrepended(filePathRoot)
-		newMetadataWildcardPopulated, err := newMetadataPrefixPrepended.ToWildcardPopulated(filePaths)
+		newMetadataWildcardPopulated, err := newMetadataPrefixPrepended.ToWildcardPopulated(logger, filePaths)
 		if err != nil {
 			return Archive{}, fmt.Errorf("failed to populate wildcards\n\t%w", err)
 		}
 
 		return Archive{
+			fs:            ar.fs,
 			metadata:      newMetadataWildcardPopulated,
 			filePath:      ar.filePath,
 			assetFilePath: ar.filePath,
+			subtoothRoot:  ar.subtoothRoot,
 		}, nil
 
 	} else {
-		r, err := gozip.OpenReader(assetArchiveFilePath.LocalString())
+		r, err := zip.OpenReader(ar.fs, assetArchiveFilePath.LocalString())
 		if err != nil {
 			return Archive{}, fmt.Errorf("failed to open zip reader %v\n\t%w", assetArchiveFilePath.LocalString(), err)
 		}
 		defer r.Close()
 
-		filePaths, err := zip.GetFilePaths(r)
+		filePaths, err := zip.GetFilePaths(r.Reader)
 		if err != nil {
 			return Archive{}, fmt.Errorf("failed to extract file paths from %v\n\t%w", assetArchiveFilePath.LocalString(), err)
 		}
 
 		newMetadata := ar.metadata
-		newMetadataWildcardPopulated, err := newMetadata.ToWildcardPopulated(filePaths)
+		newMetadataWildcardPopulated, err := newMetadata.ToWildcardPopulated(logger, filePaths)
 		if err != nil {
 			return Archive{}, fmt.Errorf("failed to populate wildcards\n\t%w", err)
 		}
 
 		return Archive{
+			fs:            ar.fs,
 			metadata:      newMetadataWildcardPopulated,
 			filePath:      ar.filePath,
 			assetFilePath: assetArchiveFilePath,
+			subtoothRoot:  ar.subtoothRoot,
 		}, nil
 	}
 }
+
+// ---------------------------------------------------------------------
+
+// getFilePathRoot returns the root directory of the files in a tooth archive.
+func getFilePathRoot(filePaths []path.Path) (path.Path, error) {
+	filePathRoot := path.ExtractLongestCommonPath(filePaths...)
+
+	// If only one file, it must be the metadata file. Then we should use the directory of the
+	// file as the root.
+	if len(filePaths) == 1 {
+		filePathRootDir, err := filePathRoot.Dir()
+		if err != nil {
+			return path.Path{}, fmt.Errorf("failed to get directory of the metadata file\n\t%w", err)
+		}
+
+		filePathRoot = filePathRootDir
+	}
+
+	return filePathRoot, nil
+}
+
+// readMetadata reads the metadata of a tooth archive. For a tooth in a subdirectory of a
+// repository, the metadata file is looked up in the subdirectory first, and the second return
+// value is the directory of the tooth in the archive if found there. It is empty otherwise.
+func readMetadata(fs vfs.FS, archiveFilePath path.Path, toothRepoPath string) (Metadata, path.Path, error) {
+	r, err := zip.OpenReader(fs, archiveFilePath.LocalString())
+	if err != nil {
+		return Metadata{}, path.Path{}, fmt.Errorf("failed to open zip reader %v\n\t%w", archiveFilePath.LocalString(),
+			err)
+	}
+	defer r.Close()
+
+	filePaths, err := zip.GetFilePaths(r.Reader)
+	if err != nil {
+		return Metadata{}, path.Path{}, fmt.Errorf("failed to extract file paths from %v\n\t%w",
+			archiveFilePath.LocalString(), err)
+	}
+
+	filePathRoot, err := getFilePathRoot(filePaths)
+	if err != nil {
+		return Metadata{}, path.Path{}, err
+	}
+
+	// Look in the subdirectory of the tooth first, and then at the root for archives of the
+	// tooth alone.
+	roots := []path.Path{filePathRoot}
+	if _, subdir := network.SplitSubtooth(toothRepoPath); subdir != "" {
+		subdirPath, err := path.Parse(subdir)
+		if err != nil {
+			return Metadata{}, path.Path{}, fmt.Errorf("failed to parse subdirectory %v\n\t%w", subdir, err)
+		}
+
+		roots = []path.Path{path.MakeEmpty().Join(filePathRoot).Join(subdirPath), filePathRoot}
+	}
+
+	// Find the metadata file, in the order of MetadataFileNames.
+	var metadataFile *gozip.File = nil
+	subtoothRoot := path.MakeEmpty()
+	for i, root := range roots {
+		for _, fileName := range MetadataFileNames {
+			metadataFilePath := root.Join(path.MustParse(fileName))
+			for _, file := range r.File {
+				if file.Name == metadataFilePath.String() {
+					metadataFile = file
+					break
+				}
+			}
+
+			if metadataFile != nil {
+				break
+			}
+		}
+
+		if metadataFile != nil {
+			if i == 0 && len(roots) > 1 {
+				subtoothRoot = root
+			}
+			break
+		}
+	}
+	if metadataFile == nil {
+		return Metadata{}, path.Path{}, errcode.Errorf(errcode.MetadataInvalid, "archive does not contain tooth.json")
+	}
+
+	format, err := ParseMetadataFormat(metadataFile.Name)
+	if err != nil {
+		return Metadata{}, path.Path{}, err
+	}
+
+	// Read the metadata file.
+	metadataFileReader, err := metadataFile.Open()
+	if err != nil {
+		return Metadata{}, path.Path{}, fmt.Errorf("failed to open %v\n\t%w", metadataFile.Name, err)
+	}
+	defer metadataFileReader.Close()
+
+	metadataBytes, err := io.ReadAll(metadataFileReader)
+	if err != nil {
+		return Metadata{}, path.Path{}, fmt.Errorf("failed to read %v\n\t%w", metadataFile.Name, err)
+	}
+
+	// Parse the metadata file.
+	metadata, err := MakeMetadataInFormat(metadataBytes, format)
+	if err != nil {
+		return Metadata{}, path.Path{}, errcode.Errorf(errcode.MetadataInvalid, "failed to parse %v\n\t%w", metadataFile.Name, err)
+	}
+
+	return metadata, subtoothRoot, nil
+}
@@ -0,0 +1,58 @@
+package tooth
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/lippkg/lip/internal/path"
+)
+
+// DefaultChangelogFile is the changelog of a tooth in its tooth archive if tooth.json does not
+// declare one.
+const DefaultChangelogFile = "CHANGELOG.md"
+
+// Changelog returns where the changelog of the tooth is: a URL, or a file in the tooth archive
+// relative to the root of the tooth. It is DefaultChangelogFile if not declared.
+func (m Metadata) Changelog() string {
+	if m.rawMetadata.Changelog == "" {
+		return DefaultChangelogFile
+	}
+
+	return m.rawMetadata.Changelog
+}
+
+// IsChangelogURL returns whether a changelog returned by Changelog is a URL instead of a file in
+// the tooth archive.
+func IsChangelogURL(changelog string) bool {
+	return strings.HasPrefix(changelog, "http://") || strings.HasPrefix(changelog, "https://")
+}
+
+// ---------------------------------------------------------------------
+
+// validateChangelog checks that the changelog is an HTTP(S) URL or a relative path in the tooth
+// archive.
+func validateChangelog(rawMetadata RawMetadata) error {
+	changelog := rawMetadata.Changelog
+	if changelog == "" {
+		return nil
+	}
+
+	if IsChangelogURL(changelog) {
+		if _, err := url.Parse(changelog); err != nil {
+			return fmt.Errorf("failed to parse URL %v\n\t%w", changelog, err)
+		}
+
+		return nil
+	}
+
+	if strings.HasPrefix(changelog, "/") || strings.Contains(changelog, "://") {
+		return fmt.Errorf("%v is neither an HTTP(S) URL nor a relative path", changelog)
+	}
+
+	if _, err := path.Parse(changelog); err != nil {
+		return fmt.Errorf("failed to parse path %v\n\t%w", changelog, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,177 @@
+package tooth
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/blang/semver/v4"
+)
+
+// capabilityDependencyPrefix marks a dependency on a capability instead of a tooth, e.g.
+// capability:levilamina-loader.
+const capabilityDependencyPrefix = "capability:"
+
+// featureNamePattern matches the names of features and capabilities, as in the JSON schema.
+var featureNamePattern = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// DependencyKey is a parsed key of dependencies in tooth.json, which is one of:
+//
+//   - a tooth repo path, e.g. github.com/tooth-hub/example.
+//   - a tooth repo path followed by the features the tooth must have, e.g.
+//     github.com/tooth-hub/example[gui,scripting].
+//   - a capability prefixed with capability:, satisfied by any tooth providing it, e.g.
+//     capability:levilamina-loader.
+type DependencyKey struct {
+	// ToothRepoPath is the tooth depended on. Empty for a capability.
+	ToothRepoPath string
+	// Features is the features the tooth must have. Empty if none is required.
+	Features []string
+	// Capability is the capability depended on. Empty for a tooth.
+	Capability string
+}
+
+// ParseDependencyKey parses a key of dependencies in tooth.json.
+func ParseDependencyKey(key string) (DependencyKey, error) {
+	if strings.HasPrefix(key, capabilityDependencyPrefix) {
+		capability := strings.TrimPrefix(key, capabilityDependencyPrefix)
+		if !featureNamePattern.MatchString(capability) {
+			return DependencyKey{}, fmt.Errorf("invalid capability %v", capability)
+		}
+
+		return DependencyKey{Capability: capability}, nil
+	}
+
+	toothRepoPath := key
+	features := make([]string, 0)
+
+	if strings.HasSuffix(key, "]") {
+		openIndex := strings.LastIndex(key, "[")
+		if openIndex == -1 {
+			return DependencyKey{}, fmt.Errorf("unmatched ] in %v", key)
+		}
+
+		toothRepoPath = key[:openIndex]
+
+		for _, feature := range strings.Split(key[openIndex+1:len(key)-1], ",") {
+			feature = strings.TrimSpace(feature)
+			if !featureNamePattern.MatchString(feature) {
+				return DependencyKey{}, fmt.Errorf("invalid feature %v in %v", feature, key)
+			}
+
+			features = append(features, feature)
+		}
+	}
+
+	if !IsValidToothRepoPath(toothRepoPath) {
+		return DependencyKey{}, fmt.Errorf("invalid tooth repo path %v", toothRepoPath)
+	}
+
+	return DependencyKey{ToothRepoPath: toothRepoPath, Features: features}, nil
+}
+
+// IsCapability returns whether the key is a dependency on a capability.
+func (k DependencyKey) IsCapability() bool {
+	return k.Capability != ""
+}
+
+// String returns the key as written in tooth.json.
+func (k DependencyKey) String() string {
+	if k.IsCapability() {
+		return capabilityDependencyPrefix + k.Capability
+	}
+
+	if len(k.Features) == 0 {
+		return k.ToothRepoPath
+	}
+
+	return fmt.Sprintf("%v[%v]", k.ToothRepoPath, strings.Join(k.Features, ","))
+}
+
+// ---------------------------------------------------------------------
+
+// dependencyIdentity returns what a key of dependencies depends on, i.e. the tooth repo path,
+// or the capability with its prefix. Keys of the same identity must not be declared together.
+func dependencyIdentity(key DependencyKey) string {
+	if key.IsCapability() {
+		return capabilityDependencyPrefix + key.Capability
+	}
+
+	return key.ToothRepoPath
+}
+
+// overrideDependency sets a dependency in dependencies, replacing the one of the same
+// identity, e.g. when platform-specific dependencies are merged with the global ones.
+func overrideDependency(dependencies map[string]string, rawKey string, dep string) {
+	// Keys are validated when the metadata is made.
+	key, err := ParseDependencyKey(rawKey)
+	if err == nil {
+		for otherRawKey := range dependencies {
+			otherKey, err := ParseDependencyKey(otherRawKey)
+			if err == nil && dependencyIdentity(otherKey) == dependencyIdentity(key) {
+				delete(dependencies, otherRawKey)
+			}
+		}
+	}
+
+	dependencies[rawKey] = dep
+}
+
+// validateDependencies checks that the keys of dependencies, including those in platforms,
+// can be parsed and that no tooth or capability is declared twice in the same map, and that
+// the declared features, capabilities and provided teeth are valid.
+func validateDependencies(rawMetadata RawMetadata) error {
+	dependencyMaps := []map[string]string{rawMetadata.Dependencies}
+	for _, platformItem := range rawMetadata.Platforms {
+		dependencyMaps = append(dependencyMaps, platformItem.Dependencies)
+	}
+
+	for _, dependencyMap := range dependencyMaps {
+		identities := make(map[string]string)
+
+		for rawKey := range dependencyMap {
+			key, err := ParseDependencyKey(rawKey)
+			if err != nil {
+				return fmt.Errorf("failed to parse dependency %v\n\t%w", rawKey, err)
+			}
+
+			identity := dependencyIdentity(key)
+			if otherRawKey, ok := identities[identity]; ok {
+				return fmt.Errorf("%v is declared twice, as %v and %v", identity, otherRawKey, rawKey)
+			}
+			identities[identity] = rawKey
+		}
+	}
+
+	for _, feature := range rawMetadata.Features {
+		if !featureNamePattern.MatchString(feature) {
+			return fmt.Errorf("invalid feature %v", feature)
+		}
+	}
+
+	for toothRepoPath, version := range rawMetadata.Provides {
+		if !IsValidToothRepoPath(toothRepoPath) {
+			return fmt.Errorf("invalid provided tooth repo path %v", toothRepoPath)
+		}
+
+		if toothRepoPath == rawMetadata.Tooth {
+			return fmt.Errorf("a tooth cannot provide itself")
+		}
+
+		if _, err := semver.Parse(version); err != nil {
+			return fmt.Errorf("failed to parse provided version %v of %v\n\t%w", version, toothRepoPath, err)
+		}
+	}
+
+	for capability, version := range rawMetadata.Capabilities {
+		if !featureNamePattern.MatchString(capability) {
+			return fmt.Errorf("invalid capability %v", capability)
+		}
+
+		if _, err := semver.Parse(version); err != nil {
+			return fmt.Errorf("failed to parse version %v of capability %v\n\t%w", version, capability, err)
+		}
+	}
+
+	return nil
+}
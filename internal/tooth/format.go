@@ -0,0 +1,561 @@
+package tooth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/lippkg/lip/internal/errcode"
+	"gopkg.in/yaml.v3"
+)
+
+// MetadataFormat is a file format of tooth metadata.
+type MetadataFormat string
+
+const (
+	JSONMetadataFormat MetadataFormat = "json"
+	YAMLMetadataFormat MetadataFormat = "yaml"
+	TOMLMetadataFormat MetadataFormat = "toml"
+)
+
+// MetadataFileNames are the names of the metadata file of a tooth, in the order of precedence
+// if a tooth has more than one.
+var MetadataFileNames = []string{"tooth.json", "tooth.yaml", "tooth.toml"}
+
+// ParseMetadataFormat returns the metadata format of a file by its extension.
+func ParseMetadataFormat(fileName string) (MetadataFormat, error) {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".json":
+		return JSONMetadataFormat, nil
+	case ".yaml", ".yml":
+		return YAMLMetadataFormat, nil
+	case ".toml":
+		return TOMLMetadataFormat, nil
+	default:
+		return "", errcode.Errorf(errcode.InvalidArgument, "unsupported metadata format of %v", fileName)
+	}
+}
+
+// FindMetadataFile returns the path of the metadata file in dir, in the order of
+// MetadataFileNames. The second return value is false if there is none.
+func FindMetadataFile(dir string) (string, bool, error) {
+	for _, fileName := range MetadataFileNames {
+		filePath := filepath.Join(dir, fileName)
+
+		if _, err := os.Stat(filePath); err == nil {
+			return filePath, true, nil
+		} else if !os.IsNotExist(err) {
+			return "", false, fmt.Errorf("failed to stat %v\n\t%w", filePath, err)
+		}
+	}
+
+	return "", false, nil
+}
+
+// ReadMetadataFile reads and parses a metadata file in the format of its extension.
+func ReadMetadataFile(filePath string) (Metadata, error) {
+	format, err := ParseMetadataFormat(filePath)
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return Metadata{}, fmt.Errorf("failed to read %v\n\t%w", filePath, err)
+	}
+
+	metadata, err := MakeMetadataInFormat(data, format)
+	if err != nil {
+		return Metadata{}, fmt.Errorf("failed to parse %v\n\t%w", filePath, err)
+	}
+
+	return metadata, nil
+}
+
+// MakeMetadataInFormat parses the given metadata in the given format and returns a Metadata.
+func MakeMetadataInFormat(data []byte, format MetadataFormat) (Metadata, error) {
+	jsonBytes, err := ConvertMetadata(data, format, JSONMetadataFormat)
+	if err != nil {
+		return Metadata{}, errcode.Errorf(errcode.MetadataInvalid, "failed to convert metadata to JSON\n\t%w", err)
+	}
+
+	return MakeMetadata(jsonBytes)
+}
+
+// ConvertMetadata converts metadata from a format to another. The order of the keys is kept
+// except when converting to TOML. The metadata is not validated. Null values are dropped when
+// converting to TOML, which has no null.
+func ConvertMetadata(data []byte, from MetadataFormat, to MetadataFormat) ([]byte, error) {
+	value, err := decodeMetadata(data, from)
+	if err != nil {
+		return nil, err
+	}
+
+	return encodeMetadata(value, to)
+}
+
+// SetMetadataFields sets string fields of metadata, keeping its format and the order of the
+// other keys, e.g. for lip new. Fields are given by their keys joined by dots, e.g. info.name.
+// Missing fields and objects are added. The metadata is not validated.
+func SetMetadataFields(data []byte, format MetadataFormat, fields map[string]string) ([]byte, error) {
+	value, err := decodeMetadata(data, format)
+	if err != nil {
+		return nil, err
+	}
+
+	root, ok := value.(*orderedObject)
+	if !ok {
+		return nil, fmt.Errorf("metadata is not an object")
+	}
+
+	keyPaths := make([]string, 0, len(fields))
+	for keyPath := range fields {
+		keyPaths = append(keyPaths, keyPath)
+	}
+	sort.Strings(keyPaths)
+
+	for _, keyPath := range keyPaths {
+		keys := strings.Split(keyPath, ".")
+
+		object := root
+		for _, key := range keys[:len(keys)-1] {
+			child, ok := object.values[key].(*orderedObject)
+			if !ok {
+				child = newOrderedObject()
+				object.set(key, child)
+			}
+			object = child
+		}
+
+		object.set(keys[len(keys)-1], fields[keyPath])
+	}
+
+	return encodeMetadata(root, format)
+}
+
+// ---------------------------------------------------------------------
+
+// decodeMetadata decodes metadata in a format into orderedObject, []interface{} and scalars.
+func decodeMetadata(data []byte, format MetadataFormat) (interface{}, error) {
+	var value interface{}
+	var err error
+
+	switch format {
+	case JSONMetadataFormat:
+		value, err = decodeJSONValue(data)
+	case YAMLMetadataFormat:
+		value, err = decodeYAMLValue(data)
+	case TOMLMetadataFormat:
+		value, err = decodeTOMLValue(data)
+	default:
+		return nil, fmt.Errorf("unsupported metadata format %v", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %v\n\t%w", format, err)
+	}
+
+	return value, nil
+}
+
+// encodeMetadata encodes metadata decoded by decodeMetadata in a format.
+func encodeMetadata(value interface{}, format MetadataFormat) ([]byte, error) {
+	switch format {
+	case JSONMetadataFormat:
+		// Version ranges contain < and >, which are kept as they are instead of being escaped.
+		buffer := &bytes.Buffer{}
+		encoder := json.NewEncoder(buffer)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "    ")
+		if err := encoder.Encode(value); err != nil {
+			return nil, fmt.Errorf("failed to encode JSON\n\t%w", err)
+		}
+
+		return buffer.Bytes(), nil
+
+	case YAMLMetadataFormat:
+		node, err := encodeYAMLNode(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode YAML\n\t%w", err)
+		}
+
+		buffer := &bytes.Buffer{}
+		encoder := yaml.NewEncoder(buffer)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(node); err != nil {
+			return nil, fmt.Errorf("failed to encode YAML\n\t%w", err)
+		}
+		if err := encoder.Close(); err != nil {
+			return nil, fmt.Errorf("failed to encode YAML\n\t%w", err)
+		}
+
+		return buffer.Bytes(), nil
+
+	case TOMLMetadataFormat:
+		buffer := &bytes.Buffer{}
+		if err := toml.NewEncoder(buffer).Encode(encodeTOMLValue(value)); err != nil {
+			return nil, fmt.Errorf("failed to encode TOML\n\t%w", err)
+		}
+
+		return buffer.Bytes(), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported metadata format %v", format)
+	}
+}
+
+// orderedObject is an object of metadata keeping the order of its keys.
+type orderedObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newOrderedObject() *orderedObject {
+	return &orderedObject{
+		keys:   make([]string, 0),
+		values: make(map[string]interface{}),
+	}
+}
+
+func (o *orderedObject) set(key string, value interface{}) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+func (o *orderedObject) MarshalJSON() ([]byte, error) {
+	buffer := &bytes.Buffer{}
+	buffer.WriteByte('{')
+	for i, key := range o.keys {
+		if i != 0 {
+			buffer.WriteByte(',')
+		}
+
+		keyBytes, err := marshalJSONWithoutEscaping(key)
+		if err != nil {
+			return nil, err
+		}
+
+		valueBytes, err := marshalJSONWithoutEscaping(o.values[key])
+		if err != nil {
+			return nil, err
+		}
+
+		buffer.Write(keyBytes)
+		buffer.WriteByte(':')
+		buffer.Write(valueBytes)
+	}
+	buffer.WriteByte('}')
+
+	return buffer.Bytes(), nil
+}
+
+// marshalJSONWithoutEscaping is json.Marshal without escaping <, > and &.
+func marshalJSONWithoutEscaping(value interface{}) ([]byte, error) {
+	buffer := &bytes.Buffer{}
+	encoder := json.NewEncoder(buffer)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSuffix(buffer.Bytes(), []byte("\n")), nil
+}
+
+// decodeJSONValue decodes JSON into orderedObject, []interface{}, json.Number and other
+// scalars.
+func decodeJSONValue(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	value, err := decodeJSONToken(decoder)
+	if err != nil {
+		return nil, err
+	}
+
+	if decoder.More() {
+		return nil, fmt.Errorf("unexpected data after the top-level value")
+	}
+
+	return value, nil
+}
+
+func decodeJSONToken(decoder *json.Decoder) (interface{}, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token {
+	case json.Delim('{'):
+		object := newOrderedObject()
+		for decoder.More() {
+			keyToken, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+
+			value, err := decodeJSONToken(decoder)
+			if err != nil {
+				return nil, err
+			}
+
+			object.set(keyToken.(string), value)
+		}
+
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+
+		return object, nil
+
+	case json.Delim('['):
+		array := make([]interface{}, 0)
+		for decoder.More() {
+			value, err := decodeJSONToken(decoder)
+			if err != nil {
+				return nil, err
+			}
+
+			array = append(array, value)
+		}
+
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+
+		return array, nil
+
+	default:
+		return token, nil
+	}
+}
+
+// decodeYAMLValue decodes YAML into orderedObject, []interface{} and scalars.
+func decodeYAMLValue(data []byte) (interface{}, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+
+	if len(document.Content) == 0 {
+		return nil, fmt.Errorf("empty document")
+	}
+
+	return decodeYAMLNode(document.Content[0])
+}
+
+func decodeYAMLNode(node *yaml.Node) (interface{}, error) {
+	switch node.Kind {
+	case yaml.AliasNode:
+		return decodeYAMLNode(node.Alias)
+
+	case yaml.MappingNode:
+		object := newOrderedObject()
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			value, err := decodeYAMLNode(node.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+
+			object.set(node.Content[i].Value, value)
+		}
+
+		return object, nil
+
+	case yaml.SequenceNode:
+		array := make([]interface{}, 0)
+		for _, itemNode := range node.Content {
+			value, err := decodeYAMLNode(itemNode)
+			if err != nil {
+				return nil, err
+			}
+
+			array = append(array, value)
+		}
+
+		return array, nil
+
+	default:
+		var value interface{}
+		if err := node.Decode(&value); err != nil {
+			return nil, fmt.Errorf("failed to decode value at line %v\n\t%w", node.Line, err)
+		}
+
+		return value, nil
+	}
+}
+
+// decodeTOMLValue decodes TOML into orderedObject, []interface{} and scalars. Keys are in the
+// order they appear in the document.
+func decodeTOMLValue(data []byte) (interface{}, error) {
+	var document map[string]interface{}
+	tomlMetadata, err := toml.Decode(string(data), &document)
+	if err != nil {
+		return nil, err
+	}
+
+	keyOrders := make(map[string]int)
+	for i, key := range tomlMetadata.Keys() {
+		keyOrders[key.String()] = i
+	}
+
+	return orderTOMLValue(document, "", keyOrders), nil
+}
+
+func orderTOMLValue(value interface{}, keyPath string, keyOrders map[string]int) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+
+		// Items of arrays of tables share key paths, which are ordered by the first item.
+		keyOrder := func(key string) int {
+			order, ok := keyOrders[joinTOMLKey(keyPath, key)]
+			if !ok {
+				return len(keyOrders)
+			}
+			return order
+		}
+		sort.SliceStable(keys, func(i, j int) bool {
+			if keyOrder(keys[i]) != keyOrder(keys[j]) {
+				return keyOrder(keys[i]) < keyOrder(keys[j])
+			}
+			return keys[i] < keys[j]
+		})
+
+		object := newOrderedObject()
+		for _, key := range keys {
+			object.set(key, orderTOMLValue(value[key], joinTOMLKey(keyPath, key), keyOrders))
+		}
+
+		return object
+
+	case []map[string]interface{}:
+		array := make([]interface{}, 0, len(value))
+		for _, item := range value {
+			array = append(array, orderTOMLValue(item, keyPath, keyOrders))
+		}
+
+		return array
+
+	case []interface{}:
+		array := make([]interface{}, 0, len(value))
+		for _, item := range value {
+			array = append(array, orderTOMLValue(item, keyPath, keyOrders))
+		}
+
+		return array
+
+	default:
+		return value
+	}
+}
+
+// joinTOMLKey joins a key to a key path as toml.Key.String does.
+func joinTOMLKey(keyPath string, key string) string {
+	keyString := toml.Key{key}.String()
+	if keyPath == "" {
+		return keyString
+	}
+
+	return keyPath + "." + keyString
+}
+
+func encodeYAMLNode(value interface{}) (*yaml.Node, error) {
+	switch value := value.(type) {
+	case *orderedObject:
+		node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for _, key := range value.keys {
+			valueNode, err := encodeYAMLNode(value.values[key])
+			if err != nil {
+				return nil, err
+			}
+
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, valueNode)
+		}
+
+		return node, nil
+
+	case []interface{}:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, item := range value {
+			itemNode, err := encodeYAMLNode(item)
+			if err != nil {
+				return nil, err
+			}
+
+			node.Content = append(node.Content, itemNode)
+		}
+
+		return node, nil
+
+	case json.Number:
+		tag := "!!int"
+		if strings.ContainsAny(value.String(), ".eE") {
+			tag = "!!float"
+		}
+
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value.String()}, nil
+
+	default:
+		node := &yaml.Node{}
+		if err := node.Encode(value); err != nil {
+			return nil, err
+		}
+
+		return node, nil
+	}
+}
+
+// encodeTOMLValue converts a value to maps, slices and scalars the TOML encoder accepts. The
+// TOML encoder sorts keys and puts tables after other keys, so the order is not kept.
+func encodeTOMLValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case *orderedObject:
+		table := make(map[string]interface{})
+		for _, key := range value.keys {
+			if value.values[key] == nil {
+				continue
+			}
+
+			table[key] = encodeTOMLValue(value.values[key])
+		}
+
+		return table
+
+	case []interface{}:
+		array := make([]interface{}, 0, len(value))
+		for _, item := range value {
+			if item == nil {
+				continue
+			}
+
+			array = append(array, encodeTOMLValue(item))
+		}
+
+		return array
+
+	case json.Number:
+		if integer, err := value.Int64(); err == nil {
+			return integer
+		}
+
+		float, err := value.Float64()
+		if err != nil {
+			return value.String()
+		}
+
+		return float
+
+	default:
+		return value
+	}
+}
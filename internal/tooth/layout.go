@@ -0,0 +1,61 @@
+package tooth
+
+import (
+	"sort"
+	"sync"
+)
+
+// Logical placement roots, expanded in templates as root.NAME to the directories of the layout
+// of the workspace, e.g. {{root.plugins}}/example.
+const (
+	// PluginsRoot is the directory plugins or mods are loaded from.
+	PluginsRoot = "plugins"
+	// BehaviorPacksRoot is the directory behavior packs are loaded from.
+	BehaviorPacksRoot = "behavior_packs"
+	// ResourcePacksRoot is the directory resource packs are loaded from.
+	ResourcePacksRoot = "resource_packs"
+	// WorldsRoot is the directory worlds are kept in.
+	WorldsRoot = "worlds"
+	// WorldRoot is the directory of the world loaded, e.g. with its pack configuration files.
+	WorldRoot = "world"
+)
+
+// rootTemplateVariablePrefix is the prefix of the names of the template variables taken from
+// the layout of the workspace.
+const rootTemplateVariablePrefix = "root."
+
+var rootNames = []string{PluginsRoot, BehaviorPacksRoot, ResourcePacksRoot, WorldsRoot, WorldRoot}
+
+var (
+	layoutRootsMu sync.RWMutex
+	layoutRoots   = make(map[string]string)
+)
+
+// RootNames returns the names of the logical placement roots, sorted.
+func RootNames() []string {
+	names := make([]string, len(rootNames))
+	copy(names, rootNames)
+	sort.Strings(names)
+
+	return names
+}
+
+// IsRootName returns whether name is a logical placement root.
+func IsRootName(name string) bool {
+	for _, rootName := range rootNames {
+		if name == rootName {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetLayoutRoots sets the directories of the logical placement roots in the workspace, relative
+// to the workspace and separated by slashes, expanded in templates as root.NAME.
+func SetLayoutRoots(roots map[string]string) {
+	layoutRootsMu.Lock()
+	defer layoutRootsMu.Unlock()
+
+	layoutRoots = roots
+}
@@ -0,0 +1,351 @@
+package tooth
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/network"
+)
+
+// LintWarning is a problem of valid metadata that may trouble its users, e.g. a missing
+// license or a dependency on all future versions of a tooth.
+type LintWarning struct {
+	// Field is the dotted path of the value, e.g. info.license or files.place.2.
+	Field string
+	// Description is what is wrong with the value.
+	Description string
+	// Fixable is whether LintMetadata can fix the problem.
+	Fixable bool
+	// Fixed is whether LintMetadata fixed the problem.
+	Fixed bool
+}
+
+// comparatorSpacePattern matches the spaces between the operator and the version of a
+// comparator of a version range, e.g. in >= 1.0.0, which blang/semver ignores.
+var comparatorSpacePattern = regexp.MustCompile(`([<>=!]+)\s+`)
+
+// LintMetadata checks metadata in a format for problems the schema and the validation of
+// metadata do not catch. The metadata must be valid. If fix is set, the fixable problems are
+// fixed, and the fixed metadata is returned in the same format, keeping the order of the keys.
+// Otherwise, or if nothing is fixed, the returned metadata is nil.
+func LintMetadata(data []byte, format MetadataFormat, fix bool) ([]LintWarning, []byte, error) {
+	value, err := decodeMetadata(data, format)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	root, ok := value.(*orderedObject)
+	if !ok {
+		return nil, nil, fmt.Errorf("metadata is not an object")
+	}
+
+	warnings := make([]LintWarning, 0)
+	warnings = append(warnings, lintToothRepoPath(root, fix)...)
+	warnings = append(warnings, lintInfo(root, fix)...)
+	warnings = append(warnings, lintDependencies(root, "", fix)...)
+	warnings = append(warnings, lintPlacements(root, fix)...)
+
+	platformItems, _ := root.values["platforms"].([]interface{})
+	for i, platformItem := range platformItems {
+		if platformObject, ok := platformItem.(*orderedObject); ok {
+			warnings = append(warnings, lintDependencies(platformObject, fmt.Sprintf("platforms.%v.", i), fix)...)
+		}
+	}
+
+	isFixed := false
+	for _, warning := range warnings {
+		isFixed = isFixed || warning.Fixed
+	}
+
+	if !isFixed {
+		return warnings, nil, nil
+	}
+
+	fixedData, err := encodeMetadata(root, format)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return warnings, fixedData, nil
+}
+
+// ---------------------------------------------------------------------
+
+// lintToothRepoPath warns about a tooth repo path with upper-case letters, which are escaped in
+// URLs and file names, and lowercases it if fix is set.
+func lintToothRepoPath(root *orderedObject, fix bool) []LintWarning {
+	toothRepoPath, _ := root.values["tooth"].(string)
+	if toothRepoPath == strings.ToLower(toothRepoPath) {
+		return nil
+	}
+
+	warning := LintWarning{
+		Field:       "tooth",
+		Description: fmt.Sprintf("tooth repo path %v is not lowercase", toothRepoPath),
+		Fixable:     true,
+	}
+
+	if fix {
+		root.set("tooth", strings.ToLower(toothRepoPath))
+		warning.Fixed = true
+	}
+
+	return []LintWarning{warning}
+}
+
+// lintInfo warns about a missing description, license or homepage. A missing homepage is set to
+// the URL of the repository of the tooth if fix is set.
+func lintInfo(root *orderedObject, fix bool) []LintWarning {
+	info, ok := root.values["info"].(*orderedObject)
+	if !ok {
+		return nil
+	}
+
+	warnings := make([]LintWarning, 0)
+
+	if description, _ := info.values["description"].(string); strings.TrimSpace(description) == "" {
+		warnings = append(warnings, LintWarning{
+			Field:       "info.description",
+			Description: "description is missing",
+		})
+	}
+
+	if license, _ := info.values["license"].(string); license == "" {
+		warnings = append(warnings, LintWarning{
+			Field:       "info.license",
+			Description: "license is missing, so installation policies requiring a license block the tooth",
+		})
+	}
+
+	if homepage, _ := info.values["homepage"].(string); homepage == "" {
+		warning := LintWarning{
+			Field:       "info.homepage",
+			Description: "homepage is missing",
+			Fixable:     true,
+		}
+
+		if fix {
+			// The tooth repo path is fixed before, so the homepage is lowercase as well.
+			toothRepoPath, _ := root.values["tooth"].(string)
+			repoPath, _ := network.SplitSubtooth(toothRepoPath)
+
+			info.set("homepage", "https://"+repoPath)
+			warning.Fixed = true
+		}
+
+		warnings = append(warnings, warning)
+	}
+
+	return warnings
+}
+
+// lintDependencies warns about dependencies without upper bounds on the versions of the teeth
+// depended on, which break when the teeth make breaking changes. If fix is set, a range with
+// lower bounds only, e.g. >=1.2.0, is bounded by the next major version, e.g. <2.0.0. The
+// fields are prefixed with prefix, e.g. platforms.0. for platform-specific dependencies.
+func lintDependencies(object *orderedObject, prefix string, fix bool) []LintWarning {
+	dependencies, ok := object.values["dependencies"].(*orderedObject)
+	if !ok {
+		return nil
+	}
+
+	warnings := make([]LintWarning, 0)
+	for _, key := range dependencies.keys {
+		versionRange, _ := dependencies.values[key].(string)
+		if isVersionRangeBounded(versionRange) {
+			continue
+		}
+
+		warning := LintWarning{
+			Field:       prefix + "dependencies." + key,
+			Description: fmt.Sprintf("version range %v of %v has no upper bound", versionRange, key),
+		}
+
+		if boundedRange, ok := boundVersionRange(versionRange); ok {
+			warning.Fixable = true
+			warning.Description += fmt.Sprintf(", e.g. %v", boundedRange)
+
+			if fix {
+				dependencies.set(key, boundedRange)
+				warning.Fixed = true
+			}
+		}
+
+		warnings = append(warnings, warning)
+	}
+
+	return warnings
+}
+
+// lintPlacements warns about files placed to the same destination more than once, including by
+// the platforms, which are appended to the general placements. Duplicates with the same source
+// are removed if fix is set. The others conflict and cannot be fixed.
+func lintPlacements(root *orderedObject, fix bool) []LintWarning {
+	warnings := make([]LintWarning, 0)
+
+	generalSources := make(map[string]string)
+	warnings = append(warnings, lintPlacementList(root, "", generalSources, fix)...)
+
+	platformItems, _ := root.values["platforms"].([]interface{})
+	for i, platformItem := range platformItems {
+		platformObject, ok := platformItem.(*orderedObject)
+		if !ok {
+			continue
+		}
+
+		sources := make(map[string]string)
+		for dest, src := range generalSources {
+			sources[dest] = src
+		}
+
+		warnings = append(warnings, lintPlacementList(platformObject, fmt.Sprintf("platforms.%v.", i), sources, fix)...)
+	}
+
+	return warnings
+}
+
+// lintPlacementList checks the placements in files.place of an object against the sources of
+// the destinations placed so far, and adds its placements to them.
+func lintPlacementList(object *orderedObject, prefix string, sources map[string]string, fix bool) []LintWarning {
+	files, ok := object.values["files"].(*orderedObject)
+	if !ok {
+		return nil
+	}
+
+	placeItems, ok := files.values["place"].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	warnings := make([]LintWarning, 0)
+	keptItems := make([]interface{}, 0, len(placeItems))
+	for i, placeItem := range placeItems {
+		placeObject, ok := placeItem.(*orderedObject)
+		if !ok {
+			keptItems = append(keptItems, placeItem)
+			continue
+		}
+
+		src, _ := placeObject.values["src"].(string)
+		dest, _ := placeObject.values["dest"].(string)
+
+		otherSrc, isPlaced := sources[dest]
+		if !isPlaced {
+			sources[dest] = src
+			keptItems = append(keptItems, placeItem)
+			continue
+		}
+
+		field := fmt.Sprintf("%vfiles.place.%v", prefix, i)
+
+		if otherSrc != src {
+			warnings = append(warnings, LintWarning{
+				Field:       field,
+				Description: fmt.Sprintf("%v is placed from both %v and %v", dest, otherSrc, src),
+			})
+			keptItems = append(keptItems, placeItem)
+			continue
+		}
+
+		warning := LintWarning{
+			Field:       field,
+			Description: fmt.Sprintf("%v is placed from %v more than once", dest, src),
+			Fixable:     true,
+		}
+
+		if fix {
+			warning.Fixed = true
+		} else {
+			keptItems = append(keptItems, placeItem)
+		}
+
+		warnings = append(warnings, warning)
+	}
+
+	if len(keptItems) != len(placeItems) {
+		files.set("place", keptItems)
+	}
+
+	return warnings
+}
+
+// isVersionRangeBounded returns whether every alternative of a version range has an upper
+// bound, i.e. a < or <= comparator, an exact version or a wildcard with a major version like
+// 1.x.
+func isVersionRangeBounded(versionRange string) bool {
+	for _, alternative := range splitVersionRange(versionRange) {
+		isBounded := false
+		for _, comparator := range alternative {
+			if isComparatorBounded(comparator) {
+				isBounded = true
+				break
+			}
+		}
+
+		if !isBounded {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isComparatorBounded returns whether a comparator of a version range has an upper bound.
+func isComparatorBounded(comparator string) bool {
+	switch {
+	case strings.HasPrefix(comparator, "<"):
+		return true
+	case strings.HasPrefix(comparator, ">"), strings.HasPrefix(comparator, "!"):
+		return false
+	}
+
+	major := strings.SplitN(strings.TrimPrefix(comparator, "="), ".", 2)[0]
+	return major != "" && major != "x" && major != "X" && major != "*"
+}
+
+// boundVersionRange bounds a version range of a single alternative with lower bounds only,
+// e.g. >=1.2.0, by the next major version of the highest lower bound, e.g. >=1.2.0 <2.0.0, or
+// by the next minor version for major version zero. The second return value is false if the
+// range is not of this kind.
+func boundVersionRange(versionRange string) (string, bool) {
+	alternatives := splitVersionRange(versionRange)
+	if len(alternatives) != 1 || len(alternatives[0]) == 0 {
+		return "", false
+	}
+
+	var lowerBound *semver.Version
+	for _, comparator := range alternatives[0] {
+		if !strings.HasPrefix(comparator, ">") {
+			return "", false
+		}
+
+		version, err := semver.Parse(strings.TrimLeft(comparator, ">="))
+		if err != nil {
+			return "", false
+		}
+
+		if lowerBound == nil || version.GT(*lowerBound) {
+			lowerBound = &version
+		}
+	}
+
+	upperBound := semver.Version{Major: lowerBound.Major + 1}
+	if lowerBound.Major == 0 {
+		upperBound = semver.Version{Minor: lowerBound.Minor + 1}
+	}
+
+	return strings.Join(alternatives[0], " ") + " <" + upperBound.String(), true
+}
+
+// splitVersionRange splits a version range into its alternatives separated by ||, each split
+// into its comparators.
+func splitVersionRange(versionRange string) [][]string {
+	alternatives := make([][]string, 0)
+	for _, alternative := range strings.Split(versionRange, "||") {
+		alternatives = append(alternatives, strings.Fields(comparatorSpacePattern.ReplaceAllString(alternative, "$1")))
+	}
+
+	return alternatives
+}
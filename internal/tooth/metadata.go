@@ -4,20 +4,31 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	gopath "path"
 
 	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/i18n"
 	"github.com/lippkg/lip/internal/path"
 	"github.com/lippkg/lip/internal/tooth/migration/v1tov2"
-	"github.com/xeipuuv/gojsonschema"
 
 	log "github.com/sirupsen/logrus"
 )
 
 type Metadata struct {
 	rawMetadata RawMetadata
+	// unknownFields is the fields of tooth.json not declared by RawMetadata, kept to be written
+	// back by MarshalJSON. Nil if there is none.
+	unknownFields *orderedObject
 }
 
 type Info struct {
@@ -25,6 +36,12 @@ type Info struct {
 	Description string
 	Author      string
 	Tags        []string
+	AvatarURL   string
+	// License is the SPDX license expression of the tooth, e.g. MIT. Empty if not declared.
+	License string
+	// Homepage is the URL of the homepage of the tooth, e.g. its repository. Empty if not
+	// declared.
+	Homepage string
 }
 type Commands struct {
 	PreInstall    []string
@@ -33,6 +50,47 @@ type Commands struct {
 	PostUninstall []string
 }
 
+// Build is how the tooth is built from its source before it is packed.
+type Build struct {
+	// Commands is the commands run by lip build, in the directory of the tooth.
+	Commands []string
+	// Output is the files and directories packed into the tooth archive besides the metadata
+	// file, relative to the directory of the tooth. Empty if everything is packed.
+	Output []path.Path
+}
+
+type Deprecation struct {
+	Reason      string
+	Replacement string
+}
+
+type Environment struct {
+	// Path is the directories relative to the workspace to add to PATH.
+	Path []path.Path
+	// Variables is the environment variables to set.
+	Variables map[string]string
+}
+
+// Service is an OS service running a program of the tooth, e.g. a server, which the user may
+// register when installing the tooth.
+type Service struct {
+	// Name is the name of the service registered with the OS.
+	Name        string
+	Description string
+	// Command is the program to run, relative to the workspace.
+	Command path.Path
+	Args    []string
+}
+
+// HealthCheck is a check run after installing the tooth to verify that it works, either a
+// command that must exit with 0 or an HTTP endpoint that must respond with a 2xx status.
+type HealthCheck struct {
+	Command string
+	URL     string
+	// Timeout is how long the check is retried until it passes.
+	Timeout time.Duration
+}
+
 type Files struct {
 	Place    []FilesPlaceItem
 	Preserve []path.Path
@@ -42,16 +100,51 @@ type Files struct {
 type FilesPlaceItem struct {
 	Src  path.Path
 	Dest path.Path
+	// Config is how user edits of the file are kept on upgrades. Empty if the file is not a
+	// config file.
+	Config ConfigStrategy
+	// Mode is the permission bits of the placed file. Zero if not specified.
+	Mode os.FileMode
+	// Executable is whether the placed file is made executable.
+	Executable bool
+	// Tags is the categories of the placed file, e.g. docs, which users may skip installing.
+	Tags []string
+	// UserData is whether the placed file holds user data, which is never replaced and is kept
+	// on uninstalling unless purged.
+	UserData bool
 }
 
+// ConfigStrategy is how a config file is placed when the user has edited the installed one.
+type ConfigStrategy string
+
+const (
+	NoConfigStrategy ConfigStrategy = ""
+	// BackupConfigStrategy backs up the edited file and places the new one.
+	BackupConfigStrategy ConfigStrategy = "backup"
+	// NewConfigStrategy keeps the edited file and places the new one with a .new suffix.
+	NewConfigStrategy ConfigStrategy = "new"
+	// MergeConfigStrategy merges the user edits into the new file, and falls back to
+	// NewConfigStrategy if they conflict.
+	MergeConfigStrategy ConfigStrategy = "merge"
+)
+
 const expectedFormatVersion = 2
 
+// defaultHealthCheckTimeout is the timeout of a health check not declaring one.
+const defaultHealthCheckTimeout = 30 * time.Second
+
+// serviceNamePattern matches the name of an OS service, as in the JSON schema.
+var serviceNamePattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]*$`)
+
+// assetSHA256Pattern matches a hex-encoded SHA-256 checksum, as in the JSON schema.
+var assetSHA256Pattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
 // MakeMetadata parses the given jsonBytes and returns a Metadata.
 func MakeMetadata(jsonBytes []byte) (Metadata, error) {
 	// Migrate if needed.
 	formatVersion, err := parseFormatVersion(jsonBytes)
 	if err != nil {
-		return Metadata{}, fmt.Errorf("failed to get format version\n\t%w", err)
+		return Metadata{}, errcode.Errorf(errcode.MetadataInvalid, "failed to get format version\n\t%w", err)
 	}
 
 	isMigrationNeeded := false
@@ -59,7 +152,7 @@ func MakeMetadata(jsonBytes []byte) (Metadata, error) {
 	case 1:
 		migratedJSONBytes, err := v1tov2.Migrate(jsonBytes)
 		if err != nil {
-			return Metadata{}, fmt.Errorf("failed to migrate metadata\n\t%w", err)
+			return Metadata{}, errcode.Errorf(errcode.MetadataInvalid, "failed to migrate metadata\n\t%w", err)
 		}
 
 		isMigrationNeeded = true
@@ -70,63 +163,126 @@ func MakeMetadata(jsonBytes []byte) (Metadata, error) {
 		// Do nothing.
 
 	default:
-		return Metadata{}, fmt.Errorf("unsupported format version: %v", formatVersion)
+		return Metadata{}, errcode.Errorf(errcode.MetadataInvalid, "unsupported format version: %v", formatVersion)
 	}
 
 	// Validate JSON against schema
-	schemaLoader := gojsonschema.NewStringLoader(metadataJSONSchema)
-	documentLoader := gojsonschema.NewBytesLoader(jsonBytes)
-
-	validationResult, err := gojsonschema.Validate(schemaLoader, documentLoader)
+	validationErrors, err := Validate(jsonBytes)
 	if err != nil {
-		return Metadata{}, fmt.Errorf("failed to validate raw metadata\n\t%w", err)
+		return Metadata{}, errcode.Errorf(errcode.MetadataInvalid, "failed to validate raw metadata\n\t%w", err)
 	}
 
-	if !validationResult.Valid() {
+	if len(validationErrors) != 0 {
 		errors := make([]string, 0)
-		for _, err := range validationResult.Errors() {
-			errors = append(errors, err.String())
+		for _, validationError := range validationErrors {
+			errors = append(errors, validationError.Error())
 		}
-		return Metadata{}, fmt.Errorf("raw metadata is invalid: %v",
+		return Metadata{}, errcode.Errorf(errcode.MetadataInvalid, "raw metadata is invalid: %v",
 			strings.Join(errors, ", "))
 	}
 
 	// Unmarshal JSON
 	var rawMetadata RawMetadata
 	if err := json.Unmarshal(jsonBytes, &rawMetadata); err != nil {
-		return Metadata{}, fmt.Errorf("failed to unmarshal raw metadata\n\t%w", err)
+		return Metadata{}, errcode.Errorf(errcode.MetadataInvalid, "failed to unmarshal raw metadata\n\t%w", err)
 	}
 
 	metadata, err := MakeMetadataFromRaw(rawMetadata)
 	if err != nil {
-		return Metadata{}, fmt.Errorf("failed to make metadata\n\t%w", err)
+		return Metadata{}, errcode.Errorf(errcode.MetadataInvalid, "failed to make metadata\n\t%w", err)
+	}
+
+	// Keep unknown fields, e.g. $schema or fields of newer versions of lip.
+	document, err := decodeJSONValue(jsonBytes)
+	if err != nil {
+		return Metadata{}, errcode.Errorf(errcode.MetadataInvalid, "failed to parse json\n\t%w", err)
+	}
+
+	if object, ok := document.(*orderedObject); ok {
+		metadata.unknownFields = collectUnknownFields(object, reflect.TypeOf(RawMetadata{}))
 	}
 
 	// Warn for obsolete tooth.json.
 	if isMigrationNeeded {
-		log.Warnf("tooth.json format version %v of %v is deprecated. This tooth might be obsolete.",
+		log.Warnf(i18n.T("tooth.json format version %v of %v is deprecated. This tooth might be obsolete."),
 			formatVersion, metadata.ToothRepoPath())
 	}
 
 	return metadata, nil
 }
 
+// IsCurrentFormat returns whether tooth.json is of the current format version, i.e. it is used
+// as it is rather than migrated each time it is loaded.
+func IsCurrentFormat(jsonBytes []byte) (bool, error) {
+	formatVersion, err := parseFormatVersion(jsonBytes)
+	if err != nil {
+		return false, err
+	}
+
+	return formatVersion == expectedFormatVersion, nil
+}
+
 // MakeMetadataFromRaw returns a Metadata from the given RawMetadata.
 func MakeMetadataFromRaw(rawMetadata RawMetadata) (Metadata, error) {
 	// Validate metadata.
 	if rawMetadata.FormatVersion != expectedFormatVersion {
-		return Metadata{}, fmt.Errorf("unsupported format version: %v", rawMetadata.FormatVersion)
+		return Metadata{}, errcode.Errorf(errcode.MetadataInvalid, "unsupported format version: %v", rawMetadata.FormatVersion)
 	}
 
 	if !IsValidToothRepoPath(rawMetadata.Tooth) {
-		return Metadata{}, fmt.Errorf("invalid tooth repo path %v", rawMetadata.Tooth)
+		return Metadata{}, errcode.Errorf(errcode.MetadataInvalid, "invalid tooth repo path %v", rawMetadata.Tooth)
 	}
 
 	if _, err := semver.Parse(rawMetadata.Version); err != nil {
-		return Metadata{}, fmt.Errorf("failed to parse version\n\t%w", err)
+		return Metadata{}, errcode.Errorf(errcode.MetadataInvalid, "failed to parse version\n\t%w", err)
+	}
+
+	if rawMetadata.Deprecated != nil && rawMetadata.Deprecated.Replacement != "" &&
+		!IsValidToothRepoPath(rawMetadata.Deprecated.Replacement) {
+		return Metadata{}, errcode.Errorf(errcode.MetadataInvalid, "invalid replacement tooth repo path %v", rawMetadata.Deprecated.Replacement)
+	}
+
+	if err := validateTemplates(rawMetadata); err != nil {
+		return Metadata{}, errcode.Errorf(errcode.MetadataInvalid, "invalid template\n\t%w", err)
+	}
+
+	if err := validateDependencies(rawMetadata); err != nil {
+		return Metadata{}, errcode.Errorf(errcode.MetadataInvalid, "invalid dependencies\n\t%w", err)
+	}
+
+	if err := validateAssetSHA256(rawMetadata); err != nil {
+		return Metadata{}, errcode.Errorf(errcode.MetadataInvalid, "invalid asset checksum\n\t%w", err)
+	}
+
+	if err := validateServices(rawMetadata); err != nil {
+		return Metadata{}, errcode.Errorf(errcode.MetadataInvalid, "invalid service\n\t%w", err)
+	}
+
+	if err := validateHealthChecks(rawMetadata); err != nil {
+		return Metadata{}, errcode.Errorf(errcode.MetadataInvalid, "invalid health check\n\t%w", err)
+	}
+
+	if err := validateRuntimes(rawMetadata); err != nil {
+		return Metadata{}, errcode.Errorf(errcode.MetadataInvalid, "invalid runtimes\n\t%w", err)
+	}
+
+	if err := validateRegistrations(rawMetadata); err != nil {
+		return Metadata{}, errcode.Errorf(errcode.MetadataInvalid, "invalid registrations\n\t%w", err)
+	}
+
+	if err := validateTools(rawMetadata); err != nil {
+		return Metadata{}, errcode.Errorf(errcode.MetadataInvalid, "invalid tools\n\t%w", err)
+	}
+
+	if err := validateChangelog(rawMetadata); err != nil {
+		return Metadata{}, errcode.Errorf(errcode.MetadataInvalid, "invalid changelog\n\t%w", err)
 	}
 
-	return Metadata{rawMetadata}, nil
+	if err := validateSupportedPlatforms(rawMetadata); err != nil {
+		return Metadata{}, errcode.Errorf(errcode.MetadataInvalid, "invalid supported platforms\n\t%w", err)
+	}
+
+	return Metadata{rawMetadata: rawMetadata}, nil
 }
 
 func (m Metadata) ToothRepoPath() string {
@@ -145,20 +301,83 @@ func (m Metadata) AssetURL() (*url.URL, error) {
 	return url.Parse(m.rawMetadata.AssetURL)
 }
 
+// AssetSHA256 returns the hex-encoded SHA-256 checksum the asset archive is pinned to. It is
+// empty if the asset archive is not pinned.
+func (m Metadata) AssetSHA256() string {
+	return m.rawMetadata.AssetSHA256
+}
+
+// AssetURLs returns the asset URLs of all platforms, without duplicates. Platform-specific
+// metadata has at most one. Template variables are expanded for each platform declared in
+// platforms, and asset URLs whose variables cannot be expanded that way, e.g. {{goarch}} of a
+// platform without goarch, are left out.
+func (m Metadata) AssetURLs() ([]*url.URL, error) {
+	version := m.rawMetadata.Version
+
+	rawAssetURLs := []string{expandTemplate(m.rawMetadata.AssetURL, makeTemplateVariables(version, "", ""))}
+	for _, platformItem := range m.rawMetadata.Platforms {
+		variables := makeTemplateVariables(version, platformItem.GOOS, platformItem.GOARCH)
+
+		// The top-level asset URL applies to platforms without their own.
+		rawAssetURLs = append(rawAssetURLs, expandTemplate(m.rawMetadata.AssetURL, variables),
+			expandTemplate(platformItem.AssetURL, variables))
+	}
+
+	assetURLs := make([]*url.URL, 0)
+	seen := make(map[string]bool)
+	for _, rawAssetURL := range rawAssetURLs {
+		if rawAssetURL == "" || seen[rawAssetURL] || !isTemplateExpanded(rawAssetURL) {
+			continue
+		}
+		seen[rawAssetURL] = true
+
+		assetURL, err := url.Parse(rawAssetURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse asset URL %v\n\t%w", rawAssetURL, err)
+		}
+
+		assetURLs = append(assetURLs, assetURL)
+	}
+
+	return assetURLs, nil
+}
+
 func (m Metadata) Commands() Commands {
 	return Commands(m.rawMetadata.Commands)
 }
 
+// Deprecation returns the deprecation of the tooth. The second return value indicates whether
+// the tooth is deprecated.
+func (m Metadata) Deprecation() (Deprecation, bool) {
+	if m.rawMetadata.Deprecated == nil {
+		return Deprecation{}, false
+	}
+
+	return Deprecation(*m.rawMetadata.Deprecated), true
+}
+
+// Dependencies returns the version ranges of the teeth depended on, keyed by their tooth repo
+// paths. Required features are left out, and so are dependencies on capabilities. See
+// DependencyFeatures and CapabilityDependencies for them.
 func (m Metadata) Dependencies() (map[string]semver.Range, error) {
 	dependencies := make(map[string]semver.Range)
 
-	for toothRepoPath, dep := range m.rawMetadata.Dependencies {
+	for rawKey, dep := range m.rawMetadata.Dependencies {
+		key, err := ParseDependencyKey(rawKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse dependency %v\n\t%w", rawKey, err)
+		}
+
+		if key.IsCapability() {
+			continue
+		}
+
 		versionRange, err := semver.ParseRange(dep)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse version range \"%v\" of %v\n\t%w", dep, toothRepoPath, err)
+			return nil, fmt.Errorf("failed to parse version range \"%v\" of %v\n\t%w", dep, key.ToothRepoPath, err)
 		}
 
-		dependencies[toothRepoPath] = versionRange
+		dependencies[key.ToothRepoPath] = versionRange
 	}
 
 	return dependencies, nil
@@ -167,13 +386,170 @@ func (m Metadata) Dependencies() (map[string]semver.Range, error) {
 func (m Metadata) DependenciesAsStrings() map[string]string {
 	dependencies := make(map[string]string)
 
-	for toothRepoPath, dep := range m.rawMetadata.Dependencies {
-		dependencies[toothRepoPath] = dep
+	for rawKey, dep := range m.rawMetadata.Dependencies {
+		// Keys are validated when the metadata is made.
+		key, err := ParseDependencyKey(rawKey)
+		if err != nil || key.IsCapability() {
+			continue
+		}
+
+		dependencies[key.ToothRepoPath] = dep
+	}
+
+	return dependencies
+}
+
+// DependencyFeatures returns the features required of the teeth depended on, keyed by their
+// tooth repo paths. Teeth without required features are left out.
+func (m Metadata) DependencyFeatures() map[string][]string {
+	features := make(map[string][]string)
+
+	for rawKey := range m.rawMetadata.Dependencies {
+		key, err := ParseDependencyKey(rawKey)
+		if err != nil || key.IsCapability() || len(key.Features) == 0 {
+			continue
+		}
+
+		features[key.ToothRepoPath] = key.Features
+	}
+
+	return features
+}
+
+// CapabilityDependencies returns the version ranges of the capabilities depended on, keyed by
+// the capabilities.
+func (m Metadata) CapabilityDependencies() (map[string]semver.Range, error) {
+	dependencies := make(map[string]semver.Range)
+
+	for capability, dep := range m.CapabilityDependenciesAsStrings() {
+		versionRange, err := semver.ParseRange(dep)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse version range \"%v\" of capability %v\n\t%w", dep, capability, err)
+		}
+
+		dependencies[capability] = versionRange
+	}
+
+	return dependencies, nil
+}
+
+func (m Metadata) CapabilityDependenciesAsStrings() map[string]string {
+	dependencies := make(map[string]string)
+
+	for rawKey, dep := range m.rawMetadata.Dependencies {
+		key, err := ParseDependencyKey(rawKey)
+		if err != nil || !key.IsCapability() {
+			continue
+		}
+
+		dependencies[key.Capability] = dep
 	}
 
 	return dependencies
 }
 
+// Features returns the optional features the tooth declares to have.
+func (m Metadata) Features() []string {
+	return append([]string{}, m.rawMetadata.Features...)
+}
+
+// HasFeatures returns whether the tooth declares to have all the features.
+func (m Metadata) HasFeatures(features []string) bool {
+	declaredFeatures := make(map[string]bool)
+	for _, feature := range m.rawMetadata.Features {
+		declaredFeatures[feature] = true
+	}
+
+	for _, feature := range features {
+		if !declaredFeatures[feature] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Capabilities returns the versions of the capabilities the tooth provides, keyed by the
+// capabilities.
+func (m Metadata) Capabilities() map[string]semver.Version {
+	capabilities := make(map[string]semver.Version)
+
+	for capability, version := range m.rawMetadata.Capabilities {
+		// Versions are validated when the metadata is made.
+		capabilities[capability] = semver.MustParse(version)
+	}
+
+	return capabilities
+}
+
+// Provides returns the versions of the teeth the tooth provides, keyed by their tooth repo
+// paths. A tooth providing another one, e.g. a fork or a bundle, satisfies dependencies on it.
+func (m Metadata) Provides() map[string]semver.Version {
+	provides := make(map[string]semver.Version)
+
+	for toothRepoPath, version := range m.rawMetadata.Provides {
+		// Versions are validated when the metadata is made.
+		provides[toothRepoPath] = semver.MustParse(version)
+	}
+
+	return provides
+}
+
+// Runtimes returns the version ranges of the runtimes the tooth is compatible with, e.g.
+// LeviLamina, keyed by the runtime names.
+func (m Metadata) Runtimes() map[string]semver.Range {
+	runtimes := make(map[string]semver.Range)
+
+	for runtime, versionRange := range m.rawMetadata.Runtimes {
+		// Version ranges are validated when the metadata is made.
+		runtimes[runtime] = semver.MustParseRange(versionRange)
+	}
+
+	return runtimes
+}
+
+// RuntimesAsStrings returns the version ranges of the runtimes as declared in tooth.json.
+func (m Metadata) RuntimesAsStrings() map[string]string {
+	runtimes := make(map[string]string)
+
+	for runtime, versionRange := range m.rawMetadata.Runtimes {
+		runtimes[runtime] = versionRange
+	}
+
+	return runtimes
+}
+
+// ProvidesTooth returns whether the tooth provides another tooth of a version in versionRange.
+func (m Metadata) ProvidesTooth(toothRepoPath string, versionRange semver.Range) bool {
+	rawVersion, ok := m.rawMetadata.Provides[toothRepoPath]
+	if !ok {
+		return false
+	}
+
+	version, err := semver.Parse(rawVersion)
+	if err != nil {
+		return false
+	}
+
+	return versionRange(version)
+}
+
+// ProvidesCapability returns whether the tooth provides a capability of a version in
+// versionRange.
+func (m Metadata) ProvidesCapability(capability string, versionRange semver.Range) bool {
+	rawVersion, ok := m.rawMetadata.Capabilities[capability]
+	if !ok {
+		return false
+	}
+
+	version, err := semver.Parse(rawVersion)
+	if err != nil {
+		return false
+	}
+
+	return versionRange(version)
+}
+
 func (m Metadata) Prerequisites() (map[string]semver.Range, error) {
 	prerequisites := make(map[string]semver.Range)
 
@@ -199,6 +575,92 @@ func (m Metadata) PrerequisitesAsStrings() map[string]string {
 	return prerequisites
 }
 
+// Environment returns the environment the tooth requires to run.
+func (m Metadata) Environment() (Environment, error) {
+	pathList := make([]path.Path, 0)
+	for _, pathItem := range m.rawMetadata.Environment.Path {
+		dir, err := path.Parse(pathItem)
+		if err != nil {
+			return Environment{}, fmt.Errorf("failed to parse environment path %v\n\t%w", pathItem, err)
+		}
+
+		pathList = append(pathList, dir)
+	}
+
+	variables := make(map[string]string)
+	for name, value := range m.rawMetadata.Environment.Variables {
+		variables[name] = value
+	}
+
+	return Environment{
+		Path:      pathList,
+		Variables: variables,
+	}, nil
+}
+
+// Service returns the OS service of the tooth. The second return value indicates whether the
+// tooth declares one.
+func (m Metadata) Service() (Service, bool, error) {
+	if m.rawMetadata.Service == nil {
+		return Service{}, false, nil
+	}
+
+	command, err := path.Parse(m.rawMetadata.Service.Command)
+	if err != nil {
+		return Service{}, false, fmt.Errorf("failed to parse service command %v\n\t%w", m.rawMetadata.Service.Command, err)
+	}
+
+	return Service{
+		Name:        m.rawMetadata.Service.Name,
+		Description: m.rawMetadata.Service.Description,
+		Command:     command,
+		Args:        append([]string{}, m.rawMetadata.Service.Args...),
+	}, true, nil
+}
+
+// HealthCheck returns the health check of the tooth. The second return value indicates
+// whether the tooth declares one.
+func (m Metadata) HealthCheck() (HealthCheck, bool) {
+	if m.rawMetadata.HealthCheck == nil {
+		return HealthCheck{}, false
+	}
+
+	timeout := defaultHealthCheckTimeout
+	if m.rawMetadata.HealthCheck.Timeout > 0 {
+		timeout = time.Duration(m.rawMetadata.HealthCheck.Timeout) * time.Second
+	}
+
+	return HealthCheck{
+		Command: m.rawMetadata.HealthCheck.Command,
+		URL:     m.rawMetadata.HealthCheck.URL,
+		Timeout: timeout,
+	}, true
+}
+
+// Build returns how the tooth is built before it is packed.
+func (m Metadata) Build() (Build, error) {
+	output := make([]path.Path, 0)
+	for _, outputItem := range m.rawMetadata.Build.Output {
+		outputPath, err := ParseWorkspaceDir(outputItem)
+		if err != nil {
+			return Build{}, fmt.Errorf("failed to parse build output %v\n\t%w", outputItem, err)
+		}
+
+		// Whitelisting the whole directory of the tooth is the same as not whitelisting.
+		if outputPath.IsEmpty() {
+			output = make([]path.Path, 0)
+			break
+		}
+
+		output = append(output, outputPath)
+	}
+
+	return Build{
+		Commands: append([]string{}, m.rawMetadata.Build.Commands...),
+		Output:   output,
+	}, nil
+}
+
 func (m Metadata) Files() (Files, error) {
 	if !m.IsWildcardPopulated() {
 		return Files{}, fmt.Errorf("wildcard is not populated")
@@ -206,25 +668,40 @@ func (m Metadata) Files() (Files, error) {
 
 	place := make([]FilesPlaceItem, 0)
 	for _, placeItem := range m.rawMetadata.Files.Place {
-		src, err := path.Parse(placeItem.Src)
+		src, err := path.ParseRelative(placeItem.Src)
 		if err != nil {
 			return Files{}, fmt.Errorf("failed to parse source path\n\t%w", err)
 		}
 
-		dest, err := path.Parse(placeItem.Dest)
+		dest, err := path.ParseRelative(placeItem.Dest)
 		if err != nil {
 			return Files{}, fmt.Errorf("failed to parse destination path\n\t%w", err)
 		}
 
+		var mode os.FileMode
+		if placeItem.Mode != "" {
+			modeBits, err := strconv.ParseUint(placeItem.Mode, 8, 32)
+			if err != nil {
+				return Files{}, fmt.Errorf("failed to parse mode %v\n\t%w", placeItem.Mode, err)
+			}
+
+			mode = os.FileMode(modeBits).Perm()
+		}
+
 		place = append(place, FilesPlaceItem{
-			Src:  src,
-			Dest: dest,
+			Src:        src,
+			Dest:       dest,
+			Config:     ConfigStrategy(placeItem.Config),
+			Mode:       mode,
+			Executable: placeItem.Executable,
+			Tags:       placeItem.Tags,
+			UserData:   placeItem.UserData,
 		})
 	}
 
 	preserve := make([]path.Path, 0)
 	for _, preserveItem := range m.rawMetadata.Files.Preserve {
-		preservePath, err := path.Parse(preserveItem)
+		preservePath, err := path.ParseRelative(preserveItem)
 		if err != nil {
 			return Files{}, fmt.Errorf("failed to parse preserve path\n\t%w", err)
 		}
@@ -234,7 +711,7 @@ func (m Metadata) Files() (Files, error) {
 
 	remove := make([]path.Path, 0)
 	for _, removeItem := range m.rawMetadata.Files.Remove {
-		removePath, err := path.Parse(removeItem)
+		removePath, err := path.ParseRelative(removeItem)
 		if err != nil {
 			return Files{}, fmt.Errorf("failed to parse remove path\n\t%w", err)
 		}
@@ -259,13 +736,29 @@ func (m Metadata) IsWildcardPopulated() bool {
 	return true
 }
 
+// MarshalJSON marshals the metadata in a canonical order: declared fields in the order of
+// RawMetadata, keys of maps sorted, and then unknown fields sorted. Unknown fields in objects,
+// but not in items of arrays, are kept as read.
 func (m Metadata) MarshalJSON() ([]byte, error) {
-	jsonBytes, err := json.MarshalIndent(m.rawMetadata, "", "    ")
-
+	jsonBytes, err := json.Marshal(m.rawMetadata)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal raw metadata\n\t%w", err)
 	}
 
+	document, err := decodeJSONValue(jsonBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode raw metadata\n\t%w", err)
+	}
+
+	if m.unknownFields != nil {
+		mergeUnknownFields(document.(*orderedObject), m.unknownFields)
+	}
+
+	jsonBytes, err = json.MarshalIndent(document, "", "    ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal metadata\n\t%w", err)
+	}
+
 	return jsonBytes, nil
 }
 
@@ -278,6 +771,11 @@ func (m Metadata) ToPlatformSpecific(goos string, goarch string) (Metadata, erro
 	if raw.Prerequisites == nil {
 		raw.Prerequisites = make(map[string]string)
 	}
+	// Copy the variables so that the platform-specific ones do not leak into m.
+	raw.Environment.Variables = make(map[string]string)
+	for name, value := range m.rawMetadata.Environment.Variables {
+		raw.Environment.Variables[name] = value
+	}
 	raw.Platforms = nil
 
 	for _, platformItem := range m.rawMetadata.Platforms {
@@ -289,8 +787,11 @@ func (m Metadata) ToPlatformSpecific(goos string, goarch string) (Metadata, erro
 			continue
 		}
 
+		// The checksum pins the asset archive it is declared with, so a platform-specific asset
+		// URL never inherits the checksum of the general one.
 		if platformItem.AssetURL != "" {
 			raw.AssetURL = platformItem.AssetURL
+			raw.AssetSHA256 = platformItem.AssetSHA256
 		}
 
 		raw.Commands.PreInstall = append(raw.Commands.PreInstall, platformItem.Commands.PreInstall...)
@@ -298,8 +799,8 @@ func (m Metadata) ToPlatformSpecific(goos string, goarch string) (Metadata, erro
 		raw.Commands.PreUninstall = append(raw.Commands.PreUninstall, platformItem.Commands.PreUninstall...)
 		raw.Commands.PostUninstall = append(raw.Commands.PostUninstall, platformItem.Commands.PostUninstall...)
 
-		for toothRepoPath, dep := range platformItem.Dependencies {
-			raw.Dependencies[toothRepoPath] = dep
+		for rawKey, dep := range platformItem.Dependencies {
+			overrideDependency(raw.Dependencies, rawKey, dep)
 		}
 
 		for toothRepoPath, prereq := range platformItem.Prerequisites {
@@ -309,9 +810,47 @@ func (m Metadata) ToPlatformSpecific(goos string, goarch string) (Metadata, erro
 		raw.Files.Place = append(raw.Files.Place, platformItem.Files.Place...)
 		raw.Files.Preserve = append(raw.Files.Preserve, platformItem.Files.Preserve...)
 		raw.Files.Remove = append(raw.Files.Remove, platformItem.Files.Remove...)
+
+		raw.Environment.Path = append(raw.Environment.Path, platformItem.Environment.Path...)
+		for name, value := range platformItem.Environment.Variables {
+			raw.Environment.Variables[name] = value
+		}
+
+		if platformItem.Service != nil {
+			raw.Service = platformItem.Service
+		}
+
+		if platformItem.HealthCheck != nil {
+			raw.HealthCheck = platformItem.HealthCheck
+		}
+
+		raw.Build.Commands = append(raw.Build.Commands, platformItem.Build.Commands...)
+		raw.Build.Output = append(raw.Build.Output, platformItem.Build.Output...)
+
+		raw.Registrations = append(raw.Registrations, platformItem.Registrations...)
+		raw.Tools = append(raw.Tools, platformItem.Tools...)
+	}
+
+	// Expand the template variables, whose values are known now.
+	variables := makeTemplateVariables(raw.Version, goos, goarch)
+	raw.AssetURL = expandTemplate(raw.AssetURL, variables)
+	raw.Files = expandFilesTemplates(raw.Files, variables)
+	raw.Registrations = expandRegistrationsTemplates(raw.Registrations, variables)
+
+	templates := append([]string{raw.AssetURL}, filesTemplates(raw.Files)...)
+	templates = append(templates, registrationsTemplates(raw.Registrations)...)
+	if err := checkWorkspaceTemplatesExpanded(templates); err != nil {
+		return Metadata{}, err
+	}
+
+	metadata, err := MakeMetadataFromRaw(raw)
+	if err != nil {
+		return Metadata{}, err
 	}
 
-	return MakeMetadataFromRaw(raw)
+	metadata.unknownFields = m.unknownFields
+
+	return metadata, nil
 }
 
 // ToFilePathPrefixPrepended prepends the given prefix to files.place field of metadata.
@@ -322,19 +861,24 @@ func (m Metadata) ToFilePathPrefixPrepended(prefix path.Path) Metadata {
 
 	for _, placeItem := range m.rawMetadata.Files.Place {
 		newPlace = append(newPlace, RawMetadataFilesPlaceItem{
-			Src:  gopath.Join(prefix.String(), placeItem.Src),
-			Dest: placeItem.Dest,
+			Src:        gopath.Join(prefix.String(), placeItem.Src),
+			Dest:       placeItem.Dest,
+			Config:     placeItem.Config,
+			Mode:       placeItem.Mode,
+			Executable: placeItem.Executable,
+			Tags:       placeItem.Tags,
+			UserData:   placeItem.UserData,
 		})
 	}
 
 	newRaw.Files.Place = newPlace
 
-	return Metadata{newRaw}
+	return Metadata{rawMetadata: newRaw, unknownFields: m.unknownFields}
 }
 
 // ToWildcardPopulated populates wildcards in files.place field of metadata.
-func (m Metadata) ToWildcardPopulated(filePaths []path.Path) (Metadata, error) {
-	debugLogger := log.WithFields(log.Fields{
+func (m Metadata) ToWildcardPopulated(logger log.FieldLogger, filePaths []path.Path) (Metadata, error) {
+	debugLogger := logger.WithFields(log.Fields{
 		"package": "tooth",
 		"method":  "Metadata.ToWildcardPopulated",
 	})
@@ -350,12 +894,12 @@ func (m Metadata) ToWildcardPopulated(filePaths []path.Path) (Metadata, error) {
 			continue
 		}
 
-		sourcePathPrefix, err := path.Parse(strings.TrimSuffix(placeItem.Src, "*"))
+		sourcePathPrefix, err := path.ParseRelative(strings.TrimSuffix(placeItem.Src, "*"))
 		if err != nil {
 			return Metadata{}, fmt.Errorf("failed to parse source path prefix\n\t%w", err)
 		}
 
-		destPathPrefix, err := path.Parse(placeItem.Dest)
+		destPathPrefix, err := path.ParseRelative(placeItem.Dest)
 		if err != nil {
 			return Metadata{}, fmt.Errorf("failed to parse destination path prefix\n\t%w", err)
 		}
@@ -368,8 +912,13 @@ func (m Metadata) ToWildcardPopulated(filePaths []path.Path) (Metadata, error) {
 			relFilePath := filePath.TrimPrefix(sourcePathPrefix)
 
 			newPlace = append(newPlace, RawMetadataFilesPlaceItem{
-				Src:  filePath.String(),
-				Dest: destPathPrefix.Join(relFilePath).String(),
+				Src:        filePath.String(),
+				Dest:       destPathPrefix.Join(relFilePath).String(),
+				Config:     placeItem.Config,
+				Mode:       placeItem.Mode,
+				Executable: placeItem.Executable,
+				Tags:       placeItem.Tags,
+				UserData:   placeItem.UserData,
 			})
 
 			debugLogger.Debugf("Populated %v to %v", filePath, destPathPrefix.Join(relFilePath))
@@ -378,27 +927,329 @@ func (m Metadata) ToWildcardPopulated(filePaths []path.Path) (Metadata, error) {
 
 	newRaw.Files.Place = newPlace
 
-	newMetadata := Metadata{newRaw}
+	newMetadata := Metadata{rawMetadata: newRaw, unknownFields: m.unknownFields}
 
 	return newMetadata, nil
 }
 
+// ToPlacementsSkipped removes the items of files.place tagged with any of the given tags, e.g.
+// docs or examples, so that their files are not placed.
+func (m Metadata) ToPlacementsSkipped(logger log.FieldLogger, tags []string) Metadata {
+	debugLogger := logger.WithFields(log.Fields{
+		"package": "tooth",
+		"method":  "Metadata.ToPlacementsSkipped",
+	})
+
+	skippedTagSet := make(map[string]bool)
+	for _, tag := range tags {
+		skippedTagSet[tag] = true
+	}
+
+	newRaw := m.rawMetadata
+
+	newPlace := make([]RawMetadataFilesPlaceItem, 0)
+
+placeLoop:
+	for _, placeItem := range m.rawMetadata.Files.Place {
+		for _, tag := range placeItem.Tags {
+			if skippedTagSet[tag] {
+				debugLogger.Debugf("Skipped %v tagged with %v", placeItem.Dest, tag)
+				continue placeLoop
+			}
+		}
+
+		newPlace = append(newPlace, placeItem)
+	}
+
+	newRaw.Files.Place = newPlace
+
+	return Metadata{rawMetadata: newRaw, unknownFields: m.unknownFields}
+}
+
+// ToDestinationsRemapped moves the destinations of files.place, files.preserve and
+// files.remove from directories of the workspace to others, e.g. to place plugins in a custom
+// plugins directory. Each key of remaps is a directory to move from and each value is the
+// directory to move to, where "." is the workspace itself. If several directories contain a
+// destination, the deepest one applies.
+func (m Metadata) ToDestinationsRemapped(remaps map[string]string) (Metadata, error) {
+	type remap struct {
+		from path.Path
+		to   path.Path
+	}
+
+	remapList := make([]remap, 0, len(remaps))
+	for fromString, toString := range remaps {
+		from, err := ParseWorkspaceDir(fromString)
+		if err != nil {
+			return Metadata{}, fmt.Errorf("failed to parse directory to remap from\n\t%w", err)
+		}
+
+		to, err := ParseWorkspaceDir(toString)
+		if err != nil {
+			return Metadata{}, fmt.Errorf("failed to parse directory to remap to\n\t%w", err)
+		}
+
+		remapList = append(remapList, remap{from: from, to: to})
+	}
+
+	remapDest := func(destString string) (string, error) {
+		dest, err := path.ParseRelative(destString)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse destination path\n\t%w", err)
+		}
+
+		var matched *remap
+		for i, r := range remapList {
+			if !dest.HasPrefix(r.from) {
+				continue
+			}
+
+			if matched == nil || len(r.from.String()) > len(matched.from.String()) {
+				matched = &remapList[i]
+			}
+		}
+
+		if matched == nil {
+			return destString, nil
+		}
+
+		return path.MakeEmpty().Join(matched.to).Join(dest.TrimPrefix(matched.from)).String(), nil
+	}
+
+	newRaw := m.rawMetadata
+
+	newPlace := make([]RawMetadataFilesPlaceItem, 0, len(m.rawMetadata.Files.Place))
+	for _, placeItem := range m.rawMetadata.Files.Place {
+		dest, err := remapDest(placeItem.Dest)
+		if err != nil {
+			return Metadata{}, err
+		}
+
+		placeItem.Dest = dest
+		newPlace = append(newPlace, placeItem)
+	}
+
+	newPreserve := make([]string, 0, len(m.rawMetadata.Files.Preserve))
+	for _, preserveItem := range m.rawMetadata.Files.Preserve {
+		dest, err := remapDest(preserveItem)
+		if err != nil {
+			return Metadata{}, err
+		}
+
+		newPreserve = append(newPreserve, dest)
+	}
+
+	newRemove := make([]string, 0, len(m.rawMetadata.Files.Remove))
+	for _, removeItem := range m.rawMetadata.Files.Remove {
+		dest, err := remapDest(removeItem)
+		if err != nil {
+			return Metadata{}, err
+		}
+
+		newRemove = append(newRemove, dest)
+	}
+
+	newRaw.Files.Place = newPlace
+	newRaw.Files.Preserve = newPreserve
+	newRaw.Files.Remove = newRemove
+
+	return Metadata{rawMetadata: newRaw, unknownFields: m.unknownFields}, nil
+}
+
+// ParseWorkspaceDir parses a directory relative to the workspace, where "." is the workspace
+// itself and thus an empty path. Absolute paths and paths out of the workspace are invalid.
+func ParseWorkspaceDir(dir string) (path.Path, error) {
+	if gopath.Clean(filepath.ToSlash(dir)) == "." {
+		return path.MakeEmpty(), nil
+	}
+
+	if filepath.IsAbs(dir) || strings.HasPrefix(filepath.ToSlash(dir), "/") {
+		return path.Path{}, fmt.Errorf("directory %v is not relative to the workspace", dir)
+	}
+
+	dirPath, err := path.Parse(dir)
+	if err != nil {
+		return path.Path{}, fmt.Errorf("failed to parse directory %v\n\t%w", dir, err)
+	}
+
+	return dirPath, nil
+}
+
 func parseFormatVersion(jsonBytes []byte) (int, error) {
 	jsonData := make(map[string]interface{})
 	err := json.Unmarshal(jsonBytes, &jsonData)
 	if err != nil {
-		return 0, fmt.Errorf("failed to parse json\n\t%w", err)
+		return 0, errcode.Errorf(errcode.MetadataInvalid, "failed to parse json\n\t%w", err)
 	}
 
 	formatVersion, ok := jsonData["format_version"]
 	if !ok {
-		return 0, fmt.Errorf("missing format_version")
+		return 0, errcode.Errorf(errcode.MetadataInvalid, "missing format_version")
 	}
 
 	formatVersionFloat64, ok := formatVersion.(float64)
 	if !ok {
-		return 0, fmt.Errorf("format_version is not an int")
+		return 0, errcode.Errorf(errcode.MetadataInvalid, "format_version is not an int")
 	}
 
 	return int(formatVersionFloat64), nil
 }
+
+// validateAssetSHA256 checks that every asset checksum is a hex-encoded SHA-256 checksum and
+// pins an asset URL. A general asset URL depending on the platform cannot be pinned by a single
+// checksum, so such asset archives have to be pinned in platforms instead.
+func validateAssetSHA256(rawMetadata RawMetadata) error {
+	type pin struct{ assetURL, assetSHA256 string }
+
+	pins := []pin{{rawMetadata.AssetURL, rawMetadata.AssetSHA256}}
+	for _, platformItem := range rawMetadata.Platforms {
+		pins = append(pins, pin{platformItem.AssetURL, platformItem.AssetSHA256})
+	}
+
+	for _, pin := range pins {
+		if pin.assetSHA256 == "" {
+			continue
+		}
+
+		if !assetSHA256Pattern.MatchString(pin.assetSHA256) {
+			return fmt.Errorf("%v is not a hex-encoded SHA-256 checksum", pin.assetSHA256)
+		}
+
+		if pin.assetURL == "" {
+			return fmt.Errorf("checksum %v is set without an asset URL", pin.assetSHA256)
+		}
+	}
+
+	generalAssetURL := expandTemplate(rawMetadata.AssetURL, makeTemplateVariables(rawMetadata.Version, "", ""))
+	if rawMetadata.AssetSHA256 != "" && !isTemplateExpanded(generalAssetURL) {
+		return fmt.Errorf("asset URL %v depends on the platform and cannot be pinned by a single checksum",
+			rawMetadata.AssetURL)
+	}
+
+	return nil
+}
+
+// validateServices checks that the services, including those in platforms, have valid names
+// and commands. The names end up in file names and command lines of the OS.
+func validateServices(rawMetadata RawMetadata) error {
+	services := []*RawMetadataService{rawMetadata.Service}
+	for _, platformItem := range rawMetadata.Platforms {
+		services = append(services, platformItem.Service)
+	}
+
+	for _, service := range services {
+		if service == nil {
+			continue
+		}
+
+		if !serviceNamePattern.MatchString(service.Name) {
+			return fmt.Errorf("invalid name %v", service.Name)
+		}
+
+		if service.Command == "" {
+			return fmt.Errorf("missing command of %v", service.Name)
+		}
+	}
+
+	return nil
+}
+
+// validateHealthChecks checks that the health checks, including those in platforms, declare
+// either a command or an HTTP URL.
+func validateHealthChecks(rawMetadata RawMetadata) error {
+	healthChecks := []*RawMetadataHealthCheck{rawMetadata.HealthCheck}
+	for _, platformItem := range rawMetadata.Platforms {
+		healthChecks = append(healthChecks, platformItem.HealthCheck)
+	}
+
+	for _, healthCheck := range healthChecks {
+		if healthCheck == nil {
+			continue
+		}
+
+		if (healthCheck.Command == "") == (healthCheck.URL == "") {
+			return fmt.Errorf("exactly one of command and url must be set")
+		}
+
+		if healthCheck.URL != "" {
+			healthCheckURL, err := url.Parse(healthCheck.URL)
+			if err != nil {
+				return fmt.Errorf("failed to parse url %v\n\t%w", healthCheck.URL, err)
+			}
+
+			if healthCheckURL.Scheme != "http" && healthCheckURL.Scheme != "https" {
+				return fmt.Errorf("url %v is not an HTTP URL", healthCheck.URL)
+			}
+		}
+
+		if healthCheck.Timeout < 0 {
+			return fmt.Errorf("negative timeout %v", healthCheck.Timeout)
+		}
+	}
+
+	return nil
+}
+
+// collectUnknownFields returns the fields of object not declared by the struct type t, with
+// the unknown fields of declared struct fields nested. Nil is returned if there is none.
+func collectUnknownFields(object *orderedObject, t reflect.Type) *orderedObject {
+	fieldTypes := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+
+		fieldTypes[name] = field.Type
+	}
+
+	unknownFields := newOrderedObject()
+	for _, key := range object.keys {
+		fieldType, ok := fieldTypes[key]
+		if !ok {
+			unknownFields.set(key, object.values[key])
+			continue
+		}
+
+		if fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+
+		nestedObject, ok := object.values[key].(*orderedObject)
+		if fieldType.Kind() != reflect.Struct || !ok {
+			continue
+		}
+
+		if nestedUnknownFields := collectUnknownFields(nestedObject, fieldType); nestedUnknownFields != nil {
+			unknownFields.set(key, nestedUnknownFields)
+		}
+	}
+
+	if len(unknownFields.keys) == 0 {
+		return nil
+	}
+
+	return unknownFields
+}
+
+// mergeUnknownFields adds unknown fields collected by collectUnknownFields to object, after
+// its fields and sorted.
+func mergeUnknownFields(object *orderedObject, unknownFields *orderedObject) {
+	keys := append([]string(nil), unknownFields.keys...)
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		nestedUnknownFields, isNested := unknownFields.values[key].(*orderedObject)
+		nestedObject, isObject := object.values[key].(*orderedObject)
+
+		// Unknown fields of a declared struct field are nested in it.
+		if isNested && isObject {
+			mergeUnknownFields(nestedObject, nestedUnknownFields)
+			continue
+		}
+
+		object.set(key, unknownFields.values[key])
+	}
+}
@@ -0,0 +1,48 @@
+package tooth
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// supportedPlatformPattern matches a supported platform, either a GOOS, e.g. windows, or a GOOS
+// and a GOARCH joined by a hyphen, e.g. linux-amd64, like the platform template variable.
+var supportedPlatformPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)?$`)
+
+// SupportedPlatforms returns the platforms the tooth supports, as declared. Empty if the tooth
+// supports all platforms.
+func (m Metadata) SupportedPlatforms() []string {
+	platforms := make([]string, len(m.rawMetadata.SupportedPlatforms))
+	copy(platforms, m.rawMetadata.SupportedPlatforms)
+
+	return platforms
+}
+
+// SupportsPlatform returns whether the tooth supports a platform. A tooth declaring no supported
+// platforms supports all of them.
+func (m Metadata) SupportsPlatform(goos string, goarch string) bool {
+	if len(m.rawMetadata.SupportedPlatforms) == 0 {
+		return true
+	}
+
+	for _, platform := range m.rawMetadata.SupportedPlatforms {
+		if platform == goos || platform == goos+"-"+goarch {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ---------------------------------------------------------------------
+
+// validateSupportedPlatforms checks that the supported platforms are GOOS or GOOS-GOARCH.
+func validateSupportedPlatforms(rawMetadata RawMetadata) error {
+	for _, platform := range rawMetadata.SupportedPlatforms {
+		if !supportedPlatformPattern.MatchString(platform) {
+			return fmt.Errorf("invalid platform %v, expected GOOS or GOOS-GOARCH, e.g. windows-amd64", platform)
+		}
+	}
+
+	return nil
+}
@@ -2,26 +2,60 @@ package tooth
 
 // Why to split Metadata and RawMetadata? Because we encounter a problem when
 // we want to add a getter with the same name as a field.
+//
+// The JSON schema of tooth.json is generated from RawMetadata. See GenerateJSONSchema for the
+// jsonschema tags.
 type RawMetadata struct {
-	FormatVersion int             `json:"format_version"`
+	FormatVersion int             `json:"format_version" jsonschema:"const=2"`
 	Tooth         string          `json:"tooth"`
 	Version       string          `json:"version"`
 	Info          RawMetadataInfo `json:"info"`
 
-	AssetURL      string              `json:"asset_url,omitempty"`
-	Commands      RawMetadataCommands `json:"commands,omitempty"`
-	Dependencies  map[string]string   `json:"dependencies,omitempty"`
-	Prerequisites map[string]string   `json:"prerequisites,omitempty"`
-	Files         RawMetadataFiles    `json:"files,omitempty"`
+	AssetURL      string                         `json:"asset_url,omitempty"`
+	AssetSHA256   string                         `json:"asset_sha256,omitempty" jsonschema:"pattern=^[0-9a-f]{64}$"`
+	Commands      RawMetadataCommands            `json:"commands,omitempty"`
+	Dependencies  map[string]string              `json:"dependencies,omitempty"`
+	Prerequisites map[string]string              `json:"prerequisites,omitempty"`
+	Files         RawMetadataFiles               `json:"files,omitempty"`
+	Environment   RawMetadataEnvironment         `json:"environment,omitempty" jsonschema:"additionalProperties=false"`
+	Service       *RawMetadataService            `json:"service,omitempty"`
+	HealthCheck   *RawMetadataHealthCheck        `json:"health_check,omitempty"`
+	Build         RawMetadataBuild               `json:"build,omitempty"`
+	Registrations []RawMetadataRegistrationsItem `json:"registrations,omitempty"`
+	Tools         []RawMetadataToolsItem         `json:"tools,omitempty"`
+	Changelog     string                         `json:"changelog,omitempty"`
 
-	Platforms []RawMetadataPlatformsItem `json:"platforms,omitempty"`
+	Features     []string          `json:"features,omitempty" jsonschema:"pattern=^[a-z0-9-]+$"`
+	Capabilities map[string]string `json:"capabilities,omitempty" jsonschema:"propertyNames=^[a-z0-9-]+$"`
+	Provides     map[string]string `json:"provides,omitempty"`
+	Runtimes     map[string]string `json:"runtimes,omitempty" jsonschema:"propertyNames=^[a-z0-9-]+$"`
+
+	SupportedPlatforms []string                   `json:"supported_platforms,omitempty" jsonschema:"pattern=^[a-z0-9]+(-[a-z0-9]+)?$"`
+	Platforms          []RawMetadataPlatformsItem `json:"platforms,omitempty"`
+
+	Deprecated *RawMetadataDeprecated `json:"deprecated,omitempty"`
 }
 
 type RawMetadataInfo struct {
 	Name        string   `json:"name"`
 	Description string   `json:"description"`
 	Author      string   `json:"author"`
-	Tags        []string `json:"tags"`
+	Tags        []string `json:"tags" jsonschema:"pattern=^[a-z0-9-]+$"`
+	AvatarURL   string   `json:"avatar_url,omitempty"`
+	License     string   `json:"license,omitempty"`
+	Homepage    string   `json:"homepage,omitempty"`
+}
+
+type RawMetadataDeprecated struct {
+	Reason      string `json:"reason,omitempty"`
+	Replacement string `json:"replacement,omitempty"`
+}
+
+type RawMetadataService struct {
+	Name        string   `json:"name" jsonschema:"pattern=^[A-Za-z0-9_][A-Za-z0-9_.-]*$"`
+	Description string   `json:"description,omitempty"`
+	Command     string   `json:"command"`
+	Args        []string `json:"args,omitempty"`
 }
 
 type RawMetadataCommands struct {
@@ -31,6 +65,16 @@ type RawMetadataCommands struct {
 	PostUninstall []string `json:"post_uninstall,omitempty"`
 }
 
+type RawMetadataBuild struct {
+	Commands []string `json:"commands,omitempty"`
+	Output   []string `json:"output,omitempty"`
+}
+
+type RawMetadataEnvironment struct {
+	Path      []string          `json:"path,omitempty"`
+	Variables map[string]string `json:"variables,omitempty" jsonschema:"propertyNames=^[A-Za-z_][A-Za-z0-9_]*$"`
+}
+
 type RawMetadataFiles struct {
 	Place    []RawMetadataFilesPlaceItem `json:"place,omitempty"`
 	Preserve []string                    `json:"preserve,omitempty"`
@@ -38,17 +82,48 @@ type RawMetadataFiles struct {
 }
 
 type RawMetadataFilesPlaceItem struct {
-	Src  string `json:"src"`
-	Dest string `json:"dest"`
+	Src        string   `json:"src"`
+	Dest       string   `json:"dest"`
+	Config     string   `json:"config,omitempty" jsonschema:"enum=backup|new|merge"`
+	Mode       string   `json:"mode,omitempty" jsonschema:"pattern=^0?[0-7]{3}$"`
+	Executable bool     `json:"executable,omitempty"`
+	Tags       []string `json:"tags,omitempty" jsonschema:"pattern=^[a-z0-9-]+$"`
+	UserData   bool     `json:"user_data,omitempty"`
+}
+
+type RawMetadataRegistrationsItem struct {
+	File  string      `json:"file"`
+	Key   string      `json:"key,omitempty"`
+	Value interface{} `json:"value"`
+}
+
+type RawMetadataToolsItem struct {
+	Name    string `json:"name"`
+	Command string `json:"command"`
+	Version string `json:"version,omitempty"`
+	Hint    string `json:"hint,omitempty"`
+}
+
+type RawMetadataHealthCheck struct {
+	Command string `json:"command,omitempty"`
+	URL     string `json:"url,omitempty"`
+	Timeout int    `json:"timeout,omitempty"`
 }
 
 type RawMetadataPlatformsItem struct {
 	GOARCH string `json:"goarch,omitempty"`
 	GOOS   string `json:"goos"`
 
-	AssetURL      string              `json:"asset_url,omitempty"`
-	Commands      RawMetadataCommands `json:"commands,omitempty"`
-	Dependencies  map[string]string   `json:"dependencies,omitempty"`
-	Prerequisites map[string]string   `json:"prerequisites,omitempty"`
-	Files         RawMetadataFiles    `json:"files,omitempty"`
+	AssetURL      string                         `json:"asset_url,omitempty"`
+	AssetSHA256   string                         `json:"asset_sha256,omitempty" jsonschema:"pattern=^[0-9a-f]{64}$"`
+	Commands      RawMetadataCommands            `json:"commands,omitempty"`
+	Dependencies  map[string]string              `json:"dependencies,omitempty"`
+	Prerequisites map[string]string              `json:"prerequisites,omitempty"`
+	Files         RawMetadataFiles               `json:"files,omitempty"`
+	Environment   RawMetadataEnvironment         `json:"environment,omitempty" jsonschema:"additionalProperties=false"`
+	Service       *RawMetadataService            `json:"service,omitempty"`
+	HealthCheck   *RawMetadataHealthCheck        `json:"health_check,omitempty"`
+	Build         RawMetadataBuild               `json:"build,omitempty"`
+	Registrations []RawMetadataRegistrationsItem `json:"registrations,omitempty"`
+	Tools         []RawMetadataToolsItem         `json:"tools,omitempty"`
 }
@@ -0,0 +1,79 @@
+package tooth
+
+import (
+	"fmt"
+
+	"github.com/lippkg/lip/internal/path"
+)
+
+// Registration is an entry a tooth adds to a JSON configuration file of the workspace when it
+// is installed, e.g. to enable a plugin or a pack, and removes when it is uninstalled.
+type Registration struct {
+	// File is the configuration file, relative to the workspace.
+	File path.Path
+	// Key is the member of the object in the file to set to Value. Empty if the file holds an
+	// array, to which Value is appended.
+	Key   string
+	Value interface{}
+}
+
+// Registrations returns the entries the tooth adds to configuration files of the workspace.
+func (m Metadata) Registrations() ([]Registration, error) {
+	registrations := make([]Registration, 0, len(m.rawMetadata.Registrations))
+	for _, registrationItem := range m.rawMetadata.Registrations {
+		file, err := parseRegistrationFile(registrationItem.File)
+		if err != nil {
+			return nil, err
+		}
+
+		registrations = append(registrations, Registration{
+			File:  file,
+			Key:   registrationItem.Key,
+			Value: registrationItem.Value,
+		})
+	}
+
+	return registrations, nil
+}
+
+// ---------------------------------------------------------------------
+
+// validateRegistrations checks that the registrations, including those in platforms, declare
+// a value and a file in the workspace. Files with template variables are checked when
+// expanded.
+func validateRegistrations(rawMetadata RawMetadata) error {
+	registrationItems := append([]RawMetadataRegistrationsItem{}, rawMetadata.Registrations...)
+	for _, platformItem := range rawMetadata.Platforms {
+		registrationItems = append(registrationItems, platformItem.Registrations...)
+	}
+
+	for _, registrationItem := range registrationItems {
+		if registrationItem.Value == nil {
+			return fmt.Errorf("no value to register in %v", registrationItem.File)
+		}
+
+		if !isTemplateExpanded(registrationItem.File) {
+			continue
+		}
+
+		if _, err := parseRegistrationFile(registrationItem.File); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parseRegistrationFile parses the configuration file of a registration.
+func parseRegistrationFile(file string) (path.Path, error) {
+	filePath, err := ParseWorkspaceDir(file)
+	if err != nil {
+		return path.Path{}, fmt.Errorf("failed to parse registration file\n\t%w", err)
+	}
+
+	if filePath.IsEmpty() {
+		return path.Path{}, fmt.Errorf("registration file %v is the workspace itself", file)
+	}
+
+	return filePath, nil
+}
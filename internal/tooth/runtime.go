@@ -0,0 +1,74 @@
+package tooth
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/blang/semver/v4"
+)
+
+// Runtimes known to lip, the keys of the runtimes field of tooth.json.
+const (
+	// LeviLaminaRuntime is the LeviLamina mod loader.
+	LeviLaminaRuntime = "levilamina"
+	// BDSRuntime is Minecraft Bedrock Dedicated Server.
+	BDSRuntime = "bds"
+)
+
+// runtimeToothRepoPaths are the teeth of the known runtimes. The version of a runtime in a
+// workspace is the version of its tooth installed there, or provided by an installed tooth.
+var runtimeToothRepoPaths = map[string]string{
+	LeviLaminaRuntime: "github.com/LiteLDev/LeviLamina",
+	BDSRuntime:        "github.com/LiteLDev/bedrock-runtime",
+}
+
+// RuntimeNames returns the names of the known runtimes, sorted.
+func RuntimeNames() []string {
+	names := make([]string, 0, len(runtimeToothRepoPaths))
+	for name := range runtimeToothRepoPaths {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// RuntimeToothRepoPath returns the tooth repo path of a known runtime.
+func RuntimeToothRepoPath(runtime string) (string, bool) {
+	toothRepoPath, ok := runtimeToothRepoPaths[runtime]
+	return toothRepoPath, ok
+}
+
+// RuntimeVersion returns the version of a runtime if the tooth is the runtime or provides it.
+// The second return value is false otherwise.
+func (m Metadata) RuntimeVersion(runtime string) (semver.Version, bool) {
+	toothRepoPath, ok := runtimeToothRepoPaths[runtime]
+	if !ok {
+		return semver.Version{}, false
+	}
+
+	if m.ToothRepoPath() == toothRepoPath {
+		return m.Version(), true
+	}
+
+	version, ok := m.Provides()[toothRepoPath]
+	return version, ok
+}
+
+// ---------------------------------------------------------------------
+
+// validateRuntimes checks that the runtimes are known and their version ranges are valid.
+func validateRuntimes(rawMetadata RawMetadata) error {
+	for runtime, versionRange := range rawMetadata.Runtimes {
+		if _, ok := runtimeToothRepoPaths[runtime]; !ok {
+			return fmt.Errorf("unknown runtime %v, expected one of %v", runtime, strings.Join(RuntimeNames(), ", "))
+		}
+
+		if _, err := semver.ParseRange(versionRange); err != nil {
+			return fmt.Errorf("failed to parse version range %v of %v\n\t%w", versionRange, runtime, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,223 @@
+package tooth
+
+//go:generate go run ./schemagen ../../schemas/tooth.v2.schema.json
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// schemaNode is a node of a JSON schema. Fields are in the order they are written.
+type schemaNode struct {
+	Schema               string           `json:"$schema,omitempty"`
+	Title                string           `json:"title,omitempty"`
+	Type                 string           `json:"type,omitempty"`
+	Const                json.RawMessage  `json:"const,omitempty"`
+	Enum                 []string         `json:"enum,omitempty"`
+	Pattern              string           `json:"pattern,omitempty"`
+	Items                *schemaNode      `json:"items,omitempty"`
+	Properties           schemaProperties `json:"properties,omitempty"`
+	Required             []string         `json:"required,omitempty"`
+	AdditionalProperties interface{}      `json:"additionalProperties,omitempty"`
+	PropertyNames        *schemaNode      `json:"propertyNames,omitempty"`
+}
+
+// schemaProperty is a property of an object in a JSON schema.
+type schemaProperty struct {
+	name string
+	node *schemaNode
+}
+
+// schemaProperties are the properties of an object in a JSON schema, kept in the order of the
+// fields of the struct.
+type schemaProperties []schemaProperty
+
+func (p schemaProperties) MarshalJSON() ([]byte, error) {
+	buffer := &bytes.Buffer{}
+	buffer.WriteByte('{')
+	for i, property := range p {
+		if i != 0 {
+			buffer.WriteByte(',')
+		}
+
+		nameBytes, err := json.Marshal(property.name)
+		if err != nil {
+			return nil, err
+		}
+
+		nodeBytes, err := json.Marshal(property.node)
+		if err != nil {
+			return nil, err
+		}
+
+		buffer.Write(nameBytes)
+		buffer.WriteByte(':')
+		buffer.Write(nodeBytes)
+	}
+	buffer.WriteByte('}')
+
+	return buffer.Bytes(), nil
+}
+
+// metadataJSONSchema is the JSON schema of tooth.json generated from RawMetadata.
+var metadataJSONSchema = string(MustGenerateJSONSchema())
+
+// GenerateJSONSchema generates the JSON schema of tooth.json from RawMetadata, indented with
+// tabs. Fields without omitempty are required. Further constraints are declared by jsonschema
+// tags of the fields, as comma-separated key=value pairs:
+//
+//   - const: the value of an integer field.
+//   - enum: the allowed values of a string field, separated by "|".
+//   - pattern: the pattern of a string field, or of the items of a string array field.
+//   - propertyNames: the pattern of the keys of a map field.
+//   - additionalProperties=false: disallow unknown properties of a struct field.
+func GenerateJSONSchema() ([]byte, error) {
+	root, err := generateSchemaNode(reflect.TypeOf(RawMetadata{}), "")
+	if err != nil {
+		return nil, err
+	}
+
+	root.Schema = "https://json-schema.org/draft-07/schema#"
+	root.Title = "tooth.json"
+
+	jsonBytes, err := json.Marshal(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal JSON schema\n\t%w", err)
+	}
+
+	indented := &bytes.Buffer{}
+	if err := json.Indent(indented, jsonBytes, "", "\t"); err != nil {
+		return nil, fmt.Errorf("failed to indent JSON schema\n\t%w", err)
+	}
+	indented.WriteByte('\n')
+
+	return indented.Bytes(), nil
+}
+
+// MustGenerateJSONSchema is like GenerateJSONSchema but panics if the schema cannot be
+// generated, which only happens if RawMetadata has an unsupported field.
+func MustGenerateJSONSchema() []byte {
+	jsonBytes, err := GenerateJSONSchema()
+	if err != nil {
+		panic(err)
+	}
+
+	return jsonBytes
+}
+
+// ---------------------------------------------------------------------
+
+// generateSchemaNode generates the JSON schema node of a type with the jsonschema tag of its
+// field.
+func generateSchemaNode(t reflect.Type, tag string) (*schemaNode, error) {
+	constraints, err := parseSchemaTag(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	node := &schemaNode{}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return generateSchemaNode(t.Elem(), tag)
+
+	case reflect.String:
+		node.Type = "string"
+		node.Pattern = constraints["pattern"]
+		if enum, ok := constraints["enum"]; ok {
+			node.Enum = strings.Split(enum, "|")
+		}
+
+	case reflect.Int:
+		node.Type = "integer"
+		if value, ok := constraints["const"]; ok {
+			node.Const = json.RawMessage(value)
+		}
+
+	case reflect.Bool:
+		node.Type = "boolean"
+
+	case reflect.Interface:
+		// Any JSON value is allowed.
+
+	case reflect.Slice:
+		items, err := generateSchemaNode(t.Elem(), "")
+		if err != nil {
+			return nil, err
+		}
+
+		// A pattern of a string array applies to its items.
+		items.Pattern = constraints["pattern"]
+
+		node.Type = "array"
+		node.Items = items
+
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("unsupported map key type %v", t.Key())
+		}
+
+		values, err := generateSchemaNode(t.Elem(), "")
+		if err != nil {
+			return nil, err
+		}
+
+		node.Type = "object"
+		node.AdditionalProperties = values
+		if pattern, ok := constraints["propertyNames"]; ok {
+			node.PropertyNames = &schemaNode{Pattern: pattern}
+		}
+
+	case reflect.Struct:
+		node.Type = "object"
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+
+			name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "" || name == "-" {
+				continue
+			}
+
+			fieldNode, err := generateSchemaNode(field.Type, field.Tag.Get("jsonschema"))
+			if err != nil {
+				return nil, fmt.Errorf("failed to generate schema of field %v\n\t%w", field.Name, err)
+			}
+
+			node.Properties = append(node.Properties, schemaProperty{name: name, node: fieldNode})
+			if !strings.Contains(options, "omitempty") {
+				node.Required = append(node.Required, name)
+			}
+		}
+
+		if constraints["additionalProperties"] == "false" {
+			node.AdditionalProperties = false
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported type %v", t)
+	}
+
+	return node, nil
+}
+
+// parseSchemaTag parses a jsonschema tag into its key=value pairs.
+func parseSchemaTag(tag string) (map[string]string, error) {
+	constraints := make(map[string]string)
+	if tag == "" {
+		return constraints, nil
+	}
+
+	for _, pair := range strings.Split(tag, ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid jsonschema tag %v", tag)
+		}
+
+		constraints[key] = value
+	}
+
+	return constraints, nil
+}
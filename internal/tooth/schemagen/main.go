@@ -0,0 +1,22 @@
+// Command schemagen writes the JSON schema of tooth.json generated from tooth.RawMetadata to
+// the given path. It is run by go generate in the tooth package.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/lippkg/lip/internal/tooth"
+)
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: schemagen <output path>")
+		os.Exit(2)
+	}
+
+	if err := os.WriteFile(os.Args[1], tooth.MustGenerateJSONSchema(), 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write JSON schema\n\t%v\n", err)
+		os.Exit(1)
+	}
+}
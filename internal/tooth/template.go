@@ -0,0 +1,216 @@
+package tooth
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// templateVariablePattern matches a variable in metadata templates, e.g. {{version}},
+// {{env.SERVER_DIR}} or {{root.plugins}}.
+var templateVariablePattern = regexp.MustCompile(`\{\{\s*([A-Za-z_]+(?:\.[A-Za-z_][A-Za-z0-9_]*)?)\s*\}\}`)
+
+// envTemplateVariablePrefix is the prefix of the names of the template variables taken from the
+// env file of the workspace.
+const envTemplateVariablePrefix = "env."
+
+var (
+	envVariablesMu sync.RWMutex
+	envVariables   = make(map[string]string)
+)
+
+// templateVariableNames are the names of the variables expanded in asset URLs and file paths
+// of metadata when converting to platform-specific:
+//
+//   - version: the version of the tooth, e.g. 1.0.0.
+//   - goos: the operating system, e.g. windows.
+//   - goarch: the architecture, e.g. amd64.
+//   - platform: the operating system and the architecture joined by a hyphen, e.g.
+//     windows-amd64.
+//
+// Besides, env.NAME is the variable NAME of the env file of the workspace, see SetEnvVariables,
+// and root.NAME is the directory of the logical placement root NAME, see SetLayoutRoots.
+var templateVariableNames = []string{"version", "goos", "goarch", "platform"}
+
+// SetEnvVariables sets the variables of the env file of the workspace, expanded in templates as
+// env.NAME.
+func SetEnvVariables(variables map[string]string) {
+	envVariablesMu.Lock()
+	defer envVariablesMu.Unlock()
+
+	envVariables = variables
+}
+
+// makeTemplateVariables returns the values of the template variables. The values depending on
+// an empty goos or goarch are left out, and the variables are kept unexpanded.
+func makeTemplateVariables(version string, goos string, goarch string) map[string]string {
+	variables := map[string]string{
+		"version": version,
+	}
+
+	envVariablesMu.RLock()
+	for name, value := range envVariables {
+		variables[envTemplateVariablePrefix+name] = value
+	}
+	envVariablesMu.RUnlock()
+
+	layoutRootsMu.RLock()
+	for name, dir := range layoutRoots {
+		variables[rootTemplateVariablePrefix+name] = dir
+	}
+	layoutRootsMu.RUnlock()
+
+	if goos != "" {
+		variables["goos"] = goos
+	}
+
+	if goarch != "" {
+		variables["goarch"] = goarch
+	}
+
+	if goos != "" && goarch != "" {
+		variables["platform"] = goos + "-" + goarch
+	}
+
+	return variables
+}
+
+// expandTemplate replaces the variables in template with their values. Variables without a
+// value are kept as is.
+func expandTemplate(template string, variables map[string]string) string {
+	return templateVariablePattern.ReplaceAllStringFunc(template, func(match string) string {
+		name := templateVariablePattern.FindStringSubmatch(match)[1]
+
+		value, ok := variables[name]
+		if !ok {
+			return match
+		}
+
+		return value
+	})
+}
+
+// isTemplateExpanded returns whether template has no variables left.
+func isTemplateExpanded(template string) bool {
+	return !templateVariablePattern.MatchString(template)
+}
+
+// validateTemplates checks that the templates in raw metadata refer to known variables only.
+func validateTemplates(rawMetadata RawMetadata) error {
+	templates := []string{rawMetadata.AssetURL}
+	templates = append(templates, filesTemplates(rawMetadata.Files)...)
+	templates = append(templates, registrationsTemplates(rawMetadata.Registrations)...)
+	for _, platformItem := range rawMetadata.Platforms {
+		templates = append(templates, platformItem.AssetURL)
+		templates = append(templates, filesTemplates(platformItem.Files)...)
+		templates = append(templates, registrationsTemplates(platformItem.Registrations)...)
+	}
+
+	for _, template := range templates {
+		for _, submatch := range templateVariablePattern.FindAllStringSubmatch(template, -1) {
+			if !isTemplateVariableName(submatch[1]) {
+				return fmt.Errorf("unknown variable %v in %v", submatch[0], template)
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkWorkspaceTemplatesExpanded checks that the env file of the workspace sets the env.NAME
+// variables in the templates, and its layout the root.NAME variables, which are expanded
+// already.
+func checkWorkspaceTemplatesExpanded(templates []string) error {
+	for _, template := range templates {
+		for _, submatch := range templateVariablePattern.FindAllStringSubmatch(template, -1) {
+			switch {
+			case strings.HasPrefix(submatch[1], envTemplateVariablePrefix):
+				return fmt.Errorf("variable %v in %v is not set in the env file of the workspace", submatch[0],
+					template)
+
+			case strings.HasPrefix(submatch[1], rootTemplateVariablePrefix):
+				return fmt.Errorf("variable %v in %v is not set by the layout of the workspace", submatch[0],
+					template)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ---------------------------------------------------------------------
+
+// expandFilesTemplates returns a copy of files with the variables in all paths expanded.
+func expandFilesTemplates(files RawMetadataFiles, variables map[string]string) RawMetadataFiles {
+	expanded := RawMetadataFiles{}
+
+	for _, placeItem := range files.Place {
+		placeItem.Src = expandTemplate(placeItem.Src, variables)
+		placeItem.Dest = expandTemplate(placeItem.Dest, variables)
+		expanded.Place = append(expanded.Place, placeItem)
+	}
+
+	for _, preserveItem := range files.Preserve {
+		expanded.Preserve = append(expanded.Preserve, expandTemplate(preserveItem, variables))
+	}
+
+	for _, removeItem := range files.Remove {
+		expanded.Remove = append(expanded.Remove, expandTemplate(removeItem, variables))
+	}
+
+	return expanded
+}
+
+// filesTemplates returns all paths in files.
+func filesTemplates(files RawMetadataFiles) []string {
+	templates := make([]string, 0)
+	for _, placeItem := range files.Place {
+		templates = append(templates, placeItem.Src, placeItem.Dest)
+	}
+	templates = append(templates, files.Preserve...)
+	templates = append(templates, files.Remove...)
+
+	return templates
+}
+
+// expandRegistrationsTemplates returns a copy of registrations with the variables in all files
+// expanded.
+func expandRegistrationsTemplates(registrations []RawMetadataRegistrationsItem,
+	variables map[string]string) []RawMetadataRegistrationsItem {
+	expanded := make([]RawMetadataRegistrationsItem, 0, len(registrations))
+	for _, registrationItem := range registrations {
+		registrationItem.File = expandTemplate(registrationItem.File, variables)
+		expanded = append(expanded, registrationItem)
+	}
+
+	return expanded
+}
+
+// registrationsTemplates returns all files in registrations.
+func registrationsTemplates(registrations []RawMetadataRegistrationsItem) []string {
+	templates := make([]string, 0, len(registrations))
+	for _, registrationItem := range registrations {
+		templates = append(templates, registrationItem.File)
+	}
+
+	return templates
+}
+
+func isTemplateVariableName(name string) bool {
+	if strings.HasPrefix(name, envTemplateVariablePrefix) {
+		return true
+	}
+
+	if strings.HasPrefix(name, rootTemplateVariablePrefix) {
+		return IsRootName(strings.TrimPrefix(name, rootTemplateVariablePrefix))
+	}
+
+	for _, variableName := range templateVariableNames {
+		if name == variableName {
+			return true
+		}
+	}
+
+	return false
+}
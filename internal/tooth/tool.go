@@ -0,0 +1,76 @@
+package tooth
+
+import (
+	"fmt"
+
+	"github.com/blang/semver/v4"
+)
+
+// Tool is a program the tooth requires on the host, e.g. a .NET runtime or Java, which lip
+// cannot install. It is detected by running a command.
+type Tool struct {
+	// Name is the name of the tool shown to the user, e.g. .NET Runtime.
+	Name string
+	// Command is run in the shell to detect the tool. The tool is missing if it fails, and its
+	// output contains the versions of the tool.
+	Command string
+	// Version is the range of the versions of the tool required. Nil if any version does.
+	Version semver.Range
+	// VersionAsString is Version as declared. Empty if any version does.
+	VersionAsString string
+	// Hint is how to get the tool if it is missing, e.g. a download URL.
+	Hint string
+}
+
+// Tools returns the programs the tooth requires on the host.
+func (m Metadata) Tools() []Tool {
+	tools := make([]Tool, 0, len(m.rawMetadata.Tools))
+	for _, toolItem := range m.rawMetadata.Tools {
+		tool := Tool{
+			Name:            toolItem.Name,
+			Command:         toolItem.Command,
+			VersionAsString: toolItem.Version,
+			Hint:            toolItem.Hint,
+		}
+
+		// Version ranges are validated when the metadata is made.
+		if toolItem.Version != "" {
+			tool.Version = semver.MustParseRange(toolItem.Version)
+		}
+
+		tools = append(tools, tool)
+	}
+
+	return tools
+}
+
+// ---------------------------------------------------------------------
+
+// validateTools checks that the tools, including those in platforms, declare a name and a
+// command, and that their version ranges are valid.
+func validateTools(rawMetadata RawMetadata) error {
+	toolItems := append([]RawMetadataToolsItem{}, rawMetadata.Tools...)
+	for _, platformItem := range rawMetadata.Platforms {
+		toolItems = append(toolItems, platformItem.Tools...)
+	}
+
+	for _, toolItem := range toolItems {
+		if toolItem.Name == "" {
+			return fmt.Errorf("a tool has no name")
+		}
+
+		if toolItem.Command == "" {
+			return fmt.Errorf("tool %v has no command", toolItem.Name)
+		}
+
+		if toolItem.Version == "" {
+			continue
+		}
+
+		if _, err := semver.ParseRange(toolItem.Version); err != nil {
+			return fmt.Errorf("failed to parse version range %v of %v\n\t%w", toolItem.Version, toolItem.Name, err)
+		}
+	}
+
+	return nil
+}
@@ -4,53 +4,43 @@ import (
 	"bufio"
 	"bytes"
 	"fmt"
-	"net/url"
-	"os"
-	"path/filepath"
 	"strings"
 
 	"github.com/blang/semver/v4"
 	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/githubrelease"
+	"github.com/lippkg/lip/internal/i18n"
 	"github.com/lippkg/lip/internal/network"
-	"github.com/lippkg/lip/internal/path"
-
-	"golang.org/x/mod/module"
+	"github.com/lippkg/lip/internal/oci"
+	"github.com/lippkg/lip/internal/registry"
+	"github.com/lippkg/lip/internal/resolution"
+	"github.com/lippkg/lip/internal/statedb"
+	"github.com/lippkg/lip/internal/vendoring"
 )
 
 // GetAllMetadata lists all installed tooth metadata.
 func GetAllMetadata(ctx *context.Context) ([]Metadata, error) {
 	metadataList := make([]Metadata, 0)
 
-	metadataDir, err := ctx.MetadataDir()
+	db, err := statedb.Open(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get metadata directory\n\t%w", err)
+		return nil, fmt.Errorf("failed to open state database\n\t%w", err)
 	}
 
-	filePathStrings, err := filepath.Glob(filepath.Join(metadataDir.LocalString(), "*.json"))
-	if err != nil {
-		return nil, fmt.Errorf("failed to list metadata files\n\t%w", err)
-	}
-
-	for _, filePathString := range filePathStrings {
-		filePath, err := path.Parse(filePathString)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse metadata file path\n\t%w", err)
-		}
-
-		jsonBytes, err := os.ReadFile(filePath.LocalString())
-		if err != nil {
-			return nil, fmt.Errorf("failed to read metadata file\n\t%w", err)
+	for _, tooth := range db.List() {
+		if tooth.Metadata == nil {
+			continue
 		}
 
-		metadata, err := MakeMetadata(jsonBytes)
+		metadata, err := MakeMetadata(tooth.Metadata)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse metadata file\n\t%w", err)
+			return nil, fmt.Errorf("failed to parse metadata of %v\n\t%w", tooth.ToothRepoPath, err)
 		}
 
-		// Check if the metadata file name matches the tooth repo path in the metadata.
-		expectedFileName := fmt.Sprintf("%v.json", url.QueryEscape(metadata.ToothRepoPath()))
-		if filePath.Base() != expectedFileName {
-			return nil, fmt.Errorf("metadata file name does not match: %v", filePath.LocalString())
+		// Check if the metadata is kept under the tooth repo path in the metadata.
+		if metadata.ToothRepoPath() != tooth.ToothRepoPath {
+			return nil, fmt.Errorf("metadata of %v is of another tooth: %v", tooth.ToothRepoPath,
+				metadata.ToothRepoPath())
 		}
 
 		metadataList = append(metadataList, metadata)
@@ -67,33 +57,19 @@ func GetAvailableVersions(ctx *context.Context, toothRepoPath string) (semver.Ve
 		return nil, fmt.Errorf("invalid repository path %v", toothRepoPath)
 	}
 
-	goModuleProxyURL, err := ctx.GoModuleProxyURL()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get go module proxy URL\n\t%w", err)
-	}
-
-	versionURL, err := network.GenerateGoModuleVersionListURL(toothRepoPath, goModuleProxyURL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate version list URL\n\t%w", err)
-	}
-
-	proxyURL, err := ctx.ProxyURL()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get proxy URL\n\t%w", err)
-	}
+	versionStrings, ok := resolution.GetVersions(ctx, toothRepoPath)
+	if !ok {
+		fetchedVersionStrings, err := fetchVersionStrings(ctx, toothRepoPath)
+		if err != nil {
+			return nil, err
+		}
 
-	content, err := network.GetContent(versionURL, proxyURL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch version list\n\t%w", err)
+		versionStrings = fetchedVersionStrings
+		resolution.SetVersions(ctx, toothRepoPath, versionStrings)
 	}
 
-	reader := bytes.NewReader(content)
-
-	// Each line is a version.
 	versionList := make(semver.Versions, 0)
-	scanner := bufio.NewScanner(reader)
-	for scanner.Scan() {
-		versionString := scanner.Text()
+	for _, versionString := range versionStrings {
 		versionString = strings.TrimPrefix(versionString, "v")
 		versionString = strings.TrimSuffix(versionString, "+incompatible")
 		version, err := semver.Parse(versionString)
@@ -116,44 +92,18 @@ func GetLatestVersion(ctx *context.Context,
 	return GetLatestVersionInVersionRange(ctx, toothRepoPath, versionRange)
 }
 
-// GetLatestVersionInVersionRange returns the latest version in a version range.
+// GetLatestVersionInVersionRange returns the latest version in a version range. Stable
+// versions are preferred over pre-release versions.
 func GetLatestVersionInVersionRange(ctx *context.Context,
 	toothRepoPath string, versionRange semver.Range) (semver.Version, error) {
+	return getLatestVersionInVersionRange(ctx, toothRepoPath, versionRange, true)
+}
 
-	availableVersions, err := GetAvailableVersions(ctx, toothRepoPath)
-	if err != nil {
-		return semver.Version{}, fmt.Errorf(
-			"failed to get available version list\n\t%w", err)
-	}
-
-	// Filter versions that satisfy the version range.
-	filteredVersions := make(semver.Versions, 0)
-	for _, version := range availableVersions {
-		if versionRange(version) {
-			filteredVersions = append(filteredVersions, version)
-		}
-	}
-
-	stableVersions := make(semver.Versions, 0)
-	for _, version := range filteredVersions {
-		if len(version.Pre) == 0 {
-			stableVersions = append(stableVersions, version)
-		}
-	}
-
-	semver.Sort(stableVersions)
-
-	if len(stableVersions) >= 1 {
-		return stableVersions[len(stableVersions)-1], nil
-	}
-
-	semver.Sort(filteredVersions)
-
-	if len(filteredVersions) >= 1 {
-		return filteredVersions[len(filteredVersions)-1], nil
-	}
-
-	return semver.Version{}, fmt.Errorf("no available version found")
+// GetLatestVersionInVersionRangeIncludingPrerelease returns the latest version in a version
+// range, whether it is a pre-release version or not.
+func GetLatestVersionInVersionRangeIncludingPrerelease(ctx *context.Context,
+	toothRepoPath string, versionRange semver.Range) (semver.Version, error) {
+	return getLatestVersionInVersionRange(ctx, toothRepoPath, versionRange, false)
 }
 
 // GetMetadata finds the installed tooth metadata.
@@ -194,10 +144,140 @@ func IsInstalled(ctx *context.Context, toothRepoPath string) (bool, error) {
 	return false, nil
 }
 
-// IsValidToothRepoPath checks if the tooth repository path is valid.
+// IsValidToothRepoPath checks if the tooth repository path is valid. Hosts other than GitHub,
+// ports and nested groups are allowed, e.g. gitlab.com/group/subgroup/repo.
 func IsValidToothRepoPath(toothRepoPath string) bool {
-	if err := module.CheckPath(toothRepoPath); err != nil {
+	if err := network.CheckToothRepoPath(toothRepoPath); err != nil {
 		return false
 	}
 	return true
 }
+
+// ---------------------------------------------------------------------
+
+// fetchVersionStrings fetches the version list of a tooth repository from the Go module
+// proxy, one version per line, or from GitHub Releases or an OCI registry if it is the tooth
+// source. In vendor mode, the only version is the vendored one.
+func fetchVersionStrings(ctx *context.Context, toothRepoPath string) ([]string, error) {
+	if ctx.Vendor() {
+		return vendoring.GetVersionStrings(ctx, toothRepoPath)
+	}
+
+	isGitHubReleasesUsed, err := githubrelease.IsUsed(ctx, toothRepoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if isGitHubReleasesUsed {
+		return githubrelease.GetVersionStrings(ctx, toothRepoPath)
+	}
+
+	isOCIUsed, err := oci.IsUsed(ctx, toothRepoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if isOCIUsed {
+		return oci.GetVersionStrings(ctx, toothRepoPath)
+	}
+
+	goModuleProxyURL, err := ctx.GoModuleProxyURL()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get go module proxy URL\n\t%w", err)
+	}
+
+	// Teeth in subdirectories of a repository share the versions of the repository.
+	goModulePath, _ := network.SplitSubtooth(toothRepoPath)
+
+	versionURL, err := network.GenerateGoModuleVersionListURL(goModulePath, goModuleProxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate version list URL\n\t%w", err)
+	}
+
+	proxyURL, err := ctx.ProxyURL()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get proxy URL\n\t%w", err)
+	}
+
+	cacheDir, err := ctx.CacheDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cache directory\n\t%w", err)
+	}
+
+	var content []byte
+	if ctx.Offline() {
+		content, err = ctx.Network().GetCachedContent(versionURL, cacheDir)
+	} else {
+		content, err = ctx.Network().GetContentWithRevalidation(ctx.GoContext(), versionURL, proxyURL, nil,
+			ctx.RetryPolicy(), cacheDir)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch version list\n\t%w", err)
+	}
+
+	versionStrings := make([]string, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		versionStrings = append(versionStrings, scanner.Text())
+	}
+
+	return versionStrings, nil
+}
+
+// getLatestVersionInVersionRange returns the latest version in a version range. If
+// preferStable is set, pre-release versions are only chosen if there is no stable version.
+func getLatestVersionInVersionRange(ctx *context.Context, toothRepoPath string,
+	versionRange semver.Range, preferStable bool) (semver.Version, error) {
+	availableVersions, err := GetAvailableVersions(ctx, toothRepoPath)
+	if err != nil {
+		return semver.Version{}, fmt.Errorf(
+			"failed to get available version list\n\t%w", err)
+	}
+
+	// Yanked versions are skipped for new resolutions unless explicitly allowed. Failing to
+	// reach the registry should not block installation.
+	yankedVersions := make(map[string]string)
+	if !ctx.AllowYanked() {
+		versions, err := registry.GetYankedVersions(ctx, toothRepoPath)
+		if err != nil {
+			ctx.Logger().Warnf(i18n.T("Failed to look up yanked versions of %v, assuming none\n\t%v"), toothRepoPath, err)
+		} else {
+			yankedVersions = versions
+		}
+	}
+
+	// Filter versions that satisfy the version range.
+	filteredVersions := make(semver.Versions, 0)
+	for _, version := range availableVersions {
+		if _, isYanked := yankedVersions[version.String()]; isYanked {
+			continue
+		}
+
+		if versionRange(version) {
+			filteredVersions = append(filteredVersions, version)
+		}
+	}
+
+	if preferStable {
+		stableVersions := make(semver.Versions, 0)
+		for _, version := range filteredVersions {
+			if len(version.Pre) == 0 {
+				stableVersions = append(stableVersions, version)
+			}
+		}
+
+		semver.Sort(stableVersions)
+
+		if len(stableVersions) >= 1 {
+			return stableVersions[len(stableVersions)-1], nil
+		}
+	}
+
+	semver.Sort(filteredVersions)
+
+	if len(filteredVersions) >= 1 {
+		return filteredVersions[len(filteredVersions)-1], nil
+	}
+
+	return semver.Version{}, fmt.Errorf("no available version found")
+}
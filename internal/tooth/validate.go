@@ -0,0 +1,172 @@
+package tooth
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// ValidationError is a violation of the JSON schema of tooth.json.
+type ValidationError struct {
+	// Field is the dotted path of the invalid value, e.g. info.tags.0. It is (root) for the
+	// whole document.
+	Field string
+	// Line and Column are the 1-based position of the invalid value in the document. Column
+	// counts characters, not bytes.
+	Line   int
+	Column int
+	// Description is what is wrong with the value.
+	Description string
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("%v:%v: %v: %v", e.Line, e.Column, e.Field, e.Description)
+}
+
+// fieldDelimiter separates the segments of the paths of values when locating them. Keys of
+// tooth.json may contain dots, e.g. tooth repo paths in dependencies.
+const fieldDelimiter = "\x00"
+
+// rootField is the first segment of the paths of values, named as in gojsonschema.
+const rootField = "(root)"
+
+// Validate validates jsonBytes against the JSON schema of tooth.json and returns the
+// violations, ordered by position. If jsonBytes is not valid JSON, the only violation is the
+// syntax error. The error is returned only if the validation cannot be performed.
+func Validate(jsonBytes []byte) ([]ValidationError, error) {
+	// Unmarshal reports syntax errors with their offsets, including trailing data.
+	var document interface{}
+	if err := json.Unmarshal(jsonBytes, &document); err != nil {
+		var syntaxErr *json.SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			return nil, fmt.Errorf("failed to parse JSON\n\t%w", err)
+		}
+
+		line, column := lineAndColumn(jsonBytes, syntaxErr.Offset)
+		return []ValidationError{{
+			Field:       rootField,
+			Line:        line,
+			Column:      column,
+			Description: syntaxErr.Error(),
+		}}, nil
+	}
+
+	positions, err := locateValues(jsonBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate values\n\t%w", err)
+	}
+
+	schemaLoader := gojsonschema.NewStringLoader(metadataJSONSchema)
+	documentLoader := gojsonschema.NewBytesLoader(jsonBytes)
+
+	validationResult, err := gojsonschema.Validate(schemaLoader, documentLoader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to validate against JSON schema\n\t%w", err)
+	}
+
+	validationErrors := make([]ValidationError, 0)
+	for _, resultError := range validationResult.Errors() {
+		// Violations inside a value without a known position, which should not happen, are
+		// reported at the beginning of the document.
+		line, column := lineAndColumn(jsonBytes, positions[resultError.Context().String(fieldDelimiter)])
+
+		validationErrors = append(validationErrors, ValidationError{
+			Field:       resultError.Field(),
+			Line:        line,
+			Column:      column,
+			Description: resultError.Description(),
+		})
+	}
+
+	sort.SliceStable(validationErrors, func(i, j int) bool {
+		if validationErrors[i].Line != validationErrors[j].Line {
+			return validationErrors[i].Line < validationErrors[j].Line
+		}
+		return validationErrors[i].Column < validationErrors[j].Column
+	})
+
+	return validationErrors, nil
+}
+
+// ---------------------------------------------------------------------
+
+// locateValues returns the byte offsets of all values in jsonBytes, which must be valid JSON,
+// keyed by their paths joined with fieldDelimiter.
+func locateValues(jsonBytes []byte) (map[string]int64, error) {
+	positions := make(map[string]int64)
+
+	decoder := json.NewDecoder(bytes.NewReader(jsonBytes))
+	if err := locateValue(decoder, jsonBytes, []string{rootField}, positions); err != nil {
+		return nil, err
+	}
+
+	return positions, nil
+}
+
+// locateValue records the offset of the next value read by decoder and of all values nested in
+// it.
+func locateValue(decoder *json.Decoder, jsonBytes []byte, fields []string, positions map[string]int64) error {
+	// The offset of the decoder is right after the previous token, so separators are skipped.
+	offset := decoder.InputOffset()
+	for offset < int64(len(jsonBytes)) && strings.IndexByte(" \t\r\n:,", jsonBytes[offset]) != -1 {
+		offset++
+	}
+	positions[strings.Join(fields, fieldDelimiter)] = offset
+
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+
+	switch token {
+	case json.Delim('{'):
+		for decoder.More() {
+			keyToken, err := decoder.Token()
+			if err != nil {
+				return err
+			}
+
+			key := keyToken.(string)
+			if err := locateValue(decoder, jsonBytes, append(fields[:len(fields):len(fields)], key), positions); err != nil {
+				return err
+			}
+		}
+
+	case json.Delim('['):
+		for i := 0; decoder.More(); i++ {
+			if err := locateValue(decoder, jsonBytes, append(fields[:len(fields):len(fields)], strconv.Itoa(i)), positions); err != nil {
+				return err
+			}
+		}
+
+	default:
+		return nil
+	}
+
+	// Read the closing delimiter.
+	if _, err := decoder.Token(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// lineAndColumn converts a byte offset in jsonBytes to a 1-based line and column.
+func lineAndColumn(jsonBytes []byte, offset int64) (int, int) {
+	if offset > int64(len(jsonBytes)) {
+		offset = int64(len(jsonBytes))
+	}
+
+	before := jsonBytes[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := utf8.RuneCount(before[bytes.LastIndexByte(before, '\n')+1:]) + 1
+
+	return line, column
+}
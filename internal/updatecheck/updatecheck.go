@@ -0,0 +1,198 @@
+package updatecheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/selfupdate"
+	"github.com/lippkg/lip/internal/tooth"
+	log "github.com/sirupsen/logrus"
+)
+
+// waitTimeout is how long lip waits for the update check after a command, so that a slow
+// network never holds up the command for long. A check not done by then is dropped, and runs
+// again after the next command.
+const waitTimeout = 5 * time.Second
+
+// State is the state of the automatic update check.
+type State struct {
+	LastCheckedAt time.Time `json:"last_checked_at"`
+}
+
+// Update is an update of lip or an installed tooth.
+type Update struct {
+	ToothRepoPath  string
+	CurrentVersion semver.Version
+	LatestVersion  semver.Version
+}
+
+// CheckAndNotify checks for updates of lip and the installed teeth if the check is due, and
+// shows them. It is run after a command, and gives up silently after waitTimeout or on errors,
+// which must never fail the command.
+func CheckAndNotify(ctx *context.Context) {
+	debugLogger := ctx.Logger().WithFields(log.Fields{
+		"package": "updatecheck",
+		"method":  "CheckAndNotify",
+	})
+
+	due, err := isDue(ctx)
+	if err != nil {
+		debugLogger.Debugf("Cannot check if update check is due: %v", err)
+		return
+	} else if !due {
+		return
+	}
+
+	// Record the check first, so that a check failing or timing out is not retried after
+	// every command.
+	if err := saveState(ctx, State{LastCheckedAt: ctx.Clock().Now()}); err != nil {
+		debugLogger.Debugf("Cannot save update check state: %v", err)
+		return
+	}
+
+	done := make(chan []Update, 1)
+	go func() {
+		done <- findUpdates(ctx)
+	}()
+
+	select {
+	case updates := <-done:
+		notify(ctx, updates)
+	case <-time.After(waitTimeout):
+		debugLogger.Debugf("Update check timed out after %v", waitTimeout)
+	}
+}
+
+// ---------------------------------------------------------------------
+
+// isDue returns whether an automatic update check should run, i.e. it is enabled, lip is not
+// offline, and the last check is older than UpdateCheckHours.
+func isDue(ctx *context.Context) (bool, error) {
+	if ctx.UpdateCheckInterval() <= 0 || ctx.Offline() {
+		return false, nil
+	}
+
+	state, err := loadState(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	return ctx.Clock().Now().Sub(state.LastCheckedAt) >= ctx.UpdateCheckInterval(), nil
+}
+
+// findUpdates finds updates of lip and the installed teeth. Those whose latest versions
+// cannot be looked up are skipped.
+func findUpdates(ctx *context.Context) []Update {
+	debugLogger := ctx.Logger().WithFields(log.Fields{
+		"package": "updatecheck",
+		"method":  "findUpdates",
+	})
+
+	updates := make([]Update, 0)
+
+	if latestVersion, err := selfupdate.GetLatestVersion(ctx); err != nil {
+		debugLogger.Debugf("Cannot look up latest version of lip: %v", err)
+	} else if latestVersion.GT(ctx.LipVersion()) {
+		updates = append(updates, Update{
+			ToothRepoPath:  selfupdate.LipRepoPath,
+			CurrentVersion: ctx.LipVersion(),
+			LatestVersion:  latestVersion,
+		})
+	}
+
+	metadataList, err := tooth.GetAllMetadata(ctx)
+	if err != nil {
+		debugLogger.Debugf("Cannot list installed teeth: %v", err)
+		return updates
+	}
+
+	for _, metadata := range metadataList {
+		latestVersion, err := tooth.GetLatestVersion(ctx, metadata.ToothRepoPath())
+		if err != nil {
+			debugLogger.Debugf("Cannot look up latest version of %v: %v", metadata.ToothRepoPath(), err)
+			continue
+		}
+
+		if latestVersion.GT(metadata.Version()) {
+			updates = append(updates, Update{
+				ToothRepoPath:  metadata.ToothRepoPath(),
+				CurrentVersion: metadata.Version(),
+				LatestVersion:  latestVersion,
+			})
+		}
+	}
+
+	return updates
+}
+
+// notify shows the updates found, and how to apply them.
+func notify(ctx *context.Context, updates []Update) {
+	isToothUpdated := false
+
+	for _, update := range updates {
+		if update.ToothRepoPath == selfupdate.LipRepoPath {
+			ctx.Logger().Infof(i18n.T("A new version of lip is available: %v -> %v. Run lip self update to update."),
+				update.CurrentVersion, update.LatestVersion)
+			continue
+		}
+
+		if !isToothUpdated {
+			ctx.Logger().Info(i18n.T("Updates of installed teeth are available:"))
+			isToothUpdated = true
+		}
+
+		ctx.Logger().Infof("  %v: %v -> %v", update.ToothRepoPath, update.CurrentVersion, update.LatestVersion)
+	}
+
+	if isToothUpdated {
+		ctx.Logger().Info(i18n.T("Run lip install --upgrade <tooth> to update."))
+	}
+}
+
+// loadState loads the state of the update check. The zero state is returned if no check has
+// run yet.
+func loadState(ctx *context.Context) (State, error) {
+	stateFilePath, err := ctx.UpdateCheckFilePath()
+	if err != nil {
+		return State{}, fmt.Errorf("failed to get update check file path\n\t%w", err)
+	}
+
+	jsonBytes, err := ctx.FS().ReadFile(stateFilePath.LocalString())
+	if os.IsNotExist(err) {
+		return State{}, nil
+	} else if err != nil {
+		return State{}, fmt.Errorf("failed to read %v\n\t%w", stateFilePath.LocalString(), err)
+	}
+
+	var state State
+	if err := json.Unmarshal(jsonBytes, &state); err != nil {
+		// A corrupted state only makes the check run now.
+		return State{}, nil
+	}
+
+	return state, nil
+}
+
+// saveState saves the state of the update check.
+func saveState(ctx *context.Context, state State) error {
+	stateFilePath, err := ctx.UpdateCheckFilePath()
+	if err != nil {
+		return fmt.Errorf("failed to get update check file path\n\t%w", err)
+	}
+
+	jsonBytes, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal update check state\n\t%w", err)
+	}
+
+	if err := ctx.FS().WriteFile(stateFilePath.LocalString(), jsonBytes, 0644); err != nil {
+		return fmt.Errorf("failed to write %v\n\t%w", stateFilePath.LocalString(), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,390 @@
+package vendoring
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/errcode"
+	"github.com/lippkg/lip/internal/network"
+	"github.com/lippkg/lip/internal/path"
+	"github.com/lippkg/lip/internal/vfs"
+)
+
+const manifestFileName = "vendor.json"
+
+const assetDirName = "assets"
+
+const expectedFormatVersion = 1
+
+// Manifest lists the teeth in the vendor directory with the checksums of their archives.
+type Manifest struct {
+	FormatVersion int                      `json:"format_version"`
+	Teeth         map[string]VendoredTooth `json:"teeth"`
+}
+
+// VendoredTooth is a tooth in the vendor directory.
+type VendoredTooth struct {
+	Version string `json:"version"`
+	// Checksum is the hex-encoded SHA-256 checksum of the tooth archive.
+	Checksum string `json:"checksum"`
+	// Assets maps asset URLs to the checksums of the asset archives. A tooth has an asset
+	// archive for each platform it is vendored on.
+	Assets map[string]string `json:"assets,omitempty"`
+}
+
+// Vendor is the content of the vendor directory being rewritten by lip vendor.
+type Vendor struct {
+	fs       vfs.FS
+	dir      path.Path
+	previous Manifest
+	manifest Manifest
+}
+
+// Open starts rewriting the vendor directory, creating it if it does not exist.
+func Open(ctx *context.Context) (*Vendor, error) {
+	dir, err := ctx.VendorDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get vendor directory\n\t%w", err)
+	}
+
+	previous, err := loadManifest(ctx.FS(), dir)
+	if os.IsNotExist(err) {
+		previous = Manifest{FormatVersion: expectedFormatVersion, Teeth: make(map[string]VendoredTooth)}
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := ctx.FS().MkdirAll(dir.Join(path.MustParse(assetDirName)).LocalString(), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create vendor directory %v\n\t%w", dir.LocalString(), err)
+	}
+
+	return &Vendor{
+		fs:       ctx.FS(),
+		dir:      dir,
+		previous: previous,
+		manifest: Manifest{FormatVersion: expectedFormatVersion, Teeth: make(map[string]VendoredTooth)},
+	}, nil
+}
+
+// Add copies the tooth archive of a tooth version into the vendor directory, and its asset
+// archive if assetURL is not empty. Asset archives vendored on other platforms for the same
+// version are kept.
+func (v *Vendor) Add(toothRepoPath string, version semver.Version, archivePath path.Path, assetURL string,
+	assetPath path.Path) error {
+	archiveFilePath, err := getArchiveFilePath(v.dir, toothRepoPath, version)
+	if err != nil {
+		return err
+	}
+
+	checksum, err := copyFile(v.fs, archivePath, archiveFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to copy tooth archive of %v@%v\n\t%w", toothRepoPath, version, err)
+	}
+
+	vendoredTooth := VendoredTooth{
+		Version:  version.String(),
+		Checksum: checksum,
+		Assets:   make(map[string]string),
+	}
+
+	if previous, ok := v.previous.Teeth[toothRepoPath]; ok && previous.Version == version.String() {
+		for previousAssetURL, assetChecksum := range previous.Assets {
+			vendoredTooth.Assets[previousAssetURL] = assetChecksum
+		}
+	}
+
+	if assetURL != "" {
+		// Asset archives are named by their checksums, since they are looked up by URL.
+		tempFilePath := v.dir.Join(path.MustParse(assetDirName)).Join(path.MustParse("asset.zip.part"))
+
+		assetChecksum, err := copyFile(v.fs, assetPath, tempFilePath)
+		if err != nil {
+			return fmt.Errorf("failed to copy asset archive of %v@%v\n\t%w", toothRepoPath, version, err)
+		}
+
+		if err := v.fs.Rename(tempFilePath.LocalString(),
+			getAssetFilePath(v.dir, assetChecksum).LocalString()); err != nil {
+			return fmt.Errorf("failed to move asset archive of %v@%v\n\t%w", toothRepoPath, version, err)
+		}
+
+		vendoredTooth.Assets[assetURL] = assetChecksum
+	}
+
+	v.manifest.Teeth[toothRepoPath] = vendoredTooth
+
+	return nil
+}
+
+// Close saves the manifest and removes the files of teeth that are no longer vendored. It
+// returns the number of teeth vendored.
+func (v *Vendor) Close() (int, error) {
+	jsonBytes, err := json.MarshalIndent(v.manifest, "", "    ")
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal vendor manifest\n\t%w", err)
+	}
+
+	manifestPath := v.dir.Join(path.MustParse(manifestFileName))
+	if err := v.fs.WriteFile(manifestPath.LocalString(), jsonBytes, 0644); err != nil {
+		return 0, fmt.Errorf("failed to write vendor manifest %v\n\t%w", manifestPath.LocalString(), err)
+	}
+
+	if err := v.removeStaleFiles(); err != nil {
+		return 0, err
+	}
+
+	return len(v.manifest.Teeth), nil
+}
+
+// GetVersionStrings returns the version of a tooth in the vendor directory, in the format of the
+// version list of the Go module proxy, e.g. v1.2.3.
+func GetVersionStrings(ctx *context.Context, toothRepoPath string) ([]string, error) {
+	_, vendoredTooth, err := getVendoredTooth(ctx, toothRepoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{"v" + vendoredTooth.Version}, nil
+}
+
+// GetArchivePath returns the path of the vendored tooth archive of a tooth version, after
+// verifying it against the checksum in the vendor manifest.
+func GetArchivePath(ctx *context.Context, toothRepoPath string, version semver.Version) (path.Path, error) {
+	dir, vendoredTooth, err := getVendoredTooth(ctx, toothRepoPath)
+	if err != nil {
+		return path.Path{}, err
+	}
+
+	if vendoredTooth.Version != version.String() {
+		return path.Path{}, errcode.Errorf(errcode.NotVendored, "%v@%v is not vendored, the vendored version is %v",
+			toothRepoPath, version, vendoredTooth.Version)
+	}
+
+	archiveFilePath, err := getArchiveFilePath(dir, toothRepoPath, version)
+	if err != nil {
+		return path.Path{}, err
+	}
+
+	if err := verifyFile(ctx.FS(), archiveFilePath, vendoredTooth.Checksum); err != nil {
+		return path.Path{}, err
+	}
+
+	return archiveFilePath, nil
+}
+
+// GetAssetPath returns the path of the vendored asset archive of a tooth version downloaded from
+// assetURL, after verifying it against the checksum in the vendor manifest.
+func GetAssetPath(ctx *context.Context, toothRepoPath string, assetURL string) (path.Path, error) {
+	dir, vendoredTooth, err := getVendoredTooth(ctx, toothRepoPath)
+	if err != nil {
+		return path.Path{}, err
+	}
+
+	assetChecksum, ok := vendoredTooth.Assets[assetURL]
+	if !ok {
+		return path.Path{}, errcode.Errorf(errcode.NotVendored,
+			"asset archive %v of %v is not vendored. Run lip vendor on this platform", assetURL, toothRepoPath)
+	}
+
+	assetFilePath := getAssetFilePath(dir, assetChecksum)
+
+	if err := verifyFile(ctx.FS(), assetFilePath, assetChecksum); err != nil {
+		return path.Path{}, err
+	}
+
+	return assetFilePath, nil
+}
+
+// ---------------------------------------------------------------------
+
+// copyFile copies a file on a file system and returns its hex-encoded SHA-256 checksum.
+func copyFile(fs vfs.FS, src path.Path, dst path.Path) (string, error) {
+	parentDir, err := dst.Dir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get parent directory of %v\n\t%w", dst.LocalString(), err)
+	}
+
+	if err := fs.MkdirAll(parentDir.LocalString(), 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory %v\n\t%w", parentDir.LocalString(), err)
+	}
+
+	srcFile, err := fs.Open(src.LocalString())
+	if err != nil {
+		return "", fmt.Errorf("failed to open %v\n\t%w", src.LocalString(), err)
+	}
+	defer srcFile.Close()
+
+	dstFile, err := fs.Create(dst.LocalString())
+	if err != nil {
+		return "", fmt.Errorf("failed to create %v\n\t%w", dst.LocalString(), err)
+	}
+	defer dstFile.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(dstFile, hash), srcFile); err != nil {
+		return "", fmt.Errorf("failed to copy %v to %v\n\t%w", src.LocalString(), dst.LocalString(), err)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// getArchiveFilePath returns the path of the tooth archive of a tooth version in the vendor
+// directory, e.g. vendor/github.com/owner/repo@v1.2.3.zip. The tooth repo path is escaped like
+// in the Go module cache, so that paths differing only in case do not collide.
+func getArchiveFilePath(dir path.Path, toothRepoPath string, version semver.Version) (path.Path, error) {
+	escapedPath, err := network.EscapeToothRepoPath(toothRepoPath)
+	if err != nil {
+		return path.Path{}, fmt.Errorf("failed to escape tooth repo path %v\n\t%w", toothRepoPath, err)
+	}
+
+	archiveFilePath, err := path.Parse(escapedPath + "@v" + version.String() + ".zip")
+	if err != nil {
+		return path.Path{}, fmt.Errorf("failed to parse vendored archive path of %v@%v\n\t%w", toothRepoPath,
+			version, err)
+	}
+
+	return dir.Join(archiveFilePath), nil
+}
+
+// getAssetFilePath returns the path of an asset archive in the vendor directory.
+func getAssetFilePath(dir path.Path, checksum string) path.Path {
+	return dir.Join(path.MustParse(assetDirName)).Join(path.MustParse(checksum + ".zip"))
+}
+
+// getVendoredTooth returns the vendor directory and the vendored tooth of a tooth repo path.
+func getVendoredTooth(ctx *context.Context, toothRepoPath string) (path.Path, VendoredTooth, error) {
+	dir, err := ctx.VendorDir()
+	if err != nil {
+		return path.Path{}, VendoredTooth{}, fmt.Errorf("failed to get vendor directory\n\t%w", err)
+	}
+
+	manifest, err := loadManifest(ctx.FS(), dir)
+	if os.IsNotExist(err) {
+		return path.Path{}, VendoredTooth{}, errcode.Errorf(errcode.NotVendored,
+			"no vendor directory found. Run lip vendor first")
+	} else if err != nil {
+		return path.Path{}, VendoredTooth{}, err
+	}
+
+	vendoredTooth, ok := manifest.Teeth[toothRepoPath]
+	if !ok {
+		return path.Path{}, VendoredTooth{}, errcode.Errorf(errcode.NotVendored, "%v is not vendored",
+			toothRepoPath)
+	}
+
+	return dir, vendoredTooth, nil
+}
+
+// loadManifest reads the vendor manifest in a vendor directory on a file system. The error
+// satisfies os.IsNotExist if there is none.
+func loadManifest(fs vfs.FS, dir path.Path) (Manifest, error) {
+	manifestPath := dir.Join(path.MustParse(manifestFileName))
+
+	jsonBytes, err := fs.ReadFile(manifestPath.LocalString())
+	if os.IsNotExist(err) {
+		return Manifest{}, err
+	} else if err != nil {
+		return Manifest{}, fmt.Errorf("failed to read vendor manifest %v\n\t%w", manifestPath.LocalString(), err)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(jsonBytes, &manifest); err != nil {
+		return Manifest{}, fmt.Errorf("failed to unmarshal vendor manifest %v\n\t%w", manifestPath.LocalString(),
+			err)
+	}
+
+	if manifest.FormatVersion != expectedFormatVersion {
+		return Manifest{}, fmt.Errorf("unsupported format version of vendor manifest: %v", manifest.FormatVersion)
+	}
+
+	if manifest.Teeth == nil {
+		manifest.Teeth = make(map[string]VendoredTooth)
+	}
+
+	return manifest, nil
+}
+
+// removeStaleFiles removes the files of teeth and asset archives that were vendored before but
+// are not in the new manifest.
+func (v *Vendor) removeStaleFiles() error {
+	// Paths are kept as strings, since joined paths may share their underlying arrays.
+	staleFilePaths := make([]string, 0)
+
+	assetChecksums := make(map[string]bool)
+	for _, vendoredTooth := range v.manifest.Teeth {
+		for _, assetChecksum := range vendoredTooth.Assets {
+			assetChecksums[assetChecksum] = true
+		}
+	}
+
+	toothRepoPaths := make([]string, 0, len(v.previous.Teeth))
+	for toothRepoPath := range v.previous.Teeth {
+		toothRepoPaths = append(toothRepoPaths, toothRepoPath)
+	}
+	sort.Strings(toothRepoPaths)
+
+	for _, toothRepoPath := range toothRepoPaths {
+		previous := v.previous.Teeth[toothRepoPath]
+
+		for _, assetChecksum := range previous.Assets {
+			if !assetChecksums[assetChecksum] {
+				staleFilePaths = append(staleFilePaths, getAssetFilePath(v.dir, assetChecksum).LocalString())
+			}
+		}
+
+		if current, ok := v.manifest.Teeth[toothRepoPath]; ok && current.Version == previous.Version {
+			continue
+		}
+
+		version, err := semver.Parse(previous.Version)
+		if err != nil {
+			continue
+		}
+
+		archiveFilePath, err := getArchiveFilePath(v.dir, toothRepoPath, version)
+		if err != nil {
+			continue
+		}
+
+		staleFilePaths = append(staleFilePaths, archiveFilePath.LocalString())
+	}
+
+	for _, staleFilePath := range staleFilePaths {
+		if err := v.fs.Remove(staleFilePath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %v\n\t%w", staleFilePath, err)
+		}
+	}
+
+	return nil
+}
+
+// verifyFile checks that a vendored file on a file system has the checksum recorded in the
+// vendor manifest.
+func verifyFile(fs vfs.FS, filePath path.Path, checksum string) error {
+	file, err := fs.Open(filePath.LocalString())
+	if os.IsNotExist(err) {
+		return errcode.Errorf(errcode.NotVendored, "vendored file %v is missing. Run lip vendor again",
+			filePath.LocalString())
+	} else if err != nil {
+		return fmt.Errorf("failed to open %v\n\t%w", filePath.LocalString(), err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return fmt.Errorf("failed to read %v\n\t%w", filePath.LocalString(), err)
+	}
+
+	if actual := hex.EncodeToString(hash.Sum(nil)); actual != checksum {
+		return errcode.Errorf(errcode.ChecksumMismatch, "checksum mismatch of %v: expected %v, got %v",
+			filePath.LocalString(), checksum, actual)
+	}
+
+	return nil
+}
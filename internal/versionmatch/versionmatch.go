@@ -0,0 +1,180 @@
+package versionmatch
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/blang/semver/v4"
+)
+
+// Kind is how a version match selects versions.
+type Kind int
+
+const (
+	// LatestKind selects the latest stable version, or the latest pre-release version if there
+	// is no stable version.
+	LatestKind Kind = iota
+	// PrereleaseKind selects the latest version, including pre-release versions.
+	PrereleaseKind
+	// ExactKind selects a specific version.
+	ExactKind
+	// RangeKind selects the latest version in a version range, preferring stable versions
+	// like LatestKind.
+	RangeKind
+)
+
+const latestKeyword = "latest"
+const prereleaseKeyword = "prerelease"
+
+// VersionMatch selects a version of a tooth, e.g. 1.2.3, ^1.2, >=1.0.0 <2.0.0, latest or
+// prerelease.
+type VersionMatch struct {
+	kind               Kind
+	version            semver.Version
+	versionRange       semver.Range
+	versionRangeString string
+}
+
+// Latest returns the version match used when no version is specified.
+func Latest() VersionMatch {
+	return VersionMatch{kind: LatestKind}
+}
+
+// Parse parses a version match. Besides exact versions, keywords and the ranges supported by
+// semver.ParseRange, caret ranges (^1.2) and tilde ranges (~1.2) are supported.
+func Parse(s string) (VersionMatch, error) {
+	switch s {
+	case latestKeyword:
+		return VersionMatch{kind: LatestKind}, nil
+
+	case prereleaseKeyword:
+		return VersionMatch{kind: PrereleaseKind}, nil
+	}
+
+	if version, err := semver.Parse(s); err == nil {
+		return VersionMatch{kind: ExactKind, version: version}, nil
+	}
+
+	versionRangeString := s
+	if strings.HasPrefix(s, "^") || strings.HasPrefix(s, "~") {
+		convertedRangeString, err := convertShorthandRange(s)
+		if err != nil {
+			return VersionMatch{}, err
+		}
+
+		versionRangeString = convertedRangeString
+	}
+
+	versionRange, err := semver.ParseRange(versionRangeString)
+	if err != nil {
+		return VersionMatch{}, fmt.Errorf("invalid version match %v\n\t%w", s, err)
+	}
+
+	return VersionMatch{
+		kind:               RangeKind,
+		versionRange:       versionRange,
+		versionRangeString: versionRangeString,
+	}, nil
+}
+
+// Kind returns the kind of the version match.
+func (m VersionMatch) Kind() Kind {
+	return m.kind
+}
+
+// Version returns the version of an exact version match.
+func (m VersionMatch) Version() (semver.Version, error) {
+	if m.kind != ExactKind {
+		return semver.Version{}, fmt.Errorf("version match is not an exact version")
+	}
+
+	return m.version, nil
+}
+
+// Range returns the version range of a range version match.
+func (m VersionMatch) Range() (semver.Range, error) {
+	if m.kind != RangeKind {
+		return nil, fmt.Errorf("version match is not a version range")
+	}
+
+	return m.versionRange, nil
+}
+
+// RangeString returns the version range of a range version match in the syntax of
+// semver.ParseRange, e.g. >=1.2.0 <2.0.0 for ^1.2.
+func (m VersionMatch) RangeString() (string, error) {
+	if m.kind != RangeKind {
+		return "", fmt.Errorf("version match is not a version range")
+	}
+
+	return m.versionRangeString, nil
+}
+
+// String returns the string representation of the version match.
+func (m VersionMatch) String() string {
+	switch m.kind {
+	case LatestKind:
+		return latestKeyword
+
+	case PrereleaseKind:
+		return prereleaseKeyword
+
+	case ExactKind:
+		return m.version.String()
+
+	case RangeKind:
+		return m.versionRangeString
+	}
+
+	// Never reached.
+	panic("unreachable")
+}
+
+// ---------------------------------------------------------------------
+
+// convertShorthandRange converts a caret or tilde range to the syntax of semver.ParseRange.
+// Missing minor and patch numbers are zero.
+//
+//   - ^1.2.3 means >=1.2.3 <2.0.0, ^0.2.3 means >=0.2.3 <0.3.0, and ^0.0.3 means >=0.0.3 <0.0.4.
+//   - ~1.2.3 means >=1.2.3 <1.3.0, and ~1 means >=1.0.0 <2.0.0.
+func convertShorthandRange(s string) (string, error) {
+	operator := s[:1]
+
+	parts := strings.Split(s[1:], ".")
+	if len(parts) > 3 {
+		return "", fmt.Errorf("invalid version range %v: too many parts", s)
+	}
+
+	numbers := [3]uint64{}
+	for i, part := range parts {
+		number, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("invalid version range %v\n\t%w", s, err)
+		}
+
+		numbers[i] = number
+	}
+
+	lower := semver.Version{Major: numbers[0], Minor: numbers[1], Patch: numbers[2]}
+
+	var upper semver.Version
+	switch {
+	case operator == "~" && len(parts) == 1:
+		upper = semver.Version{Major: lower.Major + 1}
+
+	case operator == "~":
+		upper = semver.Version{Major: lower.Major, Minor: lower.Minor + 1}
+
+	case lower.Major != 0 || len(parts) == 1:
+		upper = semver.Version{Major: lower.Major + 1}
+
+	case lower.Minor != 0 || len(parts) == 2:
+		upper = semver.Version{Minor: lower.Minor + 1}
+
+	default:
+		upper = semver.Version{Patch: lower.Patch + 1}
+	}
+
+	return fmt.Sprintf(">=%v <%v", lower, upper), nil
+}
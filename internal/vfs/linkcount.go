@@ -0,0 +1,13 @@
+package vfs
+
+// LinkCount returns the number of hard links to a file on a file system.
+func LinkCount(fsys FS, name string) (uint64, error) {
+	switch fsys := fsys.(type) {
+	case *memFS:
+		return fsys.linkCount(name)
+	case readOnlyFS:
+		return LinkCount(fsys.fs, name)
+	default:
+		return getOSLinkCount(name)
+	}
+}
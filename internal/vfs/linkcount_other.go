@@ -0,0 +1,24 @@
+//go:build !windows
+
+package vfs
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// getOSLinkCount returns the number of hard links to a file on the disk.
+func getOSLinkCount(filePath string) (uint64, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return 0, err
+	}
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, fmt.Errorf("link count is not available")
+	}
+
+	return uint64(stat.Nlink), nil
+}
@@ -0,0 +1,22 @@
+package vfs
+
+import (
+	"os"
+	"syscall"
+)
+
+// getOSLinkCount returns the number of hard links to a file on the disk.
+func getOSLinkCount(filePath string) (uint64, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	var info syscall.ByHandleFileInformation
+	if err := syscall.GetFileInformationByHandle(syscall.Handle(file.Fd()), &info); err != nil {
+		return 0, err
+	}
+
+	return uint64(info.NumberOfLinks), nil
+}
@@ -0,0 +1,741 @@
+package vfs
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// maxSymlinkHops is how many symbolic links are followed in resolving a name before giving up,
+// as a link may point to itself.
+const maxSymlinkHops = 255
+
+// memFS is a file system in memory.
+type memFS struct {
+	mu      sync.Mutex
+	entries map[string]*memEntry
+}
+
+// memEntry is a file, a directory or a symbolic link in memory. Hard links to a file share its
+// entry. A symbolic link holds its target as data.
+type memEntry struct {
+	data    []byte
+	mode    os.FileMode
+	modTime time.Time
+}
+
+// Mem returns a file system in memory, e.g. to run operations without touching the disk. It
+// is empty but for the working directory of the process, which relative names are resolved
+// against like on the disk.
+func Mem() FS {
+	m := &memFS{
+		entries: make(map[string]*memEntry),
+	}
+
+	// The working directory is known to be valid, so this cannot fail.
+	_ = m.MkdirAll(".", 0755)
+
+	return m
+}
+
+func (m *memFS) Open(name string) (File, error) {
+	return m.OpenFile(name, os.O_RDONLY, 0)
+}
+
+func (m *memFS) Create(name string) (File, error) {
+	return m.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+func (m *memFS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key, err := m.resolve(name, true)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	entry, ok := m.entries[key]
+	switch {
+	case ok && flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrExist}
+
+	case ok && entry.mode.IsDir():
+		return nil, &os.PathError{Op: "open", Path: name, Err: syscall.EISDIR}
+
+	case !ok && flag&os.O_CREATE == 0:
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+
+	case !ok:
+		if !m.isDir(filepath.Dir(key)) {
+			return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+		}
+
+		entry = &memEntry{mode: perm.Perm(), modTime: time.Now()}
+		m.entries[key] = entry
+	}
+
+	isWritable := flag&(os.O_WRONLY|os.O_RDWR) != 0
+
+	if isWritable && flag&os.O_TRUNC != 0 {
+		entry.data = nil
+		entry.modTime = time.Now()
+	}
+
+	return &memFile{
+		name:       name,
+		entry:      entry,
+		fs:         m,
+		data:       append([]byte(nil), entry.data...),
+		isReadable: flag&os.O_WRONLY == 0,
+		isWritable: isWritable,
+		isAppend:   flag&os.O_APPEND != 0,
+	}, nil
+}
+
+func (m *memFS) ReadFile(name string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key, err := m.resolve(name, true)
+	if err != nil {
+		return nil, &os.PathError{Op: "read", Path: name, Err: err}
+	}
+
+	entry, ok := m.entries[key]
+	if !ok {
+		return nil, &os.PathError{Op: "read", Path: name, Err: os.ErrNotExist}
+	}
+
+	if entry.mode.IsDir() {
+		return nil, &os.PathError{Op: "read", Path: name, Err: syscall.EISDIR}
+	}
+
+	return append([]byte(nil), entry.data...), nil
+}
+
+// WriteFile writes the content of a file. Like on the disk, an existing file keeps its
+// permission bits, and its hard links see the new content.
+func (m *memFS) WriteFile(name string, data []byte, perm os.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key, err := m.resolve(name, true)
+	if err != nil {
+		return &os.PathError{Op: "write", Path: name, Err: err}
+	}
+
+	if entry, ok := m.entries[key]; ok {
+		if entry.mode.IsDir() {
+			return &os.PathError{Op: "write", Path: name, Err: syscall.EISDIR}
+		}
+
+		entry.data = append([]byte(nil), data...)
+		entry.modTime = time.Now()
+
+		return nil
+	}
+
+	if !m.isDir(filepath.Dir(key)) {
+		return &os.PathError{Op: "write", Path: name, Err: os.ErrNotExist}
+	}
+
+	m.entries[key] = &memEntry{
+		data:    append([]byte(nil), data...),
+		mode:    perm.Perm(),
+		modTime: time.Now(),
+	}
+
+	return nil
+}
+
+func (m *memFS) Stat(name string) (os.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.stat("stat", name, true)
+}
+
+// Lstat is the same as Stat, but describes a symbolic link instead of the file it points to.
+func (m *memFS) Lstat(name string) (os.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.stat("lstat", name, false)
+}
+
+func (m *memFS) ReadDir(name string) ([]os.DirEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key, err := m.resolve(name, true)
+	if err != nil {
+		return nil, &os.PathError{Op: "readdir", Path: name, Err: err}
+	}
+
+	if !m.isDir(key) {
+		return nil, &os.PathError{Op: "readdir", Path: name, Err: os.ErrNotExist}
+	}
+
+	dirEntries := make([]os.DirEntry, 0)
+	for entryKey, entry := range m.entries {
+		if entryKey != key && filepath.Dir(entryKey) == key {
+			dirEntries = append(dirEntries, fs.FileInfoToDirEntry(memFileInfo{
+				name:  filepath.Base(entryKey),
+				entry: entry,
+			}))
+		}
+	}
+
+	sort.Slice(dirEntries, func(i, j int) bool {
+		return dirEntries[i].Name() < dirEntries[j].Name()
+	})
+
+	return dirEntries, nil
+}
+
+func (m *memFS) Mkdir(name string, perm os.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key, err := m.resolve(name, false)
+	if err != nil {
+		return &os.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+
+	if _, ok := m.entries[key]; ok || isRoot(key) {
+		return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrExist}
+	}
+
+	if !m.isDir(filepath.Dir(key)) {
+		return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrNotExist}
+	}
+
+	m.entries[key] = &memEntry{
+		mode:    os.ModeDir | perm.Perm(),
+		modTime: time.Now(),
+	}
+
+	return nil
+}
+
+func (m *memFS) MkdirAll(path string, perm os.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key, err := m.resolve(path, true)
+	if err != nil {
+		return &os.PathError{Op: "mkdir", Path: path, Err: err}
+	}
+
+	// Create the missing directories from the outermost one.
+	missing := make([]string, 0)
+	for dir := key; !isRoot(dir); dir = filepath.Dir(dir) {
+		if entry, ok := m.entries[dir]; ok {
+			if !entry.mode.IsDir() {
+				return &os.PathError{Op: "mkdir", Path: dir, Err: syscall.ENOTDIR}
+			}
+			break
+		}
+
+		missing = append(missing, dir)
+	}
+
+	for i := len(missing) - 1; i >= 0; i-- {
+		m.entries[missing[i]] = &memEntry{
+			mode:    os.ModeDir | perm.Perm(),
+			modTime: time.Now(),
+		}
+	}
+
+	return nil
+}
+
+func (m *memFS) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key, err := m.resolve(name, false)
+	if err != nil {
+		return &os.PathError{Op: "remove", Path: name, Err: err}
+	}
+
+	entry, ok := m.entries[key]
+	if !ok {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrNotExist}
+	}
+
+	if entry.mode.IsDir() && m.hasChildren(key) {
+		return &os.PathError{Op: "remove", Path: name, Err: syscall.ENOTEMPTY}
+	}
+
+	delete(m.entries, key)
+
+	return nil
+}
+
+func (m *memFS) RemoveAll(path string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key, err := m.resolve(path, false)
+	if err != nil {
+		return &os.PathError{Op: "removeall", Path: path, Err: err}
+	}
+
+	for entryKey := range m.entries {
+		if entryKey == key || isInDir(entryKey, key) {
+			delete(m.entries, entryKey)
+		}
+	}
+
+	return nil
+}
+
+func (m *memFS) Rename(oldpath string, newpath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	oldKey, err := m.resolve(oldpath, false)
+	if err != nil {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: err}
+	}
+
+	newKey, err := m.resolve(newpath, false)
+	if err != nil {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: err}
+	}
+
+	if _, ok := m.entries[oldKey]; !ok {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: os.ErrNotExist}
+	}
+
+	if !m.isDir(filepath.Dir(newKey)) {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: os.ErrNotExist}
+	}
+
+	if oldKey == newKey {
+		return nil
+	}
+
+	// Like on the disk, an existing file or empty directory is replaced.
+	if entry, ok := m.entries[newKey]; ok && entry.mode.IsDir() && m.hasChildren(newKey) {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.ENOTEMPTY}
+	}
+
+	// Move the entry and everything in it if it is a directory.
+	moved := make(map[string]*memEntry)
+	for entryKey, entry := range m.entries {
+		if entryKey == oldKey || isInDir(entryKey, oldKey) {
+			delete(m.entries, entryKey)
+			moved[newKey+strings.TrimPrefix(entryKey, oldKey)] = entry
+		}
+	}
+
+	for entryKey, entry := range moved {
+		m.entries[entryKey] = entry
+	}
+
+	return nil
+}
+
+func (m *memFS) Chmod(name string, mode os.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key, err := m.resolve(name, true)
+	if err != nil {
+		return &os.PathError{Op: "chmod", Path: name, Err: err}
+	}
+
+	entry, ok := m.entries[key]
+	if !ok {
+		return &os.PathError{Op: "chmod", Path: name, Err: os.ErrNotExist}
+	}
+
+	entry.mode = entry.mode.Type() | mode.Perm()
+
+	return nil
+}
+
+func (m *memFS) Symlink(oldname string, newname string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key, err := m.resolve(newname, false)
+	if err != nil {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: err}
+	}
+
+	if _, ok := m.entries[key]; ok || isRoot(key) {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: os.ErrExist}
+	}
+
+	if !m.isDir(filepath.Dir(key)) {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: os.ErrNotExist}
+	}
+
+	m.entries[key] = &memEntry{
+		data:    []byte(oldname),
+		mode:    os.ModeSymlink | 0777,
+		modTime: time.Now(),
+	}
+
+	return nil
+}
+
+func (m *memFS) Readlink(name string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key, err := m.resolve(name, false)
+	if err != nil {
+		return "", &os.PathError{Op: "readlink", Path: name, Err: err}
+	}
+
+	entry, ok := m.entries[key]
+	if !ok {
+		return "", &os.PathError{Op: "readlink", Path: name, Err: os.ErrNotExist}
+	}
+
+	if entry.mode&os.ModeSymlink == 0 {
+		return "", &os.PathError{Op: "readlink", Path: name, Err: syscall.EINVAL}
+	}
+
+	return string(entry.data), nil
+}
+
+func (m *memFS) Link(oldname string, newname string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	oldKey, err := m.resolve(oldname, false)
+	if err != nil {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: err}
+	}
+
+	newKey, err := m.resolve(newname, false)
+	if err != nil {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: err}
+	}
+
+	entry, ok := m.entries[oldKey]
+	if !ok {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: os.ErrNotExist}
+	}
+
+	if entry.mode.IsDir() {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: os.ErrPermission}
+	}
+
+	if _, ok := m.entries[newKey]; ok || isRoot(newKey) {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: os.ErrExist}
+	}
+
+	if !m.isDir(filepath.Dir(newKey)) {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: os.ErrNotExist}
+	}
+
+	m.entries[newKey] = entry
+
+	return nil
+}
+
+// ---------------------------------------------------------------------
+
+// memFile is an open file in memory. It reads and writes a copy of the content, which is
+// written back when the file is closed.
+type memFile struct {
+	name       string
+	entry      *memEntry
+	fs         *memFS
+	data       []byte
+	offset     int64
+	isReadable bool
+	isWritable bool
+	isAppend   bool
+	isClosed   bool
+}
+
+func (f *memFile) Read(p []byte) (int, error) {
+	n, err := f.ReadAt(p, f.offset)
+	f.offset += int64(n)
+
+	return n, err
+}
+
+func (f *memFile) ReadAt(p []byte, off int64) (int, error) {
+	if !f.isReadable || f.isClosed {
+		return 0, &os.PathError{Op: "read", Path: f.name, Err: os.ErrPermission}
+	}
+
+	if off < 0 {
+		return 0, &os.PathError{Op: "read", Path: f.name, Err: syscall.EINVAL}
+	}
+
+	if off >= int64(len(f.data)) {
+		return 0, io.EOF
+	}
+
+	n := copy(p, f.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+func (f *memFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += int64(len(f.data))
+	}
+
+	if offset < 0 {
+		return 0, &os.PathError{Op: "seek", Path: f.name, Err: syscall.EINVAL}
+	}
+
+	f.offset = offset
+
+	return offset, nil
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	if !f.isWritable || f.isClosed {
+		return 0, &os.PathError{Op: "write", Path: f.name, Err: os.ErrPermission}
+	}
+
+	if f.isAppend {
+		f.offset = int64(len(f.data))
+	}
+
+	if end := f.offset + int64(len(p)); end > int64(len(f.data)) {
+		f.data = append(f.data, make([]byte, end-int64(len(f.data)))...)
+	}
+
+	n := copy(f.data[f.offset:], p)
+	f.offset += int64(n)
+
+	return n, nil
+}
+
+func (f *memFile) Close() error {
+	if f.isClosed {
+		return &os.PathError{Op: "close", Path: f.name, Err: os.ErrClosed}
+	}
+
+	f.isClosed = true
+
+	if !f.isWritable {
+		return nil
+	}
+
+	// The entry is updated even if the file was removed or renamed in the meantime, like an
+	// open file on the disk.
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	f.entry.data = f.data
+	f.entry.modTime = time.Now()
+
+	return nil
+}
+
+func (f *memFile) Name() string {
+	return f.name
+}
+
+func (f *memFile) Stat() (os.FileInfo, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	return memFileInfo{
+		name:  filepath.Base(f.name),
+		entry: &memEntry{data: f.data, mode: f.entry.mode, modTime: f.entry.modTime},
+	}, nil
+}
+
+// memFileInfo describes a file, a directory or a symbolic link in memory.
+type memFileInfo struct {
+	name  string
+	entry *memEntry
+}
+
+func (i memFileInfo) Name() string {
+	return i.name
+}
+
+func (i memFileInfo) Size() int64 {
+	return int64(len(i.entry.data))
+}
+
+func (i memFileInfo) Mode() os.FileMode {
+	return i.entry.mode
+}
+
+func (i memFileInfo) ModTime() time.Time {
+	return i.entry.modTime
+}
+
+func (i memFileInfo) IsDir() bool {
+	return i.entry.mode.IsDir()
+}
+
+func (i memFileInfo) Sys() interface{} {
+	return nil
+}
+
+// linkCount returns the number of hard links to a file.
+func (m *memFS) linkCount(name string) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key, err := m.resolve(name, true)
+	if err != nil {
+		return 0, &os.PathError{Op: "stat", Path: name, Err: err}
+	}
+
+	entry, ok := m.entries[key]
+	if !ok {
+		return 0, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+
+	count := uint64(0)
+	for _, otherEntry := range m.entries {
+		if otherEntry == entry {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// stat describes the entry of a name, following a symbolic link at the end if followLast is
+// true. The caller must hold the lock.
+func (m *memFS) stat(op string, name string, followLast bool) (os.FileInfo, error) {
+	key, err := m.resolve(name, followLast)
+	if err != nil {
+		return nil, &os.PathError{Op: op, Path: name, Err: err}
+	}
+
+	if isRoot(key) {
+		return memFileInfo{name: key, entry: &memEntry{mode: os.ModeDir | 0755}}, nil
+	}
+
+	entry, ok := m.entries[key]
+	if !ok {
+		return nil, &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+	}
+
+	return memFileInfo{name: filepath.Base(key), entry: entry}, nil
+}
+
+// resolve resolves a name into the key of its entry, following the symbolic links in it. The
+// last element is followed only if followLast is true, e.g. not to remove a link itself. The
+// entry need not exist. The caller must hold the lock.
+func (m *memFS) resolve(name string, followLast bool) (string, error) {
+	key := clean(name)
+
+	for hops := 0; hops < maxSymlinkHops; hops++ {
+		root := filepath.VolumeName(key) + string(filepath.Separator)
+		rest := strings.TrimPrefix(key, root)
+		if rest == "" || rest == key {
+			return key, nil
+		}
+
+		elements := strings.Split(rest, string(filepath.Separator))
+
+		isResolved := true
+		dir := root
+		for i, element := range elements {
+			elementKey := filepath.Join(dir, element)
+
+			entry, ok := m.entries[elementKey]
+			if ok && entry.mode&os.ModeSymlink != 0 && (i < len(elements)-1 || followLast) {
+				target := string(entry.data)
+				if !filepath.IsAbs(target) {
+					target = filepath.Join(dir, target)
+				}
+
+				key = filepath.Join(append([]string{target}, elements[i+1:]...)...)
+				isResolved = false
+				break
+			}
+
+			dir = elementKey
+		}
+
+		if isResolved {
+			return key, nil
+		}
+	}
+
+	return "", syscall.ELOOP
+}
+
+// isDir returns whether a resolved key is a directory. The caller must hold the lock.
+func (m *memFS) isDir(key string) bool {
+	if isRoot(key) {
+		return true
+	}
+
+	entry, ok := m.entries[key]
+	return ok && entry.mode.IsDir()
+}
+
+// hasChildren returns whether a resolved key has entries in it. The caller must hold the lock.
+func (m *memFS) hasChildren(key string) bool {
+	for entryKey := range m.entries {
+		if isInDir(entryKey, key) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// clean resolves a name into an absolute path, without following symbolic links.
+func clean(name string) string {
+	if filepath.IsAbs(name) {
+		return filepath.Clean(name)
+	}
+
+	workingDir, err := os.Getwd()
+	if err != nil {
+		return filepath.Clean(name)
+	}
+
+	return filepath.Join(workingDir, name)
+}
+
+// isRoot returns whether a cleaned name is a root directory, which always exists.
+func isRoot(key string) bool {
+	return key == "." || filepath.Dir(key) == key
+}
+
+// isInDir returns whether a cleaned name is in a directory, at any depth.
+func isInDir(key string, dirKey string) bool {
+	if isRoot(dirKey) {
+		return key != dirKey && strings.HasPrefix(key, dirKey)
+	}
+
+	return strings.HasPrefix(key, dirKey+string(filepath.Separator))
+}
+
+// unwrapPathError returns the cause of a path error, so that it can be reported with another
+// operation.
+func unwrapPathError(err error) error {
+	if pathErr, ok := err.(*os.PathError); ok {
+		return pathErr.Err
+	}
+
+	return err
+}
@@ -0,0 +1,94 @@
+package vfs
+
+import (
+	"fmt"
+	"os"
+)
+
+// ErrReadOnly is returned by the writing operations of a read-only file system. It wraps
+// os.ErrPermission, so errors.Is reports it as a permission error.
+var ErrReadOnly = fmt.Errorf("read-only file system: %w", os.ErrPermission)
+
+// readOnlyFS is a file system rejecting writes to another one.
+type readOnlyFS struct {
+	fs FS
+}
+
+// ReadOnly returns a file system reading from another one and rejecting all writes with
+// ErrReadOnly, e.g. to mount a workspace that must not change.
+func ReadOnly(fs FS) FS {
+	return readOnlyFS{fs: fs}
+}
+
+func (r readOnlyFS) Open(name string) (File, error) {
+	return r.fs.Open(name)
+}
+
+func (readOnlyFS) Create(name string) (File, error) {
+	return nil, &os.PathError{Op: "create", Path: name, Err: ErrReadOnly}
+}
+
+// OpenFile opens a file only for reading, and rejects flags to write or create it.
+func (r readOnlyFS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0 {
+		return nil, &os.PathError{Op: "open", Path: name, Err: ErrReadOnly}
+	}
+
+	return r.fs.OpenFile(name, flag, perm)
+}
+
+func (r readOnlyFS) ReadFile(name string) ([]byte, error) {
+	return r.fs.ReadFile(name)
+}
+
+func (readOnlyFS) WriteFile(name string, data []byte, perm os.FileMode) error {
+	return &os.PathError{Op: "write", Path: name, Err: ErrReadOnly}
+}
+
+func (r readOnlyFS) Stat(name string) (os.FileInfo, error) {
+	return r.fs.Stat(name)
+}
+
+func (r readOnlyFS) Lstat(name string) (os.FileInfo, error) {
+	return r.fs.Lstat(name)
+}
+
+func (r readOnlyFS) ReadDir(name string) ([]os.DirEntry, error) {
+	return r.fs.ReadDir(name)
+}
+
+func (readOnlyFS) Mkdir(name string, perm os.FileMode) error {
+	return &os.PathError{Op: "mkdir", Path: name, Err: ErrReadOnly}
+}
+
+func (readOnlyFS) MkdirAll(path string, perm os.FileMode) error {
+	return &os.PathError{Op: "mkdir", Path: path, Err: ErrReadOnly}
+}
+
+func (readOnlyFS) Remove(name string) error {
+	return &os.PathError{Op: "remove", Path: name, Err: ErrReadOnly}
+}
+
+func (readOnlyFS) RemoveAll(path string) error {
+	return &os.PathError{Op: "remove", Path: path, Err: ErrReadOnly}
+}
+
+func (readOnlyFS) Rename(oldpath string, newpath string) error {
+	return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: ErrReadOnly}
+}
+
+func (readOnlyFS) Chmod(name string, mode os.FileMode) error {
+	return &os.PathError{Op: "chmod", Path: name, Err: ErrReadOnly}
+}
+
+func (readOnlyFS) Symlink(oldname string, newname string) error {
+	return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: ErrReadOnly}
+}
+
+func (r readOnlyFS) Readlink(name string) (string, error) {
+	return r.fs.Readlink(name)
+}
+
+func (readOnlyFS) Link(oldname string, newname string) error {
+	return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: ErrReadOnly}
+}
@@ -0,0 +1,71 @@
+package vfs
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// maxTempTries is how many random names are tried before giving up creating a temporary file or
+// directory.
+const maxTempTries = 10000
+
+// CreateTemp creates a new file in dir on a file system like os.CreateTemp, and opens it for
+// reading and writing. The last "*" in pattern is replaced by a random string. If dir is
+// empty, the temporary directory of the operating system is used.
+func CreateTemp(fsys FS, dir string, pattern string) (File, error) {
+	var file File
+	err := tryTempNames(fsys, dir, pattern, "createtemp", func(name string) error {
+		var err error
+		file, err = fsys.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+		return err
+	})
+
+	return file, err
+}
+
+// MkdirTemp creates a new directory in dir on a file system like os.MkdirTemp, and returns its
+// name. The last "*" in pattern is replaced by a random string. If dir is empty, the temporary
+// directory of the operating system is used.
+func MkdirTemp(fsys FS, dir string, pattern string) (string, error) {
+	var dirName string
+	err := tryTempNames(fsys, dir, pattern, "mkdirtemp", func(name string) error {
+		dirName = name
+		return fsys.Mkdir(name, 0700)
+	})
+
+	return dirName, err
+}
+
+// ---------------------------------------------------------------------
+
+// tryTempNames calls create with random names made from pattern in dir until one does not
+// exist yet. The temporary directory of the operating system is created on file systems
+// without it, e.g. in memory.
+func tryTempNames(fsys FS, dir string, pattern string, op string, create func(name string) error) error {
+	if dir == "" {
+		dir = os.TempDir()
+
+		if err := fsys.MkdirAll(dir, 0755); err != nil {
+			return &os.PathError{Op: op, Path: filepath.Join(dir, pattern), Err: unwrapPathError(err)}
+		}
+	}
+
+	prefix, suffix := pattern, ""
+	if i := strings.LastIndex(pattern, "*"); i >= 0 {
+		prefix, suffix = pattern[:i], pattern[i+1:]
+	}
+
+	for try := 0; ; try++ {
+		err := create(filepath.Join(dir, prefix+strconv.FormatUint(uint64(rand.Uint32()), 10)+suffix))
+		if err == nil {
+			return nil
+		}
+
+		if !os.IsExist(err) || try >= maxTempTries {
+			return &os.PathError{Op: op, Path: filepath.Join(dir, pattern), Err: unwrapPathError(err)}
+		}
+	}
+}
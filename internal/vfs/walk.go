@@ -0,0 +1,59 @@
+package vfs
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Walk walks the file tree rooted at root on a file system like filepath.Walk, calling fn for
+// each file or directory in lexical order. Symbolic links are not followed.
+func Walk(fsys FS, root string, fn filepath.WalkFunc) error {
+	info, err := fsys.Lstat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = walk(fsys, root, info, fn)
+	}
+
+	if err == filepath.SkipDir {
+		return nil
+	}
+
+	return err
+}
+
+// ---------------------------------------------------------------------
+
+// walk walks the file tree rooted at a file or directory whose info is known.
+func walk(fsys FS, name string, info os.FileInfo, fn filepath.WalkFunc) error {
+	if !info.IsDir() {
+		return fn(name, info, nil)
+	}
+
+	dirEntries, err := fsys.ReadDir(name)
+	err1 := fn(name, info, err)
+	if err != nil || err1 != nil {
+		// The directory is reported once with the error of reading it, and skipped.
+		return err1
+	}
+
+	for _, dirEntry := range dirEntries {
+		entryName := filepath.Join(name, dirEntry.Name())
+
+		entryInfo, err := fsys.Lstat(entryName)
+		if err != nil {
+			if err := fn(entryName, entryInfo, err); err != nil && err != filepath.SkipDir {
+				return err
+			}
+			continue
+		}
+
+		if err := walk(fsys, entryName, entryInfo, fn); err != nil {
+			if !entryInfo.IsDir() || err != filepath.SkipDir {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,149 @@
+package webhook
+
+import (
+	"bytes"
+	gocontext "context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lippkg/lip/internal/context"
+	"github.com/lippkg/lip/internal/i18n"
+	"github.com/lippkg/lip/internal/network"
+)
+
+// timeout is how long to wait for each webhook, so that an unreachable one does not hold up
+// lip for long.
+const timeout = 10 * time.Second
+
+// Payload is the JSON body sent to the webhooks.
+type Payload struct {
+	Event     string    `json:"event"`
+	Workspace string    `json:"workspace"`
+	Time      time.Time `json:"time"`
+	User      string    `json:"user"`
+	Teeth     []Tooth   `json:"teeth"`
+
+	// Text and Content are a human-readable summary, for incoming webhooks of Slack and
+	// Discord respectively, which ignore the other fields.
+	Text    string `json:"text"`
+	Content string `json:"content"`
+}
+
+// Tooth is a tooth changed by an operation.
+type Tooth struct {
+	ToothRepoPath string `json:"tooth"`
+
+	// From is the version installed before the operation, or empty if the tooth was not
+	// installed.
+	From string `json:"from,omitempty"`
+
+	// To is the version installed after the operation, or empty if the tooth was uninstalled.
+	To string `json:"to,omitempty"`
+}
+
+// Notify sends an operation on teeth to the configured webhooks. Failures are logged as
+// warnings, since the operation has already succeeded.
+func Notify(ctx *context.Context, event string, user string, teeth []Tooth) {
+	webhookURLs, err := ctx.WebhookURLs()
+	if err != nil {
+		ctx.Logger().Warnf(i18n.T("Failed to get webhook URLs:\n\t%v"), err)
+		return
+	}
+
+	if len(webhookURLs) == 0 {
+		return
+	}
+
+	workspace, err := os.Getwd()
+	if err != nil {
+		ctx.Logger().Warnf(i18n.T("Failed to get workspace directory:\n\t%v"), err)
+	}
+
+	summary := getSummary(event, workspace, teeth)
+
+	payload := Payload{
+		Event:     event,
+		Workspace: workspace,
+		Time:      ctx.Clock().Now().UTC(),
+		User:      user,
+		Teeth:     teeth,
+		Text:      summary,
+		Content:   summary,
+	}
+
+	// Arrows in the summary are kept as they are rather than escaped for HTML.
+	body := &bytes.Buffer{}
+	encoder := json.NewEncoder(body)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(payload); err != nil {
+		ctx.Logger().Warnf(i18n.T("Failed to marshal webhook payload:\n\t%v"), err)
+		return
+	}
+
+	proxyURL, err := ctx.ProxyURL()
+	if err != nil {
+		ctx.Logger().Warnf(i18n.T("Failed to get proxy URL:\n\t%v"), err)
+		return
+	}
+
+	for _, webhookURL := range webhookURLs {
+		if err := send(ctx.Network(), webhookURL, proxyURL, body.Bytes()); err != nil {
+			ctx.Logger().Warnf(i18n.T("Failed to notify webhook %v:\n\t%v"), webhookURL.Redacted(), err)
+		}
+	}
+}
+
+// ---------------------------------------------------------------------
+
+// getSummary returns a human-readable summary of an operation.
+func getSummary(event string, workspace string, teeth []Tooth) string {
+	lines := []string{fmt.Sprintf("lip %v in %v:", event, workspace)}
+	for _, tooth := range teeth {
+		switch {
+		case tooth.From == "":
+			lines = append(lines, fmt.Sprintf("- %v: installed %v", tooth.ToothRepoPath, tooth.To))
+
+		case tooth.To == "":
+			lines = append(lines, fmt.Sprintf("- %v: uninstalled %v", tooth.ToothRepoPath, tooth.From))
+
+		case tooth.From == tooth.To:
+			lines = append(lines, fmt.Sprintf("- %v: reinstalled %v", tooth.ToothRepoPath, tooth.To))
+
+		default:
+			lines = append(lines, fmt.Sprintf("- %v: %v -> %v", tooth.ToothRepoPath, tooth.From, tooth.To))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// send posts a payload to a webhook.
+func send(client *network.Client, webhookURL *url.URL, proxyURL *url.URL, body []byte) error {
+	requestCtx, cancel := gocontext.WithTimeout(gocontext.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(requestCtx, http.MethodPost, webhookURL.String(), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("cannot create HTTP request\n\t%w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "lip")
+
+	resp, err := client.SendRequest(req, proxyURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected HTTP status %v", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,230 @@
+package workspace
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/blang/semver/v4"
+	"github.com/lippkg/lip/internal/path"
+	"github.com/lippkg/lip/internal/tooth"
+	"github.com/lippkg/lip/internal/vfs"
+)
+
+// Manifest is the workspace manifest declaring the teeth a workspace requires.
+type Manifest struct {
+	FormatVersion int                `json:"format_version"`
+	Teeth         map[string]string  `json:"teeth"`
+	Profiles      map[string]Profile `json:"profiles,omitempty"`
+	Overrides     map[string]string  `json:"overrides,omitempty"`
+	// Remaps moves the files of teeth from directories of the workspace to others. Each key is
+	// a tooth repo path and each value maps the directories to move from to those to move to.
+	Remaps map[string]map[string]string `json:"remaps,omitempty"`
+	// Layout overrides the directories of the logical placement roots of the layout profile of
+	// the workspace type. Each key is a root and each value is a directory of the workspace.
+	Layout map[string]string `json:"layout,omitempty"`
+}
+
+// Profile is a named set of teeth installed in addition to the base teeth.
+type Profile struct {
+	Teeth map[string]string `json:"teeth"`
+}
+
+const expectedFormatVersion = 1
+
+const manifestFileName = "workspace.json"
+
+// MakeManifest parses the given jsonBytes and returns a Manifest.
+func MakeManifest(jsonBytes []byte) (Manifest, error) {
+	var manifest Manifest
+	if err := json.Unmarshal(jsonBytes, &manifest); err != nil {
+		return Manifest{}, fmt.Errorf("failed to unmarshal workspace manifest\n\t%w", err)
+	}
+
+	if manifest.FormatVersion != expectedFormatVersion {
+		return Manifest{}, fmt.Errorf("unsupported format version: %v", manifest.FormatVersion)
+	}
+
+	if err := validateTeeth(manifest.Teeth); err != nil {
+		return Manifest{}, err
+	}
+
+	for profileName, profile := range manifest.Profiles {
+		if err := validateTeeth(profile.Teeth); err != nil {
+			return Manifest{}, fmt.Errorf("invalid profile %v\n\t%w", profileName, err)
+		}
+	}
+
+	for toothRepoPath, versionString := range manifest.Overrides {
+		if !tooth.IsValidToothRepoPath(toothRepoPath) {
+			return Manifest{}, fmt.Errorf("invalid tooth repo path %v", toothRepoPath)
+		}
+
+		if _, err := semver.Parse(versionString); err != nil {
+			return Manifest{}, fmt.Errorf("failed to parse override version \"%v\" of %v\n\t%w",
+				versionString, toothRepoPath, err)
+		}
+	}
+
+	for toothRepoPath, remaps := range manifest.Remaps {
+		if !tooth.IsValidToothRepoPath(toothRepoPath) {
+			return Manifest{}, fmt.Errorf("invalid tooth repo path %v", toothRepoPath)
+		}
+
+		for from, to := range remaps {
+			if _, err := tooth.ParseWorkspaceDir(from); err != nil {
+				return Manifest{}, fmt.Errorf("invalid remap of %v\n\t%w", toothRepoPath, err)
+			}
+
+			if _, err := tooth.ParseWorkspaceDir(to); err != nil {
+				return Manifest{}, fmt.Errorf("invalid remap of %v\n\t%w", toothRepoPath, err)
+			}
+		}
+	}
+
+	for root, dir := range manifest.Layout {
+		if !tooth.IsRootName(root) {
+			return Manifest{}, fmt.Errorf("unknown placement root %v in layout", root)
+		}
+
+		if _, err := tooth.ParseWorkspaceDir(dir); err != nil {
+			return Manifest{}, fmt.Errorf("invalid directory of placement root %v\n\t%w", root, err)
+		}
+	}
+
+	return manifest, nil
+}
+
+// IsManifestPresent returns whether the workspace has a workspace manifest on a file system.
+func IsManifestPresent(fs vfs.FS) (bool, error) {
+	manifestPath, err := GetManifestPath()
+	if err != nil {
+		return false, fmt.Errorf("failed to get workspace manifest path\n\t%w", err)
+	}
+
+	if _, err := fs.Stat(manifestPath.LocalString()); os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to check workspace manifest %v\n\t%w", manifestPath.LocalString(), err)
+	}
+
+	return true, nil
+}
+
+// LoadManifest loads the workspace manifest in the workspace directory on a file system.
+func LoadManifest(fs vfs.FS) (Manifest, error) {
+	manifestPath, err := GetManifestPath()
+	if err != nil {
+		return Manifest{}, fmt.Errorf("failed to get workspace manifest path\n\t%w", err)
+	}
+
+	jsonBytes, err := fs.ReadFile(manifestPath.LocalString())
+	if err != nil {
+		return Manifest{}, fmt.Errorf("failed to read workspace manifest %v\n\t%w", manifestPath.LocalString(), err)
+	}
+
+	manifest, err := MakeManifest(jsonBytes)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("failed to parse workspace manifest %v\n\t%w", manifestPath.LocalString(), err)
+	}
+
+	return manifest, nil
+}
+
+// GetManifestPath returns the path of the workspace manifest.
+func GetManifestPath() (path.Path, error) {
+	workspaceDirStr, err := os.Getwd()
+	if err != nil {
+		return path.Path{}, fmt.Errorf("failed to get workspace directory\n\t%w", err)
+	}
+
+	workspaceDir, err := path.Parse(workspaceDirStr)
+	if err != nil {
+		return path.Path{}, fmt.Errorf("failed to parse workspace directory\n\t%w", err)
+	}
+
+	return workspaceDir.Join(path.MustParse(manifestFileName)), nil
+}
+
+// Requirements returns the version ranges of the base teeth merged with those of the given
+// profile. If a tooth appears in both, both ranges must be satisfied. An empty profile name
+// selects the base teeth only.
+func (m Manifest) Requirements(profileName string) (map[string]semver.Range, map[string]string, error) {
+	requirements := make(map[string]semver.Range)
+	requirementsAsStrings := make(map[string]string)
+	for toothRepoPath, versionRangeString := range m.Teeth {
+		versionRange, err := semver.ParseRange(versionRangeString)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse version range \"%v\" of %v\n\t%w",
+				versionRangeString, toothRepoPath, err)
+		}
+
+		requirements[toothRepoPath] = versionRange
+		requirementsAsStrings[toothRepoPath] = versionRangeString
+	}
+
+	if profileName != "" {
+		profile, ok := m.Profiles[profileName]
+		if !ok {
+			return nil, nil, fmt.Errorf("profile %v is not defined in the workspace manifest", profileName)
+		}
+
+		for toothRepoPath, versionRangeString := range profile.Teeth {
+			versionRange, err := semver.ParseRange(versionRangeString)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to parse version range \"%v\" of %v in profile %v\n\t%w",
+					versionRangeString, toothRepoPath, profileName, err)
+			}
+
+			// The ranges are combined after parsing, since joining their strings would bind
+			// the profile range to the last alternative of a base range with ||.
+			if baseVersionRange, ok := requirements[toothRepoPath]; ok {
+				requirements[toothRepoPath] = baseVersionRange.AND(versionRange)
+				requirementsAsStrings[toothRepoPath] = fmt.Sprintf("%v and %v",
+					requirementsAsStrings[toothRepoPath], versionRangeString)
+			} else {
+				requirements[toothRepoPath] = versionRange
+				requirementsAsStrings[toothRepoPath] = versionRangeString
+			}
+		}
+	}
+
+	return requirements, requirementsAsStrings, nil
+}
+
+// OverrideVersions returns the versions the teeth are forced to by the overrides.
+func (m Manifest) OverrideVersions() map[string]semver.Version {
+	overrideVersions := make(map[string]semver.Version)
+	for toothRepoPath, versionString := range m.Overrides {
+		// Override versions are validated when the manifest is parsed.
+		overrideVersions[toothRepoPath] = semver.MustParse(versionString)
+	}
+
+	return overrideVersions
+}
+
+// RemapsOf returns the directories the files of a tooth are moved between. It is empty if the
+// tooth is not remapped.
+func (m Manifest) RemapsOf(toothRepoPath string) map[string]string {
+	remaps := make(map[string]string)
+	for from, to := range m.Remaps[toothRepoPath] {
+		remaps[from] = to
+	}
+
+	return remaps
+}
+
+func validateTeeth(teeth map[string]string) error {
+	for toothRepoPath, versionRangeString := range teeth {
+		if !tooth.IsValidToothRepoPath(toothRepoPath) {
+			return fmt.Errorf("invalid tooth repo path %v", toothRepoPath)
+		}
+
+		if _, err := semver.ParseRange(versionRangeString); err != nil {
+			return fmt.Errorf("failed to parse version range \"%v\" of %v\n\t%w",
+				versionRangeString, toothRepoPath, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package workspace
+
+import (
+	"testing"
+
+	"github.com/blang/semver/v4"
+)
+
+func TestRequirementsCombinesRangesWithOr(t *testing.T) {
+	manifest := Manifest{
+		FormatVersion: 1,
+		Teeth: map[string]string{
+			"github.com/tooth/a": "1.x || 2.x",
+		},
+		Profiles: map[string]Profile{
+			"dev": {
+				Teeth: map[string]string{
+					"github.com/tooth/a": ">=1.5.0",
+				},
+			},
+		},
+	}
+
+	requirements, _, err := manifest.Requirements("dev")
+	if err != nil {
+		t.Fatalf("Requirements returned error: %v", err)
+	}
+
+	versionRange := requirements["github.com/tooth/a"]
+
+	tests := []struct {
+		version   string
+		satisfied bool
+	}{
+		{"1.0.0", false},
+		{"1.5.0", true},
+		{"2.0.0", true},
+		{"3.0.0", false},
+	}
+
+	for _, test := range tests {
+		if satisfied := versionRange(semver.MustParse(test.version)); satisfied != test.satisfied {
+			t.Errorf("range satisfied by %v = %v, want %v", test.version, satisfied, test.satisfied)
+		}
+	}
+}
@@ -2,13 +2,48 @@ package zip
 
 import (
 	gozip "archive/zip"
+	"fmt"
 	"strings"
 
 	"github.com/lippkg/lip/internal/path"
+	"github.com/lippkg/lip/internal/vfs"
 )
 
+// ReadCloser is a zip archive opened on a file system, to be closed once read.
+type ReadCloser struct {
+	*gozip.Reader
+	file vfs.File
+}
+
+// OpenReader opens a zip archive on a file system like zip.OpenReader.
+func OpenReader(fs vfs.FS, name string) (*ReadCloser, error) {
+	file, err := fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to get file info of %v\n\t%w", name, err)
+	}
+
+	r, err := gozip.NewReader(file, fileInfo.Size())
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &ReadCloser{Reader: r, file: file}, nil
+}
+
+// Close closes the archive.
+func (rc *ReadCloser) Close() error {
+	return rc.file.Close()
+}
+
 // GetFilePaths returns a list of file paths in a zip archive. Directories are skipped.
-func GetFilePaths(r *gozip.ReadCloser) ([]path.Path, error) {
+func GetFilePaths(r *gozip.Reader) ([]path.Path, error) {
 	filePaths := make([]path.Path, 0)
 
 	for _, file := range r.File {
@@ -17,7 +52,7 @@ func GetFilePaths(r *gozip.ReadCloser) ([]path.Path, error) {
 			continue
 		}
 
-		filePath, err := path.Parse(file.Name)
+		filePath, err := path.ParseRelative(file.Name)
 		if err != nil {
 			return nil, err
 		}